@@ -18,6 +18,7 @@ type ProviderAnalytics struct {
 	CancelledBookings   int     `json:"cancelled_bookings"`
 	PendingBookings     int     `json:"pending_bookings"`
 	TotalRevenue        float64 `json:"total_revenue"`
+	TotalTips           float64 `json:"total_tips"` // ทิปสุทธิที่ได้รับ (แยกจาก revenue ของ booking)
 	AverageRating       float64 `json:"average_rating"`
 	TotalReviews        int     `json:"total_reviews"`
 	FavoriteCount       int     `json:"favorite_count"`
@@ -40,6 +41,13 @@ type RevenueBreakdown struct {
 	AvgPrice     float64 `json:"avg_price"`
 }
 
+type TipSummary struct {
+	TipCount   int     `json:"tip_count"`
+	TotalTips  float64 `json:"total_tips"`
+	NetTips    float64 `json:"net_tips"`
+	AverageTip float64 `json:"average_tip"`
+}
+
 type RatingBreakdown struct {
 	Rating5 int `json:"rating_5"`
 	Rating4 int `json:"rating_4"`
@@ -88,6 +96,16 @@ func getProviderDashboardHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.
 			return
 		}
 
+		// 2.1 Tips
+		err = dbPool.QueryRow(ctx, `
+			SELECT COALESCE(SUM(provider_net), 0)
+			FROM booking_tips
+			WHERE provider_id = $1 AND status = 'paid'
+		`, providerID).Scan(&analytics.TotalTips)
+		if err != nil {
+			analytics.TotalTips = 0
+		}
+
 		// 3. Reviews and rating
 		err = dbPool.QueryRow(ctx, `
 			SELECT 
@@ -185,14 +203,15 @@ func getRevenueBreakdownHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.H
 
 		rows, err := dbPool.Query(ctx, `
 			SELECT 
-				sp.package_name,
+				COALESCE(bs.package_name, sp.package_name) AS package_name,
 				COUNT(*) as booking_count,
 				SUM(b.total_price) as total_revenue,
 				AVG(b.total_price) as avg_price
 			FROM bookings b
 			JOIN service_packages sp ON b.package_id = sp.package_id
+			LEFT JOIN booking_snapshots bs ON bs.booking_id = b.booking_id
 			WHERE b.provider_id = $1 AND b.status = 'completed'
-			GROUP BY sp.package_name
+			GROUP BY 1
 			ORDER BY total_revenue DESC
 		`, providerID)
 		if err != nil {
@@ -210,8 +229,18 @@ func getRevenueBreakdownHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.H
 			breakdown = append(breakdown, item)
 		}
 
+		// ทิปแยกออกจากรายได้ของแพ็คเกจ
+		var tips TipSummary
+		dbPool.QueryRow(ctx, `
+			SELECT COUNT(*), COALESCE(SUM(amount), 0), COALESCE(SUM(provider_net), 0), COALESCE(AVG(amount), 0)
+			FROM booking_tips
+			WHERE provider_id = $1 AND status = 'paid'
+		`, providerID).Scan(&tips.TipCount, &tips.TotalTips, &tips.NetTips, &tips.AverageTip)
+		tips.AverageTip = roundSatang(tips.AverageTip)
+
 		c.JSON(http.StatusOK, gin.H{
 			"revenue_breakdown": breakdown,
+			"tips":              tips,
 		})
 	}
 }
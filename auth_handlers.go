@@ -57,7 +57,8 @@ func createUserHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFun
 			GenderID  *int    `json:"gender_id"` // Optional, defaults to 4 (Prefer not to say)
 			FirstName *string `json:"first_name"`
 			LastName  *string `json:"last_name"`
-			Birthdate *string `json:"birthdate"` // Optional birthdate in YYYY-MM-DD format
+			Birthdate *string `json:"birthdate"`     // Optional birthdate in YYYY-MM-DD format
+			Referral  *string `json:"referral_code"` // Optional referral code (or ?ref= from the invite link)
 		}
 
 		if err := c.ShouldBindJSON(&newUser); err != nil {
@@ -161,6 +162,9 @@ func createUserHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFun
 			}
 		}
 
+		// Referral attribution (never blocks registration)
+		referralApplied := attributeSignupReferral(ctx, dbPool, c, userID, newUser.Referral, role, nil)
+
 		// Create JWT token for automatic login after registration
 		tokenString, err := createJWT(userID)
 		if err != nil {
@@ -169,10 +173,11 @@ func createUserHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFun
 		}
 
 		c.JSON(http.StatusCreated, gin.H{
-			"message": "User created successfully",
-			"user_id": userID,
-			"role":    role,
-			"token":   tokenString,
+			"message":          "User created successfully",
+			"user_id":          userID,
+			"role":             role,
+			"token":            tokenString,
+			"referral_applied": referralApplied,
 		})
 	}
 }
@@ -0,0 +1,321 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// ================================
+// Availability (เวลาที่จองได้จริงจาก provider_schedules + bookings)
+// ================================
+//
+// เวลาว่าง = รวมช่วง 'available' แล้วหักช่วง 'blocked'/'booked' และ booking ที่ยังไม่ยกเลิก
+// (booking กัน buffer ก่อน/หลังตามที่ provider ตั้งไว้) ช่อง slot เริ่มทุก slot_interval นาที
+// ตาม timezone ของ provider และต้องมีเวลาพอสำหรับระยะเวลาแพ็คเกจ
+
+const (
+	defaultSlotIntervalMinutes = 30
+	maxAvailabilityRangeDays   = 31
+	suggestedSlotCount         = 5
+	suggestedSlotDays          = 3
+
+	// advisory lock class สำหรับ pg_advisory_xact_lock(class, provider_id)
+	providerCalendarLockClass = 4601
+)
+
+// bookingFreeStatusesSQL lists booking statuses that no longer hold the provider's time
+const bookingFreeStatusesSQL = `('cancelled', 'rejected', 'expired', 'failed')`
+
+var (
+	errSlotUnavailable      = errors.New("provider is not available at the requested time")
+	errAvailabilityRange    = errors.New("from/to must be YYYY-MM-DD with to on or after from, at most 31 days apart")
+	errAvailabilitySettings = errors.New("buffer_minutes must be 0-240 and slot_interval_minutes 5-240")
+)
+
+// slotConflictError is returned when the requested time is taken. It unwraps to
+// errSlotUnavailable and carries the nearest bookable alternatives.
+type slotConflictError struct {
+	SuggestedSlots []AvailabilitySlot
+}
+
+func (e *slotConflictError) Error() string { return errSlotUnavailable.Error() }
+func (e *slotConflictError) Unwrap() error { return errSlotUnavailable }
+
+// isExclusionViolation reports whether err is Postgres rejecting an overlapping booking
+// (exclusion constraint bookings_no_overlap / provider_schedules_no_overlap)
+func isExclusionViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23P01"
+}
+
+// timeRange is a half-open interval [Start, End)
+type timeRange struct {
+	Start, End time.Time
+}
+
+// AvailabilitySlot is a bookable start time for a package
+type AvailabilitySlot struct {
+	StartTime   time.Time `json:"start_time"`
+	EndTime     time.Time `json:"end_time"`
+	BookingDate string    `json:"booking_date"` // YYYY-MM-DD (timezone ของ provider) สำหรับ POST /bookings/quote
+	StartClock  string    `json:"start_clock"`  // HH:MM (timezone ของ provider)
+}
+
+// AvailabilitySettings are a provider's slot preferences
+type AvailabilitySettings struct {
+	BufferMinutes       int    `json:"buffer_minutes"`        // เวลาพักระหว่าง booking
+	SlotIntervalMinutes int    `json:"slot_interval_minutes"` // ช่อง slot เริ่มทุกกี่นาที
+	Timezone            string `json:"timezone"`              // IANA เช่น Asia/Bangkok
+}
+
+func (s AvailabilitySettings) validate() error {
+	if s.BufferMinutes < 0 || s.BufferMinutes > 240 || s.SlotIntervalMinutes < 5 || s.SlotIntervalMinutes > 240 {
+		return errAvailabilitySettings
+	}
+	if _, err := loadTimezone(s.Timezone); err != nil {
+		return err
+	}
+	return nil
+}
+
+// location is the provider's time zone (Asia/Bangkok when unset)
+func (s AvailabilitySettings) location() *time.Location {
+	return timezoneOrDefault(s.Timezone)
+}
+
+// mergeRanges sorts ranges and joins the ones that overlap or touch
+func mergeRanges(rs []timeRange) []timeRange {
+	sorted := make([]timeRange, 0, len(rs))
+	for _, r := range rs {
+		if r.End.After(r.Start) {
+			sorted = append(sorted, r)
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Start.Before(sorted[j].Start) })
+
+	merged := make([]timeRange, 0, len(sorted))
+	for _, r := range sorted {
+		if n := len(merged); n > 0 && !r.Start.After(merged[n-1].End) {
+			if r.End.After(merged[n-1].End) {
+				merged[n-1].End = r.End
+			}
+			continue
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
+
+// subtractRanges removes every busy range from free
+func subtractRanges(free, busy []timeRange) []timeRange {
+	result := mergeRanges(free)
+	for _, b := range mergeRanges(busy) {
+		next := make([]timeRange, 0, len(result))
+		for _, f := range result {
+			if !b.Start.Before(f.End) || !b.End.After(f.Start) {
+				next = append(next, f) // ไม่ทับกัน
+				continue
+			}
+			if b.Start.After(f.Start) {
+				next = append(next, timeRange{f.Start, b.Start})
+			}
+			if b.End.Before(f.End) {
+				next = append(next, timeRange{b.End, f.End})
+			}
+		}
+		result = next
+	}
+	return result
+}
+
+// rangesCover reports whether r lies entirely inside one of the free ranges
+func rangesCover(free []timeRange, r timeRange) bool {
+	for _, f := range free {
+		if !r.Start.Before(f.Start) && !r.End.After(f.End) {
+			return true
+		}
+	}
+	return false
+}
+
+// alignUp rounds t up to the next multiple of step counted from local midnight in loc
+func alignUp(t time.Time, step time.Duration, loc *time.Location) time.Time {
+	midnight := startOfLocalDay(t, loc)
+	offset := t.Sub(midnight)
+	if rem := offset % step; rem != 0 {
+		offset += step - rem
+	}
+	return midnight.Add(offset)
+}
+
+// bookableSlots lists the start times in [from, to) whose whole duration fits in a free range
+// and that start no earlier than notBefore. Slots are aligned and labelled in loc.
+func bookableSlots(free []timeRange, duration, step time.Duration, from, to, notBefore time.Time, loc *time.Location) []AvailabilitySlot {
+	slots := make([]AvailabilitySlot, 0)
+	if duration <= 0 || step <= 0 {
+		return slots
+	}
+	if notBefore.After(from) {
+		from = notBefore
+	}
+	for _, f := range free {
+		start := f.Start
+		if start.Before(from) {
+			start = from
+		}
+		for s := alignUp(start, step, loc); s.Before(to) && !s.Add(duration).After(f.End); s = s.Add(step) {
+			local := s.In(loc)
+			slots = append(slots, AvailabilitySlot{
+				StartTime:   local,
+				EndTime:     s.Add(duration).In(loc),
+				BookingDate: local.Format("2006-01-02"),
+				StartClock:  local.Format("15:04"),
+			})
+		}
+	}
+	return slots
+}
+
+// nearestSlots returns up to n slots closest to target, in time order
+func nearestSlots(slots []AvailabilitySlot, target time.Time, n int) []AvailabilitySlot {
+	distance := func(s AvailabilitySlot) time.Duration {
+		d := s.StartTime.Sub(target)
+		if d < 0 {
+			return -d
+		}
+		return d
+	}
+	nearest := append([]AvailabilitySlot(nil), slots...)
+	sort.SliceStable(nearest, func(i, j int) bool { return distance(nearest[i]) < distance(nearest[j]) })
+	if len(nearest) > n {
+		nearest = nearest[:n]
+	}
+	sort.Slice(nearest, func(i, j int) bool { return nearest[i].StartTime.Before(nearest[j].StartTime) })
+	if nearest == nil {
+		nearest = []AvailabilitySlot{}
+	}
+	return nearest
+}
+
+// availabilityQuerier is implemented by both *pgxpool.Pool and pgx.Tx
+type availabilityQuerier interface {
+	rowQuerier
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+}
+
+// loadAvailabilitySettings returns the provider's settings (defaults when never saved)
+func loadAvailabilitySettings(ctx context.Context, db rowQuerier, providerID int) AvailabilitySettings {
+	s := AvailabilitySettings{SlotIntervalMinutes: defaultSlotIntervalMinutes, Timezone: defaultTimezone}
+	db.QueryRow(ctx, `
+		SELECT buffer_minutes, slot_interval_minutes, timezone FROM provider_availability_settings WHERE provider_id = $1
+	`, providerID).Scan(&s.BufferMinutes, &s.SlotIntervalMinutes, &s.Timezone)
+	return s
+}
+
+func scanTimeRanges(rows pgx.Rows, pad time.Duration) ([]timeRange, error) {
+	defer rows.Close()
+	ranges := make([]timeRange, 0)
+	for rows.Next() {
+		var r timeRange
+		if err := rows.Scan(&r.Start, &r.End); err != nil {
+			return nil, err
+		}
+		ranges = append(ranges, timeRange{r.Start.Add(-pad), r.End.Add(pad)})
+	}
+	return ranges, rows.Err()
+}
+
+// loadProviderFreeTime returns the provider's free ranges overlapping [from, to).
+// excludeBookingID ignores one booking (its own time when re-checking it).
+func loadProviderFreeTime(ctx context.Context, db availabilityQuerier, providerID int, from, to time.Time, excludeBookingID int) ([]timeRange, AvailabilitySettings, error) {
+	settings := loadAvailabilitySettings(ctx, db, providerID)
+	buffer := time.Duration(settings.BufferMinutes) * time.Minute
+
+	rows, err := db.Query(ctx, `
+		SELECT start_time, end_time FROM provider_schedules
+		WHERE provider_id = $1 AND status = 'available'
+		  AND end_time > $2 AND start_time < $3
+	`, providerID, from, to)
+	if err != nil {
+		return nil, settings, err
+	}
+	available, err := scanTimeRanges(rows, 0)
+	if err != nil {
+		return nil, settings, err
+	}
+
+	// ตารางประจำสัปดาห์ (หักวันหยุด/วันลาแล้ว)
+	recurring, err := loadRecurringWindows(ctx, db, providerID, from, to)
+	if err != nil {
+		return nil, settings, err
+	}
+	for _, w := range recurring {
+		available = append(available, w.timeRange)
+	}
+
+	// ช่วงที่ไม่ว่าง (ขยายหน้าต่างเผื่อ buffer)
+	rows, err = db.Query(ctx, `
+		SELECT start_time, end_time FROM provider_schedules
+		WHERE provider_id = $1 AND status = 'blocked'
+		  AND end_time > $2 AND start_time < $3
+	`, providerID, from, to)
+	if err != nil {
+		return nil, settings, err
+	}
+	blocked, err := scanTimeRanges(rows, 0)
+	if err != nil {
+		return nil, settings, err
+	}
+
+	rows, err = db.Query(ctx, `
+		SELECT start_time, end_time FROM bookings
+		WHERE provider_id = $1 AND status NOT IN `+bookingFreeStatusesSQL+` AND booking_id <> $4
+		  AND end_time > $2 AND start_time < $3
+		UNION ALL
+		SELECT start_time, end_time FROM provider_schedules
+		WHERE provider_id = $1 AND status = 'booked' AND booking_id IS NULL -- ที่ผูก booking นับจาก bookings แล้ว
+		  AND end_time > $2 AND start_time < $3
+	`, providerID, from.Add(-buffer), to.Add(buffer), excludeBookingID)
+	if err != nil {
+		return nil, settings, err
+	}
+	booked, err := scanTimeRanges(rows, buffer)
+	if err != nil {
+		return nil, settings, err
+	}
+
+	return subtractRanges(available, append(blocked, booked...)), settings, nil
+}
+
+// checkProviderAvailable returns a *slotConflictError (with alternatives of the same length
+// around the requested day) unless [start, end) is free
+func checkProviderAvailable(ctx context.Context, db availabilityQuerier, providerID int, start, end time.Time, excludeBookingID int) error {
+	free, settings, err := loadProviderFreeTime(ctx, db, providerID, start, end, excludeBookingID)
+	if err != nil {
+		return err
+	}
+	if rangesCover(free, timeRange{start, end}) {
+		return nil
+	}
+
+	from := startOfLocalDay(start, settings.location())
+	to := from.AddDate(0, 0, suggestedSlotDays)
+	free, settings, err = loadProviderFreeTime(ctx, db, providerID, from, to, excludeBookingID)
+	if err != nil {
+		return &slotConflictError{SuggestedSlots: []AvailabilitySlot{}}
+	}
+	slots := bookableSlots(free, end.Sub(start), time.Duration(settings.SlotIntervalMinutes)*time.Minute, from, to, time.Now(), settings.location())
+	return &slotConflictError{SuggestedSlots: nearestSlots(slots, start, suggestedSlotCount)}
+}
+
+// lockProviderCalendar serializes booking writes for one provider until the transaction ends,
+// so the availability check and the insert can't interleave with another checkout
+func lockProviderCalendar(ctx context.Context, tx pgx.Tx, providerID int) error {
+	_, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock($1, $2)`, providerCalendarLockClass, providerID)
+	return err
+}
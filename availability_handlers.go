@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// parseAvailabilityRange reads ?from=&to= (YYYY-MM-DD in loc, รวมวัน to)
+// and returns [from 00:00, day after to 00:00). Defaults to the next 7 days.
+func parseAvailabilityRange(fromParam, toParam string, now time.Time, loc *time.Location) (time.Time, time.Time, error) {
+	from := startOfLocalDay(now, loc)
+	if fromParam != "" {
+		t, err := time.ParseInLocation("2006-01-02", fromParam, loc)
+		if err != nil {
+			return time.Time{}, time.Time{}, errAvailabilityRange
+		}
+		from = t
+	}
+	to := from.AddDate(0, 0, 6)
+	if toParam != "" {
+		t, err := time.ParseInLocation("2006-01-02", toParam, loc)
+		if err != nil {
+			return time.Time{}, time.Time{}, errAvailabilityRange
+		}
+		to = t
+	}
+	if to.Before(from) || to.Sub(from) >= maxAvailabilityRangeDays*24*time.Hour {
+		return time.Time{}, time.Time{}, errAvailabilityRange
+	}
+	return from, to.AddDate(0, 0, 1), nil
+}
+
+// GET /providers/:userId/availability?from=YYYY-MM-DD&to=YYYY-MM-DD&package_id= (Public)
+// เวลาที่จองแพ็คเกจนี้ได้จริง (ตาม timezone ของ provider)
+func getProviderAvailabilityHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		providerID, err := strconv.Atoi(c.Param("userId"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid provider ID"})
+			return
+		}
+		packageID, err := strconv.Atoi(c.Query("package_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "package_id is required"})
+			return
+		}
+
+		now := time.Now()
+		settings := loadAvailabilitySettings(ctx, dbPool, providerID)
+		from, to, err := parseAvailabilityRange(c.Query("from"), c.Query("to"), now, settings.location())
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		var duration int
+		var maxAdvanceDays *int
+		err = dbPool.QueryRow(ctx, `
+			SELECT duration, max_advance_days FROM service_packages
+			WHERE package_id = $1 AND provider_id = $2 AND is_active = true
+		`, packageID, providerID).Scan(&duration, &maxAdvanceDays)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Package not found"})
+			return
+		}
+		// จองล่วงหน้าได้ไม่เกิน max_advance_days ของแพ็คเกจ
+		if maxAdvanceDays != nil {
+			if limit := now.AddDate(0, 0, *maxAdvanceDays); to.After(limit) {
+				to = limit
+			}
+		}
+
+		slots := make([]AvailabilitySlot, 0)
+		if to.After(from) {
+			free, s, err := loadProviderFreeTime(ctx, dbPool, providerID, from, to, 0)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load availability"})
+				return
+			}
+			settings = s
+			slots = bookableSlots(free, time.Duration(duration)*time.Minute,
+				time.Duration(settings.SlotIntervalMinutes)*time.Minute, from, to, now, settings.location())
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"provider_id":           providerID,
+			"package_id":            packageID,
+			"duration":              duration,
+			"timezone":              settings.Timezone,
+			"from":                  from.In(settings.location()),
+			"to":                    to.In(settings.location()),
+			"buffer_minutes":        settings.BufferMinutes,
+			"slot_interval_minutes": settings.SlotIntervalMinutes,
+			"slots":                 slots,
+		})
+	}
+}
+
+// GET /provider/availability-settings - buffer/slot interval/timezone ของตัวเอง
+func getMyAvailabilitySettingsHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, loadAvailabilitySettings(ctx, dbPool, c.GetInt("userID")))
+	}
+}
+
+// PUT /provider/availability-settings
+func updateMyAvailabilitySettingsHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetInt("userID")
+
+		var req AvailabilitySettings
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if req.Timezone == "" {
+			req.Timezone = loadAvailabilitySettings(ctx, dbPool, userID).Timezone // ไม่ส่งมา = คงค่าเดิม
+		}
+		if err := req.validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		_, err := dbPool.Exec(ctx, `
+			INSERT INTO provider_availability_settings (provider_id, buffer_minutes, slot_interval_minutes, timezone)
+			VALUES ($1, $2, $3, $4)
+			ON CONFLICT (provider_id) DO UPDATE
+			SET buffer_minutes = $2, slot_interval_minutes = $3, timezone = $4, updated_at = NOW()
+		`, userID, req.BufferMinutes, req.SlotIntervalMinutes, req.Timezone)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save availability settings"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Availability settings updated", "settings": req})
+	}
+}
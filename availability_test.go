@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+)
+
+func bkk(day, hour, minute int) time.Time {
+	return time.Date(2026, 10, day, hour, minute, 0, 0, bangkokLocation)
+}
+
+func TestMergeAndSubtractRanges(t *testing.T) {
+	t.Run("merges overlapping and touching ranges", func(t *testing.T) {
+		merged := mergeRanges([]timeRange{
+			{bkk(20, 14, 0), bkk(20, 16, 0)},
+			{bkk(20, 10, 0), bkk(20, 12, 0)},
+			{bkk(20, 12, 0), bkk(20, 13, 0)},
+			{bkk(20, 15, 0), bkk(20, 18, 0)},
+		})
+		assert.Equal(t, []timeRange{
+			{bkk(20, 10, 0), bkk(20, 13, 0)},
+			{bkk(20, 14, 0), bkk(20, 18, 0)},
+		}, merged)
+	})
+
+	t.Run("subtracts busy time from free time", func(t *testing.T) {
+		free := subtractRanges(
+			[]timeRange{{bkk(20, 10, 0), bkk(20, 22, 0)}},
+			[]timeRange{{bkk(20, 12, 0), bkk(20, 14, 0)}, {bkk(20, 21, 0), bkk(20, 23, 0)}},
+		)
+		assert.Equal(t, []timeRange{
+			{bkk(20, 10, 0), bkk(20, 12, 0)},
+			{bkk(20, 14, 0), bkk(20, 21, 0)},
+		}, free)
+	})
+
+	t.Run("covers only ranges inside one free block", func(t *testing.T) {
+		free := []timeRange{{bkk(20, 10, 0), bkk(20, 12, 0)}, {bkk(20, 14, 0), bkk(20, 21, 0)}}
+		assert.True(t, rangesCover(free, timeRange{bkk(20, 14, 0), bkk(20, 16, 0)}))
+		assert.False(t, rangesCover(free, timeRange{bkk(20, 11, 0), bkk(20, 15, 0)}))
+	})
+}
+
+func TestBookableSlots(t *testing.T) {
+	free := []timeRange{{bkk(20, 10, 15), bkk(20, 13, 0)}}
+	from, to := bkk(20, 0, 0), bkk(21, 0, 0)
+
+	t.Run("slots start on the interval grid and fit the duration", func(t *testing.T) {
+		slots := bookableSlots(free, 2*time.Hour, 30*time.Minute, from, to, bkk(19, 0, 0), bangkokLocation)
+		clocks := make([]string, len(slots))
+		for i, s := range slots {
+			clocks[i] = s.StartClock
+		}
+		assert.Equal(t, []string{"10:30", "11:00"}, clocks)
+		assert.Equal(t, "2026-10-20", slots[0].BookingDate)
+		assert.Equal(t, bkk(20, 12, 30), slots[0].EndTime)
+	})
+
+	t.Run("no slots before now", func(t *testing.T) {
+		slots := bookableSlots(free, time.Hour, 30*time.Minute, from, to, bkk(20, 11, 10), bangkokLocation)
+		if assert.Len(t, slots, 2) {
+			assert.Equal(t, "11:30", slots[0].StartClock)
+			assert.Equal(t, "12:00", slots[1].StartClock)
+		}
+	})
+
+	t.Run("buffered bookings shrink the free time", func(t *testing.T) {
+		buffer := 30 * time.Minute
+		booking := timeRange{bkk(20, 12, 0).Add(-buffer), bkk(20, 13, 0).Add(buffer)}
+		free := subtractRanges([]timeRange{{bkk(20, 10, 0), bkk(20, 16, 0)}}, []timeRange{booking})
+		slots := bookableSlots(free, time.Hour, time.Hour, from, to, bkk(19, 0, 0), bangkokLocation)
+		clocks := make([]string, len(slots))
+		for i, s := range slots {
+			clocks[i] = s.StartClock
+		}
+		assert.Equal(t, []string{"10:00", "14:00", "15:00"}, clocks)
+	})
+}
+
+func TestParseAvailabilityRange(t *testing.T) {
+	now := bkk(18, 23, 30)
+
+	from, to, err := parseAvailabilityRange("", "", now, bangkokLocation)
+	assert.NoError(t, err)
+	assert.Equal(t, bkk(18, 0, 0), from)
+	assert.Equal(t, bkk(25, 0, 0), to)
+
+	_, _, err = parseAvailabilityRange("2026-10-20", "2026-10-19", now, bangkokLocation)
+	assert.ErrorIs(t, err, errAvailabilityRange)
+
+	_, _, err = parseAvailabilityRange("2026-10-01", "2026-11-15", now, bangkokLocation)
+	assert.ErrorIs(t, err, errAvailabilityRange)
+}
+
+func TestNearestSlots(t *testing.T) {
+	free := []timeRange{{bkk(20, 9, 0), bkk(20, 18, 0)}}
+	slots := bookableSlots(free, time.Hour, time.Hour, bkk(20, 0, 0), bkk(21, 0, 0), bkk(19, 0, 0), bangkokLocation)
+
+	nearest := nearestSlots(slots, bkk(20, 14, 0), 3)
+	clocks := make([]string, len(nearest))
+	for i, s := range nearest {
+		clocks[i] = s.StartClock
+	}
+	assert.Equal(t, []string{"13:00", "14:00", "15:00"}, clocks)
+
+	assert.Equal(t, []AvailabilitySlot{}, nearestSlots(nil, bkk(20, 14, 0), 3))
+}
+
+func TestSlotConflictError(t *testing.T) {
+	var err error = &slotConflictError{SuggestedSlots: []AvailabilitySlot{}}
+	assert.ErrorIs(t, err, errSlotUnavailable)
+
+	assert.True(t, isExclusionViolation(fmt.Errorf("insert booking: %w", &pgconn.PgError{Code: "23P01"})))
+	assert.False(t, isExclusionViolation(&pgconn.PgError{Code: "23505"}))
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// ================================
+// Background Jobs (งานที่รันเป็นรอบอัตโนมัติ)
+// ================================
+
+// runPeriodically runs job immediately and then every interval until ctx is done.
+// A panic or error in one run is logged and does not stop later runs.
+func runPeriodically(ctx context.Context, name string, interval time.Duration, job func(context.Context) error) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			runJobOnce(ctx, name, job)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func runJobOnce(ctx context.Context, name string, job func(context.Context) error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("❌ Background job %s panicked: %v", name, r)
+		}
+	}()
+
+	if err := job(ctx); err != nil {
+		log.Printf("⚠️  Background job %s failed: %v", name, err)
+	}
+}
@@ -21,10 +21,10 @@ func getProviderPackagesHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.H
 		}
 
 		rows, err := dbPool.Query(ctx, `
-			SELECT package_id, provider_id, package_name, description, duration, price, is_active, created_at
+			SELECT `+servicePackageColumns+`
 			FROM service_packages
 			WHERE provider_id = $1 AND is_active = true
-			ORDER BY price ASC
+			ORDER BY display_order, price ASC
 		`, providerID)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database query failed"})
@@ -34,47 +34,57 @@ func getProviderPackagesHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.H
 
 		packages := make([]ServicePackage, 0)
 		for rows.Next() {
-			var pkg ServicePackage
-			if err := rows.Scan(&pkg.PackageID, &pkg.ProviderID, &pkg.PackageName, &pkg.Description,
-				&pkg.Duration, &pkg.Price, &pkg.IsActive, &pkg.CreatedAt); err != nil {
+			pkg, err := scanServicePackage(rows)
+			if err != nil {
 				continue
 			}
-			packages = append(packages, pkg)
+			packages = append(packages, *pkg)
 		}
+		rows.Close()
 
-		c.JSON(http.StatusOK, packages)
-	}
-}
-
-// --- POST /packages (สร้างแพ็คเกจ - Provider เท่านั้น) ---
-func createPackageHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		userID, _ := c.Get("userID")
-
-		var input struct {
-			PackageName string  `json:"package_name" binding:"required"`
-			Description *string `json:"description"`
-			Duration    int     `json:"duration" binding:"required"`
-			Price       float64 `json:"price" binding:"required"`
+		// กฎราคา dynamic ของแต่ละแพ็คเกจ (+ ราคาจริงถ้าระบุ ?date=YYYY-MM-DD&start_time=HH:MM)
+		packageIDs := make([]int, len(packages))
+		for i, pkg := range packages {
+			packageIDs[i] = pkg.PackageID
 		}
-		if err := c.ShouldBindJSON(&input); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		rules, err := loadPricingRules(ctx, dbPool, packageIDs)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database query failed"})
 			return
 		}
-
-		var packageID int
-		err := dbPool.QueryRow(ctx, `
-			INSERT INTO service_packages (provider_id, package_name, description, duration, price)
-			VALUES ($1, $2, $3, $4, $5)
-			RETURNING package_id
-		`, userID, input.PackageName, input.Description, input.Duration, input.Price).Scan(&packageID)
-
+		images, err := loadPackageImages(ctx, dbPool, packageIDs)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create package"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database query failed"})
 			return
 		}
+		loc := loadAvailabilitySettings(ctx, dbPool, providerID).location()
+		start, priceAtErr := parseLocalDateTime(c.Query("date"), c.Query("start_time"), loc)
+		now := time.Now()
+		holiday := ""
+		rates := bookingCommissionRule(ctx, dbPool, providerID, now)
+		if priceAtErr == nil {
+			holiday = holidayOn(ctx, dbPool, start)
+		}
+		for i := range packages {
+			pkg := &packages[i]
+			pkg.PricingRules = rules[pkg.PackageID]
+			pkg.Images = images[pkg.PackageID]
+			if priceAtErr != nil {
+				continue
+			}
+			b, err := priceBreakdown(pricingInput{
+				PackageName:     pkg.PackageName,
+				PackagePrice:    pkg.Price,
+				PackageDuration: pkg.Duration,
+				Surcharges:      pricingRuleAdjustments(pkg.PricingRules, pkg.Price, start, now, holiday),
+				Commission:      &rates,
+			})
+			if err == nil {
+				pkg.PriceAt = &b
+			}
+		}
 
-		c.JSON(http.StatusCreated, gin.H{"package_id": packageID, "message": "Package created successfully"})
+		c.JSON(http.StatusOK, packages)
 	}
 }
 
@@ -84,10 +94,11 @@ func createBookingHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.Handler
 		clientID, _ := c.Get("userID")
 
 		var input struct {
-			ProviderID   int     `json:"provider_id" binding:"required"`
-			PackageID    int     `json:"package_id" binding:"required"`
-			BookingDate  string  `json:"booking_date" binding:"required"` // YYYY-MM-DD
-			StartTime    string  `json:"start_time" binding:"required"`   // HH:MM
+			QuoteID      string  `json:"quote_id"` // optional: ราคาจาก POST /bookings/quote (รวมคูปอง)
+			ProviderID   int     `json:"provider_id"`
+			PackageID    int     `json:"package_id"`
+			BookingDate  string  `json:"booking_date"` // YYYY-MM-DD
+			StartTime    string  `json:"start_time"`   // HH:MM
 			Location     *string `json:"location"`
 			SpecialNotes *string `json:"special_notes"`
 		}
@@ -95,10 +106,44 @@ func createBookingHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.Handler
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
+		if input.QuoteID == "" && (input.ProviderID == 0 || input.PackageID == 0 || input.BookingDate == "" || input.StartTime == "") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "quote_id or provider_id, package_id, booking_date and start_time are required"})
+			return
+		}
+
+		// ค่าปรับการยกเลิกค้างชำระ → จองใหม่ไม่ได้
+		if !requireNoOutstandingCancellationFees(c, dbPool, ctx, clientID) {
+			return
+		}
+
+		tx, err := dbPool.Begin(ctx)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create booking"})
+			return
+		}
+		defer tx.Rollback(ctx)
+
+		// ราคามาจาก pricing engine เสมอ: quote ที่ขอไว้ หรือคำนวณใหม่ตอนนี้
+		var quote *PriceQuote
+		if input.QuoteID != "" {
+			quote, err = consumePriceQuote(ctx, tx, input.QuoteID, c.GetInt("userID"), QuoteKindBooking, time.Now())
+		} else {
+			quote, err = buildBookingQuote(ctx, dbPool, c.GetInt("userID"), bookingQuoteRequest{
+				ProviderID:  input.ProviderID,
+				PackageID:   input.PackageID,
+				BookingDate: input.BookingDate,
+				StartTime:   input.StartTime,
+			}, time.Now())
+		}
+		if err != nil {
+			respondPricingError(c, err, "Failed to create booking")
+			return
+		}
+		input.ProviderID = quote.ProviderID
 
 		// ตรวจสอบ service_type ของ provider
 		var serviceType *string
-		err := dbPool.QueryRow(ctx, `
+		err = tx.QueryRow(ctx, `
 			SELECT p.service_type 
 			FROM user_profiles p
 			JOIN users u ON u.user_id = p.user_id
@@ -120,37 +165,14 @@ func createBookingHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.Handler
 			}
 		}
 
-		// ดึงข้อมูลแพ็คเกจ
-		var duration int
-		var price float64
-		err = dbPool.QueryRow(ctx, `
-			SELECT duration, price FROM service_packages WHERE package_id = $1 AND is_active = true
-		`, input.PackageID).Scan(&duration, &price)
-		if err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Package not found"})
-			return
-		}
-
-		// สร้าง timestamp
-		startTime, err := time.Parse("2006-01-02 15:04", input.BookingDate+" "+input.StartTime)
+		// สร้างการจอง
+		bookingID, err := createBookingFromQuote(ctx, tx, quote, input.Location, input.SpecialNotes, "pending", nil)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date/time format"})
+			respondPricingError(c, err, "Failed to create booking")
 			return
 		}
-		endTime := startTime.Add(time.Duration(duration) * time.Minute)
-
-		// สร้างการจอง
-		var bookingID int
-		err = dbPool.QueryRow(ctx, `
-			INSERT INTO bookings (client_id, provider_id, package_id, booking_date, start_time, end_time, 
-								  total_price, status, location, special_notes)
-			VALUES ($1, $2, $3, $4, $5, $6, $7, 'pending', $8, $9)
-			RETURNING booking_id
-		`, clientID, input.ProviderID, input.PackageID, input.BookingDate, startTime, endTime,
-			price, input.Location, input.SpecialNotes).Scan(&bookingID)
-
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create booking", "details": err.Error()})
+		if err := tx.Commit(ctx); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create booking"})
 			return
 		}
 
@@ -160,7 +182,12 @@ func createBookingHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.Handler
 			"client_id":  clientID,
 		})
 
-		c.JSON(http.StatusCreated, gin.H{"booking_id": bookingID, "message": "Booking created successfully"})
+		c.JSON(http.StatusCreated, gin.H{
+			"booking_id":  bookingID,
+			"total_price": quote.Total,
+			"price":       quote.PriceBreakdown,
+			"message":     "Booking created successfully",
+		})
 	}
 }
 
@@ -171,12 +198,14 @@ func getMyBookingsHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.Handler
 
 		rows, err := dbPool.Query(ctx, `
 			SELECT b.booking_id, b.client_id, u_client.username, b.provider_id, u_provider.username,
-				   p.profile_image_url, sp.package_name, sp.duration, b.booking_date, b.start_time, b.end_time,
-				   b.total_price, b.status, b.location, b.special_notes, b.created_at, b.updated_at
+				   p.profile_image_url, COALESCE(bs.package_name, sp.package_name), COALESCE(bs.package_duration, sp.duration), b.booking_date, b.start_time, b.end_time,
+				   b.total_price, COALESCE(b.original_price, b.total_price), COALESCE(b.discount_amount, 0),
+				   b.status, b.location, b.special_notes, b.created_at, b.updated_at, b.timezone
 			FROM bookings b
 			JOIN users u_client ON b.client_id = u_client.user_id
 			JOIN users u_provider ON b.provider_id = u_provider.user_id
 			JOIN service_packages sp ON b.package_id = sp.package_id
+			LEFT JOIN booking_snapshots bs ON bs.booking_id = b.booking_id
 			LEFT JOIN user_profiles p ON b.provider_id = p.user_id
 			WHERE b.client_id = $1
 			ORDER BY b.created_at DESC
@@ -193,10 +222,11 @@ func getMyBookingsHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.Handler
 			if err := rows.Scan(&booking.BookingID, &booking.ClientID, &booking.ClientUsername,
 				&booking.ProviderID, &booking.ProviderUsername, &booking.ProviderProfilePic,
 				&booking.PackageName, &booking.Duration, &booking.BookingDate, &booking.StartTime,
-				&booking.EndTime, &booking.TotalPrice, &booking.Status, &booking.Location,
-				&booking.SpecialNotes, &booking.CreatedAt, &booking.UpdatedAt); err != nil {
+				&booking.EndTime, &booking.TotalPrice, &booking.OriginalPrice, &booking.DiscountAmount, &booking.Status, &booking.Location,
+				&booking.SpecialNotes, &booking.CreatedAt, &booking.UpdatedAt, &booking.Timezone); err != nil {
 				continue
 			}
+			booking.localize()
 			bookings = append(bookings, booking)
 		}
 
@@ -211,12 +241,14 @@ func getProviderBookingsHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.H
 
 		rows, err := dbPool.Query(ctx, `
 			SELECT b.booking_id, b.client_id, u_client.username, b.provider_id, u_provider.username,
-				   p.profile_image_url, sp.package_name, sp.duration, b.booking_date, b.start_time, b.end_time,
-				   b.total_price, b.status, b.location, b.special_notes, b.created_at, b.updated_at
+				   p.profile_image_url, COALESCE(bs.package_name, sp.package_name), COALESCE(bs.package_duration, sp.duration), b.booking_date, b.start_time, b.end_time,
+				   b.total_price, COALESCE(b.original_price, b.total_price), COALESCE(b.discount_amount, 0),
+				   b.status, b.location, b.special_notes, b.created_at, b.updated_at, b.timezone
 			FROM bookings b
 			JOIN users u_client ON b.client_id = u_client.user_id
 			JOIN users u_provider ON b.provider_id = u_provider.user_id
 			JOIN service_packages sp ON b.package_id = sp.package_id
+			LEFT JOIN booking_snapshots bs ON bs.booking_id = b.booking_id
 			LEFT JOIN user_profiles p ON b.provider_id = p.user_id
 			WHERE b.provider_id = $1
 			ORDER BY b.created_at DESC
@@ -233,10 +265,11 @@ func getProviderBookingsHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.H
 			if err := rows.Scan(&booking.BookingID, &booking.ClientID, &booking.ClientUsername,
 				&booking.ProviderID, &booking.ProviderUsername, &booking.ProviderProfilePic,
 				&booking.PackageName, &booking.Duration, &booking.BookingDate, &booking.StartTime,
-				&booking.EndTime, &booking.TotalPrice, &booking.Status, &booking.Location,
-				&booking.SpecialNotes, &booking.CreatedAt, &booking.UpdatedAt); err != nil {
+				&booking.EndTime, &booking.TotalPrice, &booking.OriginalPrice, &booking.DiscountAmount, &booking.Status, &booking.Location,
+				&booking.SpecialNotes, &booking.CreatedAt, &booking.UpdatedAt, &booking.Timezone); err != nil {
 				continue
 			}
+			booking.localize()
 			bookings = append(bookings, booking)
 		}
 
@@ -300,6 +333,13 @@ func updateBookingStatusHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.H
 			return
 		}
 
+		if input.Status == "cancelled" || input.Status == "rejected" {
+			// คืนสิทธิ์คูปอง / งบแคมเปญ
+			if err := releaseBookingDiscountsNow(ctx, dbPool, bookingID); err != nil {
+				log.Printf("Warning: Failed to release discounts for booking %d: %v", bookingID, err)
+			}
+		}
+
 		// Send notifications based on status change
 		switch input.Status {
 		case "confirmed":
@@ -395,7 +435,8 @@ func getBookingWorkDetailsHandler(dbPool *pgxpool.Pool, ctx context.Context) gin
 			SELECT 
 				b.booking_id, b.provider_id, b.client_id, 
 				u.username as client_username, u.phone as client_phone,
-				sp.package_name, sp.duration, b.total_price, b.status,
+				COALESCE(bs.package_name, sp.package_name) AS package_name, COALESCE(bs.package_duration, sp.duration) AS duration,
+				b.total_price, b.status,
 				b.location, p.address as location_address, 
 				p.latitude, p.longitude,
 				b.special_notes, b.start_time, b.end_time,
@@ -404,6 +445,7 @@ func getBookingWorkDetailsHandler(dbPool *pgxpool.Pool, ctx context.Context) gin
 			FROM bookings b
 			JOIN users u ON b.client_id = u.user_id
 			JOIN service_packages sp ON b.package_id = sp.package_id
+			LEFT JOIN booking_snapshots bs ON bs.booking_id = b.booking_id
 			LEFT JOIN user_profiles p ON b.client_id = p.user_id
 			LEFT JOIN booking_check_ins ci ON b.booking_id = ci.booking_id AND ci.status = 'active'
 			LEFT JOIN transactions t ON b.booking_id = t.booking_id AND t.status = 'completed'
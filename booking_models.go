@@ -12,6 +12,30 @@ type ServicePackage struct {
 	Price       float64   `json:"price"`        // ราคา
 	IsActive    bool      `json:"is_active"`    // เปิด/ปิดใช้งาน
 	CreatedAt   time.Time `json:"created_at"`
+
+	CategoryID     *int       `json:"category_id"`      // หมวดหมู่จาก provider_categories
+	LocationType   string     `json:"location_type"`    // incall, outcall, both
+	MaxAdvanceDays *int       `json:"max_advance_days"` // จองล่วงหน้าได้ไม่เกินกี่วัน (nil = ไม่จำกัด)
+	DisplayOrder   int        `json:"display_order"`
+	Version        int        `json:"version"`
+	RootPackageID  int        `json:"root_package_id"` // แพ็คเกจเวอร์ชันแรก (ทุกเวอร์ชันใช้ค่าเดียวกัน)
+	ReplacedBy     *int       `json:"replaced_by,omitempty"`
+	ArchivedAt     *time.Time `json:"archived_at,omitempty"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+
+	Images []PackageImage `json:"images,omitempty"`
+
+	PricingRules []PackagePricingRule `json:"pricing_rules,omitempty"` // กฎราคา dynamic
+	PriceAt      *PriceBreakdown      `json:"price_at,omitempty"`      // ราคาจริงสำหรับ ?date=&start_time=
+}
+
+// PackageImage (รูปของแพ็คเกจ)
+type PackageImage struct {
+	ImageID      int       `json:"image_id"`
+	PackageID    int       `json:"package_id"`
+	ImageURL     string    `json:"image_url"`
+	DisplayOrder int       `json:"display_order"`
+	CreatedAt    time.Time `json:"created_at"`
 }
 
 // Booking (การจองของ Client)
@@ -45,9 +69,12 @@ type BookingWithDetails struct {
 	PackageName        string    `json:"package_name"`
 	Duration           int       `json:"duration"`
 	BookingDate        time.Time `json:"booking_date"`
-	StartTime          time.Time `json:"start_time"`
+	StartTime          time.Time `json:"start_time"` // RFC3339 ตาม timezone ของ booking
 	EndTime            time.Time `json:"end_time"`
-	TotalPrice         float64   `json:"total_price"`
+	Timezone           string    `json:"timezone"`       // IANA เช่น Asia/Bangkok
+	TotalPrice         float64   `json:"total_price"`    // ยอดที่ต้องจ่าย (หลังส่วนลด)
+	OriginalPrice      float64   `json:"original_price"` // ราคาก่อนลด
+	DiscountAmount     float64   `json:"discount_amount"`
 	Status             string    `json:"status"`
 	Location           *string   `json:"location"`
 	SpecialNotes       *string   `json:"special_notes"`
@@ -55,6 +82,12 @@ type BookingWithDetails struct {
 	UpdatedAt          time.Time `json:"updated_at"`
 }
 
+// localize converts the booking's times to its own time zone for the response
+func (b *BookingWithDetails) localize() {
+	loc := timezoneOrDefault(b.Timezone)
+	b.StartTime, b.EndTime = b.StartTime.In(loc), b.EndTime.In(loc)
+}
+
 // Review (รีวิวหลังใช้บริการ)
 type Review struct {
 	ReviewID   int       `json:"review_id"`
@@ -136,4 +169,6 @@ type ProviderScheduleWithDetails struct {
 	Notes            *string   `json:"notes"`
 	CreatedAt        time.Time `json:"created_at"`
 	UpdatedAt        time.Time `json:"updated_at"`
+	Source           string    `json:"source"`                      // schedule, recurring (แตกจากกฎประจำสัปดาห์)
+	RecurringRuleID  *int      `json:"recurring_rule_id,omitempty"` // ถ้า source = recurring
 }
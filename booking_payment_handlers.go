@@ -3,8 +3,10 @@ package main
 import (
 	"context"
 	"fmt"
+	"math"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -22,12 +24,12 @@ func createBookingWithPaymentHandler(dbPool *pgxpool.Pool, ctx context.Context)
 		}
 
 		var req struct {
-			ProviderID  int    `json:"provider_id" binding:"required"`
-			PackageID   int    `json:"package_id" binding:"required"`
-			BookingDate string `json:"booking_date" binding:"required"` // ISO 8601
-			Notes       string `json:"notes"`
-			SuccessURL  string `json:"success_url"` // Optional
-			CancelURL   string `json:"cancel_url"`  // Optional
+			QuoteID    string  `json:"quote_id" binding:"required"` // จาก POST /bookings/quote
+			Location   *string `json:"location"`
+			Notes      *string `json:"notes"`
+			SuccessURL string  `json:"success_url"` // Optional
+			CancelURL  string  `json:"cancel_url"`  // Optional
+			walletPaymentOption
 		}
 
 		if err := c.ShouldBindJSON(&req); err != nil {
@@ -35,41 +37,80 @@ func createBookingWithPaymentHandler(dbPool *pgxpool.Pool, ctx context.Context)
 			return
 		}
 
-		// 1. ตรวจสอบว่า Package มีอยู่จริง
-		var packagePrice float64
-		var packageName string
-		var providerID int
-		err := dbPool.QueryRow(ctx, `
-			SELECT provider_id, package_name, price 
-			FROM service_packages 
-			WHERE package_id = $1 AND is_active = true
-		`, req.PackageID).Scan(&providerID, &packageName, &packagePrice)
+		// ค่าปรับการยกเลิกค้างชำระ → จองใหม่ไม่ได้
+		if !requireNoOutstandingCancellationFees(c, dbPool, ctx, userID) {
+			return
+		}
 
+		// 1. ใช้ quote (ราคาคำนวณฝั่ง server) แล้วสร้าง Booking (สถานะ pending) ใน tx เดียวกัน
+		tx, err := dbPool.Begin(ctx)
 		if err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Package not found or inactive"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create booking"})
 			return
 		}
+		defer tx.Rollback(ctx)
 
-		// ตรวจสอบว่า provider_id ตรงกับที่ระบุไหม
-		if providerID != req.ProviderID {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Package does not belong to specified provider"})
+		quote, err := consumePriceQuote(ctx, tx, req.QuoteID, c.GetInt("userID"), QuoteKindBooking, time.Now())
+		if err != nil {
+			respondPricingError(c, err, "Failed to create booking")
+			return
+		}
+		bookingID, err := createBookingFromQuote(ctx, tx, quote, req.Location, req.Notes, "pending", nil)
+		if err != nil {
+			respondPricingError(c, err, "Failed to create booking")
 			return
 		}
 
-		// 2. สร้าง Booking ใน DB (สถานะ pending)
-		var bookingID int
-		err = dbPool.QueryRow(ctx, `
-			INSERT INTO bookings (client_id, provider_id, package_id, booking_date, notes, status, total_price)
-			VALUES ($1, $2, $3, $4, $5, 'pending', $6)
-			RETURNING booking_id
-		`, userID, req.ProviderID, req.PackageID, req.BookingDate, req.Notes, packagePrice).Scan(&bookingID)
-
+		// ใช้ยอด wallet ได้บางส่วนหรือทั้งหมด (ส่วนที่เหลือต้องไม่ต่ำกว่าขั้นต่ำของ Stripe)
+		packagePrice := quote.Total
+		fromWallet, remaining, err := planWalletPayment(packagePrice, getWalletSpendableBalance(ctx, dbPool, userID),
+			req.walletPaymentOption, stripeMinimumChargeTHB)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create booking", "details": err.Error()})
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
+		if err := tx.Commit(ctx); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create booking"})
+			return
+		}
+
+		var packageName string
+		dbPool.QueryRow(ctx, `SELECT package_name FROM service_packages WHERE package_id = $1`, quote.PackageID).Scan(&packageName)
+
+		// 3. ส่วนที่จ่ายจาก wallet: จ่ายครบเลย หรือกันเงินไว้ระหว่างรอจ่ายผ่าน Stripe
+		walletDescription := fmt.Sprintf("Wallet payment for booking #%d", bookingID)
+		if fromWallet > 0 && remaining == 0 {
+			if _, err := payFromWalletNow(ctx, dbPool, userID, fromWallet, &bookingID, walletDescription); err != nil {
+				discardQuotedBooking(ctx, dbPool, bookingID, quote.QuoteID)
+				c.JSON(http.StatusPaymentRequired, gin.H{"error": "Failed to pay from wallet", "details": err.Error()})
+				return
+			}
+			if err := recordBookingPayment(dbPool, ctx, strconv.Itoa(bookingID), strconv.Itoa(quote.ProviderID), fromWallet, fromWallet, nil); err != nil {
+				fmt.Printf("❌ Wallet payment for booking %d not recorded: %v\n", bookingID, err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record booking payment"})
+				return
+			}
+			c.JSON(http.StatusOK, gin.H{
+				"message":      "Booking paid from wallet.",
+				"booking_id":   bookingID,
+				"quote_id":     quote.QuoteID,
+				"total_amount": packagePrice,
+				"wallet_paid":  fromWallet,
+			})
+			return
+		}
+
+		var walletHoldID int
+		if fromWallet > 0 {
+			walletHoldID, err = holdWalletForCheckout(ctx, dbPool, userID, fromWallet, &bookingID, walletDescription)
+			if err != nil {
+				discardQuotedBooking(ctx, dbPool, bookingID, quote.QuoteID)
+				c.JSON(http.StatusPaymentRequired, gin.H{"error": "Failed to reserve wallet balance", "details": err.Error()})
+				return
+			}
+		}
 
-		// 3. สร้าง Stripe Checkout Session (payment mode)
+		// 4. สร้าง Stripe Checkout Session (payment mode)
 		successURL := req.SuccessURL
 		cancelURL := req.CancelURL
 		if successURL == "" {
@@ -79,8 +120,8 @@ func createBookingWithPaymentHandler(dbPool *pgxpool.Pool, ctx context.Context)
 			cancelURL = "http://localhost:5174/booking/cancel"
 		}
 
-		// แปลงราคาเป็น cents (Stripe ใช้หน่วยเล็กที่สุด)
-		priceInCents := int64(packagePrice * 100)
+		// แปลงราคาเป็น cents (Stripe ใช้หน่วยเล็กที่สุด) - เฉพาะส่วนที่ไม่ได้จ่ายจาก wallet
+		priceInCents := int64(math.Round(remaining * 100))
 
 		params := &stripe.CheckoutSessionParams{
 			Mode: stripe.String(string(stripe.CheckoutSessionModePayment)), // One-time payment
@@ -90,33 +131,47 @@ func createBookingWithPaymentHandler(dbPool *pgxpool.Pool, ctx context.Context)
 						Currency: stripe.String("thb"), // Thai Baht
 						ProductData: &stripe.CheckoutSessionLineItemPriceDataProductDataParams{
 							Name:        stripe.String(packageName),
-							Description: stripe.String(fmt.Sprintf("Booking with Provider #%d", req.ProviderID)),
+							Description: stripe.String(fmt.Sprintf("Booking with Provider #%d", quote.ProviderID)),
 						},
 						UnitAmount: stripe.Int64(priceInCents),
 					},
 					Quantity: stripe.Int64(1),
 				},
 			},
+			// บันทึกบัตรไว้สำหรับเก็บค่าปรับการยกเลิก (off-session)
+			CustomerCreation: stripe.String(string(stripe.CheckoutSessionCustomerCreationAlways)),
+			PaymentIntentData: &stripe.CheckoutSessionPaymentIntentDataParams{
+				SetupFutureUsage: stripe.String(string(stripe.PaymentIntentSetupFutureUsageOffSession)),
+			},
 			SuccessURL:        stripe.String(successURL),
 			CancelURL:         stripe.String(cancelURL),
 			ClientReferenceID: stripe.String(fmt.Sprintf("%d", userID)),
 			Metadata: map[string]string{
 				"payment_type": "booking",
 				"booking_id":   fmt.Sprintf("%d", bookingID),
-				"provider_id":  fmt.Sprintf("%d", req.ProviderID),
-				"package_id":   fmt.Sprintf("%d", req.PackageID),
+				"provider_id":  fmt.Sprintf("%d", quote.ProviderID),
+				"package_id":   fmt.Sprintf("%d", quote.PackageID),
+				"quote_id":     quote.QuoteID,
 			},
 		}
+		if walletHoldID != 0 {
+			// เงินใน wallet ถูกกันไว้ → ให้ checkout หมดอายุเร็ว (ขั้นต่ำของ Stripe คือ 30 นาที)
+			params.Metadata["wallet_hold_id"] = strconv.Itoa(walletHoldID)
+			params.ExpiresAt = stripe.Int64(time.Now().Add(30 * time.Minute).Unix())
+		}
 
 		stripeSession, err := session.New(params)
 		if err != nil {
-			// หากสร้าง Stripe session ไม่สำเร็จ ลบ booking ที่สร้างไว้
-			dbPool.Exec(ctx, "DELETE FROM bookings WHERE booking_id = $1", bookingID)
+			// หากสร้าง Stripe session ไม่สำเร็จ คืนเงิน wallet ที่กันไว้ แล้วยกเลิก booking (คืนคูปอง + quote)
+			if walletHoldID != 0 {
+				releaseWalletHoldByRef(ctx, dbPool, strconv.Itoa(walletHoldID))
+			}
+			discardQuotedBooking(ctx, dbPool, bookingID, quote.QuoteID)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create payment session", "details": err.Error()})
 			return
 		}
 
-		// 4. อัปเดต booking ด้วย payment_intent_id (optional - ถ้าจะใช้)
+		// 5. อัปเดต booking ด้วย payment_intent_id (optional - ถ้าจะใช้)
 		if stripeSession.PaymentIntent != nil {
 			dbPool.Exec(ctx, `
 				UPDATE bookings 
@@ -130,7 +185,10 @@ func createBookingWithPaymentHandler(dbPool *pgxpool.Pool, ctx context.Context)
 			"checkout_url": stripeSession.URL,
 			"session_id":   stripeSession.ID,
 			"booking_id":   bookingID,
+			"quote_id":     quote.QuoteID,
 			"total_amount": packagePrice,
+			"wallet_paid":  fromWallet,
+			"card_amount":  remaining,
 		})
 	}
 }
@@ -146,21 +204,48 @@ func handleBookingPayment(dbPool *pgxpool.Pool, ctx context.Context, checkoutSes
 		return fmt.Errorf("missing booking_id or provider_id in metadata")
 	}
 
-	// 2. คำนวณค่าธรรมเนียม 12.75%
-	totalAmount := float64(checkoutSession.AmountTotal) / 100 // Convert from cents to THB
-	stripeFee := totalAmount * 0.0275                         // 2.75%
-	platformCommission := totalAmount * 0.1000                // 10%
-	// totalFee := stripeFee + platformCommission             // 12.75% (not used directly)
-	providerEarnings := totalAmount * 0.8725 // 87.25%
+	// ส่วนที่จ่ายจาก wallet (ถ้ามี) นับรวมเป็นยอด booking ด้วย
+	walletAmount, err := captureWalletHoldByRef(ctx, dbPool, checkoutSession.Metadata["wallet_hold_id"])
+	if err != nil {
+		return fmt.Errorf("failed to capture wallet hold: %v", err)
+	}
+
+	totalAmount := float64(checkoutSession.AmountTotal)/100 + walletAmount // Convert from cents to THB
+	var paymentIntentID *string
+	if checkoutSession.PaymentIntent != nil {
+		paymentIntentID = &checkoutSession.PaymentIntent.ID
+	}
+	return recordBookingPayment(dbPool, ctx, bookingID, providerIDStr, totalAmount, walletAmount, paymentIntentID)
+}
+
+// bookingPaymentFees splits a booking payment: platform commission on the whole amount, gateway
+// fee only on the part charged externally (the wallet part never goes through the gateway)
+func bookingPaymentFees(totalAmount, walletAmount, platformRate, gatewayRate float64) (gatewayFee, platformCommission, providerEarnings, feeRate float64) {
+	gatewayFee = roundSatang((totalAmount - walletAmount) * gatewayRate)
+	platformCommission = roundSatang(totalAmount * platformRate)
+	providerEarnings = roundSatang(totalAmount - gatewayFee - platformCommission)
+	if totalAmount > 0 {
+		feeRate = math.Round((gatewayFee+platformCommission)/totalAmount*10000) / 10000
+	}
+	return
+}
+
+// recordBookingPayment marks a booking paid and credits the provider (Stripe webhook or wallet).
+// walletAmount is the part of totalAmount paid from the client's wallet.
+func recordBookingPayment(dbPool *pgxpool.Pool, ctx context.Context, bookingID, providerIDStr string, totalAmount, walletAmount float64, paymentIntentID *string) error {
+	// 2. คำนวณค่าธรรมเนียมตามอัตรา ณ เวลาที่จอง (ปกติ 10% + 2.75% เฉพาะส่วนที่จ่ายผ่านบัตร)
+	bookingIDInt, _ := strconv.Atoi(bookingID)
+	platformRate, gatewayRate := bookingCommissionRates(ctx, dbPool, bookingIDInt)
+	stripeFee, platformCommission, providerEarnings, feeRate := bookingPaymentFees(totalAmount, walletAmount, platformRate, gatewayRate)
 
 	// 3. อัปเดตสถานะ booking เป็น "paid"
 	_, err := dbPool.Exec(ctx, `
 		UPDATE bookings 
 		SET status = 'paid', 
-		    payment_intent_id = $1,
+		    payment_intent_id = COALESCE($1, payment_intent_id),
 		    updated_at = NOW()
 		WHERE booking_id = $2
-	`, checkoutSession.PaymentIntent.ID, bookingID)
+	`, paymentIntentID, bookingID)
 
 	if err != nil {
 		return fmt.Errorf("failed to update booking status: %v", err)
@@ -173,9 +258,9 @@ func handleBookingPayment(dbPool *pgxpool.Pool, ctx context.Context, checkoutSes
 			user_id, type, amount, status, booking_id, 
 			stripe_fee, platform_commission, total_fee_percentage, net_amount
 		)
-		VALUES ($1, 'booking_payment', $2, 'completed', $3, $4, $5, 0.1275, $6)
+		VALUES ($1, 'booking_payment', $2, 'completed', $3, $4, $5, $7, $6)
 		RETURNING transaction_id
-	`, providerIDStr, totalAmount, bookingID, stripeFee, platformCommission, providerEarnings).Scan(&transactionID)
+	`, providerIDStr, totalAmount, bookingID, stripeFee, platformCommission, providerEarnings, feeRate).Scan(&transactionID)
 
 	if err != nil {
 		return fmt.Errorf("failed to create transaction: %v", err)
@@ -202,8 +287,8 @@ func handleBookingPayment(dbPool *pgxpool.Pool, ctx context.Context, checkoutSes
 			booking_id, transaction_id, booking_amount, 
 			commission_rate, commission_amount, provider_amount, provider_id
 		)
-		VALUES ($1, $2, $3, 0.1000, $4, $5, $6)
-	`, bookingID, transactionID, totalAmount, platformCommission, providerEarnings, providerIDStr)
+		VALUES ($1, $2, $3, $7, $4, $5, $6)
+	`, bookingID, transactionID, totalAmount, platformCommission, providerEarnings, providerIDStr, platformRate)
 
 	if err != nil {
 		return fmt.Errorf("failed to record commission transaction: %v", err)
@@ -235,6 +320,9 @@ func handleBookingPayment(dbPool *pgxpool.Pool, ctx context.Context, checkoutSes
 		})
 	}
 
+	// 9. ออกใบเสร็จ/ใบกำกับภาษีให้ลูกค้า
+	issueBookingReceiptQuietly(ctx, dbPool, bookingIDInt)
+
 	fmt.Printf("✅ Booking payment processed: BookingID=%s, Amount=฿%.2f, Provider Earnings=฿%.2f\n",
 		bookingID, totalAmount, providerEarnings)
 
@@ -250,36 +338,37 @@ func getExtensionPackagesHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.
 			return
 		}
 
-		// Get booking to calculate extension prices
-		var basePrice float64
-		var duration int
-		err = dbPool.QueryRow(ctx, `
-			SELECT b.total_price, sp.duration
-			FROM bookings b
-			JOIN service_packages sp ON b.package_id = sp.package_id
-			WHERE b.booking_id = $1
-		`, bookingID).Scan(&basePrice, &duration)
-
-		if err != nil {
+		// ราคาต่อเวลาคิดจากราคาต่อนาทีของแพ็คเกจ ณ เวลาที่จอง (ไม่ใช่ total_price ที่อาจมีส่วนลด/ต่อเวลาแล้ว)
+		snap, err := loadBookingSnapshot(ctx, dbPool, bookingID)
+		if err != nil || snap.PackageDuration <= 0 {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Booking not found"})
 			return
 		}
 
-		// Calculate per-minute rate
-		perMinuteRate := basePrice / float64(duration)
-
-		// Generate extension packages
-		packages := []gin.H{
-			{"minutes": 30, "price": perMinuteRate * 30 * 0.9, "label": "30 minutes"}, // 10% discount
-			{"minutes": 60, "price": perMinuteRate * 60 * 0.85, "label": "1 hour"},    // 15% discount
-			{"minutes": 120, "price": perMinuteRate * 120 * 0.8, "label": "2 hours"},  // 20% discount
+		rates := commissionRates{Platform: snap.PlatformCommissionRate, Gateway: snap.GatewayFeeRate}
+		in := pricingInput{PackageName: snap.PackageName, PackageDuration: snap.PackageDuration, PackagePrice: snap.PackagePrice, Commission: &rates}
+		packages := make([]gin.H, 0, len(extensionTiers))
+		for _, tier := range extensionTiers {
+			in.ExtensionMinutes = tier.Minutes
+			b, err := priceBreakdown(in)
+			if err != nil {
+				continue
+			}
+			packages = append(packages, gin.H{
+				"minutes":       tier.Minutes,
+				"price":         b.Total,
+				"label":         tier.Label,
+				"discount_rate": tier.Discount,
+				"items":         b.Items,
+			})
 		}
 
 		c.JSON(http.StatusOK, gin.H{
 			"booking_id":      bookingID,
-			"base_price":      basePrice,
-			"per_minute_rate": perMinuteRate,
+			"base_price":      in.PackagePrice,
+			"per_minute_rate": roundSatang(in.PackagePrice / float64(in.PackageDuration)),
 			"packages":        packages,
+			"message":         "Request a quote with POST /bookings/:id/extension-quote before paying",
 		})
 	}
 }
@@ -294,11 +383,10 @@ func extendBookingHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.Handler
 		}
 
 		var req struct {
-			BookingID         int     `json:"booking_id" binding:"required"`
-			AdditionalMinutes int     `json:"additional_minutes" binding:"required"`
-			Price             float64 `json:"price" binding:"required"`
-			SuccessURL        string  `json:"success_url"`
-			CancelURL         string  `json:"cancel_url"`
+			QuoteID    string `json:"quote_id" binding:"required"` // จาก POST /bookings/:id/extension-quote
+			SuccessURL string `json:"success_url"`
+			CancelURL  string `json:"cancel_url"`
+			walletPaymentOption
 		}
 
 		if err := c.ShouldBindJSON(&req); err != nil {
@@ -306,43 +394,91 @@ func extendBookingHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.Handler
 			return
 		}
 
-		// Verify booking exists and is active
-		var providerID, clientID int
-		var status string
-		err := dbPool.QueryRow(ctx, `
-			SELECT provider_id, client_id, status
-			FROM bookings
-			WHERE booking_id = $1
-		`, req.BookingID).Scan(&providerID, &clientID, &status)
-
+		// ราคาและจำนวนนาทีมาจาก quote เท่านั้น (quote ผูกกับผู้จ่าย ใช้ได้ครั้งเดียว)
+		tx, err := dbPool.Begin(ctx)
 		if err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Booking not found"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to extend booking"})
 			return
 		}
+		defer tx.Rollback(ctx)
 
-		// Only client or provider can extend
-		if userID != providerID && userID != clientID {
-			c.JSON(http.StatusForbidden, gin.H{"error": "Unauthorized"})
+		quote, err := consumePriceQuote(ctx, tx, req.QuoteID, c.GetInt("userID"), QuoteKindExtension, time.Now())
+		if err != nil {
+			respondPricingError(c, err, "Failed to extend booking")
 			return
 		}
+		bookingID, providerID := *quote.BookingID, quote.ProviderID
+		additionalMinutes, price := quote.ExtensionMinutes, quote.Total
 
-		// Verify booking is in progress
+		// Verify booking is still in progress
+		var status string
+		err = tx.QueryRow(ctx, `SELECT status FROM bookings WHERE booking_id = $1`, bookingID).Scan(&status)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Booking not found"})
+			return
+		}
 		if status != "confirmed" && status != "paid" && status != "in_progress" {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Booking must be active to extend"})
 			return
 		}
 
+		// Pay fully or partly from the wallet of whoever extends
+		fromWallet, remaining, err := planWalletPayment(price, getWalletSpendableBalance(ctx, dbPool, userID),
+			req.walletPaymentOption, stripeMinimumChargeTHB)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := tx.Commit(ctx); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to extend booking"})
+			return
+		}
+
+		walletDescription := fmt.Sprintf("Wallet payment for extension +%d min of booking #%d", additionalMinutes, bookingID)
+		if fromWallet > 0 && remaining == 0 {
+			if _, err := payFromWalletNow(ctx, dbPool, userID, fromWallet, &bookingID, walletDescription); err != nil {
+				releasePriceQuote(ctx, dbPool, quote.QuoteID)
+				c.JSON(http.StatusPaymentRequired, gin.H{"error": "Failed to pay from wallet", "details": err.Error()})
+				return
+			}
+			err := applyBookingExtension(dbPool, ctx, strconv.Itoa(bookingID), strconv.Itoa(providerID),
+				additionalMinutes, fromWallet, fromWallet, "wallet")
+			if err != nil {
+				fmt.Printf("❌ Wallet payment for extension of booking %d not recorded: %v\n", bookingID, err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to extend booking"})
+				return
+			}
+			c.JSON(http.StatusOK, gin.H{
+				"message":            "Booking extended, paid from wallet",
+				"booking_id":         bookingID,
+				"additional_minutes": additionalMinutes,
+				"amount":             price,
+				"wallet_paid":        fromWallet,
+			})
+			return
+		}
+
+		var walletHoldID int
+		if fromWallet > 0 {
+			walletHoldID, err = holdWalletForCheckout(ctx, dbPool, userID, fromWallet, &bookingID, walletDescription)
+			if err != nil {
+				releasePriceQuote(ctx, dbPool, quote.QuoteID)
+				c.JSON(http.StatusPaymentRequired, gin.H{"error": "Failed to reserve wallet balance", "details": err.Error()})
+				return
+			}
+		}
+
 		// Create Stripe checkout session for extension
 		successURL := req.SuccessURL
 		cancelURL := req.CancelURL
 		if successURL == "" {
-			successURL = fmt.Sprintf("http://localhost:5174/booking/extend-success?booking_id=%d", req.BookingID)
+			successURL = fmt.Sprintf("http://localhost:5174/booking/extend-success?booking_id=%d", bookingID)
 		}
 		if cancelURL == "" {
 			cancelURL = "http://localhost:5174/booking/extend-cancel"
 		}
 
-		priceInCents := int64(req.Price * 100)
+		priceInCents := int64(math.Round(remaining * 100))
 
 		params := &stripe.CheckoutSessionParams{
 			Mode: stripe.String(string(stripe.CheckoutSessionModePayment)),
@@ -351,8 +487,8 @@ func extendBookingHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.Handler
 					PriceData: &stripe.CheckoutSessionLineItemPriceDataParams{
 						Currency: stripe.String("thb"),
 						ProductData: &stripe.CheckoutSessionLineItemPriceDataProductDataParams{
-							Name:        stripe.String(fmt.Sprintf("Session Extension +%d minutes", req.AdditionalMinutes)),
-							Description: stripe.String(fmt.Sprintf("Extend booking #%d by %d minutes", req.BookingID, req.AdditionalMinutes)),
+							Name:        stripe.String(fmt.Sprintf("Session Extension +%d minutes", additionalMinutes)),
+							Description: stripe.String(fmt.Sprintf("Extend booking #%d by %d minutes", bookingID, additionalMinutes)),
 						},
 						UnitAmount: stripe.Int64(priceInCents),
 					},
@@ -364,14 +500,21 @@ func extendBookingHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.Handler
 			ClientReferenceID: stripe.String(fmt.Sprintf("%d", userID)),
 			Metadata: map[string]string{
 				"payment_type":       "booking_extension",
-				"booking_id":         fmt.Sprintf("%d", req.BookingID),
+				"booking_id":         fmt.Sprintf("%d", bookingID),
 				"provider_id":        fmt.Sprintf("%d", providerID),
-				"additional_minutes": fmt.Sprintf("%d", req.AdditionalMinutes),
+				"additional_minutes": fmt.Sprintf("%d", additionalMinutes),
+				"quote_id":           quote.QuoteID,
 			},
 		}
+		if walletHoldID != 0 {
+			params.Metadata["wallet_hold_id"] = strconv.Itoa(walletHoldID)
+			params.ExpiresAt = stripe.Int64(time.Now().Add(30 * time.Minute).Unix())
+		}
 
 		stripeSession, err := session.New(params)
 		if err != nil {
+			releaseWalletHoldByRef(ctx, dbPool, params.Metadata["wallet_hold_id"])
+			releasePriceQuote(ctx, dbPool, quote.QuoteID)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create payment session"})
 			return
 		}
@@ -379,9 +522,12 @@ func extendBookingHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.Handler
 		c.JSON(http.StatusOK, gin.H{
 			"checkout_url":       stripeSession.URL,
 			"session_id":         stripeSession.ID,
-			"booking_id":         req.BookingID,
-			"additional_minutes": req.AdditionalMinutes,
-			"amount":             req.Price,
+			"booking_id":         bookingID,
+			"quote_id":           quote.QuoteID,
+			"additional_minutes": additionalMinutes,
+			"amount":             price,
+			"wallet_paid":        fromWallet,
+			"card_amount":        remaining,
 		})
 	}
 }
@@ -396,11 +542,23 @@ func handleBookingExtension(dbPool *pgxpool.Pool, ctx context.Context, checkoutS
 		return fmt.Errorf("missing metadata for booking extension")
 	}
 
+	walletAmount, err := captureWalletHoldByRef(ctx, dbPool, checkoutSession.Metadata["wallet_hold_id"])
+	if err != nil {
+		return fmt.Errorf("failed to capture wallet hold: %v", err)
+	}
+
 	additionalMinutes, _ := strconv.Atoi(additionalMinutesStr)
-	totalAmount := float64(checkoutSession.AmountTotal) / 100
-	stripeFee := totalAmount * 0.0275
-	platformCommission := totalAmount * 0.1000
-	providerEarnings := totalAmount * 0.8725
+	totalAmount := float64(checkoutSession.AmountTotal)/100 + walletAmount
+	return applyBookingExtension(dbPool, ctx, bookingID, providerIDStr, additionalMinutes, totalAmount, walletAmount,
+		walletPaymentMethod(walletAmount, float64(checkoutSession.AmountTotal)/100, "card"))
+}
+
+// applyBookingExtension adds the paid minutes to the booking and credits the provider
+// (walletAmount = part of totalAmount paid from the client's wallet)
+func applyBookingExtension(dbPool *pgxpool.Pool, ctx context.Context, bookingID, providerIDStr string, additionalMinutes int, totalAmount, walletAmount float64, paymentMethod string) error {
+	bookingIDInt, _ := strconv.Atoi(bookingID)
+	platformRate, gatewayRate := bookingCommissionRates(ctx, dbPool, bookingIDInt)
+	stripeFee, platformCommission, providerEarnings, feeRate := bookingPaymentFees(totalAmount, walletAmount, platformRate, gatewayRate)
 
 	// Update booking end_time
 	_, err := dbPool.Exec(ctx, `
@@ -430,9 +588,9 @@ func handleBookingExtension(dbPool *pgxpool.Pool, ctx context.Context, checkoutS
 			user_id, type, amount, status, booking_id,
 			stripe_fee, platform_commission, total_fee_percentage, net_amount
 		)
-		VALUES ($1, 'booking_extension', $2, 'completed', $3, $4, $5, 0.1275, $6)
+		VALUES ($1, 'booking_extension', $2, 'completed', $3, $4, $5, $7, $6)
 		RETURNING transaction_id
-	`, providerIDStr, totalAmount, bookingID, stripeFee, platformCommission, providerEarnings).Scan(&transactionID)
+	`, providerIDStr, totalAmount, bookingID, stripeFee, platformCommission, providerEarnings, feeRate).Scan(&transactionID)
 
 	if err != nil {
 		return fmt.Errorf("failed to create transaction: %v", err)
@@ -469,6 +627,22 @@ func handleBookingExtension(dbPool *pgxpool.Pool, ctx context.Context, checkoutS
 		})
 	}
 
+	// Receipt for the extension (separate document from the original booking)
+	var clientID int
+	if err := dbPool.QueryRow(ctx, `SELECT client_id FROM bookings WHERE booking_id = $1`, bookingIDInt).Scan(&clientID); err == nil {
+		issueReceiptQuietly(ctx, dbPool, receiptSource{
+			SourceType:    ReceiptSourceExtension,
+			SourceRef:     strconv.Itoa(transactionID),
+			BookingID:     &bookingIDInt,
+			BuyerID:       clientID,
+			ProviderID:    &providerIDInt,
+			Description:   fmt.Sprintf("Extension +%d min for booking #%d", additionalMinutes, bookingIDInt),
+			AmountPaid:    totalAmount,
+			PaymentMethod: paymentMethod,
+			PaidAt:        time.Now(),
+		})
+	}
+
 	fmt.Printf("✅ Booking extended: BookingID=%s, +%d minutes, Amount=฿%.2f\n",
 		bookingID, additionalMinutes, totalAmount)
 
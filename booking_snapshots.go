@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ================================
+// Booking Snapshots (แพ็คเกจ/ราคา/นโยบาย ณ เวลาที่จอง — แก้ไขไม่ได้)
+// ================================
+
+// CancellationTier is one row of a provider's cancellation policy
+type CancellationTier struct {
+	HoursBeforeBooking float64 `json:"hours_before_booking"`
+	FeePercentage      float64 `json:"fee_percentage"`
+}
+
+// BookingSnapshot freezes what a booking was sold as. Later price, fee and receipt
+// computations read this instead of the live package and provider settings.
+type BookingSnapshot struct {
+	BookingID              int                `json:"booking_id"`
+	PackageName            string             `json:"package_name"`
+	PackageDuration        int                `json:"package_duration"`
+	PackagePrice           float64            `json:"package_price"`
+	PriceBreakdown         *PriceBreakdown    `json:"price_breakdown"`
+	CancellationTiers      []CancellationTier `json:"cancellation_tiers"`
+	RequireDeposit         bool               `json:"require_deposit"`
+	DepositPercentage      float64            `json:"deposit_percentage"`
+	PlatformCommissionRate float64            `json:"platform_commission_rate"`
+	GatewayFeeRate         float64            `json:"gateway_fee_rate"`
+	CreatedAt              time.Time          `json:"created_at"`
+}
+
+// rowQuerier is implemented by both *pgxpool.Pool and pgx.Tx
+type rowQuerier interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+const bookingSnapshotColumns = `
+	booking_id, package_name, package_duration, package_price, price_breakdown, cancellation_tiers,
+	require_deposit, deposit_percentage, platform_commission_rate, gateway_fee_rate, created_at`
+
+func scanBookingSnapshot(row pgx.Row) (*BookingSnapshot, error) {
+	var s BookingSnapshot
+	err := row.Scan(&s.BookingID, &s.PackageName, &s.PackageDuration, &s.PackagePrice, &s.PriceBreakdown,
+		&s.CancellationTiers, &s.RequireDeposit, &s.DepositPercentage, &s.PlatformCommissionRate, &s.GatewayFeeRate,
+		&s.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func loadBookingSnapshot(ctx context.Context, db rowQuerier, bookingID int) (*BookingSnapshot, error) {
+	return scanBookingSnapshot(db.QueryRow(ctx, `
+		SELECT `+bookingSnapshotColumns+` FROM booking_snapshots WHERE booking_id = $1
+	`, bookingID))
+}
+
+// snapshotBooking records the package, the quoted breakdown and the provider's current
+// cancellation policy, deposit setting and commission rule for a new booking
+func snapshotBooking(ctx context.Context, tx pgx.Tx, bookingID int, q *PriceQuote) error {
+	var breakdown *PriceBreakdown
+	if q != nil {
+		breakdown = &q.PriceBreakdown
+	}
+
+	var providerID int
+	var bookedAt time.Time
+	if err := tx.QueryRow(ctx, `SELECT provider_id, created_at FROM bookings WHERE booking_id = $1`, bookingID).Scan(&providerID, &bookedAt); err != nil {
+		return err
+	}
+	rates := bookingCommissionRule(ctx, tx, providerID, bookedAt)
+
+	_, err := tx.Exec(ctx, `
+		INSERT INTO booking_snapshots (
+			booking_id, package_name, package_duration, package_price, price_breakdown, cancellation_tiers,
+			require_deposit, deposit_percentage, platform_commission_rate, gateway_fee_rate
+		)
+		SELECT b.booking_id, sp.package_name, sp.duration, sp.price, $2,
+		       COALESCE((
+		           SELECT json_agg(json_build_object('hours_before_booking', cp.hours_before_booking,
+		                                             'fee_percentage', cp.fee_percentage)
+		                           ORDER BY cp.hours_before_booking)
+		           FROM cancellation_policies cp
+		           WHERE cp.provider_id = b.provider_id AND cp.is_active = true
+		       ), '[]'),
+		       COALESCE(pds.require_deposit, false), COALESCE(pds.deposit_percentage, 0.30), $3, $4
+		FROM bookings b
+		JOIN service_packages sp ON sp.package_id = b.package_id
+		LEFT JOIN provider_deposit_settings pds ON pds.provider_id = b.provider_id
+		WHERE b.booking_id = $1
+	`, bookingID, breakdown, rates.Platform, rates.Gateway)
+	return err
+}
+
+// hoursUntilStart is how long before the booking's start a cancellation happens.
+// Both are absolute instants, so the provider's time zone does not affect the fee window.
+func hoursUntilStart(start, now time.Time) float64 {
+	return start.Sub(now).Hours()
+}
+
+// cancellationFeeRate picks the tier with the smallest window that still covers
+// hoursUntilStart (same rule as the live policy lookup). No tier = no fee.
+func cancellationFeeRate(tiers []CancellationTier, hoursUntilStart float64) float64 {
+	rate, best := 0.0, -1.0
+	for _, t := range tiers {
+		if t.HoursBeforeBooking >= hoursUntilStart && (best < 0 || t.HoursBeforeBooking < best) {
+			rate, best = t.FeePercentage, t.HoursBeforeBooking
+		}
+	}
+	return rate
+}
+
+// bookingCommissionRule returns the booking commission rule (commission_rules, applies_to
+// 'booking') in effect at `at` for the provider's tier; a rule for the tier wins over a general
+// one (tier_id NULL). Without any rule the built-in defaults apply.
+func bookingCommissionRule(ctx context.Context, db rowQuerier, providerID int, at time.Time) commissionRates {
+	var r commissionRates
+	err := db.QueryRow(ctx, `
+		SELECT cr.platform_rate, cr.payment_gateway_rate
+		FROM commission_rules cr
+		LEFT JOIN users u ON u.user_id = $1
+		WHERE COALESCE(cr.applies_to, 'booking') = 'booking' AND cr.is_active = true
+		  AND cr.effective_from <= $2 AND (cr.effective_until IS NULL OR cr.effective_until > $2)
+		  AND (cr.tier_id IS NULL OR cr.tier_id = u.provider_level_id)
+		ORDER BY (cr.tier_id IS NOT NULL) DESC, cr.effective_from DESC
+		LIMIT 1
+	`, providerID, at).Scan(&r.Platform, &r.Gateway)
+	if err != nil {
+		return defaultCommissionRates
+	}
+	return r
+}
+
+// bookingCommissionRates returns the commission rates frozen on the booking (for bookings
+// without a snapshot: the rule that was in effect for the provider when it was booked)
+func bookingCommissionRates(ctx context.Context, db rowQuerier, bookingID int) (platformRate, gatewayRate float64) {
+	err := db.QueryRow(ctx, `
+		SELECT platform_commission_rate, gateway_fee_rate FROM booking_snapshots WHERE booking_id = $1
+	`, bookingID).Scan(&platformRate, &gatewayRate)
+	if err == nil {
+		return platformRate, gatewayRate
+	}
+
+	var providerID int
+	var bookedAt time.Time
+	if err := db.QueryRow(ctx, `SELECT provider_id, created_at FROM bookings WHERE booking_id = $1`, bookingID).Scan(&providerID, &bookedAt); err != nil {
+		return defaultCommissionRates.Platform, defaultCommissionRates.Gateway
+	}
+	r := bookingCommissionRule(ctx, db, providerID, bookedAt)
+	return r.Platform, r.Gateway
+}
+
+// GET /bookings/:id/snapshot - ข้อมูลที่จองไว้ ณ เวลาจอง (ลูกค้า/ผู้ให้บริการ/admin)
+func getBookingSnapshotHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetInt("userID")
+		bookingID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid booking ID"})
+			return
+		}
+
+		var clientID, providerID int
+		err = dbPool.QueryRow(ctx, `SELECT client_id, provider_id FROM bookings WHERE booking_id = $1`, bookingID).Scan(&clientID, &providerID)
+		if err != nil || (userID != clientID && userID != providerID && !isAdminUser(ctx, dbPool, userID)) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Booking not found"})
+			return
+		}
+
+		s, err := loadBookingSnapshot(ctx, dbPool, bookingID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Snapshot not found"})
+			return
+		}
+		c.JSON(http.StatusOK, s)
+	}
+}
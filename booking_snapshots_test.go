@@ -0,0 +1,30 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCancellationFeeRate(t *testing.T) {
+	tiers := []CancellationTier{
+		{HoursBeforeBooking: 48, FeePercentage: 0.25},
+		{HoursBeforeBooking: 24, FeePercentage: 0.5},
+	}
+
+	t.Run("inside the tightest window", func(t *testing.T) {
+		assert.Equal(t, 0.5, cancellationFeeRate(tiers, 10))
+	})
+
+	t.Run("between windows", func(t *testing.T) {
+		assert.Equal(t, 0.25, cancellationFeeRate(tiers, 30))
+	})
+
+	t.Run("outside every window", func(t *testing.T) {
+		assert.Equal(t, 0.0, cancellationFeeRate(tiers, 100))
+	})
+
+	t.Run("no policy", func(t *testing.T) {
+		assert.Equal(t, 0.0, cancellationFeeRate(nil, 1))
+	})
+}
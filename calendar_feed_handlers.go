@@ -0,0 +1,261 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ================================
+// Calendar Feed (ICS) - ให้ Provider/Client subscribe ใน Google/Apple Calendar
+// ================================
+
+const (
+	calendarFeedPastDays   = 30
+	calendarFeedFutureDays = 365
+)
+
+func newCalendarFeedToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// calendarFeedURL builds the subscribe URL (PUBLIC_API_URL หรือ host ของ request)
+func calendarFeedURL(c *gin.Context, token string) string {
+	base := strings.TrimRight(os.Getenv("PUBLIC_API_URL"), "/")
+	if base == "" {
+		scheme := "https"
+		if c.Request.TLS == nil && c.GetHeader("X-Forwarded-Proto") != "https" {
+			scheme = "http"
+		}
+		base = scheme + "://" + c.Request.Host
+	}
+	return base + "/calendar/ics/" + token + ".ics"
+}
+
+func calendarFeedResponse(c *gin.Context, token string, createdAt time.Time) gin.H {
+	url := calendarFeedURL(c, token)
+	return gin.H{
+		"feed_url":   url,
+		"webcal_url": "webcal://" + strings.SplitN(url, "://", 2)[1],
+		"created_at": createdAt,
+	}
+}
+
+// icsBookingStatus maps a booking status to a VEVENT STATUS (ยกเลิก → CANCELLED ให้ปฏิทินลบออก)
+func icsBookingStatus(status string) string {
+	switch status {
+	case "cancelled", "rejected", "expired", "failed": // bookingFreeStatusesSQL
+		return "CANCELLED"
+	}
+	return "CONFIRMED"
+}
+
+// scheduleLocationLabel keeps only the area of a schedule entry (ไม่ใส่ที่อยู่เต็ม)
+func scheduleLocationLabel(locationType, district, province *string) string {
+	parts := make([]string, 0, 3)
+	for _, p := range []*string{locationType, district, province} {
+		if p != nil && *p != "" {
+			parts = append(parts, *p)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// loadCalendarFeedEvents returns the user's bookings (as client or provider) and their own
+// schedule entries. Address, client name and notes are never exported.
+func loadCalendarFeedEvents(ctx context.Context, dbPool *pgxpool.Pool, userID int, now time.Time) ([]icsEvent, error) {
+	from := now.AddDate(0, 0, -calendarFeedPastDays)
+	to := now.AddDate(0, 0, calendarFeedFutureDays)
+
+	rows, err := dbPool.Query(ctx, `
+		SELECT b.booking_id, b.provider_id, b.start_time, b.end_time, b.status, b.ical_sequence,
+		       COALESCE(b.updated_at, b.created_at, NOW()),
+		       COALESCE(bs.package_name, sp.package_name, 'Booking'), pu.username
+		FROM bookings b
+		LEFT JOIN booking_snapshots bs ON bs.booking_id = b.booking_id
+		LEFT JOIN service_packages sp ON sp.package_id = b.package_id
+		JOIN users pu ON pu.user_id = b.provider_id
+		WHERE (b.client_id = $1 OR b.provider_id = $1)
+		  AND b.status NOT IN ('pending', 'pending_payment') -- ยังไม่ยืนยัน
+		  AND b.end_time > $2 AND b.start_time < $3
+		ORDER BY b.start_time
+	`, userID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := make([]icsEvent, 0)
+	for rows.Next() {
+		var bookingID, providerID, sequence int
+		var status, packageName, providerName string
+		var e icsEvent
+		if err := rows.Scan(&bookingID, &providerID, &e.Start, &e.End, &status, &sequence,
+			&e.LastModified, &packageName, &providerName); err != nil {
+			return nil, err
+		}
+		e.UID = fmt.Sprintf("booking-%d@skillmatch", bookingID)
+		e.Sequence = sequence
+		e.Status = icsBookingStatus(status)
+		if providerID == userID {
+			e.Summary = fmt.Sprintf("SkillMatch: %s (booking #%d)", packageName, bookingID)
+		} else {
+			e.Summary = fmt.Sprintf("SkillMatch: %s with %s", packageName, providerName)
+		}
+		e.Description = fmt.Sprintf("Booking #%d (%s). ดูรายละเอียดในแอป SkillMatch", bookingID, status)
+		events = append(events, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// ตารางงานของ Provider (ช่วงที่ผูก booking แสดงจาก bookings แล้ว)
+	rows, err = dbPool.Query(ctx, `
+		SELECT schedule_id, start_time, end_time, status, ical_sequence,
+		       COALESCE(updated_at, created_at, NOW()), location_type, location_district, location_province
+		FROM provider_schedules
+		WHERE provider_id = $1 AND booking_id IS NULL AND import_id IS NULL -- ไม่ส่ง block ที่ import มากลับออกไป
+		  AND end_time > $2 AND start_time < $3
+		ORDER BY start_time
+	`, userID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var scheduleID int
+		var status string
+		var locationType, district, province *string
+		var e icsEvent
+		if err := rows.Scan(&scheduleID, &e.Start, &e.End, &status, &e.Sequence, &e.LastModified,
+			&locationType, &district, &province); err != nil {
+			return nil, err
+		}
+		e.UID = fmt.Sprintf("schedule-%d@skillmatch", scheduleID)
+		e.Status = "CONFIRMED"
+		e.Location = scheduleLocationLabel(locationType, district, province)
+		switch status {
+		case "available":
+			e.Summary = "SkillMatch: Available"
+			e.Transparent = true
+		case "blocked":
+			e.Summary = "SkillMatch: Blocked"
+		default:
+			e.Summary = "SkillMatch: Booked"
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+// GET /calendar/ics/:token (Public - token คือสิทธิ์เข้าถึง, รับทั้ง xxx และ xxx.ics)
+func calendarFeedHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := strings.TrimSuffix(c.Param("token"), ".ics")
+		if len(token) != 64 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Calendar feed not found"})
+			return
+		}
+
+		var userID int
+		var username string
+		err := dbPool.QueryRow(ctx, `
+			UPDATE calendar_feed_tokens t SET last_accessed_at = NOW()
+			FROM users u
+			WHERE t.token = $1 AND u.user_id = t.user_id
+			RETURNING t.user_id, u.username
+		`, token).Scan(&userID, &username)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Calendar feed not found"})
+			return
+		}
+
+		now := time.Now()
+		events, err := loadCalendarFeedEvents(ctx, dbPool, userID, now)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build calendar feed"})
+			return
+		}
+
+		c.Header("Cache-Control", "private, max-age=300")
+		c.Header("Content-Disposition", `inline; filename="skillmatch.ics"`)
+		c.Data(http.StatusOK, "text/calendar; charset=utf-8", buildICS("SkillMatch - "+username, events, now))
+	}
+}
+
+// GET /calendar/feed - ลิงก์ calendar feed ของตัวเอง (สร้างให้ถ้ายังไม่มี)
+func getCalendarFeedHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetInt("userID")
+
+		token, err := newCalendarFeedToken()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate feed token"})
+			return
+		}
+		var createdAt time.Time
+		// ON CONFLICT DO UPDATE (ค่าเดิม) เพื่อให้ RETURNING คืน token ที่มีอยู่แล้ว
+		err = dbPool.QueryRow(ctx, `
+			INSERT INTO calendar_feed_tokens (user_id, token) VALUES ($1, $2)
+			ON CONFLICT (user_id) DO UPDATE SET token = calendar_feed_tokens.token
+			RETURNING token, created_at
+		`, userID, token).Scan(&token, &createdAt)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load calendar feed"})
+			return
+		}
+
+		c.JSON(http.StatusOK, calendarFeedResponse(c, token, createdAt))
+	}
+}
+
+// POST /calendar/feed/regenerate - ออก token ใหม่ (ลิงก์เดิมใช้ไม่ได้ทันที)
+func regenerateCalendarFeedHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetInt("userID")
+
+		token, err := newCalendarFeedToken()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate feed token"})
+			return
+		}
+		var createdAt time.Time
+		err = dbPool.QueryRow(ctx, `
+			INSERT INTO calendar_feed_tokens (user_id, token) VALUES ($1, $2)
+			ON CONFLICT (user_id) DO UPDATE SET token = EXCLUDED.token, created_at = NOW(), last_accessed_at = NULL
+			RETURNING created_at
+		`, userID, token).Scan(&createdAt)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to regenerate calendar feed"})
+			return
+		}
+
+		resp := calendarFeedResponse(c, token, createdAt)
+		resp["message"] = "Calendar feed regenerated. Old links no longer work."
+		c.JSON(http.StatusOK, resp)
+	}
+}
+
+// DELETE /calendar/feed - ปิด calendar feed
+func deleteCalendarFeedHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, err := dbPool.Exec(ctx, `DELETE FROM calendar_feed_tokens WHERE user_id = $1`, c.GetInt("userID")); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to disable calendar feed"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Calendar feed disabled"})
+	}
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// checkCalendarImportLimit enforces maxCalendarImports per provider
+func checkCalendarImportLimit(ctx context.Context, dbPool *pgxpool.Pool, providerID int) error {
+	var count int
+	if err := dbPool.QueryRow(ctx, `SELECT COUNT(*) FROM provider_calendar_imports WHERE provider_id = $1`, providerID).Scan(&count); err != nil {
+		return err
+	}
+	if count >= maxCalendarImports {
+		return errCalendarImportLimit
+	}
+	return nil
+}
+
+func loadOwnCalendarImport(ctx context.Context, dbPool *pgxpool.Pool, providerID int, importID string) (*CalendarImport, error) {
+	return scanCalendarImport(dbPool.QueryRow(ctx, `
+		SELECT `+calendarImportColumns+` FROM provider_calendar_imports WHERE import_id = $1 AND provider_id = $2
+	`, importID, providerID))
+}
+
+// GET /provider/calendar-imports - ปฏิทินภายนอกที่เชื่อมไว้
+func getMyCalendarImportsHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		rows, err := dbPool.Query(ctx, `
+			SELECT `+calendarImportColumns+` FROM provider_calendar_imports
+			WHERE provider_id = $1 ORDER BY created_at
+		`, c.GetInt("userID"))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch calendar imports"})
+			return
+		}
+		defer rows.Close()
+
+		imports := make([]*CalendarImport, 0)
+		for rows.Next() {
+			if ci, err := scanCalendarImport(rows); err == nil {
+				imports = append(imports, ci)
+			}
+		}
+		c.JSON(http.StatusOK, gin.H{"imports": imports})
+	}
+}
+
+// POST /provider/calendar-imports - เชื่อม ICS URL (Google/Apple/Outlook) แล้ว sync ทันที
+func createCalendarImportHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetInt("userID")
+
+		var req struct {
+			Name string `json:"name" binding:"required,max=100"`
+			URL  string `json:"url" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		sourceURL, err := normalizeCalendarURL(req.URL)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := checkCalendarImportLimit(ctx, dbPool, userID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		ci, err := scanCalendarImport(dbPool.QueryRow(ctx, `
+			INSERT INTO provider_calendar_imports (provider_id, name, source_type, source_url)
+			VALUES ($1, $2, $3, $4)
+			RETURNING `+calendarImportColumns,
+			userID, req.Name, CalendarImportSourceURL, sourceURL))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create calendar import"})
+			return
+		}
+
+		// sync ครั้งแรก: ถ้าไม่สำเร็จยังเก็บไว้ (last_error) และลองใหม่รอบถัดไป
+		if _, err := syncCalendarImportURL(ctx, dbPool, ci); err != nil {
+			if reloaded, rerr := loadOwnCalendarImport(ctx, dbPool, userID, strconv.Itoa(ci.ImportID)); rerr == nil {
+				ci = reloaded
+			}
+			c.JSON(http.StatusCreated, gin.H{"message": "Calendar import created but the first sync failed", "import": ci, "sync_error": err.Error()})
+			return
+		}
+		if reloaded, err := loadOwnCalendarImport(ctx, dbPool, userID, strconv.Itoa(ci.ImportID)); err == nil {
+			ci = reloaded
+		}
+		c.JSON(http.StatusCreated, gin.H{"message": "Calendar import created", "import": ci})
+	}
+}
+
+// POST /provider/calendar-imports/upload - อัปโหลดไฟล์ .ics (form: file, name, import_id = แทนที่ไฟล์เดิม)
+func uploadCalendarImportHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetInt("userID")
+
+		fileHeader, err := c.FormFile("file")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "file is required"})
+			return
+		}
+		if fileHeader.Size > maxCalendarImportBytes {
+			c.JSON(http.StatusBadRequest, gin.H{"error": errCalendarImportTooLarge.Error()})
+			return
+		}
+		file, err := fileHeader.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read file"})
+			return
+		}
+		defer file.Close()
+		data, err := io.ReadAll(io.LimitReader(file, maxCalendarImportBytes))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read file"})
+			return
+		}
+		if _, err := parseICSEvents(data, loadAvailabilitySettings(ctx, dbPool, userID).location()); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		name := strings.TrimSpace(c.PostForm("name"))
+		if name == "" {
+			name = strings.TrimSuffix(fileHeader.Filename, ".ics")
+		}
+		if r := []rune(name); len(r) > 100 {
+			name = string(r[:100])
+		}
+
+		var ci *CalendarImport
+		status := http.StatusCreated
+		if importID := c.PostForm("import_id"); importID != "" {
+			ci, err = loadOwnCalendarImport(ctx, dbPool, userID, importID)
+			if err != nil || ci.SourceType != CalendarImportSourceUpload {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Calendar import not found"})
+				return
+			}
+			status = http.StatusOK
+		} else {
+			if err := checkCalendarImportLimit(ctx, dbPool, userID); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			ci, err = scanCalendarImport(dbPool.QueryRow(ctx, `
+				INSERT INTO provider_calendar_imports (provider_id, name, source_type)
+				VALUES ($1, $2, $3)
+				RETURNING `+calendarImportColumns,
+				userID, name, CalendarImportSourceUpload))
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create calendar import"})
+				return
+			}
+		}
+
+		if _, err := applyCalendarImport(ctx, dbPool, ci, data, time.Now()); err != nil {
+			recordCalendarImportError(ctx, dbPool, ci.ImportID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to import calendar"})
+			return
+		}
+		if reloaded, err := loadOwnCalendarImport(ctx, dbPool, userID, strconv.Itoa(ci.ImportID)); err == nil {
+			ci = reloaded
+		}
+		c.JSON(status, gin.H{"message": "Calendar imported", "import": ci})
+	}
+}
+
+// POST /provider/calendar-imports/:importId/sync - sync URL ทันที
+func syncCalendarImportHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetInt("userID")
+
+		ci, err := loadOwnCalendarImport(ctx, dbPool, userID, c.Param("importId"))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Calendar import not found"})
+			return
+		}
+		if ci.SourceType != CalendarImportSourceURL {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Uploaded calendars are updated by uploading the file again"})
+			return
+		}
+
+		if _, err := syncCalendarImportURL(ctx, dbPool, ci); err != nil {
+			c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to sync calendar", "details": err.Error()})
+			return
+		}
+		if reloaded, err := loadOwnCalendarImport(ctx, dbPool, userID, c.Param("importId")); err == nil {
+			ci = reloaded
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Calendar synced", "import": ci})
+	}
+}
+
+// PATCH /provider/calendar-imports/:importId - เปิด/ปิด sync อัตโนมัติ
+func updateCalendarImportHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Name     *string `json:"name" binding:"omitempty,min=1,max=100"`
+			IsActive *bool   `json:"is_active"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		ci, err := scanCalendarImport(dbPool.QueryRow(ctx, `
+			UPDATE provider_calendar_imports
+			SET name = COALESCE($3, name), is_active = COALESCE($4, is_active), updated_at = NOW()
+			WHERE import_id = $1 AND provider_id = $2
+			RETURNING `+calendarImportColumns,
+			c.Param("importId"), c.GetInt("userID"), req.Name, req.IsActive))
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Calendar import not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update calendar import"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Calendar import updated", "import": ci})
+	}
+}
+
+// DELETE /provider/calendar-imports/:importId - ยกเลิกการเชื่อม (ลบ block ที่ import มาด้วย)
+func deleteCalendarImportHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tag, err := dbPool.Exec(ctx, `
+			DELETE FROM provider_calendar_imports WHERE import_id = $1 AND provider_id = $2
+		`, c.Param("importId"), c.GetInt("userID"))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete calendar import"})
+			return
+		}
+		if tag.RowsAffected() == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Calendar import not found"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Calendar import deleted"})
+	}
+}
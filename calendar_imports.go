@@ -0,0 +1,263 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ================================
+// External Calendar Imports - ดึงเวลาไม่ว่างจาก Google/Apple/Outlook มาเป็น schedule 'blocked'
+// ================================
+
+const (
+	CalendarImportSourceURL    = "url"
+	CalendarImportSourceUpload = "upload"
+
+	maxCalendarImports        = 10
+	maxCalendarImportBytes    = 5 << 20 // 5 MB
+	maxImportedBlocks         = 5000
+	calendarImportHorizonDays = 180
+)
+
+var (
+	errCalendarImportURL      = errors.New("url must be a public http(s) or webcal calendar link")
+	errCalendarImportTooLarge = errors.New("calendar is larger than 5 MB")
+	errCalendarImportLimit    = errors.New("at most 10 calendar imports are allowed")
+	errCalendarImportBlocked  = errors.New("calendar host resolves to a private address")
+)
+
+// CalendarImport is an external calendar whose busy times block the provider's schedule
+type CalendarImport struct {
+	ImportID     int        `json:"import_id"`
+	ProviderID   int        `json:"provider_id"`
+	Name         string     `json:"name"`
+	SourceType   string     `json:"source_type"` // url, upload
+	SourceURL    *string    `json:"source_url,omitempty"`
+	IsActive     bool       `json:"is_active"`
+	LastSyncedAt *time.Time `json:"last_synced_at"`
+	LastError    *string    `json:"last_error"`
+	EventCount   int        `json:"event_count"` // จำนวนช่วงเวลาที่ block อยู่
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+const calendarImportColumns = `
+	import_id, provider_id, name, source_type, source_url, is_active,
+	last_synced_at, last_error, event_count, created_at, updated_at`
+
+func scanCalendarImport(row pgx.Row) (*CalendarImport, error) {
+	var ci CalendarImport
+	err := row.Scan(&ci.ImportID, &ci.ProviderID, &ci.Name, &ci.SourceType, &ci.SourceURL, &ci.IsActive,
+		&ci.LastSyncedAt, &ci.LastError, &ci.EventCount, &ci.CreatedAt, &ci.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &ci, nil
+}
+
+// normalizeCalendarURL accepts http(s) and webcal links (webcal = https)
+func normalizeCalendarURL(raw string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || u.Host == "" {
+		return "", errCalendarImportURL
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "webcal", "webcals":
+		u.Scheme = "https"
+	case "http", "https":
+	default:
+		return "", errCalendarImportURL
+	}
+	return u.String(), nil
+}
+
+// denyPrivateAddress stops the importer from reaching internal services (ตรวจตอน dial กัน DNS rebinding)
+func denyPrivateAddress(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsUnspecified() || ip.IsMulticast() {
+		return errCalendarImportBlocked
+	}
+	return nil
+}
+
+var calendarImportClient = &http.Client{
+	Timeout: 20 * time.Second,
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{Timeout: 10 * time.Second, Control: denyPrivateAddress}).DialContext,
+	},
+}
+
+// fetchCalendarURL downloads an ICS feed (สูงสุด 5 MB)
+func fetchCalendarURL(ctx context.Context, sourceURL string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, sourceURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/calendar")
+	req.Header.Set("User-Agent", "SkillMatch-Calendar-Import/1.0")
+
+	resp, err := calendarImportClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("calendar server returned %s", resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxCalendarImportBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxCalendarImportBytes {
+		return nil, errCalendarImportTooLarge
+	}
+	return data, nil
+}
+
+// applyCalendarImport parses an ICS file and syncs its busy blocks into provider_schedules:
+// new occurrences are inserted, moved ones updated in place (same external_uid) and ones
+// that disappeared from the source deleted. Returns the number of blocks.
+func applyCalendarImport(ctx context.Context, dbPool *pgxpool.Pool, ci *CalendarImport, data []byte, now time.Time) (int, error) {
+	loc := loadAvailabilitySettings(ctx, dbPool, ci.ProviderID).location()
+	events, err := parseICSEvents(data, loc)
+	if err != nil {
+		return 0, err
+	}
+	blocks := expandICSBusyBlocks(events, now.AddDate(0, 0, -1), now.AddDate(0, 0, calendarImportHorizonDays))
+	if len(blocks) > maxImportedBlocks {
+		blocks = blocks[:maxImportedBlocks]
+	}
+
+	tx, err := dbPool.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	notes := "Imported: " + ci.Name
+	uids := make([]string, 0, len(blocks))
+	for _, b := range blocks {
+		_, err := tx.Exec(ctx, `
+			INSERT INTO provider_schedules (
+				provider_id, start_time, end_time, status, notes, is_visible_to_admin, import_id, external_uid
+			) VALUES ($1, $2, $3, 'blocked', $4, true, $5, $6)
+			ON CONFLICT (import_id, external_uid) DO UPDATE
+			SET start_time = EXCLUDED.start_time, end_time = EXCLUDED.end_time, notes = EXCLUDED.notes, updated_at = NOW()
+			WHERE provider_schedules.start_time IS DISTINCT FROM EXCLUDED.start_time
+			   OR provider_schedules.end_time IS DISTINCT FROM EXCLUDED.end_time
+			   OR provider_schedules.notes IS DISTINCT FROM EXCLUDED.notes
+		`, ci.ProviderID, b.Start, b.End, notes, ci.ImportID, b.ExternalUID)
+		if err != nil {
+			return 0, err
+		}
+		uids = append(uids, b.ExternalUID)
+	}
+
+	// event ที่หายไปจากต้นทาง (ถูกลบ/ยกเลิก/เลยช่วง sync) → ลบ block
+	if _, err := tx.Exec(ctx, `
+		DELETE FROM provider_schedules WHERE import_id = $1 AND NOT (external_uid = ANY($2))
+	`, ci.ImportID, uids); err != nil {
+		return 0, err
+	}
+	if _, err := tx.Exec(ctx, `
+		UPDATE provider_calendar_imports
+		SET last_synced_at = NOW(), last_error = NULL, event_count = $2, updated_at = NOW()
+		WHERE import_id = $1
+	`, ci.ImportID, len(blocks)); err != nil {
+		return 0, err
+	}
+	return len(blocks), tx.Commit(ctx)
+}
+
+// recordCalendarImportError keeps the old blocks and stores why the sync failed
+func recordCalendarImportError(ctx context.Context, dbPool *pgxpool.Pool, importID int, syncErr error) {
+	_, err := dbPool.Exec(ctx, `
+		UPDATE provider_calendar_imports SET last_error = $2, updated_at = NOW() WHERE import_id = $1
+	`, importID, syncErr.Error())
+	if err != nil {
+		log.Printf("Warning: failed to record calendar import %d error: %v", importID, err)
+	}
+}
+
+// syncCalendarImportURL fetches and applies one URL source, recording failures on the import
+func syncCalendarImportURL(ctx context.Context, dbPool *pgxpool.Pool, ci *CalendarImport) (int, error) {
+	if ci.SourceURL == nil {
+		return 0, errCalendarImportURL
+	}
+	data, err := fetchCalendarURL(ctx, *ci.SourceURL)
+	if err == nil {
+		var n int
+		if n, err = applyCalendarImport(ctx, dbPool, ci, data, time.Now()); err == nil {
+			return n, nil
+		}
+	}
+	recordCalendarImportError(ctx, dbPool, ci.ImportID, err)
+	return 0, err
+}
+
+// syncDueCalendarImports re-syncs active URL imports not synced within the last hour
+func syncDueCalendarImports(ctx context.Context, dbPool *pgxpool.Pool) (int, error) {
+	rows, err := dbPool.Query(ctx, `
+		SELECT `+calendarImportColumns+`
+		FROM provider_calendar_imports
+		WHERE source_type = 'url' AND is_active = true
+		  AND (last_synced_at IS NULL OR last_synced_at < NOW() - INTERVAL '50 minutes')
+		ORDER BY last_synced_at NULLS FIRST
+		LIMIT 200
+	`)
+	if err != nil {
+		return 0, err
+	}
+	imports := make([]*CalendarImport, 0)
+	for rows.Next() {
+		ci, err := scanCalendarImport(rows)
+		if err != nil {
+			rows.Close()
+			return 0, err
+		}
+		imports = append(imports, ci)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	synced := 0
+	for _, ci := range imports {
+		if _, err := syncCalendarImportURL(ctx, dbPool, ci); err != nil {
+			log.Printf("⚠️  Calendar import %d (provider %d) failed: %v", ci.ImportID, ci.ProviderID, err)
+			continue
+		}
+		synced++
+	}
+	return synced, nil
+}
+
+// startCalendarImportScheduler checks every 10 minutes for URL calendars due for their hourly sync
+func startCalendarImportScheduler(dbPool *pgxpool.Pool, ctx context.Context) {
+	runPeriodically(ctx, "calendar-import", 10*time.Minute, func(ctx context.Context) error {
+		n, err := syncDueCalendarImports(ctx, dbPool)
+		if n > 0 {
+			log.Printf("📅 Synced %d external calendar(s)", n)
+		}
+		return err
+	})
+}
@@ -0,0 +1,456 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type CreateCampaignRequest struct {
+	Name             string   `json:"name" binding:"required"`
+	Description      *string  `json:"description"`
+	Code             *string  `json:"code"`
+	DiscountType     string   `json:"discount_type" binding:"required,oneof=percentage fixed"`
+	DiscountValue    float64  `json:"discount_value" binding:"required,gt=0"`
+	MaxDiscount      *float64 `json:"max_discount"`
+	MinBookingAmount *float64 `json:"min_booking_amount"`
+	FundingSource    string   `json:"funding_source"` // platform (default สำหรับ admin), provider
+	ProviderID       *int     `json:"provider_id"`
+	TotalBudget      *float64 `json:"total_budget"`
+	PerUserLimit     *int     `json:"per_user_limit"`
+	FirstBookingOnly bool     `json:"first_booking_only"`
+	CategoryIDs      []int    `json:"category_ids"`
+	TierIDs          []int    `json:"tier_ids"`
+	Provinces        []string `json:"provinces"`
+	DaysOfWeek       []int    `json:"days_of_week"`
+	StartsAt         string   `json:"starts_at" binding:"required"` // YYYY-MM-DD
+	EndsAt           string   `json:"ends_at" binding:"required"`   // YYYY-MM-DD
+}
+
+func isAdminUser(ctx context.Context, dbPool *pgxpool.Pool, userID int) bool {
+	var isAdmin bool
+	dbPool.QueryRow(ctx, `SELECT COALESCE(is_admin, false) FROM users WHERE user_id = $1`, userID).Scan(&isAdmin)
+	return isAdmin
+}
+
+// parseCampaignDate reads a YYYY-MM-DD campaign date as a Bangkok calendar day: its start, or
+// with endOfDay its last second (ends_at is inclusive)
+func parseCampaignDate(value string, endOfDay bool) (time.Time, error) {
+	t, err := time.ParseInLocation("2006-01-02", value, bangkokLocation)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1).Add(-time.Second)
+	}
+	return t, nil
+}
+
+// POST /campaigns (Admin: แคมเปญ platform หรือ provider, Provider: แคมเปญที่ตัวเองออกเงิน)
+func createCampaignHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetInt("userID")
+
+		var req CreateCampaignRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		startsAt, err1 := parseCampaignDate(req.StartsAt, false)
+		endsAt, err2 := parseCampaignDate(req.EndsAt, true)
+		if err1 != nil || err2 != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "starts_at and ends_at must be YYYY-MM-DD"})
+			return
+		}
+		if !endsAt.After(startsAt) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "ends_at must be after starts_at"})
+			return
+		}
+		if req.DiscountType == "percentage" && req.DiscountValue > 100 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Percentage discount cannot exceed 100"})
+			return
+		}
+		if req.TotalBudget != nil && *req.TotalBudget <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "total_budget must be greater than 0"})
+			return
+		}
+		for _, d := range req.DaysOfWeek {
+			if d < 0 || d > 6 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "days_of_week must be 0 (Sunday) to 6 (Saturday)"})
+				return
+			}
+		}
+
+		if isAdminUser(ctx, dbPool, userID) {
+			if req.FundingSource == "" {
+				req.FundingSource = CampaignFundedByPlatform
+			}
+			if req.FundingSource != CampaignFundedByPlatform && req.FundingSource != CampaignFundedByProvider {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "funding_source must be platform or provider"})
+				return
+			}
+			if req.FundingSource == CampaignFundedByProvider && req.ProviderID == nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "provider_id is required for provider-funded campaigns"})
+				return
+			}
+		} else {
+			// provider สร้างได้เฉพาะแคมเปญที่ตัวเองออกเงิน ใช้กับงานของตัวเองเท่านั้น
+			req.FundingSource = CampaignFundedByProvider
+			req.ProviderID = &userID
+		}
+
+		perUserLimit := 1
+		if req.PerUserLimit != nil {
+			perUserLimit = *req.PerUserLimit
+		}
+		if req.Code != nil {
+			code := strings.ToUpper(strings.TrimSpace(*req.Code))
+			req.Code = &code
+			if code == "" {
+				req.Code = nil
+			}
+		}
+
+		campaign, err := scanCampaign(dbPool.QueryRow(ctx, `
+			INSERT INTO promotion_campaigns (
+				name, description, code, discount_type, discount_value, max_discount, min_booking_amount,
+				funding_source, provider_id, total_budget, per_user_limit, first_booking_only,
+				category_ids, tier_ids, provinces, days_of_week, starts_at, ends_at, created_by
+			) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19)
+			RETURNING `+campaignColumns,
+			req.Name, req.Description, req.Code, req.DiscountType, req.DiscountValue, req.MaxDiscount, req.MinBookingAmount,
+			req.FundingSource, req.ProviderID, req.TotalBudget, perUserLimit, req.FirstBookingOnly,
+			req.CategoryIDs, req.TierIDs, req.Provinces, req.DaysOfWeek, startsAt, endsAt, userID))
+		if err != nil {
+			if strings.Contains(err.Error(), "duplicate") {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Campaign code already exists"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create campaign"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"message": "Campaign created", "campaign": campaign})
+	}
+}
+
+func listCampaigns(c *gin.Context, dbPool *pgxpool.Pool, ctx context.Context, where string, args ...interface{}) {
+	rows, err := dbPool.Query(ctx, `SELECT `+campaignColumns+` FROM promotion_campaigns `+where+` ORDER BY created_at DESC`, args...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch campaigns"})
+		return
+	}
+	defer rows.Close()
+
+	campaigns := make([]gin.H, 0)
+	for rows.Next() {
+		campaign, err := scanCampaign(rows)
+		if err != nil {
+			continue
+		}
+		campaigns = append(campaigns, gin.H{"campaign": campaign, "budget_remaining": campaign.budgetRemaining()})
+	}
+	c.JSON(http.StatusOK, gin.H{"campaigns": campaigns, "total": len(campaigns)})
+}
+
+// GET /admin/campaigns
+func adminGetCampaignsHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Query("active") == "true" {
+			listCampaigns(c, dbPool, ctx, `WHERE is_active = true AND ends_at >= NOW()`)
+			return
+		}
+		listCampaigns(c, dbPool, ctx, ``)
+	}
+}
+
+// GET /campaigns/my (แคมเปญที่ provider ออกเงินหรือสร้างเอง)
+func getMyCampaignsHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetInt("userID")
+		listCampaigns(c, dbPool, ctx, `WHERE provider_id = $1 OR created_by = $1`, userID)
+	}
+}
+
+// GET /campaigns/active (Public) - แคมเปญที่เลือกใช้ได้โดยไม่ต้องกรอกโค้ด
+func getActiveCampaignsHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		listCampaigns(c, dbPool, ctx, `
+			WHERE is_active = true AND code IS NULL
+			  AND starts_at <= NOW() AND ends_at >= NOW()
+			  AND (total_budget IS NULL OR budget_used < total_budget)`)
+	}
+}
+
+// loadOwnedCampaign returns the campaign if userID is an admin or the funding provider
+func loadOwnedCampaign(c *gin.Context, dbPool *pgxpool.Pool, ctx context.Context) (*Campaign, bool) {
+	userID := c.GetInt("userID")
+	campaign, err := scanCampaign(dbPool.QueryRow(ctx, `SELECT `+campaignColumns+` FROM promotion_campaigns WHERE campaign_id = $1`, c.Param("id")))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Campaign not found"})
+		return nil, false
+	}
+	owner := campaign.CreatedBy == userID || (campaign.ProviderID != nil && *campaign.ProviderID == userID)
+	if !owner && !isAdminUser(ctx, dbPool, userID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return nil, false
+	}
+	return campaign, true
+}
+
+// PATCH /campaigns/:id (เปิด/ปิด, เพิ่มงบ, ขยายเวลา)
+func updateCampaignHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		campaign, ok := loadOwnedCampaign(c, dbPool, ctx)
+		if !ok {
+			return
+		}
+
+		var req struct {
+			IsActive     *bool    `json:"is_active"`
+			TotalBudget  *float64 `json:"total_budget"`
+			PerUserLimit *int     `json:"per_user_limit"`
+			EndsAt       *string  `json:"ends_at"` // YYYY-MM-DD
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		var endsAt *time.Time
+		if req.EndsAt != nil {
+			t, err := parseCampaignDate(*req.EndsAt, true)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "ends_at must be YYYY-MM-DD"})
+				return
+			}
+			endsAt = &t
+		}
+		if req.TotalBudget != nil && *req.TotalBudget < campaign.BudgetUsed {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "total_budget cannot be lower than the budget already used", "budget_used": campaign.BudgetUsed})
+			return
+		}
+
+		updated, err := scanCampaign(dbPool.QueryRow(ctx, `
+			UPDATE promotion_campaigns
+			SET is_active = COALESCE($2, is_active),
+			    total_budget = COALESCE($3, total_budget),
+			    per_user_limit = COALESCE($4, per_user_limit),
+			    ends_at = COALESCE($5, ends_at),
+			    updated_at = NOW()
+			WHERE campaign_id = $1
+			RETURNING `+campaignColumns,
+			campaign.CampaignID, req.IsActive, req.TotalBudget, req.PerUserLimit, endsAt))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update campaign"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Campaign updated", "campaign": updated})
+	}
+}
+
+// POST /campaigns/redeem - ใช้แคมเปญกับ booking (ระบุ campaign_id หรือ code)
+func redeemCampaignHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetInt("userID")
+
+		var req struct {
+			BookingID  int    `json:"booking_id" binding:"required"`
+			CampaignID int    `json:"campaign_id"`
+			Code       string `json:"code"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		campaignID := req.CampaignID
+		if req.Code != "" {
+			err := dbPool.QueryRow(ctx, `SELECT campaign_id FROM promotion_campaigns WHERE code = UPPER($1)`, strings.TrimSpace(req.Code)).Scan(&campaignID)
+			if err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Campaign not found"})
+				return
+			}
+		} else if campaignID == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "campaign_id or code is required"})
+			return
+		} else {
+			// แคมเปญที่มีโค้ดต้องกรอกโค้ด
+			var hasCode bool
+			err := dbPool.QueryRow(ctx, `SELECT code IS NOT NULL FROM promotion_campaigns WHERE campaign_id = $1`, campaignID).Scan(&hasCode)
+			if err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Campaign not found"})
+				return
+			}
+			if hasCode {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "This campaign requires a code"})
+				return
+			}
+		}
+
+		tx, err := dbPool.Begin(ctx)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to apply campaign"})
+			return
+		}
+		defer tx.Rollback(ctx)
+
+		r, err := redeemCampaign(ctx, tx, campaignID, req.BookingID, userID)
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Campaign or booking not found"})
+			return
+		case errors.Is(err, errCampaignBudgetExhausted), errors.Is(err, errCampaignUserLimit),
+			errors.Is(err, errCampaignAlreadyOnBooking), errors.Is(err, errCampaignNotActive), errors.Is(err, errCouponNotStackable):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		case errors.Is(err, errCampaignNotEligible), errors.Is(err, errBookingNotRedeemable):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		case err != nil:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to apply campaign"})
+			return
+		}
+		if err := tx.Commit(ctx); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to apply campaign"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":         "Campaign applied",
+			"redemption":      r,
+			"original_price":  r.OriginalAmount,
+			"discount_amount": r.DiscountAmount,
+			"new_total":       roundSatang(r.OriginalAmount - r.DiscountAmount),
+		})
+	}
+}
+
+// GET /campaigns/:id/report - ผลการดำเนินงานของแคมเปญ
+func getCampaignReportHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		campaign, ok := loadOwnedCampaign(c, dbPool, ctx)
+		if !ok {
+			return
+		}
+
+		var report struct {
+			Redemptions        int      `json:"redemptions"`
+			Released           int      `json:"released"`
+			Completed          int      `json:"completed_bookings"`
+			UniqueUsers        int      `json:"unique_users"`
+			NewClients         int      `json:"new_clients"` // ใช้กับ booking แรก
+			TotalDiscount      float64  `json:"total_discount"`
+			AverageDiscount    float64  `json:"average_discount"`
+			GrossBookings      float64  `json:"gross_booking_value"` // ราคาก่อนลดของ booking ที่จบแล้ว
+			SubsidyPaid        float64  `json:"subsidy_paid"`        // platform จ่ายชดเชย provider แล้ว
+			SubsidyOutstanding float64  `json:"subsidy_outstanding"` // รอ booking จบ
+			BudgetRemaining    *float64 `json:"budget_remaining"`
+			BudgetUsedPct      *float64 `json:"budget_used_percentage"`
+		}
+		err := dbPool.QueryRow(ctx, `
+			SELECT
+				COUNT(*) FILTER (WHERE cr.status <> 'released'),
+				COUNT(*) FILTER (WHERE cr.status = 'released'),
+				COUNT(*) FILTER (WHERE cr.status = 'settled'),
+				COUNT(DISTINCT cr.user_id) FILTER (WHERE cr.status <> 'released'),
+				COUNT(*) FILTER (WHERE cr.status <> 'released' AND NOT EXISTS (
+					SELECT 1 FROM bookings ob
+					WHERE ob.client_id = cr.user_id AND ob.booking_id < cr.booking_id
+					  AND ob.status NOT IN ('cancelled', 'rejected', 'expired')
+				)),
+				COALESCE(SUM(cr.discount_amount) FILTER (WHERE cr.status <> 'released'), 0),
+				COALESCE(AVG(cr.discount_amount) FILTER (WHERE cr.status <> 'released'), 0),
+				COALESCE(SUM(cr.original_amount) FILTER (WHERE cr.status = 'settled'), 0),
+				COALESCE(SUM(cr.discount_amount) FILTER (WHERE cr.status = 'settled' AND cr.funding_source = 'platform'), 0),
+				COALESCE(SUM(cr.discount_amount) FILTER (WHERE cr.status = 'applied' AND cr.funding_source = 'platform'), 0)
+			FROM campaign_redemptions cr
+			WHERE cr.campaign_id = $1
+		`, campaign.CampaignID).Scan(&report.Redemptions, &report.Released, &report.Completed, &report.UniqueUsers,
+			&report.NewClients, &report.TotalDiscount, &report.AverageDiscount, &report.GrossBookings,
+			&report.SubsidyPaid, &report.SubsidyOutstanding)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build campaign report"})
+			return
+		}
+		report.AverageDiscount = roundSatang(report.AverageDiscount)
+		report.BudgetRemaining = campaign.budgetRemaining()
+		if campaign.TotalBudget != nil && *campaign.TotalBudget > 0 {
+			pct := roundSatang(campaign.BudgetUsed / *campaign.TotalBudget * 100)
+			report.BudgetUsedPct = &pct
+		}
+
+		rows, err := dbPool.Query(ctx, `
+			SELECT TO_CHAR(DATE(created_at), 'YYYY-MM-DD'), COUNT(*), COALESCE(SUM(discount_amount), 0)
+			FROM campaign_redemptions
+			WHERE campaign_id = $1 AND status <> 'released'
+			GROUP BY DATE(created_at)
+			ORDER BY DATE(created_at)
+		`, campaign.CampaignID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build campaign report"})
+			return
+		}
+		defer rows.Close()
+
+		daily := make([]gin.H, 0)
+		for rows.Next() {
+			var date string
+			var count int
+			var discount float64
+			if err := rows.Scan(&date, &count, &discount); err == nil {
+				daily = append(daily, gin.H{"date": date, "redemptions": count, "discount": discount})
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"campaign": campaign,
+			"report":   report,
+			"daily":    daily,
+		})
+	}
+}
+
+// GET /campaigns/:id/redemptions
+func getCampaignRedemptionsHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		campaign, ok := loadOwnedCampaign(c, dbPool, ctx)
+		if !ok {
+			return
+		}
+		limit, _ := strconv.Atoi(c.DefaultQuery("limit", "100"))
+		if limit <= 0 || limit > 500 {
+			limit = 100
+		}
+
+		rows, err := dbPool.Query(ctx, `
+			SELECT `+campaignRedemptionColumns+`
+			FROM campaign_redemptions
+			WHERE campaign_id = $1
+			ORDER BY created_at DESC
+			LIMIT $2
+		`, campaign.CampaignID, limit)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch redemptions"})
+			return
+		}
+		defer rows.Close()
+
+		redemptions := make([]*CampaignRedemption, 0)
+		for rows.Next() {
+			if r, err := scanCampaignRedemption(rows); err == nil {
+				redemptions = append(redemptions, r)
+			}
+		}
+		c.JSON(http.StatusOK, gin.H{"redemptions": redemptions, "total": len(redemptions)})
+	}
+}
@@ -0,0 +1,460 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ================================
+// Promotion Campaigns (แคมเปญส่วนลดระดับ platform พร้อมงบประมาณ)
+// ================================
+
+const (
+	CampaignFundedByPlatform = "platform"
+	CampaignFundedByProvider = "provider"
+
+	RedemptionApplied  = "applied"  // ใช้กับ booking แล้ว รอ booking จบ
+	RedemptionSettled  = "settled"  // booking จบแล้ว (platform จ่ายส่วนลดคืน provider แล้ว)
+	RedemptionReleased = "released" // booking ถูกยกเลิก คืนงบให้แคมเปญ
+)
+
+// bookings in these statuses can still take a campaign discount (not paid yet)
+var campaignRedeemableBookingStatuses = map[string]bool{"pending": true, "deposit_paid": true}
+
+var (
+	errCampaignNotActive        = errors.New("campaign is not active")
+	errCampaignBudgetExhausted  = errors.New("campaign budget is used up")
+	errCampaignUserLimit        = errors.New("campaign already used the maximum number of times")
+	errCampaignAlreadyOnBooking = errors.New("booking already has a campaign discount")
+	errCampaignNotEligible      = errors.New("booking is not eligible for this campaign")
+	errBookingNotRedeemable     = errors.New("booking can no longer take a discount")
+)
+
+type Campaign struct {
+	CampaignID       int       `json:"campaign_id"`
+	Name             string    `json:"name"`
+	Description      *string   `json:"description"`
+	Code             *string   `json:"code"` // null = เลือกใช้จากรายการแคมเปญได้เลย
+	DiscountType     string    `json:"discount_type"`
+	DiscountValue    float64   `json:"discount_value"`
+	MaxDiscount      *float64  `json:"max_discount"`
+	MinBookingAmount *float64  `json:"min_booking_amount"`
+	FundingSource    string    `json:"funding_source"` // platform, provider
+	ProviderID       *int      `json:"provider_id"`    // null = ทุก provider
+	TotalBudget      *float64  `json:"total_budget"`   // null = ไม่จำกัด
+	BudgetUsed       float64   `json:"budget_used"`
+	PerUserLimit     int       `json:"per_user_limit"`
+	RedemptionCount  int       `json:"redemption_count"`
+	FirstBookingOnly bool      `json:"first_booking_only"`
+	CategoryIDs      []int     `json:"category_ids"`
+	TierIDs          []int     `json:"tier_ids"`
+	Provinces        []string  `json:"provinces"`
+	DaysOfWeek       []int     `json:"days_of_week"` // 0 = อาทิตย์ ... 6 = เสาร์ (วันที่จอง)
+	StartsAt         time.Time `json:"starts_at"`
+	EndsAt           time.Time `json:"ends_at"`
+	IsActive         bool      `json:"is_active"`
+	CreatedBy        int       `json:"created_by"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+const campaignColumns = `
+	campaign_id, name, description, code, discount_type, discount_value, max_discount, min_booking_amount,
+	funding_source, provider_id, total_budget, budget_used, per_user_limit, redemption_count,
+	first_booking_only, category_ids, tier_ids, provinces, days_of_week, starts_at, ends_at,
+	is_active, created_by, created_at`
+
+func scanCampaign(row pgx.Row) (*Campaign, error) {
+	var c Campaign
+	err := row.Scan(&c.CampaignID, &c.Name, &c.Description, &c.Code, &c.DiscountType, &c.DiscountValue,
+		&c.MaxDiscount, &c.MinBookingAmount, &c.FundingSource, &c.ProviderID, &c.TotalBudget, &c.BudgetUsed,
+		&c.PerUserLimit, &c.RedemptionCount, &c.FirstBookingOnly, &c.CategoryIDs, &c.TierIDs, &c.Provinces,
+		&c.DaysOfWeek, &c.StartsAt, &c.EndsAt, &c.IsActive, &c.CreatedBy, &c.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// budgetRemaining returns nil for campaigns without a budget cap
+func (c *Campaign) budgetRemaining() *float64 {
+	if c.TotalBudget == nil {
+		return nil
+	}
+	remaining := roundSatang(*c.TotalBudget - c.BudgetUsed)
+	if remaining < 0 {
+		remaining = 0
+	}
+	return &remaining
+}
+
+type CampaignRedemption struct {
+	RedemptionID   int        `json:"redemption_id"`
+	CampaignID     int        `json:"campaign_id"`
+	UserID         int        `json:"user_id"`
+	BookingID      int        `json:"booking_id"`
+	ProviderID     int        `json:"provider_id"`
+	OriginalAmount float64    `json:"original_amount"`
+	DiscountAmount float64    `json:"discount_amount"`
+	FundingSource  string     `json:"funding_source"`
+	Status         string     `json:"status"`
+	SettledAt      *time.Time `json:"settled_at"`
+	ReleasedAt     *time.Time `json:"released_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+const campaignRedemptionColumns = `
+	redemption_id, campaign_id, user_id, booking_id, provider_id, original_amount, discount_amount,
+	funding_source, status, settled_at, released_at, created_at`
+
+func scanCampaignRedemption(row pgx.Row) (*CampaignRedemption, error) {
+	var r CampaignRedemption
+	err := row.Scan(&r.RedemptionID, &r.CampaignID, &r.UserID, &r.BookingID, &r.ProviderID, &r.OriginalAmount,
+		&r.DiscountAmount, &r.FundingSource, &r.Status, &r.SettledAt, &r.ReleasedAt, &r.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// campaignBookingFacts is what eligibility is decided on
+type campaignBookingFacts struct {
+	Amount           float64
+	IsFirstBooking   bool
+	ProviderID       int
+	CategoryIDs      []int
+	ProviderTierID   *int
+	ProviderProvince *string
+	BookingDate      time.Time
+	UserRedemptions  int
+}
+
+// checkCampaignEligibility returns nil when the booking qualifies for the campaign at now
+func checkCampaignEligibility(c *Campaign, f campaignBookingFacts, now time.Time) error {
+	if !c.IsActive || now.Before(c.StartsAt) || now.After(c.EndsAt) {
+		return errCampaignNotActive
+	}
+	if remaining := c.budgetRemaining(); remaining != nil && *remaining <= 0 {
+		return errCampaignBudgetExhausted
+	}
+	if c.PerUserLimit > 0 && f.UserRedemptions >= c.PerUserLimit {
+		return errCampaignUserLimit
+	}
+	if c.ProviderID != nil && *c.ProviderID != f.ProviderID {
+		return fmt.Errorf("%w: other provider", errCampaignNotEligible)
+	}
+	if c.MinBookingAmount != nil && f.Amount < *c.MinBookingAmount {
+		return fmt.Errorf("%w: minimum booking amount is ฿%s", errCampaignNotEligible, formatBaht(*c.MinBookingAmount))
+	}
+	if c.FirstBookingOnly && !f.IsFirstBooking {
+		return fmt.Errorf("%w: first booking only", errCampaignNotEligible)
+	}
+	if len(c.CategoryIDs) > 0 && !intsOverlap(c.CategoryIDs, f.CategoryIDs) {
+		return fmt.Errorf("%w: service category", errCampaignNotEligible)
+	}
+	if len(c.TierIDs) > 0 && (f.ProviderTierID == nil || !intsOverlap(c.TierIDs, []int{*f.ProviderTierID})) {
+		return fmt.Errorf("%w: provider tier", errCampaignNotEligible)
+	}
+	if len(c.Provinces) > 0 {
+		matched := false
+		for _, p := range c.Provinces {
+			if f.ProviderProvince != nil && p == *f.ProviderProvince {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return fmt.Errorf("%w: province", errCampaignNotEligible)
+		}
+	}
+	if len(c.DaysOfWeek) > 0 && !intsOverlap(c.DaysOfWeek, []int{int(f.BookingDate.Weekday())}) {
+		return fmt.Errorf("%w: day of week", errCampaignNotEligible)
+	}
+	return nil
+}
+
+func intsOverlap(a, b []int) bool {
+	for _, x := range a {
+		for _, y := range b {
+			if x == y {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// campaignDiscount is the discount for amount, capped by max_discount, the amount itself
+// and whatever is left of the campaign budget
+func campaignDiscount(c *Campaign, amount float64) float64 {
+	var discount float64
+	if c.DiscountType == "percentage" {
+		discount = amount * c.DiscountValue / 100
+	} else {
+		discount = c.DiscountValue
+	}
+	if c.MaxDiscount != nil && discount > *c.MaxDiscount {
+		discount = *c.MaxDiscount
+	}
+	if discount > amount {
+		discount = amount
+	}
+	if remaining := c.budgetRemaining(); remaining != nil && discount > *remaining {
+		discount = *remaining
+	}
+	return roundSatang(discount)
+}
+
+// loadCampaignBookingFacts reads what eligibility needs about the booking, its provider and the client
+func loadCampaignBookingFacts(ctx context.Context, tx pgx.Tx, campaignID, bookingID, clientID, providerID int, amount float64, bookingDate time.Time) (campaignBookingFacts, error) {
+	f := campaignBookingFacts{Amount: amount, ProviderID: providerID, BookingDate: bookingDate}
+
+	err := tx.QueryRow(ctx, `
+		SELECT NOT EXISTS (
+			SELECT 1 FROM bookings
+			WHERE client_id = $1 AND booking_id <> $2 AND status NOT IN ('cancelled', 'rejected', 'expired')
+		)
+	`, clientID, bookingID).Scan(&f.IsFirstBooking)
+	if err != nil {
+		return f, err
+	}
+
+	err = tx.QueryRow(ctx, `
+		SELECT u.tier_id, p.province
+		FROM users u
+		LEFT JOIN user_profiles p ON u.user_id = p.user_id
+		WHERE u.user_id = $1
+	`, providerID).Scan(&f.ProviderTierID, &f.ProviderProvince)
+	if err != nil {
+		return f, err
+	}
+
+	err = tx.QueryRow(ctx, `
+		SELECT COALESCE(array_agg(category_id), '{}') FROM provider_categories WHERE provider_id = $1
+	`, providerID).Scan(&f.CategoryIDs)
+	if err != nil {
+		return f, err
+	}
+
+	err = tx.QueryRow(ctx, `
+		SELECT COUNT(*) FROM campaign_redemptions
+		WHERE campaign_id = $1 AND user_id = $2 AND status <> 'released'
+	`, campaignID, clientID).Scan(&f.UserRedemptions)
+	return f, err
+}
+
+// redeemCampaign applies a campaign discount to a booking. The campaign row is locked for the
+// whole check-and-spend, so concurrent redemptions can never overspend the budget or the
+// per-user limit.
+func redeemCampaign(ctx context.Context, tx pgx.Tx, campaignID, bookingID, userID int) (*CampaignRedemption, error) {
+	c, err := scanCampaign(tx.QueryRow(ctx, `SELECT `+campaignColumns+` FROM promotion_campaigns WHERE campaign_id = $1 FOR UPDATE`, campaignID))
+	if err != nil {
+		return nil, err
+	}
+
+	clientID, providerID, status, existing, err := loadBookingDiscounts(ctx, tx, bookingID)
+	if err != nil {
+		return nil, err
+	}
+	if clientID != userID {
+		return nil, pgx.ErrNoRows
+	}
+	if !campaignRedeemableBookingStatuses[status] {
+		return nil, errBookingNotRedeemable
+	}
+	if existing.HasCampaign {
+		return nil, errCampaignAlreadyOnBooking
+	}
+	if existing.Coupons > 0 && !existing.AllStackable {
+		return nil, errCouponNotStackable
+	}
+	amount := existing.CurrentTotal
+
+	var bookingDate time.Time
+	if err := tx.QueryRow(ctx, `SELECT booking_date FROM bookings WHERE booking_id = $1`, bookingID).Scan(&bookingDate); err != nil {
+		return nil, err
+	}
+
+	facts, err := loadCampaignBookingFacts(ctx, tx, campaignID, bookingID, clientID, providerID, amount, bookingDate)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkCampaignEligibility(c, facts, time.Now()); err != nil {
+		return nil, err
+	}
+
+	discount := campaignDiscount(c, amount)
+	if discount <= 0 {
+		return nil, errCampaignBudgetExhausted
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE promotion_campaigns
+		SET budget_used = budget_used + $2, redemption_count = redemption_count + 1, updated_at = NOW()
+		WHERE campaign_id = $1
+	`, campaignID, discount)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := scanCampaignRedemption(tx.QueryRow(ctx, `
+		INSERT INTO campaign_redemptions (campaign_id, user_id, booking_id, provider_id, original_amount, discount_amount, funding_source)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING `+campaignRedemptionColumns,
+		campaignID, clientID, bookingID, providerID, amount, discount, c.FundingSource))
+	if err != nil {
+		return nil, err
+	}
+
+	if _, _, _, err := applyBookingDiscount(ctx, tx, bookingID, discount); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// --- Settlement job ---
+
+// runCampaignSettlement returns the coupons and campaign budget of bookings that were cancelled
+// and settles campaign redemptions of finished bookings. For platform-funded campaigns the platform pays
+// the discount to the provider, so the provider still earns the full package price.
+func runCampaignSettlement(ctx context.Context, dbPool *pgxpool.Pool) error {
+	released, err := releaseCancelledBookingDiscounts(ctx, dbPool)
+	if err != nil {
+		return err
+	}
+
+	rows, err := dbPool.Query(ctx, `
+		SELECT cr.redemption_id
+		FROM campaign_redemptions cr
+		JOIN bookings b ON cr.booking_id = b.booking_id
+		WHERE cr.status = 'applied' AND b.status IN ('completed', 'funds_released')
+		ORDER BY cr.redemption_id
+		LIMIT 500
+	`)
+	if err != nil {
+		return err
+	}
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	rows.Close()
+
+	settled := 0
+	for _, id := range ids {
+		if err := settleCampaignRedemption(ctx, dbPool, id); err != nil {
+			log.Printf("⚠️  Campaign redemption #%d settlement failed: %v", id, err)
+			continue
+		}
+		settled++
+	}
+
+	if released > 0 || settled > 0 {
+		log.Printf("🎯 Campaign settlement: %d released, %d settled", released, settled)
+	}
+	return nil
+}
+
+// releaseCancelledBookingDiscounts catches cancellations that didn't release their discounts
+// directly (status changes, expiry)
+func releaseCancelledBookingDiscounts(ctx context.Context, dbPool *pgxpool.Pool) (int, error) {
+	rows, err := dbPool.Query(ctx, `
+		SELECT b.booking_id
+		FROM bookings b
+		WHERE b.status IN ('cancelled', 'rejected', 'expired')
+		  AND (EXISTS (SELECT 1 FROM campaign_redemptions cr WHERE cr.booking_id = b.booking_id AND cr.status = 'applied')
+		    OR EXISTS (SELECT 1 FROM coupon_usages cu WHERE cu.booking_id = b.booking_id AND cu.status = 'applied'))
+		LIMIT 500
+	`)
+	if err != nil {
+		return 0, err
+	}
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	rows.Close()
+
+	released := 0
+	for _, id := range ids {
+		if err := releaseBookingDiscountsNow(ctx, dbPool, id); err != nil {
+			log.Printf("⚠️  Releasing discounts of booking #%d failed: %v", id, err)
+			continue
+		}
+		released++
+	}
+	return released, nil
+}
+
+func settleCampaignRedemption(ctx context.Context, dbPool *pgxpool.Pool, redemptionID int) error {
+	tx, err := dbPool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	r, err := scanCampaignRedemption(tx.QueryRow(ctx, `
+		UPDATE campaign_redemptions
+		SET status = 'settled', settled_at = NOW()
+		WHERE redemption_id = $1 AND status = 'applied'
+		RETURNING `+campaignRedemptionColumns, redemptionID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil // settled by another run
+	}
+	if err != nil {
+		return err
+	}
+
+	if r.FundingSource == CampaignFundedByPlatform && r.DiscountAmount > 0 {
+		_, err = tx.Exec(ctx, `
+			INSERT INTO wallets (user_id, available_balance, pending_balance, total_earned)
+			VALUES ($1, $2, 0, $2)
+			ON CONFLICT (user_id)
+			DO UPDATE SET
+				available_balance = wallets.available_balance + $2,
+				total_earned = wallets.total_earned + $2,
+				updated_at = NOW()
+		`, r.ProviderID, r.DiscountAmount)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(ctx, `
+			INSERT INTO transactions (
+				user_id, type, status, amount, commission_amount, platform_commission, net_amount,
+				booking_id, description, processed_at
+			) VALUES ($1, 'campaign_subsidy', 'completed', $2, 0, 0, $2, $3, $4, NOW())
+		`, r.ProviderID, r.DiscountAmount, r.BookingID,
+			fmt.Sprintf("Platform campaign discount for booking #%d (campaign #%d)", r.BookingID, r.CampaignID))
+		if err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+
+	if r.FundingSource == CampaignFundedByPlatform && r.DiscountAmount > 0 {
+		CreateNotification(r.ProviderID, "campaign_subsidy_paid",
+			fmt.Sprintf("Platform ชดเชยส่วนลดแคมเปญ ฿%s สำหรับการจอง #%d", formatBaht(r.DiscountAmount), r.BookingID),
+			map[string]interface{}{"booking_id": r.BookingID, "campaign_id": r.CampaignID, "amount": r.DiscountAmount})
+	}
+	return nil
+}
+
+// startCampaignSettlementScheduler runs runCampaignSettlement every 30 minutes
+func startCampaignSettlementScheduler(dbPool *pgxpool.Pool, ctx context.Context) {
+	runPeriodically(ctx, "campaign settlement", 30*time.Minute, func(ctx context.Context) error {
+		return runCampaignSettlement(ctx, dbPool)
+	})
+}
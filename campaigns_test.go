@@ -0,0 +1,134 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testCampaign() *Campaign {
+	return &Campaign{
+		CampaignID:    1,
+		DiscountType:  "fixed",
+		DiscountValue: 100,
+		FundingSource: CampaignFundedByPlatform,
+		PerUserLimit:  1,
+		StartsAt:      time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+		EndsAt:        time.Date(2026, 12, 31, 23, 59, 59, 0, time.UTC),
+		IsActive:      true,
+	}
+}
+
+// Test campaign discount calculation and caps
+func TestCampaignDiscount(t *testing.T) {
+	amount := func(v float64) *float64 { return &v }
+
+	t.Run("Fixed Amount", func(t *testing.T) {
+		assert.Equal(t, 100.0, campaignDiscount(testCampaign(), 1500))
+	})
+
+	t.Run("Never More Than Booking", func(t *testing.T) {
+		assert.Equal(t, 80.0, campaignDiscount(testCampaign(), 80))
+	})
+
+	t.Run("Percentage With Max", func(t *testing.T) {
+		c := testCampaign()
+		c.DiscountType = "percentage"
+		c.DiscountValue = 20
+		assert.Equal(t, 300.0, campaignDiscount(c, 1500))
+		c.MaxDiscount = amount(250)
+		assert.Equal(t, 250.0, campaignDiscount(c, 1500))
+	})
+
+	t.Run("Capped By Remaining Budget", func(t *testing.T) {
+		c := testCampaign()
+		c.TotalBudget = amount(50000)
+		c.BudgetUsed = 49960
+		assert.Equal(t, 40.0, campaignDiscount(c, 1500))
+		c.BudgetUsed = 50000
+		assert.Equal(t, 0.0, campaignDiscount(c, 1500))
+	})
+}
+
+// Test campaign eligibility rules
+func TestCheckCampaignEligibility(t *testing.T) {
+	now := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+	saturday := time.Date(2026, 6, 6, 0, 0, 0, 0, time.UTC)
+	tier := 2
+	province := "เชียงใหม่"
+	facts := campaignBookingFacts{
+		Amount:           1500,
+		IsFirstBooking:   true,
+		ProviderID:       10,
+		CategoryIDs:      []int{3, 5},
+		ProviderTierID:   &tier,
+		ProviderProvince: &province,
+		BookingDate:      saturday,
+	}
+
+	t.Run("Eligible", func(t *testing.T) {
+		c := testCampaign()
+		c.FirstBookingOnly = true
+		c.CategoryIDs = []int{5}
+		c.TierIDs = []int{2, 3}
+		c.Provinces = []string{"เชียงใหม่", "ภูเก็ต"}
+		c.DaysOfWeek = []int{0, 6}
+		assert.NoError(t, checkCampaignEligibility(c, facts, now))
+	})
+
+	t.Run("Outside Campaign Window", func(t *testing.T) {
+		c := testCampaign()
+		assert.ErrorIs(t, checkCampaignEligibility(c, facts, c.EndsAt.Add(time.Minute)), errCampaignNotActive)
+		c.IsActive = false
+		assert.ErrorIs(t, checkCampaignEligibility(c, facts, now), errCampaignNotActive)
+	})
+
+	t.Run("Budget Used Up", func(t *testing.T) {
+		c := testCampaign()
+		budget := 50000.0
+		c.TotalBudget = &budget
+		c.BudgetUsed = budget
+		assert.ErrorIs(t, checkCampaignEligibility(c, facts, now), errCampaignBudgetExhausted)
+	})
+
+	t.Run("Per User Limit", func(t *testing.T) {
+		f := facts
+		f.UserRedemptions = 1
+		assert.ErrorIs(t, checkCampaignEligibility(testCampaign(), f, now), errCampaignUserLimit)
+	})
+
+	t.Run("Rules Not Met", func(t *testing.T) {
+		cases := map[string]func(c *Campaign, f *campaignBookingFacts){
+			"first booking": func(c *Campaign, f *campaignBookingFacts) { c.FirstBookingOnly = true; f.IsFirstBooking = false },
+			"category":      func(c *Campaign, f *campaignBookingFacts) { c.CategoryIDs = []int{7} },
+			"tier":          func(c *Campaign, f *campaignBookingFacts) { c.TierIDs = []int{4}; f.ProviderTierID = nil },
+			"province":      func(c *Campaign, f *campaignBookingFacts) { c.Provinces = []string{"ภูเก็ต"} },
+			"day of week":   func(c *Campaign, f *campaignBookingFacts) { c.DaysOfWeek = []int{1, 2, 3} },
+			"provider":      func(c *Campaign, f *campaignBookingFacts) { other := 11; c.ProviderID = &other },
+			"minimum":       func(c *Campaign, f *campaignBookingFacts) { min := 2000.0; c.MinBookingAmount = &min },
+		}
+		for name, apply := range cases {
+			c := testCampaign()
+			f := facts
+			apply(c, &f)
+			err := checkCampaignEligibility(c, f, now)
+			assert.True(t, errors.Is(err, errCampaignNotEligible), name)
+		}
+	})
+}
+
+// Test campaign dates are Bangkok calendar days
+func TestParseCampaignDate(t *testing.T) {
+	start, err := parseCampaignDate("2026-11-01", false)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2026, 10, 31, 17, 0, 0, 0, time.UTC), start.UTC())
+
+	end, err := parseCampaignDate("2026-11-30", true)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2026, 11, 30, 16, 59, 59, 0, time.UTC), end.UTC())
+
+	_, err = parseCampaignDate("30/11/2026", true)
+	assert.Error(t, err)
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stripe/stripe-go/v78"
+	"github.com/stripe/stripe-go/v78/checkout/session"
+)
+
+// GET /cancellation-fees (ค่าปรับของฉัน + ยอดค้างชำระ)
+func getMyCancellationFeesHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetInt("userID")
+
+		rows, err := dbPool.Query(ctx, `
+			SELECT `+cancellationFeeColumns+`
+			FROM cancellation_fees
+			WHERE client_id = $1
+			ORDER BY created_at DESC
+		`, userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch cancellation fees"})
+			return
+		}
+		defer rows.Close()
+
+		fees := make([]*CancellationFee, 0)
+		var outstanding float64
+		for rows.Next() {
+			f, err := scanCancellationFee(rows)
+			if err != nil {
+				continue
+			}
+			outstanding += f.Outstanding
+			fees = append(fees, f)
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"fees":              fees,
+			"outstanding_total": roundSatang(outstanding),
+			"can_book":          outstanding == 0,
+		})
+	}
+}
+
+// POST /cancellation-fees/:fee_id/pay (ชำระค่าปรับค้างผ่าน Stripe Checkout)
+func payCancellationFeeHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetInt("userID")
+
+		var req struct {
+			SuccessURL string `json:"success_url"`
+			CancelURL  string `json:"cancel_url"`
+		}
+		c.ShouldBindJSON(&req)
+
+		f, err := scanCancellationFee(dbPool.QueryRow(ctx,
+			`SELECT `+cancellationFeeColumns+` FROM cancellation_fees WHERE fee_id = $1`, c.Param("fee_id")))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Cancellation fee not found"})
+			return
+		}
+		if f.ClientID != userID {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+			return
+		}
+		if f.Outstanding <= 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "Nothing to pay for this fee", "status": f.Status})
+			return
+		}
+
+		successURL := req.SuccessURL
+		cancelURL := req.CancelURL
+		if successURL == "" {
+			successURL = "http://localhost:5174/cancellation-fees?payment=success"
+		}
+		if cancelURL == "" {
+			cancelURL = "http://localhost:5174/cancellation-fees?payment=cancelled"
+		}
+
+		params := &stripe.CheckoutSessionParams{
+			Mode: stripe.String(string(stripe.CheckoutSessionModePayment)),
+			LineItems: []*stripe.CheckoutSessionLineItemParams{
+				{
+					PriceData: &stripe.CheckoutSessionLineItemPriceDataParams{
+						Currency: stripe.String("thb"),
+						ProductData: &stripe.CheckoutSessionLineItemPriceDataProductDataParams{
+							Name: stripe.String(fmt.Sprintf("Cancellation fee - booking #%d", f.BookingID)),
+						},
+						UnitAmount: stripe.Int64(int64(math.Round(f.Outstanding * 100))),
+					},
+					Quantity: stripe.Int64(1),
+				},
+			},
+			SuccessURL:        stripe.String(successURL),
+			CancelURL:         stripe.String(cancelURL),
+			ClientReferenceID: stripe.String(strconv.Itoa(userID)),
+			Metadata: map[string]string{
+				"payment_type": "cancellation_fee",
+				"fee_id":       strconv.Itoa(f.FeeID),
+			},
+		}
+
+		s, err := session.New(params)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create payment session", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"checkout_url": s.URL,
+			"session_id":   s.ID,
+			"amount":       f.Outstanding,
+		})
+	}
+}
+
+// handleCancellationFeePayment - เรียกจาก paymentWebhookHandler() เมื่อ metadata.payment_type == "cancellation_fee"
+func handleCancellationFeePayment(dbPool *pgxpool.Pool, ctx context.Context, checkoutSession stripe.CheckoutSession) error {
+	feeID, err := strconv.Atoi(checkoutSession.Metadata["fee_id"])
+	if err != nil {
+		return fmt.Errorf("invalid fee_id in metadata: %v", err)
+	}
+	amount := float64(checkoutSession.AmountTotal) / 100
+
+	tx, err := dbPool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	f, err := applyCancellationFeePayment(ctx, tx, feeID, amount, CancellationFeeByCheckout, checkoutSession.ID)
+	if err != nil {
+		return fmt.Errorf("failed to record cancellation fee payment: %v", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+
+	if f.Status == "paid" {
+		CreateNotification(f.ProviderID, "cancellation_fee_paid",
+			fmt.Sprintf("ได้รับค่าปรับการยกเลิกสำหรับการจอง #%d แล้ว", f.BookingID),
+			map[string]interface{}{"fee_id": f.FeeID, "booking_id": f.BookingID, "amount": f.ProviderCredited})
+	}
+	return nil
+}
+
+// ================================
+// Admin: Cancellation Fees
+// ================================
+
+// GET /admin/cancellation-fees?status=pending|paid|waived|all
+func adminGetCancellationFeesHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		status := c.DefaultQuery("status", "pending")
+		if status == "all" {
+			status = ""
+		}
+
+		rows, err := dbPool.Query(ctx, `
+			SELECT `+cancellationFeeColumns+`
+			FROM cancellation_fees
+			WHERE ($1 = '' OR status = $1)
+			ORDER BY created_at DESC
+			LIMIT 500
+		`, status)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch cancellation fees"})
+			return
+		}
+		defer rows.Close()
+
+		fees := make([]*CancellationFee, 0)
+		var outstanding float64
+		for rows.Next() {
+			if f, err := scanCancellationFee(rows); err == nil {
+				outstanding += f.Outstanding
+				fees = append(fees, f)
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{"fees": fees, "total": len(fees), "outstanding_total": roundSatang(outstanding)})
+	}
+}
+
+// POST /admin/cancellation-fees/:fee_id/waive (ยกเว้นยอดที่ยังค้าง; ส่วนที่เก็บไปแล้วไม่คืน)
+func adminWaiveCancellationFeeHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		adminID := c.GetInt("userID")
+
+		var req struct {
+			Reason string `json:"reason" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		f, err := scanCancellationFee(dbPool.QueryRow(ctx, `
+			UPDATE cancellation_fees
+			SET status = 'waived', waived_at = NOW(), waived_by = $2, waiver_reason = $3
+			WHERE fee_id = $1 AND status = 'pending'
+			RETURNING `+cancellationFeeColumns, c.Param("fee_id"), adminID, req.Reason))
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Pending cancellation fee not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to waive cancellation fee"})
+			return
+		}
+
+		CreateNotification(f.ClientID, "cancellation_fee_waived",
+			fmt.Sprintf("ค่าปรับการยกเลิกสำหรับการจอง #%d ได้รับการยกเว้นแล้ว", f.BookingID),
+			map[string]interface{}{"fee_id": f.FeeID, "booking_id": f.BookingID})
+
+		c.JSON(http.StatusOK, gin.H{"message": "Cancellation fee waived", "fee": f})
+	}
+}
@@ -0,0 +1,396 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stripe/stripe-go/v78"
+	"github.com/stripe/stripe-go/v78/paymentintent"
+)
+
+// ================================
+// Cancellation Fee Settlement (เก็บค่าปรับยกเลิก: มัดจำ → ยอดที่ชำระแล้ว → บัตรที่บันทึกไว้ → ค้างชำระ)
+// ================================
+
+// cancellationFeeCommissionRate is the platform's cut of a collected cancellation fee
+const cancellationFeeCommissionRate = 0.10
+
+const (
+	CancellationFeeByDeposit   = "deposit"
+	CancellationFeeByPayment   = "booking_payment"
+	CancellationFeeBySavedCard = "saved_card"
+	CancellationFeeByCheckout  = "stripe_checkout"
+)
+
+var errNoSavedPaymentMethod = errors.New("no saved payment method")
+
+const cancellationFeeColumns = `
+	fee_id, booking_id, cancelled_by, client_id, provider_id, fee_amount, fee_percentage, amount_paid, status,
+	provider_credited, platform_commission, last_charge_error, paid_at, waived_at, waived_by, waiver_reason, created_at`
+
+func scanCancellationFee(row pgx.Row) (*CancellationFee, error) {
+	var f CancellationFee
+	err := row.Scan(&f.FeeID, &f.BookingID, &f.CancelledBy, &f.ClientID, &f.ProviderID, &f.FeeAmount, &f.FeePercentage,
+		&f.AmountPaid, &f.Status, &f.ProviderCredited, &f.PlatformCommission, &f.LastChargeError,
+		&f.PaidAt, &f.WaivedAt, &f.WaivedBy, &f.WaiverReason, &f.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if f.Status == "pending" {
+		f.Outstanding = roundSatang(f.FeeAmount - f.AmountPaid)
+	}
+	return &f, nil
+}
+
+// planCancellationFeeSettlement uses the paid deposit first: the part covering the fee is kept,
+// anything above the fee goes back to the client, and the rest of the fee is still owed
+func planCancellationFeeSettlement(fee, deposit float64) (fromDeposit, depositRefund, remaining float64) {
+	fromDeposit = roundSatang(deposit)
+	if fromDeposit > fee {
+		fromDeposit = roundSatang(fee)
+	}
+	depositRefund = roundSatang(deposit - fromDeposit)
+	remaining = roundSatang(fee - fromDeposit)
+	return
+}
+
+// lockFundedDeposit locks the booking's paid deposit, only if a completed wallet payment backs it.
+// A deposit marked paid without one was never funded, so nothing is kept or refunded from it.
+func lockFundedDeposit(ctx context.Context, tx pgx.Tx, bookingID int) (depositID int, amount float64, err error) {
+	err = tx.QueryRow(ctx, `
+		SELECT d.deposit_id, d.amount
+		FROM booking_deposits d
+		JOIN transactions t ON t.transaction_id = d.wallet_transaction_id
+		WHERE d.booking_id = $1 AND d.status = 'paid'
+		  AND t.type::text = 'wallet_payment' AND t.status::text = 'completed' AND t.amount >= d.amount
+		FOR UPDATE OF d
+	`, bookingID).Scan(&depositID, &amount)
+	if errors.Is(err, pgx.ErrNoRows) {
+		var unfunded int
+		tx.QueryRow(ctx, `SELECT COUNT(*) FROM booking_deposits WHERE booking_id = $1 AND status = 'paid'`, bookingID).Scan(&unfunded)
+		if unfunded > 0 {
+			log.Printf("⚠️  Booking #%d: deposit marked paid without a wallet payment, ignored", bookingID)
+		}
+		return 0, 0, nil
+	}
+	return depositID, amount, err
+}
+
+// reverseBookingPayment takes back the provider's credit for a booking the client already paid
+// (PromptPay/wallet) and returns the amount captured, so a cancellation can take its fee from it
+// and refund the rest. The net credit comes out of pending_balance first, then available_balance;
+// what the provider no longer has (already withdrawn) is recorded in debt_balance instead of
+// pushing available_balance below zero.
+func reverseBookingPayment(ctx context.Context, tx pgx.Tx, bookingID int) (captured float64, err error) {
+	// ล็อก booking ไว้ก่อน → การ reverse ซ้อนกันสองครั้งจะเห็น reversal ของอีกฝั่งเสมอ
+	if _, err := tx.Exec(ctx, `SELECT 1 FROM bookings WHERE booking_id = $1 FOR UPDATE`, bookingID); err != nil {
+		return 0, err
+	}
+
+	var providerID int
+	var providerNet float64
+	err = tx.QueryRow(ctx, `
+		SELECT user_id, COALESCE(SUM(amount), 0), COALESCE(SUM(net_amount), 0)
+		FROM transactions
+		WHERE booking_id = $1 AND type::text = 'booking_payment' AND status::text = 'completed'
+		  AND NOT EXISTS (
+			SELECT 1 FROM transactions r
+			WHERE r.booking_id = $1 AND r.type::text = 'booking_payment_reversal'
+		  )
+		GROUP BY user_id
+	`, bookingID).Scan(&providerID, &captured, &providerNet)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	captured, providerNet = roundSatang(captured), roundSatang(providerNet)
+	if captured <= 0 {
+		return 0, nil
+	}
+
+	var pending, available float64
+	err = tx.QueryRow(ctx, `
+		SELECT pending_balance, available_balance FROM wallets WHERE user_id = $1 FOR UPDATE
+	`, providerID).Scan(&pending, &available)
+	if err != nil {
+		return 0, err
+	}
+	fromPending, fromAvailable, shortfall := splitPaymentReversal(providerNet, pending, available)
+
+	_, err = tx.Exec(ctx, `
+		UPDATE wallets
+		SET pending_balance = pending_balance - $2,
+		    available_balance = available_balance - $3,
+		    debt_balance = debt_balance + $4,
+		    total_earned = total_earned - $5,
+		    updated_at = NOW()
+		WHERE user_id = $1
+	`, providerID, fromPending, fromAvailable, shortfall, providerNet)
+	if err != nil {
+		return 0, err
+	}
+
+	description := fmt.Sprintf("Booking #%d cancelled: payment reversed", bookingID)
+	if shortfall > 0 {
+		description += fmt.Sprintf(" (฿%s owed by provider)", formatBaht(shortfall))
+		log.Printf("⚠️  Booking #%d reversal: provider %d short ฿%.2f, recorded as debt\n", bookingID, providerID, shortfall)
+	}
+	_, err = tx.Exec(ctx, `
+		INSERT INTO transactions (user_id, type, status, amount, net_amount, booking_id, description, processed_at)
+		VALUES ($1, 'booking_payment_reversal', 'completed', $2, $3, $4, $5, NOW())
+	`, providerID, captured, -providerNet, bookingID, description)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.Exec(ctx, `UPDATE bookings SET payment_status = 'refunded', updated_at = NOW() WHERE booking_id = $1`, bookingID)
+	return captured, err
+}
+
+// splitPaymentReversal takes net from pending first, then from what is left in available; the
+// rest is the provider's shortfall
+func splitPaymentReversal(net, pending, available float64) (fromPending, fromAvailable, shortfall float64) {
+	fromPending = math.Min(net, math.Max(pending, 0))
+	fromAvailable = math.Min(roundSatang(net-fromPending), math.Max(available, 0))
+	shortfall = roundSatang(net - fromPending - fromAvailable)
+	return
+}
+
+// splitCancellationFee splits a collected amount into platform commission and provider share
+func splitCancellationFee(amount float64) (commission, providerShare float64) {
+	commission = roundSatang(amount * cancellationFeeCommissionRate)
+	providerShare = roundSatang(amount - commission)
+	return
+}
+
+// applyCancellationFeePayment records money collected for a fee and credits the provider's
+// share. A reference that was already applied (webhook retry) is ignored.
+func applyCancellationFeePayment(ctx context.Context, tx pgx.Tx, feeID int, amount float64, method, reference string) (*CancellationFee, error) {
+	f, err := scanCancellationFee(tx.QueryRow(ctx, `SELECT `+cancellationFeeColumns+` FROM cancellation_fees WHERE fee_id = $1 FOR UPDATE`, feeID))
+	if err != nil {
+		return nil, err
+	}
+
+	var paymentID int
+	err = tx.QueryRow(ctx, `
+		INSERT INTO cancellation_fee_payments (fee_id, method, amount, reference)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (reference) DO NOTHING
+		RETURNING payment_id
+	`, feeID, method, amount, reference).Scan(&paymentID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return f, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	commission, providerShare := splitCancellationFee(amount)
+
+	// provider ได้ส่วนแบ่งหลังหักค่าคอมมิชชั่น
+	_, err = tx.Exec(ctx, `
+		INSERT INTO wallets (user_id, available_balance, pending_balance, total_earned)
+		VALUES ($1, $2, 0, $2)
+		ON CONFLICT (user_id)
+		DO UPDATE SET
+			available_balance = wallets.available_balance + $2,
+			total_earned = wallets.total_earned + $2,
+			updated_at = NOW()
+	`, f.ProviderID, providerShare)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO transactions (
+			user_id, type, status, amount, commission_amount, platform_commission, net_amount,
+			booking_id, description, processed_at
+		) VALUES ($1, 'cancellation_fee', 'completed', $2, $3, $3, $4, $5, $6, NOW())
+	`, f.ProviderID, amount, commission, providerShare, f.BookingID,
+		fmt.Sprintf("Cancellation fee for booking #%d (%s)", f.BookingID, method))
+	if err != nil {
+		return nil, err
+	}
+
+	return scanCancellationFee(tx.QueryRow(ctx, `
+		UPDATE cancellation_fees
+		SET amount_paid = amount_paid + $2,
+		    provider_credited = provider_credited + $3,
+		    platform_commission = platform_commission + $4,
+		    status = CASE WHEN amount_paid + $2 >= fee_amount THEN 'paid' ELSE status END,
+		    paid_at = CASE WHEN amount_paid + $2 >= fee_amount THEN NOW() ELSE paid_at END,
+		    last_charge_error = NULL
+		WHERE fee_id = $1
+		RETURNING `+cancellationFeeColumns, feeID, amount, providerShare, commission))
+}
+
+// settleCancellationFee collects a new fee: from the funded deposit first, then from what the
+// client already paid for the booking, then by charging the card saved on the booking. Whatever
+// is left stays pending and blocks new bookings. Deposit or payment left over after the fee is
+// refunded (to the wallet when refundToWallet is set).
+func settleCancellationFee(ctx context.Context, dbPool *pgxpool.Pool, feeID int, refundToWallet bool) (*CancellationFee, error) {
+	tx, err := dbPool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	f, err := scanCancellationFee(tx.QueryRow(ctx, `SELECT `+cancellationFeeColumns+` FROM cancellation_fees WHERE fee_id = $1 FOR UPDATE`, feeID))
+	if err != nil {
+		return nil, err
+	}
+
+	// 1. หักจากเงินมัดจำ (เฉพาะมัดจำที่ชำระจริง)
+	depositID, deposit, err := lockFundedDeposit(ctx, tx, f.BookingID)
+	if err != nil {
+		return nil, err
+	}
+
+	fromDeposit, depositRefund, remaining := planCancellationFeeSettlement(f.Outstanding, deposit)
+	if deposit > 0 {
+		_, err = tx.Exec(ctx, `
+			UPDATE booking_deposits SET status = 'forfeited', forfeited_at = NOW(), updated_at = NOW()
+			WHERE deposit_id = $1
+		`, depositID)
+		if err != nil {
+			return nil, err
+		}
+		if fromDeposit > 0 {
+			f, err = applyCancellationFeePayment(ctx, tx, feeID, fromDeposit, CancellationFeeByDeposit,
+				fmt.Sprintf("deposit-%d", depositID))
+			if err != nil {
+				return nil, err
+			}
+		}
+		err = recordClientRefund(ctx, tx, f.BookingID, f.ClientID, depositRefund,
+			fmt.Sprintf("Deposit balance after cancellation fee for booking #%d", f.BookingID), refundToWallet)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// 2. หักจากยอดที่ชำระ booking ไปแล้ว (PromptPay/wallet) ส่วนที่เหลือคืน client
+	captured, err := reverseBookingPayment(ctx, tx, f.BookingID)
+	if err != nil {
+		return nil, err
+	}
+	if captured > 0 {
+		var fromPayment, paymentRefund float64
+		fromPayment, paymentRefund, remaining = planCancellationFeeSettlement(remaining, captured)
+		if fromPayment > 0 {
+			f, err = applyCancellationFeePayment(ctx, tx, feeID, fromPayment, CancellationFeeByPayment,
+				fmt.Sprintf("booking-payment-%d", f.BookingID))
+			if err != nil {
+				return nil, err
+			}
+		}
+		err = recordClientRefund(ctx, tx, f.BookingID, f.ClientID, paymentRefund,
+			fmt.Sprintf("Booking payment refund after cancellation fee for booking #%d", f.BookingID), refundToWallet)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var paymentIntentID *string
+	tx.QueryRow(ctx, `SELECT payment_intent_id FROM bookings WHERE booking_id = $1`, f.BookingID).Scan(&paymentIntentID)
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	if remaining <= 0 {
+		return f, nil
+	}
+
+	// 3. ตัดบัตรที่บันทึกไว้ตอนจ่าย booking (off-session)
+	chargeID, err := chargeSavedPaymentMethod(paymentIntentID, remaining, f)
+	if err != nil {
+		msg := err.Error()
+		dbPool.Exec(ctx, `UPDATE cancellation_fees SET last_charge_error = $2 WHERE fee_id = $1`, feeID, msg)
+		f.LastChargeError = &msg
+		if !errors.Is(err, errNoSavedPaymentMethod) {
+			log.Printf("⚠️  Cancellation fee #%d: saved card charge failed: %v", feeID, err)
+		}
+		return f, nil
+	}
+
+	tx, err = dbPool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+	f, err = applyCancellationFeePayment(ctx, tx, feeID, remaining, CancellationFeeBySavedCard, chargeID)
+	if err != nil {
+		// เงินถูกตัดแล้ว แต่บันทึกไม่สำเร็จ → ให้ reconciliation/finance ตามต่อ
+		log.Printf("❌ Cancellation fee #%d charged (%s) but not recorded: %v", feeID, chargeID, err)
+		return nil, err
+	}
+	return f, tx.Commit(ctx)
+}
+
+// chargeSavedPaymentMethod charges the card the client saved when paying for the booking
+func chargeSavedPaymentMethod(paymentIntentID *string, amount float64, f *CancellationFee) (string, error) {
+	if stripe.Key == "" || paymentIntentID == nil || *paymentIntentID == "" {
+		return "", errNoSavedPaymentMethod
+	}
+
+	original, err := paymentintent.Get(*paymentIntentID, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to load booking payment: %w", err)
+	}
+	if original.Customer == nil || original.PaymentMethod == nil {
+		return "", errNoSavedPaymentMethod
+	}
+
+	params := &stripe.PaymentIntentParams{
+		Amount:        stripe.Int64(int64(math.Round(amount * 100))),
+		Currency:      stripe.String("thb"),
+		Customer:      stripe.String(original.Customer.ID),
+		PaymentMethod: stripe.String(original.PaymentMethod.ID),
+		OffSession:    stripe.Bool(true),
+		Confirm:       stripe.Bool(true),
+		Description:   stripe.String(fmt.Sprintf("Cancellation fee for booking #%d", f.BookingID)),
+	}
+	params.AddMetadata("payment_type", "cancellation_fee")
+	params.AddMetadata("fee_id", fmt.Sprintf("%d", f.FeeID))
+	params.SetIdempotencyKey(fmt.Sprintf("cancellation-fee-%d-%.2f", f.FeeID, amount))
+
+	pi, err := paymentintent.New(params)
+	if err != nil {
+		return "", err
+	}
+	if pi.Status != stripe.PaymentIntentStatusSucceeded {
+		return "", fmt.Errorf("payment %s is %s", pi.ID, pi.Status)
+	}
+	return pi.ID, nil
+}
+
+// requireNoOutstandingCancellationFees blocks new bookings while the client owes fees
+func requireNoOutstandingCancellationFees(c *gin.Context, dbPool *pgxpool.Pool, ctx context.Context, clientID interface{}) bool {
+	var count int
+	var total float64
+	err := dbPool.QueryRow(ctx, `
+		SELECT COUNT(*), COALESCE(SUM(fee_amount - amount_paid), 0)
+		FROM cancellation_fees
+		WHERE client_id = $1 AND status = 'pending'
+	`, clientID).Scan(&count, &total)
+	if err != nil || count == 0 {
+		return true
+	}
+
+	c.JSON(http.StatusPaymentRequired, gin.H{
+		"error":             "You have unpaid cancellation fees",
+		"message":           "กรุณาชำระค่าปรับการยกเลิกที่ค้างอยู่ก่อนทำการจองใหม่",
+		"outstanding_fees":  count,
+		"outstanding_total": roundSatang(total),
+	})
+	return false
+}
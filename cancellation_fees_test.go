@@ -0,0 +1,79 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test how a cancellation fee is taken from the deposit
+func TestPlanCancellationFeeSettlement(t *testing.T) {
+	tests := []struct {
+		name                                string
+		fee, deposit                        float64
+		fromDeposit, depositRefund, remains float64
+	}{
+		{"No Deposit", 500, 0, 0, 0, 500},
+		{"Deposit Covers Part", 1000, 600, 600, 0, 400},
+		{"Deposit Covers Exactly", 600, 600, 600, 0, 0},
+		{"Deposit Larger Than Fee", 250.5, 900, 250.5, 649.5, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fromDeposit, depositRefund, remaining := planCancellationFeeSettlement(tt.fee, tt.deposit)
+			assert.Equal(t, tt.fromDeposit, fromDeposit)
+			assert.Equal(t, tt.depositRefund, depositRefund)
+			assert.Equal(t, tt.remains, remaining)
+		})
+	}
+}
+
+// Test a fee taken from the deposit and then from the captured booking payment
+func TestPlanCancellationFeeFromCapturedPayment(t *testing.T) {
+	t.Run("PromptPay Paid Booking Without Deposit", func(t *testing.T) {
+		_, _, remaining := planCancellationFeeSettlement(500, 0)
+		fromPayment, paymentRefund, remaining := planCancellationFeeSettlement(remaining, 2000)
+		assert.Equal(t, 500.0, fromPayment)
+		assert.Equal(t, 1500.0, paymentRefund)
+		assert.Equal(t, 0.0, remaining) // ไม่เหลือหนี้ค้าง
+	})
+
+	t.Run("Deposit First Then Payment", func(t *testing.T) {
+		_, _, remaining := planCancellationFeeSettlement(800, 300)
+		fromPayment, paymentRefund, remaining := planCancellationFeeSettlement(remaining, 1700)
+		assert.Equal(t, 500.0, fromPayment)
+		assert.Equal(t, 1200.0, paymentRefund)
+		assert.Equal(t, 0.0, remaining)
+	})
+}
+
+// Test a reversal never pushes the provider's balances below zero; the rest becomes debt
+func TestSplitPaymentReversal(t *testing.T) {
+	t.Run("Still Pending", func(t *testing.T) {
+		p, a, short := splitPaymentReversal(872.5, 1000, 50)
+		assert.Equal(t, []float64{872.5, 0, 0}, []float64{p, a, short})
+	})
+
+	t.Run("Partly Released", func(t *testing.T) {
+		p, a, short := splitPaymentReversal(872.5, 300, 1000)
+		assert.Equal(t, []float64{300, 572.5, 0}, []float64{p, a, short})
+	})
+
+	t.Run("Already Withdrawn", func(t *testing.T) {
+		p, a, short := splitPaymentReversal(872.5, 0, 200)
+		assert.Equal(t, []float64{0, 200, 672.5}, []float64{p, a, short})
+	})
+}
+
+// Test provider share after platform commission
+func TestSplitCancellationFee(t *testing.T) {
+	commission, share := splitCancellationFee(1000)
+	assert.Equal(t, 100.0, commission)
+	assert.Equal(t, 900.0, share)
+
+	commission, share = splitCancellationFee(333.35)
+	assert.Equal(t, 33.34, commission)
+	assert.Equal(t, 300.01, share)
+	assert.Equal(t, 333.35, roundSatang(commission+share))
+}
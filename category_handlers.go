@@ -178,6 +178,16 @@ func updateProviderCategoriesHandler(dbPool *pgxpool.Pool, ctx context.Context)
 			}
 		}
 
+		// แพ็คเกจที่อยู่ในหมวดหมู่ที่ถูกเอาออก → ไม่มีหมวดหมู่
+		_, err = tx.Exec(ctx, `
+			UPDATE service_packages SET category_id = NULL
+			WHERE provider_id = $1 AND category_id IS NOT NULL AND NOT (category_id = ANY($2))
+		`, userID, req.CategoryIDs)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update package categories"})
+			return
+		}
+
 		// Commit transaction
 		if err = tx.Commit(ctx); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
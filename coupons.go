@@ -0,0 +1,290 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ================================
+// Coupon Redemption (ใช้คูปองแบบ atomic + กฎการใช้ร่วมกัน)
+// ================================
+
+var (
+	errCouponNotValid       = errors.New("coupon is expired or not active yet")
+	errCouponUsedUp         = errors.New("coupon usage limit reached")
+	errCouponAlreadyUsed    = errors.New("you have already used this coupon")
+	errCouponOnBooking      = errors.New("coupon is already applied to this booking")
+	errCouponNotStackable   = errors.New("booking already has a discount that cannot be combined")
+	errCouponOtherProvider  = errors.New("coupon is not valid for this provider")
+	errCouponBelowMinimum   = errors.New("booking amount is below the coupon minimum")
+	errCouponNothingToApply = errors.New("booking has nothing left to discount")
+)
+
+// CouponRedemption is one coupon applied to a booking
+type CouponRedemption struct {
+	UsageID        int     `json:"usage_id"`
+	CouponID       int     `json:"coupon_id"`
+	Code           string  `json:"code"`
+	BookingID      int     `json:"booking_id"`
+	DiscountAmount float64 `json:"discount_amount"`
+	OriginalPrice  float64 `json:"original_price"`
+	TotalDiscount  float64 `json:"total_discount"` // ส่วนลดรวมทั้งหมดของ booking
+	NewTotal       float64 `json:"new_total"`
+}
+
+// bookingDiscounts summarizes discounts already on a booking
+type bookingDiscounts struct {
+	Coupons        int
+	AllStackable   bool
+	HasCampaign    bool
+	CouponIDs      []int
+	OriginalPrice  float64
+	CurrentTotal   float64
+	DiscountAmount float64
+}
+
+// checkCouponStacking decides whether a coupon may join the discounts already on a booking:
+// one discount per booking, unless every coupon involved is marked stackable.
+// Campaign discounts combine only with stackable coupons.
+func checkCouponStacking(stackable bool, existing bookingDiscounts) error {
+	if existing.Coupons == 0 && !existing.HasCampaign {
+		return nil
+	}
+	if !stackable || (existing.Coupons > 0 && !existing.AllStackable) {
+		return errCouponNotStackable
+	}
+	return nil
+}
+
+// couponDiscountFor is the coupon discount on amount, capped by max_discount and the amount itself
+func couponDiscountFor(coupon *Coupon, amount float64) float64 {
+	var discount float64
+	if coupon.DiscountType == "percentage" {
+		discount = amount * (coupon.DiscountValue / 100)
+	} else {
+		discount = coupon.DiscountValue
+	}
+	if coupon.MaxDiscount != nil && discount > *coupon.MaxDiscount {
+		discount = *coupon.MaxDiscount
+	}
+	if discount > amount {
+		discount = amount
+	}
+	return roundSatang(discount)
+}
+
+// loadBookingDiscounts locks the booking and reads the discounts already applied to it
+func loadBookingDiscounts(ctx context.Context, tx pgx.Tx, bookingID int) (clientID, providerID int, status string, d bookingDiscounts, err error) {
+	err = tx.QueryRow(ctx, `
+		SELECT client_id, provider_id, status, total_price,
+		       COALESCE(original_price, total_price), COALESCE(discount_amount, 0)
+		FROM bookings WHERE booking_id = $1
+		FOR UPDATE
+	`, bookingID).Scan(&clientID, &providerID, &status, &d.CurrentTotal, &d.OriginalPrice, &d.DiscountAmount)
+	if err != nil {
+		return
+	}
+
+	err = tx.QueryRow(ctx, `
+		SELECT COUNT(*), COALESCE(bool_and(COALESCE(c.stackable, false)), true),
+		       COALESCE(array_agg(cu.coupon_id), '{}')
+		FROM coupon_usages cu
+		JOIN coupons c ON c.coupon_id = cu.coupon_id
+		WHERE cu.booking_id = $1 AND cu.status = 'applied'
+	`, bookingID).Scan(&d.Coupons, &d.AllStackable, &d.CouponIDs)
+	if err != nil {
+		return
+	}
+
+	err = tx.QueryRow(ctx, `
+		SELECT EXISTS (SELECT 1 FROM campaign_redemptions WHERE booking_id = $1 AND status <> 'released')
+	`, bookingID).Scan(&d.HasCampaign)
+	return
+}
+
+// applyBookingDiscount takes amount off what the client pays, keeping the list price in
+// original_price and the running discount in discount_amount
+func applyBookingDiscount(ctx context.Context, tx pgx.Tx, bookingID int, amount float64) (originalPrice, totalDiscount, newTotal float64, err error) {
+	err = tx.QueryRow(ctx, `
+		UPDATE bookings
+		SET original_price = COALESCE(original_price, total_price),
+		    discount_amount = COALESCE(discount_amount, 0) + $2,
+		    total_price = total_price - $2,
+		    updated_at = NOW()
+		WHERE booking_id = $1
+		RETURNING original_price, discount_amount, total_price
+	`, bookingID, amount).Scan(&originalPrice, &totalDiscount, &newTotal)
+	return
+}
+
+// couponTermsColumns are the coupon fields needed to price and redeem a coupon
+const couponTermsColumns = `coupon_id, code, discount_type, discount_value, min_booking_amount, max_discount,
+	valid_from, valid_until, usage_limit, COALESCE(used_count, 0), provider_id, COALESCE(stackable, false)`
+
+func scanCouponTerms(row pgx.Row) (*Coupon, error) {
+	var coupon Coupon
+	err := row.Scan(&coupon.CouponID, &coupon.Code, &coupon.DiscountType, &coupon.DiscountValue,
+		&coupon.MinBookingAmount, &coupon.MaxDiscount, &coupon.ValidFrom, &coupon.ValidUntil,
+		&coupon.UsageLimit, &coupon.UsedCount, &coupon.ProviderID, &coupon.Stackable)
+	if err != nil {
+		return nil, err
+	}
+	return &coupon, nil
+}
+
+// lookupCouponQuery finds an active coupon by code that userID may use
+// (คูปองส่วนตัว เช่น รางวัลชวนเพื่อน ใช้ได้เฉพาะเจ้าของ)
+const lookupCouponQuery = `
+	SELECT ` + couponTermsColumns + `
+	FROM coupons
+	WHERE UPPER(code) = UPPER($1) AND is_active = true
+	  AND (assigned_user_id IS NULL OR assigned_user_id = $2)`
+
+// checkCouponTerms checks the coupon's own terms: validity window, usage limit, provider and minimum amount
+func checkCouponTerms(coupon *Coupon, now time.Time, providerID int, listPrice float64) error {
+	if now.Before(coupon.ValidFrom) || now.After(coupon.ValidUntil) {
+		return errCouponNotValid
+	}
+	if coupon.UsageLimit != nil && coupon.UsedCount >= *coupon.UsageLimit {
+		return errCouponUsedUp
+	}
+	if coupon.ProviderID != nil && *coupon.ProviderID != providerID {
+		return errCouponOtherProvider
+	}
+	if coupon.MinBookingAmount != nil && listPrice < *coupon.MinBookingAmount {
+		return errCouponBelowMinimum
+	}
+	return nil
+}
+
+// redeemCoupon applies a coupon to a booking inside tx. The coupon and booking rows are locked
+// for the whole check-and-apply, so concurrent requests can't overshoot usage_limit or stack
+// coupons that don't allow it.
+func redeemCoupon(ctx context.Context, tx pgx.Tx, code string, bookingID, userID int, now time.Time) (*CouponRedemption, error) {
+	coupon, err := scanCouponTerms(tx.QueryRow(ctx, lookupCouponQuery+" FOR UPDATE", strings.TrimSpace(code), userID))
+	if err != nil {
+		return nil, err
+	}
+
+	var alreadyUsed bool
+	err = tx.QueryRow(ctx, `
+		SELECT EXISTS (SELECT 1 FROM coupon_usages WHERE coupon_id = $1 AND user_id = $2 AND status = 'applied')
+	`, coupon.CouponID, userID).Scan(&alreadyUsed)
+	if err != nil {
+		return nil, err
+	}
+
+	clientID, providerID, status, existing, err := loadBookingDiscounts(ctx, tx, bookingID)
+	if err != nil {
+		return nil, err
+	}
+	if clientID != userID {
+		return nil, pgx.ErrNoRows
+	}
+	if !campaignRedeemableBookingStatuses[status] {
+		return nil, errBookingNotRedeemable
+	}
+	for _, id := range existing.CouponIDs {
+		if id == coupon.CouponID {
+			return nil, errCouponOnBooking
+		}
+	}
+	if alreadyUsed {
+		return nil, errCouponAlreadyUsed
+	}
+	if err := checkCouponStacking(coupon.Stackable, existing); err != nil {
+		return nil, err
+	}
+	if err := checkCouponTerms(coupon, now, providerID, existing.OriginalPrice); err != nil {
+		return nil, err
+	}
+
+	discount := couponDiscountFor(coupon, existing.CurrentTotal)
+	if discount <= 0 {
+		return nil, errCouponNothingToApply
+	}
+
+	// conditional update กันเกิน usage_limit อีกชั้น
+	tag, err := tx.Exec(ctx, `
+		UPDATE coupons SET used_count = COALESCE(used_count, 0) + 1
+		WHERE coupon_id = $1 AND (usage_limit IS NULL OR COALESCE(used_count, 0) < usage_limit)
+	`, coupon.CouponID)
+	if err != nil {
+		return nil, err
+	}
+	if tag.RowsAffected() == 0 {
+		return nil, errCouponUsedUp
+	}
+
+	r := CouponRedemption{CouponID: coupon.CouponID, Code: coupon.Code, BookingID: bookingID, DiscountAmount: discount}
+	err = tx.QueryRow(ctx, `
+		INSERT INTO coupon_usages (coupon_id, user_id, booking_id, discount_amount, status)
+		VALUES ($1, $2, $3, $4, 'applied')
+		RETURNING usage_id
+	`, coupon.CouponID, userID, bookingID, discount).Scan(&r.UsageID)
+	if err != nil {
+		return nil, err
+	}
+
+	r.OriginalPrice, r.TotalDiscount, r.NewTotal, err = applyBookingDiscount(ctx, tx, bookingID, discount)
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// releaseBookingDiscounts gives back the coupons and campaign budget used by a cancelled
+// booking. Safe to call more than once.
+func releaseBookingDiscounts(ctx context.Context, tx pgx.Tx, bookingID int) error {
+	_, err := tx.Exec(ctx, `
+		WITH released AS (
+			UPDATE coupon_usages SET status = 'released', released_at = NOW()
+			WHERE booking_id = $1 AND status = 'applied'
+			RETURNING coupon_id
+		)
+		UPDATE coupons c
+		SET used_count = GREATEST(COALESCE(c.used_count, 0) - r.n, 0)
+		FROM (SELECT coupon_id, COUNT(*) AS n FROM released GROUP BY coupon_id) r
+		WHERE c.coupon_id = r.coupon_id
+	`, bookingID)
+	if err != nil {
+		return fmt.Errorf("release coupons: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `
+		WITH released AS (
+			UPDATE campaign_redemptions SET status = 'released', released_at = NOW()
+			WHERE booking_id = $1 AND status = 'applied'
+			RETURNING campaign_id, discount_amount
+		)
+		UPDATE promotion_campaigns pc
+		SET budget_used = GREATEST(pc.budget_used - r.amount, 0),
+		    redemption_count = GREATEST(pc.redemption_count - r.n, 0),
+		    updated_at = NOW()
+		FROM (SELECT campaign_id, SUM(discount_amount) AS amount, COUNT(*) AS n FROM released GROUP BY campaign_id) r
+		WHERE pc.campaign_id = r.campaign_id
+	`, bookingID)
+	if err != nil {
+		return fmt.Errorf("release campaign: %w", err)
+	}
+	return nil
+}
+
+// releaseBookingDiscountsNow is releaseBookingDiscounts in its own transaction
+func releaseBookingDiscountsNow(ctx context.Context, dbPool *pgxpool.Pool, bookingID int) error {
+	tx, err := dbPool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	if err := releaseBookingDiscounts(ctx, tx, bookingID); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test when a coupon may join discounts already on a booking
+func TestCheckCouponStacking(t *testing.T) {
+	t.Run("First Discount", func(t *testing.T) {
+		assert.NoError(t, checkCouponStacking(false, bookingDiscounts{AllStackable: true}))
+	})
+
+	t.Run("Second Coupon Not Stackable", func(t *testing.T) {
+		existing := bookingDiscounts{Coupons: 1, AllStackable: true}
+		assert.ErrorIs(t, checkCouponStacking(false, existing), errCouponNotStackable)
+	})
+
+	t.Run("Existing Coupon Not Stackable", func(t *testing.T) {
+		existing := bookingDiscounts{Coupons: 1, AllStackable: false}
+		assert.ErrorIs(t, checkCouponStacking(true, existing), errCouponNotStackable)
+	})
+
+	t.Run("Both Stackable", func(t *testing.T) {
+		existing := bookingDiscounts{Coupons: 2, AllStackable: true}
+		assert.NoError(t, checkCouponStacking(true, existing))
+	})
+
+	t.Run("With Campaign Discount", func(t *testing.T) {
+		existing := bookingDiscounts{HasCampaign: true, AllStackable: true}
+		assert.ErrorIs(t, checkCouponStacking(false, existing), errCouponNotStackable)
+		assert.NoError(t, checkCouponStacking(true, existing))
+	})
+}
+
+func TestCouponDiscountFor(t *testing.T) {
+	max := 200.0
+
+	assert.Equal(t, 150.0, couponDiscountFor(&Coupon{DiscountType: "percentage", DiscountValue: 10}, 1500))
+	assert.Equal(t, 200.0, couponDiscountFor(&Coupon{DiscountType: "percentage", DiscountValue: 20, MaxDiscount: &max}, 1500))
+	assert.Equal(t, 300.0, couponDiscountFor(&Coupon{DiscountType: "fixed", DiscountValue: 300}, 1500))
+	// never more than what is left to pay
+	assert.Equal(t, 120.0, couponDiscountFor(&Coupon{DiscountType: "fixed", DiscountValue: 300}, 120))
+	assert.Equal(t, 33.33, couponDiscountFor(&Coupon{DiscountType: "percentage", DiscountValue: 10}, 333.33))
+}
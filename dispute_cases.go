@@ -0,0 +1,405 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ================================
+// Dispute Cases (ข้อพิพาท: หลักฐาน, ข้อความ 3 ฝ่าย, กำหนดเวลาตอบ)
+// ================================
+
+const (
+	DisputePartyClient   = "client"
+	DisputePartyProvider = "provider"
+	DisputePartyAdmin    = "admin"
+
+	DisputeOutcomeRefundClient = "refund_client"
+	DisputeOutcomePayProvider  = "pay_provider"
+	DisputeOutcomeSplit        = "split"
+
+	DisputeEvidencePhoto       = "photo"
+	DisputeEvidenceDocument    = "document"
+	DisputeEvidenceChatExcerpt = "chat_excerpt"
+	DisputeEvidenceCheckIn     = "check_in"
+	DisputeEvidenceNote        = "note"
+)
+
+// disputeResponseWindow is how long the other party has to answer a new dispute
+const disputeResponseWindow = 48 * time.Hour
+
+const maxDisputeEvidenceBytes = 10 << 20 // 10MB
+
+// booking statuses that can still be disputed (escrow not yet paid out)
+var disputableBookingStatuses = map[string]bool{
+	"provider_arrived": true, "in_progress": true, "completed": true,
+}
+
+var (
+	errDisputeNotOpen      = errors.New("dispute is not open")
+	errInvalidDisputeSplit = errors.New("invalid refund split")
+)
+
+type DisputeCase struct {
+	CaseID                 int        `json:"case_id"`
+	BookingID              int        `json:"booking_id"`
+	ClientID               int        `json:"client_id"`
+	ProviderID             int        `json:"provider_id"`
+	OpenedBy               int        `json:"opened_by"`
+	Reason                 string     `json:"reason"`
+	Description            string     `json:"description"`
+	Status                 string     `json:"status"` // open, resolved
+	DisputedAmount         float64    `json:"disputed_amount"`
+	ClientResponseDueAt    *time.Time `json:"client_response_due_at"`
+	ClientRespondedAt      *time.Time `json:"client_responded_at"`
+	ProviderResponseDueAt  *time.Time `json:"provider_response_due_at"`
+	ProviderRespondedAt    *time.Time `json:"provider_responded_at"`
+	Outcome                *string    `json:"outcome"`
+	RefundAmount           *float64   `json:"refund_amount"`
+	ProviderAmount         *float64   `json:"provider_amount"`
+	OutcomeNotes           *string    `json:"outcome_notes"`
+	ResolvedBy             *int       `json:"resolved_by"`
+	ResolvedAt             *time.Time `json:"resolved_at"`
+	CreatedAt              time.Time  `json:"created_at"`
+	ClientDeadlineStatus   string     `json:"client_deadline_status"`
+	ProviderDeadlineStatus string     `json:"provider_deadline_status"`
+}
+
+const disputeCaseColumns = `
+	case_id, booking_id, client_id, provider_id, opened_by, reason, description, status, disputed_amount,
+	client_response_due_at, client_responded_at, provider_response_due_at, provider_responded_at,
+	outcome, refund_amount, provider_amount, outcome_notes, resolved_by, resolved_at, created_at`
+
+func scanDisputeCase(row pgx.Row) (*DisputeCase, error) {
+	var d DisputeCase
+	err := row.Scan(&d.CaseID, &d.BookingID, &d.ClientID, &d.ProviderID, &d.OpenedBy, &d.Reason, &d.Description,
+		&d.Status, &d.DisputedAmount, &d.ClientResponseDueAt, &d.ClientRespondedAt,
+		&d.ProviderResponseDueAt, &d.ProviderRespondedAt, &d.Outcome, &d.RefundAmount, &d.ProviderAmount,
+		&d.OutcomeNotes, &d.ResolvedBy, &d.ResolvedAt, &d.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	d.ClientDeadlineStatus = disputeDeadlineStatus(d.ClientResponseDueAt, d.ClientRespondedAt, now)
+	d.ProviderDeadlineStatus = disputeDeadlineStatus(d.ProviderResponseDueAt, d.ProviderRespondedAt, now)
+	return &d, nil
+}
+
+// disputeDeadlineStatus: none (nothing requested), pending, met or missed
+func disputeDeadlineStatus(due, respondedAt *time.Time, now time.Time) string {
+	switch {
+	case due == nil:
+		return "none"
+	case respondedAt != nil:
+		return "met"
+	case now.After(*due):
+		return "missed"
+	default:
+		return "pending"
+	}
+}
+
+// disputePartyOf returns the caller's role in the case ("" = no access)
+func disputePartyOf(d *DisputeCase, userID int, isAdmin bool) string {
+	switch {
+	case userID == d.ClientID:
+		return DisputePartyClient
+	case userID == d.ProviderID:
+		return DisputePartyProvider
+	case isAdmin:
+		return DisputePartyAdmin
+	}
+	return ""
+}
+
+// computeDisputeSplit splits the disputed escrow between a client refund and the provider.
+// For split outcomes exactly one of refundPercentage (0-100) or refundAmount is used.
+func computeDisputeSplit(amount float64, outcome string, refundPercentage, refundAmount *float64) (refund, provider float64, err error) {
+	switch outcome {
+	case DisputeOutcomeRefundClient:
+		return roundSatang(amount), 0, nil
+	case DisputeOutcomePayProvider:
+		return 0, roundSatang(amount), nil
+	case DisputeOutcomeSplit:
+		switch {
+		case refundAmount != nil && refundPercentage == nil:
+			refund = roundSatang(*refundAmount)
+		case refundPercentage != nil && refundAmount == nil:
+			if *refundPercentage < 0 || *refundPercentage > 100 {
+				return 0, 0, errInvalidDisputeSplit
+			}
+			refund = roundSatang(amount * *refundPercentage / 100)
+		default:
+			return 0, 0, errInvalidDisputeSplit
+		}
+		if refund <= 0 || refund >= amount {
+			return 0, 0, errInvalidDisputeSplit
+		}
+		return refund, roundSatang(amount - refund), nil
+	}
+	return 0, 0, fmt.Errorf("unknown outcome %q", outcome)
+}
+
+// --- Timeline ---
+
+// addDisputeEvent appends an entry to the case timeline
+func addDisputeEvent(ctx context.Context, tx pgx.Tx, caseID int, actorID *int, eventType string, details map[string]interface{}) error {
+	var detailJSON []byte
+	if details != nil {
+		detailJSON, _ = json.Marshal(details)
+	}
+	_, err := tx.Exec(ctx, `
+		INSERT INTO dispute_events (case_id, actor_id, event_type, details)
+		VALUES ($1, $2, $3, $4)
+	`, caseID, actorID, eventType, detailJSON)
+	return err
+}
+
+// markDisputeResponse records that a party has answered; the first answer to an outstanding
+// deadline is what counts
+func markDisputeResponse(ctx context.Context, tx pgx.Tx, caseID int, party string) error {
+	var err error
+	switch party {
+	case DisputePartyClient:
+		_, err = tx.Exec(ctx, `
+			UPDATE dispute_cases SET client_responded_at = NOW(), updated_at = NOW()
+			WHERE case_id = $1 AND client_response_due_at IS NOT NULL AND client_responded_at IS NULL
+		`, caseID)
+	case DisputePartyProvider:
+		_, err = tx.Exec(ctx, `
+			UPDATE dispute_cases SET provider_responded_at = NOW(), updated_at = NOW()
+			WHERE case_id = $1 AND provider_response_due_at IS NOT NULL AND provider_responded_at IS NULL
+		`, caseID)
+	}
+	return err
+}
+
+// --- Resolution ---
+
+type disputeResolution struct {
+	CaseID          int     `json:"case_id"`
+	BookingID       int     `json:"booking_id"`
+	Outcome         string  `json:"outcome"`
+	RefundAmount    float64 `json:"refund_amount"`
+	ProviderAmount  float64 `json:"provider_amount"`
+	PlatformFee     float64 `json:"platform_fee"`
+	ProviderNetPaid float64 `json:"provider_net_paid"`
+	RefundTo        string  `json:"refund_to,omitempty"`
+}
+
+// resolveDisputeCase settles the escrow according to the outcome: the provider share is paid to
+// the provider's wallet (after the platform fee) and the client share is either credited to the
+// client's wallet or recorded as a pending booking_refund to the original payment method
+func resolveDisputeCase(ctx context.Context, tx pgx.Tx, caseID, adminID int, outcome string, refundPercentage, refundAmount *float64, notes string, refundToWallet bool) (*disputeResolution, error) {
+	d, err := scanDisputeCase(tx.QueryRow(ctx, `SELECT `+disputeCaseColumns+` FROM dispute_cases WHERE case_id = $1 FOR UPDATE`, caseID))
+	if err != nil {
+		return nil, err
+	}
+	if d.Status != "open" {
+		return nil, errDisputeNotOpen
+	}
+
+	res := disputeResolution{CaseID: caseID, BookingID: d.BookingID, Outcome: outcome}
+	res.RefundAmount, res.ProviderAmount, err = computeDisputeSplit(d.DisputedAmount, outcome, refundPercentage, refundAmount)
+	if err != nil {
+		return nil, err
+	}
+
+	// 1. ส่วนของ provider → wallet (หัก platform fee)
+	if res.ProviderAmount > 0 {
+		res.PlatformFee, res.ProviderNetPaid, err = creditProviderEscrow(ctx, tx, d.BookingID, d.ProviderID, res.ProviderAmount,
+			fmt.Sprintf("Dispute #%d settlement for booking #%d (%s)", caseID, d.BookingID, outcome))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// 2. ส่วนของ client → คืนเงินเข้า wallet หรือรอ finance โอนคืนช่องทางเดิม
+	if res.RefundAmount > 0 {
+		err = recordClientRefund(ctx, tx, d.BookingID, d.ClientID, res.RefundAmount,
+			fmt.Sprintf("Refund from dispute #%d for booking #%d (%s)", caseID, d.BookingID, outcome), refundToWallet)
+		if err != nil {
+			return nil, err
+		}
+		res.RefundTo = refundDestination(refundToWallet)
+	}
+
+	// 3. อัปเดต escrow
+	escrowStatus := "refunded"
+	if outcome == DisputeOutcomePayProvider {
+		escrowStatus = "released"
+	} else if outcome == DisputeOutcomeSplit {
+		escrowStatus = "partially_refunded"
+	}
+	_, err = tx.Exec(ctx, `
+		UPDATE escrow_payments
+		SET status = $1, admin_decision = $2, released_at = NOW(), updated_at = NOW()
+		WHERE booking_id = $3 AND status IN ('locked', 'disputed')
+	`, escrowStatus, outcome, d.BookingID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 4. อัปเดต booking
+	_, err = tx.Exec(ctx, `
+		UPDATE bookings
+		SET status = 'dispute_resolved',
+		    escrow_locked = false,
+		    admin_decision = $1,
+		    admin_decision_notes = $2,
+		    resolved_by_admin_id = $3,
+		    resolved_at = NOW(),
+		    updated_at = NOW()
+		WHERE booking_id = $4
+	`, outcome, notes, adminID, d.BookingID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 5. ปิดเคส
+	_, err = tx.Exec(ctx, `
+		UPDATE dispute_cases
+		SET status = 'resolved', outcome = $1, refund_amount = $2, provider_amount = $3,
+		    outcome_notes = $4, resolved_by = $5, resolved_at = NOW(), updated_at = NOW()
+		WHERE case_id = $6
+	`, outcome, res.RefundAmount, res.ProviderAmount, notes, adminID, caseID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = addDisputeEvent(ctx, tx, caseID, &adminID, "resolved", map[string]interface{}{
+		"outcome":         outcome,
+		"refund_amount":   res.RefundAmount,
+		"provider_amount": res.ProviderAmount,
+		"platform_fee":    res.PlatformFee,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// notifyDisputeParties sends a notification to client and provider (except the actor)
+func notifyDisputeParties(d *DisputeCase, actorID int, notifType, message string) {
+	for _, userID := range []int{d.ClientID, d.ProviderID} {
+		if userID == actorID {
+			continue
+		}
+		CreateNotification(userID, notifType, message, map[string]interface{}{
+			"case_id":    d.CaseID,
+			"booking_id": d.BookingID,
+		})
+	}
+}
+
+// notifyDisputeAdmins sends a notification to every admin
+func notifyDisputeAdmins(ctx context.Context, dbPool *pgxpool.Pool, d *DisputeCase, notifType, message string) {
+	rows, err := dbPool.Query(ctx, `SELECT user_id FROM users WHERE is_admin = true`)
+	if err != nil {
+		log.Printf("⚠️  Failed to load admins for dispute #%d: %v", d.CaseID, err)
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var adminID int
+		if rows.Scan(&adminID) == nil {
+			CreateNotification(adminID, notifType, message, map[string]interface{}{
+				"case_id":    d.CaseID,
+				"booking_id": d.BookingID,
+			})
+		}
+	}
+}
+
+// --- Background job ---
+
+// runDisputeDeadlineCheck records missed response deadlines on the timeline and tells admins
+func runDisputeDeadlineCheck(ctx context.Context, dbPool *pgxpool.Pool) error {
+	rows, err := dbPool.Query(ctx, `
+		UPDATE dispute_cases
+		SET client_deadline_missed_at = CASE
+		        WHEN client_response_due_at < NOW() AND client_responded_at IS NULL AND client_deadline_missed_at IS NULL
+		        THEN NOW() ELSE client_deadline_missed_at END,
+		    provider_deadline_missed_at = CASE
+		        WHEN provider_response_due_at < NOW() AND provider_responded_at IS NULL AND provider_deadline_missed_at IS NULL
+		        THEN NOW() ELSE provider_deadline_missed_at END,
+		    updated_at = NOW()
+		WHERE status = 'open'
+		  AND ((client_response_due_at < NOW() AND client_responded_at IS NULL AND client_deadline_missed_at IS NULL)
+		    OR (provider_response_due_at < NOW() AND provider_responded_at IS NULL AND provider_deadline_missed_at IS NULL))
+		RETURNING `+disputeCaseColumns+`,
+		          -- NOW() คงที่ทั้ง statement: missed_at = updated_at แปลว่าเพิ่งพลาดในรอบนี้
+		          client_deadline_missed_at = updated_at, provider_deadline_missed_at = updated_at
+	`)
+	if err != nil {
+		return err
+	}
+
+	type missed struct {
+		d       *DisputeCase
+		parties []string
+	}
+	var all []missed
+	for rows.Next() {
+		var d DisputeCase
+		var clientMissed, providerMissed bool
+		if err := rows.Scan(&d.CaseID, &d.BookingID, &d.ClientID, &d.ProviderID, &d.OpenedBy, &d.Reason, &d.Description,
+			&d.Status, &d.DisputedAmount, &d.ClientResponseDueAt, &d.ClientRespondedAt,
+			&d.ProviderResponseDueAt, &d.ProviderRespondedAt, &d.Outcome, &d.RefundAmount, &d.ProviderAmount,
+			&d.OutcomeNotes, &d.ResolvedBy, &d.ResolvedAt, &d.CreatedAt, &clientMissed, &providerMissed); err != nil {
+			rows.Close()
+			return err
+		}
+		m := missed{d: &d}
+		if clientMissed {
+			m.parties = append(m.parties, DisputePartyClient)
+		}
+		if providerMissed {
+			m.parties = append(m.parties, DisputePartyProvider)
+		}
+		all = append(all, m)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, m := range all {
+		for _, party := range m.parties {
+			if err := recordMissedDisputeDeadline(ctx, dbPool, m.d.CaseID, party); err != nil {
+				log.Printf("⚠️  Failed to record missed deadline on dispute #%d: %v", m.d.CaseID, err)
+				continue
+			}
+			notifyDisputeAdmins(ctx, dbPool, m.d, "dispute_deadline_missed",
+				fmt.Sprintf("The %s did not respond to dispute #%d (booking #%d) in time", party, m.d.CaseID, m.d.BookingID))
+		}
+	}
+	return nil
+}
+
+func recordMissedDisputeDeadline(ctx context.Context, dbPool *pgxpool.Pool, caseID int, party string) error {
+	tx, err := dbPool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := addDisputeEvent(ctx, tx, caseID, nil, "deadline_missed", map[string]interface{}{"party": party}); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// startDisputeDeadlineScheduler checks response deadlines every 15 minutes
+func startDisputeDeadlineScheduler(dbPool *pgxpool.Pool, ctx context.Context) {
+	runPeriodically(ctx, "dispute deadlines", 15*time.Minute, func(ctx context.Context) error {
+		return runDisputeDeadlineCheck(ctx, dbPool)
+	})
+}
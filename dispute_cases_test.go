@@ -0,0 +1,79 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test dispute outcome amounts
+func TestComputeDisputeSplit(t *testing.T) {
+	pct := func(v float64) *float64 { return &v }
+
+	t.Run("Full Outcomes", func(t *testing.T) {
+		refund, provider, err := computeDisputeSplit(1500, DisputeOutcomeRefundClient, nil, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, 1500.0, refund)
+		assert.Equal(t, 0.0, provider)
+
+		refund, provider, err = computeDisputeSplit(1500, DisputeOutcomePayProvider, nil, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, 0.0, refund)
+		assert.Equal(t, 1500.0, provider)
+	})
+
+	t.Run("Split By Percentage", func(t *testing.T) {
+		refund, provider, err := computeDisputeSplit(999.99, DisputeOutcomeSplit, pct(30), nil)
+		assert.NoError(t, err)
+		assert.Equal(t, 300.0, refund)
+		assert.Equal(t, 699.99, provider)
+		assert.Equal(t, 999.99, roundSatang(refund+provider))
+	})
+
+	t.Run("Split By Amount", func(t *testing.T) {
+		refund, provider, err := computeDisputeSplit(1500, DisputeOutcomeSplit, nil, pct(400))
+		assert.NoError(t, err)
+		assert.Equal(t, 400.0, refund)
+		assert.Equal(t, 1100.0, provider)
+	})
+
+	t.Run("Invalid Splits", func(t *testing.T) {
+		for _, tc := range []struct {
+			name           string
+			percent, fixed *float64
+		}{
+			{"neither", nil, nil},
+			{"both", pct(50), pct(100)},
+			{"zero percent", pct(0), nil},
+			{"full percent", pct(100), nil},
+			{"over amount", nil, pct(2000)},
+			{"negative", pct(-5), nil},
+		} {
+			_, _, err := computeDisputeSplit(1500, DisputeOutcomeSplit, tc.percent, tc.fixed)
+			assert.ErrorIs(t, err, errInvalidDisputeSplit, tc.name)
+		}
+
+		_, _, err := computeDisputeSplit(1500, "keep_it", nil, nil)
+		assert.Error(t, err)
+	})
+}
+
+// Test response deadline states and party lookup
+func TestDisputeDeadlinesAndParties(t *testing.T) {
+	now := time.Date(2025, 10, 16, 12, 0, 0, 0, bangkokLocation)
+	earlier, later := now.Add(-time.Hour), now.Add(time.Hour)
+
+	assert.Equal(t, "none", disputeDeadlineStatus(nil, nil, now))
+	assert.Equal(t, "pending", disputeDeadlineStatus(&later, nil, now))
+	assert.Equal(t, "missed", disputeDeadlineStatus(&earlier, nil, now))
+	assert.Equal(t, "met", disputeDeadlineStatus(&earlier, &earlier, now))
+
+	d := &DisputeCase{ClientID: 10, ProviderID: 20}
+	assert.Equal(t, DisputePartyClient, disputePartyOf(d, 10, false))
+	assert.Equal(t, DisputePartyProvider, disputePartyOf(d, 20, true))
+	assert.Equal(t, DisputePartyAdmin, disputePartyOf(d, 99, true))
+	assert.Equal(t, "", disputePartyOf(d, 99, false))
+
+	assert.Equal(t, []int{3, 1, 2}, uniqueInts([]int{3, 1, 3, 2, 1}))
+}
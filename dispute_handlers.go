@@ -0,0 +1,731 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// loadDisputeForUser loads the case in :case_id and the caller's party (client, provider or admin)
+func loadDisputeForUser(c *gin.Context, dbPool *pgxpool.Pool, ctx context.Context) (*DisputeCase, string, bool) {
+	userID := c.GetInt("userID")
+	caseID, err := strconv.Atoi(c.Param("case_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid case ID"})
+		return nil, "", false
+	}
+
+	d, err := scanDisputeCase(dbPool.QueryRow(ctx, `SELECT `+disputeCaseColumns+` FROM dispute_cases WHERE case_id = $1`, caseID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Dispute not found"})
+		return nil, "", false
+	}
+
+	var isAdmin bool
+	dbPool.QueryRow(ctx, `SELECT COALESCE(is_admin, false) FROM users WHERE user_id = $1`, userID).Scan(&isAdmin)
+	party := disputePartyOf(d, userID, isAdmin)
+	if party == "" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return nil, "", false
+	}
+	return d, party, true
+}
+
+// POST /bookings/:id/dispute
+// Client เปิดข้อพิพาท → ระงับการปลดเงิน และให้ provider ตอบภายใน 48 ชม.
+func disputeBookingHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		clientID := c.GetInt("userID")
+		bookingID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid booking ID"})
+			return
+		}
+
+		var req struct {
+			Reason      string `json:"reason" binding:"required"`
+			Description string `json:"description" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		tx, err := dbPool.Begin(ctx)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Transaction failed"})
+			return
+		}
+		defer tx.Rollback(ctx)
+
+		var dbClientID, providerID int
+		var status string
+		var amount float64
+		err = tx.QueryRow(ctx, `
+			SELECT client_id, provider_id, status, COALESCE(remaining_amount, 0)
+			FROM bookings
+			WHERE booking_id = $1
+			FOR UPDATE
+		`, bookingID).Scan(&dbClientID, &providerID, &status, &amount)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Booking not found"})
+			return
+		}
+		if dbClientID != clientID {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Not your booking"})
+			return
+		}
+		if !disputableBookingStatuses[status] {
+			c.JSON(http.StatusConflict, gin.H{"error": "This booking can no longer be disputed", "status": status})
+			return
+		}
+
+		// Partial unique index allows one open case per booking
+		d, err := scanDisputeCase(tx.QueryRow(ctx, `
+			INSERT INTO dispute_cases (
+				booking_id, client_id, provider_id, opened_by, reason, description, disputed_amount,
+				provider_response_due_at
+			) VALUES ($1, $2, $3, $2, $4, $5, $6, $7)
+			ON CONFLICT (booking_id) WHERE status = 'open' DO NOTHING
+			RETURNING `+disputeCaseColumns,
+			bookingID, clientID, providerID, req.Reason, req.Description, amount, time.Now().Add(disputeResponseWindow)))
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusConflict, gin.H{"error": "A dispute is already open for this booking"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create dispute"})
+			return
+		}
+
+		// อัปเดต escrow status → disputed
+		_, err = tx.Exec(ctx, `
+			UPDATE escrow_payments
+			SET status = 'disputed', dispute_reason = $1, disputed_at = NOW(), updated_at = NOW()
+			WHERE booking_id = $2 AND status = 'locked'
+		`, req.Reason, bookingID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create dispute"})
+			return
+		}
+
+		// อัปเดต booking (หยุดนับเวลาปลดเงินอัตโนมัติ)
+		_, err = tx.Exec(ctx, `
+			UPDATE bookings
+			SET status = 'disputed',
+			    dispute_reason = $1,
+			    dispute_description = $2,
+			    disputed_at = NOW(),
+			    updated_at = NOW()
+			WHERE booking_id = $3
+		`, req.Reason, req.Description, bookingID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create dispute"})
+			return
+		}
+
+		err = addDisputeEvent(ctx, tx, d.CaseID, &clientID, "opened", map[string]interface{}{
+			"reason":         req.Reason,
+			"booking_status": status,
+			"amount":         amount,
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create dispute"})
+			return
+		}
+
+		if err := tx.Commit(ctx); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create dispute"})
+			return
+		}
+
+		notifyDisputeParties(d, clientID, "dispute_opened",
+			fmt.Sprintf("The client opened a dispute on booking #%d. Please respond by %s.",
+				bookingID, d.ProviderResponseDueAt.In(bangkokLocation).Format("02 Jan 2006 15:04")))
+		notifyDisputeAdmins(ctx, dbPool, d, "dispute_opened",
+			fmt.Sprintf("New dispute #%d on booking #%d: %s", d.CaseID, bookingID, req.Reason))
+
+		c.JSON(http.StatusCreated, gin.H{
+			"message":   "Dispute created successfully",
+			"dispute":   d,
+			"next_step": "The provider has 48 hours to respond, then an admin will review the case",
+		})
+	}
+}
+
+// GET /disputes (เคสของฉันทั้งในฐานะ client และ provider)
+func getMyDisputesHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetInt("userID")
+
+		rows, err := dbPool.Query(ctx, `
+			SELECT `+disputeCaseColumns+`
+			FROM dispute_cases
+			WHERE client_id = $1 OR provider_id = $1
+			ORDER BY created_at DESC
+		`, userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch disputes"})
+			return
+		}
+		defer rows.Close()
+
+		disputes := make([]*DisputeCase, 0)
+		for rows.Next() {
+			if d, err := scanDisputeCase(rows); err == nil {
+				disputes = append(disputes, d)
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{"disputes": disputes, "total": len(disputes)})
+	}
+}
+
+// GET /disputes/:case_id (รายละเอียดเคส + timeline + หลักฐาน + ข้อความ)
+func getDisputeCaseHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		d, party, ok := loadDisputeForUser(c, dbPool, ctx)
+		if !ok {
+			return
+		}
+
+		// Timeline
+		timeline := make([]gin.H, 0)
+		rows, err := dbPool.Query(ctx, `
+			SELECT event_id, actor_id, event_type, details, created_at
+			FROM dispute_events WHERE case_id = $1 ORDER BY created_at, event_id
+		`, d.CaseID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch dispute timeline"})
+			return
+		}
+		for rows.Next() {
+			var eventID int
+			var actorID *int
+			var eventType string
+			var details []byte
+			var createdAt time.Time
+			if rows.Scan(&eventID, &actorID, &eventType, &details, &createdAt) != nil {
+				continue
+			}
+			var detailMap map[string]interface{}
+			json.Unmarshal(details, &detailMap)
+			timeline = append(timeline, gin.H{
+				"event_id": eventID, "actor_id": actorID, "event_type": eventType,
+				"details": detailMap, "created_at": createdAt,
+			})
+		}
+		rows.Close()
+
+		// Evidence
+		evidence := make([]gin.H, 0)
+		rows, err = dbPool.Query(ctx, `
+			SELECT evidence_id, submitted_by, party, evidence_type, description, file_url, content_type,
+			       message_ids, check_in_id, snapshot, created_at
+			FROM dispute_evidence WHERE case_id = $1 ORDER BY created_at, evidence_id
+		`, d.CaseID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch dispute evidence"})
+			return
+		}
+		for rows.Next() {
+			var evidenceID, submittedBy int
+			var evParty, evidenceType string
+			var description, fileURL, contentType *string
+			var messageIDs []int32
+			var checkInID *int
+			var snapshot []byte
+			var createdAt time.Time
+			if rows.Scan(&evidenceID, &submittedBy, &evParty, &evidenceType, &description, &fileURL, &contentType,
+				&messageIDs, &checkInID, &snapshot, &createdAt) != nil {
+				continue
+			}
+			var snapshotData interface{}
+			json.Unmarshal(snapshot, &snapshotData)
+			item := gin.H{
+				"evidence_id": evidenceID, "submitted_by": submittedBy, "party": evParty,
+				"evidence_type": evidenceType, "description": description, "content_type": contentType,
+				"message_ids": messageIDs, "check_in_id": checkInID, "snapshot": snapshotData,
+				"created_at": createdAt,
+			}
+			if fileURL != nil {
+				item["file_url"] = storedFileURL(*fileURL)
+			}
+			evidence = append(evidence, item)
+		}
+		rows.Close()
+
+		// Messages
+		messages := make([]gin.H, 0)
+		rows, err = dbPool.Query(ctx, `
+			SELECT message_id, sender_id, sender_party, body, created_at
+			FROM dispute_messages WHERE case_id = $1 ORDER BY created_at, message_id
+		`, d.CaseID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch dispute messages"})
+			return
+		}
+		for rows.Next() {
+			var messageID, senderID int
+			var senderParty, body string
+			var createdAt time.Time
+			if rows.Scan(&messageID, &senderID, &senderParty, &body, &createdAt) != nil {
+				continue
+			}
+			messages = append(messages, gin.H{
+				"message_id": messageID, "sender_id": senderID, "sender_party": senderParty,
+				"body": body, "created_at": createdAt,
+			})
+		}
+		rows.Close()
+
+		c.JSON(http.StatusOK, gin.H{
+			"dispute":  d,
+			"my_party": party,
+			"timeline": timeline,
+			"evidence": evidence,
+			"messages": messages,
+		})
+	}
+}
+
+// POST /disputes/:case_id/messages (ข้อความระหว่าง client, provider และ admin)
+func postDisputeMessageHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetInt("userID")
+		d, party, ok := loadDisputeForUser(c, dbPool, ctx)
+		if !ok {
+			return
+		}
+		if d.Status != "open" {
+			c.JSON(http.StatusConflict, gin.H{"error": errDisputeNotOpen.Error()})
+			return
+		}
+
+		var req struct {
+			Body string `json:"body" binding:"required,max=5000"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		tx, err := dbPool.Begin(ctx)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Transaction failed"})
+			return
+		}
+		defer tx.Rollback(ctx)
+
+		var messageID int
+		var createdAt time.Time
+		err = tx.QueryRow(ctx, `
+			INSERT INTO dispute_messages (case_id, sender_id, sender_party, body)
+			VALUES ($1, $2, $3, $4)
+			RETURNING message_id, created_at
+		`, d.CaseID, userID, party, req.Body).Scan(&messageID, &createdAt)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send message"})
+			return
+		}
+		if err := markDisputeResponse(ctx, tx, d.CaseID, party); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send message"})
+			return
+		}
+		if err := tx.Commit(ctx); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send message"})
+			return
+		}
+
+		notifyDisputeParties(d, userID, "dispute_message",
+			fmt.Sprintf("New message from the %s on dispute #%d", party, d.CaseID))
+
+		c.JSON(http.StatusCreated, gin.H{
+			"message_id":   messageID,
+			"sender_party": party,
+			"created_at":   createdAt,
+		})
+	}
+}
+
+// POST /disputes/:case_id/evidence
+// type: photo | document (image_base64), chat_excerpt (message_ids), check_in (check_in_id), note
+func addDisputeEvidenceHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetInt("userID")
+		d, party, ok := loadDisputeForUser(c, dbPool, ctx)
+		if !ok {
+			return
+		}
+		if d.Status != "open" {
+			c.JSON(http.StatusConflict, gin.H{"error": errDisputeNotOpen.Error()})
+			return
+		}
+
+		var req struct {
+			Type        string `json:"type" binding:"required,oneof=photo document chat_excerpt check_in note"`
+			Description string `json:"description"`
+			FileBase64  string `json:"file_base64"`
+			MessageIDs  []int  `json:"message_ids"`
+			CheckInID   *int   `json:"check_in_id"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		var (
+			fileURL, contentType *string
+			messageIDs           []int
+			checkInID            *int
+			snapshot             interface{}
+		)
+
+		switch req.Type {
+		case DisputeEvidencePhoto, DisputeEvidenceDocument:
+			encoded := req.FileBase64
+			if idx := strings.Index(encoded, ";base64,"); idx >= 0 {
+				encoded = encoded[idx+len(";base64,"):]
+			}
+			data, err := base64.StdEncoding.DecodeString(encoded)
+			if err != nil || len(data) == 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "file_base64 is required and must be valid base64"})
+				return
+			}
+			if len(data) > maxDisputeEvidenceBytes {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Evidence file must be 10MB or smaller"})
+				return
+			}
+			ct := http.DetectContentType(data)
+			if req.Type == DisputeEvidencePhoto && !strings.HasPrefix(ct, "image/") {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Photo evidence must be an image"})
+				return
+			}
+			objectKey := fmt.Sprintf("dispute-evidence/%d/%s", d.CaseID, uuid.NewString())
+			stored, err := uploadFileToStorage(ctx, objectKey, data, ct)
+			if err != nil {
+				log.Printf("❌ Failed to store dispute evidence: %v", err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store evidence file"})
+				return
+			}
+			fileURL, contentType = &stored, &ct
+
+		case DisputeEvidenceChatExcerpt:
+			if len(req.MessageIDs) == 0 || len(req.MessageIDs) > 50 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "message_ids must contain 1-50 message IDs"})
+				return
+			}
+			// ต้องเป็นข้อความในห้องแชทระหว่าง client กับ provider ของเคสนี้ (เก็บสำเนาไว้กันแก้ไข/ลบ)
+			rows, err := dbPool.Query(ctx, `
+				SELECT m.message_id, m.sender_id, m.content, m.created_at
+				FROM messages m
+				JOIN conversations cv ON cv.conversation_id = m.conversation_id
+				WHERE m.message_id = ANY($1)
+				  AND cv.user1_id = LEAST($2::int, $3::int) AND cv.user2_id = GREATEST($2::int, $3::int)
+				ORDER BY m.created_at, m.message_id
+			`, req.MessageIDs, d.ClientID, d.ProviderID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load chat messages"})
+				return
+			}
+			excerpt := make([]gin.H, 0, len(req.MessageIDs))
+			for rows.Next() {
+				var messageID, senderID int
+				var content string
+				var sentAt time.Time
+				if rows.Scan(&messageID, &senderID, &content, &sentAt) == nil {
+					messageIDs = append(messageIDs, messageID)
+					excerpt = append(excerpt, gin.H{
+						"message_id": messageID, "sender_id": senderID, "content": content, "sent_at": sentAt,
+					})
+				}
+			}
+			rows.Close()
+			if len(excerpt) != len(uniqueInts(req.MessageIDs)) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Some messages were not found in the chat between client and provider"})
+				return
+			}
+			snapshot = excerpt
+
+		case DisputeEvidenceCheckIn:
+			if req.CheckInID == nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "check_in_id is required"})
+				return
+			}
+			var checkedInAt time.Time
+			var checkedOutAt *time.Time
+			var lat, lng *float64
+			var ciStatus string
+			var notes *string
+			err := dbPool.QueryRow(ctx, `
+				SELECT checked_in_at, checked_out_at, latitude, longitude, status, notes
+				FROM booking_check_ins
+				WHERE check_in_id = $1 AND booking_id = $2
+			`, *req.CheckInID, d.BookingID).Scan(&checkedInAt, &checkedOutAt, &lat, &lng, &ciStatus, &notes)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Check-in record not found for this booking"})
+				return
+			}
+			checkInID = req.CheckInID
+			snapshot = gin.H{
+				"checked_in_at": checkedInAt, "checked_out_at": checkedOutAt,
+				"latitude": lat, "longitude": lng, "status": ciStatus, "notes": notes,
+			}
+
+		case DisputeEvidenceNote:
+			if strings.TrimSpace(req.Description) == "" {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "description is required for a note"})
+				return
+			}
+		}
+
+		var snapshotJSON []byte
+		if snapshot != nil {
+			snapshotJSON, _ = json.Marshal(snapshot)
+		}
+
+		tx, err := dbPool.Begin(ctx)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Transaction failed"})
+			return
+		}
+		defer tx.Rollback(ctx)
+
+		var evidenceID int
+		err = tx.QueryRow(ctx, `
+			INSERT INTO dispute_evidence (
+				case_id, submitted_by, party, evidence_type, description, file_url, content_type,
+				message_ids, check_in_id, snapshot
+			) VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, $7, $8, $9, $10)
+			RETURNING evidence_id
+		`, d.CaseID, userID, party, req.Type, req.Description, fileURL, contentType,
+			messageIDs, checkInID, snapshotJSON).Scan(&evidenceID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save evidence"})
+			return
+		}
+
+		err = addDisputeEvent(ctx, tx, d.CaseID, &userID, "evidence_added", map[string]interface{}{
+			"evidence_id": evidenceID, "type": req.Type, "party": party,
+		})
+		if err == nil {
+			err = markDisputeResponse(ctx, tx, d.CaseID, party)
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save evidence"})
+			return
+		}
+		if err := tx.Commit(ctx); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save evidence"})
+			return
+		}
+
+		notifyDisputeParties(d, userID, "dispute_evidence",
+			fmt.Sprintf("The %s added %s evidence to dispute #%d", party, strings.ReplaceAll(req.Type, "_", " "), d.CaseID))
+
+		c.JSON(http.StatusCreated, gin.H{"message": "Evidence added", "evidence_id": evidenceID})
+	}
+}
+
+func uniqueInts(values []int) []int {
+	seen := make(map[int]bool, len(values))
+	out := make([]int, 0, len(values))
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// ================================
+// Admin: Disputes
+// ================================
+
+// GET /admin/disputes?status=open|resolved|all
+func adminGetDisputesHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		status := c.DefaultQuery("status", "open")
+		if status == "all" {
+			status = ""
+		}
+
+		rows, err := dbPool.Query(ctx, `
+			SELECT `+disputeCaseColumns+`
+			FROM dispute_cases
+			WHERE ($1 = '' OR status = $1)
+			ORDER BY created_at DESC
+			LIMIT 200
+		`, status)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch disputes"})
+			return
+		}
+		defer rows.Close()
+
+		disputes := make([]*DisputeCase, 0)
+		for rows.Next() {
+			if d, err := scanDisputeCase(rows); err == nil {
+				disputes = append(disputes, d)
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{"disputes": disputes, "total": len(disputes)})
+	}
+}
+
+// POST /admin/disputes/:case_id/request-response (ขอคำชี้แจงจาก client/provider ภายในเวลาที่กำหนด)
+func adminRequestDisputeResponseHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		adminID := c.GetInt("userID")
+		caseID, err := strconv.Atoi(c.Param("case_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid case ID"})
+			return
+		}
+
+		var req struct {
+			Party   string `json:"party" binding:"required,oneof=client provider"`
+			Hours   int    `json:"hours" binding:"required,min=1,max=336"`
+			Message string `json:"message" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		dueAt := time.Now().Add(time.Duration(req.Hours) * time.Hour)
+
+		tx, err := dbPool.Begin(ctx)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Transaction failed"})
+			return
+		}
+		defer tx.Rollback(ctx)
+
+		d, err := scanDisputeCase(tx.QueryRow(ctx, `
+			UPDATE dispute_cases
+			SET client_response_due_at = CASE WHEN $2 = 'client' THEN $3 ELSE client_response_due_at END,
+			    client_responded_at = CASE WHEN $2 = 'client' THEN NULL ELSE client_responded_at END,
+			    client_deadline_missed_at = CASE WHEN $2 = 'client' THEN NULL ELSE client_deadline_missed_at END,
+			    provider_response_due_at = CASE WHEN $2 = 'provider' THEN $3 ELSE provider_response_due_at END,
+			    provider_responded_at = CASE WHEN $2 = 'provider' THEN NULL ELSE provider_responded_at END,
+			    provider_deadline_missed_at = CASE WHEN $2 = 'provider' THEN NULL ELSE provider_deadline_missed_at END,
+			    updated_at = NOW()
+			WHERE case_id = $1 AND status = 'open'
+			RETURNING `+disputeCaseColumns, caseID, req.Party, dueAt))
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Open dispute not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update dispute"})
+			return
+		}
+
+		_, err = tx.Exec(ctx, `
+			INSERT INTO dispute_messages (case_id, sender_id, sender_party, body)
+			VALUES ($1, $2, 'admin', $3)
+		`, caseID, adminID, req.Message)
+		if err == nil {
+			err = addDisputeEvent(ctx, tx, caseID, &adminID, "response_requested", map[string]interface{}{
+				"party": req.Party, "due_at": dueAt,
+			})
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update dispute"})
+			return
+		}
+		if err := tx.Commit(ctx); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update dispute"})
+			return
+		}
+
+		partyID := d.ClientID
+		if req.Party == DisputePartyProvider {
+			partyID = d.ProviderID
+		}
+		CreateNotification(partyID, "dispute_response_requested",
+			fmt.Sprintf("An admin needs your response on dispute #%d by %s", caseID,
+				dueAt.In(bangkokLocation).Format("02 Jan 2006 15:04")),
+			map[string]interface{}{"case_id": caseID, "booking_id": d.BookingID, "due_at": dueAt})
+
+		c.JSON(http.StatusOK, gin.H{"message": "Response requested", "dispute": d})
+	}
+}
+
+// POST /admin/disputes/:case_id/resolve
+// outcome: refund_client, pay_provider, split (refund_percentage หรือ refund_amount)
+func adminResolveDisputeHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		adminID := c.GetInt("userID")
+		caseID, err := strconv.Atoi(c.Param("case_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid case ID"})
+			return
+		}
+
+		var req struct {
+			Outcome          string   `json:"outcome" binding:"required,oneof=refund_client pay_provider split"`
+			RefundPercentage *float64 `json:"refund_percentage"`
+			RefundAmount     *float64 `json:"refund_amount"`
+			Notes            string   `json:"notes" binding:"required"`
+			RefundToWallet   bool     `json:"refund_to_wallet"` // คืนส่วนของ client เข้า wallet ทันที
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		tx, err := dbPool.Begin(ctx)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Transaction failed"})
+			return
+		}
+		defer tx.Rollback(ctx)
+
+		res, err := resolveDisputeCase(ctx, tx, caseID, adminID, req.Outcome, req.RefundPercentage, req.RefundAmount, req.Notes, req.RefundToWallet)
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Dispute not found"})
+			return
+		case errors.Is(err, errDisputeNotOpen):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		case errors.Is(err, errInvalidDisputeSplit):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Split needs either refund_percentage or refund_amount, leaving both sides a positive share"})
+			return
+		case err != nil:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resolve dispute"})
+			return
+		}
+
+		if err := tx.Commit(ctx); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resolve dispute"})
+			return
+		}
+
+		d := &DisputeCase{CaseID: res.CaseID, BookingID: res.BookingID}
+		dbPool.QueryRow(ctx, `SELECT client_id, provider_id FROM dispute_cases WHERE case_id = $1`, caseID).Scan(&d.ClientID, &d.ProviderID)
+		notifyDisputeParties(d, adminID, "dispute_resolved",
+			fmt.Sprintf("Dispute #%d has been resolved: client refund ฿%s, provider ฿%s",
+				caseID, formatBaht(res.RefundAmount), formatBaht(res.ProviderAmount)))
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":    "Dispute resolved successfully",
+			"resolution": res,
+		})
+	}
+}
@@ -229,6 +229,7 @@ func registerWithVerificationHandler(dbPool *pgxpool.Pool, ctx context.Context)
 			LastName  *string `json:"last_name"`
 			Phone     *string `json:"phone"`
 			OTP       string  `json:"otp" binding:"required,len=6"`
+			Referral  *string `json:"referral_code"`
 		}
 
 		if err := c.ShouldBindJSON(&newUser); err != nil {
@@ -294,6 +295,9 @@ func registerWithVerificationHandler(dbPool *pgxpool.Pool, ctx context.Context)
 		// 4. Delete used OTP
 		_, _ = dbPool.Exec(ctx, "DELETE FROM email_verifications WHERE email = $1", newUser.Email)
 
+		// 4.1 Referral attribution (never blocks registration)
+		referralApplied := attributeSignupReferral(ctx, dbPool, c, userID, newUser.Referral, "client", newUser.Phone)
+
 		// 5. Create JWT token
 		tokenString, err := createJWT(userID)
 		if err != nil {
@@ -302,9 +306,10 @@ func registerWithVerificationHandler(dbPool *pgxpool.Pool, ctx context.Context)
 		}
 
 		c.JSON(http.StatusCreated, gin.H{
-			"message": "Registration successful",
-			"user_id": userID,
-			"token":   tokenString,
+			"message":          "Registration successful",
+			"user_id":          userID,
+			"token":            tokenString,
+			"referral_applied": referralApplied,
 		})
 	}
 }
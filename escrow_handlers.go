@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
@@ -52,7 +53,7 @@ type EscrowPayment struct {
 func providerArrivedHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		bookingID := c.Param("id")
-		providerID := c.GetInt("user_id")
+		providerID := c.GetInt("userID")
 
 		// ตรวจสอบว่าเป็น provider ของ booking นี้จริง
 		var dbProviderID int
@@ -117,7 +118,7 @@ func providerArrivedHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.Handl
 func confirmProviderArrivalHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		bookingID := c.Param("id")
-		clientID := c.GetInt("user_id")
+		clientID := c.GetInt("userID")
 
 		// ตรวจสอบว่าเป็น client ของ booking นี้จริง
 		var dbClientID int
@@ -205,7 +206,7 @@ func confirmProviderArrivalHandler(dbPool *pgxpool.Pool, ctx context.Context) gi
 func providerCompleteServiceHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		bookingID := c.Param("id")
-		providerID := c.GetInt("user_id")
+		providerID := c.GetInt("userID")
 
 		var req struct {
 			Notes string `json:"notes"`
@@ -241,14 +242,24 @@ func providerCompleteServiceHandler(dbPool *pgxpool.Pool, ctx context.Context) g
 		}
 
 		// อัปเดตสถานะ
-		_, err = dbPool.Exec(ctx, `
+		tx, err := dbPool.Begin(ctx)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Transaction failed"})
+			return
+		}
+		defer tx.Rollback(ctx)
+
+		var completedAt time.Time
+		var remainingAmount float64
+		err = tx.QueryRow(ctx, `
 			UPDATE bookings
 			SET status = 'completed',
 			    provider_completed_at = NOW(),
 			    provider_completion_notes = $1,
 			    updated_at = NOW()
-			WHERE booking_id = $2
-		`, req.Notes, bookingID)
+			WHERE booking_id = $2 AND status = 'in_progress'
+			RETURNING provider_completed_at, COALESCE(remaining_amount, 0)
+		`, req.Notes, bookingID).Scan(&completedAt, &remainingAmount)
 
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update status"})
@@ -256,22 +267,41 @@ func providerCompleteServiceHandler(dbPool *pgxpool.Pool, ctx context.Context) g
 		}
 
 		// อัปเดต escrow
-		_, err = dbPool.Exec(ctx, `
+		_, err = tx.Exec(ctx, `
 			UPDATE escrow_payments
 			SET provider_completed_at = NOW()
 			WHERE booking_id = $1 AND status = 'locked'
 		`, bookingID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update escrow"})
+			return
+		}
 
-		// ส่ง notification ให้ client
+		// กำหนดเวลาให้ client ยืนยัน (เลยเวลาแล้วไม่มี dispute → ปลดเงินอัตโนมัติ)
 		bookingIDInt, _ := strconv.Atoi(bookingID)
+		rule, deadline, err := assignEscrowDeadline(ctx, tx, bookingIDInt, completedAt, remainingAmount)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set confirmation deadline"})
+			return
+		}
+
+		if err := tx.Commit(ctx); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+			return
+		}
+
+		// ส่ง notification ให้ client
 		sendNotification(dbPool, ctx, bookingIDInt, "service_completed",
 			"Provider has completed the service. Please confirm to release the payment.")
 
 		c.JSON(http.StatusOK, gin.H{
-			"message":         "Service marked as completed",
-			"status":          "completed",
-			"next_step":       "Wait for client confirmation to release payment",
-			"auto_release_in": "24 hours if no dispute",
+			"message":            "Service marked as completed",
+			"status":             "completed",
+			"next_step":          "Wait for client confirmation to release payment",
+			"auto_release_at":    deadline,
+			"auto_release_in":    fmt.Sprintf("%d hours if no dispute", rule.ConfirmationWindowHours),
+			"auto_release_rule":  rule.Name,
+			"confirmation_hours": rule.ConfirmationWindowHours,
 		})
 	}
 }
@@ -281,7 +311,7 @@ func providerCompleteServiceHandler(dbPool *pgxpool.Pool, ctx context.Context) g
 func confirmServiceCompletionHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		bookingID := c.Param("id")
-		clientID := c.GetInt("user_id")
+		clientID := c.GetInt("userID")
 
 		var req struct {
 			Rating int    `json:"rating"` // 1-5
@@ -292,13 +322,12 @@ func confirmServiceCompletionHandler(dbPool *pgxpool.Pool, ctx context.Context)
 		// ตรวจสอบว่าเป็น client ของ booking นี้จริง
 		var dbClientID, providerID int
 		var status string
-		var remainingAmount float64
 
 		err := dbPool.QueryRow(ctx, `
-			SELECT b.client_id, b.provider_id, b.status, b.remaining_amount
+			SELECT b.client_id, b.provider_id, b.status
 			FROM bookings b
 			WHERE b.booking_id = $1
-		`, bookingID).Scan(&dbClientID, &providerID, &status, &remainingAmount)
+		`, bookingID).Scan(&dbClientID, &providerID, &status)
 
 		if err != nil {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Booking not found"})
@@ -326,69 +355,18 @@ func confirmServiceCompletionHandler(dbPool *pgxpool.Pool, ctx context.Context)
 		}
 		defer tx.Rollback(ctx)
 
-		// 1. อัปเดต escrow status
-		_, err = tx.Exec(ctx, `
-			UPDATE escrow_payments
-			SET status = 'released',
-			    client_confirmed_at = NOW(),
-			    released_at = NOW()
-			WHERE booking_id = $1 AND status = 'locked'
-		`, bookingID)
-
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to release escrow"})
+		bookingIDInt, _ := strconv.Atoi(bookingID)
+		release, err := releaseEscrowFunds(ctx, tx, bookingIDInt, EscrowReleasedByClient)
+		if errors.Is(err, errEscrowNotReleasable) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Payment has already been released or disputed"})
 			return
 		}
-
-		// 2. คำนวณ platform fee (แนะนำ 8% ของยอดรวม)
-		const platformFeePercentage = 0.08 // 8% platform commission
-		platformFee := remainingAmount * platformFeePercentage
-		providerReceives := remainingAmount - platformFee
-
-		// 3. เพิ่มเงินเข้า wallet ของ provider (หักค่าคอมมิชชั่นแล้ว)
-		_, err = tx.Exec(ctx, `
-			UPDATE users
-			SET wallet_balance = wallet_balance + $1,
-			    updated_at = NOW()
-			WHERE user_id = $2
-		`, providerReceives, providerID)
-
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update wallet"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to release escrow"})
 			return
 		}
 
-		// 4. บันทึก platform fee เข้าระบบ
-		_, err = tx.Exec(ctx, `
-			INSERT INTO wallet_transactions (
-				user_id, transaction_type, amount,
-				description, reference_id, reference_type
-			) VALUES (0, 'platform_commission', $1, $2, $3, 'booking')
-		`, platformFee,
-			fmt.Sprintf("Platform commission (8%%) from booking #%s", bookingID),
-			bookingID)
-
-		// 5. บันทึก transaction ให้ provider
-		_, err = tx.Exec(ctx, `
-			INSERT INTO wallet_transactions (
-				user_id, transaction_type, amount,
-				description, reference_id, reference_type
-			) VALUES ($1, 'escrow_release', $2, $3, $4, 'booking')
-		`, providerID, providerReceives,
-			fmt.Sprintf("Remaining payment for booking #%s (after 8%% platform fee)", bookingID),
-			bookingID)
-
-		// 6. อัปเดต booking status
-		_, err = tx.Exec(ctx, `
-			UPDATE bookings
-			SET status = 'funds_released',
-			    payment_status = 'fully_paid',
-			    client_confirmed_at = NOW(),
-			    updated_at = NOW()
-			WHERE booking_id = $1
-		`, bookingID)
-
-		// 7. บันทึก review (ถ้ามี)
+		// บันทึก review (ถ้ามี)
 		if req.Rating > 0 {
 			_, err = tx.Exec(ctx, `
 				INSERT INTO reviews (
@@ -404,244 +382,20 @@ func confirmServiceCompletionHandler(dbPool *pgxpool.Pool, ctx context.Context)
 		}
 
 		// ส่ง notification ให้ provider
-		bookingIDInt, _ := strconv.Atoi(bookingID)
 		sendNotification(dbPool, ctx, bookingIDInt, "payment_released",
-			fmt.Sprintf("Client confirmed service completion. ฿%.2f has been added to your wallet (after platform fee).", providerReceives))
+			fmt.Sprintf("Client confirmed service completion. ฿%.2f has been added to your wallet (after platform fee).", release.ProviderReceives))
 
 		c.JSON(http.StatusOK, gin.H{
 			"message":           "Service confirmed. Payment released to provider.",
-			"amount_released":   providerReceives,
-			"platform_fee":      platformFee,
-			"platform_fee_rate": "8%",
-			"total_amount":      remainingAmount,
+			"amount_released":   release.ProviderReceives,
+			"platform_fee":      release.PlatformFee,
+			"platform_fee_rate": fmt.Sprintf("%.0f%%", escrowPlatformFeeRate*100),
+			"total_amount":      release.TotalAmount,
 			"status":            "funds_released",
 		})
 	}
 }
 
-// POST /bookings/:id/dispute
-// Client ร้องเรียนว่ามีปัญหากับการให้บริการ
-func disputeBookingHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		bookingID := c.Param("id")
-		clientID := c.GetInt("user_id")
-
-		var req struct {
-			Reason      string   `json:"reason" binding:"required"`
-			Description string   `json:"description" binding:"required"`
-			Evidence    []string `json:"evidence"` // URLs to images/videos
-		}
-
-		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-			return
-		}
-
-		// ตรวจสอบว่าเป็น client ของ booking นี้จริง
-		var dbClientID int
-		var status string
-
-		err := dbPool.QueryRow(ctx, `
-			SELECT client_id, status
-			FROM bookings
-			WHERE booking_id = $1
-		`, bookingID).Scan(&dbClientID, &status)
-
-		if err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Booking not found"})
-			return
-		}
-
-		if dbClientID != clientID {
-			c.JSON(http.StatusForbidden, gin.H{"error": "Not your booking"})
-			return
-		}
-
-		// อัปเดต escrow status → disputed
-		_, err = dbPool.Exec(ctx, `
-			UPDATE escrow_payments
-			SET status = 'disputed',
-			    dispute_reason = $1,
-			    disputed_at = NOW()
-			WHERE booking_id = $2 AND status = 'locked'
-		`, req.Reason, bookingID)
-
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create dispute"})
-			return
-		}
-
-		// อัปเดต booking
-		_, err = dbPool.Exec(ctx, `
-			UPDATE bookings
-			SET status = 'disputed',
-			    dispute_reason = $1,
-			    dispute_description = $2,
-			    disputed_at = NOW(),
-			    updated_at = NOW()
-			WHERE booking_id = $3
-		`, req.Reason, req.Description, bookingID)
-
-		// ส่ง notification ให้ admin และ provider
-		bookingIDInt, _ := strconv.Atoi(bookingID)
-		sendNotification(dbPool, ctx, bookingIDInt, "dispute_created",
-			"A dispute has been raised. Admin will review and make a decision.")
-
-		c.JSON(http.StatusOK, gin.H{
-			"message":    "Dispute created successfully",
-			"booking_id": bookingID,
-			"status":     "disputed",
-			"next_step":  "Admin will review within 24-48 hours",
-		})
-	}
-}
-
-// POST /admin/bookings/:id/resolve-dispute
-// Admin ตัดสินข้อพิพาท
-func adminResolveDisputeHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		bookingID := c.Param("id")
-		adminID := c.GetInt("user_id")
-
-		var req struct {
-			Decision         string  `json:"decision" binding:"required"` // refund_client, pay_provider, split
-			RefundPercentage float64 `json:"refund_percentage"`           // 0-100
-			Notes            string  `json:"notes" binding:"required"`
-		}
-
-		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-			return
-		}
-
-		// ดึงข้อมูล booking
-		var clientID, providerID int
-		var remainingAmount float64
-
-		err := dbPool.QueryRow(ctx, `
-			SELECT client_id, provider_id, remaining_amount
-			FROM bookings
-			WHERE booking_id = $1 AND status = 'disputed'
-		`, bookingID).Scan(&clientID, &providerID, &remainingAmount)
-
-		if err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Disputed booking not found"})
-			return
-		}
-
-		tx, err := dbPool.Begin(ctx)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Transaction failed"})
-			return
-		}
-		defer tx.Rollback(ctx)
-
-		var refundAmount, providerAmount float64
-
-		switch req.Decision {
-		case "refund_client":
-			// คืนเงินให้ client เต็มจำนวน
-			refundAmount = remainingAmount
-			providerAmount = 0
-
-		case "pay_provider":
-			// จ่ายให้ provider เต็มจำนวน
-			refundAmount = 0
-			providerAmount = remainingAmount
-
-		case "split":
-			// แบ่งตาม percentage
-			refundAmount = remainingAmount * (req.RefundPercentage / 100)
-			providerAmount = remainingAmount - refundAmount
-
-		default:
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid decision"})
-			return
-		}
-
-		// 1. คืนเงินให้ client (ถ้ามี)
-		if refundAmount > 0 {
-			_, err = tx.Exec(ctx, `
-				UPDATE users
-				SET wallet_balance = wallet_balance + $1
-				WHERE user_id = $2
-			`, refundAmount, clientID)
-
-			_, err = tx.Exec(ctx, `
-				INSERT INTO wallet_transactions (
-					user_id, transaction_type, amount,
-					description, reference_id, reference_type
-				) VALUES ($1, 'refund', $2, $3, $4, 'booking')
-			`, clientID, refundAmount,
-				fmt.Sprintf("Refund for disputed booking #%s", bookingID),
-				bookingID)
-		}
-
-		// 2. จ่ายเงินให้ provider (ถ้ามี)
-		if providerAmount > 0 {
-			_, err = tx.Exec(ctx, `
-				UPDATE users
-				SET wallet_balance = wallet_balance + $1
-				WHERE user_id = $2
-			`, providerAmount, providerID)
-
-			_, err = tx.Exec(ctx, `
-				INSERT INTO wallet_transactions (
-					user_id, transaction_type, amount,
-					description, reference_id, reference_type
-				) VALUES ($1, 'escrow_release', $2, $3, $4, 'booking')
-			`, providerID, providerAmount,
-				fmt.Sprintf("Partial payment for disputed booking #%s", bookingID),
-				bookingID)
-		}
-
-		// 3. อัปเดต escrow
-		escrowStatus := "refunded"
-		if req.Decision == "pay_provider" {
-			escrowStatus = "released"
-		} else if req.Decision == "split" {
-			escrowStatus = "partially_refunded"
-		}
-
-		_, err = tx.Exec(ctx, `
-			UPDATE escrow_payments
-			SET status = $1,
-			    admin_decision = $2,
-			    released_at = NOW()
-			WHERE booking_id = $3
-		`, escrowStatus, req.Decision, bookingID)
-
-		// 4. อัปเดต booking
-		_, err = tx.Exec(ctx, `
-			UPDATE bookings
-			SET status = 'dispute_resolved',
-			    admin_decision = $1,
-			    admin_decision_notes = $2,
-			    resolved_by_admin_id = $3,
-			    resolved_at = NOW(),
-			    updated_at = NOW()
-			WHERE booking_id = $4
-		`, req.Decision, req.Notes, adminID, bookingID)
-
-		if err := tx.Commit(ctx); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resolve dispute"})
-			return
-		}
-
-		// ส่ง notification
-		bookingIDInt, _ := strconv.Atoi(bookingID)
-		sendNotification(dbPool, ctx, bookingIDInt, "dispute_resolved",
-			fmt.Sprintf("Dispute resolved. Decision: %s", req.Decision))
-
-		c.JSON(http.StatusOK, gin.H{
-			"message":         "Dispute resolved successfully",
-			"decision":        req.Decision,
-			"refund_amount":   refundAmount,
-			"provider_amount": providerAmount,
-		})
-	}
-}
-
 // Helper function
 func sendNotification(dbPool *pgxpool.Pool, ctx context.Context, bookingID int, notifType, message string) {
 	// Implementation for sending notifications
@@ -0,0 +1,389 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ================================
+// Escrow Release & Client Confirmation Deadlines
+// ================================
+
+// escrowPlatformFeeRate is the platform commission taken from the remaining (escrowed) amount
+const escrowPlatformFeeRate = 0.08
+
+// Who released the escrow (bookings.escrow_released_by)
+const (
+	EscrowReleasedByClient = "client"
+	EscrowReleasedByAuto   = "auto"
+)
+
+var errEscrowNotReleasable = errors.New("booking is not awaiting client confirmation")
+
+// EscrowReleaseRule decides how long a client has to confirm completion (escrow_release_rules)
+type EscrowReleaseRule struct {
+	RuleID                  int      `json:"rule_id"`
+	Name                    string   `json:"name"`
+	ConfirmationWindowHours int      `json:"confirmation_window_hours"`
+	ReminderIntervalHours   int      `json:"reminder_interval_hours"` // 0 = ไม่ส่งเตือน
+	MinAmount               float64  `json:"min_amount"`
+	MaxAmount               *float64 `json:"max_amount"`
+	Priority                int      `json:"priority"` // เลขน้อยถูกเลือกก่อน
+	IsActive                bool     `json:"is_active"`
+}
+
+// defaultEscrowReleaseRule applies when no active rule matches
+var defaultEscrowReleaseRule = EscrowReleaseRule{Name: "Default", ConfirmationWindowHours: 24, ReminderIntervalHours: 8}
+
+// escrowConfirmDeadline is when funds release automatically if the client neither confirms nor disputes
+func escrowConfirmDeadline(completedAt time.Time, rule EscrowReleaseRule) time.Time {
+	return completedAt.Add(time.Duration(rule.ConfirmationWindowHours) * time.Hour)
+}
+
+// escrowReminderMessage tells the client when the payment auto-releases, in the booking's time zone
+func escrowReminderMessage(bookingID int, deadline time.Time, timezone string) string {
+	return fmt.Sprintf("Please confirm booking #%d is complete or raise a dispute. Payment will be released to the provider automatically on %s.",
+		bookingID, formatLocalTime(deadline, timezone))
+}
+
+// escrowRemindersDue is how many reminders should have gone out by now: one every
+// interval after completion, never at or after the deadline
+func escrowRemindersDue(completedAt, now time.Time, rule EscrowReleaseRule) int {
+	if rule.ReminderIntervalHours <= 0 || !now.After(completedAt) {
+		return 0
+	}
+	interval := time.Duration(rule.ReminderIntervalHours) * time.Hour
+	window := time.Duration(rule.ConfirmationWindowHours) * time.Hour
+
+	maxReminders := int((window - 1) / interval)
+	due := int(now.Sub(completedAt) / interval)
+	if due > maxReminders {
+		return maxReminders
+	}
+	return due
+}
+
+// matchEscrowReleaseRule picks the active rule for the escrowed amount
+func matchEscrowReleaseRule(ctx context.Context, tx pgx.Tx, amount float64) (EscrowReleaseRule, error) {
+	var rule EscrowReleaseRule
+	err := tx.QueryRow(ctx, `
+		SELECT rule_id, name, confirmation_window_hours, reminder_interval_hours, min_amount, max_amount, priority, is_active
+		FROM escrow_release_rules
+		WHERE is_active = true
+		  AND min_amount <= $1
+		  AND (max_amount IS NULL OR max_amount > $1)
+		ORDER BY priority, rule_id
+		LIMIT 1
+	`, amount).Scan(&rule.RuleID, &rule.Name, &rule.ConfirmationWindowHours, &rule.ReminderIntervalHours,
+		&rule.MinAmount, &rule.MaxAmount, &rule.Priority, &rule.IsActive)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return defaultEscrowReleaseRule, nil
+	}
+	return rule, err
+}
+
+// assignEscrowDeadline fixes the rule and deadline when the provider marks the service
+// completed, so later rule edits do not move existing deadlines
+func assignEscrowDeadline(ctx context.Context, tx pgx.Tx, bookingID int, completedAt time.Time, amount float64) (EscrowReleaseRule, time.Time, error) {
+	rule, err := matchEscrowReleaseRule(ctx, tx, amount)
+	if err != nil {
+		return rule, time.Time{}, err
+	}
+
+	var ruleID *int
+	if rule.RuleID != 0 {
+		ruleID = &rule.RuleID
+	}
+	deadline := escrowConfirmDeadline(completedAt, rule)
+	_, err = tx.Exec(ctx, `
+		UPDATE bookings
+		SET escrow_release_rule_id = $1, escrow_confirm_deadline = $2, escrow_reminders_sent = 0
+		WHERE booking_id = $3
+	`, ruleID, deadline, bookingID)
+	return rule, deadline, err
+}
+
+// escrowRelease is the money moved by releaseEscrowFunds
+type escrowRelease struct {
+	BookingID        int
+	ClientID         int
+	ProviderID       int
+	TotalAmount      float64
+	PlatformFee      float64
+	ProviderReceives float64
+}
+
+// releaseEscrowFunds pays the escrowed remaining amount to the provider's wallet (after the
+// platform fee). It locks the booking, so a client confirmation and the auto-release job
+// can never both pay out.
+func releaseEscrowFunds(ctx context.Context, tx pgx.Tx, bookingID int, releasedBy string) (*escrowRelease, error) {
+	r := escrowRelease{BookingID: bookingID}
+	var status string
+	err := tx.QueryRow(ctx, `
+		SELECT client_id, provider_id, status, COALESCE(remaining_amount, 0)
+		FROM bookings WHERE booking_id = $1
+		FOR UPDATE
+	`, bookingID).Scan(&r.ClientID, &r.ProviderID, &status, &r.TotalAmount)
+	if err != nil {
+		return nil, err
+	}
+	if status != "completed" {
+		return nil, errEscrowNotReleasable
+	}
+
+	// 1. อัปเดต escrow status
+	_, err = tx.Exec(ctx, `
+		UPDATE escrow_payments
+		SET status = 'released',
+		    client_confirmed_at = CASE WHEN $2 = 'client' THEN NOW() ELSE client_confirmed_at END,
+		    released_at = NOW(),
+		    updated_at = NOW()
+		WHERE booking_id = $1 AND status = 'locked'
+	`, bookingID, releasedBy)
+	if err != nil {
+		return nil, err
+	}
+
+	// 2. เพิ่มเงินเข้า wallet ของ provider (หักค่าคอมมิชชั่นแล้ว)
+	r.PlatformFee, r.ProviderReceives, err = creditProviderEscrow(ctx, tx, bookingID, r.ProviderID, r.TotalAmount,
+		fmt.Sprintf("Remaining payment for booking #%d (after %.0f%% platform fee, released by %s)",
+			bookingID, escrowPlatformFeeRate*100, releasedBy))
+	if err != nil {
+		return nil, err
+	}
+
+	// 3. อัปเดต booking status
+	_, err = tx.Exec(ctx, `
+		UPDATE bookings
+		SET status = 'funds_released',
+		    payment_status = 'fully_paid',
+		    client_confirmed_at = CASE WHEN $2 = 'client' THEN NOW() ELSE client_confirmed_at END,
+		    escrow_locked = false,
+		    escrow_released_by = $2,
+		    escrow_auto_released = ($2 = 'auto'),
+		    escrow_released_at = NOW(),
+		    updated_at = NOW()
+		WHERE booking_id = $1
+	`, bookingID, releasedBy)
+	if err != nil {
+		return nil, err
+	}
+
+	return &r, nil
+}
+
+// creditProviderEscrow pays amount of escrowed money to the provider's available balance
+// after the platform fee and records the matching escrow_release transaction
+func creditProviderEscrow(ctx context.Context, tx pgx.Tx, bookingID, providerID int, amount float64, description string) (fee, net float64, err error) {
+	fee = roundSatang(amount * escrowPlatformFeeRate)
+	net = roundSatang(amount - fee)
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO wallets (user_id, available_balance, pending_balance, total_earned)
+		VALUES ($1, $2, 0, $2)
+		ON CONFLICT (user_id)
+		DO UPDATE SET
+			available_balance = wallets.available_balance + $2,
+			total_earned = wallets.total_earned + $2,
+			updated_at = NOW()
+	`, providerID, net)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO transactions (
+			user_id, type, status, amount, commission_amount, platform_commission, net_amount,
+			booking_id, description, processed_at
+		) VALUES ($1, 'escrow_release', 'completed', $2, $3, $3, $4, $5, $6, NOW())
+	`, providerID, amount, fee, net, bookingID, description)
+	if err != nil {
+		return 0, 0, err
+	}
+	return fee, net, nil
+}
+
+// --- Background job ---
+
+// runEscrowAutoRelease assigns missing deadlines, sends reminders and releases
+// bookings whose confirmation window has passed without a dispute
+func runEscrowAutoRelease(ctx context.Context, dbPool *pgxpool.Pool) error {
+	now := time.Now()
+
+	// 1. bookings completed before deadlines existed get one now
+	rows, err := dbPool.Query(ctx, `
+		SELECT b.booking_id, b.provider_completed_at, COALESCE(b.remaining_amount, 0)
+		FROM bookings b
+		JOIN escrow_payments e ON e.booking_id = b.booking_id AND e.status = 'locked'
+		WHERE b.status = 'completed'
+		  AND b.provider_completed_at IS NOT NULL
+		  AND b.escrow_confirm_deadline IS NULL
+	`)
+	if err != nil {
+		return err
+	}
+	type pendingDeadline struct {
+		bookingID   int
+		completedAt time.Time
+		amount      float64
+	}
+	var missing []pendingDeadline
+	for rows.Next() {
+		var p pendingDeadline
+		if rows.Scan(&p.bookingID, &p.completedAt, &p.amount) == nil {
+			missing = append(missing, p)
+		}
+	}
+	rows.Close()
+
+	for _, p := range missing {
+		tx, err := dbPool.Begin(ctx)
+		if err != nil {
+			return err
+		}
+		_, _, err = assignEscrowDeadline(ctx, tx, p.bookingID, p.completedAt, p.amount)
+		if err == nil {
+			err = tx.Commit(ctx)
+		}
+		tx.Rollback(ctx)
+		if err != nil {
+			log.Printf("⚠️  Failed to assign escrow deadline for booking %d: %v", p.bookingID, err)
+		}
+	}
+
+	// 2. reminders
+	rows, err = dbPool.Query(ctx, `
+		SELECT b.booking_id, b.client_id, b.provider_completed_at, b.escrow_confirm_deadline, b.escrow_reminders_sent, b.timezone,
+		       COALESCE(r.confirmation_window_hours, $1), COALESCE(r.reminder_interval_hours, $2)
+		FROM bookings b
+		JOIN escrow_payments e ON e.booking_id = b.booking_id AND e.status = 'locked'
+		LEFT JOIN escrow_release_rules r ON r.rule_id = b.escrow_release_rule_id
+		WHERE b.status = 'completed'
+		  AND b.escrow_confirm_deadline > NOW()
+	`, defaultEscrowReleaseRule.ConfirmationWindowHours, defaultEscrowReleaseRule.ReminderIntervalHours)
+	if err != nil {
+		return err
+	}
+	type reminder struct {
+		bookingID, clientID, sent, due int
+		deadline                       time.Time
+		timezone                       string
+	}
+	var reminders []reminder
+	for rows.Next() {
+		var r reminder
+		var completedAt time.Time
+		var rule EscrowReleaseRule
+		if rows.Scan(&r.bookingID, &r.clientID, &completedAt, &r.deadline, &r.sent, &r.timezone,
+			&rule.ConfirmationWindowHours, &rule.ReminderIntervalHours) != nil {
+			continue
+		}
+		if r.due = escrowRemindersDue(completedAt, now, rule); r.due > r.sent {
+			reminders = append(reminders, r)
+		}
+	}
+	rows.Close()
+
+	for _, r := range reminders {
+		result, err := dbPool.Exec(ctx, `
+			UPDATE bookings SET escrow_reminders_sent = $1, escrow_last_reminder_at = NOW()
+			WHERE booking_id = $2 AND escrow_reminders_sent = $3
+		`, r.due, r.bookingID, r.sent)
+		if err != nil || result.RowsAffected() == 0 {
+			continue
+		}
+		CreateNotification(r.clientID, "escrow_confirmation_reminder",
+			escrowReminderMessage(r.bookingID, r.deadline, r.timezone),
+			map[string]interface{}{
+				"booking_id":   r.bookingID,
+				"deadline":     r.deadline,
+				"reminder_no":  r.due,
+				"auto_release": true,
+			})
+	}
+
+	// 3. auto-release
+	rows, err = dbPool.Query(ctx, `
+		SELECT b.booking_id
+		FROM bookings b
+		JOIN escrow_payments e ON e.booking_id = b.booking_id AND e.status = 'locked'
+		WHERE b.status = 'completed'
+		  AND b.escrow_confirm_deadline <= NOW()
+		ORDER BY b.escrow_confirm_deadline
+		LIMIT 200
+	`)
+	if err != nil {
+		return err
+	}
+	var due []int
+	for rows.Next() {
+		var bookingID int
+		if rows.Scan(&bookingID) == nil {
+			due = append(due, bookingID)
+		}
+	}
+	rows.Close()
+
+	for _, bookingID := range due {
+		release, err := autoReleaseEscrow(ctx, dbPool, bookingID)
+		if errors.Is(err, errEscrowNotReleasable) {
+			continue // disputed or confirmed in the meantime
+		}
+		if err != nil {
+			log.Printf("⚠️  Failed to auto-release escrow for booking %d: %v", bookingID, err)
+			continue
+		}
+
+		CreateNotification(release.ProviderID, "escrow_auto_released",
+			fmt.Sprintf("The confirmation window for booking #%d has passed. ฿%.2f has been added to your wallet (after platform fee).",
+				bookingID, release.ProviderReceives),
+			map[string]interface{}{"booking_id": bookingID, "amount": release.ProviderReceives})
+		CreateNotification(release.ClientID, "escrow_auto_released",
+			fmt.Sprintf("Booking #%d was not confirmed or disputed in time, so payment has been released to the provider.", bookingID),
+			map[string]interface{}{"booking_id": bookingID})
+	}
+	return nil
+}
+
+func autoReleaseEscrow(ctx context.Context, dbPool *pgxpool.Pool, bookingID int) (*escrowRelease, error) {
+	tx, err := dbPool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	// ยืนยันอีกครั้งหลังล็อก booking: ยังไม่เลย deadline หรือถูก dispute แล้ว → ข้าม
+	var overdue bool
+	err = tx.QueryRow(ctx, `
+		SELECT COALESCE(escrow_confirm_deadline <= NOW(), false) FROM bookings WHERE booking_id = $1 FOR UPDATE
+	`, bookingID).Scan(&overdue)
+	if err != nil {
+		return nil, err
+	}
+	if !overdue {
+		return nil, errEscrowNotReleasable
+	}
+
+	release, err := releaseEscrowFunds(ctx, tx, bookingID, EscrowReleasedByAuto)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	log.Printf("⏰ Escrow auto-released: Booking=%d, Provider=%d, Amount=฿%.2f", bookingID, release.ProviderID, release.ProviderReceives)
+	return release, nil
+}
+
+// startEscrowAutoReleaseScheduler checks deadlines and reminders every 10 minutes
+func startEscrowAutoReleaseScheduler(dbPool *pgxpool.Pool, ctx context.Context) {
+	runPeriodically(ctx, "escrow-auto-release", 10*time.Minute, func(ctx context.Context) error {
+		return runEscrowAutoRelease(ctx, dbPool)
+	})
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test confirmation deadline and reminder schedule
+func TestEscrowReleaseSchedule(t *testing.T) {
+	completedAt := time.Date(2025, 10, 16, 10, 0, 0, 0, bangkokLocation)
+	after := func(h float64) time.Time { return completedAt.Add(time.Duration(h * float64(time.Hour))) }
+
+	t.Run("Deadline", func(t *testing.T) {
+		assert.Equal(t, after(24), escrowConfirmDeadline(completedAt, defaultEscrowReleaseRule))
+		assert.Equal(t, after(72), escrowConfirmDeadline(completedAt, EscrowReleaseRule{ConfirmationWindowHours: 72}))
+	})
+
+	t.Run("Reminders Every 8h In 24h Window", func(t *testing.T) {
+		rule := defaultEscrowReleaseRule
+		assert.Equal(t, 0, escrowRemindersDue(completedAt, after(7.9), rule))
+		assert.Equal(t, 1, escrowRemindersDue(completedAt, after(8), rule))
+		assert.Equal(t, 2, escrowRemindersDue(completedAt, after(16.5), rule))
+		assert.Equal(t, 2, escrowRemindersDue(completedAt, after(30), rule)) // ไม่เตือนตอนครบกำหนด
+	})
+
+	t.Run("Interval Equal To Half Window", func(t *testing.T) {
+		rule := EscrowReleaseRule{ConfirmationWindowHours: 24, ReminderIntervalHours: 12}
+		assert.Equal(t, 1, escrowRemindersDue(completedAt, after(23), rule))
+		assert.Equal(t, 1, escrowRemindersDue(completedAt, after(48), rule))
+	})
+
+	t.Run("Reminders Disabled", func(t *testing.T) {
+		rule := EscrowReleaseRule{ConfirmationWindowHours: 24}
+		assert.Equal(t, 0, escrowRemindersDue(completedAt, after(20), rule))
+		assert.Equal(t, 0, escrowRemindersDue(completedAt, completedAt.Add(-time.Hour), defaultEscrowReleaseRule))
+	})
+}
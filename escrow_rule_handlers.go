@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ================================
+// Admin: Escrow Release Rules (ระยะเวลาให้ client ยืนยันก่อนปลดเงินอัตโนมัติ)
+// ================================
+
+type escrowReleaseRuleRequest struct {
+	Name                    string   `json:"name" binding:"required"`
+	ConfirmationWindowHours int      `json:"confirmation_window_hours" binding:"required,min=1,max=720"`
+	ReminderIntervalHours   int      `json:"reminder_interval_hours" binding:"min=0"`
+	MinAmount               float64  `json:"min_amount" binding:"min=0"`
+	MaxAmount               *float64 `json:"max_amount"`
+	Priority                int      `json:"priority"`
+	IsActive                *bool    `json:"is_active"`
+}
+
+// GET /admin/escrow/release-rules
+func adminGetEscrowReleaseRulesHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		rows, err := dbPool.Query(ctx, `
+			SELECT rule_id, name, confirmation_window_hours, reminder_interval_hours, min_amount, max_amount, priority, is_active
+			FROM escrow_release_rules
+			ORDER BY priority, rule_id
+		`)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch release rules"})
+			return
+		}
+		defer rows.Close()
+
+		rules := make([]EscrowReleaseRule, 0)
+		for rows.Next() {
+			var r EscrowReleaseRule
+			if err := rows.Scan(&r.RuleID, &r.Name, &r.ConfirmationWindowHours, &r.ReminderIntervalHours,
+				&r.MinAmount, &r.MaxAmount, &r.Priority, &r.IsActive); err != nil {
+				continue
+			}
+			rules = append(rules, r)
+		}
+
+		c.JSON(http.StatusOK, gin.H{"rules": rules, "fallback": defaultEscrowReleaseRule})
+	}
+}
+
+// POST /admin/escrow/release-rules
+func adminCreateEscrowReleaseRuleHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req escrowReleaseRuleRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if req.MaxAmount != nil && *req.MaxAmount <= req.MinAmount {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "max_amount must be greater than min_amount"})
+			return
+		}
+		isActive := req.IsActive == nil || *req.IsActive
+
+		var ruleID int
+		err := dbPool.QueryRow(ctx, `
+			INSERT INTO escrow_release_rules (
+				name, confirmation_window_hours, reminder_interval_hours, min_amount, max_amount, priority, is_active
+			) VALUES ($1, $2, $3, $4, $5, $6, $7)
+			RETURNING rule_id
+		`, req.Name, req.ConfirmationWindowHours, req.ReminderIntervalHours, req.MinAmount, req.MaxAmount,
+			req.Priority, isActive).Scan(&ruleID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create release rule"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"message": "Release rule created", "rule_id": ruleID})
+	}
+}
+
+// PUT /admin/escrow/release-rules/:rule_id (มีผลกับงานที่เสร็จหลังจากนี้เท่านั้น)
+func adminUpdateEscrowReleaseRuleHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req escrowReleaseRuleRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if req.MaxAmount != nil && *req.MaxAmount <= req.MinAmount {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "max_amount must be greater than min_amount"})
+			return
+		}
+
+		result, err := dbPool.Exec(ctx, `
+			UPDATE escrow_release_rules
+			SET name = $1, confirmation_window_hours = $2, reminder_interval_hours = $3,
+			    min_amount = $4, max_amount = $5, priority = $6,
+			    is_active = COALESCE($7, is_active), updated_at = NOW()
+			WHERE rule_id = $8
+		`, req.Name, req.ConfirmationWindowHours, req.ReminderIntervalHours, req.MinAmount, req.MaxAmount,
+			req.Priority, req.IsActive, c.Param("rule_id"))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update release rule"})
+			return
+		}
+		if result.RowsAffected() == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Release rule not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Release rule updated"})
+	}
+}
@@ -127,6 +127,8 @@ func adminProcessWithdrawalHandler(dbPool *pgxpool.Pool, ctx context.Context) gi
 		}
 
 		now := time.Now()
+		withdrawalIDInt, _ := strconv.Atoi(withdrawalID)
+		var withholdingTax float64
 
 		switch req.Action {
 		case "approve":
@@ -160,6 +162,12 @@ func adminProcessWithdrawalHandler(dbPool *pgxpool.Pool, ctx context.Context) gi
 				return
 			}
 
+			// Fix the withholding tax now so the admin transfers the right amount
+			if withholdingTax, err = assessWithdrawalWithholding(ctx, tx, withdrawalIDInt, now); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to assess withholding tax", "details": err.Error()})
+				return
+			}
+
 		case "reject":
 			if currentStatus != "pending" {
 				c.JSON(http.StatusBadRequest, gin.H{"error": "Can only reject pending withdrawals"})
@@ -247,6 +255,12 @@ func adminProcessWithdrawalHandler(dbPool *pgxpool.Pool, ctx context.Context) gi
 				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update transaction", "details": err.Error()})
 				return
 			}
+
+			// Record withholding tax as its own ledger line
+			if withholdingTax, err = settleWithdrawalWithholding(ctx, tx, withdrawalIDInt, now); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record withholding tax", "details": err.Error()})
+				return
+			}
 		}
 
 		// Commit transaction
@@ -256,9 +270,10 @@ func adminProcessWithdrawalHandler(dbPool *pgxpool.Pool, ctx context.Context) gi
 		}
 
 		c.JSON(http.StatusOK, gin.H{
-			"message":       "Withdrawal processed successfully",
-			"withdrawal_id": withdrawalID,
-			"action":        req.Action,
+			"message":         "Withdrawal processed successfully",
+			"withdrawal_id":   withdrawalID,
+			"action":          req.Action,
+			"withholding_tax": withholdingTax,
 		})
 	}
 }
@@ -349,7 +364,7 @@ func adminGetFinancialSummaryHandler(dbPool *pgxpool.Pool, ctx context.Context)
 
 func adminGenerateFinancialReportHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		adminID, _ := c.Get("userID")
+		adminID := c.GetInt("userID")
 
 		var req struct {
 			ReportType  string    `json:"report_type" binding:"required,oneof=daily weekly monthly yearly"`
@@ -361,63 +376,13 @@ func adminGenerateFinancialReportHandler(dbPool *pgxpool.Pool, ctx context.Conte
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
+		if !req.PeriodEnd.After(req.PeriodStart) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "period_end must be after period_start"})
+			return
+		}
 
-		// Calculate metrics
-		var (
-			totalBookings, totalSubscriptions                                      int
-			totalRevenue, totalCommission, totalProviderEarnings, totalWithdrawals float64
-		)
-
-		// Total bookings & revenue
-		dbPool.QueryRow(ctx, `
-			SELECT COUNT(*), COALESCE(SUM(amount), 0), COALESCE(SUM(commission_amount), 0)
-			FROM transactions
-			WHERE type = 'booking_payment'
-			  AND status = 'completed'
-			  AND created_at BETWEEN $1 AND $2
-		`, req.PeriodStart, req.PeriodEnd).Scan(&totalBookings, &totalRevenue, &totalCommission)
-
-		// Provider earnings
-		dbPool.QueryRow(ctx, `
-			SELECT COALESCE(SUM(net_amount), 0)
-			FROM transactions
-			WHERE type = 'provider_earning'
-			  AND status = 'completed'
-			  AND created_at BETWEEN $1 AND $2
-		`, req.PeriodStart, req.PeriodEnd).Scan(&totalProviderEarnings)
-
-		// Withdrawals
-		dbPool.QueryRow(ctx, `
-			SELECT COALESCE(SUM(net_amount), 0)
-			FROM withdrawals
-			WHERE status = 'completed'
-			  AND completed_at BETWEEN $1 AND $2
-		`, req.PeriodStart, req.PeriodEnd).Scan(&totalWithdrawals)
-
-		// Subscription fees
-		dbPool.QueryRow(ctx, `
-			SELECT COUNT(*)
-			FROM transactions
-			WHERE type = 'subscription_fee'
-			  AND status = 'completed'
-			  AND created_at BETWEEN $1 AND $2
-		`, req.PeriodStart, req.PeriodEnd).Scan(&totalSubscriptions)
-
-		// Insert report
-		var reportID int
-		err := dbPool.QueryRow(ctx, `
-			INSERT INTO financial_reports (
-				report_type, period_start, period_end,
-				total_bookings, total_revenue, total_commission,
-				total_provider_earnings, total_withdrawals, total_subscriptions,
-				generated_by
-			) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
-			RETURNING report_id
-		`, req.ReportType, req.PeriodStart, req.PeriodEnd,
-			totalBookings, totalRevenue, totalCommission,
-			totalProviderEarnings, totalWithdrawals, totalSubscriptions,
-			adminID).Scan(&reportID)
-
+		// Calculate metrics (breakdown by category/tier + previous period, see financial_reports.go)
+		reportID, data, err := generateFinancialReport(ctx, dbPool, req.ReportType, req.PeriodStart, req.PeriodEnd, &adminID, false)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate report"})
 			return
@@ -427,13 +392,16 @@ func adminGenerateFinancialReportHandler(dbPool *pgxpool.Pool, ctx context.Conte
 			"message":   "Financial report generated successfully",
 			"report_id": reportID,
 			"summary": gin.H{
-				"total_bookings":          totalBookings,
-				"total_revenue":           totalRevenue,
-				"total_commission":        totalCommission,
-				"total_provider_earnings": totalProviderEarnings,
-				"total_withdrawals":       totalWithdrawals,
-				"total_subscriptions":     totalSubscriptions,
+				"total_bookings":          data.Totals.Bookings,
+				"total_revenue":           data.Totals.Revenue,
+				"total_commission":        data.Totals.Commission,
+				"total_provider_earnings": data.ProviderEarnings,
+				"total_withdrawals":       data.Totals.Withdrawals,
+				"total_subscriptions":     data.Subscriptions,
+				"total_refunds":           data.Totals.Refunds,
+				"outstanding_escrow":      data.Totals.OutstandingEscrow,
 			},
+			"report": data,
 		})
 	}
 }
@@ -446,7 +414,7 @@ func adminGetCommissionRulesHandler(dbPool *pgxpool.Pool, ctx context.Context) g
 	return func(c *gin.Context) {
 		rows, err := dbPool.Query(ctx, `
 			SELECT rule_id, name, description, platform_rate, payment_gateway_rate,
-			       tier_id, effective_from, effective_until, is_active,
+			       tier_id, COALESCE(applies_to, 'booking'), effective_from, effective_until, is_active,
 			       created_at, updated_at
 			FROM commission_rules
 			ORDER BY effective_from DESC
@@ -462,7 +430,7 @@ func adminGetCommissionRulesHandler(dbPool *pgxpool.Pool, ctx context.Context) g
 		for rows.Next() {
 			var rule CommissionRule
 			rows.Scan(&rule.RuleID, &rule.Name, &rule.Description, &rule.PlatformRate,
-				&rule.PaymentGatewayRate, &rule.TierID, &rule.EffectiveFrom,
+				&rule.PaymentGatewayRate, &rule.TierID, &rule.AppliesTo, &rule.EffectiveFrom,
 				&rule.EffectiveUntil, &rule.IsActive, &rule.CreatedAt, &rule.UpdatedAt)
 			rules = append(rules, rule)
 		}
@@ -525,13 +493,13 @@ func adminGetUserWalletHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.Ha
 
 		var wallet Wallet
 		err = dbPool.QueryRow(ctx, `
-			SELECT wallet_id, user_id, available_balance, pending_balance,
+			SELECT wallet_id, user_id, available_balance, pending_balance, credit_balance, debt_balance,
 			       total_earned, total_withdrawn, updated_at
 			FROM wallets
 			WHERE user_id = $1
 		`, userID).Scan(
 			&wallet.WalletID, &wallet.UserID, &wallet.AvailableBalance,
-			&wallet.PendingBalance, &wallet.TotalEarned, &wallet.TotalWithdrawn,
+			&wallet.PendingBalance, &wallet.CreditBalance, &wallet.DebtBalance, &wallet.TotalEarned, &wallet.TotalWithdrawn,
 			&wallet.LastUpdated,
 		)
 
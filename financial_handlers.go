@@ -157,11 +157,11 @@ func getMyWalletHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFu
 		err := dbPool.QueryRow(ctx, `
 			INSERT INTO wallets (user_id) VALUES ($1)
 			ON CONFLICT (user_id) DO UPDATE SET user_id = EXCLUDED.user_id
-			RETURNING wallet_id, user_id, available_balance, pending_balance,
+			RETURNING wallet_id, user_id, available_balance, pending_balance, credit_balance, debt_balance,
 			          total_earned, total_withdrawn, updated_at
 		`, userID).Scan(
 			&wallet.WalletID, &wallet.UserID, &wallet.AvailableBalance,
-			&wallet.PendingBalance, &wallet.TotalEarned, &wallet.TotalWithdrawn,
+			&wallet.PendingBalance, &wallet.CreditBalance, &wallet.DebtBalance, &wallet.TotalEarned, &wallet.TotalWithdrawn,
 			&wallet.LastUpdated,
 		)
 
@@ -245,10 +245,10 @@ func requestWithdrawalHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.Han
 			return
 		}
 
-		// Check wallet balance
+		// Check wallet balance (ถอนได้เฉพาะรายได้ใน available_balance หักยอดค้างคืน; credit_balance ถอนไม่ได้)
 		var availableBalance float64
 		err := dbPool.QueryRow(ctx, `
-			SELECT available_balance FROM wallets WHERE user_id = $1
+			SELECT available_balance - debt_balance FROM wallets WHERE user_id = $1
 		`, userID).Scan(&availableBalance)
 
 		if err != nil {
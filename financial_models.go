@@ -34,11 +34,25 @@ type Wallet struct {
 	UserID           int       `json:"user_id"`
 	AvailableBalance float64   `json:"available_balance"`
 	PendingBalance   float64   `json:"pending_balance"`
+	CreditBalance    float64   `json:"credit_balance"` // เงินเติม/เงินคืน ใช้จ่ายได้แต่ถอนไม่ได้
+	DebtBalance      float64   `json:"debt_balance"`   // ยอดค้างคืนแพลตฟอร์ม หักจากยอดถอนได้
 	TotalEarned      float64   `json:"total_earned"`
 	TotalWithdrawn   float64   `json:"total_withdrawn"`
 	LastUpdated      time.Time `json:"last_updated"`
 }
 
+type WalletTopup struct {
+	TopupID          int        `json:"topup_id"`
+	UserID           int        `json:"user_id"`
+	Amount           float64    `json:"amount"`
+	Method           string     `json:"method"` // stripe, promptpay
+	Status           string     `json:"status"` // pending, completed, expired, failed
+	PaymentReference *string    `json:"payment_reference,omitempty"`
+	StripeSessionID  *string    `json:"stripe_session_id,omitempty"`
+	CompletedAt      *time.Time `json:"completed_at"`
+	CreatedAt        time.Time  `json:"created_at"`
+}
+
 // ================================
 // Transaction Models
 // ================================
@@ -55,6 +69,12 @@ const (
 	TxTypeAdjustment      TransactionType = "admin_adjustment"
 	TxTypeBonus           TransactionType = "bonus"
 	TxTypePenalty         TransactionType = "penalty"
+	TxTypeWalletTopup     TransactionType = "wallet_topup"
+	TxTypeWalletPayment   TransactionType = "wallet_payment"
+	TxTypeWalletRefund    TransactionType = "wallet_refund"
+	TxTypeTip             TransactionType = "tip"
+	TxTypeCampaignSubsidy TransactionType = "campaign_subsidy"
+	TxTypeReferralReward  TransactionType = "referral_reward"
 )
 
 type TransactionStatus string
@@ -145,6 +165,7 @@ type CommissionRule struct {
 	PlatformRate       float64    `json:"platform_rate"`        // 0.1000 = 10%
 	PaymentGatewayRate float64    `json:"payment_gateway_rate"` // 0.0275 = 2.75%
 	TierID             *int       `json:"tier_id"`
+	AppliesTo          string     `json:"applies_to"` // booking, tip
 	EffectiveFrom      time.Time  `json:"effective_from"`
 	EffectiveUntil     *time.Time `json:"effective_until"`
 	IsActive           bool       `json:"is_active"`
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ================================
+// Admin: Financial Report History, Export & Schedules
+// ================================
+
+type financialReportRow struct {
+	ReportID              int       `json:"report_id"`
+	ReportType            string    `json:"report_type"`
+	PeriodStart           time.Time `json:"period_start"`
+	PeriodEnd             time.Time `json:"period_end"`
+	TotalBookings         int       `json:"total_bookings"`
+	TotalRevenue          float64   `json:"total_revenue"`
+	TotalCommission       float64   `json:"total_commission"`
+	TotalProviderEarnings float64   `json:"total_provider_earnings"`
+	TotalWithdrawals      float64   `json:"total_withdrawals"`
+	TotalSubscriptions    float64   `json:"total_subscriptions"`
+	TotalRefunds          float64   `json:"total_refunds"`
+	OutstandingEscrow     float64   `json:"outstanding_escrow"`
+	IsScheduled           bool      `json:"is_scheduled"`
+	GeneratedBy           *int      `json:"generated_by"`
+	GeneratedAt           time.Time `json:"generated_at"`
+}
+
+const financialReportColumns = `
+	report_id, report_type, period_start, period_end, COALESCE(total_bookings, 0),
+	COALESCE(total_revenue, 0), COALESCE(total_commission, 0), COALESCE(total_provider_earnings, 0),
+	COALESCE(total_withdrawals, 0), COALESCE(total_subscriptions, 0), COALESCE(total_refunds, 0),
+	COALESCE(outstanding_escrow, 0), is_scheduled, generated_by, generated_at
+`
+
+func scanFinancialReportRow(scan func(dest ...interface{}) error, extra ...interface{}) (*financialReportRow, error) {
+	var r financialReportRow
+	dest := []interface{}{&r.ReportID, &r.ReportType, &r.PeriodStart, &r.PeriodEnd, &r.TotalBookings,
+		&r.TotalRevenue, &r.TotalCommission, &r.TotalProviderEarnings,
+		&r.TotalWithdrawals, &r.TotalSubscriptions, &r.TotalRefunds,
+		&r.OutstandingEscrow, &r.IsScheduled, &r.GeneratedBy, &r.GeneratedAt}
+	if err := scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// GET /admin/financial/reports?type=monthly&limit=50
+func adminGetFinancialReportsHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+		if limit <= 0 || limit > 500 {
+			limit = 50
+		}
+
+		rows, err := dbPool.Query(ctx, `
+			SELECT `+financialReportColumns+`
+			FROM financial_reports
+			WHERE ($1 = '' OR report_type = $1)
+			ORDER BY period_start DESC, report_id DESC
+			LIMIT $2
+		`, c.Query("type"), limit)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch reports"})
+			return
+		}
+		defer rows.Close()
+
+		reports := make([]financialReportRow, 0)
+		for rows.Next() {
+			r, err := scanFinancialReportRow(rows.Scan)
+			if err != nil {
+				continue
+			}
+			reports = append(reports, *r)
+		}
+
+		c.JSON(http.StatusOK, gin.H{"reports": reports, "total": len(reports)})
+	}
+}
+
+// loadFinancialReport returns the stored report and its breakdown. Reports generated
+// before breakdowns existed are recomputed from their period.
+func loadFinancialReport(ctx context.Context, dbPool *pgxpool.Pool, reportID string) (*financialReportRow, *FinancialReportData, error) {
+	var breakdown []byte
+	row := dbPool.QueryRow(ctx, `
+		SELECT `+financialReportColumns+`, breakdown
+		FROM financial_reports WHERE report_id = $1
+	`, reportID)
+	report, err := scanFinancialReportRow(row.Scan, &breakdown)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var data FinancialReportData
+	if len(breakdown) > 0 && json.Unmarshal(breakdown, &data) == nil && data.Previous != nil {
+		return report, &data, nil
+	}
+
+	start := time.Date(report.PeriodStart.Year(), report.PeriodStart.Month(), report.PeriodStart.Day(), 0, 0, 0, 0, bangkokLocation)
+	end := time.Date(report.PeriodEnd.Year(), report.PeriodEnd.Month(), report.PeriodEnd.Day(), 0, 0, 0, 0, bangkokLocation).AddDate(0, 0, 1)
+	computed, err := buildFinancialReportData(ctx, dbPool, report.ReportType, start, end)
+	if err != nil {
+		return nil, nil, err
+	}
+	return report, computed, nil
+}
+
+// GET /admin/financial/reports/:report_id
+func adminGetFinancialReportHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		report, data, err := loadFinancialReport(ctx, dbPool, c.Param("report_id"))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Report not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"report": report, "breakdown": data})
+	}
+}
+
+// GET /admin/financial/reports/:report_id/export?format=csv|xlsx
+func adminExportFinancialReportHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		format := c.DefaultQuery("format", "csv")
+		if format != "csv" && format != "xlsx" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "format must be csv or xlsx"})
+			return
+		}
+
+		report, data, err := loadFinancialReport(ctx, dbPool, c.Param("report_id"))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Report not found"})
+			return
+		}
+
+		sheets := financialReportSheets(data)
+		filename := fmt.Sprintf("financial_report_%s_%s", report.ReportType, report.PeriodStart.Format("2006-01-02"))
+
+		if format == "xlsx" {
+			content, err := writeXLSX(sheets)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build XLSX"})
+				return
+			}
+			contentType := "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+			c.Header("Content-Type", contentType)
+			c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.xlsx"`, filename))
+			c.Data(http.StatusOK, contentType, content)
+			return
+		}
+
+		var buf bytes.Buffer
+		w := csv.NewWriter(&buf)
+		for i, sheet := range sheets {
+			if i > 0 {
+				w.Write([]string{})
+			}
+			w.Write([]string{"# " + sheet.Name})
+			for _, row := range sheet.Rows {
+				w.Write(csvCells(row))
+			}
+		}
+		w.Flush()
+
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.csv"`, filename))
+		c.Data(http.StatusOK, "text/csv; charset=utf-8", append([]byte("\xef\xbb\xbf"), buf.Bytes()...))
+	}
+}
+
+// financialReportSheets lays a report out as Summary / By Category / By Tier tables
+func financialReportSheets(data *FinancialReportData) []xlsxSheet {
+	var previous ReportMetrics
+	change := map[string]*float64{}
+	if data.Previous != nil {
+		previous = data.Previous.Totals
+		change = data.Previous.ChangePercent
+	}
+	pct := func(key string) interface{} {
+		if v := change[key]; v != nil {
+			return *v
+		}
+		return nil
+	}
+
+	summary := xlsxSheet{Name: "Summary", Rows: [][]interface{}{
+		{"Report type", data.ReportType},
+		{"Period", fmt.Sprintf("%s to %s", data.PeriodStart.In(bangkokLocation).Format("2006-01-02"),
+			data.PeriodEnd.Add(-time.Nanosecond).In(bangkokLocation).Format("2006-01-02"))},
+		{},
+		{"Metric", "Current", "Previous", "Change %"},
+		{"Bookings", data.Totals.Bookings, previous.Bookings, pct("bookings")},
+		{"Revenue", data.Totals.Revenue, previous.Revenue, pct("revenue")},
+		{"Commission", data.Totals.Commission, previous.Commission, pct("commission")},
+		{"Refunds", data.Totals.Refunds, previous.Refunds, pct("refunds")},
+		{"Withdrawals", data.Totals.Withdrawals, previous.Withdrawals, pct("withdrawals")},
+		{"Outstanding escrow", data.Totals.OutstandingEscrow, previous.OutstandingEscrow, pct("outstanding_escrow")},
+		{"Provider earnings", data.ProviderEarnings},
+		{"Subscriptions", data.Subscriptions},
+	}}
+
+	breakdown := func(name, keyHeader string, rows []ReportBreakdownRow) xlsxSheet {
+		sheet := xlsxSheet{Name: name, Rows: [][]interface{}{
+			{keyHeader, "Bookings", "Revenue", "Commission", "Refunds", "Withdrawals", "Outstanding escrow"},
+		}}
+		for _, r := range rows {
+			sheet.Rows = append(sheet.Rows, []interface{}{
+				r.Key, r.Bookings, r.Revenue, r.Commission, r.Refunds, r.Withdrawals, r.OutstandingEscrow,
+			})
+		}
+		return sheet
+	}
+
+	return []xlsxSheet{
+		summary,
+		breakdown("By Category", "Category", data.ByCategory),
+		breakdown("By Tier", "Provider tier", data.ByTier),
+	}
+}
+
+func csvCells(row []interface{}) []string {
+	cells := make([]string, len(row))
+	for i, v := range row {
+		switch v := v.(type) {
+		case nil:
+			cells[i] = ""
+		case float64:
+			cells[i] = strconv.FormatFloat(v, 'f', 2, 64)
+		default:
+			cells[i] = fmt.Sprint(v)
+		}
+	}
+	return cells
+}
+
+// GET /admin/financial/report-definitions
+func adminGetReportDefinitionsHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		rows, err := dbPool.Query(ctx, `
+			SELECT report_type, is_enabled, last_generated_at, last_period_start
+			FROM financial_report_definitions
+			ORDER BY CASE report_type WHEN 'daily' THEN 1 WHEN 'weekly' THEN 2 ELSE 3 END
+		`)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch report definitions"})
+			return
+		}
+		defer rows.Close()
+
+		definitions := make([]gin.H, 0)
+		for rows.Next() {
+			var reportType string
+			var isEnabled bool
+			var lastGeneratedAt, lastPeriodStart *time.Time
+			if err := rows.Scan(&reportType, &isEnabled, &lastGeneratedAt, &lastPeriodStart); err != nil {
+				continue
+			}
+			nextStart, nextEnd := lastCompletedReportPeriod(reportType, time.Now().Add(-scheduledReportDelay))
+			definitions = append(definitions, gin.H{
+				"report_type":       reportType,
+				"is_enabled":        isEnabled,
+				"last_generated_at": lastGeneratedAt,
+				"last_period_start": lastPeriodStart,
+				"due_period_start":  nextStart,
+				"due_period_end":    nextEnd,
+			})
+		}
+
+		c.JSON(http.StatusOK, gin.H{"definitions": definitions})
+	}
+}
+
+// PUT /admin/financial/report-definitions/:report_type
+func adminUpdateReportDefinitionHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			IsEnabled *bool `json:"is_enabled" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		result, err := dbPool.Exec(ctx, `
+			UPDATE financial_report_definitions SET is_enabled = $1, updated_at = NOW()
+			WHERE report_type = $2
+		`, *req.IsEnabled, c.Param("report_type"))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update report definition"})
+			return
+		}
+		if result.RowsAffected() == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Report definition not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Report definition updated", "report_type": c.Param("report_type"), "is_enabled": *req.IsEnabled})
+	}
+}
@@ -0,0 +1,384 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ================================
+// Financial Reports (รายงานการเงินรายวัน/สัปดาห์/เดือน)
+// ================================
+
+// Report types (financial_reports.report_type)
+const (
+	ReportDaily   = "daily"
+	ReportWeekly  = "weekly"
+	ReportMonthly = "monthly"
+	ReportYearly  = "yearly"
+)
+
+// scheduledReportDelay gives late webhooks/payouts time to land before a period is reported
+const scheduledReportDelay = time.Hour
+
+// ReportMetrics are the money figures a report is broken down by
+type ReportMetrics struct {
+	Bookings          int     `json:"bookings"`
+	Revenue           float64 `json:"revenue"`
+	Commission        float64 `json:"commission"`
+	Refunds           float64 `json:"refunds"`
+	Withdrawals       float64 `json:"withdrawals"`
+	OutstandingEscrow float64 `json:"outstanding_escrow"` // เงินที่ยังล็อกอยู่ใน escrow ณ สิ้นงวด
+}
+
+func (m *ReportMetrics) add(o ReportMetrics) {
+	m.Bookings += o.Bookings
+	m.Revenue += o.Revenue
+	m.Commission += o.Commission
+	m.Refunds += o.Refunds
+	m.Withdrawals += o.Withdrawals
+	m.OutstandingEscrow += o.OutstandingEscrow
+}
+
+func (m ReportMetrics) rounded() ReportMetrics {
+	round := func(v float64) float64 { return math.Round(v*100) / 100 }
+	return ReportMetrics{
+		Bookings:          m.Bookings,
+		Revenue:           round(m.Revenue),
+		Commission:        round(m.Commission),
+		Refunds:           round(m.Refunds),
+		Withdrawals:       round(m.Withdrawals),
+		OutstandingEscrow: round(m.OutstandingEscrow),
+	}
+}
+
+// ReportBreakdownRow is one category or provider tier
+type ReportBreakdownRow struct {
+	Key string `json:"key"`
+	ReportMetrics
+}
+
+// ReportComparison is the previous period and the % change per metric (nil when previous was 0)
+type ReportComparison struct {
+	PeriodStart   time.Time           `json:"period_start"`
+	PeriodEnd     time.Time           `json:"period_end"`
+	Totals        ReportMetrics       `json:"totals"`
+	ChangePercent map[string]*float64 `json:"change_percent"`
+}
+
+// FinancialReportData is stored in financial_reports.breakdown
+type FinancialReportData struct {
+	ReportType       string               `json:"report_type"`
+	PeriodStart      time.Time            `json:"period_start"`
+	PeriodEnd        time.Time            `json:"period_end"` // exclusive
+	Totals           ReportMetrics        `json:"totals"`
+	ProviderEarnings float64              `json:"provider_earnings"`
+	Subscriptions    int                  `json:"subscriptions"`
+	ByCategory       []ReportBreakdownRow `json:"by_category"`
+	ByTier           []ReportBreakdownRow `json:"by_tier"`
+	Previous         *ReportComparison    `json:"previous"`
+}
+
+// reportFact is one (category, tier) group loaded from the ledger
+type reportFact struct {
+	Category string
+	Tier     string
+	ReportMetrics
+}
+
+// --- Periods (Bangkok time) ---
+
+// reportPeriodContaining returns the [start, end) period of the given type that contains t
+func reportPeriodContaining(reportType string, t time.Time) (time.Time, time.Time) {
+	local := t.In(bangkokLocation)
+	day := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, bangkokLocation)
+
+	switch reportType {
+	case ReportWeekly:
+		// สัปดาห์เริ่มวันจันทร์
+		offset := (int(day.Weekday()) + 6) % 7
+		start := day.AddDate(0, 0, -offset)
+		return start, start.AddDate(0, 0, 7)
+	case ReportMonthly:
+		start := time.Date(local.Year(), local.Month(), 1, 0, 0, 0, 0, bangkokLocation)
+		return start, start.AddDate(0, 1, 0)
+	case ReportYearly:
+		start := time.Date(local.Year(), time.January, 1, 0, 0, 0, 0, bangkokLocation)
+		return start, start.AddDate(1, 0, 0)
+	default:
+		return day, day.AddDate(0, 0, 1)
+	}
+}
+
+// lastCompletedReportPeriod is the most recent period of the type that has fully ended
+func lastCompletedReportPeriod(reportType string, now time.Time) (time.Time, time.Time) {
+	currentStart, _ := reportPeriodContaining(reportType, now)
+	return reportPeriodContaining(reportType, currentStart.Add(-time.Nanosecond))
+}
+
+// previousReportPeriod is the period to compare against: the previous calendar
+// period when [start, end) is one, otherwise a window of the same length just before it
+func previousReportPeriod(reportType string, start, end time.Time) (time.Time, time.Time) {
+	if s, e := reportPeriodContaining(reportType, start); s.Equal(start) && e.Equal(end) {
+		return reportPeriodContaining(reportType, start.Add(-time.Nanosecond))
+	}
+	return start.Add(-end.Sub(start)), start
+}
+
+// reportDate converts a period boundary to the DATE stored in financial_reports
+func reportDate(t time.Time) time.Time {
+	local := t.In(bangkokLocation)
+	return time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// --- Aggregation ---
+
+// summarizeReportFacts rolls (category, tier) groups up into totals and both breakdowns
+func summarizeReportFacts(facts []reportFact) (ReportMetrics, []ReportBreakdownRow, []ReportBreakdownRow) {
+	var totals ReportMetrics
+	byCategory := map[string]*ReportMetrics{}
+	byTier := map[string]*ReportMetrics{}
+
+	for _, f := range facts {
+		totals.add(f.ReportMetrics)
+		if byCategory[f.Category] == nil {
+			byCategory[f.Category] = &ReportMetrics{}
+		}
+		byCategory[f.Category].add(f.ReportMetrics)
+		if byTier[f.Tier] == nil {
+			byTier[f.Tier] = &ReportMetrics{}
+		}
+		byTier[f.Tier].add(f.ReportMetrics)
+	}
+
+	return totals.rounded(), breakdownRows(byCategory), breakdownRows(byTier)
+}
+
+func breakdownRows(groups map[string]*ReportMetrics) []ReportBreakdownRow {
+	rows := make([]ReportBreakdownRow, 0, len(groups))
+	for key, m := range groups {
+		rows = append(rows, ReportBreakdownRow{Key: key, ReportMetrics: m.rounded()})
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].Revenue != rows[j].Revenue {
+			return rows[i].Revenue > rows[j].Revenue
+		}
+		return rows[i].Key < rows[j].Key
+	})
+	return rows
+}
+
+// reportChangePercent compares each metric with the previous period
+func reportChangePercent(current, previous ReportMetrics) map[string]*float64 {
+	pct := func(cur, prev float64) *float64 {
+		if prev == 0 {
+			return nil
+		}
+		v := math.Round((cur-prev)/math.Abs(prev)*10000) / 100
+		return &v
+	}
+	return map[string]*float64{
+		"bookings":           pct(float64(current.Bookings), float64(previous.Bookings)),
+		"revenue":            pct(current.Revenue, previous.Revenue),
+		"commission":         pct(current.Commission, previous.Commission),
+		"refunds":            pct(current.Refunds, previous.Refunds),
+		"withdrawals":        pct(current.Withdrawals, previous.Withdrawals),
+		"outstanding_escrow": pct(current.OutstandingEscrow, previous.OutstandingEscrow),
+	}
+}
+
+// loadReportFacts groups the ledger for [start, end) by the provider's primary category and tier
+func loadReportFacts(ctx context.Context, dbPool *pgxpool.Pool, start, end time.Time) ([]reportFact, error) {
+	rows, err := dbPool.Query(ctx, `
+		WITH facts AS (
+			SELECT COALESCE(b.provider_id, t.user_id) AS provider_id,
+			       CASE WHEN t.type::text = 'booking_payment' THEN 1 ELSE 0 END AS bookings,
+			       CASE WHEN t.type::text IN ('booking_payment', 'booking_extension') THEN t.amount ELSE 0 END AS revenue,
+			       CASE WHEN t.type::text IN ('booking_payment', 'booking_extension')
+			            THEN COALESCE(t.platform_commission, t.commission_amount, 0) ELSE 0 END AS commission,
+			       CASE WHEN t.type::text IN ('booking_refund', 'refund') THEN t.amount ELSE 0 END AS refunds,
+			       0::numeric AS withdrawals,
+			       0::numeric AS escrow
+			FROM transactions t
+			LEFT JOIN bookings b ON b.booking_id = t.booking_id
+			WHERE t.status::text = 'completed'
+			  AND t.type::text IN ('booking_payment', 'booking_extension', 'booking_refund', 'refund')
+			  AND t.created_at >= $1 AND t.created_at < $2
+
+			UNION ALL
+
+			SELECT w.user_id, 0, 0, 0, 0, w.net_amount, 0
+			FROM withdrawals w
+			WHERE w.status::text = 'completed'
+			  AND w.completed_at >= $1 AND w.completed_at < $2
+
+			UNION ALL
+
+			SELECT b.provider_id, 0, 0, 0, 0, 0, e.amount
+			FROM escrow_payments e
+			JOIN bookings b ON b.booking_id = e.booking_id
+			WHERE e.locked_at < $2
+			  AND (e.released_at IS NULL OR e.released_at >= $2)
+		)
+		SELECT COALESCE(cat.name, 'Uncategorized'), COALESCE(tr.name, 'Unknown'),
+		       SUM(f.bookings)::int, SUM(f.revenue), SUM(f.commission), SUM(f.refunds),
+		       SUM(f.withdrawals), SUM(f.escrow)
+		FROM facts f
+		LEFT JOIN users u ON u.user_id = f.provider_id
+		LEFT JOIN tiers tr ON tr.tier_id = u.provider_level_id
+		LEFT JOIN LATERAL (
+			SELECT sc.name FROM provider_categories pc
+			JOIN service_categories sc ON sc.category_id = pc.category_id
+			WHERE pc.provider_id = f.provider_id
+			ORDER BY sc.display_order, sc.category_id
+			LIMIT 1
+		) cat ON true
+		GROUP BY 1, 2
+	`, start, end)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	facts := make([]reportFact, 0)
+	for rows.Next() {
+		var f reportFact
+		if err := rows.Scan(&f.Category, &f.Tier, &f.Bookings, &f.Revenue, &f.Commission,
+			&f.Refunds, &f.Withdrawals, &f.OutstandingEscrow); err != nil {
+			return nil, err
+		}
+		facts = append(facts, f)
+	}
+	return facts, rows.Err()
+}
+
+// buildFinancialReportData computes a report and its comparison with the previous period
+func buildFinancialReportData(ctx context.Context, dbPool *pgxpool.Pool, reportType string, start, end time.Time) (*FinancialReportData, error) {
+	facts, err := loadReportFacts(ctx, dbPool, start, end)
+	if err != nil {
+		return nil, err
+	}
+	data := &FinancialReportData{ReportType: reportType, PeriodStart: start, PeriodEnd: end}
+	data.Totals, data.ByCategory, data.ByTier = summarizeReportFacts(facts)
+
+	prevStart, prevEnd := previousReportPeriod(reportType, start, end)
+	prevFacts, err := loadReportFacts(ctx, dbPool, prevStart, prevEnd)
+	if err != nil {
+		return nil, err
+	}
+	prevTotals, _, _ := summarizeReportFacts(prevFacts)
+	data.Previous = &ReportComparison{
+		PeriodStart:   prevStart,
+		PeriodEnd:     prevEnd,
+		Totals:        prevTotals,
+		ChangePercent: reportChangePercent(data.Totals, prevTotals),
+	}
+
+	// Provider earnings
+	dbPool.QueryRow(ctx, `
+		SELECT COALESCE(SUM(net_amount), 0)
+		FROM transactions
+		WHERE type = 'provider_earning'
+		  AND status = 'completed'
+		  AND created_at >= $1 AND created_at < $2
+	`, start, end).Scan(&data.ProviderEarnings)
+
+	// Subscription fees
+	dbPool.QueryRow(ctx, `
+		SELECT COUNT(*)
+		FROM transactions
+		WHERE type = 'subscription_fee'
+		  AND status = 'completed'
+		  AND created_at >= $1 AND created_at < $2
+	`, start, end).Scan(&data.Subscriptions)
+
+	return data, nil
+}
+
+// generateFinancialReport computes and stores a report. Scheduled reports are unique per
+// (report_type, period_start); reportID is 0 when that period already has one.
+func generateFinancialReport(ctx context.Context, dbPool *pgxpool.Pool, reportType string, start, end time.Time, generatedBy *int, scheduled bool) (int, *FinancialReportData, error) {
+	data, err := buildFinancialReportData(ctx, dbPool, reportType, start, end)
+	if err != nil {
+		return 0, nil, err
+	}
+	breakdown, err := json.Marshal(data)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	var reportID int
+	err = dbPool.QueryRow(ctx, `
+		INSERT INTO financial_reports (
+			report_type, period_start, period_end,
+			total_bookings, total_revenue, total_commission,
+			total_provider_earnings, total_withdrawals, total_subscriptions,
+			total_refunds, outstanding_escrow, breakdown, is_scheduled, generated_by
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+		ON CONFLICT (report_type, period_start) WHERE is_scheduled DO NOTHING
+		RETURNING report_id
+	`, reportType, reportDate(start), reportDate(end.Add(-time.Nanosecond)),
+		data.Totals.Bookings, data.Totals.Revenue, data.Totals.Commission,
+		data.ProviderEarnings, data.Totals.Withdrawals, data.Subscriptions,
+		data.Totals.Refunds, data.Totals.OutstandingEscrow, breakdown, scheduled, generatedBy).Scan(&reportID)
+	if err != nil && !(scheduled && errors.Is(err, pgx.ErrNoRows)) {
+		return 0, nil, err
+	}
+	return reportID, data, nil
+}
+
+// runScheduledFinancialReports generates the last completed period of every enabled definition
+func runScheduledFinancialReports(ctx context.Context, dbPool *pgxpool.Pool) error {
+	rows, err := dbPool.Query(ctx, `SELECT report_type FROM financial_report_definitions WHERE is_enabled = true`)
+	if err != nil {
+		return err
+	}
+	var reportTypes []string
+	for rows.Next() {
+		var reportType string
+		if err := rows.Scan(&reportType); err == nil {
+			reportTypes = append(reportTypes, reportType)
+		}
+	}
+	rows.Close()
+
+	now := time.Now().Add(-scheduledReportDelay)
+	for _, reportType := range reportTypes {
+		start, end := lastCompletedReportPeriod(reportType, now)
+
+		var exists bool
+		dbPool.QueryRow(ctx, `
+			SELECT EXISTS(SELECT 1 FROM financial_reports WHERE is_scheduled AND report_type = $1 AND period_start = $2)
+		`, reportType, reportDate(start)).Scan(&exists)
+		if exists {
+			continue
+		}
+
+		reportID, _, err := generateFinancialReport(ctx, dbPool, reportType, start, end, nil, true)
+		if err != nil {
+			return fmt.Errorf("%s report for %s: %w", reportType, start.Format("2006-01-02"), err)
+		}
+		dbPool.Exec(ctx, `
+			UPDATE financial_report_definitions
+			SET last_generated_at = NOW(), last_period_start = $2, updated_at = NOW()
+			WHERE report_type = $1
+		`, reportType, reportDate(start))
+		log.Printf("📊 Generated %s financial report #%d for %s", reportType, reportID, start.Format("2006-01-02"))
+	}
+	return nil
+}
+
+// startFinancialReportScheduler checks hourly for report periods that are due
+func startFinancialReportScheduler(dbPool *pgxpool.Pool, ctx context.Context) {
+	runPeriodically(ctx, "financial-reports", time.Hour, func(ctx context.Context) error {
+		return runScheduledFinancialReports(ctx, dbPool)
+	})
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test report period boundaries (Bangkok time)
+func TestReportPeriodContaining(t *testing.T) {
+	// Wednesday 15 Oct 2025 20:00 UTC = Thursday 16 Oct 03:00 in Bangkok
+	at := time.Date(2025, 10, 15, 20, 0, 0, 0, time.UTC)
+
+	t.Run("Daily", func(t *testing.T) {
+		start, end := reportPeriodContaining(ReportDaily, at)
+		assert.Equal(t, time.Date(2025, 10, 16, 0, 0, 0, 0, bangkokLocation), start)
+		assert.Equal(t, time.Date(2025, 10, 17, 0, 0, 0, 0, bangkokLocation), end)
+	})
+
+	t.Run("Weekly Starts Monday", func(t *testing.T) {
+		start, end := reportPeriodContaining(ReportWeekly, at)
+		assert.Equal(t, time.Date(2025, 10, 13, 0, 0, 0, 0, bangkokLocation), start)
+		assert.Equal(t, time.Date(2025, 10, 20, 0, 0, 0, 0, bangkokLocation), end)
+	})
+
+	t.Run("Monthly", func(t *testing.T) {
+		start, end := reportPeriodContaining(ReportMonthly, at)
+		assert.Equal(t, time.Date(2025, 10, 1, 0, 0, 0, 0, bangkokLocation), start)
+		assert.Equal(t, time.Date(2025, 11, 1, 0, 0, 0, 0, bangkokLocation), end)
+	})
+
+	t.Run("Last Completed", func(t *testing.T) {
+		start, _ := lastCompletedReportPeriod(ReportMonthly, at)
+		assert.Equal(t, time.Date(2025, 9, 1, 0, 0, 0, 0, bangkokLocation), start)
+		start, _ = lastCompletedReportPeriod(ReportWeekly, at)
+		assert.Equal(t, time.Date(2025, 10, 6, 0, 0, 0, 0, bangkokLocation), start)
+	})
+}
+
+// Test comparison period selection
+func TestPreviousReportPeriod(t *testing.T) {
+	t.Run("Calendar Month", func(t *testing.T) {
+		start, end := previousReportPeriod(ReportMonthly,
+			time.Date(2025, 3, 1, 0, 0, 0, 0, bangkokLocation), time.Date(2025, 4, 1, 0, 0, 0, 0, bangkokLocation))
+		assert.Equal(t, time.Date(2025, 2, 1, 0, 0, 0, 0, bangkokLocation), start)
+		assert.Equal(t, time.Date(2025, 3, 1, 0, 0, 0, 0, bangkokLocation), end)
+	})
+
+	t.Run("Custom Window", func(t *testing.T) {
+		from := time.Date(2025, 3, 10, 0, 0, 0, 0, bangkokLocation)
+		start, end := previousReportPeriod(ReportMonthly, from, from.AddDate(0, 0, 5))
+		assert.Equal(t, from.AddDate(0, 0, -5), start)
+		assert.Equal(t, from, end)
+	})
+}
+
+// Test category/tier roll-up and period comparison
+func TestSummarizeReportFacts(t *testing.T) {
+	facts := []reportFact{
+		{Category: "Massage", Tier: "Gold", ReportMetrics: ReportMetrics{Bookings: 2, Revenue: 3000, Commission: 300}},
+		{Category: "Massage", Tier: "Silver", ReportMetrics: ReportMetrics{Bookings: 1, Revenue: 1000, Commission: 100, Refunds: 200}},
+		{Category: "Companion", Tier: "Gold", ReportMetrics: ReportMetrics{Withdrawals: 500.555, OutstandingEscrow: 750}},
+	}
+
+	totals, byCategory, byTier := summarizeReportFacts(facts)
+
+	assert.Equal(t, ReportMetrics{Bookings: 3, Revenue: 4000, Commission: 400, Refunds: 200, Withdrawals: 500.56, OutstandingEscrow: 750}, totals)
+	assert.Len(t, byCategory, 2)
+	assert.Equal(t, "Massage", byCategory[0].Key)
+	assert.Equal(t, 4000.0, byCategory[0].Revenue)
+	assert.Equal(t, "Gold", byTier[0].Key)
+	assert.Equal(t, 750.0, byTier[0].OutstandingEscrow)
+
+	t.Run("Change Percent", func(t *testing.T) {
+		change := reportChangePercent(totals, ReportMetrics{Bookings: 4, Revenue: 3200})
+		assert.Equal(t, -25.0, *change["bookings"])
+		assert.Equal(t, 25.0, *change["revenue"])
+		assert.Nil(t, change["refunds"])
+	})
+}
+
+// Test report export layout and the XLSX container
+func TestFinancialReportXLSX(t *testing.T) {
+	ten := 10.0
+	data := &FinancialReportData{
+		ReportType:  ReportDaily,
+		PeriodStart: time.Date(2025, 10, 16, 0, 0, 0, 0, bangkokLocation),
+		PeriodEnd:   time.Date(2025, 10, 17, 0, 0, 0, 0, bangkokLocation),
+		Totals:      ReportMetrics{Bookings: 1, Revenue: 1100},
+		ByCategory:  []ReportBreakdownRow{{Key: "Spa & <Wellness>", ReportMetrics: ReportMetrics{Revenue: 1100}}},
+		Previous:    &ReportComparison{Totals: ReportMetrics{Revenue: 1000}, ChangePercent: map[string]*float64{"revenue": &ten}},
+	}
+
+	sheets := financialReportSheets(data)
+	assert.Len(t, sheets, 3)
+	assert.Equal(t, []interface{}{"Period", "2025-10-16 to 2025-10-16"}, sheets[0].Rows[1])
+	assert.Equal(t, []interface{}{"Revenue", 1100.0, 1000.0, 10.0}, sheets[0].Rows[5])
+	assert.Equal(t, []string{"Bookings", "1", "0", ""}, csvCells(sheets[0].Rows[4]))
+
+	content, err := writeXLSX(sheets)
+	assert.NoError(t, err)
+
+	zr, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	assert.NoError(t, err)
+	files := map[string]string{}
+	for _, f := range zr.File {
+		rc, _ := f.Open()
+		b, _ := io.ReadAll(rc)
+		rc.Close()
+		files[f.Name] = string(b)
+	}
+	assert.Contains(t, files, "[Content_Types].xml")
+	assert.Contains(t, files["xl/workbook.xml"], `<sheet name="By Category" sheetId="2" r:id="rId2"/>`)
+	assert.Contains(t, files["xl/worksheets/sheet2.xml"], "Spa &amp; &lt;Wellness&gt;")
+	assert.Contains(t, files["xl/worksheets/sheet1.xml"], `<c r="B6"><v>1100</v></c>`)
+}
+
+// Test spreadsheet column naming
+func TestXLSXColumnName(t *testing.T) {
+	assert.Equal(t, "A", xlsxColumnName(0))
+	assert.Equal(t, "Z", xlsxColumnName(25))
+	assert.Equal(t, "AA", xlsxColumnName(26))
+	assert.Equal(t, "AZ", xlsxColumnName(51))
+}
@@ -2,9 +2,12 @@ package main
 
 import (
 	"context"
+	"encoding/base64"
 	"fmt"
 	"log"
 	"os"
+	"strings"
+	"time"
 
 	"cloud.google.com/go/storage"
 )
@@ -67,6 +70,43 @@ func getGCSBucketName() string {
 	return gcsBucketName
 }
 
+// uploadFileToStorage stores a file under objectKey and returns the value to save in the DB.
+// With GCS enabled this is the object key (use storage.SignedURL to view it);
+// in development mode without GCS the file is returned as a data URL.
+func uploadFileToStorage(ctx context.Context, objectKey string, data []byte, contentType string) (string, error) {
+	if !isGCSEnabled() {
+		return "data:" + contentType + ";base64," + base64.StdEncoding.EncodeToString(data), nil
+	}
+
+	wc := gcsClient.Bucket(gcsBucketName).Object(objectKey).NewWriter(ctx)
+	wc.ContentType = contentType
+	if _, err := wc.Write(data); err != nil {
+		wc.Close()
+		return "", fmt.Errorf("failed to upload %s: %w", objectKey, err)
+	}
+	if err := wc.Close(); err != nil {
+		return "", fmt.Errorf("failed to finalize upload %s: %w", objectKey, err)
+	}
+	return objectKey, nil
+}
+
+// storedFileURL turns a value returned by uploadFileToStorage into something the app can open
+func storedFileURL(stored string) string {
+	if stored == "" || strings.HasPrefix(stored, "data:") || !isGCSEnabled() {
+		return stored
+	}
+	url, err := storage.SignedURL(getGCSBucketName(), stored, &storage.SignedURLOptions{
+		Scheme:  storage.SigningSchemeV4,
+		Method:  "GET",
+		Expires: time.Now().Add(15 * time.Minute),
+	})
+	if err != nil {
+		log.Printf("⚠️  Failed to sign storage URL: %v", err)
+		return ""
+	}
+	return url
+}
+
 // closeGCS closes the GCS client
 func closeGCS() {
 	if gcsClient != nil {
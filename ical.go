@@ -0,0 +1,112 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// ================================
+// iCalendar (RFC 5545) writer สำหรับ calendar feed
+// ================================
+
+const (
+	icsDateTimeFormat = "20060102T150405Z"
+	icsLineLimit      = 75 // octets ต่อบรรทัด (ไม่รวม CRLF)
+)
+
+// icsEvent is one VEVENT. UID must stay the same for the lifetime of the booking/schedule
+// and Sequence must grow whenever its time or status changes so calendar apps replace it.
+type icsEvent struct {
+	UID          string
+	Sequence     int
+	Start        time.Time
+	End          time.Time
+	Summary      string
+	Description  string
+	Location     string
+	Status       string // CONFIRMED, TENTATIVE, CANCELLED
+	Transparent  bool   // true = ไม่นับว่าไม่ว่าง (เช่น ช่วงเวลาว่างรับงาน)
+	LastModified time.Time
+}
+
+// icsEscape escapes TEXT values (RFC 5545 3.3.11)
+func icsEscape(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, ";", `\;`)
+	s = strings.ReplaceAll(s, ",", `\,`)
+	s = strings.ReplaceAll(s, "\r\n", `\n`)
+	s = strings.ReplaceAll(s, "\n", `\n`)
+	return s
+}
+
+// icsFold splits a content line into 75-octet chunks without breaking UTF-8 characters
+// (ภาษาไทยใช้ 3 bytes ต่อตัวอักษร)
+func icsFold(line string) string {
+	if len(line) <= icsLineLimit {
+		return line + "\r\n"
+	}
+	var b strings.Builder
+	limit := icsLineLimit
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		b.WriteString(line[:cut])
+		b.WriteString("\r\n ")
+		line = line[cut:]
+		limit = icsLineLimit - 1 // บรรทัดต่อเริ่มด้วยช่องว่าง 1 ตัว
+	}
+	b.WriteString(line)
+	b.WriteString("\r\n")
+	return b.String()
+}
+
+// buildICS renders a VCALENDAR with the given events
+func buildICS(calendarName string, events []icsEvent, now time.Time) []byte {
+	var b strings.Builder
+	write := func(line string) { b.WriteString(icsFold(line)) }
+
+	write("BEGIN:VCALENDAR")
+	write("VERSION:2.0")
+	write("PRODID:-//SkillMatch//Calendar Feed//TH")
+	write("CALSCALE:GREGORIAN")
+	write("METHOD:PUBLISH")
+	write("X-WR-CALNAME:" + icsEscape(calendarName))
+	write("X-WR-TIMEZONE:Asia/Bangkok")
+	write("X-PUBLISHED-TTL:PT1H")
+	write("REFRESH-INTERVAL;VALUE=DURATION:PT1H")
+
+	stamp := now.UTC().Format(icsDateTimeFormat)
+	for _, e := range events {
+		write("BEGIN:VEVENT")
+		write("UID:" + e.UID)
+		write("DTSTAMP:" + stamp)
+		write("SEQUENCE:" + strconv.Itoa(e.Sequence))
+		write("DTSTART:" + e.Start.UTC().Format(icsDateTimeFormat))
+		write("DTEND:" + e.End.UTC().Format(icsDateTimeFormat))
+		write("SUMMARY:" + icsEscape(e.Summary))
+		if e.Description != "" {
+			write("DESCRIPTION:" + icsEscape(e.Description))
+		}
+		if e.Location != "" {
+			write("LOCATION:" + icsEscape(e.Location))
+		}
+		if e.Status != "" {
+			write("STATUS:" + e.Status)
+		}
+		if e.Transparent {
+			write("TRANSP:TRANSPARENT")
+		} else {
+			write("TRANSP:OPAQUE")
+		}
+		if !e.LastModified.IsZero() {
+			write("LAST-MODIFIED:" + e.LastModified.UTC().Format(icsDateTimeFormat))
+		}
+		write("END:VEVENT")
+	}
+	write("END:VCALENDAR")
+	return []byte(b.String())
+}
@@ -0,0 +1,481 @@
+package main
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ================================
+// iCalendar import - อ่าน VEVENT/RRULE จากปฏิทินภายนอกเป็นช่วงเวลาไม่ว่าง
+// ================================
+
+var errICSInvalid = errors.New("file is not a valid iCalendar (.ics) calendar")
+
+const maxICSOccurrences = 2000 // ต่อ 1 event ที่ซ้ำ (กัน RRULE ไม่มีที่สิ้นสุด)
+
+// icsImportEvent is one VEVENT from an external calendar
+type icsImportEvent struct {
+	UID          string
+	Start        time.Time
+	End          time.Time
+	RRule        string
+	ExDates      []time.Time
+	RecurrenceID *time.Time // แก้ไขเฉพาะครั้งของ event ที่ซ้ำ
+	Cancelled    bool
+	Transparent  bool // TRANSP:TRANSPARENT = ว่าง
+}
+
+// importedBlock is one busy occurrence. ExternalUID is stable across syncs.
+type importedBlock struct {
+	ExternalUID string
+	timeRange
+}
+
+// icsUnfold joins folded lines (CRLF + space/tab) and returns the content lines
+func icsUnfold(data string) []string {
+	data = strings.ReplaceAll(data, "\r\n", "\n")
+	lines := make([]string, 0)
+	for _, line := range strings.Split(data, "\n") {
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		if line = strings.TrimRight(line, "\r"); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// parseICSProperty splits NAME;PARAM=x;PARAM="y":value
+func parseICSProperty(line string) (string, map[string]string, string) {
+	inQuotes := false
+	colon := -1
+	for i, r := range line {
+		if r == '"' {
+			inQuotes = !inQuotes
+		} else if r == ':' && !inQuotes {
+			colon = i
+			break
+		}
+	}
+	if colon < 0 {
+		return strings.ToUpper(line), nil, ""
+	}
+
+	parts := strings.Split(line[:colon], ";")
+	params := make(map[string]string)
+	for _, p := range parts[1:] {
+		if kv := strings.SplitN(p, "=", 2); len(kv) == 2 {
+			params[strings.ToUpper(kv[0])] = strings.Trim(kv[1], `"`)
+		}
+	}
+	return strings.ToUpper(parts[0]), params, line[colon+1:]
+}
+
+// icsLocation resolves TZID, falling back to the provider's zone (รวมถึง floating time)
+func icsLocation(tzid string, fallback *time.Location) *time.Location {
+	if tzid != "" {
+		if loc, err := loadTimezone(tzid); err == nil {
+			return loc
+		}
+	}
+	return fallback
+}
+
+// parseICSTime parses DATE-TIME (UTC, TZID หรือ floating) and DATE values.
+// Floating times and dates are wall-clock in floating. allDay is true for DATE values.
+func parseICSTime(value string, params map[string]string, floating *time.Location) (t time.Time, allDay bool, err error) {
+	value = strings.TrimSpace(value)
+	loc := icsLocation(params["TZID"], floating)
+	switch {
+	case params["VALUE"] == "DATE" || len(value) == 8:
+		t, err = time.ParseInLocation("20060102", value, floating)
+		return t, true, err
+	case strings.HasSuffix(value, "Z"):
+		t, err = time.Parse("20060102T150405Z", value)
+		return t, false, err
+	default:
+		t, err = time.ParseInLocation("20060102T150405", value, loc)
+		return t, false, err
+	}
+}
+
+// parseICSDuration parses DURATION values such as PT1H30M, P1D or P2W
+func parseICSDuration(s string) (time.Duration, error) {
+	s = strings.TrimPrefix(strings.TrimPrefix(strings.ToUpper(s), "+"), "P")
+	if s == "" {
+		return 0, errICSInvalid
+	}
+	var d time.Duration
+	inTime := false
+	num := ""
+	for _, r := range s {
+		switch {
+		case r >= '0' && r <= '9':
+			num += string(r)
+		case r == 'T':
+			inTime = true
+		default:
+			n, err := strconv.Atoi(num)
+			if err != nil {
+				return 0, errICSInvalid
+			}
+			num = ""
+			switch {
+			case r == 'W':
+				d += time.Duration(n) * 7 * 24 * time.Hour
+			case r == 'D':
+				d += time.Duration(n) * 24 * time.Hour
+			case r == 'H' && inTime:
+				d += time.Duration(n) * time.Hour
+			case r == 'M' && inTime:
+				d += time.Duration(n) * time.Minute
+			case r == 'S' && inTime:
+				d += time.Duration(n) * time.Second
+			default:
+				return 0, errICSInvalid
+			}
+		}
+	}
+	if num != "" {
+		return 0, errICSInvalid
+	}
+	return d, nil
+}
+
+// parseICSEvents reads the VEVENTs of a calendar. Events without UID or DTSTART are skipped.
+// Floating times and all-day events are placed in loc (timezone ของ provider).
+func parseICSEvents(data []byte, loc *time.Location) ([]icsImportEvent, error) {
+	lines := icsUnfold(string(data))
+	if len(lines) == 0 || !strings.EqualFold(lines[0], "BEGIN:VCALENDAR") {
+		return nil, errICSInvalid
+	}
+
+	events := make([]icsImportEvent, 0)
+	var cur *icsImportEvent
+	var duration time.Duration
+	var hasEnd, allDay bool
+	depth := 0 // component ซ้อนใน VEVENT (เช่น VALARM)
+	for _, line := range lines {
+		name, params, value := parseICSProperty(line)
+		switch {
+		case name == "BEGIN" && strings.EqualFold(value, "VEVENT") && cur == nil:
+			cur, duration, hasEnd, allDay, depth = &icsImportEvent{}, 0, false, false, 0
+			continue
+		case cur == nil:
+			continue
+		case name == "BEGIN":
+			depth++
+			continue
+		case name == "END" && depth > 0:
+			depth--
+			continue
+		case name == "END" && strings.EqualFold(value, "VEVENT"):
+			if cur.UID != "" && !cur.Start.IsZero() {
+				if !hasEnd {
+					cur.End = cur.Start.Add(duration)
+					if duration == 0 && allDay {
+						cur.End = cur.Start.AddDate(0, 0, 1)
+					}
+				}
+				if cur.End.After(cur.Start) {
+					events = append(events, *cur)
+				}
+			}
+			cur = nil
+			continue
+		case depth > 0:
+			continue
+		}
+
+		switch name {
+		case "UID":
+			cur.UID = strings.TrimSpace(value)
+		case "DTSTART":
+			t, day, err := parseICSTime(value, params, loc)
+			if err != nil {
+				return nil, errICSInvalid
+			}
+			cur.Start, allDay = t, day
+		case "DTEND":
+			t, _, err := parseICSTime(value, params, loc)
+			if err != nil {
+				return nil, errICSInvalid
+			}
+			cur.End, hasEnd = t, true
+		case "DURATION":
+			d, err := parseICSDuration(value)
+			if err != nil {
+				return nil, err
+			}
+			duration = d
+		case "RRULE":
+			cur.RRule = strings.ToUpper(value)
+		case "EXDATE":
+			for _, v := range strings.Split(value, ",") {
+				if t, _, err := parseICSTime(v, params, loc); err == nil {
+					cur.ExDates = append(cur.ExDates, t)
+				}
+			}
+		case "RECURRENCE-ID":
+			if t, _, err := parseICSTime(value, params, loc); err == nil {
+				cur.RecurrenceID = &t
+			}
+		case "STATUS":
+			cur.Cancelled = strings.EqualFold(value, "CANCELLED")
+		case "TRANSP":
+			cur.Transparent = strings.EqualFold(value, "TRANSPARENT")
+		}
+	}
+	return events, nil
+}
+
+var icsWeekdays = map[string]time.Weekday{
+	"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday,
+	"TH": time.Thursday, "FR": time.Friday, "SA": time.Saturday,
+}
+
+// icsByDay is one BYDAY entry; Ordinal is 0 (ทุกสัปดาห์) or the nth/-nth weekday of the month
+type icsByDay struct {
+	Ordinal int
+	Weekday time.Weekday
+}
+
+func parseICSByDay(value string) []icsByDay {
+	days := make([]icsByDay, 0)
+	for _, v := range strings.Split(value, ",") {
+		if len(v) < 2 {
+			continue
+		}
+		wd, ok := icsWeekdays[v[len(v)-2:]]
+		if !ok {
+			continue
+		}
+		ordinal, _ := strconv.Atoi(v[:len(v)-2])
+		days = append(days, icsByDay{ordinal, wd})
+	}
+	return days
+}
+
+// nthWeekdayOfMonth returns the day of month of the nth (or -nth from the end) weekday, or 0
+func nthWeekdayOfMonth(year int, month time.Month, wd time.Weekday, n int, loc *time.Location) int {
+	lastDay := time.Date(year, month+1, 0, 0, 0, 0, 0, loc).Day()
+	if n > 0 {
+		first := time.Date(year, month, 1, 0, 0, 0, 0, loc).Weekday()
+		day := 1 + (int(wd)-int(first)+7)%7 + (n-1)*7
+		if day > lastDay {
+			return 0
+		}
+		return day
+	}
+	last := time.Date(year, month, lastDay, 0, 0, 0, 0, loc).Weekday()
+	day := lastDay - (int(last)-int(wd)+7)%7 + (n+1)*7
+	if day < 1 {
+		return 0
+	}
+	return day
+}
+
+// icsOccurrences expands an RRULE (FREQ DAILY/WEEKLY/MONTHLY/YEARLY with INTERVAL, COUNT,
+// UNTIL, BYDAY and BYMONTHDAY) into occurrence start times before to. Without COUNT the
+// expansion skips ahead to periods near from. Wall-clock time is kept in the DTSTART zone.
+// Events without RRULE return DTSTART only.
+func icsOccurrences(e icsImportEvent, from, to time.Time) []time.Time {
+	if e.RRule == "" {
+		return []time.Time{e.Start}
+	}
+	rule := make(map[string]string)
+	for _, part := range strings.Split(e.RRule, ";") {
+		if kv := strings.SplitN(part, "=", 2); len(kv) == 2 {
+			rule[kv[0]] = kv[1]
+		}
+	}
+	interval, _ := strconv.Atoi(rule["INTERVAL"])
+	if interval < 1 {
+		interval = 1
+	}
+	count, _ := strconv.Atoi(rule["COUNT"])
+	until := to
+	if v, ok := rule["UNTIL"]; ok {
+		if t, allDay, err := parseICSTime(v, map[string]string{}, e.Start.Location()); err == nil {
+			if allDay {
+				t = t.AddDate(0, 0, 1).Add(-time.Second) // UNTIL เป็นวันที่ = รวมทั้งวัน
+			}
+			if t.Before(until) {
+				until = t
+			}
+		}
+	}
+	byDay := parseICSByDay(rule["BYDAY"])
+	byMonthDay := make([]int, 0)
+	for _, v := range strings.Split(rule["BYMONTHDAY"], ",") {
+		if d, err := strconv.Atoi(v); err == nil && d != 0 {
+			byMonthDay = append(byMonthDay, d)
+		}
+	}
+
+	s := e.Start
+	loc := s.Location()
+	at := func(y int, m time.Month, d int) time.Time {
+		return time.Date(y, m, d, s.Hour(), s.Minute(), s.Second(), 0, loc)
+	}
+
+	result := make([]time.Time, 0)
+	emitted := 0
+	// candidates of one period, sorted; returns false when the expansion is done
+	emit := func(candidates []time.Time) bool {
+		sort.Slice(candidates, func(i, j int) bool { return candidates[i].Before(candidates[j]) })
+		for _, t := range candidates {
+			if t.Before(s) {
+				continue
+			}
+			if t.After(until) || (count > 0 && emitted >= count) || emitted >= maxICSOccurrences {
+				return false
+			}
+			result = append(result, t)
+			emitted++
+		}
+		return true
+	}
+
+	// ไม่มี COUNT = ไม่ต้องนับตั้งแต่ DTSTART ข้ามไปช่วงก่อน from ได้เลย
+	startPeriod := 0
+	if count == 0 && from.After(s) {
+		switch rule["FREQ"] {
+		case "DAILY":
+			startPeriod = int(from.Sub(s).Hours()/24)/interval - 1
+		case "WEEKLY":
+			startPeriod = int(from.Sub(s).Hours()/(24*7))/interval - 1
+		case "MONTHLY":
+			startPeriod = ((from.Year()-s.Year())*12+int(from.Month()-s.Month()))/interval - 1
+		case "YEARLY":
+			startPeriod = (from.Year()-s.Year())/interval - 1
+		}
+		if startPeriod < 0 {
+			startPeriod = 0
+		}
+	}
+
+	for period := startPeriod; period < startPeriod+maxICSOccurrences; period++ {
+		var candidates []time.Time
+		switch rule["FREQ"] {
+		case "DAILY":
+			candidates = []time.Time{at(s.Year(), s.Month(), s.Day()+period*interval)}
+		case "WEEKLY":
+			// สัปดาห์เริ่มวันจันทร์ (WKST=MO)
+			weekStart := at(s.Year(), s.Month(), s.Day()-(int(s.Weekday())+6)%7+period*interval*7)
+			if len(byDay) == 0 {
+				candidates = []time.Time{weekStart.AddDate(0, 0, (int(s.Weekday())+6)%7)}
+			}
+			for _, d := range byDay {
+				candidates = append(candidates, weekStart.AddDate(0, 0, (int(d.Weekday)+6)%7))
+			}
+		case "MONTHLY":
+			first := time.Date(s.Year(), s.Month()+time.Month(period*interval), 1, 0, 0, 0, 0, loc)
+			y, m := first.Year(), first.Month()
+			lastDay := time.Date(y, m+1, 0, 0, 0, 0, 0, loc).Day()
+			switch {
+			case len(byDay) > 0:
+				for _, d := range byDay {
+					if d.Ordinal != 0 {
+						if day := nthWeekdayOfMonth(y, m, d.Weekday, d.Ordinal, loc); day > 0 {
+							candidates = append(candidates, at(y, m, day))
+						}
+						continue
+					}
+					for day := 1; day <= lastDay; day++ {
+						if time.Date(y, m, day, 0, 0, 0, 0, loc).Weekday() == d.Weekday {
+							candidates = append(candidates, at(y, m, day))
+						}
+					}
+				}
+			case len(byMonthDay) > 0:
+				for _, d := range byMonthDay {
+					if d < 0 {
+						d = lastDay + d + 1
+					}
+					if d >= 1 && d <= lastDay {
+						candidates = append(candidates, at(y, m, d))
+					}
+				}
+			default:
+				if s.Day() <= lastDay { // วันที่ 31 ในเดือนที่มี 30 วัน = ข้าม
+					candidates = []time.Time{at(y, m, s.Day())}
+				}
+			}
+		case "YEARLY":
+			y := s.Year() + period*interval
+			if t := at(y, s.Month(), s.Day()); t.Month() == s.Month() { // 29 ก.พ. ในปีที่ไม่มี = ข้าม
+				candidates = []time.Time{t}
+			}
+		default:
+			return []time.Time{e.Start}
+		}
+		if !emit(candidates) {
+			break
+		}
+		if len(candidates) > 0 && candidates[0].After(until) {
+			break
+		}
+	}
+	return result
+}
+
+// expandICSBusyBlocks turns events into busy blocks overlapping [from, to).
+// Cancelled and transparent (free) events are skipped, EXDATEs removed and
+// RECURRENCE-ID overrides replace the occurrence they modify.
+func expandICSBusyBlocks(events []icsImportEvent, from, to time.Time) []importedBlock {
+	// occurrence ที่ถูกแก้ไข/ยกเลิกเฉพาะครั้ง
+	overridden := make(map[string]bool)
+	for _, e := range events {
+		if e.RecurrenceID != nil {
+			overridden[e.UID+"/"+e.RecurrenceID.UTC().Format(icsDateTimeFormat)] = true
+		}
+	}
+
+	blocks := make([]importedBlock, 0)
+	seen := make(map[string]bool)
+	add := func(uid string, start, end time.Time) {
+		if end.After(from) && start.Before(to) && !seen[uid] {
+			seen[uid] = true
+			blocks = append(blocks, importedBlock{uid, timeRange{start, end}})
+		}
+	}
+
+	for _, e := range events {
+		if e.Cancelled || e.Transparent {
+			continue
+		}
+		length := e.End.Sub(e.Start)
+		if e.RecurrenceID != nil {
+			add(e.UID+"/"+e.RecurrenceID.UTC().Format(icsDateTimeFormat), e.Start, e.Start.Add(length))
+			continue
+		}
+		if e.RRule == "" {
+			add(e.UID, e.Start, e.End)
+			continue
+		}
+		for _, start := range icsOccurrences(e, from, to) {
+			key := e.UID + "/" + start.UTC().Format(icsDateTimeFormat)
+			if overridden[key] || icsExcluded(e.ExDates, start) {
+				continue
+			}
+			add(key, start, start.Add(length))
+		}
+	}
+	sort.Slice(blocks, func(i, j int) bool { return blocks[i].Start.Before(blocks[j].Start) })
+	return blocks
+}
+
+func icsExcluded(exDates []time.Time, start time.Time) bool {
+	for _, ex := range exDates {
+		if ex.Equal(start) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const sampleImportICS = "BEGIN:VCALENDAR\r\n" +
+	"VERSION:2.0\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:dentist@example.com\r\n" +
+	"DTSTART:20261020T020000Z\r\n" +
+	"DTEND:20261020T030000Z\r\n" +
+	"SUMMARY:Dentist\\, long folded\r\n" +
+	"  summary line\r\n" +
+	"BEGIN:VALARM\r\n" +
+	"TRIGGER:-PT15M\r\n" +
+	"DURATION:PT5M\r\n" +
+	"END:VALARM\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:gym@example.com\r\n" +
+	"DTSTART;TZID=Asia/Bangkok:20261019T180000\r\n" +
+	"DURATION:PT1H30M\r\n" +
+	"RRULE:FREQ=WEEKLY;BYDAY=MO,WE;COUNT=4\r\n" +
+	"EXDATE;TZID=Asia/Bangkok:20261021T180000\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:gym@example.com\r\n" +
+	"RECURRENCE-ID;TZID=Asia/Bangkok:20261026T180000\r\n" +
+	"DTSTART;TZID=Asia/Bangkok:20261026T200000\r\n" +
+	"DTEND;TZID=Asia/Bangkok:20261026T213000\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:trip@example.com\r\n" +
+	"DTSTART;VALUE=DATE:20261023\r\n" +
+	"DTEND;VALUE=DATE:20261025\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:cancelled@example.com\r\n" +
+	"DTSTART:20261022T020000Z\r\n" +
+	"DTEND:20261022T030000Z\r\n" +
+	"STATUS:CANCELLED\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:reminder@example.com\r\n" +
+	"DTSTART:20261022T050000Z\r\n" +
+	"DTEND:20261022T060000Z\r\n" +
+	"TRANSP:TRANSPARENT\r\n" +
+	"END:VEVENT\r\n" +
+	"END:VCALENDAR\r\n"
+
+func TestParseICSEvents(t *testing.T) {
+	events, err := parseICSEvents([]byte(sampleImportICS), bangkokLocation)
+	assert.NoError(t, err)
+	if !assert.Len(t, events, 6) {
+		return
+	}
+
+	// VALARM's DURATION must not change the event
+	assert.Equal(t, bkk(20, 9, 0), events[0].Start.In(bangkokLocation))
+	assert.Equal(t, bkk(20, 10, 0), events[0].End.In(bangkokLocation))
+	// DURATION instead of DTEND
+	assert.Equal(t, 90*time.Minute, events[1].End.Sub(events[1].Start))
+	assert.Len(t, events[1].ExDates, 1)
+	assert.NotNil(t, events[2].RecurrenceID)
+	// all-day in Bangkok
+	assert.Equal(t, bkk(23, 0, 0), events[3].Start)
+	assert.Equal(t, bkk(25, 0, 0), events[3].End)
+	assert.True(t, events[4].Cancelled)
+	assert.True(t, events[5].Transparent)
+
+	_, err = parseICSEvents([]byte("hello"), bangkokLocation)
+	assert.ErrorIs(t, err, errICSInvalid)
+}
+
+func TestExpandICSBusyBlocks(t *testing.T) {
+	events, _ := parseICSEvents([]byte(sampleImportICS), bangkokLocation)
+	blocks := expandICSBusyBlocks(events, bkk(18, 0, 0), bkk(18, 0, 0).AddDate(0, 0, 30))
+
+	got := make(map[string]timeRange)
+	for _, b := range blocks {
+		got[b.ExternalUID] = timeRange{b.Start.In(bangkokLocation), b.End.In(bangkokLocation)}
+	}
+	assert.Equal(t, map[string]timeRange{
+		"dentist@example.com": {bkk(20, 9, 0), bkk(20, 10, 0)},
+		// COUNT=4: 19, 21 (EXDATE), 26 (moved to 20:00), 28
+		"gym@example.com/20261019T110000Z": {bkk(19, 18, 0), bkk(19, 19, 30)},
+		"gym@example.com/20261026T110000Z": {bkk(26, 20, 0), bkk(26, 21, 30)},
+		"gym@example.com/20261028T110000Z": {bkk(28, 18, 0), bkk(28, 19, 30)},
+		"trip@example.com":                 {bkk(23, 0, 0), bkk(25, 0, 0)},
+	}, got)
+
+	t.Run("external uids are stable across syncs", func(t *testing.T) {
+		again := expandICSBusyBlocks(events, bkk(18, 0, 0), bkk(18, 0, 0).AddDate(0, 0, 30))
+		assert.Equal(t, blocks, again)
+	})
+}
+
+func TestICSOccurrences(t *testing.T) {
+	start := time.Date(2026, 1, 30, 18, 0, 0, 0, bangkokLocation) // ศุกร์สุดท้ายของ ม.ค.
+
+	t.Run("monthly last friday", func(t *testing.T) {
+		e := icsImportEvent{Start: start, RRule: "FREQ=MONTHLY;BYDAY=-1FR;COUNT=3"}
+		occ := icsOccurrences(e, start, start.AddDate(1, 0, 0))
+		if assert.Len(t, occ, 3) {
+			assert.Equal(t, 27, occ[1].Day()) // 27 ก.พ. 2026
+			assert.Equal(t, 27, occ[2].Day()) // 27 มี.ค. 2026
+		}
+	})
+
+	t.Run("monthly on the 31st skips short months", func(t *testing.T) {
+		s := time.Date(2026, 1, 31, 9, 0, 0, 0, bangkokLocation)
+		occ := icsOccurrences(icsImportEvent{Start: s, RRule: "FREQ=MONTHLY"}, s, time.Date(2026, 5, 1, 0, 0, 0, 0, bangkokLocation))
+		months := make([]time.Month, len(occ))
+		for i, o := range occ {
+			months[i] = o.Month()
+		}
+		assert.Equal(t, []time.Month{time.January, time.March}, months)
+	})
+
+	t.Run("old daily rules without count still reach the window", func(t *testing.T) {
+		s := time.Date(2015, 1, 1, 9, 0, 0, 0, bangkokLocation)
+		from := time.Date(2026, 10, 18, 0, 0, 0, 0, bangkokLocation)
+		occ := icsOccurrences(icsImportEvent{Start: s, RRule: "FREQ=DAILY;UNTIL=20261020"}, from, from.AddDate(0, 0, 30))
+		last := occ[len(occ)-1]
+		assert.Equal(t, time.Date(2026, 10, 20, 9, 0, 0, 0, bangkokLocation), last)
+	})
+}
+
+func TestCalendarImportURL(t *testing.T) {
+	u, err := normalizeCalendarURL("webcal://calendar.google.com/calendar/ical/x/basic.ics")
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(u, "https://calendar.google.com/"))
+
+	_, err = normalizeCalendarURL("file:///etc/passwd")
+	assert.ErrorIs(t, err, errCalendarImportURL)
+
+	assert.ErrorIs(t, denyPrivateAddress("tcp", "127.0.0.1:80", nil), errCalendarImportBlocked)
+	assert.ErrorIs(t, denyPrivateAddress("tcp", "10.0.0.5:443", nil), errCalendarImportBlocked)
+	assert.ErrorIs(t, denyPrivateAddress("tcp", "169.254.169.254:80", nil), errCalendarImportBlocked)
+	assert.NoError(t, denyPrivateAddress("tcp", "142.250.0.1:443", nil))
+}
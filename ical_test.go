@@ -0,0 +1,60 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestICSEscapeAndFold(t *testing.T) {
+	assert.Equal(t, `a\, b\; c\\d\nline`, icsEscape("a, b; c\\d\nline"))
+
+	t.Run("short lines are not folded", func(t *testing.T) {
+		assert.Equal(t, "SUMMARY:hi\r\n", icsFold("SUMMARY:hi"))
+	})
+
+	t.Run("long Thai lines fold at 75 octets without splitting characters", func(t *testing.T) {
+		line := "SUMMARY:" + strings.Repeat("นวด", 20)
+		folded := icsFold(line)
+		parts := strings.Split(strings.TrimSuffix(folded, "\r\n"), "\r\n")
+		assert.Greater(t, len(parts), 1)
+		for i, p := range parts {
+			assert.LessOrEqual(t, len(p), icsLineLimit)
+			assert.True(t, utf8.ValidString(p))
+			if i > 0 {
+				assert.True(t, strings.HasPrefix(p, " "))
+			}
+		}
+		unfolded := strings.ReplaceAll(strings.TrimSuffix(folded, "\r\n"), "\r\n ", "")
+		assert.Equal(t, line, unfolded)
+	})
+}
+
+func TestBuildICS(t *testing.T) {
+	now := time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)
+	events := []icsEvent{
+		{
+			UID: "booking-12@skillmatch", Sequence: 3, Status: icsBookingStatus("cancelled"),
+			Start: bkk(20, 10, 0), End: bkk(20, 12, 0), Summary: "SkillMatch: Massage, 2h",
+		},
+		{
+			UID: "schedule-7@skillmatch", Status: icsBookingStatus("confirmed"), Transparent: true,
+			Start: bkk(21, 10, 0), End: bkk(21, 18, 0), Summary: "SkillMatch: Available",
+		},
+	}
+	ics := string(buildICS("SkillMatch - bob", events, now))
+
+	assert.True(t, strings.HasPrefix(ics, "BEGIN:VCALENDAR\r\n"))
+	assert.True(t, strings.HasSuffix(ics, "END:VCALENDAR\r\n"))
+	assert.Equal(t, 2, strings.Count(ics, "BEGIN:VEVENT"))
+	assert.Contains(t, ics, "UID:booking-12@skillmatch\r\nDTSTAMP:20261018T090000Z\r\nSEQUENCE:3\r\n")
+	// เวลาไทย 10:00 = 03:00 UTC
+	assert.Contains(t, ics, "DTSTART:20261020T030000Z\r\nDTEND:20261020T050000Z\r\n")
+	assert.Contains(t, ics, `SUMMARY:SkillMatch: Massage\, 2h`)
+	assert.Contains(t, ics, "STATUS:CANCELLED\r\nTRANSP:OPAQUE")
+	assert.Contains(t, ics, "STATUS:CONFIRMED\r\nTRANSP:TRANSPARENT")
+	assert.NotContains(t, ics, "LOCATION:")
+}
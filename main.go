@@ -105,6 +105,16 @@ func main() {
 	// --- 6. Run Migrations (from migrations.go) ---
 	runMigrations(dbPool, ctx)
 
+	// --- 6.1 Start Background Jobs ---
+	startFinancialReportScheduler(dbPool, ctx)
+	startReconciliationScheduler(dbPool, ctx)
+	startEscrowAutoReleaseScheduler(dbPool, ctx)
+	startDisputeDeadlineScheduler(dbPool, ctx)
+	startPaymentExpiryScheduler(dbPool, ctx)
+	startCampaignSettlementScheduler(dbPool, ctx)
+	startReferralRewardScheduler(dbPool, ctx)
+	startCalendarImportScheduler(dbPool, ctx)
+
 	// --- 7. Setup Gin Router ---
 	router := gin.Default()
 
@@ -204,22 +214,37 @@ func main() {
 		protected.PUT("/provider/me/categories", updateProviderCategoriesHandler(dbPool, ctx)) // อัพเดทหมวดหมู่ของตัวเอง
 
 		// 🆕 Booking Routes
-		protected.POST("/packages", createPackageHandler(dbPool, ctx))                                // สร้างแพ็คเกจ (provider)
-		protected.POST("/bookings", createBookingHandler(dbPool, ctx))                                // จองบริการ (ไม่มีการชำระเงิน)
-		protected.POST("/bookings/create-with-payment", createBookingWithPaymentHandler(dbPool, ctx)) // 🆕 จองบริการพร้อมชำระเงิน (Stripe)
-		protected.POST("/bookings/create-with-qr", createBookingWithQRHandler(dbPool, ctx))           // 🆕 จองบริการพร้อม QR Code PromptPay
-		protected.GET("/bookings/my", getMyBookingsHandler(dbPool, ctx))                              // ดูการจองของตัวเอง (client)
-		protected.GET("/bookings/provider", getProviderBookingsHandler(dbPool, ctx))                  // ดูการจองที่เข้ามา (provider)
-		protected.PATCH("/bookings/:id/status", updateBookingStatusHandler(dbPool, ctx))              // อัพเดทสถานะการจอง
-		protected.GET("/bookings/:id/work-details", getBookingWorkDetailsHandler(dbPool, ctx))        // 🆕 รายละเอียด booking สำหรับ provider ทำงาน
-		protected.GET("/bookings/:id/extension-packages", getExtensionPackagesHandler(dbPool, ctx))   // 🆕 ดูแพ็คเกจต่อเวลา
-		protected.GET("/bookings/:id/payment", getBookingPaymentHandler(dbPool, ctx))                 // 🆕 ดูข้อมูลการชำระเงิน
-		protected.POST("/bookings/extend", extendBookingHandler(dbPool, ctx))                         // 🆕 ต่อเวลา booking
-		protected.POST("/provider/location/update", updateProviderLocationHandler(dbPool, ctx))       // 🆕 อัพเดทพิกัด provider
+		protected.POST("/packages", createPackageHandler(dbPool, ctx))                                            // สร้างแพ็คเกจ (provider)
+		protected.GET("/provider/packages", getMyPackagesHandler(dbPool, ctx))                                    // แพ็คเกจของตัวเอง (รวมที่ปิดไว้)
+		protected.PUT("/provider/packages/order", reorderPackagesHandler(dbPool, ctx))                            // เรียงลำดับแพ็คเกจ
+		protected.PUT("/provider/packages/:packageId", updatePackageHandler(dbPool, ctx))                         // แก้ไขแพ็คเกจ (มี booking แล้ว → เวอร์ชันใหม่)
+		protected.PATCH("/provider/packages/:packageId/status", setPackageStatusHandler(dbPool, ctx))             // เปิด/ปิดแพ็คเกจ
+		protected.DELETE("/provider/packages/:packageId", deletePackageHandler(dbPool, ctx))                      // ลบแพ็คเกจ (มี booking → archive)
+		protected.GET("/provider/packages/:packageId/versions", getPackageVersionsHandler(dbPool, ctx))           // ประวัติเวอร์ชัน
+		protected.POST("/provider/packages/:packageId/images", uploadPackageImageHandler(dbPool, ctx))            // เพิ่มรูปแพ็คเกจ
+		protected.DELETE("/provider/packages/:packageId/images/:imageId", deletePackageImageHandler(dbPool, ctx)) // ลบรูปแพ็คเกจ
+		protected.POST("/bookings/quote", createBookingQuoteHandler(dbPool, ctx))                                 // 🆕 ใบเสนอราคาการจอง (quote_id ใช้ตอน checkout)
+		protected.GET("/quotes/:id", getPriceQuoteHandler(dbPool, ctx))                                           // 🆕 ดู quote
+		protected.POST("/bookings", createBookingHandler(dbPool, ctx))                                            // จองบริการ (ไม่มีการชำระเงิน)
+		protected.POST("/bookings/create-with-payment", createBookingWithPaymentHandler(dbPool, ctx))             // 🆕 จองบริการพร้อมชำระเงิน (Stripe)
+		protected.POST("/bookings/create-with-qr", createBookingWithQRHandler(dbPool, ctx))                       // 🆕 จองบริการพร้อม QR Code PromptPay
+		protected.GET("/bookings/my", getMyBookingsHandler(dbPool, ctx))                                          // ดูการจองของตัวเอง (client)
+		protected.GET("/bookings/provider", getProviderBookingsHandler(dbPool, ctx))                              // ดูการจองที่เข้ามา (provider)
+		protected.PATCH("/bookings/:id/status", updateBookingStatusHandler(dbPool, ctx))                          // อัพเดทสถานะการจอง
+		protected.GET("/bookings/:id/work-details", getBookingWorkDetailsHandler(dbPool, ctx))                    // 🆕 รายละเอียด booking สำหรับ provider ทำงาน
+		protected.GET("/bookings/:id/extension-packages", getExtensionPackagesHandler(dbPool, ctx))               // 🆕 ดูแพ็คเกจต่อเวลา
+		protected.GET("/bookings/:id/payment", getBookingPaymentHandler(dbPool, ctx))                             // 🆕 ดูข้อมูลการชำระเงิน
+		protected.GET("/bookings/:id/snapshot", getBookingSnapshotHandler(dbPool, ctx))                           // แพ็คเกจ/ราคา/นโยบาย ณ เวลาจอง
+		protected.POST("/bookings/:id/extension-quote", createExtensionQuoteHandler(dbPool, ctx))                 // 🆕 ใบเสนอราคาต่อเวลา
+		protected.POST("/bookings/extend", extendBookingHandler(dbPool, ctx))                                     // 🆕 ต่อเวลา booking
+		protected.POST("/provider/location/update", updateProviderLocationHandler(dbPool, ctx))                   // 🆕 อัพเดทพิกัด provider
 
 		// 🆕 Payment Routes (QR Code & PromptPay)
-		protected.POST("/payments/:payment_reference/confirm", confirmPaymentHandler(dbPool, ctx))   // ยืนยันการชำระเงิน
-		protected.GET("/payments/:payment_reference/status", checkPaymentStatusHandler(dbPool, ctx)) // ตรวจสอบสถานะการชำระเงิน
+		protected.POST("/payments/:payment_reference/confirm", confirmPaymentHandler(dbPool, ctx))         // ยืนยันการชำระเงิน (admin เท่านั้น)
+		protected.GET("/payments/:payment_reference/status", checkPaymentStatusHandler(dbPool, ctx))       // ตรวจสอบสถานะการชำระเงิน
+		protected.GET("/payments/:payment_reference/qr.png", getPaymentQRImageHandler(dbPool, ctx, "png")) // รูป QR Code (PNG)
+		protected.GET("/payments/:payment_reference/qr.svg", getPaymentQRImageHandler(dbPool, ctx, "svg")) // รูป QR Code (SVG)
+		protected.POST("/payments/:payment_reference/slip", uploadPaymentSlipHandler(dbPool, ctx))         // อัปโหลดสลิป (ตรวจ + ยืนยันอัตโนมัติ)
 
 		// 🆕 Review Routes
 		protected.POST("/reviews", createReviewHandler(dbPool, ctx)) // สร้างรีวิว
@@ -267,10 +292,23 @@ func main() {
 		protected.GET("/bank-accounts", getMyBankAccountsHandler(dbPool, ctx))                     // ดูบัญชีธนาคารของตัวเอง
 		protected.DELETE("/bank-accounts/:bank_account_id", deleteBankAccountHandler(dbPool, ctx)) // ลบบัญชีธนาคาร
 		protected.GET("/wallet", getMyWalletHandler(dbPool, ctx))                                  // ดู wallet ของตัวเอง
+		protected.POST("/wallet/topup", createWalletTopupHandler(dbPool, ctx))                     // เติมเงินเข้า wallet (Stripe / PromptPay)
+		protected.GET("/wallet/topups", getMyWalletTopupsHandler(dbPool, ctx))                     // ประวัติการเติมเงิน
 		protected.POST("/withdrawals", requestWithdrawalHandler(dbPool, ctx))                      // ขอถอนเงิน
 		protected.GET("/withdrawals", getMyWithdrawalsHandler(dbPool, ctx))                        // ดูประวัติการถอนเงิน
 		protected.GET("/transactions", getMyTransactionsHandler(dbPool, ctx))                      // ดูประวัติธุรกรรม
 
+		// Withholding Tax (ภาษีหัก ณ ที่จ่าย)
+		protected.GET("/tax/statements", getTaxStatementsHandler(dbPool, ctx))                   // สรุปภาษีหัก ณ ที่จ่ายรายปี
+		protected.PUT("/tax/profile", updateTaxProfileHandler(dbPool, ctx))                      // ข้อมูลผู้เสียภาษี
+		protected.GET("/tax/certificates/:year/pdf", getMyTaxCertificatePDFHandler(dbPool, ctx)) // ดาวน์โหลด 50 ทวิ
+
+		// Receipts / Tax Invoices (ใบเสร็จรับเงิน/ใบกำกับภาษี)
+		protected.GET("/bookings/:id/receipt", getBookingReceiptHandler(dbPool, ctx))   // ใบเสร็จ PDF (?type=booking|deposit)
+		protected.GET("/receipts", getMyReceiptsHandler(dbPool, ctx))                   // ใบเสร็จทั้งหมดของฉัน
+		protected.GET("/receipts/:receipt_id/pdf", getReceiptPDFHandler(dbPool, ctx))   // ดาวน์โหลดใบเสร็จ
+		protected.POST("/receipts/:receipt_id/email", emailReceiptHandler(dbPool, ctx)) // ส่งใบเสร็จทางอีเมล
+
 		// 🆕 Provider Document & Verification System
 		protected.POST("/provider/documents", uploadProviderDocumentHandler(dbPool, ctx))     // อัปโหลดเอกสาร (from provider_system_handlers.go)
 		protected.GET("/provider/documents", getMyDocumentsHandler(dbPool, ctx))              // ดูเอกสารของตัวเอง (from provider_system_handlers.go)
@@ -289,10 +327,29 @@ func main() {
 		protected.POST("/provider/create-upgrade-checkout", createProviderUpgradeCheckoutHandler(dbPool, ctx)) // 🆕 สร้าง Stripe Checkout (หลังแอดมินอนุมัติ)
 
 		// 🆕 Provider Schedule Management (from schedule_handlers.go)
-		protected.POST("/provider/schedule", createScheduleHandler(dbPool, ctx))               // สร้างตารางงาน
-		protected.GET("/provider/schedule/me", getMySchedulesHandler(dbPool, ctx))             // ดูตารางงานของตัวเอง
-		protected.PATCH("/provider/schedule/:scheduleId", updateScheduleHandler(dbPool, ctx))  // แก้ไขตารางงาน
-		protected.DELETE("/provider/schedule/:scheduleId", deleteScheduleHandler(dbPool, ctx)) // ลบตารางงาน
+		protected.POST("/provider/schedule", createScheduleHandler(dbPool, ctx))                                    // สร้างตารางงาน
+		protected.GET("/provider/schedule/me", getMySchedulesHandler(dbPool, ctx))                                  // ดูตารางงานของตัวเอง
+		protected.PATCH("/provider/schedule/:scheduleId", updateScheduleHandler(dbPool, ctx))                       // แก้ไขตารางงาน
+		protected.DELETE("/provider/schedule/:scheduleId", deleteScheduleHandler(dbPool, ctx))                      // ลบตารางงาน
+		protected.GET("/provider/schedule/recurring", getMyRecurringSchedulesHandler(dbPool, ctx))                  // 🆕 กฎตารางประจำสัปดาห์ + วันยกเว้น
+		protected.POST("/provider/schedule/recurring", createRecurringScheduleHandler(dbPool, ctx))                 // 🆕 เพิ่มกฎประจำสัปดาห์
+		protected.PUT("/provider/schedule/recurring", replaceRecurringSchedulesHandler(dbPool, ctx))                // 🆕 แก้ไขกฎทั้งหมด (bulk)
+		protected.PATCH("/provider/schedule/recurring/pause", pauseRecurringSchedulesHandler(dbPool, ctx))          // 🆕 หยุด/เปิดกฎ
+		protected.PUT("/provider/schedule/recurring/:ruleId", updateRecurringScheduleHandler(dbPool, ctx))          // 🆕 แก้ไขกฎ
+		protected.DELETE("/provider/schedule/recurring/:ruleId", deleteRecurringScheduleHandler(dbPool, ctx))       // 🆕 ลบกฎ
+		protected.POST("/provider/schedule/exceptions", createScheduleExceptionHandler(dbPool, ctx))                // 🆕 วันลา/วันยกเว้น
+		protected.DELETE("/provider/schedule/exceptions/:exceptionId", deleteScheduleExceptionHandler(dbPool, ctx)) // 🆕 ลบวันยกเว้น
+		protected.GET("/calendar/feed", getCalendarFeedHandler(dbPool, ctx))                                        // 🆕 ลิงก์ ICS feed ของตัวเอง
+		protected.POST("/calendar/feed/regenerate", regenerateCalendarFeedHandler(dbPool, ctx))                     // 🆕 ออกลิงก์ใหม่ (ยกเลิกลิงก์เดิม)
+		protected.DELETE("/calendar/feed", deleteCalendarFeedHandler(dbPool, ctx))                                  // 🆕 ปิด ICS feed
+		protected.GET("/provider/calendar-imports", getMyCalendarImportsHandler(dbPool, ctx))                       // 🆕 ปฏิทินภายนอกที่เชื่อมไว้
+		protected.POST("/provider/calendar-imports", createCalendarImportHandler(dbPool, ctx))                      // 🆕 เชื่อม ICS URL
+		protected.POST("/provider/calendar-imports/upload", uploadCalendarImportHandler(dbPool, ctx))               // 🆕 อัปโหลดไฟล์ .ics
+		protected.POST("/provider/calendar-imports/:importId/sync", syncCalendarImportHandler(dbPool, ctx))         // 🆕 sync ทันที
+		protected.PATCH("/provider/calendar-imports/:importId", updateCalendarImportHandler(dbPool, ctx))           // 🆕 เปิด/ปิด sync
+		protected.DELETE("/provider/calendar-imports/:importId", deleteCalendarImportHandler(dbPool, ctx))          // 🆕 ยกเลิกการเชื่อม
+		protected.GET("/provider/availability-settings", getMyAvailabilitySettingsHandler(dbPool, ctx))             // buffer/slot interval
+		protected.PUT("/provider/availability-settings", updateMyAvailabilitySettingsHandler(dbPool, ctx))          // ตั้งค่า buffer/slot interval
 
 		// 🆕 Safety Features (from safety_handlers.go)
 		protected.POST("/safety/trusted-contacts", addTrustedContactHandler(dbPool, ctx))          // เพิ่มผู้ติดต่อฉุกเฉิน
@@ -310,12 +367,33 @@ func main() {
 		protected.POST("/gallery/private/purchase", purchaseGalleryAccessHandler(dbPool, ctx))       // ซื้อสิทธิ์ดู private gallery
 
 		// 🆕 Deposit & Cancellation (from promotion_handlers.go)
+		protected.GET("/provider/pricing-rules", getMyPricingRulesHandler(dbPool, ctx))              // 🆕 กฎราคา dynamic ของแพ็คเกจ
+		protected.POST("/provider/pricing-rules", createPricingRuleHandler(dbPool, ctx))             // 🆕 เพิ่มกฎราคา
+		protected.PUT("/provider/pricing-rules/:ruleId", updatePricingRuleHandler(dbPool, ctx))      // 🆕 แก้ไขกฎราคา
+		protected.DELETE("/provider/pricing-rules/:ruleId", deletePricingRuleHandler(dbPool, ctx))   // 🆕 ลบกฎราคา
 		protected.GET("/provider/deposit-settings", getDepositSettingsHandler(dbPool, ctx))          // ดูตั้งค่ามัดจำ
 		protected.PUT("/provider/deposit-settings", updateDepositSettingsHandler(dbPool, ctx))       // อัพเดทตั้งค่ามัดจำ
 		protected.POST("/bookings/:id/deposit/pay", payDepositHandler(dbPool, ctx))                  // จ่ายมัดจำ
 		protected.GET("/provider/cancellation-policy", getCancellationPolicyHandler(dbPool, ctx))    // ดูนโยบายยกเลิก
 		protected.PUT("/provider/cancellation-policy", updateCancellationPolicyHandler(dbPool, ctx)) // อัพเดทนโยบายยกเลิก
 		protected.POST("/bookings/:id/cancel", cancelBookingWithFeeHandler(dbPool, ctx))             // ยกเลิก booking พร้อมคำนวณค่าปรับ
+		protected.POST("/bookings/:id/tip", createBookingTipHandler(dbPool, ctx))                    // ทิป provider หลังจบงาน (card / promptpay / wallet)
+		protected.GET("/bookings/:id/tips", getBookingTipsHandler(dbPool, ctx))                      // ดูทิปของ booking
+		protected.GET("/cancellation-fees", getMyCancellationFeesHandler(dbPool, ctx))               // ค่าปรับของฉัน / ยอดค้าง
+		protected.POST("/cancellation-fees/:fee_id/pay", payCancellationFeeHandler(dbPool, ctx))     // ชำระค่าปรับค้าง (Stripe)
+
+		// 🆕 Escrow (from escrow_handlers.go)
+		protected.POST("/bookings/:id/provider-arrived", providerArrivedHandler(dbPool, ctx))            // provider แจ้งถึงสถานที่
+		protected.POST("/bookings/:id/confirm-arrival", confirmProviderArrivalHandler(dbPool, ctx))      // client ยืนยันการมาถึง
+		protected.POST("/bookings/:id/provider-complete", providerCompleteServiceHandler(dbPool, ctx))   // provider แจ้งงานเสร็จ (เริ่มนับเวลาปลดเงิน)
+		protected.POST("/bookings/:id/confirm-completion", confirmServiceCompletionHandler(dbPool, ctx)) // client ยืนยันงานเสร็จ ปลดเงินทันที
+		protected.POST("/bookings/:id/dispute", disputeBookingHandler(dbPool, ctx))                      // เปิดข้อพิพาท (หยุดปลดเงินอัตโนมัติ)
+
+		// 🆕 Dispute Cases (from dispute_handlers.go)
+		protected.GET("/disputes", getMyDisputesHandler(dbPool, ctx))                         // เคสข้อพิพาทของฉัน
+		protected.GET("/disputes/:case_id", getDisputeCaseHandler(dbPool, ctx))               // รายละเอียด + timeline + หลักฐาน + ข้อความ
+		protected.POST("/disputes/:case_id/messages", postDisputeMessageHandler(dbPool, ctx)) // ส่งข้อความ (client/provider/admin)
+		protected.POST("/disputes/:case_id/evidence", addDisputeEvidenceHandler(dbPool, ctx)) // ส่งหลักฐาน
 
 		// 🆕 Profile Boost (from promotion_handlers.go)
 		protected.GET("/boost/packages", getBoostPackagesHandler(dbPool, ctx)) // ดูแพ็คเกจ boost
@@ -327,12 +405,25 @@ func main() {
 		protected.POST("/coupons/apply", applyCouponHandler(dbPool, ctx))    // ใช้คูปอง
 		protected.GET("/coupons/my", getProviderCouponsHandler(dbPool, ctx)) // ดูคูปองของฉัน
 
+		// 🆕 Referral Program (from referral_handlers.go)
+		protected.GET("/referrals/me", getMyReferralDashboardHandler(dbPool, ctx)) // โค้ด/ลิงก์ชวนเพื่อน + dashboard
+
+		// 🆕 Promotion Campaigns (from campaign_handlers.go)
+		protected.POST("/campaigns", createCampaignHandler(dbPool, ctx))                        // สร้างแคมเปญ (Admin: platform/provider, Provider: ออกเงินเอง)
+		protected.GET("/campaigns/my", getMyCampaignsHandler(dbPool, ctx))                      // แคมเปญของฉัน
+		protected.POST("/campaigns/redeem", redeemCampaignHandler(dbPool, ctx))                 // ใช้แคมเปญกับ booking
+		protected.PATCH("/campaigns/:id", updateCampaignHandler(dbPool, ctx))                   // เปิด/ปิด, เพิ่มงบ, ขยายเวลา
+		protected.GET("/campaigns/:id/report", getCampaignReportHandler(dbPool, ctx))           // ผลการดำเนินงานของแคมเปญ
+		protected.GET("/campaigns/:id/redemptions", getCampaignRedemptionsHandler(dbPool, ctx)) // รายการที่ใช้แคมเปญ
+
 		// 🆕 Photo Verification Badge (from promotion_handlers.go)
 		protected.POST("/photos/:id/verify", submitPhotoVerificationHandler(dbPool, ctx)) // ส่งรูปเพื่อขอ verified badge
 	}
 
 	// Public Coupon/Promotion Routes (ไม่ต้อง login)
 	router.GET("/coupons/browse", browseCouponsHandler(dbPool, ctx))                          // ดูคูปองทั้งหมดที่ active (Public)
+	router.GET("/referrals/code/:code", checkReferralCodeHandler(dbPool, ctx))                // ตรวจโค้ดชวนเพื่อนก่อนสมัคร (Public)
+	router.GET("/campaigns/active", getActiveCampaignsHandler(dbPool, ctx))                   // แคมเปญที่ใช้ได้ตอนนี้ (Public)
 	router.GET("/coupons/provider/:providerId", getProviderPublicCouponsHandler(dbPool, ctx)) // ดูคูปองของ provider นั้นๆ (Public)
 
 	// Admin Routes (ต้อง Login และเป็น Admin หรือ GOD)
@@ -367,9 +458,60 @@ func main() {
 		admin.POST("/financial/reports", adminGenerateFinancialReportHandler(dbPool, ctx))               // สร้างรายงานทางการเงิน
 		admin.GET("/commission-rules", adminGetCommissionRulesHandler(dbPool, ctx))                      // ดูกฎค่าคอมมิชชั่น
 		admin.PUT("/commission-rules/:rule_id", adminUpdateCommissionRuleHandler(dbPool, ctx))           // แก้ไขกฎค่าคอมมิชชั่น
+		admin.GET("/payment-slips", adminGetPaymentSlipsHandler(dbPool, ctx))                            // คิวตรวจสลิป PromptPay
+		admin.POST("/payment-slips/:slip_id/review", adminReviewPaymentSlipHandler(dbPool, ctx))         // อนุมัติ/ปฏิเสธสลิป
 		admin.GET("/wallets/:user_id", adminGetUserWalletHandler(dbPool, ctx))                           // ดู wallet ของ user
 		admin.POST("/wallets/:user_id/adjust", adminAdjustWalletHandler(dbPool, ctx))                    // ปรับยอด wallet (bonus/penalty)
 
+		// Bulk Payouts (ไฟล์โอนเงินหลายรายการ)
+		admin.GET("/payouts/formats", adminGetPayoutFormatsHandler())                             // รูปแบบไฟล์ที่รองรับ
+		admin.POST("/payouts/runs", adminCreatePayoutRunHandler(dbPool, ctx))                     // สร้างไฟล์โอนจากคำขอที่อนุมัติแล้ว
+		admin.GET("/payouts/runs", adminGetPayoutRunsHandler(dbPool, ctx))                        // รายการ payout run
+		admin.GET("/payouts/runs/:run_id", adminGetPayoutRunHandler(dbPool, ctx))                 // รายละเอียด payout run
+		admin.GET("/payouts/runs/:run_id/file", adminDownloadPayoutFileHandler(dbPool, ctx))      // ดาวน์โหลดไฟล์ส่งธนาคาร
+		admin.POST("/payouts/runs/:run_id/results", adminUploadPayoutResultsHandler(dbPool, ctx)) // อัปโหลดไฟล์ผลการโอน
+
+		// Financial Reports (รายงานอัตโนมัติ + export)
+		admin.GET("/financial/reports", adminGetFinancialReportsHandler(dbPool, ctx))                            // ประวัติรายงาน
+		admin.GET("/financial/reports/:report_id", adminGetFinancialReportHandler(dbPool, ctx))                  // รายงานแยกตาม category/tier + เทียบงวดก่อน
+		admin.GET("/financial/reports/:report_id/export", adminExportFinancialReportHandler(dbPool, ctx))        // ?format=csv|xlsx
+		admin.GET("/financial/report-definitions", adminGetReportDefinitionsHandler(dbPool, ctx))                // รายงานที่สร้างอัตโนมัติ
+		admin.PUT("/financial/report-definitions/:report_type", adminUpdateReportDefinitionHandler(dbPool, ctx)) // เปิด/ปิดรายงานอัตโนมัติ
+
+		// Reconciliation (กระทบยอดรายคืน)
+		admin.GET("/reconciliation/runs", adminGetReconciliationRunsHandler(dbPool, ctx))                        // ประวัติการกระทบยอด
+		admin.POST("/reconciliation/runs", adminRunReconciliationHandler(dbPool, ctx))                           // รันทันที
+		admin.GET("/reconciliation/discrepancies", adminGetDiscrepanciesHandler(dbPool, ctx))                    // รายการที่ยอดไม่ตรง
+		admin.PATCH("/reconciliation/discrepancies/:discrepancy_id", adminUpdateDiscrepancyHandler(dbPool, ctx)) // ปิดเคส
+
+		// Escrow Release Rules (ตั้งค่าเวลาปลดเงินอัตโนมัติ)
+		admin.GET("/escrow/release-rules", adminGetEscrowReleaseRulesHandler(dbPool, ctx))            // ดูกฎปลดเงิน
+		admin.POST("/escrow/release-rules", adminCreateEscrowReleaseRuleHandler(dbPool, ctx))         // เพิ่มกฎ
+		admin.PUT("/escrow/release-rules/:rule_id", adminUpdateEscrowReleaseRuleHandler(dbPool, ctx)) // แก้ไขกฎ
+
+		// Cancellation Fees (ค่าปรับการยกเลิก)
+		admin.GET("/cancellation-fees", adminGetCancellationFeesHandler(dbPool, ctx))                 // รายการค่าปรับ
+		admin.POST("/cancellation-fees/:fee_id/waive", adminWaiveCancellationFeeHandler(dbPool, ctx)) // ยกเว้นค่าปรับ
+
+		// Disputes (ข้อพิพาท)
+		admin.GET("/disputes", adminGetDisputesHandler(dbPool, ctx))                                       // เคสทั้งหมด
+		admin.POST("/disputes/:case_id/request-response", adminRequestDisputeResponseHandler(dbPool, ctx)) // ขอคำชี้แจงพร้อมกำหนดเวลา
+		admin.POST("/disputes/:case_id/resolve", adminResolveDisputeHandler(dbPool, ctx))                  // ตัดสิน (คืนเงิน/จ่าย provider/แบ่ง)
+		admin.PUT("/holidays", adminUpsertHolidayHandler(dbPool, ctx))                                     // เพิ่ม/แก้ไขวันหยุด (ใช้กับกฎราคา holiday)
+		admin.DELETE("/holidays/:date", adminDeleteHolidayHandler(dbPool, ctx))                            // ลบวันหยุด
+		admin.GET("/referrals", adminGetReferralsHandler(dbPool, ctx))                                     // referral ทั้งหมด (?status=flagged)
+		admin.PATCH("/referrals/:id", adminReviewReferralHandler(dbPool, ctx))                             // ตรวจ referral ที่ถูก flag
+		admin.GET("/campaigns", adminGetCampaignsHandler(dbPool, ctx))                                     // แคมเปญทั้งหมด (?active=true)
+
+		// Withholding Tax & 50 Tawi
+		admin.GET("/tax/withholding-rates", adminGetWithholdingRatesHandler(dbPool, ctx))                 // อัตราภาษีหัก ณ ที่จ่าย
+		admin.POST("/tax/withholding-rates", adminCreateWithholdingRateHandler(dbPool, ctx))              // เพิ่มอัตราภาษี
+		admin.PUT("/tax/withholding-rates/:rate_id", adminUpdateWithholdingRateHandler(dbPool, ctx))      // แก้ไขอัตราภาษี
+		admin.POST("/tax/certificates/generate", adminGenerateTaxCertificatesHandler(dbPool, ctx))        // ออก 50 ทวิ รายปี
+		admin.GET("/tax/certificates", adminGetTaxCertificatesHandler(dbPool, ctx))                       // รายการ 50 ทวิ
+		admin.GET("/tax/certificates/:certificate_id/pdf", adminGetTaxCertificatePDFHandler(dbPool, ctx)) // ดาวน์โหลด 50 ทวิ
+		admin.GET("/tax/export", adminExportWithholdingTaxHandler(dbPool, ctx))                           // ไฟล์สำหรับยื่น ภ.ง.ด.3/53
+
 		// 🆕 Admin Provider Management
 		admin.GET("/providers/pending", getAdminPendingProvidersHandler(dbPool, ctx))            // ดู providers ที่รอตรวจสอบ (from provider_system_handlers.go)
 		admin.PATCH("/verify-document/:documentId", adminVerifyDocumentHandler(dbPool, ctx))     // อนุมัติ/ปฏิเสธเอกสาร (from provider_system_handlers.go)
@@ -437,12 +579,15 @@ func main() {
 
 	// 🆕 Provider Public Profile Routes (No auth required - anyone can view)
 	// Public routes - ข้อมูลจำกัด (ไม่แสดง Age, Height, Weight, ServiceType, etc.)
-	router.GET("/provider/:userId/public", getPublicProfileHandler(dbPool, ctx))         // ดู profile แบบจำกัด (ไม่ต้อง login)
-	router.GET("/provider/:userId/photos", getProviderPhotosHandler(dbPool, ctx))        // ดูรูปภาพของผู้ให้บริการ (Public)
-	router.GET("/packages/:providerId", getProviderPackagesHandler(dbPool, ctx))         // ดูแพ็คเกจของ provider (Public)
-	router.GET("/reviews/:providerId", getProviderReviewsHandler(dbPool, ctx))           // ดูรีวิวของ provider (Public)
-	router.GET("/reviews/stats/:providerId", getProviderReviewStatsHandler(dbPool, ctx)) // สถิติรีวิว (Public)
-	router.GET("/favorites/check/:providerId", checkFavoriteHandler(dbPool, ctx))        // เช็ค favorite (Public - optional auth)
+	router.GET("/provider/:userId/public", getPublicProfileHandler(dbPool, ctx))               // ดู profile แบบจำกัด (ไม่ต้อง login)
+	router.GET("/provider/:userId/photos", getProviderPhotosHandler(dbPool, ctx))              // ดูรูปภาพของผู้ให้บริการ (Public)
+	router.GET("/providers/:userId/availability", getProviderAvailabilityHandler(dbPool, ctx)) // เวลาที่จองได้ (?from=&to=&package_id=, Public)
+	router.GET("/holidays", getHolidaysHandler(dbPool, ctx))                                   // ปฏิทินวันหยุดไทย (Public)
+	router.GET("/calendar/ics/:token", calendarFeedHandler(dbPool, ctx))                       // 🆕 ICS feed (token ลับ, Public)
+	router.GET("/packages/:providerId", getProviderPackagesHandler(dbPool, ctx))               // ดูแพ็คเกจของ provider (Public)
+	router.GET("/reviews/:providerId", getProviderReviewsHandler(dbPool, ctx))                 // ดูรีวิวของ provider (Public)
+	router.GET("/reviews/stats/:providerId", getProviderReviewStatsHandler(dbPool, ctx))       // สถิติรีวิว (Public)
+	router.GET("/favorites/check/:providerId", checkFavoriteHandler(dbPool, ctx))              // เช็ค favorite (Public - optional auth)
 
 	// Protected routes - ข้อมูลเต็มรูปแบบ (ต้อง login)
 	protected.GET("/provider/:userId", getAuthenticatedProfileHandler(dbPool, ctx))           // ดู profile เต็มรูปแบบ (ต้อง login)
@@ -1040,5 +1040,1315 @@ func runMigrations(dbPool *pgxpool.Pool, ctx context.Context) {
 		fmt.Println("✅ Migration 036: Email Verifications Table completed!")
 	}
 
+	// --- Migration 037: Payment Slips (PromptPay slip upload & matching) ---
+	fmt.Println("🔄 Running Migration 037: Payment Slips...")
+	_, err = dbPool.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS payment_slips (
+			slip_id SERIAL PRIMARY KEY,
+			payment_id INT NOT NULL REFERENCES payments(payment_id) ON DELETE CASCADE,
+			uploaded_by INT NOT NULL REFERENCES users(user_id),
+			image_url TEXT NOT NULL, -- GCS object key (or data URL in development)
+			qr_payload TEXT, -- slip mini-QR as read by the client app
+			verifier VARCHAR(50) NOT NULL, -- 'local', 'slipok', 'easyslip', 'http'
+			extracted_amount DECIMAL(10, 2),
+			extracted_transaction_ref VARCHAR(100),
+			extracted_transferred_at TIMESTAMPTZ,
+			sending_bank VARCHAR(20),
+			is_verified BOOLEAN DEFAULT false,
+			match_status VARCHAR(20) NOT NULL DEFAULT 'review', -- 'matched', 'review', 'rejected', 'approved'
+			match_notes TEXT,
+			reviewed_by INT REFERENCES users(user_id),
+			reviewed_at TIMESTAMPTZ,
+			review_notes TEXT,
+			created_at TIMESTAMPTZ DEFAULT NOW()
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_payment_slips_payment ON payment_slips(payment_id);
+		CREATE INDEX IF NOT EXISTS idx_payment_slips_review ON payment_slips(match_status) WHERE match_status = 'review';
+		-- A bank transaction can only ever confirm one payment
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_payment_slips_used_ref ON payment_slips(extracted_transaction_ref)
+			WHERE match_status IN ('matched', 'approved');
+
+		ALTER TABLE payments
+			ADD COLUMN IF NOT EXISTS slip_status VARCHAR(20); -- latest slip match_status
+	`)
+	if err != nil {
+		log.Printf("Warning: Migration 037 error: %v\n", err)
+	} else {
+		fmt.Println("✅ Migration 037: Payment Slips completed!")
+	}
+
+	// --- Migration 038: Payout Runs (bank bulk-transfer files) ---
+	fmt.Println("🔄 Running Migration 038: Payout Runs...")
+	_, err = dbPool.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS payout_runs (
+			run_id SERIAL PRIMARY KEY,
+			file_format VARCHAR(30) NOT NULL,           -- 'generic_csv', 'kbank_bulk'
+			status VARCHAR(20) NOT NULL DEFAULT 'processing', -- 'processing', 'completed', 'partially_failed', 'failed'
+			item_count INT NOT NULL DEFAULT 0,
+			total_amount DECIMAL(12, 2) NOT NULL DEFAULT 0,
+			file_name VARCHAR(255) NOT NULL,
+			file_content BYTEA NOT NULL,
+			result_file_name VARCHAR(255),
+			created_by INT REFERENCES users(user_id),
+			created_at TIMESTAMPTZ DEFAULT NOW(),
+			results_uploaded_by INT REFERENCES users(user_id),
+			results_uploaded_at TIMESTAMPTZ
+		);
+
+		CREATE TABLE IF NOT EXISTS payout_run_items (
+			item_id SERIAL PRIMARY KEY,
+			run_id INT NOT NULL REFERENCES payout_runs(run_id) ON DELETE CASCADE,
+			withdrawal_id INT NOT NULL,
+			user_id INT NOT NULL REFERENCES users(user_id),
+			amount DECIMAL(12, 2) NOT NULL,
+			bank_code VARCHAR(10),
+			account_number VARCHAR(50) NOT NULL,
+			account_name VARCHAR(200) NOT NULL,
+			reference VARCHAR(40) NOT NULL UNIQUE,       -- echoed back in the bank result file
+			status VARCHAR(20) NOT NULL DEFAULT 'processing', -- 'processing', 'completed', 'failed'
+			bank_reference VARCHAR(100),
+			failure_reason TEXT,
+			updated_at TIMESTAMPTZ DEFAULT NOW()
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_payout_run_items_run ON payout_run_items(run_id);
+		CREATE INDEX IF NOT EXISTS idx_payout_run_items_withdrawal ON payout_run_items(withdrawal_id);
+
+		ALTER TABLE withdrawals
+			ADD COLUMN IF NOT EXISTS payout_run_id INT REFERENCES payout_runs(run_id);
+	`)
+	if err != nil {
+		log.Printf("Warning: Migration 038 error: %v\n", err)
+	} else {
+		fmt.Println("✅ Migration 038: Payout Runs completed!")
+	}
+
+	// --- Migration 039: Withholding Tax & 50 Tawi Certificates ---
+	fmt.Println("🔄 Running Migration 039: Withholding Tax...")
+	_, err = dbPool.Exec(ctx, `
+		DO $$
+		BEGIN
+			IF EXISTS (SELECT 1 FROM pg_type WHERE typname = 'transaction_type') THEN
+				ALTER TYPE transaction_type ADD VALUE IF NOT EXISTS 'withholding_tax';
+			END IF;
+		END $$;
+	`)
+	if err != nil {
+		log.Printf("Warning: Could not add withholding_tax transaction type: %v\n", err)
+	}
+
+	_, err = dbPool.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS withholding_tax_rates (
+			rate_id SERIAL PRIMARY KEY,
+			name VARCHAR(100) NOT NULL,
+			taxpayer_type VARCHAR(20) NOT NULL DEFAULT 'individual', -- 'individual' (ภ.ง.ด.3), 'company' (ภ.ง.ด.53)
+			income_type VARCHAR(20) NOT NULL DEFAULT '40(8)',        -- ประเภทเงินได้ตามมาตรา 40
+			rate DECIMAL(5, 4) NOT NULL,                             -- 0.0300 = 3%
+			min_amount DECIMAL(12, 2) NOT NULL DEFAULT 1000.00,      -- ไม่หักถ้าจ่ายต่อครั้งต่ำกว่านี้
+			effective_from TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			effective_until TIMESTAMPTZ,
+			is_active BOOLEAN DEFAULT true,
+			created_at TIMESTAMPTZ DEFAULT NOW(),
+			updated_at TIMESTAMPTZ DEFAULT NOW()
+		);
+
+		INSERT INTO withholding_tax_rates (name, taxpayer_type, income_type, rate, min_amount, effective_from)
+		SELECT 'Service fee - individual', 'individual', '40(8)', 0.0300, 1000.00, '2020-01-01'
+		WHERE NOT EXISTS (SELECT 1 FROM withholding_tax_rates WHERE taxpayer_type = 'individual');
+
+		INSERT INTO withholding_tax_rates (name, taxpayer_type, income_type, rate, min_amount, effective_from)
+		SELECT 'Service fee - company', 'company', '40(8)', 0.0300, 1000.00, '2020-01-01'
+		WHERE NOT EXISTS (SELECT 1 FROM withholding_tax_rates WHERE taxpayer_type = 'company');
+
+		CREATE TABLE IF NOT EXISTS provider_tax_profiles (
+			user_id INT PRIMARY KEY REFERENCES users(user_id) ON DELETE CASCADE,
+			taxpayer_type VARCHAR(20) NOT NULL DEFAULT 'individual',
+			tax_id VARCHAR(13) NOT NULL,   -- เลขประจำตัวผู้เสียภาษี 13 หลัก
+			legal_name VARCHAR(255) NOT NULL,
+			address TEXT,
+			updated_at TIMESTAMPTZ DEFAULT NOW()
+		);
+
+		CREATE TABLE IF NOT EXISTS tax_certificates (
+			certificate_id SERIAL PRIMARY KEY,
+			certificate_no VARCHAR(30) NOT NULL UNIQUE,
+			user_id INT NOT NULL REFERENCES users(user_id),
+			tax_year INT NOT NULL,
+			sequence_no INT NOT NULL,
+			taxpayer_type VARCHAR(20) NOT NULL,
+			payee_name VARCHAR(255) NOT NULL,
+			payee_tax_id VARCHAR(13),
+			payee_address TEXT,
+			income_type VARCHAR(20) NOT NULL,
+			total_gross DECIMAL(12, 2) NOT NULL,
+			total_tax DECIMAL(12, 2) NOT NULL,
+			entry_count INT NOT NULL,
+			issued_by INT REFERENCES users(user_id),
+			issued_at TIMESTAMPTZ DEFAULT NOW(),
+			UNIQUE (user_id, tax_year),
+			UNIQUE (tax_year, sequence_no)
+		);
+
+		CREATE TABLE IF NOT EXISTS withholding_tax_entries (
+			entry_id SERIAL PRIMARY KEY,
+			user_id INT NOT NULL REFERENCES users(user_id),
+			withdrawal_id INT UNIQUE,
+			transaction_id INT,
+			rate_id INT REFERENCES withholding_tax_rates(rate_id),
+			tax_year INT NOT NULL,
+			income_type VARCHAR(20) NOT NULL,
+			gross_amount DECIMAL(12, 2) NOT NULL,
+			rate DECIMAL(5, 4) NOT NULL,
+			tax_amount DECIMAL(12, 2) NOT NULL,
+			net_paid DECIMAL(12, 2) NOT NULL,
+			paid_at TIMESTAMPTZ NOT NULL,
+			certificate_id INT REFERENCES tax_certificates(certificate_id),
+			created_at TIMESTAMPTZ DEFAULT NOW()
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_withholding_entries_user_year ON withholding_tax_entries(user_id, tax_year);
+		CREATE INDEX IF NOT EXISTS idx_withholding_entries_paid_at ON withholding_tax_entries(paid_at);
+
+		ALTER TABLE withdrawals
+			ADD COLUMN IF NOT EXISTS withholding_tax DECIMAL(12, 2),
+			ADD COLUMN IF NOT EXISTS withholding_rate_id INT REFERENCES withholding_tax_rates(rate_id);
+
+		ALTER TABLE payout_run_items
+			ADD COLUMN IF NOT EXISTS withholding_tax DECIMAL(12, 2) NOT NULL DEFAULT 0;
+	`)
+	if err != nil {
+		log.Printf("Warning: Migration 039 error: %v\n", err)
+	} else {
+		fmt.Println("✅ Migration 039: Withholding Tax completed!")
+	}
+
+	// --- Migration 040: Client Receipts / Tax Invoices ---
+	fmt.Println("🔄 Running Migration 040: Client Receipts...")
+	_, err = dbPool.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS receipts (
+			receipt_id SERIAL PRIMARY KEY,
+			receipt_no VARCHAR(30) NOT NULL UNIQUE,
+			fiscal_year INT NOT NULL,
+			sequence_no INT NOT NULL,
+			source_type VARCHAR(20) NOT NULL,
+			source_ref VARCHAR(50) NOT NULL,
+			booking_id INT REFERENCES bookings(booking_id),
+			buyer_id INT NOT NULL REFERENCES users(user_id),
+			provider_id INT REFERENCES users(user_id),
+			description TEXT NOT NULL,
+			subtotal DECIMAL(12, 2) NOT NULL,
+			discount_amount DECIMAL(12, 2) NOT NULL DEFAULT 0,
+			coupon_code VARCHAR(50),
+			vat_rate DECIMAL(5, 4) NOT NULL,
+			vat_base DECIMAL(12, 2) NOT NULL,
+			vat_amount DECIMAL(12, 2) NOT NULL,
+			total_amount DECIMAL(12, 2) NOT NULL,
+			payment_method VARCHAR(20) NOT NULL,
+			paid_at TIMESTAMPTZ NOT NULL,
+			issued_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			UNIQUE (fiscal_year, sequence_no),
+			UNIQUE (source_type, source_ref)
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_receipts_buyer ON receipts(buyer_id, issued_at DESC);
+		CREATE INDEX IF NOT EXISTS idx_receipts_booking ON receipts(booking_id);
+	`)
+	if err != nil {
+		log.Printf("Warning: Migration 040 error: %v\n", err)
+	} else {
+		fmt.Println("✅ Migration 040: Client Receipts completed!")
+	}
+
+	// --- Migration 041: Scheduled Financial Reports ---
+	fmt.Println("🔄 Running Migration 041: Scheduled Financial Reports...")
+	_, err = dbPool.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS financial_reports (
+			report_id SERIAL PRIMARY KEY,
+			report_type VARCHAR(50) NOT NULL,
+			period_start DATE NOT NULL,
+			period_end DATE NOT NULL,
+			total_bookings INTEGER DEFAULT 0,
+			total_revenue DECIMAL(12, 2) DEFAULT 0.00,
+			total_commission DECIMAL(12, 2) DEFAULT 0.00,
+			total_provider_earnings DECIMAL(12, 2) DEFAULT 0.00,
+			total_withdrawals DECIMAL(12, 2) DEFAULT 0.00,
+			total_subscriptions DECIMAL(12, 2) DEFAULT 0.00,
+			breakdown JSONB,
+			generated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			generated_by INTEGER REFERENCES users(user_id),
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		);
+
+		ALTER TABLE financial_reports
+			ADD COLUMN IF NOT EXISTS total_refunds DECIMAL(12, 2) DEFAULT 0.00,
+			ADD COLUMN IF NOT EXISTS outstanding_escrow DECIMAL(12, 2) DEFAULT 0.00,
+			ADD COLUMN IF NOT EXISTS is_scheduled BOOLEAN NOT NULL DEFAULT false;
+
+		CREATE INDEX IF NOT EXISTS idx_financial_reports_period ON financial_reports(period_start, period_end);
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_financial_reports_scheduled
+			ON financial_reports(report_type, period_start) WHERE is_scheduled;
+
+		-- รายงานที่ระบบสร้างอัตโนมัติ
+		CREATE TABLE IF NOT EXISTS financial_report_definitions (
+			report_type VARCHAR(20) PRIMARY KEY CHECK (report_type IN ('daily', 'weekly', 'monthly')),
+			is_enabled BOOLEAN NOT NULL DEFAULT true,
+			last_generated_at TIMESTAMPTZ,
+			last_period_start DATE,
+			updated_at TIMESTAMPTZ DEFAULT NOW()
+		);
+
+		INSERT INTO financial_report_definitions (report_type) VALUES ('daily'), ('weekly'), ('monthly')
+		ON CONFLICT (report_type) DO NOTHING;
+
+		-- outstanding escrow ในรายงานอ่านจากตารางนี้ (เดิมอยู่ใน migrations_escrow.sql)
+		CREATE TABLE IF NOT EXISTS escrow_payments (
+			escrow_id SERIAL PRIMARY KEY,
+			booking_id INTEGER NOT NULL REFERENCES bookings(booking_id),
+			amount DECIMAL(10, 2) NOT NULL,
+			status VARCHAR(50) NOT NULL DEFAULT 'locked',
+			locked_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			client_confirmed_at TIMESTAMP,
+			provider_completed_at TIMESTAMP,
+			released_at TIMESTAMP,
+			dispute_reason TEXT,
+			disputed_at TIMESTAMP,
+			admin_decision VARCHAR(50),
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			CONSTRAINT valid_status CHECK (status IN (
+				'locked', 'released', 'refunded', 'disputed', 'partially_refunded'
+			))
+		);
+	`)
+	if err != nil {
+		log.Printf("Warning: Migration 041 error: %v\n", err)
+	} else {
+		fmt.Println("✅ Migration 041: Scheduled Financial Reports completed!")
+	}
+
+	// --- Migration 042: Nightly Reconciliation ---
+	fmt.Println("🔄 Running Migration 042: Reconciliation...")
+	_, err = dbPool.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS reconciliation_runs (
+			run_id SERIAL PRIMARY KEY,
+			trigger VARCHAR(20) NOT NULL DEFAULT 'scheduled',
+			status VARCHAR(20) NOT NULL DEFAULT 'running' CHECK (status IN ('running', 'completed', 'failed')),
+			wallets_checked INT NOT NULL DEFAULT 0,
+			escrows_checked INT NOT NULL DEFAULT 0,
+			payments_checked INT NOT NULL DEFAULT 0,
+			discrepancy_count INT NOT NULL DEFAULT 0,
+			new_count INT NOT NULL DEFAULT 0,
+			resolved_count INT NOT NULL DEFAULT 0,
+			error_message TEXT,
+			started_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			finished_at TIMESTAMPTZ
+		);
+
+		CREATE TABLE IF NOT EXISTS reconciliation_discrepancies (
+			discrepancy_id SERIAL PRIMARY KEY,
+			first_run_id INT NOT NULL REFERENCES reconciliation_runs(run_id),
+			last_run_id INT NOT NULL REFERENCES reconciliation_runs(run_id),
+			check_type VARCHAR(20) NOT NULL CHECK (check_type IN ('wallet', 'escrow', 'payment')),
+			code VARCHAR(50) NOT NULL,
+			entity_type VARCHAR(20) NOT NULL,
+			entity_id INT NOT NULL,
+			user_id INT REFERENCES users(user_id) ON DELETE SET NULL,
+			booking_id INT,
+			expected_amount DECIMAL(12, 2),
+			actual_amount DECIMAL(12, 2),
+			difference DECIMAL(12, 2),
+			details JSONB,
+			status VARCHAR(20) NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'resolved', 'ignored')),
+			resolution_notes TEXT,
+			resolved_by INT REFERENCES users(user_id),
+			resolved_at TIMESTAMPTZ,
+			first_seen_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			last_seen_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		);
+
+		-- เคสเปิดได้ครั้งละหนึ่งเคสต่อปัญหา/รายการ
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_reconciliation_discrepancies_open
+			ON reconciliation_discrepancies(code, entity_type, entity_id) WHERE status = 'open';
+		CREATE INDEX IF NOT EXISTS idx_reconciliation_discrepancies_status
+			ON reconciliation_discrepancies(status, check_type);
+	`)
+	if err != nil {
+		log.Printf("Warning: Migration 042 error: %v\n", err)
+	} else {
+		fmt.Println("✅ Migration 042: Reconciliation completed!")
+	}
+
+	// --- Migration 043: Escrow Auto-Release ---
+	fmt.Println("🔄 Running Migration 043: Escrow Auto-Release...")
+	_, err = dbPool.Exec(ctx, `
+		DO $$
+		BEGIN
+			IF EXISTS (SELECT 1 FROM pg_type WHERE typname = 'transaction_type') THEN
+				ALTER TYPE transaction_type ADD VALUE IF NOT EXISTS 'escrow_release';
+			END IF;
+		END $$;
+	`)
+	if err != nil {
+		log.Printf("Warning: Migration 043 (transaction_type) error: %v\n", err)
+	}
+
+	_, err = dbPool.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS escrow_release_rules (
+			rule_id SERIAL PRIMARY KEY,
+			name VARCHAR(100) NOT NULL,
+			confirmation_window_hours INT NOT NULL CHECK (confirmation_window_hours > 0),
+			reminder_interval_hours INT NOT NULL DEFAULT 0 CHECK (reminder_interval_hours >= 0),
+			min_amount DECIMAL(10, 2) NOT NULL DEFAULT 0,
+			max_amount DECIMAL(10, 2),
+			priority INT NOT NULL DEFAULT 100,
+			is_active BOOLEAN NOT NULL DEFAULT true,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		);
+
+		INSERT INTO escrow_release_rules (name, confirmation_window_hours, reminder_interval_hours)
+		SELECT 'Default', 24, 8
+		WHERE NOT EXISTS (SELECT 1 FROM escrow_release_rules);
+
+		-- คอลัมน์ escrow เดิมอยู่ใน migrations_escrow.sql
+		ALTER TABLE bookings
+		ADD COLUMN IF NOT EXISTS remaining_amount DECIMAL(10, 2) DEFAULT 0,
+		ADD COLUMN IF NOT EXISTS escrow_locked BOOLEAN DEFAULT false,
+		ADD COLUMN IF NOT EXISTS provider_arrived_at TIMESTAMP,
+		ADD COLUMN IF NOT EXISTS client_confirmed_arrival_at TIMESTAMP,
+		ADD COLUMN IF NOT EXISTS provider_completed_at TIMESTAMP,
+		ADD COLUMN IF NOT EXISTS provider_completion_notes TEXT,
+		ADD COLUMN IF NOT EXISTS client_confirmed_at TIMESTAMP,
+		ADD COLUMN IF NOT EXISTS dispute_reason TEXT,
+		ADD COLUMN IF NOT EXISTS dispute_description TEXT,
+		ADD COLUMN IF NOT EXISTS disputed_at TIMESTAMP,
+		ADD COLUMN IF NOT EXISTS admin_decision VARCHAR(50),
+		ADD COLUMN IF NOT EXISTS admin_decision_notes TEXT,
+		ADD COLUMN IF NOT EXISTS resolved_by_admin_id INTEGER REFERENCES users(user_id),
+		ADD COLUMN IF NOT EXISTS resolved_at TIMESTAMP,
+		ADD COLUMN IF NOT EXISTS escrow_release_rule_id INTEGER REFERENCES escrow_release_rules(rule_id),
+		ADD COLUMN IF NOT EXISTS escrow_confirm_deadline TIMESTAMPTZ,
+		ADD COLUMN IF NOT EXISTS escrow_reminders_sent INT NOT NULL DEFAULT 0,
+		ADD COLUMN IF NOT EXISTS escrow_last_reminder_at TIMESTAMPTZ,
+		ADD COLUMN IF NOT EXISTS escrow_released_by VARCHAR(20),
+		ADD COLUMN IF NOT EXISTS escrow_auto_released BOOLEAN NOT NULL DEFAULT false,
+		ADD COLUMN IF NOT EXISTS escrow_released_at TIMESTAMPTZ;
+
+		CREATE INDEX IF NOT EXISTS idx_bookings_escrow_confirm_deadline
+			ON bookings(escrow_confirm_deadline) WHERE status = 'completed';
+	`)
+	if err != nil {
+		log.Printf("Warning: Migration 043 error: %v\n", err)
+	} else {
+		fmt.Println("✅ Migration 043: Escrow Auto-Release completed!")
+	}
+
+	// --- Migration 044: Dispute Cases ---
+	fmt.Println("🔄 Running Migration 044: Dispute Cases...")
+	_, err = dbPool.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS dispute_cases (
+			case_id SERIAL PRIMARY KEY,
+			booking_id INT NOT NULL REFERENCES bookings(booking_id),
+			client_id INT NOT NULL REFERENCES users(user_id),
+			provider_id INT NOT NULL REFERENCES users(user_id),
+			opened_by INT NOT NULL REFERENCES users(user_id),
+			reason TEXT NOT NULL,
+			description TEXT NOT NULL,
+			status VARCHAR(20) NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'resolved')),
+			disputed_amount DECIMAL(10, 2) NOT NULL DEFAULT 0,
+			client_response_due_at TIMESTAMPTZ,
+			client_responded_at TIMESTAMPTZ,
+			client_deadline_missed_at TIMESTAMPTZ,
+			provider_response_due_at TIMESTAMPTZ,
+			provider_responded_at TIMESTAMPTZ,
+			provider_deadline_missed_at TIMESTAMPTZ,
+			outcome VARCHAR(20) CHECK (outcome IN ('refund_client', 'pay_provider', 'split')),
+			refund_amount DECIMAL(10, 2),
+			provider_amount DECIMAL(10, 2),
+			outcome_notes TEXT,
+			resolved_by INT REFERENCES users(user_id),
+			resolved_at TIMESTAMPTZ,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		);
+
+		-- เปิดได้ครั้งละหนึ่งเคสต่อ booking
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_dispute_cases_open_booking
+			ON dispute_cases(booking_id) WHERE status = 'open';
+		CREATE INDEX IF NOT EXISTS idx_dispute_cases_client ON dispute_cases(client_id);
+		CREATE INDEX IF NOT EXISTS idx_dispute_cases_provider ON dispute_cases(provider_id);
+
+		CREATE TABLE IF NOT EXISTS dispute_events (
+			event_id SERIAL PRIMARY KEY,
+			case_id INT NOT NULL REFERENCES dispute_cases(case_id) ON DELETE CASCADE,
+			actor_id INT REFERENCES users(user_id),
+			event_type VARCHAR(30) NOT NULL,
+			details JSONB,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		);
+		CREATE INDEX IF NOT EXISTS idx_dispute_events_case ON dispute_events(case_id);
+
+		CREATE TABLE IF NOT EXISTS dispute_evidence (
+			evidence_id SERIAL PRIMARY KEY,
+			case_id INT NOT NULL REFERENCES dispute_cases(case_id) ON DELETE CASCADE,
+			submitted_by INT NOT NULL REFERENCES users(user_id),
+			party VARCHAR(10) NOT NULL CHECK (party IN ('client', 'provider', 'admin')),
+			evidence_type VARCHAR(20) NOT NULL CHECK (evidence_type IN ('photo', 'document', 'chat_excerpt', 'check_in', 'note')),
+			description TEXT,
+			file_url TEXT,
+			content_type VARCHAR(100),
+			message_ids INT[],
+			check_in_id INT,
+			snapshot JSONB, -- สำเนาข้อความแชท/check-in ณ เวลาที่ส่งหลักฐาน
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		);
+		CREATE INDEX IF NOT EXISTS idx_dispute_evidence_case ON dispute_evidence(case_id);
+
+		CREATE TABLE IF NOT EXISTS dispute_messages (
+			message_id SERIAL PRIMARY KEY,
+			case_id INT NOT NULL REFERENCES dispute_cases(case_id) ON DELETE CASCADE,
+			sender_id INT NOT NULL REFERENCES users(user_id),
+			sender_party VARCHAR(10) NOT NULL CHECK (sender_party IN ('client', 'provider', 'admin')),
+			body TEXT NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		);
+		CREATE INDEX IF NOT EXISTS idx_dispute_messages_case ON dispute_messages(case_id);
+	`)
+	if err != nil {
+		log.Printf("Warning: Migration 044 error: %v\n", err)
+	} else {
+		fmt.Println("✅ Migration 044: Dispute Cases completed!")
+	}
+
+	// --- Migration 045: Cancellation Fee Settlement ---
+	fmt.Println("🔄 Running Migration 045: Cancellation Fee Settlement...")
+	_, err = dbPool.Exec(ctx, `
+		DO $$
+		BEGIN
+			IF EXISTS (SELECT 1 FROM pg_type WHERE typname = 'transaction_type') THEN
+				ALTER TYPE transaction_type ADD VALUE IF NOT EXISTS 'cancellation_fee';
+			END IF;
+		END $$;
+	`)
+	if err != nil {
+		log.Printf("Warning: Migration 045 (transaction_type) error: %v\n", err)
+	}
+
+	_, err = dbPool.Exec(ctx, `
+		-- ตารางเดิมอยู่ใน docs/sql-migrations/034_safety_business_features.sql
+		CREATE TABLE IF NOT EXISTS booking_deposits (
+			deposit_id SERIAL PRIMARY KEY,
+			booking_id INT NOT NULL REFERENCES bookings(booking_id) ON DELETE CASCADE UNIQUE,
+			client_id INT NOT NULL REFERENCES users(user_id),
+			provider_id INT NOT NULL REFERENCES users(user_id),
+			amount DECIMAL(10, 2) NOT NULL,
+			percentage DECIMAL(3, 2) NOT NULL,
+			status VARCHAR(20) DEFAULT 'pending' CHECK (status IN ('pending', 'paid', 'refunded', 'forfeited')),
+			paid_at TIMESTAMPTZ,
+			refunded_at TIMESTAMPTZ,
+			forfeited_at TIMESTAMPTZ,
+			payment_intent_id VARCHAR(255),
+			created_at TIMESTAMPTZ DEFAULT NOW(),
+			updated_at TIMESTAMPTZ DEFAULT NOW()
+		);
+
+		CREATE TABLE IF NOT EXISTS cancellation_fees (
+			fee_id SERIAL PRIMARY KEY,
+			booking_id INT NOT NULL REFERENCES bookings(booking_id) ON DELETE CASCADE,
+			cancelled_by INT NOT NULL REFERENCES users(user_id),
+			fee_amount DECIMAL(10, 2) NOT NULL,
+			fee_percentage DECIMAL(3, 2) NOT NULL,
+			status VARCHAR(20) DEFAULT 'pending' CHECK (status IN ('pending', 'paid', 'waived')),
+			paid_at TIMESTAMPTZ,
+			waived_at TIMESTAMPTZ,
+			waived_by INT REFERENCES users(user_id),
+			waiver_reason TEXT,
+			created_at TIMESTAMPTZ DEFAULT NOW()
+		);
+
+		ALTER TABLE cancellation_fees
+			ADD COLUMN IF NOT EXISTS client_id INT REFERENCES users(user_id),
+			ADD COLUMN IF NOT EXISTS provider_id INT REFERENCES users(user_id),
+			ADD COLUMN IF NOT EXISTS amount_paid DECIMAL(10, 2) NOT NULL DEFAULT 0,
+			ADD COLUMN IF NOT EXISTS provider_credited DECIMAL(10, 2) NOT NULL DEFAULT 0,
+			ADD COLUMN IF NOT EXISTS platform_commission DECIMAL(10, 2) NOT NULL DEFAULT 0,
+			ADD COLUMN IF NOT EXISTS last_charge_error TEXT;
+
+		-- แถวเก่า: client คือผู้ยกเลิก
+		UPDATE cancellation_fees cf
+		SET client_id = b.client_id, provider_id = b.provider_id
+		FROM bookings b
+		WHERE b.booking_id = cf.booking_id AND cf.client_id IS NULL;
+
+		CREATE INDEX IF NOT EXISTS idx_cancellation_fees_client_pending
+			ON cancellation_fees(client_id) WHERE status = 'pending';
+
+		-- ประวัติการเก็บเงิน (reference กันบันทึกซ้ำจาก webhook)
+		CREATE TABLE IF NOT EXISTS cancellation_fee_payments (
+			payment_id SERIAL PRIMARY KEY,
+			fee_id INT NOT NULL REFERENCES cancellation_fees(fee_id) ON DELETE CASCADE,
+			method VARCHAR(20) NOT NULL CHECK (method IN ('deposit', 'saved_card', 'stripe_checkout')),
+			amount DECIMAL(10, 2) NOT NULL,
+			reference VARCHAR(255) NOT NULL UNIQUE,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		);
+	`)
+	if err != nil {
+		log.Printf("Warning: Migration 045 error: %v\n", err)
+	} else {
+		fmt.Println("✅ Migration 045: Cancellation Fee Settlement completed!")
+	}
+
+	// --- Migration 046: Wallet Top-ups & Wallet Payments ---
+	fmt.Println("🔄 Running Migration 046: Wallet Top-ups & Wallet Payments...")
+	_, err = dbPool.Exec(ctx, `
+		DO $$
+		BEGIN
+			IF EXISTS (SELECT 1 FROM pg_type WHERE typname = 'transaction_type') THEN
+				ALTER TYPE transaction_type ADD VALUE IF NOT EXISTS 'wallet_topup';
+				ALTER TYPE transaction_type ADD VALUE IF NOT EXISTS 'wallet_payment';
+				ALTER TYPE transaction_type ADD VALUE IF NOT EXISTS 'wallet_refund';
+			END IF;
+		END $$;
+	`)
+	if err != nil {
+		log.Printf("Warning: Migration 046 (transaction_type) error: %v\n", err)
+	}
+
+	_, err = dbPool.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS wallet_topups (
+			topup_id SERIAL PRIMARY KEY,
+			user_id INT NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+			amount DECIMAL(10, 2) NOT NULL CHECK (amount > 0),
+			method VARCHAR(20) NOT NULL CHECK (method IN ('stripe', 'promptpay')),
+			status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'completed', 'expired', 'failed')),
+			payment_reference VARCHAR(100) UNIQUE,
+			stripe_session_id VARCHAR(255) UNIQUE,
+			completed_at TIMESTAMPTZ,
+			created_at TIMESTAMPTZ DEFAULT NOW(),
+			updated_at TIMESTAMPTZ DEFAULT NOW()
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_wallet_topups_user ON wallet_topups(user_id, created_at DESC);
+
+		-- payments ใช้กับ QR เติมเงินได้ด้วย (ไม่มี booking)
+		ALTER TABLE payments ALTER COLUMN booking_id DROP NOT NULL;
+		ALTER TABLE payments ADD COLUMN IF NOT EXISTS topup_id INT REFERENCES wallet_topups(topup_id) ON DELETE CASCADE;
+		ALTER TABLE payments ADD COLUMN IF NOT EXISTS wallet_hold_id INT;
+		ALTER TABLE payments ADD COLUMN IF NOT EXISTS wallet_amount DECIMAL(10, 2) DEFAULT 0;
+
+		CREATE INDEX IF NOT EXISTS idx_payments_topup ON payments(topup_id) WHERE topup_id IS NOT NULL;
+		CREATE INDEX IF NOT EXISTS idx_payments_pending_expiry ON payments(expires_at) WHERE payment_status = 'pending';
+	`)
+	if err != nil {
+		log.Printf("Warning: Migration 046 error: %v\n", err)
+	} else {
+		fmt.Println("✅ Migration 046: Wallet Top-ups & Wallet Payments completed!")
+	}
+
+	// --- Migration 047: Tips ---
+	fmt.Println("🔄 Running Migration 047: Tips...")
+	_, err = dbPool.Exec(ctx, `
+		DO $$
+		BEGIN
+			IF EXISTS (SELECT 1 FROM pg_type WHERE typname = 'transaction_type') THEN
+				ALTER TYPE transaction_type ADD VALUE IF NOT EXISTS 'tip';
+			END IF;
+		END $$;
+	`)
+	if err != nil {
+		log.Printf("Warning: Migration 047 (transaction_type) error: %v\n", err)
+	}
+
+	_, err = dbPool.Exec(ctx, `
+		-- กฎค่าคอมมิชชั่นแยกตามประเภทรายได้ (booking / tip)
+		ALTER TABLE commission_rules ADD COLUMN IF NOT EXISTS applies_to VARCHAR(30) DEFAULT 'booking';
+
+		-- ทิปไม่หักค่าคอมมิชชั่น (effective_from ย้อนหลังเพื่อไม่ให้แซงกฎ booking ที่ใช้อยู่)
+		INSERT INTO commission_rules (name, description, platform_rate, payment_gateway_rate, applies_to, effective_from)
+		SELECT 'Tips', 'Tips go to the provider in full', 0, 0, 'tip', DATE '2020-01-01'
+		WHERE NOT EXISTS (SELECT 1 FROM commission_rules WHERE applies_to = 'tip');
+
+		CREATE TABLE IF NOT EXISTS booking_tips (
+			tip_id SERIAL PRIMARY KEY,
+			booking_id INT NOT NULL REFERENCES bookings(booking_id) ON DELETE CASCADE,
+			client_id INT NOT NULL REFERENCES users(user_id),
+			provider_id INT NOT NULL REFERENCES users(user_id),
+			amount DECIMAL(10, 2) NOT NULL CHECK (amount > 0),
+			message TEXT,
+			payment_method VARCHAR(20) NOT NULL CHECK (payment_method IN ('card', 'promptpay', 'wallet')),
+			status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'paid', 'expired', 'failed')),
+			platform_commission DECIMAL(10, 2) DEFAULT 0,
+			gateway_fee DECIMAL(10, 2) DEFAULT 0,
+			provider_net DECIMAL(10, 2) DEFAULT 0,
+			payment_reference VARCHAR(100) UNIQUE,
+			paid_at TIMESTAMPTZ,
+			created_at TIMESTAMPTZ DEFAULT NOW(),
+			updated_at TIMESTAMPTZ DEFAULT NOW()
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_booking_tips_booking ON booking_tips(booking_id);
+		CREATE INDEX IF NOT EXISTS idx_booking_tips_provider ON booking_tips(provider_id, paid_at DESC) WHERE status = 'paid';
+
+		-- payments ใช้กับ QR ทิปได้ด้วย
+		ALTER TABLE payments ADD COLUMN IF NOT EXISTS tip_id INT REFERENCES booking_tips(tip_id) ON DELETE CASCADE;
+		CREATE INDEX IF NOT EXISTS idx_payments_tip ON payments(tip_id) WHERE tip_id IS NOT NULL;
+	`)
+	if err != nil {
+		log.Printf("Warning: Migration 047 error: %v\n", err)
+	} else {
+		fmt.Println("✅ Migration 047: Tips completed!")
+	}
+
+	// --- Migration 048: Promotion Campaigns ---
+	fmt.Println("🔄 Running Migration 048: Promotion Campaigns...")
+	_, err = dbPool.Exec(ctx, `
+		DO $$
+		BEGIN
+			IF EXISTS (SELECT 1 FROM pg_type WHERE typname = 'transaction_type') THEN
+				ALTER TYPE transaction_type ADD VALUE IF NOT EXISTS 'campaign_subsidy';
+			END IF;
+		END $$;
+	`)
+	if err != nil {
+		log.Printf("Warning: Migration 048 (transaction_type) error: %v\n", err)
+	}
+
+	_, err = dbPool.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS promotion_campaigns (
+			campaign_id SERIAL PRIMARY KEY,
+			name VARCHAR(200) NOT NULL,
+			description TEXT,
+			code VARCHAR(50) UNIQUE,
+			discount_type VARCHAR(20) NOT NULL CHECK (discount_type IN ('percentage', 'fixed')),
+			discount_value DECIMAL(10, 2) NOT NULL CHECK (discount_value > 0),
+			max_discount DECIMAL(10, 2),
+			min_booking_amount DECIMAL(10, 2),
+			funding_source VARCHAR(20) NOT NULL DEFAULT 'platform' CHECK (funding_source IN ('platform', 'provider')),
+			provider_id INT REFERENCES users(user_id) ON DELETE CASCADE,
+			total_budget DECIMAL(12, 2),
+			budget_used DECIMAL(12, 2) NOT NULL DEFAULT 0,
+			per_user_limit INT NOT NULL DEFAULT 1,
+			redemption_count INT NOT NULL DEFAULT 0,
+			first_booking_only BOOLEAN NOT NULL DEFAULT false,
+			category_ids INT[],
+			tier_ids INT[],
+			provinces TEXT[],
+			days_of_week INT[],
+			starts_at TIMESTAMPTZ NOT NULL,
+			ends_at TIMESTAMPTZ NOT NULL,
+			is_active BOOLEAN NOT NULL DEFAULT true,
+			created_by INT NOT NULL REFERENCES users(user_id),
+			created_at TIMESTAMPTZ DEFAULT NOW(),
+			updated_at TIMESTAMPTZ DEFAULT NOW(),
+			CHECK (total_budget IS NULL OR budget_used <= total_budget),
+			CHECK (funding_source = 'platform' OR provider_id IS NOT NULL)
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_promotion_campaigns_active ON promotion_campaigns(starts_at, ends_at) WHERE is_active = true;
+
+		CREATE TABLE IF NOT EXISTS campaign_redemptions (
+			redemption_id SERIAL PRIMARY KEY,
+			campaign_id INT NOT NULL REFERENCES promotion_campaigns(campaign_id) ON DELETE CASCADE,
+			user_id INT NOT NULL REFERENCES users(user_id),
+			booking_id INT NOT NULL REFERENCES bookings(booking_id) ON DELETE CASCADE,
+			provider_id INT NOT NULL REFERENCES users(user_id),
+			original_amount DECIMAL(10, 2) NOT NULL,
+			discount_amount DECIMAL(10, 2) NOT NULL CHECK (discount_amount > 0),
+			funding_source VARCHAR(20) NOT NULL,
+			status VARCHAR(20) NOT NULL DEFAULT 'applied' CHECK (status IN ('applied', 'settled', 'released')),
+			settled_at TIMESTAMPTZ,
+			released_at TIMESTAMPTZ,
+			created_at TIMESTAMPTZ DEFAULT NOW()
+		);
+
+		-- แคมเปญเดียวต่อ booking (ไม่นับที่คืนงบไปแล้ว)
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_campaign_redemptions_booking ON campaign_redemptions(booking_id) WHERE status <> 'released';
+		CREATE INDEX IF NOT EXISTS idx_campaign_redemptions_campaign ON campaign_redemptions(campaign_id, user_id);
+		CREATE INDEX IF NOT EXISTS idx_campaign_redemptions_applied ON campaign_redemptions(status) WHERE status = 'applied';
+	`)
+	if err != nil {
+		log.Printf("Warning: Migration 048 error: %v\n", err)
+	} else {
+		fmt.Println("✅ Migration 048: Promotion Campaigns completed!")
+	}
+
+	// --- Migration 049: Atomic Coupon Redemption ---
+	fmt.Println("🔄 Running Migration 049: Atomic Coupon Redemption...")
+	_, err = dbPool.Exec(ctx, `
+		ALTER TABLE coupons ADD COLUMN IF NOT EXISTS stackable BOOLEAN DEFAULT false;
+
+		ALTER TABLE coupon_usages ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'applied';
+		ALTER TABLE coupon_usages ADD COLUMN IF NOT EXISTS released_at TIMESTAMPTZ;
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_coupon_usages_booking_coupon ON coupon_usages(booking_id, coupon_id) WHERE status = 'applied';
+		CREATE INDEX IF NOT EXISTS idx_coupon_usages_applied ON coupon_usages(booking_id) WHERE status = 'applied';
+
+		-- ราคาก่อนลด + ส่วนลดรวม (total_price = ยอดที่ลูกค้าจ่ายจริง)
+		ALTER TABLE bookings ADD COLUMN IF NOT EXISTS original_price DECIMAL(10, 2);
+		ALTER TABLE bookings ADD COLUMN IF NOT EXISTS discount_amount DECIMAL(10, 2) DEFAULT 0;
+
+		-- booking เก่าที่ใช้คูปองไปแล้ว
+		UPDATE bookings b
+		SET original_price = b.total_price + u.total, discount_amount = u.total
+		FROM (SELECT booking_id, SUM(discount_amount) AS total FROM coupon_usages GROUP BY booking_id) u
+		WHERE b.booking_id = u.booking_id AND b.original_price IS NULL;
+	`)
+	if err != nil {
+		log.Printf("Warning: Migration 049 error: %v\n", err)
+	} else {
+		fmt.Println("✅ Migration 049: Atomic Coupon Redemption completed!")
+	}
+
+	// --- Migration 050: Referral Program ---
+	fmt.Println("🔄 Running Migration 050: Referral Program...")
+	_, err = dbPool.Exec(ctx, `
+		DO $$
+		BEGIN
+			IF EXISTS (SELECT 1 FROM pg_type WHERE typname = 'transaction_type') THEN
+				ALTER TYPE transaction_type ADD VALUE IF NOT EXISTS 'referral_reward';
+			END IF;
+		END $$;
+	`)
+	if err != nil {
+		log.Printf("Warning: Migration 050 (transaction_type) error: %v\n", err)
+	}
+
+	_, err = dbPool.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS referral_codes (
+			user_id INT PRIMARY KEY REFERENCES users(user_id) ON DELETE CASCADE,
+			code VARCHAR(20) NOT NULL UNIQUE,
+			created_at TIMESTAMPTZ DEFAULT NOW()
+		);
+
+		-- IP / device ตอนสมัคร ใช้ตรวจ referral ทุจริต
+		CREATE TABLE IF NOT EXISTS user_signup_fingerprints (
+			user_id INT PRIMARY KEY REFERENCES users(user_id) ON DELETE CASCADE,
+			ip_address VARCHAR(64),
+			device_id VARCHAR(255),
+			created_at TIMESTAMPTZ DEFAULT NOW()
+		);
+		CREATE INDEX IF NOT EXISTS idx_signup_fingerprints_ip ON user_signup_fingerprints(ip_address, created_at);
+		CREATE INDEX IF NOT EXISTS idx_signup_fingerprints_device ON user_signup_fingerprints(device_id);
+
+		CREATE TABLE IF NOT EXISTS referrals (
+			referral_id SERIAL PRIMARY KEY,
+			referrer_id INT NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+			referred_id INT NOT NULL UNIQUE REFERENCES users(user_id) ON DELETE CASCADE,
+			code VARCHAR(20) NOT NULL,
+			referred_role VARCHAR(20) NOT NULL CHECK (referred_role IN ('client', 'provider')),
+			status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'flagged', 'rewarded', 'rejected')),
+			fraud_reasons TEXT[] NOT NULL DEFAULT '{}',
+			signup_ip VARCHAR(64),
+			device_id VARCHAR(255),
+			referrer_reward DECIMAL(10, 2) NOT NULL DEFAULT 0,
+			referred_reward DECIMAL(10, 2) NOT NULL DEFAULT 0,
+			reward_coupon_id INT REFERENCES coupons(coupon_id) ON DELETE SET NULL,
+			qualified_at TIMESTAMPTZ,
+			rewarded_at TIMESTAMPTZ,
+			reviewed_by INT REFERENCES users(user_id),
+			reviewed_at TIMESTAMPTZ,
+			review_notes TEXT,
+			created_at TIMESTAMPTZ DEFAULT NOW(),
+			updated_at TIMESTAMPTZ DEFAULT NOW(),
+			CHECK (referrer_id <> referred_id)
+		);
+		CREATE INDEX IF NOT EXISTS idx_referrals_referrer ON referrals(referrer_id, created_at DESC);
+		CREATE INDEX IF NOT EXISTS idx_referrals_status ON referrals(status) WHERE status IN ('pending', 'flagged');
+
+		-- คูปองส่วนตัว (ใช้ได้เฉพาะ user นี้)
+		ALTER TABLE coupons ADD COLUMN IF NOT EXISTS assigned_user_id INT REFERENCES users(user_id) ON DELETE CASCADE;
+	`)
+	if err != nil {
+		log.Printf("Warning: Migration 050 error: %v\n", err)
+	} else {
+		fmt.Println("✅ Migration 050: Referral Program completed!")
+	}
+
+	// --- Migration 051: Price Quotes ---
+	fmt.Println("🔄 Running Migration 051: Price Quotes...")
+	_, err = dbPool.Exec(ctx, `
+		-- ใบเสนอราคาจาก pricing engine (checkout ต้องจ่ายตามยอดนี้, ใช้ได้ครั้งเดียว)
+		CREATE TABLE IF NOT EXISTS price_quotes (
+			quote_id VARCHAR(40) PRIMARY KEY,
+			kind VARCHAR(20) NOT NULL CHECK (kind IN ('booking', 'extension')),
+			user_id INT NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+			provider_id INT NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+			package_id INT NOT NULL REFERENCES service_packages(package_id),
+			booking_id INT REFERENCES bookings(booking_id) ON DELETE CASCADE,
+			start_time TIMESTAMPTZ,
+			end_time TIMESTAMPTZ,
+			extension_minutes INT NOT NULL DEFAULT 0,
+			coupon_code VARCHAR(50),
+			breakdown JSONB NOT NULL,
+			total DECIMAL(10, 2) NOT NULL,
+			deposit_amount DECIMAL(10, 2) NOT NULL DEFAULT 0,
+			expires_at TIMESTAMPTZ NOT NULL,
+			used_at TIMESTAMPTZ,
+			created_at TIMESTAMPTZ DEFAULT NOW()
+		);
+		CREATE INDEX IF NOT EXISTS idx_price_quotes_user ON price_quotes(user_id, created_at DESC);
+
+		ALTER TABLE bookings ADD COLUMN IF NOT EXISTS quote_id VARCHAR(40) REFERENCES price_quotes(quote_id) ON DELETE SET NULL;
+	`)
+	if err != nil {
+		log.Printf("Warning: Migration 051 error: %v\n", err)
+	} else {
+		fmt.Println("✅ Migration 051: Price Quotes completed!")
+	}
+
+	// --- Migration 052: Dynamic Pricing Rules ---
+	fmt.Println("🔄 Running Migration 052: Dynamic Pricing Rules...")
+	_, err = dbPool.Exec(ctx, `
+		-- ปฏิทินวันหยุดไทย (admin จัดการ) ใช้กับกฎราคา holiday
+		CREATE TABLE IF NOT EXISTS thai_holidays (
+			holiday_date DATE PRIMARY KEY,
+			name VARCHAR(100) NOT NULL,
+			name_th VARCHAR(100),
+			created_by INT REFERENCES users(user_id) ON DELETE SET NULL,
+			created_at TIMESTAMPTZ DEFAULT NOW()
+		);
+
+		INSERT INTO thai_holidays (holiday_date, name, name_th) VALUES
+			('2026-01-01', 'New Year''s Day', 'วันขึ้นปีใหม่'),
+			('2026-04-06', 'Chakri Memorial Day', 'วันจักรี'),
+			('2026-04-13', 'Songkran Festival', 'วันสงกรานต์'),
+			('2026-04-14', 'Songkran Festival', 'วันสงกรานต์'),
+			('2026-04-15', 'Songkran Festival', 'วันสงกรานต์'),
+			('2026-05-01', 'National Labour Day', 'วันแรงงานแห่งชาติ'),
+			('2026-05-04', 'Coronation Day', 'วันฉัตรมงคล'),
+			('2026-06-03', 'Queen Suthida''s Birthday', 'วันเฉลิมพระชนมพรรษาพระราชินี'),
+			('2026-07-28', 'King Vajiralongkorn''s Birthday', 'วันเฉลิมพระชนมพรรษา ร.10'),
+			('2026-08-12', 'Mother''s Day', 'วันแม่แห่งชาติ'),
+			('2026-10-13', 'King Bhumibol Memorial Day', 'วันนวมินทรมหาราช'),
+			('2026-10-23', 'Chulalongkorn Day', 'วันปิยมหาราช'),
+			('2026-12-05', 'Father''s Day', 'วันพ่อแห่งชาติ'),
+			('2026-12-10', 'Constitution Day', 'วันรัฐธรรมนูญ'),
+			('2026-12-31', 'New Year''s Eve', 'วันสิ้นปี')
+		ON CONFLICT (holiday_date) DO NOTHING;
+
+		-- กฎราคา dynamic ต่อแพ็คเกจ (ปรับเป็น % ของราคาแพ็คเกจ)
+		CREATE TABLE IF NOT EXISTS package_pricing_rules (
+			rule_id SERIAL PRIMARY KEY,
+			package_id INT NOT NULL REFERENCES service_packages(package_id) ON DELETE CASCADE,
+			provider_id INT NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+			rule_type VARCHAR(20) NOT NULL CHECK (rule_type IN ('time_of_day', 'day_of_week', 'holiday', 'last_minute', 'min_notice')),
+			label VARCHAR(100),
+			adjustment_percent DECIMAL(6, 2) NOT NULL CHECK (adjustment_percent BETWEEN -90 AND 300),
+			days_of_week INT[] NOT NULL DEFAULT '{}',
+			start_time TIME,
+			end_time TIME,
+			notice_hours INT,
+			is_active BOOLEAN NOT NULL DEFAULT true,
+			created_at TIMESTAMPTZ DEFAULT NOW(),
+			updated_at TIMESTAMPTZ DEFAULT NOW()
+		);
+		CREATE INDEX IF NOT EXISTS idx_pricing_rules_package ON package_pricing_rules(package_id) WHERE is_active = true;
+	`)
+	if err != nil {
+		log.Printf("Warning: Migration 052 error: %v\n", err)
+	} else {
+		fmt.Println("✅ Migration 052: Dynamic Pricing Rules completed!")
+	}
+
+	// --- Migration 053: Booking Snapshots ---
+	fmt.Println("🔄 Running Migration 053: Booking Snapshots...")
+	_, err = dbPool.Exec(ctx, `
+		-- ข้อมูลแพ็คเกจ/ราคา/นโยบาย ณ เวลาที่จอง (แก้ไขไม่ได้)
+		CREATE TABLE IF NOT EXISTS booking_snapshots (
+			booking_id INT PRIMARY KEY REFERENCES bookings(booking_id) ON DELETE CASCADE,
+			package_name VARCHAR(100) NOT NULL,
+			package_duration INT NOT NULL,
+			package_price DECIMAL(10, 2) NOT NULL,
+			price_breakdown JSONB,
+			cancellation_tiers JSONB NOT NULL DEFAULT '[]',
+			require_deposit BOOLEAN NOT NULL DEFAULT false,
+			deposit_percentage DECIMAL(5, 2) NOT NULL DEFAULT 0.30,
+			platform_commission_rate DECIMAL(5, 4) NOT NULL,
+			gateway_fee_rate DECIMAL(5, 4) NOT NULL,
+			created_at TIMESTAMPTZ DEFAULT NOW()
+		);
+
+		CREATE OR REPLACE FUNCTION prevent_booking_snapshot_update() RETURNS trigger AS $$
+		BEGIN
+			RAISE EXCEPTION 'booking snapshots are immutable (booking %)', OLD.booking_id;
+		END;
+		$$ LANGUAGE plpgsql;
+
+		DROP TRIGGER IF EXISTS trg_booking_snapshots_immutable ON booking_snapshots;
+		CREATE TRIGGER trg_booking_snapshots_immutable
+			BEFORE UPDATE ON booking_snapshots
+			FOR EACH ROW EXECUTE FUNCTION prevent_booking_snapshot_update();
+
+		-- booking เดิม: ใช้ข้อมูลปัจจุบันเท่าที่มี (ดีที่สุดที่ทำได้ย้อนหลัง)
+		INSERT INTO booking_snapshots (
+			booking_id, package_name, package_duration, package_price, cancellation_tiers,
+			require_deposit, deposit_percentage, platform_commission_rate, gateway_fee_rate, created_at
+		)
+		SELECT b.booking_id, sp.package_name, sp.duration, sp.price,
+		       COALESCE((
+		           SELECT json_agg(json_build_object('hours_before_booking', cp.hours_before_booking,
+		                                             'fee_percentage', cp.fee_percentage)
+		                           ORDER BY cp.hours_before_booking)
+		           FROM cancellation_policies cp
+		           WHERE cp.provider_id = b.provider_id AND cp.is_active = true
+		       ), '[]'),
+		       COALESCE(pds.require_deposit, false), COALESCE(pds.deposit_percentage, 0.30), 0.10, 0.0275, b.created_at
+		FROM bookings b
+		JOIN service_packages sp ON sp.package_id = b.package_id
+		LEFT JOIN provider_deposit_settings pds ON pds.provider_id = b.provider_id
+		ON CONFLICT (booking_id) DO NOTHING;
+	`)
+	if err != nil {
+		log.Printf("Warning: Migration 053 error: %v\n", err)
+	} else {
+		fmt.Println("✅ Migration 053: Booking Snapshots completed!")
+	}
+
+	// --- Migration 054: Package Management ---
+	fmt.Println("🔄 Running Migration 054: Package Management...")
+	_, err = dbPool.Exec(ctx, `
+		-- เวอร์ชันแพ็คเกจ: แก้แพ็คเกจที่มี booking แล้ว → แถวใหม่ (root_package_id เดิม), แถวเก่า replaced_by
+		ALTER TABLE service_packages ADD COLUMN IF NOT EXISTS category_id INT REFERENCES service_categories(category_id) ON DELETE SET NULL;
+		ALTER TABLE service_packages ADD COLUMN IF NOT EXISTS location_type VARCHAR(10) NOT NULL DEFAULT 'both';
+		ALTER TABLE service_packages ADD COLUMN IF NOT EXISTS max_advance_days INT;
+		ALTER TABLE service_packages ADD COLUMN IF NOT EXISTS display_order INT NOT NULL DEFAULT 0;
+		ALTER TABLE service_packages ADD COLUMN IF NOT EXISTS version INT NOT NULL DEFAULT 1;
+		ALTER TABLE service_packages ADD COLUMN IF NOT EXISTS root_package_id INT REFERENCES service_packages(package_id);
+		ALTER TABLE service_packages ADD COLUMN IF NOT EXISTS replaced_by INT REFERENCES service_packages(package_id);
+		ALTER TABLE service_packages ADD COLUMN IF NOT EXISTS archived_at TIMESTAMPTZ;
+		ALTER TABLE service_packages ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW();
+
+		ALTER TABLE service_packages DROP CONSTRAINT IF EXISTS service_packages_location_type_check;
+		ALTER TABLE service_packages ADD CONSTRAINT service_packages_location_type_check
+			CHECK (location_type IN ('incall', 'outcall', 'both'));
+
+		CREATE INDEX IF NOT EXISTS idx_service_packages_provider ON service_packages(provider_id, display_order);
+		CREATE INDEX IF NOT EXISTS idx_service_packages_root ON service_packages(root_package_id);
+
+		CREATE TABLE IF NOT EXISTS package_images (
+			image_id SERIAL PRIMARY KEY,
+			package_id INT NOT NULL REFERENCES service_packages(package_id) ON DELETE CASCADE,
+			image_url TEXT NOT NULL,
+			display_order INT NOT NULL DEFAULT 0,
+			created_at TIMESTAMPTZ DEFAULT NOW()
+		);
+		CREATE INDEX IF NOT EXISTS idx_package_images_package ON package_images(package_id, display_order);
+	`)
+	if err != nil {
+		log.Printf("Warning: Migration 054 error: %v\n", err)
+	} else {
+		fmt.Println("✅ Migration 054: Package Management completed!")
+	}
+
+	// --- Migration 055: Provider Availability Settings ---
+	fmt.Println("🔄 Running Migration 055: Provider Availability Settings...")
+	_, err = dbPool.Exec(ctx, `
+		-- buffer ระหว่าง booking และช่วงห่างของ slot ที่แสดงให้ลูกค้า
+		CREATE TABLE IF NOT EXISTS provider_availability_settings (
+			provider_id INT PRIMARY KEY REFERENCES users(user_id) ON DELETE CASCADE,
+			buffer_minutes INT NOT NULL DEFAULT 0 CHECK (buffer_minutes BETWEEN 0 AND 240),
+			slot_interval_minutes INT NOT NULL DEFAULT 30 CHECK (slot_interval_minutes BETWEEN 5 AND 240),
+			updated_at TIMESTAMPTZ DEFAULT NOW()
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_bookings_provider_time ON bookings(provider_id, start_time, end_time);
+		CREATE INDEX IF NOT EXISTS idx_schedules_provider_time ON provider_schedules(provider_id, start_time, end_time);
+	`)
+	if err != nil {
+		log.Printf("Warning: Migration 055 error: %v\n", err)
+	} else {
+		fmt.Println("✅ Migration 055: Provider Availability Settings completed!")
+	}
+
+	// --- Migration 056: Double-Booking Prevention ---
+	fmt.Println("🔄 Running Migration 056: Double-Booking Prevention...")
+	_, err = dbPool.Exec(ctx, `
+		-- กันจองซ้อนระดับฐานข้อมูล: booking ที่ยังไม่ยกเลิกของ provider เดียวกันห้ามเวลาทับกัน
+		CREATE EXTENSION IF NOT EXISTS btree_gist;
+
+		DO $$
+		BEGIN
+			IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'bookings_no_overlap') THEN
+				ALTER TABLE bookings ADD CONSTRAINT bookings_no_overlap
+					EXCLUDE USING gist (provider_id WITH =, tstzrange(start_time, end_time, '[)') WITH &&)
+					WHERE (status NOT IN ('cancelled', 'rejected', 'expired', 'failed'));
+			END IF;
+
+			-- schedule 'booked' ห้ามทับกัน
+			IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'provider_schedules_no_overlap') THEN
+				ALTER TABLE provider_schedules ADD CONSTRAINT provider_schedules_no_overlap
+					EXCLUDE USING gist (provider_id WITH =, tsrange(start_time, end_time, '[)') WITH &&)
+					WHERE (status = 'booked');
+			END IF;
+		END $$;
+
+		-- schedule 'booked' หนึ่งแถวต่อ booking
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_schedules_booking_unique
+			ON provider_schedules(booking_id) WHERE booking_id IS NOT NULL;
+
+		-- booking ถูกยกเลิก/ปฏิเสธ (ทุกเส้นทาง) → คืนเวลาใน provider_schedules ทันที
+		CREATE OR REPLACE FUNCTION release_booking_schedule() RETURNS trigger AS $$
+		BEGIN
+			IF NEW.status IN ('cancelled', 'rejected', 'expired', 'failed') THEN
+				DELETE FROM provider_schedules WHERE booking_id = NEW.booking_id;
+			END IF;
+			RETURN NEW;
+		END;
+		$$ LANGUAGE plpgsql;
+
+		DROP TRIGGER IF EXISTS trg_bookings_release_schedule ON bookings;
+		CREATE TRIGGER trg_bookings_release_schedule
+			AFTER UPDATE OF status ON bookings
+			FOR EACH ROW WHEN (OLD.status IS DISTINCT FROM NEW.status)
+			EXECUTE FUNCTION release_booking_schedule();
+	`)
+	if err != nil {
+		log.Printf("Warning: Migration 056 error (overlapping bookings must be resolved first): %v\n", err)
+	} else {
+		fmt.Println("✅ Migration 056: Double-Booking Prevention completed!")
+	}
+
+	// --- Migration 057: Recurring Weekly Schedules ---
+	fmt.Println("🔄 Running Migration 057: Recurring weekly schedules and schedule exceptions...")
+	_, err = dbPool.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS provider_recurring_schedules (
+			rule_id SERIAL PRIMARY KEY,
+			provider_id INTEGER NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+			weekday SMALLINT NOT NULL CHECK (weekday BETWEEN 0 AND 6),
+			start_time TIME NOT NULL,
+			end_time TIME NOT NULL CHECK (end_time <> start_time), -- end < start = ข้ามเที่ยงคืน
+			location_type VARCHAR(20) CHECK (location_type IN ('Incall', 'Outcall', 'Both')),
+			location_province VARCHAR(100),
+			effective_from DATE NOT NULL DEFAULT CURRENT_DATE,
+			effective_until DATE CHECK (effective_until IS NULL OR effective_until >= effective_from),
+			skip_holidays BOOLEAN NOT NULL DEFAULT false,
+			is_paused BOOLEAN NOT NULL DEFAULT false,
+			notes TEXT,
+			created_at TIMESTAMP DEFAULT NOW(),
+			updated_at TIMESTAMP DEFAULT NOW()
+		);
+		CREATE INDEX IF NOT EXISTS idx_recurring_schedules_provider ON provider_recurring_schedules(provider_id) WHERE is_paused = false;
+
+		CREATE TABLE IF NOT EXISTS provider_schedule_exceptions (
+			exception_id SERIAL PRIMARY KEY,
+			provider_id INTEGER NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+			start_date DATE NOT NULL,
+			end_date DATE NOT NULL CHECK (end_date >= start_date),
+			reason TEXT,
+			created_at TIMESTAMP DEFAULT NOW()
+		);
+		CREATE INDEX IF NOT EXISTS idx_schedule_exceptions_provider ON provider_schedule_exceptions(provider_id, end_date);
+	`)
+	if err != nil {
+		log.Printf("Warning: Migration 057 error: %v\n", err)
+	} else {
+		fmt.Println("✅ Migration 057: Recurring weekly schedules completed!")
+	}
+
+	// --- Migration 058: Calendar Feeds (ICS) ---
+	fmt.Println("🔄 Running Migration 058: Calendar feed tokens and iCalendar sequences...")
+	_, err = dbPool.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS calendar_feed_tokens (
+			user_id INTEGER PRIMARY KEY REFERENCES users(user_id) ON DELETE CASCADE,
+			token VARCHAR(64) NOT NULL UNIQUE,
+			created_at TIMESTAMPTZ DEFAULT NOW(),
+			last_accessed_at TIMESTAMPTZ
+		);
+
+		-- SEQUENCE ของ VEVENT: เพิ่มทุกครั้งที่เวลา/สถานะเปลี่ยน ให้ปฏิทินภายนอกอัปเดตรายการเดิม
+		ALTER TABLE bookings ADD COLUMN IF NOT EXISTS ical_sequence INTEGER NOT NULL DEFAULT 0;
+		ALTER TABLE provider_schedules ADD COLUMN IF NOT EXISTS ical_sequence INTEGER NOT NULL DEFAULT 0;
+
+		CREATE OR REPLACE FUNCTION bump_ical_sequence() RETURNS trigger AS $$
+		BEGIN
+			NEW.ical_sequence := OLD.ical_sequence + 1;
+			RETURN NEW;
+		END;
+		$$ LANGUAGE plpgsql;
+
+		DROP TRIGGER IF EXISTS trg_bookings_ical_sequence ON bookings;
+		CREATE TRIGGER trg_bookings_ical_sequence
+			BEFORE UPDATE ON bookings
+			FOR EACH ROW WHEN (OLD.start_time IS DISTINCT FROM NEW.start_time
+				OR OLD.end_time IS DISTINCT FROM NEW.end_time
+				OR OLD.status IS DISTINCT FROM NEW.status)
+			EXECUTE FUNCTION bump_ical_sequence();
+
+		DROP TRIGGER IF EXISTS trg_provider_schedules_ical_sequence ON provider_schedules;
+		CREATE TRIGGER trg_provider_schedules_ical_sequence
+			BEFORE UPDATE ON provider_schedules
+			FOR EACH ROW WHEN (OLD.start_time IS DISTINCT FROM NEW.start_time
+				OR OLD.end_time IS DISTINCT FROM NEW.end_time
+				OR OLD.status IS DISTINCT FROM NEW.status
+				OR OLD.location_type IS DISTINCT FROM NEW.location_type
+				OR OLD.location_district IS DISTINCT FROM NEW.location_district
+				OR OLD.location_province IS DISTINCT FROM NEW.location_province)
+			EXECUTE FUNCTION bump_ical_sequence();
+	`)
+	if err != nil {
+		log.Printf("Warning: Migration 058 error: %v\n", err)
+	} else {
+		fmt.Println("✅ Migration 058: Calendar Feeds completed!")
+	}
+
+	// --- Migration 059: External Calendar Imports ---
+	fmt.Println("🔄 Running Migration 059: External calendar imports...")
+	_, err = dbPool.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS provider_calendar_imports (
+			import_id SERIAL PRIMARY KEY,
+			provider_id INTEGER NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+			name VARCHAR(100) NOT NULL,
+			source_type VARCHAR(10) NOT NULL CHECK (source_type IN ('url', 'upload')),
+			source_url TEXT,
+			is_active BOOLEAN NOT NULL DEFAULT true,
+			last_synced_at TIMESTAMPTZ,
+			last_error TEXT,
+			event_count INTEGER NOT NULL DEFAULT 0,
+			created_at TIMESTAMPTZ DEFAULT NOW(),
+			updated_at TIMESTAMPTZ DEFAULT NOW(),
+			CHECK (source_type <> 'url' OR source_url IS NOT NULL)
+		);
+		CREATE INDEX IF NOT EXISTS idx_calendar_imports_provider ON provider_calendar_imports(provider_id);
+
+		-- block ที่มาจากปฏิทินภายนอก: ลบตามเมื่อยกเลิกการเชื่อม, external_uid ใช้ de-duplicate ตอน re-sync
+		ALTER TABLE provider_schedules ADD COLUMN IF NOT EXISTS import_id INTEGER REFERENCES provider_calendar_imports(import_id) ON DELETE CASCADE;
+		ALTER TABLE provider_schedules ADD COLUMN IF NOT EXISTS external_uid TEXT;
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_schedules_import_uid ON provider_schedules(import_id, external_uid);
+	`)
+	if err != nil {
+		log.Printf("Warning: Migration 059 error: %v\n", err)
+	} else {
+		fmt.Println("✅ Migration 059: External Calendar Imports completed!")
+	}
+
+	// --- Migration 060: Time Zone Policy ---
+	fmt.Println("🔄 Running Migration 060: Schedule times as TIMESTAMPTZ and per-provider/booking time zones...")
+	_, err = dbPool.Exec(ctx, `
+		-- provider_schedules เดิมเก็บ TIMESTAMP (เวลาไทยไม่มี timezone) → TIMESTAMPTZ
+		-- constraint/trigger ที่อ้าง start_time/end_time ต้องลบก่อนเปลี่ยนชนิดคอลัมน์แล้วสร้างใหม่
+		DO $$
+		BEGIN
+			IF EXISTS (
+				SELECT 1 FROM information_schema.columns
+				WHERE table_name = 'provider_schedules' AND column_name = 'start_time'
+				  AND data_type = 'timestamp without time zone'
+			) THEN
+				ALTER TABLE provider_schedules DROP CONSTRAINT IF EXISTS provider_schedules_no_overlap;
+				DROP TRIGGER IF EXISTS trg_provider_schedules_ical_sequence ON provider_schedules;
+
+				ALTER TABLE provider_schedules
+					ALTER COLUMN start_time TYPE TIMESTAMPTZ USING start_time AT TIME ZONE 'Asia/Bangkok',
+					ALTER COLUMN end_time TYPE TIMESTAMPTZ USING end_time AT TIME ZONE 'Asia/Bangkok',
+					ALTER COLUMN created_at TYPE TIMESTAMPTZ USING created_at AT TIME ZONE 'Asia/Bangkok',
+					ALTER COLUMN updated_at TYPE TIMESTAMPTZ USING updated_at AT TIME ZONE 'Asia/Bangkok';
+
+				ALTER TABLE provider_schedules ADD CONSTRAINT provider_schedules_no_overlap
+					EXCLUDE USING gist (provider_id WITH =, tstzrange(start_time, end_time, '[)') WITH &&)
+					WHERE (status = 'booked');
+
+				CREATE TRIGGER trg_provider_schedules_ical_sequence
+					BEFORE UPDATE ON provider_schedules
+					FOR EACH ROW WHEN (OLD.start_time IS DISTINCT FROM NEW.start_time
+						OR OLD.end_time IS DISTINCT FROM NEW.end_time
+						OR OLD.status IS DISTINCT FROM NEW.status
+						OR OLD.location_type IS DISTINCT FROM NEW.location_type
+						OR OLD.location_district IS DISTINCT FROM NEW.location_district
+						OR OLD.location_province IS DISTINCT FROM NEW.location_province)
+					EXECUTE FUNCTION bump_ical_sequence();
+			END IF;
+
+			IF EXISTS (
+				SELECT 1 FROM information_schema.columns
+				WHERE table_name = 'provider_recurring_schedules' AND column_name = 'created_at'
+				  AND data_type = 'timestamp without time zone'
+			) THEN
+				ALTER TABLE provider_recurring_schedules
+					ALTER COLUMN created_at TYPE TIMESTAMPTZ USING created_at AT TIME ZONE 'Asia/Bangkok',
+					ALTER COLUMN updated_at TYPE TIMESTAMPTZ USING updated_at AT TIME ZONE 'Asia/Bangkok';
+				ALTER TABLE provider_schedule_exceptions
+					ALTER COLUMN created_at TYPE TIMESTAMPTZ USING created_at AT TIME ZONE 'Asia/Bangkok';
+			END IF;
+		END $$;
+
+		-- timezone ของ provider (เวลาใน slot/ตารางประจำสัปดาห์/ปฏิทินที่ import) และของ booking (ณ เวลาที่จอง)
+		ALTER TABLE provider_availability_settings ADD COLUMN IF NOT EXISTS timezone VARCHAR(64) NOT NULL DEFAULT 'Asia/Bangkok';
+		ALTER TABLE bookings ADD COLUMN IF NOT EXISTS timezone VARCHAR(64) NOT NULL DEFAULT 'Asia/Bangkok';
+	`)
+	if err != nil {
+		log.Printf("Warning: Migration 060 error: %v\n", err)
+	} else {
+		fmt.Println("✅ Migration 060: Time Zone Policy completed!")
+	}
+
+	// --- Migration 061: Deposit Funding ---
+	fmt.Println("🔄 Running Migration 061: Link booking deposits to their wallet payment...")
+	_, err = dbPool.Exec(ctx, `
+		-- มัดจำที่มีเงินจริงต้องมี wallet_payment ที่ completed ผูกอยู่ (แถวเก่าไม่มี = ยังไม่ได้รับเงิน)
+		ALTER TABLE booking_deposits ADD COLUMN IF NOT EXISTS wallet_transaction_id INTEGER REFERENCES transactions(transaction_id);
+	`)
+	if err != nil {
+		log.Printf("Warning: Migration 061 error: %v\n", err)
+	} else {
+		fmt.Println("✅ Migration 061: Deposit Funding completed!")
+	}
+
+	// --- Migration 062: Booking Payment Reversal ---
+	fmt.Println("🔄 Running Migration 062: Booking payment reversal on cancellation...")
+	_, err = dbPool.Exec(ctx, `
+		DO $$
+		BEGIN
+			IF EXISTS (SELECT 1 FROM pg_type WHERE typname = 'transaction_type') THEN
+				ALTER TYPE transaction_type ADD VALUE IF NOT EXISTS 'booking_payment_reversal';
+			END IF;
+		END $$;
+	`)
+	if err != nil {
+		log.Printf("Warning: Migration 062 error: %v\n", err)
+	} else {
+		fmt.Println("✅ Migration 062: Booking Payment Reversal completed!")
+	}
+
+	// --- Migration 063: Wallet Credit Balance ---
+	fmt.Println("🔄 Running Migration 063: Non-withdrawable wallet credit...")
+	_, err = dbPool.Exec(ctx, `
+		-- เงินเติม/เงินคืนของ client แยกจากรายได้ (available_balance) → ใช้จ่ายได้ แต่ถอนไม่ได้
+		ALTER TABLE wallets ADD COLUMN IF NOT EXISTS credit_balance DECIMAL(10, 2) DEFAULT 0.00;
+		-- ส่วนของ wallet_payment ที่ตัดจาก credit_balance (ใช้คืนเงินกลับยอดเดิมเมื่อปล่อย hold)
+		ALTER TABLE transactions ADD COLUMN IF NOT EXISTS credit_amount DECIMAL(10, 2) DEFAULT 0.00;
+	`)
+	if err != nil {
+		log.Printf("Warning: Migration 063 error: %v\n", err)
+	} else {
+		fmt.Println("✅ Migration 063: Wallet Credit Balance completed!")
+	}
+
+	// --- Migration 064: Pricing Rule Discount Cap ---
+	fmt.Println("🔄 Running Migration 064: Capping pricing rule discounts...")
+	_, err = dbPool.Exec(ctx, `
+		-- ส่วนลดต่อกฎไม่เกิน 50% (กฎเดิมที่ลดมากกว่านั้นถูกปรับลงมาที่ -50)
+		UPDATE package_pricing_rules SET adjustment_percent = -50, updated_at = NOW() WHERE adjustment_percent < -50;
+		ALTER TABLE package_pricing_rules DROP CONSTRAINT IF EXISTS package_pricing_rules_adjustment_percent_check;
+		ALTER TABLE package_pricing_rules ADD CONSTRAINT package_pricing_rules_adjustment_percent_check
+			CHECK (adjustment_percent BETWEEN -50 AND 300 AND adjustment_percent <> 0);
+	`)
+	if err != nil {
+		log.Printf("Warning: Migration 064 error: %v\n", err)
+	} else {
+		fmt.Println("✅ Migration 064: Pricing Rule Discount Cap completed!")
+	}
+
+	// --- Migration 065: Provider Debt Balance ---
+	fmt.Println("🔄 Running Migration 065: Provider debt balance...")
+	_, err = dbPool.Exec(ctx, `
+		-- ยอดที่ provider ต้องคืนแพลตฟอร์ม (เช่น booking ถูกยกเลิกหลังถอนรายได้ไปแล้ว) → หักจากยอดถอนได้
+		ALTER TABLE wallets ADD COLUMN IF NOT EXISTS debt_balance DECIMAL(10, 2) DEFAULT 0.00;
+	`)
+	if err != nil {
+		log.Printf("Warning: Migration 065 error: %v\n", err)
+	} else {
+		fmt.Println("✅ Migration 065: Provider Debt Balance completed!")
+	}
+
 	fmt.Println("✅ All Database Migrations สำเร็จ!")
 }
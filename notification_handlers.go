@@ -73,17 +73,36 @@ func CreateNotification(userID int, notifType, message string, metadata map[stri
 // getNotificationTitle returns a default title based on notification type
 func getNotificationTitle(notifType string) string {
 	titles := map[string]string{
-		"new_message":       "New Message",
-		"booking_request":   "New Booking Request",
-		"booking_confirmed": "Booking Confirmed",
-		"booking_cancelled": "Booking Cancelled",
-		"booking_completed": "Booking Completed",
-		"kyc_approved":      "KYC Approved",
-		"kyc_rejected":      "KYC Rejected",
-		"new_review":        "New Review",
-		"payment_success":   "Payment Successful",
-		"payment_failed":    "Payment Failed",
-		"tier_upgraded":     "Tier Upgraded",
+		"new_message":                  "New Message",
+		"booking_request":              "New Booking Request",
+		"booking_confirmed":            "Booking Confirmed",
+		"booking_cancelled":            "Booking Cancelled",
+		"booking_completed":            "Booking Completed",
+		"kyc_approved":                 "KYC Approved",
+		"kyc_rejected":                 "KYC Rejected",
+		"new_review":                   "New Review",
+		"payment_success":              "Payment Successful",
+		"payment_failed":               "Payment Failed",
+		"tier_upgraded":                "Tier Upgraded",
+		"withdrawal_completed":         "Withdrawal Completed",
+		"withdrawal_failed":            "Withdrawal Failed",
+		"reconciliation_alert":         "Reconciliation Alert",
+		"escrow_confirmation_reminder": "Please Confirm Service Completion",
+		"escrow_auto_released":         "Escrow Released",
+		"dispute_opened":               "Dispute Opened",
+		"dispute_message":              "New Dispute Message",
+		"dispute_evidence":             "New Dispute Evidence",
+		"dispute_response_requested":   "Response Requested",
+		"dispute_deadline_missed":      "Dispute Deadline Missed",
+		"dispute_resolved":             "Dispute Resolved",
+		"cancellation_fee_due":         "Cancellation Fee Due",
+		"cancellation_fee_paid":        "Cancellation Fee Received",
+		"cancellation_fee_waived":      "Cancellation Fee Waived",
+		"campaign_subsidy_paid":        "Campaign Discount Compensated",
+		"referral_reward":              "Referral Reward",
+		"referral_welcome_coupon":      "Welcome Coupon",
+		"tip_received":                 "Tip Received",
+		"wallet_topup_completed":       "Wallet Top-up Completed",
 	}
 	if title, ok := titles[notifType]; ok {
 		return title
@@ -0,0 +1,574 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ================================
+// Package Management (แพ็คเกจของ provider: CRUD + เวอร์ชัน + รูป)
+// ================================
+//
+// แพ็คเกจที่มี booking แล้วจะไม่ถูกแก้ทับ: การแก้ชื่อ/รายละเอียด/ระยะเวลา/ราคา
+// จะสร้างเวอร์ชันใหม่ (root_package_id เดิม) แล้วปิดเวอร์ชันเก่า (replaced_by)
+// ส่วน booking เดิมยังชี้ไปที่เวอร์ชันที่จองไว้
+
+const (
+	PackageLocationIncall  = "incall"
+	PackageLocationOutcall = "outcall"
+	PackageLocationBoth    = "both"
+)
+
+const (
+	maxPackageImages     = 10
+	maxPackageImageBytes = 5 << 20
+	maxPackageNameLength = 100
+	maxAdvanceDaysLimit  = 365
+)
+
+var (
+	errPackageName         = errors.New("package_name is required (max 100 characters)")
+	errPackageDuration     = errors.New("duration must be between 15 and 1440 minutes")
+	errPackagePrice        = errors.New("price must be greater than 0")
+	errPackageLocationType = errors.New("location_type must be incall, outcall or both")
+	errPackageAdvanceDays  = errors.New("max_advance_days must be between 1 and 365")
+	errPackageCategory     = errors.New("category_id must be one of your provider categories")
+)
+
+const servicePackageColumns = `
+	package_id, provider_id, package_name, description, duration, price, is_active, created_at,
+	category_id, location_type, max_advance_days, display_order, version,
+	COALESCE(root_package_id, package_id), replaced_by, archived_at, updated_at`
+
+func scanServicePackage(row pgx.Row) (*ServicePackage, error) {
+	var p ServicePackage
+	err := row.Scan(&p.PackageID, &p.ProviderID, &p.PackageName, &p.Description, &p.Duration, &p.Price,
+		&p.IsActive, &p.CreatedAt, &p.CategoryID, &p.LocationType, &p.MaxAdvanceDays, &p.DisplayOrder,
+		&p.Version, &p.RootPackageID, &p.ReplacedBy, &p.ArchivedAt, &p.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// packageInput is the body for creating/updating a package (ส่งค่าทั้งหมด)
+type packageInput struct {
+	PackageName    string  `json:"package_name" binding:"required"`
+	Description    *string `json:"description"`
+	Duration       int     `json:"duration" binding:"required"`
+	Price          float64 `json:"price" binding:"required"`
+	CategoryID     *int    `json:"category_id"`
+	LocationType   string  `json:"location_type"`
+	MaxAdvanceDays *int    `json:"max_advance_days"`
+	IsActive       *bool   `json:"is_active"`
+}
+
+// normalize fills defaults and validates the fields that don't need the database
+func (in *packageInput) normalize() error {
+	in.PackageName = strings.TrimSpace(in.PackageName)
+	if in.PackageName == "" || len([]rune(in.PackageName)) > maxPackageNameLength {
+		return errPackageName
+	}
+	if in.Duration < 15 || in.Duration > 1440 {
+		return errPackageDuration
+	}
+	if in.Price <= 0 {
+		return errPackagePrice
+	}
+	if in.LocationType == "" {
+		in.LocationType = PackageLocationBoth
+	}
+	switch in.LocationType {
+	case PackageLocationIncall, PackageLocationOutcall, PackageLocationBoth:
+	default:
+		return errPackageLocationType
+	}
+	if in.MaxAdvanceDays != nil && (*in.MaxAdvanceDays < 1 || *in.MaxAdvanceDays > maxAdvanceDaysLimit) {
+		return errPackageAdvanceDays
+	}
+	return nil
+}
+
+// changesBookedTerms reports whether applying in to p changes what clients paid for,
+// which needs a new version once the package has bookings
+func (in *packageInput) changesBookedTerms(p *ServicePackage) bool {
+	desc := func(s *string) string {
+		if s == nil {
+			return ""
+		}
+		return *s
+	}
+	return in.PackageName != p.PackageName || desc(in.Description) != desc(p.Description) ||
+		in.Duration != p.Duration || roundSatang(in.Price) != roundSatang(p.Price)
+}
+
+// checkPackageCategory makes sure the category is one the provider offers
+func checkPackageCategory(ctx context.Context, dbPool *pgxpool.Pool, providerID int, categoryID *int) error {
+	if categoryID == nil {
+		return nil
+	}
+	var ok bool
+	dbPool.QueryRow(ctx, `
+		SELECT EXISTS (SELECT 1 FROM provider_categories WHERE provider_id = $1 AND category_id = $2)
+	`, providerID, *categoryID).Scan(&ok)
+	if !ok {
+		return errPackageCategory
+	}
+	return nil
+}
+
+// loadOwnPackage returns the current (not replaced, not archived) version of a provider's package
+func loadOwnPackage(ctx context.Context, db rowQuerier, providerID int, packageID string, lock bool) (*ServicePackage, error) {
+	query := `SELECT ` + servicePackageColumns + ` FROM service_packages
+		WHERE package_id = $1 AND provider_id = $2 AND replaced_by IS NULL AND archived_at IS NULL`
+	if lock {
+		query += " FOR UPDATE"
+	}
+	return scanServicePackage(db.QueryRow(ctx, query, packageID, providerID))
+}
+
+// loadPackageImages returns the images of the given packages, keyed by package
+func loadPackageImages(ctx context.Context, dbPool *pgxpool.Pool, packageIDs []int) (map[int][]PackageImage, error) {
+	rows, err := dbPool.Query(ctx, `
+		SELECT image_id, package_id, image_url, display_order, created_at
+		FROM package_images
+		WHERE package_id = ANY($1)
+		ORDER BY display_order, image_id
+	`, packageIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	images := make(map[int][]PackageImage)
+	for rows.Next() {
+		var img PackageImage
+		if err := rows.Scan(&img.ImageID, &img.PackageID, &img.ImageURL, &img.DisplayOrder, &img.CreatedAt); err != nil {
+			return nil, err
+		}
+		img.ImageURL = storedFileURL(img.ImageURL)
+		images[img.PackageID] = append(images[img.PackageID], img)
+	}
+	return images, rows.Err()
+}
+
+// --- POST /packages (สร้างแพ็คเกจ - Provider เท่านั้น) ---
+func createPackageHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetInt("userID")
+
+		var input packageInput
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := input.normalize(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := checkPackageCategory(ctx, dbPool, userID, input.CategoryID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		// แพ็คเกจใหม่ต่อท้ายรายการ (root_package_id NULL = เวอร์ชันแรก)
+		pkg, err := scanServicePackage(dbPool.QueryRow(ctx, `
+			INSERT INTO service_packages (
+				provider_id, package_name, description, duration, price, is_active,
+				category_id, location_type, max_advance_days, display_order
+			)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9,
+			        (SELECT COALESCE(MAX(display_order), 0) + 1 FROM service_packages WHERE provider_id = $1))
+			RETURNING `+servicePackageColumns,
+			userID, input.PackageName, input.Description, input.Duration, input.Price, input.IsActive == nil || *input.IsActive,
+			input.CategoryID, input.LocationType, input.MaxAdvanceDays))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create package"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"package_id": pkg.PackageID, "package": pkg, "message": "Package created successfully"})
+	}
+}
+
+// GET /provider/packages - แพ็คเกจของตัวเอง (รวมที่ปิดไว้, ?include_archived=true รวมที่ลบ/เวอร์ชันเก่า)
+func getMyPackagesHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetInt("userID")
+		includeArchived := c.Query("include_archived") == "true"
+
+		rows, err := dbPool.Query(ctx, `
+			SELECT `+servicePackageColumns+`
+			FROM service_packages
+			WHERE provider_id = $1 AND ($2 OR (replaced_by IS NULL AND archived_at IS NULL))
+			ORDER BY display_order, package_id
+		`, userID, includeArchived)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch packages"})
+			return
+		}
+		defer rows.Close()
+
+		packages := make([]*ServicePackage, 0)
+		packageIDs := make([]int, 0)
+		for rows.Next() {
+			if p, err := scanServicePackage(rows); err == nil {
+				packages = append(packages, p)
+				packageIDs = append(packageIDs, p.PackageID)
+			}
+		}
+		rows.Close()
+
+		images, err := loadPackageImages(ctx, dbPool, packageIDs)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch package images"})
+			return
+		}
+		rules, err := loadPricingRules(ctx, dbPool, packageIDs)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch pricing rules"})
+			return
+		}
+		for _, p := range packages {
+			p.Images = images[p.PackageID]
+			p.PricingRules = rules[p.PackageID]
+		}
+
+		c.JSON(http.StatusOK, gin.H{"packages": packages})
+	}
+}
+
+// PUT /provider/packages/:packageId - แก้ไขแพ็คเกจ (ส่งค่าทั้งหมด)
+// ถ้ามี booking แล้วและแก้ชื่อ/รายละเอียด/ระยะเวลา/ราคา → สร้างเวอร์ชันใหม่
+func updatePackageHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetInt("userID")
+
+		var input packageInput
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := input.normalize(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := checkPackageCategory(ctx, dbPool, userID, input.CategoryID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		tx, err := dbPool.Begin(ctx)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+			return
+		}
+		defer tx.Rollback(ctx)
+
+		current, err := loadOwnPackage(ctx, tx, userID, c.Param("packageId"), true)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Package not found"})
+			return
+		}
+		isActive := current.IsActive
+		if input.IsActive != nil {
+			isActive = *input.IsActive
+		}
+
+		var hasBookings bool
+		tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM bookings WHERE package_id = $1)`, current.PackageID).Scan(&hasBookings)
+
+		newVersion := hasBookings && input.changesBookedTerms(current)
+		var pkg *ServicePackage
+		if newVersion {
+			pkg, err = scanServicePackage(tx.QueryRow(ctx, `
+				INSERT INTO service_packages (
+					provider_id, package_name, description, duration, price, is_active,
+					category_id, location_type, max_advance_days, display_order, version, root_package_id
+				)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+				RETURNING `+servicePackageColumns,
+				userID, input.PackageName, input.Description, input.Duration, input.Price, isActive,
+				input.CategoryID, input.LocationType, input.MaxAdvanceDays, current.DisplayOrder,
+				current.Version+1, current.RootPackageID))
+			if err == nil {
+				_, err = tx.Exec(ctx, `
+					UPDATE service_packages SET is_active = false, replaced_by = $2, updated_at = NOW()
+					WHERE package_id = $1
+				`, current.PackageID, pkg.PackageID)
+			}
+			// กฎราคาและรูปย้ายไปเวอร์ชันใหม่ (booking เดิมมี snapshot ของราคาแล้ว)
+			if err == nil {
+				_, err = tx.Exec(ctx, `UPDATE package_pricing_rules SET package_id = $2 WHERE package_id = $1`, current.PackageID, pkg.PackageID)
+			}
+			if err == nil {
+				_, err = tx.Exec(ctx, `UPDATE package_images SET package_id = $2 WHERE package_id = $1`, current.PackageID, pkg.PackageID)
+			}
+		} else {
+			pkg, err = scanServicePackage(tx.QueryRow(ctx, `
+				UPDATE service_packages
+				SET package_name = $2, description = $3, duration = $4, price = $5, is_active = $6,
+				    category_id = $7, location_type = $8, max_advance_days = $9, updated_at = NOW()
+				WHERE package_id = $1
+				RETURNING `+servicePackageColumns,
+				current.PackageID, input.PackageName, input.Description, input.Duration, input.Price, isActive,
+				input.CategoryID, input.LocationType, input.MaxAdvanceDays))
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update package"})
+			return
+		}
+		if err := tx.Commit(ctx); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update package"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":             "Package updated",
+			"package":             pkg,
+			"new_version":         newVersion,
+			"previous_package_id": current.PackageID,
+		})
+	}
+}
+
+// PATCH /provider/packages/:packageId/status - เปิด/ปิดการขาย (ไม่สร้างเวอร์ชันใหม่)
+func setPackageStatusHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetInt("userID")
+
+		var req struct {
+			IsActive *bool `json:"is_active" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		tag, err := dbPool.Exec(ctx, `
+			UPDATE service_packages SET is_active = $3, updated_at = NOW()
+			WHERE package_id = $1 AND provider_id = $2 AND replaced_by IS NULL AND archived_at IS NULL
+		`, c.Param("packageId"), userID, *req.IsActive)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update package"})
+			return
+		}
+		if tag.RowsAffected() == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Package not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Package status updated", "is_active": *req.IsActive})
+	}
+}
+
+// DELETE /provider/packages/:packageId - ลบแพ็คเกจ
+// มี booking แล้ว → archive (ประวัติการจองยังอ้างอิงได้), ไม่มี → ลบจริง
+func deletePackageHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetInt("userID")
+
+		tx, err := dbPool.Begin(ctx)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+			return
+		}
+		defer tx.Rollback(ctx)
+
+		current, err := loadOwnPackage(ctx, tx, userID, c.Param("packageId"), true)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Package not found"})
+			return
+		}
+
+		// เวอร์ชันเก่า/quote ที่ค้างอยู่ก็ยังอ้างอิง package_id นี้ → archive ไว้
+		var referenced bool
+		tx.QueryRow(ctx, `
+			SELECT EXISTS (SELECT 1 FROM bookings WHERE package_id = $1)
+			    OR EXISTS (SELECT 1 FROM price_quotes WHERE package_id = $1)
+			    OR $2 > 1
+		`, current.PackageID, current.Version).Scan(&referenced)
+
+		if referenced {
+			_, err = tx.Exec(ctx, `
+				UPDATE service_packages SET is_active = false, archived_at = NOW(), updated_at = NOW()
+				WHERE package_id = $1
+			`, current.PackageID)
+		} else {
+			_, err = tx.Exec(ctx, `DELETE FROM service_packages WHERE package_id = $1`, current.PackageID)
+		}
+		if err == nil {
+			err = tx.Commit(ctx)
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete package"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Package deleted", "archived": referenced})
+	}
+}
+
+// PUT /provider/packages/order - เรียงลำดับแพ็คเกจ {"package_ids": [3, 1, 2]}
+func reorderPackagesHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetInt("userID")
+
+		var req struct {
+			PackageIDs []int `json:"package_ids" binding:"required,min=1"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		tag, err := dbPool.Exec(ctx, `
+			UPDATE service_packages sp SET display_order = o.position, updated_at = NOW()
+			FROM unnest($2::int[]) WITH ORDINALITY AS o(package_id, position)
+			WHERE sp.package_id = o.package_id AND sp.provider_id = $1
+			  AND sp.replaced_by IS NULL AND sp.archived_at IS NULL
+		`, userID, req.PackageIDs)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reorder packages"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Packages reordered", "updated": tag.RowsAffected()})
+	}
+}
+
+// GET /provider/packages/:packageId/versions - ประวัติเวอร์ชันของแพ็คเกจ
+func getPackageVersionsHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetInt("userID")
+
+		rows, err := dbPool.Query(ctx, `
+			SELECT `+servicePackageColumns+`
+			FROM service_packages
+			WHERE provider_id = $1
+			  AND COALESCE(root_package_id, package_id) = (
+			      SELECT COALESCE(root_package_id, package_id) FROM service_packages WHERE package_id = $2 AND provider_id = $1
+			  )
+			ORDER BY version DESC
+		`, userID, c.Param("packageId"))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch package versions"})
+			return
+		}
+		defer rows.Close()
+
+		versions := make([]*ServicePackage, 0)
+		for rows.Next() {
+			if p, err := scanServicePackage(rows); err == nil {
+				versions = append(versions, p)
+			}
+		}
+		if len(versions) == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Package not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"versions": versions})
+	}
+}
+
+// POST /provider/packages/:packageId/images - เพิ่มรูปแพ็คเกจ {"image_base64": "..."}
+func uploadPackageImageHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetInt("userID")
+
+		var req struct {
+			ImageBase64 string `json:"image_base64" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		pkg, err := loadOwnPackage(ctx, dbPool, userID, c.Param("packageId"), false)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Package not found"})
+			return
+		}
+		var count int
+		dbPool.QueryRow(ctx, `SELECT COUNT(*) FROM package_images WHERE package_id = $1`, pkg.PackageID).Scan(&count)
+		if count >= maxPackageImages {
+			c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("A package can have at most %d images", maxPackageImages)})
+			return
+		}
+
+		encoded := req.ImageBase64
+		if idx := strings.Index(encoded, ";base64,"); idx >= 0 {
+			encoded = encoded[idx+len(";base64,"):]
+		}
+		data, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(data) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid base64 data"})
+			return
+		}
+		if len(data) > maxPackageImageBytes {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Package image must be 5MB or smaller"})
+			return
+		}
+		ct := http.DetectContentType(data)
+		if !strings.HasPrefix(ct, "image/") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "File must be an image"})
+			return
+		}
+
+		objectKey := fmt.Sprintf("package-images/%d/%d/%s", userID, pkg.RootPackageID, uuid.NewString())
+		stored, err := uploadFileToStorage(ctx, objectKey, data, ct)
+		if err != nil {
+			log.Printf("❌ Failed to store package image: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store image"})
+			return
+		}
+
+		var img PackageImage
+		err = dbPool.QueryRow(ctx, `
+			INSERT INTO package_images (package_id, image_url, display_order)
+			VALUES ($1, $2, (SELECT COALESCE(MAX(display_order), 0) + 1 FROM package_images WHERE package_id = $1))
+			RETURNING image_id, package_id, image_url, display_order, created_at
+		`, pkg.PackageID, stored).Scan(&img.ImageID, &img.PackageID, &img.ImageURL, &img.DisplayOrder, &img.CreatedAt)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save image"})
+			return
+		}
+		img.ImageURL = storedFileURL(img.ImageURL)
+
+		c.JSON(http.StatusCreated, gin.H{"message": "Image uploaded", "image": img})
+	}
+}
+
+// DELETE /provider/packages/:packageId/images/:imageId
+func deletePackageImageHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetInt("userID")
+
+		tag, err := dbPool.Exec(ctx, `
+			DELETE FROM package_images pi
+			USING service_packages sp
+			WHERE pi.image_id = $1 AND pi.package_id = $2 AND sp.package_id = pi.package_id AND sp.provider_id = $3
+		`, c.Param("imageId"), c.Param("packageId"), userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete image"})
+			return
+		}
+		if tag.RowsAffected() == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Image not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Image deleted"})
+	}
+}
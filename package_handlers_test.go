@@ -0,0 +1,60 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPackageInputNormalize(t *testing.T) {
+	t.Run("defaults location type to both", func(t *testing.T) {
+		in := packageInput{PackageName: "  2 Hours ", Duration: 120, Price: 3000}
+		assert.NoError(t, in.normalize())
+		assert.Equal(t, "2 Hours", in.PackageName)
+		assert.Equal(t, PackageLocationBoth, in.LocationType)
+	})
+
+	t.Run("rejects invalid fields", func(t *testing.T) {
+		days := 0
+		cases := map[error]packageInput{
+			errPackageName:         {PackageName: " ", Duration: 60, Price: 1000},
+			errPackageDuration:     {PackageName: "Quick", Duration: 10, Price: 1000},
+			errPackagePrice:        {PackageName: "Free", Duration: 60, Price: -1},
+			errPackageLocationType: {PackageName: "1 Hour", Duration: 60, Price: 1000, LocationType: "hotel"},
+			errPackageAdvanceDays:  {PackageName: "1 Hour", Duration: 60, Price: 1000, MaxAdvanceDays: &days},
+		}
+		for want, in := range cases {
+			assert.ErrorIs(t, in.normalize(), want)
+		}
+	})
+}
+
+func TestPackageChangesBookedTerms(t *testing.T) {
+	desc := "Dinner and a movie"
+	current := &ServicePackage{PackageName: "Evening", Description: &desc, Duration: 180, Price: 4500}
+	base := packageInput{PackageName: "Evening", Description: &desc, Duration: 180, Price: 4500}
+
+	t.Run("display-only changes keep the version", func(t *testing.T) {
+		in := base
+		in.LocationType = PackageLocationIncall
+		days := 30
+		in.MaxAdvanceDays = &days
+		assert.False(t, in.changesBookedTerms(current))
+	})
+
+	t.Run("price or duration changes need a new version", func(t *testing.T) {
+		in := base
+		in.Price = 5000
+		assert.True(t, in.changesBookedTerms(current))
+
+		in = base
+		in.Duration = 240
+		assert.True(t, in.changesBookedTerms(current))
+	})
+
+	t.Run("clearing the description needs a new version", func(t *testing.T) {
+		in := base
+		in.Description = nil
+		assert.True(t, in.changesBookedTerms(current))
+	})
+}
@@ -231,6 +231,33 @@ func paymentWebhookHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.Handle
 					return
 				}
 				fmt.Println("✅ Booking extension webhook processed successfully")
+			} else if paymentType == "cancellation_fee" {
+				// --- Handle Outstanding Cancellation Fee ---
+				err := handleCancellationFeePayment(dbPool, ctx, checkoutSession)
+				if err != nil {
+					fmt.Printf("❌ Error processing cancellation fee payment: %v\n", err)
+					c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process cancellation fee payment", "details": err.Error()})
+					return
+				}
+				fmt.Println("✅ Cancellation fee webhook processed successfully")
+			} else if paymentType == "wallet_topup" {
+				// --- Handle Wallet Top-up ---
+				err := handleWalletTopupPayment(dbPool, ctx, checkoutSession)
+				if err != nil {
+					fmt.Printf("❌ Error processing wallet top-up: %v\n", err)
+					c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process wallet top-up", "details": err.Error()})
+					return
+				}
+				fmt.Println("✅ Wallet top-up webhook processed successfully")
+			} else if paymentType == "tip" {
+				// --- Handle Tip After Completed Booking ---
+				err := handleTipPayment(dbPool, ctx, checkoutSession)
+				if err != nil {
+					fmt.Printf("❌ Error processing tip payment: %v\n", err)
+					c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process tip payment", "details": err.Error()})
+					return
+				}
+				fmt.Println("✅ Tip webhook processed successfully")
 			} else if paymentType == "provider_tier_upgrade" {
 				// --- Handle Provider Tier Upgrade Payment ---
 				err := handleProviderTierUpgrade(dbPool, ctx, checkoutSession)
@@ -283,6 +310,20 @@ func paymentWebhookHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.Handle
 			}
 		}
 
+		// Checkout ที่หมดอายุโดยไม่ได้จ่าย → ปล่อยเงิน wallet ที่กันไว้
+		if event.Type == "checkout.session.expired" {
+			var checkoutSession stripe.CheckoutSession
+			if err := json.Unmarshal(event.Data.Raw, &checkoutSession); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to parse webhook JSON"})
+				return
+			}
+			if err := handleCheckoutSessionExpired(dbPool, ctx, checkoutSession); err != nil {
+				fmt.Printf("❌ Error processing expired checkout: %v\n", err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process expired checkout", "details": err.Error()})
+				return
+			}
+		}
+
 		c.JSON(http.StatusOK, gin.H{"status": "received"})
 	}
 }
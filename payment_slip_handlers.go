@@ -0,0 +1,400 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const maxSlipImageBytes = 5 << 20 // 5MB
+
+// --- POST /payments/:payment_reference/slip (อัปโหลดสลิปโอนเงิน PromptPay) ---
+func uploadPaymentSlipHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
+	verifier := newSlipVerifierFromEnv()
+
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			return
+		}
+		paymentRef := c.Param("payment_reference")
+
+		var req struct {
+			ImageBase64   string  `json:"image_base64" binding:"required"`
+			QRPayload     string  `json:"qr_payload"`     // mini-QR บนสลิป (ถ้าแอปอ่านได้)
+			Amount        float64 `json:"amount"`         // ยอดที่โอน (ตามที่ลูกค้าระบุ)
+			TransferredAt string  `json:"transferred_at"` // RFC3339
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		// 1. ตรวจสอบ Payment (ต้องเป็นลูกค้าของ booking นี้)
+		var paymentID int
+		var payment slipPaymentInfo
+		var paymentStatus string
+		var clientID int
+		err := dbPool.QueryRow(ctx, `
+			SELECT p.payment_id, p.amount, p.payment_status, p.created_at, p.expires_at, COALESCE(b.client_id, wt.user_id, bt.client_id)
+			FROM payments p
+			LEFT JOIN bookings b ON p.booking_id = b.booking_id
+			LEFT JOIN wallet_topups wt ON p.topup_id = wt.topup_id
+			LEFT JOIN booking_tips bt ON p.tip_id = bt.tip_id
+			WHERE p.payment_reference = $1
+		`, paymentRef).Scan(&paymentID, &payment.Amount, &paymentStatus, &payment.CreatedAt, &payment.ExpiresAt, &clientID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Payment not found"})
+			return
+		}
+		if clientID != userID.(int) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+			return
+		}
+		if paymentStatus == "completed" {
+			c.JSON(http.StatusConflict, gin.H{"error": "Payment has already been completed"})
+			return
+		}
+
+		// 2. ถอดรหัสรูปสลิป (รองรับทั้ง base64 ล้วนและ data URL)
+		encoded := req.ImageBase64
+		if idx := strings.Index(encoded, ";base64,"); idx >= 0 {
+			encoded = encoded[idx+len(";base64,"):]
+		}
+		image, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid base64 data"})
+			return
+		}
+		if len(image) > maxSlipImageBytes {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Slip image must be 5MB or smaller"})
+			return
+		}
+
+		var declaredAt *time.Time
+		if req.TransferredAt != "" {
+			t, err := time.Parse(time.RFC3339, req.TransferredAt)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "transferred_at must be RFC3339"})
+				return
+			}
+			declaredAt = &t
+		}
+
+		// 3. เก็บรูปสลิป
+		objectKey := fmt.Sprintf("payment-slips/%s/%s.jpg", paymentRef, uuid.NewString())
+		imageURL, err := uploadFileToStorage(ctx, objectKey, image, http.DetectContentType(image))
+		if err != nil {
+			log.Printf("❌ Failed to store payment slip: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store slip image"})
+			return
+		}
+
+		// 4. อ่านข้อมูลจากสลิป
+		slip, err := verifier.VerifySlip(ctx, SlipInput{
+			Image:          image,
+			QRPayload:      req.QRPayload,
+			DeclaredAmount: req.Amount,
+			DeclaredAt:     declaredAt,
+		})
+		if err != nil {
+			log.Printf("⚠️  Slip verification failed (%s): %v", verifier.Name(), err)
+			slip = &SlipData{Amount: req.Amount, TransferredAt: declaredAt, Verifier: verifier.Name()}
+		}
+
+		// 5. จับคู่กับ Payment
+		var duplicateRef bool
+		if slip.TransactionRef != "" {
+			dbPool.QueryRow(ctx, `
+				SELECT EXISTS(
+					SELECT 1 FROM payment_slips
+					WHERE extracted_transaction_ref = $1 AND match_status IN ('matched', 'approved')
+				)
+			`, slip.TransactionRef).Scan(&duplicateRef)
+		}
+		matchStatus, matchNotes := matchSlipToPayment(slip, payment, duplicateRef)
+
+		var transactionRef *string
+		if slip.TransactionRef != "" {
+			transactionRef = &slip.TransactionRef
+		}
+
+		// Unique index on used transaction refs makes a concurrent duplicate fail here
+		var slipID int
+		err = dbPool.QueryRow(ctx, `
+			INSERT INTO payment_slips (
+				payment_id, uploaded_by, image_url, qr_payload, verifier,
+				extracted_amount, extracted_transaction_ref, extracted_transferred_at,
+				sending_bank, is_verified, match_status, match_notes
+			)
+			VALUES ($1, $2, $3, NULLIF($4, ''), $5, $6, $7, $8, NULLIF($9, ''), $10, $11, $12)
+			RETURNING slip_id
+		`, paymentID, userID, imageURL, req.QRPayload, slip.Verifier,
+			slip.Amount, transactionRef, slip.TransferredAt,
+			slip.SendingBank, slip.Verified, matchStatus, matchNotes).Scan(&slipID)
+		if err != nil && matchStatus == SlipStatusMatched {
+			matchStatus, matchNotes = SlipStatusRejected, "transaction reference has already been used for another payment"
+			err = dbPool.QueryRow(ctx, `
+				INSERT INTO payment_slips (
+					payment_id, uploaded_by, image_url, verifier, extracted_amount,
+					extracted_transaction_ref, is_verified, match_status, match_notes
+				)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+				RETURNING slip_id
+			`, paymentID, userID, imageURL, slip.Verifier, slip.Amount,
+				transactionRef, slip.Verified, matchStatus, matchNotes).Scan(&slipID)
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save payment slip"})
+			return
+		}
+
+		dbPool.Exec(ctx, `
+			UPDATE payments SET slip_image = $1, slip_status = $2, updated_at = NOW() WHERE payment_id = $3
+		`, imageURL, matchStatus, paymentID)
+
+		// 6. สลิปตรง → ยืนยันการชำระเงินอัตโนมัติ
+		response := gin.H{
+			"slip_id":      slipID,
+			"match_status": matchStatus,
+			"match_notes":  matchNotes,
+			"slip":         slip,
+		}
+
+		if matchStatus == SlipStatusMatched {
+			result, err := completePromptPayPayment(dbPool, ctx, paymentID, slip.TransactionRef, &imageURL)
+			if err != nil && err != errPaymentNotPending {
+				log.Printf("❌ Auto-confirm failed for payment %s: %v", paymentRef, err)
+				dbPool.Exec(ctx, `
+					UPDATE payment_slips SET match_status = 'review', match_notes = $1 WHERE slip_id = $2
+				`, "auto-confirm failed: "+err.Error(), slipID)
+				response["match_status"] = SlipStatusReview
+				response["message"] = "Slip received and sent for manual review"
+				c.JSON(http.StatusAccepted, response)
+				return
+			}
+			if result != nil && result.Topup != nil {
+				response["topup_id"] = result.Topup.TopupID
+			} else if result != nil && result.Tip != nil {
+				response["tip_id"] = result.Tip.TipID
+			} else if result != nil {
+				response["booking_id"] = result.BookingID
+			}
+			response["message"] = "Payment confirmed automatically"
+			c.JSON(http.StatusOK, response)
+			return
+		}
+
+		if matchStatus == SlipStatusRejected {
+			response["message"] = "Slip rejected"
+			c.JSON(http.StatusUnprocessableEntity, response)
+			return
+		}
+
+		response["message"] = "Slip received and sent for manual review"
+		c.JSON(http.StatusAccepted, response)
+	}
+}
+
+// --- GET /admin/payment-slips (คิวตรวจสลิป) ---
+func adminGetPaymentSlipsHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		status := c.DefaultQuery("status", SlipStatusReview)
+
+		rows, err := dbPool.Query(ctx, `
+			SELECT
+				s.slip_id, s.payment_id, p.payment_reference, p.booking_id, p.topup_id, p.tip_id,
+				CASE WHEN p.topup_id IS NOT NULL THEN 'wallet_topup'
+				     WHEN p.tip_id IS NOT NULL THEN 'tip'
+				     ELSE 'booking' END,
+				p.amount, p.payment_status, s.uploaded_by, s.image_url, s.verifier, s.extracted_amount, s.extracted_transaction_ref,
+				s.extracted_transferred_at, s.sending_bank, s.is_verified, s.match_status, s.match_notes,
+				s.created_at
+			FROM payment_slips s
+			JOIN payments p ON s.payment_id = p.payment_id
+			WHERE s.match_status = $1
+			ORDER BY s.created_at ASC
+		`, status)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch payment slips"})
+			return
+		}
+		defer rows.Close()
+
+		slips := []gin.H{}
+		for rows.Next() {
+			var slipID, paymentID, uploadedBy int
+			var bookingID, topupID, tipID *int // สลิปเติมเงิน/ทิปไม่มี booking_id
+			var paymentRef, paymentType, paymentStatus, imageURL, verifier, matchStatus string
+			var expectedAmount float64
+			var extractedAmount *float64
+			var transactionRef, sendingBank, matchNotes *string
+			var transferredAt *time.Time
+			var isVerified bool
+			var createdAt time.Time
+
+			if err := rows.Scan(
+				&slipID, &paymentID, &paymentRef, &bookingID, &topupID, &tipID, &paymentType,
+				&expectedAmount, &paymentStatus, &uploadedBy, &imageURL, &verifier, &extractedAmount, &transactionRef,
+				&transferredAt, &sendingBank, &isVerified, &matchStatus, &matchNotes,
+				&createdAt,
+			); err != nil {
+				log.Printf("⚠️  Failed to scan payment slip: %v", err)
+				continue
+			}
+
+			slips = append(slips, gin.H{
+				"slip_id":           slipID,
+				"payment_id":        paymentID,
+				"payment_reference": paymentRef,
+				"payment_type":      paymentType,
+				"booking_id":        bookingID,
+				"topup_id":          topupID,
+				"tip_id":            tipID,
+				"expected_amount":   expectedAmount,
+				"payment_status":    paymentStatus,
+				"uploaded_by":       uploadedBy,
+				"image_url":         imageURL,
+				"verifier":          verifier,
+				"extracted_amount":  extractedAmount,
+				"transaction_ref":   transactionRef,
+				"transferred_at":    transferredAt,
+				"sending_bank":      sendingBank,
+				"is_verified":       isVerified,
+				"match_status":      matchStatus,
+				"match_notes":       matchNotes,
+				"created_at":        createdAt,
+			})
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"slips": slips,
+			"total": len(slips),
+		})
+	}
+}
+
+// --- POST /admin/payment-slips/:slip_id/review (อนุมัติ/ปฏิเสธสลิปจากคิว) ---
+func adminReviewPaymentSlipHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		adminID := c.GetInt("userID")
+		slipID, err := strconv.Atoi(c.Param("slip_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid slip ID"})
+			return
+		}
+
+		var req struct {
+			Action string `json:"action" binding:"required,oneof=approve reject"`
+			Notes  string `json:"notes"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		var paymentID, uploadedBy int
+		var imageURL, matchStatus string
+		var transactionRef *string
+		err = dbPool.QueryRow(ctx, `
+			SELECT payment_id, uploaded_by, image_url, extracted_transaction_ref, match_status
+			FROM payment_slips WHERE slip_id = $1
+		`, slipID).Scan(&paymentID, &uploadedBy, &imageURL, &transactionRef, &matchStatus)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Payment slip not found"})
+			return
+		}
+		if matchStatus != SlipStatusReview {
+			c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Slip is already %s", matchStatus)})
+			return
+		}
+
+		newStatus := SlipStatusRejected
+		if req.Action == "approve" {
+			newStatus = SlipStatusApproved
+		}
+
+		if err := setSlipReviewStatus(ctx, dbPool, slipID, paymentID, SlipStatusReview, newStatus, &adminID, req.Notes); err != nil {
+			if errors.Is(err, errSlipNotInReview) {
+				c.JSON(http.StatusConflict, gin.H{"error": "Slip has already been reviewed"})
+				return
+			}
+			c.JSON(http.StatusConflict, gin.H{"error": "Failed to update slip (transaction reference may already be used)"})
+			return
+		}
+
+		if newStatus == SlipStatusRejected {
+			CreateNotification(uploadedBy, "payment_failed", "Your payment slip could not be verified. Please upload a new slip or contact support.", map[string]interface{}{
+				"slip_id": slipID,
+				"notes":   req.Notes,
+			})
+			c.JSON(http.StatusOK, gin.H{"message": "Slip rejected", "slip_id": slipID})
+			return
+		}
+
+		ref := fmt.Sprintf("SLIP-%d", slipID)
+		if transactionRef != nil && *transactionRef != "" {
+			ref = *transactionRef
+		}
+		result, err := completePromptPayPayment(dbPool, ctx, paymentID, ref, &imageURL)
+		if err == errPaymentNotPending {
+			c.JSON(http.StatusOK, gin.H{"message": "Slip approved (payment was already completed)", "slip_id": slipID})
+			return
+		}
+		if err != nil {
+			// ยืนยันไม่สำเร็จ → คืนสลิปกลับเข้าคิวตรวจ ให้ admin อนุมัติใหม่ได้
+			log.Printf("❌ Confirm after slip %d approval failed: %v", slipID, err)
+			if rbErr := setSlipReviewStatus(ctx, dbPool, slipID, paymentID, SlipStatusApproved, SlipStatusReview, nil, "confirm failed: "+err.Error()); rbErr != nil {
+				log.Printf("❌ Could not return slip %d to review: %v", slipID, rbErr)
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to confirm payment; slip returned to review"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":    "Slip approved and payment confirmed",
+			"slip_id":    slipID,
+			"booking_id": result.BookingID,
+			"amount":     result.Amount,
+		})
+	}
+}
+
+var errSlipNotInReview = errors.New("payment slip is not in the expected review state")
+
+// setSlipReviewStatus moves a slip from `from` to `to` together with its payment's slip_status in
+// one transaction; a slip that is no longer in `from` (reviewed concurrently) is left untouched
+func setSlipReviewStatus(ctx context.Context, dbPool *pgxpool.Pool, slipID, paymentID int, from, to string, reviewerID *int, notes string) error {
+	tx, err := dbPool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx, `
+		UPDATE payment_slips
+		SET match_status = $1, reviewed_by = COALESCE($2, reviewed_by), reviewed_at = NOW(), review_notes = $3
+		WHERE slip_id = $4 AND match_status = $5
+	`, to, reviewerID, notes, slipID, from)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return errSlipNotInReview
+	}
+	if _, err := tx.Exec(ctx, `UPDATE payments SET slip_status = $1, updated_at = NOW() WHERE payment_id = $2`, to, paymentID); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
@@ -0,0 +1,311 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ================================
+// Bank Bulk-Transfer (Payout) Files
+// ================================
+
+// PayoutItem is one approved withdrawal inside a payout run
+type PayoutItem struct {
+	ItemID         int     `json:"item_id"`
+	WithdrawalID   int     `json:"withdrawal_id"`
+	UserID         int     `json:"user_id"`
+	Amount         float64 `json:"amount"`          // net amount to transfer (after withholding tax)
+	WithholdingTax float64 `json:"withholding_tax"` // ภาษีหัก ณ ที่จ่าย
+	BankName       string  `json:"bank_name"`
+	BankCode       string  `json:"bank_code"`
+	AccountNumber  string  `json:"account_number"`
+	AccountName    string  `json:"account_name"`
+	Reference      string  `json:"reference"`
+}
+
+// PayoutResult is one line of a bank result file
+type PayoutResult struct {
+	Reference     string
+	Success       bool
+	BankReference string
+	FailureReason string
+}
+
+// PayoutRunHeader carries the run-level data written into file headers
+type PayoutRunHeader struct {
+	RunID            int
+	CreatedAt        time.Time
+	DebitAccount     string // บัญชีต้นทางของแพลตฟอร์ม
+	DebitAccountName string
+}
+
+// payoutFileFormat knows how to write a bulk-transfer file and read its result file
+type payoutFileFormat struct {
+	Name        string
+	Description string
+	Extension   string
+	ContentType string
+	Generate    func(header PayoutRunHeader, items []PayoutItem) ([]byte, error)
+	ParseResult func(data []byte) ([]PayoutResult, error)
+}
+
+// payoutFormats is the registry of supported bank file layouts
+var payoutFormats = map[string]payoutFileFormat{
+	"generic_csv": {
+		Name:        "generic_csv",
+		Description: "Generic CSV (one row per transfer)",
+		Extension:   "csv",
+		ContentType: "text/csv",
+		Generate:    generateGenericPayoutCSV,
+		ParseResult: parseGenericPayoutResultCSV,
+	},
+	"kbank_bulk": {
+		Name:        "kbank_bulk",
+		Description: "KBank bulk payment (fixed-width H/D/T records, TIS-620)",
+		Extension:   "txt",
+		ContentType: "text/plain; charset=windows-874",
+		Generate:    generateKBankBulkFile,
+		ParseResult: parseKBankBulkResult,
+	},
+}
+
+// Thai bank codes (Bank of Thailand 3-digit codes) keyed by the short code stored in bank_accounts
+var thaiBankCodes = map[string]string{
+	"BBL":   "002",
+	"KBANK": "004",
+	"KTB":   "006",
+	"TTB":   "011",
+	"SCB":   "014",
+	"CIMB":  "022",
+	"UOB":   "024",
+	"BAY":   "025",
+	"GSB":   "030",
+	"GHB":   "033",
+	"BAAC":  "034",
+	"TISCO": "067",
+	"KKP":   "069",
+	"LHB":   "073",
+}
+
+// thaiBankNumericCode converts "KBANK" / "004" / "4" to the 3-digit code
+func thaiBankNumericCode(code string) string {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if numeric, ok := thaiBankCodes[code]; ok {
+		return numeric
+	}
+	if n, err := strconv.Atoi(code); err == nil && n > 0 && n < 1000 {
+		return fmt.Sprintf("%03d", n)
+	}
+	return ""
+}
+
+// payoutReference is the per-transfer reference echoed back in bank result files
+func payoutReference(runID, withdrawalID int) string {
+	return fmt.Sprintf("PO%05dW%08d", runID, withdrawalID)
+}
+
+// --- Generic CSV ---
+
+func generateGenericPayoutCSV(_ PayoutRunHeader, items []PayoutItem) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"reference", "withdrawal_id", "bank_code", "bank_name", "account_number", "account_name", "amount"})
+	for _, item := range items {
+		w.Write([]string{
+			item.Reference,
+			strconv.Itoa(item.WithdrawalID),
+			thaiBankNumericCode(item.BankCode),
+			item.BankName,
+			onlyDigits(item.AccountNumber),
+			item.AccountName,
+			fmt.Sprintf("%.2f", item.Amount),
+		})
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// parseGenericPayoutResultCSV reads "reference,status,bank_reference,failure_reason"
+// (status: success/completed/ok or failed/rejected)
+func parseGenericPayoutResultCSV(data []byte) ([]PayoutResult, error) {
+	r := csv.NewReader(bytes.NewReader(data))
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+
+	var results []PayoutResult
+	for line := 1; ; line++ {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if line == 1 && strings.EqualFold(record[0], "reference") {
+			continue // header
+		}
+		if len(record) < 2 {
+			return nil, fmt.Errorf("line %d: expected at least reference and status", line)
+		}
+
+		result := PayoutResult{Reference: strings.TrimSpace(record[0])}
+		switch strings.ToLower(strings.TrimSpace(record[1])) {
+		case "success", "completed", "ok":
+			result.Success = true
+		case "failed", "fail", "rejected", "error":
+			result.Success = false
+		default:
+			return nil, fmt.Errorf("line %d: unknown status %q", line, record[1])
+		}
+		if len(record) > 2 {
+			result.BankReference = strings.TrimSpace(record[2])
+		}
+		if len(record) > 3 {
+			result.FailureReason = strings.TrimSpace(record[3])
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// --- KBank bulk payment (fixed-width) ---
+//
+// Layout of the KBank K-Cash Connect Plus bulk payment (direct credit) text file as issued to the
+// platform's corporate account; check field positions against that spec when KBank revises it.
+// Widths are in bytes of the TIS-620 (Windows-874) encoded file, not in characters: a Thai
+// name is 1 byte per character there but 3 in UTF-8.
+//
+// H | record type(1) | company account(10) | company name(40) | effective date DDMMYYYY(8) | item count(6) | total satang(15)
+// D | record type(1) | sequence(6) | bank code(3) | account(20, left) | amount satang(15) | name(50, left) | reference(20, left)
+// T | record type(1) | item count(6) | total satang(15)
+
+func generateKBankBulkFile(header PayoutRunHeader, items []PayoutItem) ([]byte, error) {
+	var buf bytes.Buffer
+	var totalSatang int64
+
+	details := make([]string, 0, len(items))
+	for i, item := range items {
+		bankCode := thaiBankNumericCode(item.BankCode)
+		if bankCode == "" {
+			return nil, fmt.Errorf("withdrawal %d: unknown bank code %q", item.WithdrawalID, item.BankCode)
+		}
+		satang := int64(math.Round(item.Amount * 100))
+		totalSatang += satang
+		details = append(details, "D"+
+			fmt.Sprintf("%06d", i+1)+
+			bankCode+
+			padRight(onlyDigits(item.AccountNumber), 20)+
+			fmt.Sprintf("%015d", satang)+
+			padRight(item.AccountName, 50)+
+			padRight(item.Reference, 20))
+	}
+
+	buf.WriteString("H" +
+		padRight(onlyDigits(header.DebitAccount), 10) +
+		padRight(header.DebitAccountName, 40) +
+		header.CreatedAt.Format("02012006") +
+		fmt.Sprintf("%06d", len(items)) +
+		fmt.Sprintf("%015d", totalSatang) + "\r\n")
+	for _, d := range details {
+		buf.WriteString(d + "\r\n")
+	}
+	buf.WriteString("T" + fmt.Sprintf("%06d", len(items)) + fmt.Sprintf("%015d", totalSatang) + "\r\n")
+	return buf.Bytes(), nil
+}
+
+// parseKBankBulkResult reads the bank's return file (TIS-620, byte positions):
+// D | sequence(6) | reference(20) | status(1: 'S' success / 'F' failed) | bank reference(20) | reason(rest)
+func parseKBankBulkResult(data []byte) ([]PayoutResult, error) {
+	var results []PayoutResult
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		record := strings.TrimRight(scanner.Text(), "\r")
+		if record == "" || record[0] != 'D' {
+			continue // header / trailer
+		}
+		if len(record) < 28 {
+			return nil, fmt.Errorf("line %d: detail record too short", line)
+		}
+
+		result := PayoutResult{Reference: strings.TrimSpace(record[7:27])}
+		switch record[27] {
+		case 'S':
+			result.Success = true
+		case 'F':
+			result.Success = false
+		default:
+			return nil, fmt.Errorf("line %d: unknown status %q", line, record[27])
+		}
+		if len(record) > 28 {
+			end := min(len(record), 48)
+			result.BankReference = strings.TrimSpace(record[28:end])
+		}
+		if len(record) > 48 {
+			result.FailureReason = strings.TrimSpace(decodeTIS620(record[48:]))
+		}
+		results = append(results, result)
+	}
+	return results, scanner.Err()
+}
+
+// padRight encodes s as TIS-620 and pads or truncates it to exactly n bytes (fixed-width bank
+// files are positioned by byte)
+func padRight(s string, n int) string {
+	b := encodeTIS620(s)
+	if len(b) >= n {
+		return string(b[:n])
+	}
+	return string(b) + strings.Repeat(" ", n-len(b))
+}
+
+// encodeTIS620 converts UTF-8 to TIS-620 / Windows-874: ASCII as is, Thai U+0E01-U+0E5B to
+// 0xA1-0xFB; anything else becomes '?'
+func encodeTIS620(s string) []byte {
+	b := make([]byte, 0, len(s))
+	for _, r := range s {
+		switch {
+		case r < 0x80:
+			b = append(b, byte(r))
+		case r >= 0x0E01 && r <= 0x0E5B && (r <= 0x0E3A || r >= 0x0E3F):
+			b = append(b, byte(r-0x0E00+0xA0))
+		default:
+			b = append(b, '?')
+		}
+	}
+	return b
+}
+
+// decodeTIS620 is the reverse of encodeTIS620
+func decodeTIS620(s string) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c < 0x80:
+			sb.WriteByte(c)
+		case c >= 0xA1 && c <= 0xFB && (c <= 0xDA || c >= 0xDF):
+			sb.WriteRune(rune(c) - 0xA0 + 0x0E00)
+		default:
+			sb.WriteRune(0xFFFD)
+		}
+	}
+	return sb.String()
+}
+
+// payoutFormatNames lists the registered formats in a stable order
+func payoutFormatNames() []string {
+	names := make([]string, 0, len(payoutFormats))
+	for name := range payoutFormats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
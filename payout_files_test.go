@@ -0,0 +1,116 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testPayoutItems() []PayoutItem {
+	return []PayoutItem{
+		{WithdrawalID: 12, Amount: 1500.5, BankName: "ธนาคารกสิกรไทย", BankCode: "KBANK", AccountNumber: "123-4-56789-0", AccountName: "สมชาย ใจดี", Reference: payoutReference(7, 12)},
+		{WithdrawalID: 15, Amount: 872.25, BankName: "ธนาคารไทยพาณิชย์", BankCode: "014", AccountNumber: "9876543210", AccountName: "Jane Doe", Reference: payoutReference(7, 15)},
+	}
+}
+
+// Test bank code normalization
+func TestThaiBankNumericCode(t *testing.T) {
+	assert.Equal(t, "004", thaiBankNumericCode("kbank"))
+	assert.Equal(t, "014", thaiBankNumericCode("014"))
+	assert.Equal(t, "002", thaiBankNumericCode("2"))
+	assert.Equal(t, "", thaiBankNumericCode("UNKNOWN"))
+}
+
+// Test generic CSV payout files
+func TestGenericPayoutCSV(t *testing.T) {
+	t.Run("Generate", func(t *testing.T) {
+		data, err := generateGenericPayoutCSV(PayoutRunHeader{}, testPayoutItems())
+		assert.NoError(t, err)
+		lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+		assert.Len(t, lines, 3)
+		assert.Equal(t, "PO00007W00000012,12,004,ธนาคารกสิกรไทย,1234567890,สมชาย ใจดี,1500.50", lines[1])
+	})
+
+	t.Run("Parse Result", func(t *testing.T) {
+		results, err := parseGenericPayoutResultCSV([]byte("reference,status,bank_reference,failure_reason\n" +
+			"PO00007W00000012,success,TRX001,\n" +
+			"PO00007W00000015,failed,,Account closed\n"))
+		assert.NoError(t, err)
+		assert.Len(t, results, 2)
+		assert.True(t, results[0].Success)
+		assert.Equal(t, "TRX001", results[0].BankReference)
+		assert.False(t, results[1].Success)
+		assert.Equal(t, "Account closed", results[1].FailureReason)
+	})
+
+	t.Run("Unknown Status", func(t *testing.T) {
+		_, err := parseGenericPayoutResultCSV([]byte("PO00007W00000012,maybe\n"))
+		assert.Error(t, err)
+	})
+}
+
+// Test KBank fixed-width payout files
+func TestKBankBulkFile(t *testing.T) {
+	header := PayoutRunHeader{RunID: 7, CreatedAt: time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC), DebitAccount: "111-2-33333-4", DebitAccountName: "SkillMatch Co., Ltd."}
+
+	t.Run("Generate", func(t *testing.T) {
+		data, err := generateKBankBulkFile(header, testPayoutItems())
+		assert.NoError(t, err)
+		lines := strings.Split(strings.TrimRight(string(data), "\r\n"), "\r\n")
+		assert.Len(t, lines, 4)
+
+		assert.Equal(t, "H1112333334"+padRight("SkillMatch Co., Ltd.", 40)+"01032025"+"000002"+"000000000237275", lines[0])
+		assert.Equal(t, "D000001004"+padRight("1234567890", 20)+"000000000150050"+padRight("สมชาย ใจดี", 50)+padRight("PO00007W00000012", 20), lines[1])
+		assert.Equal(t, "T000002000000000237275", lines[3])
+	})
+
+	t.Run("Thai Names Keep Fixed Byte Widths", func(t *testing.T) {
+		items := testPayoutItems()
+		items[1].AccountName = "นางสาวกาญจนาภรณ์ ศรีสุวรรณวงศ์ไพบูลย์ชัยมงคลเจริญสุขยิ่งยืนนาน" // ยาวเกิน 50 ตัวอักษร
+		thaiHeader := header
+		thaiHeader.DebitAccountName = "บริษัท สกิลแมทช์ จำกัด"
+		data, err := generateKBankBulkFile(thaiHeader, items)
+		assert.NoError(t, err)
+		lines := strings.Split(strings.TrimRight(string(data), "\r\n"), "\r\n")
+		assert.Len(t, lines, 4)
+
+		assert.Len(t, lines[0], 80)
+		assert.Len(t, lines[1], 115)
+		assert.Len(t, lines[2], 115)
+		assert.Len(t, lines[3], 22)
+		// ชื่ออยู่ที่ byte 45-95 เสมอ และ reference ไม่เลื่อนตำแหน่ง
+		assert.Equal(t, "สมชาย ใจดี", strings.TrimSpace(decodeTIS620(lines[1][45:95])))
+		assert.Equal(t, "PO00007W00000012", strings.TrimSpace(lines[1][95:115]))
+		assert.Equal(t, "PO00007W00000015", strings.TrimSpace(lines[2][95:115]))
+		assert.Equal(t, []byte{0xCA, 0xC1, 0xAA, 0xD2, 0xC2}, []byte(lines[1][45:50])) // สมชาย ใน TIS-620
+		assert.Equal(t, "บริษัท สกิลแมทช์ จำกัด", strings.TrimSpace(decodeTIS620(lines[0][11:51])))
+	})
+
+	t.Run("Unknown Bank Code", func(t *testing.T) {
+		items := testPayoutItems()
+		items[0].BankCode = "XYZ"
+		_, err := generateKBankBulkFile(header, items)
+		assert.Error(t, err)
+	})
+
+	t.Run("Parse Result", func(t *testing.T) {
+		data := "H000002\r\n" +
+			"D000001" + padRight("PO00007W00000012", 20) + "S" + padRight("KB20250301001", 20) + "\r\n" +
+			"D000002" + padRight("PO00007W00000015", 20) + "F" + padRight("", 20) + "INVALID ACCOUNT\r\n" +
+			"D000003" + padRight("PO00007W00000016", 20) + "F" + padRight("", 20) + padRight("บัญชีปิดแล้ว", 12) + "\r\n" +
+			"T000003\r\n"
+		results, err := parseKBankBulkResult([]byte(data))
+		assert.NoError(t, err)
+		assert.Len(t, results, 3)
+		assert.Equal(t, "บัญชีปิดแล้ว", results[2].FailureReason)
+		assert.Equal(t, PayoutResult{Reference: "PO00007W00000012", Success: true, BankReference: "KB20250301001"}, results[0])
+		assert.Equal(t, PayoutResult{Reference: "PO00007W00000015", Success: false, FailureReason: "INVALID ACCOUNT"}, results[1])
+	})
+
+	t.Run("Bad Status", func(t *testing.T) {
+		_, err := parseKBankBulkResult([]byte("D000001" + padRight("PO00007W00000012", 20) + "X\r\n"))
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,567 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const maxPayoutResultFileBytes = 10 << 20 // 10MB
+
+// ================================
+// Admin: Bulk Payouts (ไฟล์โอนเงินหลายรายการ)
+// ================================
+
+// --- GET /admin/payouts/formats ---
+func adminGetPayoutFormatsHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		formats := make([]gin.H, 0, len(payoutFormats))
+		for _, name := range payoutFormatNames() {
+			f := payoutFormats[name]
+			formats = append(formats, gin.H{
+				"format":      f.Name,
+				"description": f.Description,
+				"extension":   f.Extension,
+			})
+		}
+		c.JSON(http.StatusOK, gin.H{"formats": formats})
+	}
+}
+
+// --- POST /admin/payouts/runs (สร้างไฟล์โอนเงินจากคำขอถอนที่อนุมัติแล้ว) ---
+func adminCreatePayoutRunHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		adminID, _ := c.Get("userID")
+
+		var req struct {
+			Format        string `json:"format" binding:"required"`
+			WithdrawalIDs []int  `json:"withdrawal_ids"` // ว่าง = ทุกรายการที่อนุมัติแล้ว
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		format, ok := payoutFormats[req.Format]
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown payout format", "formats": payoutFormatNames()})
+			return
+		}
+
+		tx, err := dbPool.Begin(ctx)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+			return
+		}
+		defer tx.Rollback(ctx)
+
+		// 1. ล็อกคำขอถอนที่อนุมัติแล้วและยังไม่อยู่ในไฟล์ใด (SKIP LOCKED กันสร้างไฟล์ซ้อนกัน)
+		query := `
+			SELECT w.withdrawal_id, w.user_id, w.net_amount,
+			       ba.bank_name, COALESCE(ba.bank_code, ''), ba.account_number, ba.account_name
+			FROM withdrawals w
+			JOIN bank_accounts ba ON w.bank_account_id = ba.bank_account_id
+			WHERE w.status = 'approved' AND w.payout_run_id IS NULL
+		`
+		args := []interface{}{}
+		if len(req.WithdrawalIDs) > 0 {
+			query += ` AND w.withdrawal_id = ANY($1)`
+			args = append(args, req.WithdrawalIDs)
+		}
+		query += ` ORDER BY w.approved_at ASC FOR UPDATE OF w SKIP LOCKED`
+
+		rows, err := tx.Query(ctx, query, args...)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch withdrawals", "details": err.Error()})
+			return
+		}
+		var items []PayoutItem
+		for rows.Next() {
+			var item PayoutItem
+			if err := rows.Scan(&item.WithdrawalID, &item.UserID, &item.Amount,
+				&item.BankName, &item.BankCode, &item.AccountNumber, &item.AccountName); err != nil {
+				rows.Close()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read withdrawals", "details": err.Error()})
+				return
+			}
+			items = append(items, item)
+		}
+		rows.Close()
+
+		if len(items) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "No approved withdrawals waiting for payout"})
+			return
+		}
+
+		// 2. สร้าง payout run
+		now := time.Now()
+		var runID int
+		err = tx.QueryRow(ctx, `
+			INSERT INTO payout_runs (file_format, status, file_name, file_content, created_by, created_at)
+			VALUES ($1, 'processing', '', ''::bytea, $2, $3)
+			RETURNING run_id
+		`, format.Name, adminID, now).Scan(&runID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create payout run", "details": err.Error()})
+			return
+		}
+
+		var total float64
+		for i := range items {
+			// หักภาษี ณ ที่จ่ายก่อนโอน (ยอดในไฟล์ = ยอดสุทธิหลังหักภาษี)
+			tax, err := assessWithdrawalWithholding(ctx, tx, items[i].WithdrawalID, now)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to assess withholding tax", "details": err.Error()})
+				return
+			}
+			items[i].WithholdingTax = tax
+			items[i].Amount -= tax
+			items[i].Reference = payoutReference(runID, items[i].WithdrawalID)
+			total += items[i].Amount
+		}
+
+		header := PayoutRunHeader{RunID: runID, CreatedAt: now}
+		tx.QueryRow(ctx, `
+			SELECT account_number, account_name FROM platform_bank_accounts
+			WHERE is_default = true AND is_active = true
+			LIMIT 1
+		`).Scan(&header.DebitAccount, &header.DebitAccountName)
+
+		content, err := format.Generate(header, items)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to generate payout file", "details": err.Error()})
+			return
+		}
+		fileName := fmt.Sprintf("payout_%05d_%s.%s", runID, now.Format("20060102"), format.Extension)
+
+		_, err = tx.Exec(ctx, `
+			UPDATE payout_runs
+			SET item_count = $1, total_amount = $2, file_name = $3, file_content = $4
+			WHERE run_id = $5
+		`, len(items), total, fileName, content, runID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save payout file", "details": err.Error()})
+			return
+		}
+
+		// 3. บันทึกรายการ + เปลี่ยนสถานะคำขอถอนเป็น processing
+		for _, item := range items {
+			_, err = tx.Exec(ctx, `
+				INSERT INTO payout_run_items (run_id, withdrawal_id, user_id, amount, withholding_tax, bank_code, account_number, account_name, reference)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+			`, runID, item.WithdrawalID, item.UserID, item.Amount, item.WithholdingTax, item.BankCode, item.AccountNumber, item.AccountName, item.Reference)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save payout item", "details": err.Error()})
+				return
+			}
+
+			_, err = tx.Exec(ctx, `
+				UPDATE withdrawals
+				SET status = 'processing', processed_at = $1, payout_run_id = $2
+				WHERE withdrawal_id = $3
+			`, now, runID, item.WithdrawalID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update withdrawal", "details": err.Error()})
+				return
+			}
+		}
+
+		if err = tx.Commit(ctx); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{
+			"message":      "Payout file generated",
+			"run_id":       runID,
+			"format":       format.Name,
+			"file_name":    fileName,
+			"item_count":   len(items),
+			"total_amount": total,
+			"download_url": fmt.Sprintf("/admin/payouts/runs/%d/file", runID),
+		})
+	}
+}
+
+// --- GET /admin/payouts/runs ---
+func adminGetPayoutRunsHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		rows, err := dbPool.Query(ctx, `
+			SELECT run_id, file_format, status, item_count, total_amount, file_name,
+			       created_by, created_at, results_uploaded_at
+			FROM payout_runs
+			ORDER BY created_at DESC
+			LIMIT 100
+		`)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch payout runs"})
+			return
+		}
+		defer rows.Close()
+
+		runs := make([]gin.H, 0)
+		for rows.Next() {
+			var (
+				runID, itemCount         int
+				fileFormat, status, name string
+				total                    float64
+				createdBy                *int
+				createdAt                time.Time
+				resultsUploadedAt        *time.Time
+			)
+			if err := rows.Scan(&runID, &fileFormat, &status, &itemCount, &total, &name,
+				&createdBy, &createdAt, &resultsUploadedAt); err != nil {
+				continue
+			}
+			runs = append(runs, gin.H{
+				"run_id":              runID,
+				"format":              fileFormat,
+				"status":              status,
+				"item_count":          itemCount,
+				"total_amount":        total,
+				"file_name":           name,
+				"created_by":          createdBy,
+				"created_at":          createdAt,
+				"results_uploaded_at": resultsUploadedAt,
+			})
+		}
+
+		c.JSON(http.StatusOK, gin.H{"runs": runs, "total": len(runs)})
+	}
+}
+
+// --- GET /admin/payouts/runs/:run_id ---
+func adminGetPayoutRunHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		runID, err := strconv.Atoi(c.Param("run_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid run ID"})
+			return
+		}
+
+		var (
+			fileFormat, status, name string
+			itemCount                int
+			total                    float64
+			createdAt                time.Time
+			resultsUploadedAt        *time.Time
+		)
+		err = dbPool.QueryRow(ctx, `
+			SELECT file_format, status, item_count, total_amount, file_name, created_at, results_uploaded_at
+			FROM payout_runs WHERE run_id = $1
+		`, runID).Scan(&fileFormat, &status, &itemCount, &total, &name, &createdAt, &resultsUploadedAt)
+		if err == pgx.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Payout run not found"})
+			return
+		} else if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch payout run"})
+			return
+		}
+
+		rows, err := dbPool.Query(ctx, `
+			SELECT item_id, withdrawal_id, user_id, amount, withholding_tax, COALESCE(bank_code, ''), account_number, account_name,
+			       reference, status, bank_reference, failure_reason
+			FROM payout_run_items
+			WHERE run_id = $1
+			ORDER BY item_id
+		`, runID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch payout items"})
+			return
+		}
+		defer rows.Close()
+
+		items := make([]gin.H, 0)
+		for rows.Next() {
+			var item PayoutItem
+			var itemStatus string
+			var bankReference, failureReason *string
+			if err := rows.Scan(&item.ItemID, &item.WithdrawalID, &item.UserID, &item.Amount, &item.WithholdingTax, &item.BankCode,
+				&item.AccountNumber, &item.AccountName, &item.Reference, &itemStatus, &bankReference, &failureReason); err != nil {
+				continue
+			}
+			items = append(items, gin.H{
+				"item_id":         item.ItemID,
+				"withdrawal_id":   item.WithdrawalID,
+				"user_id":         item.UserID,
+				"amount":          item.Amount,
+				"withholding_tax": item.WithholdingTax,
+				"bank_code":       item.BankCode,
+				"account_number":  item.AccountNumber,
+				"account_name":    item.AccountName,
+				"reference":       item.Reference,
+				"status":          itemStatus,
+				"bank_reference":  bankReference,
+				"failure_reason":  failureReason,
+			})
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"run_id":              runID,
+			"format":              fileFormat,
+			"status":              status,
+			"item_count":          itemCount,
+			"total_amount":        total,
+			"file_name":           name,
+			"created_at":          createdAt,
+			"results_uploaded_at": resultsUploadedAt,
+			"items":               items,
+		})
+	}
+}
+
+// --- GET /admin/payouts/runs/:run_id/file (ดาวน์โหลดไฟล์ส่งธนาคาร) ---
+func adminDownloadPayoutFileHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		runID, err := strconv.Atoi(c.Param("run_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid run ID"})
+			return
+		}
+
+		var fileFormat, fileName string
+		var content []byte
+		err = dbPool.QueryRow(ctx, `
+			SELECT file_format, file_name, file_content FROM payout_runs WHERE run_id = $1
+		`, runID).Scan(&fileFormat, &fileName, &content)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Payout run not found"})
+			return
+		}
+
+		contentType := "application/octet-stream"
+		if f, ok := payoutFormats[fileFormat]; ok {
+			contentType = f.ContentType
+		}
+		c.Header("Content-Type", contentType)
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, fileName))
+		c.Data(http.StatusOK, contentType, content)
+	}
+}
+
+// --- POST /admin/payouts/runs/:run_id/results (อัปโหลดไฟล์ผลการโอนจากธนาคาร) ---
+func adminUploadPayoutResultsHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		adminID, _ := c.Get("userID")
+		runID, err := strconv.Atoi(c.Param("run_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid run ID"})
+			return
+		}
+
+		fileHeader, err := c.FormFile("file")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Result file is required"})
+			return
+		}
+		if fileHeader.Size > maxPayoutResultFileBytes {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Result file must be 10MB or smaller"})
+			return
+		}
+		file, err := fileHeader.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read result file"})
+			return
+		}
+		data, err := io.ReadAll(file)
+		file.Close()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read result file"})
+			return
+		}
+
+		tx, err := dbPool.Begin(ctx)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+			return
+		}
+		defer tx.Rollback(ctx)
+
+		var fileFormat string
+		err = tx.QueryRow(ctx, `
+			SELECT file_format FROM payout_runs WHERE run_id = $1 FOR UPDATE
+		`, runID).Scan(&fileFormat)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Payout run not found"})
+			return
+		}
+		format, ok := payoutFormats[fileFormat]
+		if !ok {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Payout run has an unknown format"})
+			return
+		}
+
+		results, err := format.ParseResult(data)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid result file", "details": err.Error()})
+			return
+		}
+
+		type payoutOutcome struct {
+			userID       int
+			withdrawalID int
+			amount       float64
+			success      bool
+		}
+		var outcomes []payoutOutcome
+		unmatched := make([]string, 0)
+		now := time.Now()
+
+		for _, result := range results {
+			var itemID, withdrawalID, userID int
+			var requestedAmount, netAmount float64
+			err := tx.QueryRow(ctx, `
+				SELECT i.item_id, i.withdrawal_id, w.user_id, w.requested_amount, i.amount
+				FROM payout_run_items i
+				JOIN withdrawals w ON w.withdrawal_id = i.withdrawal_id
+				WHERE i.run_id = $1 AND i.reference = $2 AND i.status = 'processing'
+				FOR UPDATE OF i, w
+			`, runID, result.Reference).Scan(&itemID, &withdrawalID, &userID, &requestedAmount, &netAmount)
+			if err == pgx.ErrNoRows {
+				unmatched = append(unmatched, result.Reference) // ไม่อยู่ในไฟล์นี้ หรือประมวลผลไปแล้ว
+				continue
+			} else if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to look up payout item", "details": err.Error()})
+				return
+			}
+
+			if result.Success {
+				_, err = tx.Exec(ctx, `
+					UPDATE payout_run_items SET status = 'completed', bank_reference = $1, updated_at = $2
+					WHERE item_id = $3
+				`, result.BankReference, now, itemID)
+				if err == nil {
+					_, err = tx.Exec(ctx, `
+						UPDATE withdrawals
+						SET status = 'completed', completed_at = $1, transfer_reference = $2
+						WHERE withdrawal_id = $3
+					`, now, result.BankReference, withdrawalID)
+				}
+				if err == nil {
+					_, err = tx.Exec(ctx, `
+						UPDATE wallets
+						SET total_withdrawn = total_withdrawn + $1,
+						    last_updated = CURRENT_TIMESTAMP
+						WHERE user_id = $2
+					`, requestedAmount, userID)
+				}
+				if err == nil {
+					_, err = tx.Exec(ctx, `
+						UPDATE transactions
+						SET status = 'completed', processed_at = $1
+						WHERE withdrawal_id = $2 AND type = 'withdrawal'
+					`, now, withdrawalID)
+				}
+				if err == nil {
+					_, err = settleWithdrawalWithholding(ctx, tx, withdrawalID, now)
+				}
+			} else {
+				// โอนไม่สำเร็จ → คืนเงินเข้ากระเป๋า
+				_, err = tx.Exec(ctx, `
+					UPDATE payout_run_items SET status = 'failed', bank_reference = NULLIF($1, ''), failure_reason = $2, updated_at = $3
+					WHERE item_id = $4
+				`, result.BankReference, result.FailureReason, now, itemID)
+				if err == nil {
+					_, err = tx.Exec(ctx, `
+						UPDATE withdrawals
+						SET status = 'failed', rejection_reason = $1
+						WHERE withdrawal_id = $2
+					`, result.FailureReason, withdrawalID)
+				}
+				if err == nil {
+					_, err = tx.Exec(ctx, `
+						UPDATE wallets
+						SET available_balance = available_balance + $1,
+						    last_updated = CURRENT_TIMESTAMP
+						WHERE user_id = $2
+					`, requestedAmount, userID)
+				}
+				if err == nil {
+					_, err = tx.Exec(ctx, `
+						UPDATE transactions
+						SET status = 'failed'
+						WHERE withdrawal_id = $1 AND type = 'withdrawal'
+					`, withdrawalID)
+				}
+			}
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to apply payout result", "details": err.Error()})
+				return
+			}
+
+			outcomes = append(outcomes, payoutOutcome{userID: userID, withdrawalID: withdrawalID, amount: netAmount, success: result.Success})
+		}
+
+		// สรุปสถานะของ run
+		var processing, completed, failed int
+		err = tx.QueryRow(ctx, `
+			SELECT COUNT(*) FILTER (WHERE status = 'processing'),
+			       COUNT(*) FILTER (WHERE status = 'completed'),
+			       COUNT(*) FILTER (WHERE status = 'failed')
+			FROM payout_run_items WHERE run_id = $1
+		`, runID).Scan(&processing, &completed, &failed)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to summarize payout run"})
+			return
+		}
+		runStatus := "processing"
+		if processing == 0 {
+			switch {
+			case failed == 0:
+				runStatus = "completed"
+			case completed == 0:
+				runStatus = "failed"
+			default:
+				runStatus = "partially_failed"
+			}
+		}
+
+		_, err = tx.Exec(ctx, `
+			UPDATE payout_runs
+			SET status = $1, result_file_name = $2, results_uploaded_by = $3, results_uploaded_at = $4
+			WHERE run_id = $5
+		`, runStatus, fileHeader.Filename, adminID, now, runID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update payout run"})
+			return
+		}
+
+		if err = tx.Commit(ctx); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+			return
+		}
+
+		// แจ้งเตือน provider หลัง commit
+		for _, o := range outcomes {
+			metadata := map[string]interface{}{"withdrawal_id": o.withdrawalID, "amount": o.amount}
+			var notifyErr error
+			if o.success {
+				notifyErr = CreateNotification(o.userID, "withdrawal_completed",
+					fmt.Sprintf("โอนเงิน ฿%.2f เข้าบัญชีของคุณเรียบร้อยแล้ว", o.amount), metadata)
+			} else {
+				notifyErr = CreateNotification(o.userID, "withdrawal_failed",
+					fmt.Sprintf("การโอนเงิน ฿%.2f ไม่สำเร็จ ยอดเงินถูกคืนเข้ากระเป๋าแล้ว กรุณาตรวจสอบบัญชีธนาคาร", o.amount), metadata)
+			}
+			if notifyErr != nil {
+				log.Printf("⚠️  Failed to send payout notification to user %d: %v", o.userID, notifyErr)
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":              "Payout results applied",
+			"run_id":               runID,
+			"status":               runStatus,
+			"completed":            completed,
+			"failed":               failed,
+			"still_processing":     processing,
+			"unmatched_references": unmatched,
+		})
+	}
+}
@@ -0,0 +1,308 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf16"
+)
+
+// ================================
+// Embedded TrueType Fonts (ฟอนต์ภาษาไทยสำหรับ PDF)
+// ================================
+//
+// Helvetica covers Latin-1 only, so text is drawn with an embedded TrueType
+// font (Sarabun by default) as a Type0/CIDFontType2 font with Identity-H
+// encoding (2-byte glyph IDs) and a ToUnicode CMap, so Thai text renders and
+// can be searched/copied. Characters the font lacks fall back to Helvetica.
+// There is no shaping: Thai vowels and tone marks use the font's default
+// mark positions.
+
+const (
+	defaultPDFFontPath     = "fonts/Sarabun-Regular.ttf"
+	defaultPDFBoldFontPath = "fonts/Sarabun-Bold.ttf"
+)
+
+var errUnsupportedFont = errors.New("not a TrueType font")
+
+type pdfTrueTypeFont struct {
+	name            string // PostScript name (BaseFont)
+	data            []byte
+	unitsPerEm      float64
+	bbox            [4]int16
+	ascent, descent int16
+	glyphs          map[rune]uint16
+	advances        []uint16 // by glyph ID, in font units
+}
+
+var (
+	pdfFontsOnce sync.Once
+	pdfFonts     [2]*pdfTrueTypeFont // regular, bold
+)
+
+// defaultPDFFonts loads the regular and bold fonts once (PDF_FONT_PATH / PDF_FONT_BOLD_PATH).
+// A missing font is logged and that style falls back to the other one, or to Helvetica.
+func defaultPDFFonts() [2]*pdfTrueTypeFont {
+	pdfFontsOnce.Do(func() {
+		paths := [2]string{os.Getenv("PDF_FONT_PATH"), os.Getenv("PDF_FONT_BOLD_PATH")}
+		if paths[0] == "" {
+			paths[0] = defaultPDFFontPath
+		}
+		if paths[1] == "" {
+			paths[1] = defaultPDFBoldFontPath
+		}
+		for i, path := range paths {
+			data, err := os.ReadFile(path)
+			if err == nil {
+				pdfFonts[i], err = parseTrueTypeFont(data)
+			}
+			if err != nil {
+				log.Printf("⚠️  PDF font %s not loaded: %v (Thai text will not render)", path, err)
+			}
+		}
+	})
+	return pdfFonts
+}
+
+// glyph returns the glyph ID for r (0 = not in the font)
+func (f *pdfTrueTypeFont) glyph(r rune) uint16 {
+	return f.glyphs[r]
+}
+
+// width is the advance of a glyph in PDF text space units (1/1000 em)
+func (f *pdfTrueTypeFont) width(gid uint16) int {
+	if len(f.advances) == 0 {
+		return 0
+	}
+	adv := f.advances[len(f.advances)-1]
+	if int(gid) < len(f.advances) {
+		adv = f.advances[gid]
+	}
+	return int(float64(adv) * 1000 / f.unitsPerEm)
+}
+
+func (f *pdfTrueTypeFont) scale(v int16) int {
+	return int(float64(v) * 1000 / f.unitsPerEm)
+}
+
+// parseTrueTypeFont reads the tables needed to embed a glyf-based TrueType font
+func parseTrueTypeFont(data []byte) (*pdfTrueTypeFont, error) {
+	if len(data) < 12 {
+		return nil, errUnsupportedFont
+	}
+	switch binary.BigEndian.Uint32(data) {
+	case 0x00010000, 0x74727565: // 1.0 / 'true'
+	default:
+		return nil, errUnsupportedFont // รวมถึง OpenType แบบ CFF ('OTTO')
+	}
+
+	tables := map[string][]byte{}
+	numTables := int(binary.BigEndian.Uint16(data[4:]))
+	for i := 0; i < numTables; i++ {
+		rec := 12 + i*16
+		if rec+16 > len(data) {
+			return nil, errUnsupportedFont
+		}
+		off := int(binary.BigEndian.Uint32(data[rec+8:]))
+		length := int(binary.BigEndian.Uint32(data[rec+12:]))
+		if off < 0 || length < 0 || off+length > len(data) {
+			return nil, fmt.Errorf("table %s is out of bounds", data[rec:rec+4])
+		}
+		tables[string(data[rec:rec+4])] = data[off : off+length]
+	}
+
+	head, hhea, maxp, hmtx := tables["head"], tables["hhea"], tables["maxp"], tables["hmtx"]
+	if len(head) < 54 || len(hhea) < 36 || len(maxp) < 6 || tables["cmap"] == nil {
+		return nil, errors.New("font is missing head/hhea/maxp/cmap")
+	}
+
+	f := &pdfTrueTypeFont{data: data}
+	f.unitsPerEm = float64(binary.BigEndian.Uint16(head[18:]))
+	if f.unitsPerEm == 0 {
+		return nil, errors.New("font has no unitsPerEm")
+	}
+	for i := range f.bbox {
+		f.bbox[i] = int16(binary.BigEndian.Uint16(head[36+i*2:]))
+	}
+	f.ascent = int16(binary.BigEndian.Uint16(hhea[4:]))
+	f.descent = int16(binary.BigEndian.Uint16(hhea[6:]))
+
+	numGlyphs := int(binary.BigEndian.Uint16(maxp[4:]))
+	numMetrics := int(binary.BigEndian.Uint16(hhea[34:]))
+	if numMetrics == 0 || numMetrics > numGlyphs || len(hmtx) < numMetrics*4 {
+		return nil, errors.New("font has an invalid hmtx table")
+	}
+	f.advances = make([]uint16, numMetrics)
+	for i := range f.advances {
+		f.advances[i] = binary.BigEndian.Uint16(hmtx[i*4:])
+	}
+
+	glyphs, err := parseCmap(tables["cmap"])
+	if err != nil {
+		return nil, err
+	}
+	f.glyphs = glyphs
+	f.name = fontPostScriptName(tables["name"])
+	return f, nil
+}
+
+// parseCmap reads the Unicode mapping (format 12 preferred, else format 4)
+func parseCmap(cmap []byte) (map[rune]uint16, error) {
+	if len(cmap) < 4 {
+		return nil, errors.New("invalid cmap table")
+	}
+	var format4, format12 []byte
+	n := int(binary.BigEndian.Uint16(cmap[2:]))
+	for i := 0; i < n; i++ {
+		rec := 4 + i*8
+		if rec+8 > len(cmap) {
+			break
+		}
+		platform := binary.BigEndian.Uint16(cmap[rec:])
+		encoding := binary.BigEndian.Uint16(cmap[rec+2:])
+		off := int(binary.BigEndian.Uint32(cmap[rec+4:]))
+		if off+4 > len(cmap) || !(platform == 0 || platform == 3 && (encoding == 1 || encoding == 10)) {
+			continue
+		}
+		switch binary.BigEndian.Uint16(cmap[off:]) {
+		case 4:
+			format4 = cmap[off:]
+		case 12:
+			format12 = cmap[off:]
+		}
+	}
+
+	glyphs := map[rune]uint16{}
+	switch {
+	case len(format12) >= 16:
+		groups := int(binary.BigEndian.Uint32(format12[12:]))
+		for g := 0; g < groups && 16+g*12+12 <= len(format12); g++ {
+			p := format12[16+g*12:]
+			start, end, gid := binary.BigEndian.Uint32(p), binary.BigEndian.Uint32(p[4:]), binary.BigEndian.Uint32(p[8:])
+			for c := start; c <= end && c <= 0x10ffff; c++ {
+				glyphs[rune(c)] = uint16(gid + c - start)
+			}
+		}
+	case len(format4) >= 14:
+		segX2 := int(binary.BigEndian.Uint16(format4[6:]))
+		if 16+segX2*4 > len(format4) {
+			return nil, errors.New("invalid cmap format 4")
+		}
+		for s := 0; s < segX2; s += 2 {
+			end := int(binary.BigEndian.Uint16(format4[14+s:]))
+			start := int(binary.BigEndian.Uint16(format4[16+segX2+s:]))
+			delta := binary.BigEndian.Uint16(format4[16+segX2*2+s:])
+			rangePos := 16 + segX2*3 + s
+			rangeOffset := int(binary.BigEndian.Uint16(format4[rangePos:]))
+			for c := start; c <= end && c != 0xffff; c++ {
+				gid := uint16(c) + delta
+				if rangeOffset != 0 {
+					p := rangePos + rangeOffset + 2*(c-start)
+					if p+2 > len(format4) {
+						break
+					}
+					if gid = binary.BigEndian.Uint16(format4[p:]); gid != 0 {
+						gid += delta
+					}
+				}
+				if gid != 0 {
+					glyphs[rune(c)] = gid
+				}
+			}
+		}
+	default:
+		return nil, errors.New("font has no Unicode cmap")
+	}
+	return glyphs, nil
+}
+
+// fontPostScriptName reads name ID 6, keeping only characters allowed in a PDF name
+func fontPostScriptName(name []byte) string {
+	var found string
+	if len(name) >= 6 {
+		count := int(binary.BigEndian.Uint16(name[2:]))
+		storage := int(binary.BigEndian.Uint16(name[4:]))
+		for i := 0; i < count && 6+i*12+12 <= len(name); i++ {
+			rec := name[6+i*12:]
+			platform, nameID := binary.BigEndian.Uint16(rec), binary.BigEndian.Uint16(rec[6:])
+			length, off := int(binary.BigEndian.Uint16(rec[8:])), storage+int(binary.BigEndian.Uint16(rec[10:]))
+			if nameID != 6 || off+length > len(name) {
+				continue
+			}
+			raw := name[off : off+length]
+			if platform == 3 || platform == 0 {
+				units := make([]uint16, len(raw)/2)
+				for j := range units {
+					units[j] = binary.BigEndian.Uint16(raw[j*2:])
+				}
+				found = string(utf16.Decode(units))
+			} else {
+				found = string(raw)
+			}
+			break
+		}
+	}
+
+	clean := strings.Map(func(r rune) rune {
+		if r == '-' || r >= '0' && r <= '9' || r >= 'A' && r <= 'Z' || r >= 'a' && r <= 'z' {
+			return r
+		}
+		return -1
+	}, found)
+	if clean == "" {
+		return "EmbeddedFont"
+	}
+	return clean
+}
+
+// pdfTrueTypeObjects returns the five objects (Type0 font, CIDFont, descriptor, font file,
+// ToUnicode) for a font numbered from first; used maps glyph IDs to the text they came from
+func pdfTrueTypeObjects(f *pdfTrueTypeFont, first int, used map[uint16]rune) []string {
+	gids := make([]int, 0, len(used))
+	for gid := range used {
+		gids = append(gids, int(gid))
+	}
+	sort.Ints(gids)
+
+	var widths, chars strings.Builder
+	for _, gid := range gids {
+		fmt.Fprintf(&widths, "%d [%d] ", gid, f.width(uint16(gid)))
+	}
+
+	// ToUnicode: ไม่เกิน 100 รายการต่อ bfchar block
+	for i := 0; i < len(gids); i += 100 {
+		block := gids[i:min(i+100, len(gids))]
+		fmt.Fprintf(&chars, "%d beginbfchar\n", len(block))
+		for _, gid := range block {
+			fmt.Fprintf(&chars, "<%04X> <", gid)
+			for _, u := range utf16.Encode([]rune{used[uint16(gid)]}) {
+				fmt.Fprintf(&chars, "%04X", u)
+			}
+			chars.WriteString(">\n")
+		}
+		chars.WriteString("endbfchar\n")
+	}
+	toUnicode := "/CIDInit /ProcSet findresource begin\n12 dict begin\nbegincmap\n" +
+		"/CIDSystemInfo << /Registry (Adobe) /Ordering (UCS) /Supplement 0 >> def\n" +
+		"/CMapName /Adobe-Identity-UCS def\n/CMapType 2 def\n" +
+		"1 begincodespacerange\n<0000> <FFFF>\nendcodespacerange\n" +
+		chars.String() +
+		"endcmap\nCMapName currentdict /CMap defineresource pop\nend\nend\n"
+
+	return []string{
+		fmt.Sprintf("<< /Type /Font /Subtype /Type0 /BaseFont /%s /Encoding /Identity-H /DescendantFonts [%d 0 R] /ToUnicode %d 0 R >>",
+			f.name, first+1, first+4),
+		fmt.Sprintf("<< /Type /Font /Subtype /CIDFontType2 /BaseFont /%s /CIDSystemInfo << /Registry (Adobe) /Ordering (Identity) /Supplement 0 >> /FontDescriptor %d 0 R /CIDToGIDMap /Identity /DW 1000 /W [%s] >>",
+			f.name, first+2, strings.TrimSpace(widths.String())),
+		fmt.Sprintf("<< /Type /FontDescriptor /FontName /%s /Flags 32 /FontBBox [%d %d %d %d] /ItalicAngle 0 /Ascent %d /Descent %d /CapHeight %d /StemV 80 /FontFile2 %d 0 R >>",
+			f.name, f.scale(f.bbox[0]), f.scale(f.bbox[1]), f.scale(f.bbox[2]), f.scale(f.bbox[3]),
+			f.scale(f.ascent), f.scale(f.descent), f.scale(f.ascent), first+3),
+		fmt.Sprintf("<< /Length %d /Length1 %d >>\nstream\n%s\nendstream", len(f.data), len(f.data), f.data),
+		fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", len(toUnicode), toUnicode),
+	}
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+)
+
+// ================================
+// Minimal PDF Writer (A4, embedded TrueType + Helvetica fallback)
+// ================================
+//
+// Enough for text-and-lines documents (tax certificates, receipts).
+// Text uses the embedded TrueType font (see pdf_truetype.go) so Thai renders;
+// without one, or for characters it lacks, Helvetica is used and anything
+// outside WinAnsi (Latin-1) is replaced with '?'.
+
+const (
+	pdfPageWidth  = 595.28 // A4 in points
+	pdfPageHeight = 841.89
+)
+
+type pdfDocument struct {
+	pages []*bytes.Buffer
+	title string
+	fonts [2]*pdfTrueTypeFont // regular, bold (nil = Helvetica only)
+	used  [2]map[uint16]rune  // glyphs drawn per font, for widths and ToUnicode
+}
+
+// pdfTextRun is part of a string drawn with one font
+type pdfTextRun struct {
+	font    string // resource name
+	operand string // (literal) or <hex glyph IDs>
+	width   float64
+}
+
+func newPDFDocument(title string) *pdfDocument {
+	doc := &pdfDocument{title: title, fonts: defaultPDFFonts()}
+	doc.AddPage()
+	return doc
+}
+
+// AddPage starts a new page; subsequent drawing goes to it
+func (d *pdfDocument) AddPage() {
+	d.pages = append(d.pages, &bytes.Buffer{})
+}
+
+func (d *pdfDocument) page() *bytes.Buffer {
+	return d.pages[len(d.pages)-1]
+}
+
+// Text draws a string with its baseline at (x, y), measured from the top-left corner
+func (d *pdfDocument) Text(x, y, size float64, bold bool, text string) {
+	fmt.Fprintf(d.page(), "BT %.2f %.2f Td", x, pdfPageHeight-y)
+	for _, run := range d.textRuns(text, size, bold) {
+		fmt.Fprintf(d.page(), " /%s %.1f Tf %s Tj", run.font, size, run.operand)
+	}
+	d.page().WriteString(" ET\n")
+}
+
+// TextRight draws a string right-aligned at x
+func (d *pdfDocument) TextRight(x, y, size float64, bold bool, text string) {
+	var width float64
+	for _, run := range d.textRuns(text, size, bold) {
+		width += run.width
+	}
+	d.Text(x-width, y, size, bold, text)
+}
+
+// trueTypeSlot picks the embedded font for a style (-1 = none loaded)
+func (d *pdfDocument) trueTypeSlot(bold bool) int {
+	switch {
+	case bold && d.fonts[1] != nil:
+		return 1
+	case d.fonts[0] != nil:
+		return 0
+	case d.fonts[1] != nil:
+		return 1
+	}
+	return -1
+}
+
+// textRuns splits text into runs of the embedded font (F3/F4) and Helvetica (F1/F2)
+// for characters the embedded font does not have
+func (d *pdfDocument) textRuns(text string, size float64, bold bool) []pdfTextRun {
+	slot := d.trueTypeSlot(bold)
+	var runs []pdfTextRun
+	var pending strings.Builder
+	embedded := false
+
+	flush := func() {
+		if pending.Len() == 0 {
+			return
+		}
+		if embedded {
+			runs[len(runs)-1].operand = "<" + pending.String() + ">"
+		} else {
+			runs[len(runs)-1].operand = "(" + pdfEscape(pending.String()) + ")"
+			runs[len(runs)-1].width = pdfTextWidth(pending.String(), size, bold)
+		}
+		pending.Reset()
+	}
+
+	for _, r := range text {
+		var gid uint16
+		if slot >= 0 {
+			gid = d.fonts[slot].glyph(r)
+		}
+		if len(runs) == 0 || embedded != (gid != 0) {
+			flush()
+			embedded = gid != 0
+			font := "F1"
+			switch {
+			case embedded:
+				font = fmt.Sprintf("F%d", 3+slot)
+			case bold:
+				font = "F2"
+			}
+			runs = append(runs, pdfTextRun{font: font})
+		}
+		if !embedded {
+			pending.WriteRune(r)
+			continue
+		}
+		if d.used[slot] == nil {
+			d.used[slot] = map[uint16]rune{}
+		}
+		if _, ok := d.used[slot][gid]; !ok {
+			d.used[slot][gid] = r
+		}
+		fmt.Fprintf(&pending, "%04X", gid)
+		runs[len(runs)-1].width += float64(d.fonts[slot].width(gid)) * size / 1000
+	}
+	flush()
+	return runs
+}
+
+// Line draws a straight line between two points (top-left origin)
+func (d *pdfDocument) Line(x1, y1, x2, y2, width float64) {
+	fmt.Fprintf(d.page(), "%.2f w %.2f %.2f m %.2f %.2f l S\n", width, x1, pdfPageHeight-y1, x2, pdfPageHeight-y2)
+}
+
+// Rect draws a rectangle outline whose top-left corner is (x, y)
+func (d *pdfDocument) Rect(x, y, w, h, width float64) {
+	fmt.Fprintf(d.page(), "%.2f w %.2f %.2f %.2f %.2f re S\n", width, x, pdfPageHeight-y-h, w, h)
+}
+
+// Bytes serializes the document
+func (d *pdfDocument) Bytes() []byte {
+	var out bytes.Buffer
+	var offsets []int
+
+	writeObj := func(body string) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	// 1 catalog, 2 pages, 3-4 Helvetica, 5 info, then (content, page) pairs, then embedded fonts
+	pageCount := len(d.pages)
+	kids := make([]string, pageCount)
+	for i := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 7+i*2)
+	}
+
+	fontResources := "/F1 3 0 R /F2 4 0 R"
+	var fontObjects []string
+	for slot, f := range d.fonts {
+		if f == nil || len(d.used[slot]) == 0 {
+			continue
+		}
+		first := 6 + pageCount*2 + len(fontObjects)
+		fontResources += fmt.Sprintf(" /F%d %d 0 R", 3+slot, first)
+		fontObjects = append(fontObjects, pdfTrueTypeObjects(f, first, d.used[slot])...)
+	}
+
+	writeObj("<< /Type /Catalog /Pages 2 0 R >>")
+	writeObj(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), pageCount))
+	writeObj("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	writeObj("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+	writeObj(fmt.Sprintf("<< /Title %s /Producer (SkillMatch) >>", pdfInfoString(d.title)))
+	for _, content := range d.pages {
+		writeObj(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", content.Len(), content.String()))
+		writeObj(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.2f %.2f] /Resources << /Font << %s >> >> /Contents %d 0 R >>",
+			pdfPageWidth, pdfPageHeight, fontResources, len(offsets)))
+	}
+	for _, obj := range fontObjects {
+		writeObj(obj)
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R /Info 5 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	return out.Bytes()
+}
+
+// pdfEscape converts text to an escaped WinAnsi string literal body
+func pdfEscape(text string) string {
+	var b strings.Builder
+	for _, r := range text {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '฿': // ฿ is not in WinAnsi
+			b.WriteString("THB ")
+		case r >= 0x20 && r < 0x7f:
+			b.WriteRune(r)
+		case r >= 0xa0 && r <= 0xff:
+			fmt.Fprintf(&b, "\\%03o", r)
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
+
+// pdfInfoString encodes document metadata: a literal for Latin-1, UTF-16BE otherwise
+func pdfInfoString(text string) string {
+	for _, r := range text {
+		if r > 0xff {
+			var b strings.Builder
+			b.WriteString("<FEFF")
+			for _, u := range utf16.Encode([]rune(text)) {
+				fmt.Fprintf(&b, "%04X", u)
+			}
+			b.WriteString(">")
+			return b.String()
+		}
+	}
+	return "(" + pdfEscape(text) + ")"
+}
+
+// pdfTextWidth estimates rendered width using average Helvetica glyph widths
+func pdfTextWidth(text string, size float64, bold bool) float64 {
+	var units float64
+	for _, r := range text {
+		switch {
+		case r == ' ' || r == '.' || r == ',' || r == ':' || r == 'i' || r == 'l' || r == 'I':
+			units += 278
+		case r >= '0' && r <= '9':
+			units += 556
+		case r >= 'A' && r <= 'Z':
+			units += 667
+		default:
+			units += 556
+		}
+	}
+	if bold {
+		units *= 1.05
+	}
+	return units * size / 1000
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// buildTestTrueType builds a minimal TrueType font mapping each distinct rune (in code point
+// order) to its own glyph (glyph 0 = .notdef), every glyph 500/1000 em wide
+func buildTestTrueType(name string, text string) []byte {
+	be := binary.BigEndian
+	var runes []rune
+	for _, r := range text {
+		if !slices.Contains(runes, r) {
+			runes = append(runes, r)
+		}
+	}
+	slices.Sort(runes)
+	numGlyphs := len(runes) + 1
+
+	head := make([]byte, 54)
+	be.PutUint32(head[0:], 0x00010000)
+	be.PutUint16(head[18:], 1000)   // unitsPerEm
+	be.PutUint16(head[38:], 0xff38) // yMin -200
+	be.PutUint16(head[40:], 1000)   // xMax
+	be.PutUint16(head[42:], 900)    // yMax
+	hhea := make([]byte, 36)
+	be.PutUint16(hhea[4:], 900)    // ascender
+	be.PutUint16(hhea[6:], 0xff38) // descender -200
+	be.PutUint16(hhea[34:], uint16(numGlyphs))
+	maxp := make([]byte, 6)
+	be.PutUint32(maxp[0:], 0x00005000)
+	be.PutUint16(maxp[4:], uint16(numGlyphs))
+	hmtx := make([]byte, numGlyphs*4)
+	for i := 0; i < numGlyphs; i++ {
+		be.PutUint16(hmtx[i*4:], 500)
+	}
+
+	// cmap format 4: one segment per character + the final 0xFFFF segment
+	segX2 := (len(runes) + 1) * 2
+	sub := make([]byte, 16+segX2*4)
+	be.PutUint16(sub[0:], 4)
+	be.PutUint16(sub[2:], uint16(len(sub)))
+	be.PutUint16(sub[6:], uint16(segX2))
+	for i, r := range append(append([]rune{}, runes...), 0xffff) {
+		be.PutUint16(sub[14+i*2:], uint16(r))       // endCode
+		be.PutUint16(sub[16+segX2+i*2:], uint16(r)) // startCode
+		delta := uint16(i+1) - uint16(r)            // glyph = i+1
+		if r == 0xffff {
+			delta = 1
+		}
+		be.PutUint16(sub[16+segX2*2+i*2:], delta)
+	}
+	cmap := append([]byte{0, 0, 0, 1, 0, 3, 0, 1, 0, 0, 0, 12}, sub...)
+
+	// name: ID 6 in UTF-16BE (platform 3)
+	var utf []byte
+	for _, u := range utf16.Encode([]rune(name)) {
+		utf = be.AppendUint16(utf, u)
+	}
+	nameTable := make([]byte, 18)
+	be.PutUint16(nameTable[2:], 1)
+	be.PutUint16(nameTable[4:], 18)
+	be.PutUint16(nameTable[6:], 3)
+	be.PutUint16(nameTable[8:], 1)
+	be.PutUint16(nameTable[12:], 6)
+	be.PutUint16(nameTable[14:], uint16(len(utf)))
+	nameTable = append(nameTable, utf...)
+
+	tables := []struct {
+		tag  string
+		data []byte
+	}{{"cmap", cmap}, {"head", head}, {"hhea", hhea}, {"hmtx", hmtx}, {"maxp", maxp}, {"name", nameTable}}
+	font := make([]byte, 12+16*len(tables))
+	be.PutUint32(font[0:], 0x00010000)
+	be.PutUint16(font[4:], uint16(len(tables)))
+	for i, t := range tables {
+		rec := font[12+i*16:]
+		copy(rec, t.tag)
+		be.PutUint32(rec[8:], uint32(len(font)))
+		be.PutUint32(rec[12:], uint32(len(t.data)))
+		font = append(font, t.data...)
+		for len(font)%4 != 0 {
+			font = append(font, 0)
+		}
+	}
+	return font
+}
+
+// withPDFFonts makes newPDFDocument use font (nil = Helvetica only) for the rest of the test
+func withPDFFonts(t *testing.T, font *pdfTrueTypeFont) {
+	defaultPDFFonts()
+	saved := pdfFonts
+	pdfFonts = [2]*pdfTrueTypeFont{font, nil}
+	t.Cleanup(func() { pdfFonts = saved })
+}
+
+// extractPDFText decodes the embedded-font text of a PDF through its ToUnicode CMap
+func extractPDFText(pdf []byte) string {
+	toUnicode := map[string]string{}
+	for _, m := range regexp.MustCompile(`<([0-9A-F]{4})> <([0-9A-F]+)>`).FindAllSubmatch(pdf, -1) {
+		raw, _ := hex.DecodeString(string(m[2]))
+		units := make([]uint16, len(raw)/2)
+		for i := range units {
+			units[i] = binary.BigEndian.Uint16(raw[i*2:])
+		}
+		toUnicode[string(m[1])] = string(utf16.Decode(units))
+	}
+
+	var out strings.Builder
+	for _, m := range regexp.MustCompile(`<([0-9A-F]+)> Tj`).FindAllSubmatch(pdf, -1) {
+		for i := 0; i+4 <= len(m[1]); i += 4 {
+			out.WriteString(toUnicode[string(m[1][i:i+4])])
+		}
+	}
+	return out.String()
+}
+
+func TestParseTrueTypeFont(t *testing.T) {
+	f, err := parseTrueTypeFont(buildTestTrueType("Sarabun-Regular", "ขก"))
+	assert.NoError(t, err)
+	assert.Equal(t, "Sarabun-Regular", f.name)
+	assert.Equal(t, uint16(1), f.glyph('ก'))
+	assert.Equal(t, uint16(2), f.glyph('ข'))
+	assert.Equal(t, uint16(0), f.glyph('A'))
+	assert.Equal(t, 500, f.width(2))
+
+	_, err = parseTrueTypeFont([]byte("OTTO0000000000000000"))
+	assert.ErrorIs(t, err, errUnsupportedFont)
+}
+
+// Thai text must survive a write → extract round trip through the embedded font
+func TestPDFThaiTextRoundTrip(t *testing.T) {
+	thai := "หนังสือรับรองการหักภาษี ณ ที่จ่าย"
+	f, err := parseTrueTypeFont(buildTestTrueType("TestThai", thai))
+	assert.NoError(t, err)
+
+	doc := &pdfDocument{title: "ใบเสร็จ RC2026-000001", fonts: [2]*pdfTrueTypeFont{f, nil}}
+	doc.AddPage()
+	doc.Text(50, 60, 16, true, thai)
+	doc.TextRight(545, 80, 10, false, "RC-12")
+	pdf := doc.Bytes()
+
+	assert.Equal(t, thai, extractPDFText(pdf))
+	assert.NotContains(t, string(pdf), "???")
+	assert.Contains(t, string(pdf), "/Subtype /Type0 /BaseFont /TestThai /Encoding /Identity-H")
+	assert.Contains(t, string(pdf), "/CIDFontType2")
+	assert.Contains(t, string(pdf), "/FontFile2")
+	assert.Contains(t, string(pdf), "/F1 10.0 Tf (RC-12) Tj") // ไม่มีใน font → Helvetica
+	assert.Contains(t, string(pdf), "/Title <FEFF0E43")
+
+	t.Run("Xref Points At Objects", func(t *testing.T) {
+		m := regexp.MustCompile(`startxref\n(\d+)`).FindSubmatch(pdf)
+		xref, _ := strconv.Atoi(string(m[1]))
+		assert.True(t, bytes.HasPrefix(pdf[xref:], []byte("xref\n")))
+		for i, off := range regexp.MustCompile(`(\d{10}) 00000 n`).FindAllSubmatch(pdf, -1) {
+			n, _ := strconv.Atoi(string(off[1]))
+			assert.True(t, bytes.HasPrefix(pdf[n:], []byte(strconv.Itoa(i+1)+" 0 obj")))
+		}
+	})
+
+	t.Run("Widths From Font Metrics", func(t *testing.T) {
+		runs := doc.textRuns("กา", 10, false)
+		assert.Len(t, runs, 1)
+		assert.Equal(t, 10.0, runs[0].width) // 2 glyphs × 500/1000 em × 10pt
+	})
+}
@@ -0,0 +1,520 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ================================
+// Pricing Engine (คำนวณราคาฝั่ง server → quote ที่มีอายุสั้น → checkout ตามยอดใน quote)
+// ================================
+
+const (
+	QuoteKindBooking   = "booking"
+	QuoteKindExtension = "extension"
+
+	priceQuoteTTL = 15 * time.Minute
+
+	// ค่าธรรมเนียมการจองเมื่อไม่มี commission_rules ที่ใช้ได้ (ปกติมาจาก bookingCommissionRule)
+	bookingPlatformCommissionRate = 0.10
+	bookingGatewayFeeRate         = 0.0275
+)
+
+// commissionRates is a booking commission rule: platform cut and payment gateway fee
+type commissionRates struct {
+	Platform float64
+	Gateway  float64
+}
+
+var defaultCommissionRates = commissionRates{Platform: bookingPlatformCommissionRate, Gateway: bookingGatewayFeeRate}
+
+// Line item types
+const (
+	PriceItemBase      = "base"
+	PriceItemExtension = "extension"
+	PriceItemSurcharge = "surcharge"
+	PriceItemDiscount  = "discount"
+	PriceItemCoupon    = "coupon"
+)
+
+// extensionTier is an extension length with its discount on the package's per-minute rate
+type extensionTier struct {
+	Minutes  int     `json:"minutes"`
+	Discount float64 `json:"discount_rate"`
+	Label    string  `json:"label"`
+}
+
+var extensionTiers = []extensionTier{
+	{Minutes: 30, Discount: 0.10, Label: "30 minutes"},
+	{Minutes: 60, Discount: 0.15, Label: "1 hour"},
+	{Minutes: 120, Discount: 0.20, Label: "2 hours"},
+}
+
+var (
+	errQuoteNotFound          = errors.New("quote not found")
+	errQuoteExpired           = errors.New("quote has expired, please request a new one")
+	errQuoteUsed              = errors.New("quote has already been used")
+	errQuoteStale             = errors.New("price has changed since the quote, please request a new one")
+	errQuotePackageNotFound   = errors.New("package not found or inactive")
+	errQuoteProviderMismatch  = errors.New("package does not belong to specified provider")
+	errQuoteInvalidTime       = errors.New("invalid booking date/time, expected YYYY-MM-DD and HH:MM")
+	errQuoteTimeInPast        = errors.New("booking time must be in the future")
+	errQuoteCouponNotFound    = errors.New("coupon not found")
+	errQuoteForbidden         = errors.New("not allowed to price this booking")
+	errQuoteTooFarAhead       = errors.New("booking is further ahead than this package allows")
+	errQuoteLocationType      = errors.New("this package is not available for the requested location type")
+	errExtensionUnavailable   = errors.New("provider is not available for the extra time")
+	errExtensionMinutes       = errors.New("unsupported extension length")
+	errExtensionBookingActive = errors.New("booking must be active to extend")
+)
+
+// PriceLineItem is one row of a quote; discounts are negative
+type PriceLineItem struct {
+	Type   string  `json:"type"`
+	Label  string  `json:"label"`
+	Amount float64 `json:"amount"`
+}
+
+// QuoteCommission previews how the total is split once paid
+type QuoteCommission struct {
+	PlatformRate       float64 `json:"platform_rate"`
+	GatewayRate        float64 `json:"gateway_rate"`
+	PlatformCommission float64 `json:"platform_commission"`
+	GatewayFee         float64 `json:"gateway_fee"`
+	ProviderNet        float64 `json:"provider_net"`
+}
+
+// PriceBreakdown is the itemized price computed by priceBreakdown
+type PriceBreakdown struct {
+	Items         []PriceLineItem `json:"items"`
+	Subtotal      float64         `json:"subtotal"` // ก่อนหักส่วนลด
+	Discount      float64         `json:"discount"` // ส่วนลดรวม (รวมคูปอง)
+	Total         float64         `json:"total"`    // ยอดที่ต้องชำระ
+	DepositAmount float64         `json:"deposit_amount"`
+	Commission    QuoteCommission `json:"commission"`
+}
+
+// couponDiscount is the part of Discount that comes from the coupon
+func (b PriceBreakdown) couponDiscount() float64 {
+	var d float64
+	for _, item := range b.Items {
+		if item.Type == PriceItemCoupon {
+			d -= item.Amount
+		}
+	}
+	return roundSatang(d)
+}
+
+// PriceQuote is a stored breakdown that a checkout must charge exactly
+type PriceQuote struct {
+	QuoteID          string     `json:"quote_id"`
+	Kind             string     `json:"kind"`
+	UserID           int        `json:"user_id"` // ผู้ที่จะจ่าย
+	ProviderID       int        `json:"provider_id"`
+	PackageID        int        `json:"package_id"`
+	BookingID        *int       `json:"booking_id,omitempty"` // เฉพาะ quote ต่อเวลา
+	StartTime        *time.Time `json:"start_time,omitempty"`
+	EndTime          *time.Time `json:"end_time,omitempty"`
+	ExtensionMinutes int        `json:"extension_minutes,omitempty"`
+	CouponCode       *string    `json:"coupon_code,omitempty"`
+	PriceBreakdown
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// pricingInput is the server-side data a price is computed from
+type pricingInput struct {
+	PackageName      string
+	PackagePrice     float64
+	PackageDuration  int // minutes
+	ExtensionMinutes int // 0 = ราคาจองแพ็คเกจ
+	Surcharges       []PriceLineItem
+	Coupon           *Coupon
+	DepositRate      float64          // 0 = provider ไม่เก็บมัดจำ
+	Commission       *commissionRates // nil = defaultCommissionRates
+}
+
+func extensionTierFor(minutes int) (extensionTier, bool) {
+	for _, t := range extensionTiers {
+		if t.Minutes == minutes {
+			return t, true
+		}
+	}
+	return extensionTier{}, false
+}
+
+// priceBreakdown itemizes a booking or extension price. Coupons only apply to bookings and are
+// taken last, from what is left after other discounts.
+func priceBreakdown(in pricingInput) (PriceBreakdown, error) {
+	var b PriceBreakdown
+
+	if in.ExtensionMinutes > 0 {
+		tier, ok := extensionTierFor(in.ExtensionMinutes)
+		if !ok || in.PackageDuration <= 0 {
+			return b, errExtensionMinutes
+		}
+		amount := roundSatang(in.PackagePrice / float64(in.PackageDuration) * float64(tier.Minutes))
+		b.Items = append(b.Items, PriceLineItem{Type: PriceItemExtension, Label: "Extension " + tier.Label, Amount: amount})
+		if tier.Discount > 0 {
+			b.Items = append(b.Items, PriceLineItem{
+				Type:   PriceItemDiscount,
+				Label:  fmt.Sprintf("Extension discount %.0f%%", tier.Discount*100),
+				Amount: -roundSatang(amount * tier.Discount),
+			})
+		}
+	} else {
+		b.Items = append(b.Items, PriceLineItem{Type: PriceItemBase, Label: in.PackageName, Amount: roundSatang(in.PackagePrice)})
+	}
+	b.Items = append(b.Items, in.Surcharges...)
+
+	for _, item := range b.Items {
+		if item.Amount >= 0 {
+			b.Subtotal += item.Amount
+		} else {
+			b.Discount -= item.Amount
+		}
+	}
+	if b.Discount > b.Subtotal {
+		b.Discount = b.Subtotal
+	}
+
+	if in.Coupon != nil && in.ExtensionMinutes == 0 {
+		if d := couponDiscountFor(in.Coupon, roundSatang(b.Subtotal-b.Discount)); d > 0 {
+			b.Items = append(b.Items, PriceLineItem{Type: PriceItemCoupon, Label: "Coupon " + in.Coupon.Code, Amount: -d})
+			b.Discount += d
+		}
+	}
+
+	b.Subtotal = roundSatang(b.Subtotal)
+	b.Discount = roundSatang(b.Discount)
+	b.Total = roundSatang(b.Subtotal - b.Discount)
+	if in.DepositRate > 0 {
+		b.DepositAmount = roundSatang(b.Total * in.DepositRate)
+	}
+
+	rates := defaultCommissionRates
+	if in.Commission != nil {
+		rates = *in.Commission
+	}
+	b.Commission = QuoteCommission{
+		PlatformRate:       rates.Platform,
+		GatewayRate:        rates.Gateway,
+		PlatformCommission: roundSatang(b.Total * rates.Platform),
+		GatewayFee:         roundSatang(b.Total * rates.Gateway),
+	}
+	b.Commission.ProviderNet = roundSatang(b.Total - b.Commission.PlatformCommission - b.Commission.GatewayFee)
+	return b, nil
+}
+
+// bookingQuoteRequest is what a client asks a booking price for
+type bookingQuoteRequest struct {
+	ProviderID  int    `json:"provider_id" binding:"required"`
+	PackageID   int    `json:"package_id" binding:"required"`
+	BookingDate string `json:"booking_date" binding:"required"` // YYYY-MM-DD
+	StartTime   string `json:"start_time" binding:"required"`   // HH:MM
+	CouponCode  string `json:"coupon_code"`
+
+	LocationType string `json:"location_type"` // optional: incall / outcall
+}
+
+// buildBookingQuote prices a new booking from the package and its pricing rules, the provider's
+// deposit setting and an optional coupon. The quote is not stored; see savePriceQuote.
+func buildBookingQuote(ctx context.Context, dbPool *pgxpool.Pool, clientID int, req bookingQuoteRequest, now time.Time) (*PriceQuote, error) {
+	in := pricingInput{}
+	var providerID int
+	var locationType string
+	var maxAdvanceDays *int
+	err := dbPool.QueryRow(ctx, `
+		SELECT provider_id, package_name, duration, price, location_type, max_advance_days
+		FROM service_packages
+		WHERE package_id = $1 AND is_active = true
+	`, req.PackageID).Scan(&providerID, &in.PackageName, &in.PackageDuration, &in.PackagePrice, &locationType, &maxAdvanceDays)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, errQuotePackageNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if providerID != req.ProviderID {
+		return nil, errQuoteProviderMismatch
+	}
+
+	// booking_date/start_time เป็นเวลาตามนาฬิกาของ provider
+	loc := loadAvailabilitySettings(ctx, dbPool, providerID).location()
+	start, err := parseLocalDateTime(req.BookingDate, req.StartTime, loc)
+	if err != nil {
+		return nil, errQuoteInvalidTime
+	}
+	if !start.After(now) {
+		return nil, errQuoteTimeInPast
+	}
+	if maxAdvanceDays != nil && start.After(now.AddDate(0, 0, *maxAdvanceDays)) {
+		return nil, errQuoteTooFarAhead
+	}
+	if req.LocationType != "" && locationType != PackageLocationBoth && req.LocationType != locationType {
+		return nil, errQuoteLocationType
+	}
+	end := start.Add(time.Duration(in.PackageDuration) * time.Minute)
+	if err := checkProviderAvailable(ctx, dbPool, providerID, start, end, 0); err != nil {
+		return nil, err
+	}
+
+	// dynamic pricing ของแพ็คเกจ (ช่วงเวลา/วัน/วันหยุด/จองกระชั้นชิด)
+	rules, err := loadPricingRules(ctx, dbPool, []int{req.PackageID})
+	if err != nil {
+		return nil, err
+	}
+	if len(rules[req.PackageID]) > 0 {
+		in.Surcharges = pricingRuleAdjustments(rules[req.PackageID], in.PackagePrice, start, now, holidayOn(ctx, dbPool, start))
+	}
+
+	var requireDeposit bool
+	var depositRate float64
+	err = dbPool.QueryRow(ctx, `
+		SELECT require_deposit, deposit_percentage FROM provider_deposit_settings WHERE provider_id = $1
+	`, providerID).Scan(&requireDeposit, &depositRate)
+	if err == nil && requireDeposit {
+		in.DepositRate = depositRate
+	}
+
+	// ค่าคอมมิชชั่นตามกฎที่ใช้กับ tier ของ provider ณ เวลาที่จอง
+	rates := bookingCommissionRule(ctx, dbPool, providerID, now)
+	in.Commission = &rates
+
+	q := &PriceQuote{
+		Kind:       QuoteKindBooking,
+		UserID:     clientID,
+		ProviderID: providerID,
+		PackageID:  req.PackageID,
+		StartTime:  &start,
+		EndTime:    &end,
+	}
+
+	if code := strings.TrimSpace(req.CouponCode); code != "" {
+		coupon, err := scanCouponTerms(dbPool.QueryRow(ctx, lookupCouponQuery, code, clientID))
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, errQuoteCouponNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+		var alreadyUsed bool
+		dbPool.QueryRow(ctx, `
+			SELECT EXISTS (SELECT 1 FROM coupon_usages WHERE coupon_id = $1 AND user_id = $2 AND status = 'applied')
+		`, coupon.CouponID, clientID).Scan(&alreadyUsed)
+		if alreadyUsed {
+			return nil, errCouponAlreadyUsed
+		}
+		if err := checkCouponTerms(coupon, now, providerID, in.PackagePrice); err != nil {
+			return nil, err
+		}
+		in.Coupon = coupon
+		q.CouponCode = &coupon.Code
+	}
+
+	q.PriceBreakdown, err = priceBreakdown(in)
+	if err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// buildExtensionQuote prices extending an active booking by minutes, for its client or provider.
+// The per-minute rate comes from the package as it was booked (booking snapshot).
+func buildExtensionQuote(ctx context.Context, dbPool *pgxpool.Pool, userID, bookingID, minutes int) (*PriceQuote, error) {
+	in := pricingInput{ExtensionMinutes: minutes}
+	q := &PriceQuote{Kind: QuoteKindExtension, UserID: userID, BookingID: &bookingID, ExtensionMinutes: minutes}
+
+	var clientID int
+	var status string
+	var endTime time.Time
+	err := dbPool.QueryRow(ctx, `
+		SELECT b.client_id, b.provider_id, b.status, b.end_time, b.package_id, bs.package_name, bs.package_duration, bs.package_price
+		FROM bookings b
+		JOIN booking_snapshots bs ON bs.booking_id = b.booking_id
+		WHERE b.booking_id = $1
+	`, bookingID).Scan(&clientID, &q.ProviderID, &status, &endTime, &q.PackageID, &in.PackageName, &in.PackageDuration, &in.PackagePrice)
+	if err != nil {
+		return nil, err
+	}
+	if userID != clientID && userID != q.ProviderID {
+		return nil, errQuoteForbidden
+	}
+	if status != "confirmed" && status != "paid" && status != "in_progress" {
+		return nil, errExtensionBookingActive
+	}
+	// เวลาที่ต่อต้องไม่ชน booking ถัดไป/ช่วงที่ provider ไม่ว่าง
+	extraEnd := endTime.Add(time.Duration(minutes) * time.Minute)
+	if err := checkProviderAvailable(ctx, dbPool, q.ProviderID, endTime, extraEnd, bookingID); errors.Is(err, errSlotUnavailable) {
+		return nil, errExtensionUnavailable
+	} else if err != nil {
+		return nil, err
+	}
+
+	// ต่อเวลาใช้อัตราค่าคอมมิชชั่นที่ล็อกไว้กับ booking (เหมือน applyBookingExtension)
+	var rates commissionRates
+	rates.Platform, rates.Gateway = bookingCommissionRates(ctx, dbPool, bookingID)
+	in.Commission = &rates
+
+	q.PriceBreakdown, err = priceBreakdown(in)
+	if err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+func newQuoteID() (string, error) {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "QT" + strings.ToUpper(hex.EncodeToString(b)), nil
+}
+
+const priceQuoteColumns = `
+	quote_id, kind, user_id, provider_id, package_id, booking_id, start_time, end_time,
+	extension_minutes, coupon_code, breakdown, expires_at, used_at, created_at`
+
+func scanPriceQuote(row pgx.Row) (*PriceQuote, error) {
+	var q PriceQuote
+	err := row.Scan(&q.QuoteID, &q.Kind, &q.UserID, &q.ProviderID, &q.PackageID, &q.BookingID, &q.StartTime, &q.EndTime,
+		&q.ExtensionMinutes, &q.CouponCode, &q.PriceBreakdown, &q.ExpiresAt, &q.UsedAt, &q.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &q, nil
+}
+
+// savePriceQuote stores q with a new quote ID, valid for priceQuoteTTL
+func savePriceQuote(ctx context.Context, dbPool *pgxpool.Pool, q *PriceQuote, now time.Time) (*PriceQuote, error) {
+	id, err := newQuoteID()
+	if err != nil {
+		return nil, err
+	}
+	return scanPriceQuote(dbPool.QueryRow(ctx, `
+		INSERT INTO price_quotes (
+			quote_id, kind, user_id, provider_id, package_id, booking_id, start_time, end_time,
+			extension_minutes, coupon_code, breakdown, total, deposit_amount, expires_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+		RETURNING `+priceQuoteColumns,
+		id, q.Kind, q.UserID, q.ProviderID, q.PackageID, q.BookingID, q.StartTime, q.EndTime,
+		q.ExtensionMinutes, q.CouponCode, q.PriceBreakdown, q.Total, q.DepositAmount, now.Add(priceQuoteTTL)))
+}
+
+// consumePriceQuote locks and marks a quote used, so each quote pays for exactly one checkout
+func consumePriceQuote(ctx context.Context, tx pgx.Tx, quoteID string, userID int, kind string, now time.Time) (*PriceQuote, error) {
+	q, err := scanPriceQuote(tx.QueryRow(ctx, `
+		SELECT `+priceQuoteColumns+` FROM price_quotes WHERE quote_id = $1 AND user_id = $2 FOR UPDATE
+	`, strings.TrimSpace(quoteID), userID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, errQuoteNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if q.Kind != kind {
+		return nil, errQuoteNotFound
+	}
+	if q.UsedAt != nil {
+		return nil, errQuoteUsed
+	}
+	if now.After(q.ExpiresAt) {
+		return nil, errQuoteExpired
+	}
+
+	_, err = tx.Exec(ctx, `UPDATE price_quotes SET used_at = $2 WHERE quote_id = $1`, q.QuoteID, now)
+	if err != nil {
+		return nil, err
+	}
+	q.UsedAt = &now
+	return q, nil
+}
+
+// releasePriceQuote makes a quote usable again after a checkout that failed before charging anything
+func releasePriceQuote(ctx context.Context, dbPool *pgxpool.Pool, quoteID string) {
+	if quoteID == "" {
+		return
+	}
+	dbPool.Exec(ctx, `UPDATE price_quotes SET used_at = NULL WHERE quote_id = $1`, quoteID)
+}
+
+// createBookingFromQuote inserts the booking a quote priced and redeems its coupon, checking the
+// booking ends up at exactly the quoted total
+func createBookingFromQuote(ctx context.Context, tx pgx.Tx, q *PriceQuote, location, notes *string, status string, paymentMethod *string) (int, error) {
+	var quoteID *string
+	if q.QuoteID != "" {
+		quoteID = &q.QuoteID
+	}
+
+	// ตรวจเวลาว่างอีกครั้งตอนสร้างจริง (quote อาจออกก่อนมีคนจองช่วงเดียวกัน)
+	if q.StartTime == nil || q.EndTime == nil {
+		return 0, errQuoteInvalidTime
+	}
+	if err := lockProviderCalendar(ctx, tx, q.ProviderID); err != nil {
+		return 0, err
+	}
+	if err := checkProviderAvailable(ctx, tx, q.ProviderID, *q.StartTime, *q.EndTime, 0); err != nil {
+		return 0, err
+	}
+
+	// booking เก็บ timezone ของ provider ไว้ใช้แสดงผล/แจ้งเตือน แม้ provider จะเปลี่ยนภายหลัง
+	timezone := loadAvailabilitySettings(ctx, tx, q.ProviderID).Timezone
+	var bookingID int
+	err := tx.QueryRow(ctx, `
+		INSERT INTO bookings (
+			client_id, provider_id, package_id, booking_date, start_time, end_time,
+			total_price, status, location, special_notes, payment_method, quote_id, timezone
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, 'pending', $8, $9, $10, $11, $12)
+		RETURNING booking_id
+	`, q.UserID, q.ProviderID, q.PackageID, q.StartTime.In(timezoneOrDefault(timezone)).Format("2006-01-02"), q.StartTime, q.EndTime,
+		roundSatang(q.Total+q.couponDiscount()), location, notes, paymentMethod, quoteID, timezone).Scan(&bookingID)
+	if isExclusionViolation(err) {
+		return 0, &slotConflictError{SuggestedSlots: []AvailabilitySlot{}}
+	}
+	if err != nil {
+		return 0, err
+	}
+	if err := snapshotBooking(ctx, tx, bookingID, q); err != nil {
+		return 0, fmt.Errorf("snapshot booking: %w", err)
+	}
+
+	// คูปองใน quote → ตัดสิทธิ์จริงตอนนี้ (ล็อกคูปองกันใช้เกิน usage_limit)
+	if q.CouponCode != nil {
+		r, err := redeemCoupon(ctx, tx, *q.CouponCode, bookingID, q.UserID, time.Now())
+		if err != nil {
+			return 0, err
+		}
+		if math.Abs(r.NewTotal-q.Total) >= 0.01 {
+			return 0, errQuoteStale
+		}
+	}
+
+	// booking ถูกสร้างเป็น pending เพื่อให้ redeemCoupon ใช้ได้ แล้วค่อยตั้งสถานะจริงใน tx เดียวกัน
+	if status != "pending" {
+		if _, err := tx.Exec(ctx, `UPDATE bookings SET status = $2 WHERE booking_id = $1`, bookingID, status); err != nil {
+			return 0, err
+		}
+	}
+	return bookingID, nil
+}
+
+// discardQuotedBooking undoes a booking whose checkout failed before any charge: coupons go back
+// and the quote can be used again
+func discardQuotedBooking(ctx context.Context, dbPool *pgxpool.Pool, bookingID int, quoteID string) {
+	if err := releaseBookingDiscountsNow(ctx, dbPool, bookingID); err != nil {
+		fmt.Printf("Warning: Failed to release discounts of booking %d: %v\n", bookingID, err)
+	}
+	dbPool.Exec(ctx, "UPDATE bookings SET status = 'cancelled', updated_at = NOW() WHERE booking_id = $1", bookingID)
+	releasePriceQuote(ctx, dbPool, quoteID)
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// respondPricingError maps pricing/quote errors to HTTP responses
+func respondPricingError(c *gin.Context, err error, fallback string) {
+	var conflict *slotConflictError
+	if errors.As(err, &conflict) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "suggested_slots": conflict.SuggestedSlots})
+		return
+	}
+	switch {
+	case errors.Is(err, errQuoteNotFound), errors.Is(err, errQuotePackageNotFound), errors.Is(err, errQuoteCouponNotFound),
+		errors.Is(err, pgx.ErrNoRows):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, errQuoteForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, errQuoteExpired), errors.Is(err, errQuoteUsed), errors.Is(err, errQuoteStale),
+		errors.Is(err, errCouponUsedUp), errors.Is(err, errCouponAlreadyUsed),
+		errors.Is(err, errSlotUnavailable), errors.Is(err, errExtensionUnavailable):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, errQuoteProviderMismatch), errors.Is(err, errQuoteInvalidTime), errors.Is(err, errQuoteTimeInPast),
+		errors.Is(err, errQuoteTooFarAhead), errors.Is(err, errQuoteLocationType),
+		errors.Is(err, errExtensionMinutes), errors.Is(err, errExtensionBookingActive),
+		errors.Is(err, errCouponNotValid), errors.Is(err, errCouponOtherProvider), errors.Is(err, errCouponBelowMinimum):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
+
+// POST /bookings/quote - ใบเสนอราคาการจอง (ใช้ quote_id ตอน checkout ภายใน 15 นาที)
+func createBookingQuoteHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetInt("userID")
+
+		var req bookingQuoteRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		now := time.Now()
+		q, err := buildBookingQuote(ctx, dbPool, userID, req, now)
+		if err != nil {
+			respondPricingError(c, err, "Failed to price booking")
+			return
+		}
+		q, err = savePriceQuote(ctx, dbPool, q, now)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save quote"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, q)
+	}
+}
+
+// POST /bookings/:id/extension-quote - ใบเสนอราคาต่อเวลา
+func createExtensionQuoteHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetInt("userID")
+		bookingID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid booking ID"})
+			return
+		}
+
+		var req struct {
+			AdditionalMinutes int `json:"additional_minutes" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		q, err := buildExtensionQuote(ctx, dbPool, userID, bookingID, req.AdditionalMinutes)
+		if err != nil {
+			respondPricingError(c, err, "Failed to price extension")
+			return
+		}
+		q, err = savePriceQuote(ctx, dbPool, q, time.Now())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save quote"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, q)
+	}
+}
+
+// GET /quotes/:id - ดู quote ของตัวเอง
+func getPriceQuoteHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetInt("userID")
+
+		q, err := scanPriceQuote(dbPool.QueryRow(ctx, `
+			SELECT `+priceQuoteColumns+` FROM price_quotes WHERE quote_id = $1 AND user_id = $2
+		`, c.Param("id"), userID))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Quote not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"quote":   q,
+			"expired": q.UsedAt == nil && time.Now().After(q.ExpiresAt),
+		})
+	}
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// pricingRuleRequest is the body for creating/updating a pricing rule
+type pricingRuleRequest struct {
+	PackageID         int     `json:"package_id"`
+	RuleType          string  `json:"rule_type" binding:"required"`
+	Label             *string `json:"label"`
+	AdjustmentPercent float64 `json:"adjustment_percent" binding:"required"`
+	DaysOfWeek        []int   `json:"days_of_week"`
+	StartTime         *string `json:"start_time"`
+	EndTime           *string `json:"end_time"`
+	NoticeHours       *int    `json:"notice_hours"`
+	IsActive          *bool   `json:"is_active"`
+}
+
+func (req pricingRuleRequest) rule() *PackagePricingRule {
+	r := &PackagePricingRule{
+		PackageID:         req.PackageID,
+		RuleType:          req.RuleType,
+		Label:             req.Label,
+		AdjustmentPercent: req.AdjustmentPercent,
+		DaysOfWeek:        req.DaysOfWeek,
+		StartTime:         req.StartTime,
+		EndTime:           req.EndTime,
+		NoticeHours:       req.NoticeHours,
+		IsActive:          req.IsActive == nil || *req.IsActive,
+	}
+	if r.DaysOfWeek == nil {
+		r.DaysOfWeek = []int{}
+	}
+	return r
+}
+
+// GET /provider/pricing-rules?package_id= - กฎราคาของแพ็คเกจตัวเอง
+func getMyPricingRulesHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetInt("userID")
+		packageID, _ := strconv.Atoi(c.Query("package_id"))
+
+		rows, err := dbPool.Query(ctx, `
+			SELECT `+pricingRuleColumns+`
+			FROM package_pricing_rules
+			WHERE provider_id = $1 AND ($2 = 0 OR package_id = $2)
+			ORDER BY package_id, rule_id
+		`, userID, packageID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch pricing rules"})
+			return
+		}
+		defer rows.Close()
+
+		rules := make([]*PackagePricingRule, 0)
+		for rows.Next() {
+			if r, err := scanPricingRule(rows); err == nil {
+				rules = append(rules, r)
+			}
+		}
+		c.JSON(http.StatusOK, gin.H{"rules": rules})
+	}
+}
+
+// POST /provider/pricing-rules - เพิ่มกฎราคาให้แพ็คเกจ
+func createPricingRuleHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetInt("userID")
+
+		var req pricingRuleRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		r := req.rule()
+		if err := validatePricingRule(r); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		var ownerID int
+		err := dbPool.QueryRow(ctx, `SELECT provider_id FROM service_packages WHERE package_id = $1`, req.PackageID).Scan(&ownerID)
+		if err != nil || ownerID != userID {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Package not found"})
+			return
+		}
+
+		r, err = scanPricingRule(dbPool.QueryRow(ctx, `
+			INSERT INTO package_pricing_rules (
+				package_id, provider_id, rule_type, label, adjustment_percent, days_of_week,
+				start_time, end_time, notice_hours, is_active
+			) VALUES ($1, $2, $3, $4, $5, $6, $7::time, $8::time, $9, $10)
+			RETURNING `+pricingRuleColumns,
+			r.PackageID, userID, r.RuleType, r.Label, r.AdjustmentPercent, r.DaysOfWeek,
+			r.StartTime, r.EndTime, r.NoticeHours, r.IsActive))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create pricing rule"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"message": "Pricing rule created", "rule": r})
+	}
+}
+
+// PUT /provider/pricing-rules/:ruleId - แก้ไขกฎราคา (ส่งค่าทั้งหมด)
+func updatePricingRuleHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetInt("userID")
+
+		var req pricingRuleRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		r := req.rule()
+		if err := validatePricingRule(r); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		r, err := scanPricingRule(dbPool.QueryRow(ctx, `
+			UPDATE package_pricing_rules
+			SET rule_type = $3, label = $4, adjustment_percent = $5, days_of_week = $6,
+			    start_time = $7::time, end_time = $8::time, notice_hours = $9, is_active = $10, updated_at = NOW()
+			WHERE rule_id = $1 AND provider_id = $2
+			RETURNING `+pricingRuleColumns,
+			c.Param("ruleId"), userID, r.RuleType, r.Label, r.AdjustmentPercent, r.DaysOfWeek,
+			r.StartTime, r.EndTime, r.NoticeHours, r.IsActive))
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Pricing rule not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update pricing rule"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Pricing rule updated", "rule": r})
+	}
+}
+
+// DELETE /provider/pricing-rules/:ruleId
+func deletePricingRuleHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetInt("userID")
+
+		tag, err := dbPool.Exec(ctx, `
+			DELETE FROM package_pricing_rules WHERE rule_id = $1 AND provider_id = $2
+		`, c.Param("ruleId"), userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete pricing rule"})
+			return
+		}
+		if tag.RowsAffected() == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Pricing rule not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Pricing rule deleted"})
+	}
+}
+
+// ================================
+// Thai Holiday Calendar (admin)
+// ================================
+
+// ThaiHoliday is a date that holiday pricing rules apply to
+type ThaiHoliday struct {
+	HolidayDate string    `json:"holiday_date"` // YYYY-MM-DD
+	Name        string    `json:"name"`
+	NameTH      *string   `json:"name_th"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// GET /holidays?year=2026 (Public)
+func getHolidaysHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		year, err := strconv.Atoi(c.DefaultQuery("year", strconv.Itoa(time.Now().Year())))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid year"})
+			return
+		}
+
+		rows, err := dbPool.Query(ctx, `
+			SELECT TO_CHAR(holiday_date, 'YYYY-MM-DD'), name, name_th, created_at
+			FROM thai_holidays
+			WHERE EXTRACT(YEAR FROM holiday_date) = $1
+			ORDER BY holiday_date
+		`, year)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch holidays"})
+			return
+		}
+		defer rows.Close()
+
+		holidays := make([]ThaiHoliday, 0)
+		for rows.Next() {
+			var h ThaiHoliday
+			if err := rows.Scan(&h.HolidayDate, &h.Name, &h.NameTH, &h.CreatedAt); err == nil {
+				holidays = append(holidays, h)
+			}
+		}
+		c.JSON(http.StatusOK, gin.H{"year": year, "holidays": holidays})
+	}
+}
+
+// PUT /admin/holidays - เพิ่ม/แก้ไขวันหยุด
+func adminUpsertHolidayHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			HolidayDate string  `json:"holiday_date" binding:"required"`
+			Name        string  `json:"name" binding:"required"`
+			NameTH      *string `json:"name_th"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if _, err := time.Parse("2006-01-02", req.HolidayDate); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "holiday_date must be YYYY-MM-DD"})
+			return
+		}
+
+		_, err := dbPool.Exec(ctx, `
+			INSERT INTO thai_holidays (holiday_date, name, name_th, created_by)
+			VALUES ($1, $2, $3, $4)
+			ON CONFLICT (holiday_date) DO UPDATE SET name = $2, name_th = $3
+		`, req.HolidayDate, req.Name, req.NameTH, c.GetInt("userID"))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save holiday"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Holiday saved", "holiday_date": req.HolidayDate})
+	}
+}
+
+// DELETE /admin/holidays/:date
+func adminDeleteHolidayHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tag, err := dbPool.Exec(ctx, `DELETE FROM thai_holidays WHERE holiday_date = $1::date`, c.Param("date"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date"})
+			return
+		}
+		if tag.RowsAffected() == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Holiday not found"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Holiday deleted"})
+	}
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ================================
+// Dynamic Pricing Rules (ราคาตามช่วงเวลา/วัน/วันหยุด/จองกระชั้นชิด ต่อแพ็คเกจ)
+// ================================
+
+const (
+	PricingRuleTimeOfDay  = "time_of_day" // ช่วงเวลาของวัน (ข้ามเที่ยงคืนได้) + จำกัดวันได้
+	PricingRuleDayOfWeek  = "day_of_week" // วันในสัปดาห์
+	PricingRuleHoliday    = "holiday"     // วันหยุดจากปฏิทินวันหยุดไทย (admin)
+	PricingRuleLastMinute = "last_minute" // ส่วนลดเมื่อจองใกล้เวลาเริ่ม
+	PricingRuleMinNotice  = "min_notice"  // ค่าบริการเพิ่มเมื่อจองน้อยกว่าเวลาแจ้งล่วงหน้าขั้นต่ำ
+
+	pricingRuleMaxDiscountPercent = 50  // ส่วนลดต่อกฎ และส่วนลดรวมทุกกฎ ไม่เกิน 50% ของราคาแพ็คเกจ
+	pricingRuleMaxPremiumPercent  = 300 // ค่าบริการเพิ่มต่อกฎ
+)
+
+var pricingRuleTypes = map[string]bool{
+	PricingRuleTimeOfDay: true, PricingRuleDayOfWeek: true, PricingRuleHoliday: true,
+	PricingRuleLastMinute: true, PricingRuleMinNotice: true,
+}
+
+var (
+	errPricingRuleType      = errors.New("rule_type must be time_of_day, day_of_week, holiday, last_minute or min_notice")
+	errPricingRuleAdjust    = errors.New("adjustment_percent must be non-zero and between -50 and 300")
+	errPricingRuleDays      = errors.New("days_of_week must be 0 (Sunday) to 6 (Saturday)")
+	errPricingRuleTime      = errors.New("start_time and end_time (HH:MM) are required and must differ")
+	errPricingRuleNotice    = errors.New("notice_hours must be greater than 0")
+	errPricingRuleDirection = errors.New("last_minute rules must discount and min_notice rules must add a premium")
+)
+
+// PackagePricingRule adjusts a package's price by a percentage when it matches the booking
+type PackagePricingRule struct {
+	RuleID            int       `json:"rule_id"`
+	PackageID         int       `json:"package_id"`
+	ProviderID        int       `json:"provider_id"`
+	RuleType          string    `json:"rule_type"`
+	Label             *string   `json:"label"`
+	AdjustmentPercent float64   `json:"adjustment_percent"` // +25 = แพงขึ้น 25%, -15 = ลด 15%
+	DaysOfWeek        []int     `json:"days_of_week"`       // 0 = อาทิตย์ ... 6 = เสาร์
+	StartTime         *string   `json:"start_time"`         // HH:MM (time_of_day)
+	EndTime           *string   `json:"end_time"`           // HH:MM (time_of_day, ไม่รวม)
+	NoticeHours       *int      `json:"notice_hours"`       // last_minute / min_notice
+	IsActive          bool      `json:"is_active"`
+	CreatedAt         time.Time `json:"created_at"`
+}
+
+const pricingRuleColumns = `
+	rule_id, package_id, provider_id, rule_type, label, adjustment_percent, days_of_week,
+	TO_CHAR(start_time, 'HH24:MI'), TO_CHAR(end_time, 'HH24:MI'), notice_hours, is_active, created_at`
+
+func scanPricingRule(row pgx.Row) (*PackagePricingRule, error) {
+	var r PackagePricingRule
+	err := row.Scan(&r.RuleID, &r.PackageID, &r.ProviderID, &r.RuleType, &r.Label, &r.AdjustmentPercent, &r.DaysOfWeek,
+		&r.StartTime, &r.EndTime, &r.NoticeHours, &r.IsActive, &r.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// validatePricingRule checks the fields each rule type needs
+func validatePricingRule(r *PackagePricingRule) error {
+	if !pricingRuleTypes[r.RuleType] {
+		return errPricingRuleType
+	}
+	if r.AdjustmentPercent == 0 || math.IsNaN(r.AdjustmentPercent) ||
+		r.AdjustmentPercent < -pricingRuleMaxDiscountPercent || r.AdjustmentPercent > pricingRuleMaxPremiumPercent {
+		return errPricingRuleAdjust
+	}
+	for _, d := range r.DaysOfWeek {
+		if d < 0 || d > 6 {
+			return errPricingRuleDays
+		}
+	}
+	switch r.RuleType {
+	case PricingRuleTimeOfDay:
+		start, okStart := clockMinutes(r.StartTime)
+		end, okEnd := clockMinutes(r.EndTime)
+		if !okStart || !okEnd || start == end {
+			return errPricingRuleTime
+		}
+	case PricingRuleDayOfWeek:
+		if len(r.DaysOfWeek) == 0 {
+			return errPricingRuleDays
+		}
+	case PricingRuleLastMinute, PricingRuleMinNotice:
+		if r.NoticeHours == nil || *r.NoticeHours <= 0 {
+			return errPricingRuleNotice
+		}
+		if (r.RuleType == PricingRuleLastMinute) != (r.AdjustmentPercent < 0) {
+			return errPricingRuleDirection
+		}
+	}
+	return nil
+}
+
+// clockMinutes parses HH:MM into minutes after midnight
+func clockMinutes(s *string) (int, bool) {
+	if s == nil {
+		return 0, false
+	}
+	t, err := time.Parse("15:04", *s)
+	if err != nil {
+		return 0, false
+	}
+	return t.Hour()*60 + t.Minute(), true
+}
+
+func containsDay(days []int, d time.Weekday) bool {
+	for _, day := range days {
+		if day == int(d) {
+			return true
+		}
+	}
+	return false
+}
+
+// pricingRuleMatches reports whether r applies to a booking starting at start, booked at now.
+// holiday is the name of the holiday on the start date ("" = not a holiday).
+func pricingRuleMatches(r PackagePricingRule, start, now time.Time, holiday string) bool {
+	if !r.IsActive {
+		return false
+	}
+	if len(r.DaysOfWeek) > 0 && !containsDay(r.DaysOfWeek, start.Weekday()) {
+		return false
+	}
+	switch r.RuleType {
+	case PricingRuleTimeOfDay:
+		from, _ := clockMinutes(r.StartTime)
+		until, _ := clockMinutes(r.EndTime)
+		m := start.Hour()*60 + start.Minute()
+		if from < until {
+			return m >= from && m < until
+		}
+		return m >= from || m < until // ข้ามเที่ยงคืน เช่น 22:00-02:00
+	case PricingRuleDayOfWeek:
+		return true // ตรวจวันไปแล้วด้านบน
+	case PricingRuleHoliday:
+		return holiday != ""
+	case PricingRuleLastMinute, PricingRuleMinNotice:
+		return r.NoticeHours != nil && start.Sub(now) < time.Duration(*r.NoticeHours)*time.Hour
+	}
+	return false
+}
+
+// pricingRuleLabel is the line-item label shown to clients
+func pricingRuleLabel(r PackagePricingRule, holiday string) string {
+	if r.Label != nil && *r.Label != "" {
+		return *r.Label
+	}
+	switch r.RuleType {
+	case PricingRuleTimeOfDay:
+		return fmt.Sprintf("Time of day %s-%s", *r.StartTime, *r.EndTime)
+	case PricingRuleDayOfWeek:
+		return "Day of week"
+	case PricingRuleHoliday:
+		return "Holiday surcharge (" + holiday + ")"
+	case PricingRuleLastMinute:
+		return "Last-minute discount"
+	case PricingRuleMinNotice:
+		return "Minimum-notice premium"
+	}
+	return r.RuleType
+}
+
+// pricingRuleAdjustments turns the matching rules into quote line items. Every rule is a
+// percentage of the package price, so the total doesn't depend on rule order. Discounts that
+// match together are capped at pricingRuleMaxDiscountPercent of the package price in total
+// (the last ones are cut down), so stacked rules can never bring the price near ฿0.
+func pricingRuleAdjustments(rules []PackagePricingRule, packagePrice float64, start, now time.Time, holiday string) []PriceLineItem {
+	items := make([]PriceLineItem, 0)
+	discountLeft := roundSatang(packagePrice * pricingRuleMaxDiscountPercent / 100)
+	for _, r := range rules {
+		if !pricingRuleMatches(r, start, now, holiday) {
+			continue
+		}
+		amount := roundSatang(packagePrice * r.AdjustmentPercent / 100)
+		if amount < 0 {
+			amount = -math.Min(-amount, discountLeft)
+			discountLeft = roundSatang(discountLeft + amount)
+		}
+		if math.Abs(amount) < 0.01 {
+			continue
+		}
+		itemType := PriceItemSurcharge
+		if amount < 0 {
+			itemType = PriceItemDiscount
+		}
+		items = append(items, PriceLineItem{Type: itemType, Label: pricingRuleLabel(r, holiday), Amount: amount})
+	}
+	return items
+}
+
+// loadPricingRules returns the active rules of the given packages, keyed by package
+func loadPricingRules(ctx context.Context, dbPool *pgxpool.Pool, packageIDs []int) (map[int][]PackagePricingRule, error) {
+	rows, err := dbPool.Query(ctx, `
+		SELECT `+pricingRuleColumns+`
+		FROM package_pricing_rules
+		WHERE package_id = ANY($1) AND is_active = true
+		ORDER BY rule_id
+	`, packageIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := make(map[int][]PackagePricingRule)
+	for rows.Next() {
+		r, err := scanPricingRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		rules[r.PackageID] = append(rules[r.PackageID], *r)
+	}
+	return rules, rows.Err()
+}
+
+// holidayOn returns the holiday name on date ("" when it's a normal day)
+func holidayOn(ctx context.Context, dbPool *pgxpool.Pool, date time.Time) string {
+	var name string
+	dbPool.QueryRow(ctx, `SELECT name FROM thai_holidays WHERE holiday_date = $1`, date.Format("2006-01-02")).Scan(&name)
+	return name
+}
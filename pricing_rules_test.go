@@ -0,0 +1,97 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func strPtr(s string) *string { return &s }
+
+// Test which dynamic pricing rules match a booking
+func TestPricingRuleMatches(t *testing.T) {
+	friday := time.Date(2026, 10, 23, 23, 0, 0, 0, time.UTC) // Friday 23:00
+	now := time.Date(2026, 10, 20, 12, 0, 0, 0, time.UTC)
+
+	t.Run("Time Of Day Across Midnight", func(t *testing.T) {
+		r := PackagePricingRule{RuleType: PricingRuleTimeOfDay, StartTime: strPtr("22:00"), EndTime: strPtr("02:00"), IsActive: true}
+		assert.True(t, pricingRuleMatches(r, friday, now, ""))
+		assert.True(t, pricingRuleMatches(r, friday.Add(2*time.Hour), now, ""))
+		assert.False(t, pricingRuleMatches(r, friday.Add(-6*time.Hour), now, ""))
+	})
+
+	t.Run("Day Of Week", func(t *testing.T) {
+		r := PackagePricingRule{RuleType: PricingRuleDayOfWeek, DaysOfWeek: []int{5, 6}, IsActive: true}
+		assert.True(t, pricingRuleMatches(r, friday, now, ""))
+		assert.False(t, pricingRuleMatches(r, friday.AddDate(0, 0, 2), now, ""))
+	})
+
+	t.Run("Holiday", func(t *testing.T) {
+		r := PackagePricingRule{RuleType: PricingRuleHoliday, IsActive: true}
+		assert.True(t, pricingRuleMatches(r, friday, now, "Chulalongkorn Day"))
+		assert.False(t, pricingRuleMatches(r, friday, now, ""))
+	})
+
+	t.Run("Notice Hours", func(t *testing.T) {
+		hours := 24
+		r := PackagePricingRule{RuleType: PricingRuleLastMinute, NoticeHours: &hours, IsActive: true}
+		assert.False(t, pricingRuleMatches(r, friday, now, ""))
+		assert.True(t, pricingRuleMatches(r, friday, friday.Add(-3*time.Hour), ""))
+	})
+
+	t.Run("Inactive", func(t *testing.T) {
+		r := PackagePricingRule{RuleType: PricingRuleHoliday}
+		assert.False(t, pricingRuleMatches(r, friday, now, "Chulalongkorn Day"))
+	})
+}
+
+func TestPricingRuleAdjustments(t *testing.T) {
+	friday := time.Date(2026, 10, 23, 23, 0, 0, 0, time.UTC)
+	hours := 6
+	rules := []PackagePricingRule{
+		{RuleType: PricingRuleDayOfWeek, DaysOfWeek: []int{5, 6}, AdjustmentPercent: 20, IsActive: true},
+		{RuleType: PricingRuleHoliday, AdjustmentPercent: 50, IsActive: true},
+		{RuleType: PricingRuleLastMinute, NoticeHours: &hours, AdjustmentPercent: -10, IsActive: true},
+	}
+
+	items := pricingRuleAdjustments(rules, 2000, friday, friday.Add(-2*time.Hour), "Chulalongkorn Day")
+	assert.Len(t, items, 3)
+	assert.Equal(t, 400.0, items[0].Amount)
+	assert.Equal(t, "Holiday surcharge (Chulalongkorn Day)", items[1].Label)
+	assert.Equal(t, PriceItemDiscount, items[2].Type)
+
+	b, err := priceBreakdown(pricingInput{PackageName: "Dinner", PackagePrice: 2000, PackageDuration: 120, Surcharges: items})
+	assert.NoError(t, err)
+	assert.Equal(t, 3400.0, b.Subtotal)
+	assert.Equal(t, 200.0, b.Discount)
+	assert.Equal(t, 3200.0, b.Total)
+
+	t.Run("Stacked Discounts Are Capped", func(t *testing.T) {
+		stacked := []PackagePricingRule{
+			{RuleType: PricingRuleDayOfWeek, DaysOfWeek: []int{5}, AdjustmentPercent: -40, IsActive: true},
+			{RuleType: PricingRuleLastMinute, NoticeHours: &hours, AdjustmentPercent: -30, IsActive: true},
+			{RuleType: PricingRuleTimeOfDay, StartTime: strPtr("22:00"), EndTime: strPtr("02:00"), AdjustmentPercent: -50, IsActive: true},
+		}
+		items := pricingRuleAdjustments(stacked, 2000, friday, friday.Add(-2*time.Hour), "")
+		assert.Len(t, items, 2)
+		assert.Equal(t, -800.0, items[0].Amount)
+		assert.Equal(t, -200.0, items[1].Amount) // เหลือส่วนลดได้อีก 200 จากเพดาน 50%
+
+		b, err := priceBreakdown(pricingInput{PackageName: "Dinner", PackagePrice: 2000, PackageDuration: 120, Surcharges: items})
+		assert.NoError(t, err)
+		assert.Equal(t, 1000.0, b.Total)
+	})
+}
+
+func TestValidatePricingRule(t *testing.T) {
+	hours := 12
+	assert.NoError(t, validatePricingRule(&PackagePricingRule{RuleType: PricingRuleMinNotice, NoticeHours: &hours, AdjustmentPercent: 15}))
+	assert.ErrorIs(t, validatePricingRule(&PackagePricingRule{RuleType: PricingRuleMinNotice, NoticeHours: &hours, AdjustmentPercent: -15}), errPricingRuleDirection)
+	assert.ErrorIs(t, validatePricingRule(&PackagePricingRule{RuleType: PricingRuleTimeOfDay, StartTime: strPtr("22:00"), AdjustmentPercent: 10}), errPricingRuleTime)
+	assert.ErrorIs(t, validatePricingRule(&PackagePricingRule{RuleType: PricingRuleDayOfWeek, DaysOfWeek: []int{7}, AdjustmentPercent: 10}), errPricingRuleDays)
+	assert.ErrorIs(t, validatePricingRule(&PackagePricingRule{RuleType: "surge", AdjustmentPercent: 10}), errPricingRuleType)
+	assert.ErrorIs(t, validatePricingRule(&PackagePricingRule{RuleType: PricingRuleLastMinute, NoticeHours: &hours, AdjustmentPercent: -60}), errPricingRuleAdjust)
+	assert.ErrorIs(t, validatePricingRule(&PackagePricingRule{RuleType: PricingRuleHoliday, AdjustmentPercent: 301}), errPricingRuleAdjust)
+	assert.ErrorIs(t, validatePricingRule(&PackagePricingRule{RuleType: PricingRuleHoliday, AdjustmentPercent: 0}), errPricingRuleAdjust)
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test itemized pricing for bookings and extensions
+func TestPriceBreakdown(t *testing.T) {
+	t.Run("Package Only", func(t *testing.T) {
+		b, err := priceBreakdown(pricingInput{PackageName: "Dinner", PackagePrice: 2000, PackageDuration: 120})
+		assert.NoError(t, err)
+		assert.Len(t, b.Items, 1)
+		assert.Equal(t, 2000.0, b.Subtotal)
+		assert.Equal(t, 2000.0, b.Total)
+		assert.Equal(t, 0.0, b.DepositAmount)
+		assert.Equal(t, 200.0, b.Commission.PlatformCommission)
+		assert.Equal(t, 55.0, b.Commission.GatewayFee)
+		assert.Equal(t, 1745.0, b.Commission.ProviderNet)
+	})
+
+	t.Run("Provider Commission Rule", func(t *testing.T) {
+		b, err := priceBreakdown(pricingInput{PackageName: "Dinner", PackagePrice: 2000, PackageDuration: 120,
+			Commission: &commissionRates{Platform: 0.08, Gateway: 0.0275}})
+		assert.NoError(t, err)
+		assert.Equal(t, 0.08, b.Commission.PlatformRate)
+		assert.Equal(t, 160.0, b.Commission.PlatformCommission)
+		assert.Equal(t, 1785.0, b.Commission.ProviderNet)
+	})
+
+	t.Run("Surcharge Coupon And Deposit", func(t *testing.T) {
+		b, err := priceBreakdown(pricingInput{
+			PackageName:     "Dinner",
+			PackagePrice:    2000,
+			PackageDuration: 120,
+			Surcharges:      []PriceLineItem{{Type: PriceItemSurcharge, Label: "Holiday", Amount: 500}},
+			Coupon:          &Coupon{Code: "SAVE10", DiscountType: "percentage", DiscountValue: 10},
+			DepositRate:     0.30,
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 2500.0, b.Subtotal)
+		assert.Equal(t, 250.0, b.Discount)
+		assert.Equal(t, 2250.0, b.Total)
+		assert.Equal(t, 675.0, b.DepositAmount)
+		assert.Equal(t, 250.0, b.couponDiscount())
+	})
+
+	t.Run("Extension Tier", func(t *testing.T) {
+		b, err := priceBreakdown(pricingInput{PackagePrice: 1200, PackageDuration: 60, ExtensionMinutes: 30})
+		assert.NoError(t, err)
+		assert.Equal(t, 600.0, b.Subtotal)
+		assert.Equal(t, 60.0, b.Discount)
+		assert.Equal(t, 540.0, b.Total)
+	})
+
+	t.Run("Extension Ignores Coupon", func(t *testing.T) {
+		b, err := priceBreakdown(pricingInput{PackagePrice: 1200, PackageDuration: 60, ExtensionMinutes: 60,
+			Coupon: &Coupon{Code: "X", DiscountType: "fixed", DiscountValue: 100}})
+		assert.NoError(t, err)
+		assert.Equal(t, 1020.0, b.Total)
+		assert.Equal(t, 0.0, b.couponDiscount())
+	})
+
+	t.Run("Unsupported Extension Length", func(t *testing.T) {
+		_, err := priceBreakdown(pricingInput{PackagePrice: 1200, PackageDuration: 60, ExtensionMinutes: 45})
+		assert.ErrorIs(t, err, errExtensionMinutes)
+	})
+}
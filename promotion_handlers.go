@@ -2,12 +2,15 @@ package main
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -84,16 +87,23 @@ func payDepositHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFun
 		userID, _ := c.Get("userID")
 		bookingID, _ := strconv.Atoi(c.Param("id"))
 
+		var input walletPaymentOption
+		c.ShouldBindJSON(&input)
+
 		// Verify booking and get deposit info
 		var clientID, providerID int
 		var totalPrice, depositPercentage float64
+		var quotedDeposit *float64
 		err := dbPool.QueryRow(ctx, `
 			SELECT b.client_id, b.provider_id, b.total_price, 
-				   COALESCE(pds.deposit_percentage, 0.30) as deposit_pct
+				   COALESCE(bs.deposit_percentage, pds.deposit_percentage, 0.30) as deposit_pct,
+				   CASE WHEN pq.total = b.total_price AND pq.deposit_amount > 0 THEN pq.deposit_amount END
 			FROM bookings b
 			LEFT JOIN provider_deposit_settings pds ON b.provider_id = pds.provider_id
+			LEFT JOIN price_quotes pq ON pq.quote_id = b.quote_id
+			LEFT JOIN booking_snapshots bs ON bs.booking_id = b.booking_id
 			WHERE b.booking_id = $1 AND b.status = 'pending'
-		`, bookingID).Scan(&clientID, &providerID, &totalPrice, &depositPercentage)
+		`, bookingID).Scan(&clientID, &providerID, &totalPrice, &depositPercentage, &quotedDeposit)
 
 		if err != nil {
 			c.JSON(http.StatusNotFound, gin.H{"error": "ไม่พบการจอง"})
@@ -105,7 +115,11 @@ func payDepositHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFun
 			return
 		}
 
-		depositAmount := totalPrice * depositPercentage
+		// มัดจำตาม quote ถ้ายอด booking ยังตรงกับ quote
+		depositAmount := roundSatang(totalPrice * depositPercentage)
+		if quotedDeposit != nil {
+			depositAmount = *quotedDeposit
+		}
 
 		// Check if deposit already exists
 		var existingID int
@@ -115,17 +129,43 @@ func payDepositHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFun
 			return
 		}
 
-		// TODO: Process payment via Stripe
-		// For now, just create deposit record
+		fromWallet, remaining, err := planWalletPayment(depositAmount, getWalletSpendableBalance(ctx, dbPool, userID), input, 0)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		// ยังไม่มีการตัดบัตรสำหรับมัดจำ → บันทึกว่าจ่ายแล้วได้เฉพาะเมื่อ wallet ครอบคลุมทั้งหมด
+		if remaining > 0 {
+			c.JSON(http.StatusPaymentRequired, gin.H{"error": errCardPaymentUnavailable.Error(), "amount": depositAmount})
+			return
+		}
+
+		tx, err := dbPool.Begin(ctx)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "ไม่สามารถดำเนินการเงินมัดจำได้"})
+			return
+		}
+		defer tx.Rollback(ctx)
+
+		// ตัด wallet แล้วผูก transaction ไว้กับมัดจำ (ใช้ยืนยันว่ามัดจำมีเงินจริงตอนหักค่าปรับ/คืนเงิน)
+		var walletTransactionID *int
+		if fromWallet > 0 {
+			transactionID, err := payFromWallet(ctx, tx, userID, fromWallet, &bookingID, fmt.Sprintf("Wallet payment for deposit of booking #%d", bookingID))
+			if err != nil {
+				c.JSON(http.StatusPaymentRequired, gin.H{"error": "ยอดเงินใน wallet ไม่เพียงพอ"})
+				return
+			}
+			walletTransactionID = &transactionID
+		}
 
 		var depositID int
-		err = dbPool.QueryRow(ctx, `
-			INSERT INTO booking_deposits (booking_id, client_id, provider_id, amount, percentage, status, paid_at)
-			VALUES ($1, $2, $3, $4, $5, 'paid', NOW())
+		err = tx.QueryRow(ctx, `
+			INSERT INTO booking_deposits (booking_id, client_id, provider_id, amount, percentage, status, paid_at, wallet_transaction_id)
+			VALUES ($1, $2, $3, $4, $5, 'paid', NOW(), $6)
 			RETURNING deposit_id
-		`, bookingID, clientID, providerID, depositAmount, depositPercentage).Scan(&depositID)
+		`, bookingID, clientID, providerID, depositAmount, depositPercentage, walletTransactionID).Scan(&depositID)
 
-		if err != nil {
+		if err != nil || tx.Commit(ctx) != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "ไม่สามารถดำเนินการเงินมัดจำได้"})
 			return
 		}
@@ -139,12 +179,18 @@ func payDepositHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFun
 			"amount":     depositAmount,
 		})
 
+		// ออกใบเสร็จเงินมัดจำ
+		if _, err := issueDepositReceipt(ctx, dbPool, depositID); err != nil {
+			logReceiptFailure(ReceiptSourceDeposit, strconv.Itoa(depositID), err)
+		}
+
 		c.JSON(http.StatusCreated, gin.H{
-			"deposit_id": depositID,
-			"amount":     depositAmount,
-			"percentage": depositPercentage,
-			"message":    "ชำระเงินมัดจำสำเร็จ",
-			"remaining":  totalPrice - depositAmount,
+			"deposit_id":  depositID,
+			"amount":      depositAmount,
+			"wallet_paid": fromWallet,
+			"percentage":  depositPercentage,
+			"message":     "ชำระเงินมัดจำสำเร็จ",
+			"remaining":   totalPrice - depositAmount,
 		})
 	}
 }
@@ -226,7 +272,8 @@ func cancelBookingWithFeeHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.
 		bookingID, _ := strconv.Atoi(c.Param("id"))
 
 		var input struct {
-			Reason string `json:"reason"`
+			Reason         string `json:"reason"`
+			RefundToWallet bool   `json:"refund_to_wallet"` // คืนเงินมัดจำ/ค่าบริการเข้า wallet แทนช่องทางเดิม
 		}
 		c.ShouldBindJSON(&input)
 
@@ -234,11 +281,12 @@ func cancelBookingWithFeeHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.
 		var clientID, providerID int
 		var totalPrice float64
 		var startTime time.Time
+		var timezone string
 		err := dbPool.QueryRow(ctx, `
-			SELECT client_id, provider_id, total_price, start_time
+			SELECT client_id, provider_id, total_price, start_time, timezone
 			FROM bookings
 			WHERE booking_id = $1 AND status IN ('pending', 'confirmed', 'deposit_paid')
-		`, bookingID).Scan(&clientID, &providerID, &totalPrice, &startTime)
+		`, bookingID).Scan(&clientID, &providerID, &totalPrice, &startTime, &timezone)
 
 		if err != nil {
 			c.JSON(http.StatusNotFound, gin.H{"error": "ไม่พบการจองหรือไม่สามารถยกเลิกได้"})
@@ -250,52 +298,124 @@ func cancelBookingWithFeeHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.
 			return
 		}
 
-		// Calculate hours before booking
-		hoursUntilBooking := time.Until(startTime).Hours()
+		// Calculate hours before booking (start_time เป็น timestamptz → นับจากเวลาจริง ไม่ขึ้นกับ timezone)
+		hoursUntilBooking := hoursUntilStart(startTime, time.Now())
 
-		// Get applicable fee
+		// Get applicable fee (นโยบายยกเลิก ณ เวลาที่จอง)
 		var feePercentage float64 = 0
-		err = dbPool.QueryRow(ctx, `
-			SELECT fee_percentage FROM cancellation_policies
-			WHERE provider_id = $1 AND is_active = true AND hours_before_booking >= $2
-			ORDER BY hours_before_booking ASC
-			LIMIT 1
-		`, providerID, hoursUntilBooking).Scan(&feePercentage)
+		if snap, err := loadBookingSnapshot(ctx, dbPool, bookingID); err == nil {
+			feePercentage = cancellationFeeRate(snap.CancellationTiers, hoursUntilBooking)
+		} else {
+			dbPool.QueryRow(ctx, `
+				SELECT fee_percentage FROM cancellation_policies
+				WHERE provider_id = $1 AND is_active = true AND hours_before_booking >= $2
+				ORDER BY hours_before_booking ASC
+				LIMIT 1
+			`, providerID, hoursUntilBooking).Scan(&feePercentage)
+		}
 
-		feeAmount := totalPrice * feePercentage
+		feeAmount := roundSatang(totalPrice * feePercentage)
+		clientCancels := userID == clientID
 
-		// Create cancellation fee record (only if client cancels)
-		if userID == clientID && feeAmount > 0 {
-			dbPool.Exec(ctx, `
-				INSERT INTO cancellation_fees (booking_id, cancelled_by, fee_amount, fee_percentage, status)
-				VALUES ($1, $2, $3, $4, 'pending')
-			`, bookingID, userID, feeAmount, feePercentage)
+		tx, err := dbPool.Begin(ctx)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "ไม่สามารถยกเลิกการจองได้"})
+			return
 		}
+		defer tx.Rollback(ctx)
 
-		// Handle deposit (refund or forfeit)
-		if userID == clientID {
-			// Client cancels - forfeit deposit to provider
-			dbPool.Exec(ctx, `
-				UPDATE booking_deposits SET status = 'forfeited', forfeited_at = NOW()
-				WHERE booking_id = $1 AND status = 'paid'
-			`, bookingID)
+		// ล็อก booking ก่อนคืนเงิน/สร้างค่าปรับ → คำขอยกเลิกที่ส่งซ้อนกันจะได้ 409 ไม่คืนเงินซ้ำ
+		var lockedID int
+		err = tx.QueryRow(ctx, `
+			SELECT booking_id FROM bookings
+			WHERE booking_id = $1 AND status IN ('pending', 'confirmed', 'deposit_paid')
+			FOR UPDATE
+		`, bookingID).Scan(&lockedID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusConflict, gin.H{"error": "การจองนี้ถูกยกเลิกหรือเปลี่ยนสถานะไปแล้ว"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "ไม่สามารถยกเลิกการจองได้"})
+			return
+		}
+
+		// Create cancellation fee record (only if client cancels)
+		var feeID int
+		if clientCancels && feeAmount > 0 {
+			err = tx.QueryRow(ctx, `
+				INSERT INTO cancellation_fees (booking_id, cancelled_by, client_id, provider_id, fee_amount, fee_percentage, status)
+				VALUES ($1, $2, $2, $3, $4, $5, 'pending')
+				RETURNING fee_id
+			`, bookingID, clientID, providerID, feeAmount, feePercentage).Scan(&feeID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "ไม่สามารถบันทึกค่าปรับได้"})
+				return
+			}
 		} else {
-			// Provider cancels - refund deposit to client
-			dbPool.Exec(ctx, `
-				UPDATE booking_deposits SET status = 'refunded', refunded_at = NOW()
-				WHERE booking_id = $1 AND status = 'paid'
-			`, bookingID)
+			// ไม่มีค่าปรับ (หรือ provider ยกเลิก) → คืนเงินมัดจำและยอดที่ชำระแล้วทั้งหมดให้ client
+			depositID, depositAmount, err := lockFundedDeposit(ctx, tx, bookingID)
+			if err == nil && depositAmount > 0 {
+				_, err = tx.Exec(ctx, `
+					UPDATE booking_deposits SET status = 'refunded', refunded_at = NOW(), updated_at = NOW()
+					WHERE deposit_id = $1
+				`, depositID)
+				if err == nil {
+					err = recordClientRefund(ctx, tx, bookingID, clientID, depositAmount,
+						fmt.Sprintf("Deposit refund for cancelled booking #%d", bookingID), input.RefundToWallet)
+				}
+			}
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "ไม่สามารถคืนเงินมัดจำได้"})
+				return
+			}
+
+			captured, err := reverseBookingPayment(ctx, tx, bookingID)
+			if err == nil {
+				err = recordClientRefund(ctx, tx, bookingID, clientID, captured,
+					fmt.Sprintf("Payment refund for cancelled booking #%d", bookingID), input.RefundToWallet)
+			}
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "ไม่สามารถคืนเงินค่าบริการได้"})
+				return
+			}
 		}
 
 		// Update booking
-		dbPool.Exec(ctx, `
+		tag, err := tx.Exec(ctx, `
 			UPDATE bookings SET status = 'cancelled', cancelled_at = NOW(), cancellation_reason = $1
-			WHERE booking_id = $2
+			WHERE booking_id = $2 AND status IN ('pending', 'confirmed', 'deposit_paid')
 		`, input.Reason, bookingID)
+		if err == nil && tag.RowsAffected() == 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "การจองนี้ถูกยกเลิกหรือเปลี่ยนสถานะไปแล้ว"})
+			return
+		}
+		if err == nil {
+			// คืนสิทธิ์คูปอง / งบแคมเปญที่ใช้กับ booking นี้
+			err = releaseBookingDiscounts(ctx, tx, bookingID)
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "ไม่สามารถยกเลิกการจองได้"})
+			return
+		}
+
+		if err := tx.Commit(ctx); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "ไม่สามารถยกเลิกการจองได้"})
+			return
+		}
+
+		// เก็บค่าปรับ: หักจากมัดจำ → ยอดที่ชำระแล้ว → ตัดบัตรที่บันทึกไว้ → ค้างชำระ
+		var fee *CancellationFee
+		if feeID != 0 {
+			fee, err = settleCancellationFee(ctx, dbPool, feeID, input.RefundToWallet)
+			if err != nil {
+				fmt.Printf("Warning: Failed to settle cancellation fee %d: %v\n", feeID, err)
+			}
+		}
 
 		// Notify other party
 		notifyUserID := clientID
-		if userID == clientID {
+		if clientCancels {
 			notifyUserID = providerID
 		}
 		CreateNotification(notifyUserID, "booking_cancelled", "การจองถูกยกเลิกแล้ว", map[string]interface{}{
@@ -303,12 +423,21 @@ func cancelBookingWithFeeHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.
 			"cancelled_by":     userID,
 			"cancellation_fee": feeAmount,
 		})
+		if fee != nil && fee.Outstanding > 0 {
+			CreateNotification(clientID, "cancellation_fee_due",
+				fmt.Sprintf("มีค่าปรับการยกเลิกค้างชำระ ฿%s กรุณาชำระก่อนทำการจองครั้งถัดไป", formatBaht(fee.Outstanding)),
+				map[string]interface{}{"fee_id": fee.FeeID, "booking_id": bookingID, "outstanding": fee.Outstanding})
+		}
 
 		c.JSON(http.StatusOK, gin.H{
 			"message":             "ยกเลิกการจองสำเร็จ",
 			"cancellation_fee":    feeAmount,
 			"fee_percentage":      feePercentage,
 			"hours_until_booking": hoursUntilBooking,
+			"start_time":          startTime.In(timezoneOrDefault(timezone)),
+			"timezone":            timezone,
+			"fee_settlement":      fee,
+			"refund_destination":  refundDestination(input.RefundToWallet),
 		})
 	}
 }
@@ -379,30 +508,66 @@ func purchaseBoostHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.Handler
 			return
 		}
 
-		// TODO: Process payment via Stripe
+		fromWallet, remaining, err := planWalletPayment(pkg.Price, getWalletSpendableBalance(ctx, dbPool, userID), input.walletPaymentOption, 0)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		// ยังไม่มีการตัดบัตรสำหรับ boost → เปิดใช้ได้เฉพาะเมื่อจ่ายจาก wallet ครบ
+		if remaining > 0 {
+			c.JSON(http.StatusPaymentRequired, gin.H{"error": errCardPaymentUnavailable.Error(), "amount": pkg.Price})
+			return
+		}
+
+		tx, err := dbPool.Begin(ctx)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "ไม่สามารถเปิดใช้งาน boost ได้"})
+			return
+		}
+		defer tx.Rollback(ctx)
+
+		// ส่วนที่จ่ายจาก wallet ตัดทันที
+		if fromWallet > 0 {
+			_, err = payFromWallet(ctx, tx, userID, fromWallet, nil, fmt.Sprintf("Wallet payment for profile boost - %s", pkg.Name))
+			if err != nil {
+				c.JSON(http.StatusPaymentRequired, gin.H{"error": "ยอดเงินใน wallet ไม่เพียงพอ"})
+				return
+			}
+		}
 
 		startTime := time.Now()
 		endTime := startTime.Add(time.Duration(pkg.Duration) * time.Hour)
 
 		var boostID int
-		err = dbPool.QueryRow(ctx, `
+		err = tx.QueryRow(ctx, `
 			INSERT INTO profile_boosts (user_id, boost_type, start_time, end_time, amount, status)
 			VALUES ($1, $2, $3, $4, $5, 'active')
 			RETURNING boost_id
 		`, userID, pkg.BoostType, startTime, endTime, pkg.Price).Scan(&boostID)
 
-		if err != nil {
+		if err != nil || tx.Commit(ctx) != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "ไม่สามารถเปิดใช้งาน boost ได้"})
 			return
 		}
 
+		issueReceiptQuietly(ctx, dbPool, receiptSource{
+			SourceType:    ReceiptSourceBoost,
+			SourceRef:     strconv.Itoa(boostID),
+			BuyerID:       userID.(int),
+			Description:   fmt.Sprintf("Profile boost - %s (%d hours)", pkg.Name, pkg.Duration),
+			AmountPaid:    pkg.Price,
+			PaymentMethod: "wallet",
+			PaidAt:        startTime,
+		})
+
 		c.JSON(http.StatusCreated, gin.H{
-			"boost_id":   boostID,
-			"boost_type": pkg.BoostType,
-			"start_time": startTime,
-			"end_time":   endTime,
-			"price":      pkg.Price,
-			"message":    "เปิดใช้งาน boost สำเร็จ",
+			"boost_id":    boostID,
+			"boost_type":  pkg.BoostType,
+			"start_time":  startTime,
+			"end_time":    endTime,
+			"price":       pkg.Price,
+			"wallet_paid": fromWallet,
+			"message":     "เปิดใช้งาน boost สำเร็จ",
 		})
 	}
 }
@@ -479,11 +644,11 @@ func createCouponHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerF
 		var couponID int
 		err := dbPool.QueryRow(ctx, `
 			INSERT INTO coupons (code, discount_type, discount_value, min_booking_amount, max_discount, 
-								 valid_from, valid_until, usage_limit, created_by, provider_id)
-			VALUES (UPPER($1), $2, $3, $4, $5, $6, $7, $8, $9, $10)
+								 valid_from, valid_until, usage_limit, created_by, provider_id, stackable)
+			VALUES (UPPER($1), $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
 			RETURNING coupon_id
 		`, input.Code, input.DiscountType, input.DiscountValue, input.MinBookingAmount,
-			input.MaxDiscount, validFrom, validUntil, input.UsageLimit, userID, providerID).Scan(&couponID)
+			input.MaxDiscount, validFrom, validUntil, input.UsageLimit, userID, providerID, input.Stackable).Scan(&couponID)
 
 		if err != nil {
 			if strings.Contains(err.Error(), "duplicate") {
@@ -505,7 +670,7 @@ func createCouponHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerF
 // POST /coupons/apply
 func applyCouponHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		userID, _ := c.Get("userID")
+		userID := c.GetInt("userID")
 
 		var input ApplyCouponRequest
 		if err := c.ShouldBindJSON(&input); err != nil {
@@ -513,102 +678,42 @@ func applyCouponHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFu
 			return
 		}
 
-		// Get coupon details
-		var coupon Coupon
-		err := dbPool.QueryRow(ctx, `
-			SELECT coupon_id, code, discount_type, discount_value, min_booking_amount, max_discount, 
-				   valid_from, valid_until, usage_limit, used_count, provider_id
-			FROM coupons
-			WHERE UPPER(code) = UPPER($1) AND is_active = true
-		`, input.Code).Scan(&coupon.CouponID, &coupon.Code, &coupon.DiscountType, &coupon.DiscountValue,
-			&coupon.MinBookingAmount, &coupon.MaxDiscount, &coupon.ValidFrom, &coupon.ValidUntil,
-			&coupon.UsageLimit, &coupon.UsedCount, &coupon.ProviderID)
-
+		tx, err := dbPool.Begin(ctx)
 		if err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "ไม่พบคูปอง"})
-			return
-		}
-
-		// Validate coupon
-		now := time.Now()
-		if now.Before(coupon.ValidFrom) || now.After(coupon.ValidUntil) {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "คูปองหมดอายุหรือยังไม่เปิดใช้งาน"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "ไม่สามารถใช้คูปองได้"})
 			return
 		}
+		defer tx.Rollback(ctx)
 
-		if coupon.UsageLimit != nil && coupon.UsedCount >= *coupon.UsageLimit {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "คูปองถูกใช้ครบจำนวนที่กำหนดแล้ว"})
+		r, err := redeemCoupon(ctx, tx, input.Code, input.BookingID, userID, time.Now())
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			c.JSON(http.StatusNotFound, gin.H{"error": "ไม่พบคูปองหรือการจอง"})
 			return
-		}
-
-		// Check if user already used this coupon
-		var usageID int
-		err = dbPool.QueryRow(ctx, `SELECT usage_id FROM coupon_usages WHERE coupon_id = $1 AND user_id = $2`,
-			coupon.CouponID, userID).Scan(&usageID)
-		if err == nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "คุณใช้คูปองนี้ไปแล้ว"})
+		case errors.Is(err, errCouponUsedUp), errors.Is(err, errCouponAlreadyUsed),
+			errors.Is(err, errCouponOnBooking), errors.Is(err, errCouponNotStackable):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
 			return
-		}
-
-		// Get booking details
-		var providerID int
-		var totalPrice float64
-		err = dbPool.QueryRow(ctx, `
-			SELECT provider_id, total_price FROM bookings
-			WHERE booking_id = $1 AND client_id = $2 AND status IN ('pending', 'deposit_paid')
-		`, input.BookingID, userID).Scan(&providerID, &totalPrice)
-
-		if err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "ไม่พบการจอง"})
+		case errors.Is(err, errCouponNotValid), errors.Is(err, errCouponOtherProvider), errors.Is(err, errCouponBelowMinimum),
+			errors.Is(err, errCouponNothingToApply), errors.Is(err, errBookingNotRedeemable):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
-		}
-
-		// Check if coupon is for this provider
-		if coupon.ProviderID != nil && *coupon.ProviderID != providerID {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "คูปองนี้ใช้ไม่ได้กับผู้ให้บริการนี้"})
+		case err != nil:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "ไม่สามารถใช้คูปองได้"})
 			return
 		}
-
-		// Check minimum booking amount
-		if coupon.MinBookingAmount != nil && totalPrice < *coupon.MinBookingAmount {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "ยอดการจองต่ำกว่าขั้นต่ำที่กำหนด"})
+		if err := tx.Commit(ctx); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "ไม่สามารถใช้คูปองได้"})
 			return
 		}
 
-		// Calculate discount
-		var discountAmount float64
-		if coupon.DiscountType == "percentage" {
-			discountAmount = totalPrice * (coupon.DiscountValue / 100)
-		} else {
-			discountAmount = coupon.DiscountValue
-		}
-
-		// Apply max discount cap
-		if coupon.MaxDiscount != nil && discountAmount > *coupon.MaxDiscount {
-			discountAmount = *coupon.MaxDiscount
-		}
-
-		newTotal := totalPrice - discountAmount
-
-		// Record usage
-		dbPool.Exec(ctx, `
-			INSERT INTO coupon_usages (coupon_id, user_id, booking_id, discount_amount)
-			VALUES ($1, $2, $3, $4)
-		`, coupon.CouponID, userID, input.BookingID, discountAmount)
-
-		// Update coupon used count
-		dbPool.Exec(ctx, `UPDATE coupons SET used_count = used_count + 1 WHERE coupon_id = $1`, coupon.CouponID)
-
-		// Update booking total
-		dbPool.Exec(ctx, `UPDATE bookings SET total_price = $1 WHERE booking_id = $2`, newTotal, input.BookingID)
-
 		c.JSON(http.StatusOK, gin.H{
-			"coupon_code":     coupon.Code,
-			"discount_type":   coupon.DiscountType,
-			"discount_value":  coupon.DiscountValue,
-			"discount_amount": discountAmount,
-			"original_price":  totalPrice,
-			"new_total":       newTotal,
+			"coupon_code":     r.Code,
+			"usage_id":        r.UsageID,
+			"discount_amount": r.DiscountAmount,
+			"original_price":  r.OriginalPrice,
+			"total_discount":  r.TotalDiscount,
+			"new_total":       r.NewTotal,
 			"message":         "ใช้คูปองสำเร็จ",
 		})
 	}
@@ -678,6 +783,7 @@ func browseCouponsHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.Handler
 			  AND c.valid_from <= NOW() 
 			  AND c.valid_until >= NOW()
 			  AND (c.usage_limit IS NULL OR c.used_count < c.usage_limit)
+			  AND c.assigned_user_id IS NULL
 			ORDER BY c.created_at DESC
 		`)
 		if err != nil {
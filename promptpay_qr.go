@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+
+	"rsc.io/qr"
+)
+
+// PromptPay QR Code Generator
+// อ้างอิง: EMVCo QR Code Specification for Payment Systems + BOT PromptPay Tag 29
+
+// PromptPay target types (sub-tag ของ Tag 29)
+const (
+	PromptPayTargetPhone      = "phone"       // เบอร์มือถือ (Sub-tag 01)
+	PromptPayTargetNationalID = "national_id" // เลขบัตรประชาชน / เลขประจำตัวผู้เสียภาษี 13 หลัก (Sub-tag 02)
+	PromptPayTargetEWallet    = "ewallet"     // e-Wallet ID 15 หลัก (Sub-tag 03)
+)
+
+const promptPayAID = "A000000677010111"
+
+var (
+	errInvalidPromptPayPhone      = errors.New("invalid PromptPay phone number: must be a 10-digit Thai mobile number")
+	errInvalidPromptPayNationalID = errors.New("invalid PromptPay national/tax ID: must be 13 digits with a valid check digit")
+	errInvalidPromptPayEWallet    = errors.New("invalid PromptPay e-Wallet ID: must be 15 digits")
+	errUnknownPromptPayTarget     = errors.New("unrecognised PromptPay ID: expected a phone number, 13-digit national/tax ID or 15-digit e-Wallet ID")
+)
+
+// PromptPayTarget is a validated, normalized PromptPay receiver
+type PromptPayTarget struct {
+	Type  string `json:"type"`
+	Value string `json:"value"` // normalized value as written into the payload
+}
+
+// parsePromptPayTarget validates and normalizes a PromptPay ID.
+// If targetType is empty, the type is detected from the number of digits.
+func parsePromptPayTarget(raw string, targetType string) (PromptPayTarget, error) {
+	digits := onlyDigits(raw)
+
+	if targetType == "" {
+		switch {
+		case len(digits) == 13 && !strings.HasPrefix(digits, "0066"):
+			targetType = PromptPayTargetNationalID
+		case len(digits) == 15:
+			targetType = PromptPayTargetEWallet
+		case len(digits) >= 9 && len(digits) <= 13:
+			targetType = PromptPayTargetPhone
+		default:
+			return PromptPayTarget{}, errUnknownPromptPayTarget
+		}
+	}
+
+	switch targetType {
+	case PromptPayTargetPhone:
+		phone, err := normalizePromptPayPhone(digits)
+		if err != nil {
+			return PromptPayTarget{}, err
+		}
+		return PromptPayTarget{Type: PromptPayTargetPhone, Value: phone}, nil
+	case PromptPayTargetNationalID:
+		if !isValidThaiNationalID(digits) {
+			return PromptPayTarget{}, errInvalidPromptPayNationalID
+		}
+		return PromptPayTarget{Type: PromptPayTargetNationalID, Value: digits}, nil
+	case PromptPayTargetEWallet:
+		if len(digits) != 15 {
+			return PromptPayTarget{}, errInvalidPromptPayEWallet
+		}
+		return PromptPayTarget{Type: PromptPayTargetEWallet, Value: digits}, nil
+	default:
+		return PromptPayTarget{}, fmt.Errorf("unsupported PromptPay target type: %s", targetType)
+	}
+}
+
+// normalizePromptPayPhone converts a Thai mobile number to the 13-digit
+// 0066XXXXXXXXX form expected by banking apps.
+// Accepts 0812345678, 66812345678, +66 81 234 5678, 0066812345678 and 812345678.
+func normalizePromptPayPhone(digits string) (string, error) {
+	switch {
+	case len(digits) == 13 && strings.HasPrefix(digits, "0066"):
+		digits = digits[4:]
+	case len(digits) == 11 && strings.HasPrefix(digits, "66"):
+		digits = digits[2:]
+	case len(digits) == 10 && strings.HasPrefix(digits, "0"):
+		digits = digits[1:]
+	}
+
+	// Thai mobile numbers start with 6, 8 or 9 after the leading zero
+	if len(digits) != 9 || !strings.ContainsRune("689", rune(digits[0])) {
+		return "", errInvalidPromptPayPhone
+	}
+	return "0066" + digits, nil
+}
+
+// isValidThaiNationalID checks the mod-11 check digit used by both
+// personal ID cards and juristic tax IDs
+func isValidThaiNationalID(id string) bool {
+	if len(id) != 13 || onlyDigits(id) != id {
+		return false
+	}
+	sum := 0
+	for i := 0; i < 12; i++ {
+		sum += int(id[i]-'0') * (13 - i)
+	}
+	return (11-sum%11)%10 == int(id[12]-'0')
+}
+
+func onlyDigits(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// emvTLV encodes a single EMVCo tag-length-value field
+func emvTLV(tag, value string) string {
+	return fmt.Sprintf("%s%02d%s", tag, len(value), value)
+}
+
+// buildPromptPayPayload builds the EMVCo payload for an already-validated target.
+// An amount of 0 produces a static (reusable) QR without Tag 54.
+func buildPromptPayPayload(target PromptPayTarget, amount float64) string {
+	subTag := "01"
+	switch target.Type {
+	case PromptPayTargetNationalID:
+		subTag = "02"
+	case PromptPayTargetEWallet:
+		subTag = "03"
+	}
+
+	// Point of Initiation: 11 = static, 12 = dynamic (one-time amount)
+	pointOfInitiation := "11"
+	if amount > 0 {
+		pointOfInitiation = "12"
+	}
+
+	var b strings.Builder
+	b.WriteString(emvTLV("00", "01"))
+	b.WriteString(emvTLV("01", pointOfInitiation))
+	// Merchant Account Information (Tag 29)
+	b.WriteString(emvTLV("29", emvTLV("00", promptPayAID)+emvTLV(subTag, target.Value)))
+	// Country Code (Tag 58)
+	b.WriteString(emvTLV("58", "TH"))
+	// Transaction Currency (Tag 53) - THB = 764
+	b.WriteString(emvTLV("53", "764"))
+	if amount > 0 {
+		// Amount (Tag 54)
+		b.WriteString(emvTLV("54", fmt.Sprintf("%.2f", amount)))
+	}
+	// CRC (Tag 63) covers everything up to and including its own tag + length
+	b.WriteString("6304")
+
+	payload := b.String()
+	return payload + calculateCRC16(payload)
+}
+
+// generatePromptPayQR validates a PromptPay ID and returns the EMVCo payload string
+func generatePromptPayQR(promptPayID string, targetType string, amount float64) (string, error) {
+	target, err := parsePromptPayTarget(promptPayID, targetType)
+	if err != nil {
+		return "", err
+	}
+	return buildPromptPayPayload(target, amount), nil
+}
+
+// CRC16-CCITT Checksum calculation for PromptPay QR
+func calculateCRC16(data string) string {
+	crc := uint16(0xFFFF)
+	polynomial := uint16(0x1021)
+
+	for i := 0; i < len(data); i++ {
+		crc ^= uint16(data[i]) << 8
+		for j := 0; j < 8; j++ {
+			if (crc & 0x8000) != 0 {
+				crc = (crc << 1) ^ polynomial
+			} else {
+				crc = crc << 1
+			}
+		}
+	}
+
+	return fmt.Sprintf("%04X", crc&0xFFFF)
+}
+
+// renderQRPNG renders a QR payload as a PNG image (scale = pixels per module)
+func renderQRPNG(payload string, scale int) ([]byte, error) {
+	code, err := qr.Encode(payload, qr.M)
+	if err != nil {
+		return nil, err
+	}
+	code.Scale = scale
+	return code.PNG(), nil
+}
+
+// renderQRSVG renders a QR payload as an SVG image with a 4-module quiet zone
+func renderQRSVG(payload string, scale int) ([]byte, error) {
+	code, err := qr.Encode(payload, qr.M)
+	if err != nil {
+		return nil, err
+	}
+
+	const quietZone = 4
+	dim := code.Size + 2*quietZone
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`,
+		dim*scale, dim*scale, dim, dim)
+	fmt.Fprintf(&buf, `<rect width="%d" height="%d" fill="#fff"/><path fill="#000" d="`, dim, dim)
+	for y := 0; y < code.Size; y++ {
+		for x := 0; x < code.Size; x++ {
+			if code.Black(x, y) {
+				fmt.Fprintf(&buf, "M%d %dh1v1h-1z", x+quietZone, y+quietZone)
+			}
+		}
+	}
+	buf.WriteString(`"/></svg>`)
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test PromptPay payloads against hard-coded reference vectors. Each expected payload was
+// checked field by field against the EMVCo/BOT layout and its CRC recomputed with an independent
+// CRC-16/CCITT-FALSE implementation; promptPayFields re-checks the structure below.
+func TestPromptPayPayloadVectors(t *testing.T) {
+	cases := []struct {
+		name       string
+		id         string
+		targetType string
+		amount     float64
+		want       string
+	}{
+		{"Phone Static", "080-123-4567", "", 0,
+			"00020101021129370016A000000677010111011300668012345675802TH530376463046197"},
+		{"Phone Plus66", "+66 80 123 4567", "", 0,
+			"00020101021129370016A000000677010111011300668012345675802TH530376463046197"},
+		{"Phone Amount", "0812345678", "", 1500,
+			"00020101021229370016A000000677010111011300668123456785802TH530376454071500.0063046960"},
+		{"Phone Amount Decimal", "0801234567", PromptPayTargetPhone, 4.22,
+			"00020101021229370016A000000677010111011300668012345675802TH530376454044.22630444FE"},
+		{"National ID Static", "1-1017-00230-70-8", "", 0,
+			"00020101021129370016A000000677010111021311017002307085802TH5303764630437EC"},
+		{"Tax ID Amount", "0105556177553", PromptPayTargetNationalID, 2999.5,
+			"00020101021229370016A000000677010111021301055561775535802TH530376454072999.5063047680"},
+		{"E-Wallet Static", "012345678901234", "", 0,
+			"00020101021129390016A00000067701011103150123456789012345802TH530376463049781"},
+		{"E-Wallet Amount", "140000012345678", PromptPayTargetEWallet, 350,
+			"00020101021229390016A00000067701011103151400000123456785802TH53037645406350.006304BCFC"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			payload, err := generatePromptPayQR(tc.id, tc.targetType, tc.amount)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, payload)
+
+			fields := promptPayFields(t, tc.want)
+			assert.Equal(t, "01", fields["00"])
+			assert.Equal(t, "TH", fields["58"])
+			assert.Equal(t, "764", fields["53"])
+			if tc.amount > 0 {
+				assert.Equal(t, "12", fields["01"])
+				assert.Equal(t, strconv.FormatFloat(tc.amount, 'f', 2, 64), fields["54"])
+			} else {
+				assert.Equal(t, "11", fields["01"])
+				assert.NotContains(t, fields, "54")
+			}
+			assert.True(t, strings.HasPrefix(fields["29"], "0016"+promptPayAID))
+		})
+	}
+
+	t.Run("CRC Check Value", func(t *testing.T) {
+		// CRC-16/CCITT-FALSE standard check value
+		assert.Equal(t, "29B1", calculateCRC16("123456789"))
+	})
+}
+
+// promptPayFields splits a payload into its top-level EMVCo TLV fields and checks that tag 63 is
+// last and matches a bit-by-bit CRC-16/CCITT-FALSE written independently of calculateCRC16
+func promptPayFields(t *testing.T, payload string) map[string]string {
+	fields := map[string]string{}
+	for i := 0; i < len(payload); {
+		if !assert.LessOrEqual(t, i+4, len(payload)) {
+			return fields
+		}
+		tag := payload[i : i+2]
+		n, err := strconv.Atoi(payload[i+2 : i+4])
+		if !assert.NoError(t, err) || !assert.LessOrEqual(t, i+4+n, len(payload)) {
+			return fields
+		}
+		fields[tag] = payload[i+4 : i+4+n]
+		if tag == "63" {
+			assert.Equal(t, len(payload), i+4+n, "CRC must be the last field")
+		}
+		i += 4 + n
+	}
+
+	crc := 0xFFFF
+	for _, c := range []byte(payload[:len(payload)-4]) {
+		for bit := 7; bit >= 0; bit-- {
+			top := (crc>>15)&1 == 1
+			in := (c>>bit)&1 == 1
+			crc = (crc << 1) & 0xFFFF
+			if top != in {
+				crc ^= 0x1021
+			}
+		}
+	}
+	assert.Equal(t, fmt.Sprintf("%04X", crc), fields["63"])
+	return fields
+}
+
+// Test against reference vectors published with the promptpay-qr library
+func TestPromptPayReferencePayloads(t *testing.T) {
+	t.Run("Phone Without Amount", func(t *testing.T) {
+		payload := buildPromptPayPayload(PromptPayTarget{Type: PromptPayTargetPhone, Value: "0066801234567"}, 0)
+		assert.Equal(t, "00020101021129370016A000000677010111011300668012345675802TH530376463046197", payload)
+	})
+
+	t.Run("Phone With Amount", func(t *testing.T) {
+		payload := buildPromptPayPayload(PromptPayTarget{Type: PromptPayTargetPhone, Value: "0066000000000"}, 4.22)
+		assert.Equal(t, "00020101021229370016A000000677010111011300660000000005802TH530376454044.226304E469", payload)
+	})
+
+	t.Run("National ID", func(t *testing.T) {
+		payload := buildPromptPayPayload(PromptPayTarget{Type: PromptPayTargetNationalID, Value: "1111111111111"}, 0)
+		assert.Equal(t, "00020101021129370016A000000677010111021311111111111115802TH530376463047B5A", payload)
+	})
+
+	t.Run("E-Wallet ID", func(t *testing.T) {
+		payload := buildPromptPayPayload(PromptPayTarget{Type: PromptPayTargetEWallet, Value: "012345678901234"}, 0)
+		assert.Equal(t, "00020101021129390016A00000067701011103150123456789012345802TH530376463049781", payload)
+	})
+}
+
+// Test PromptPay ID validation and normalization
+func TestPromptPayTargetParsing(t *testing.T) {
+	t.Run("Normalize Thai Mobile Numbers", func(t *testing.T) {
+		for _, input := range []string{"0812345678", "081-234-5678", "+66812345678", "66 81 234 5678", "0066812345678", "812345678"} {
+			target, err := parsePromptPayTarget(input, "")
+			assert.NoError(t, err, input)
+			assert.Equal(t, PromptPayTargetPhone, target.Type, input)
+			assert.Equal(t, "0066812345678", target.Value, input)
+		}
+	})
+
+	t.Run("Reject Landline Numbers", func(t *testing.T) {
+		_, err := parsePromptPayTarget("021234567", PromptPayTargetPhone)
+		assert.Equal(t, errInvalidPromptPayPhone, err)
+	})
+
+	t.Run("Reject Bad National ID Check Digit", func(t *testing.T) {
+		_, err := parsePromptPayTarget("1101700230709", "")
+		assert.Equal(t, errInvalidPromptPayNationalID, err)
+	})
+
+	t.Run("Reject Short E-Wallet ID", func(t *testing.T) {
+		_, err := parsePromptPayTarget("01234567890123", PromptPayTargetEWallet)
+		assert.Equal(t, errInvalidPromptPayEWallet, err)
+	})
+
+	t.Run("Reject Unknown Format", func(t *testing.T) {
+		_, err := parsePromptPayTarget("12345", "")
+		assert.Equal(t, errUnknownPromptPayTarget, err)
+	})
+}
+
+// Test QR image rendering
+func TestPromptPayQRImage(t *testing.T) {
+	payload := buildPromptPayPayload(PromptPayTarget{Type: PromptPayTargetPhone, Value: "0066812345678"}, 1500)
+
+	t.Run("Render PNG", func(t *testing.T) {
+		png, err := renderQRPNG(payload, 8)
+		assert.NoError(t, err)
+		assert.True(t, bytes.HasPrefix(png, []byte("\x89PNG\r\n\x1a\n")))
+	})
+
+	t.Run("Render SVG", func(t *testing.T) {
+		svg, err := renderQRSVG(payload, 8)
+		assert.NoError(t, err)
+		assert.True(t, bytes.HasPrefix(svg, []byte("<svg")))
+		assert.True(t, bytes.HasSuffix(svg, []byte("</svg>")))
+	})
+}
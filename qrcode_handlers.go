@@ -4,62 +4,17 @@ import (
 	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-// PromptPay QR Code Generator
-// อ้างอิง: EMVCo QR Code Specification for Payment Systems
-func generatePromptPayQR(phoneNumber string, amount float64) string {
-	// Format: 00020101021129370016A000000677010111021[phone]5303764540[amount]6304[checksum]
-
-	// Merchant Account Information (Tag 29)
-	merchantID := fmt.Sprintf("0016A000000677010111%02d%s", len(phoneNumber), phoneNumber)
-	merchantInfo := fmt.Sprintf("29%02d%s", len(merchantID), merchantID)
-
-	// Amount (Tag 54)
-	amountStr := fmt.Sprintf("%.2f", amount)
-	amountTag := fmt.Sprintf("54%02d%s", len(amountStr), amountStr)
-
-	// Country Code (Tag 58)
-	countryCode := "5802TH"
-
-	// Transaction Currency (Tag 53) - THB = 764
-	currencyCode := "5303764"
-
-	// Build QR payload (without checksum)
-	payload := fmt.Sprintf("00020101021130%02d%s%s%s%s6304", len(merchantInfo), merchantInfo, currencyCode, amountTag, countryCode)
-
-	// Calculate CRC16-CCITT checksum
-	checksum := calculateCRC16(payload)
-
-	return payload + checksum
-}
-
-// CRC16-CCITT Checksum calculation for PromptPay QR
-func calculateCRC16(data string) string {
-	crc := uint16(0xFFFF)
-	polynomial := uint16(0x1021)
-
-	for i := 0; i < len(data); i++ {
-		crc ^= uint16(data[i]) << 8
-		for j := 0; j < 8; j++ {
-			if (crc & 0x8000) != 0 {
-				crc = (crc << 1) ^ polynomial
-			} else {
-				crc = crc << 1
-			}
-		}
-	}
-
-	return fmt.Sprintf("%04X", crc&0xFFFF)
-}
-
 // --- POST /bookings/create-with-qr (สร้างการจองพร้อม QR Code PromptPay) ---
 func createBookingWithQRHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -70,13 +25,13 @@ func createBookingWithQRHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.H
 		}
 
 		var req struct {
-			ProviderID   int     `json:"provider_id" binding:"required"`
-			PackageID    int     `json:"package_id" binding:"required"`
-			BookingDate  string  `json:"booking_date" binding:"required"` // YYYY-MM-DD
-			StartTime    string  `json:"start_time" binding:"required"`   // HH:MM
-			Location     *string `json:"location"`
-			SpecialNotes *string `json:"special_notes"`
-			PhoneNumber  string  `json:"phone_number" binding:"required"` // เบอร์โทร PromptPay ของผู้รับเงิน
+			QuoteID       string  `json:"quote_id" binding:"required"` // จาก POST /bookings/quote
+			Location      *string `json:"location"`
+			SpecialNotes  *string `json:"special_notes"`
+			PhoneNumber   string  `json:"phone_number"`   // เบอร์โทร PromptPay ของผู้รับเงิน (legacy)
+			PromptPayID   string  `json:"promptpay_id"`   // เบอร์มือถือ / เลขบัตรประชาชน / เลขผู้เสียภาษี / e-Wallet ID
+			PromptPayType string  `json:"promptpay_type"` // phone, national_id, ewallet (ไม่ระบุ = ตรวจจากจำนวนหลัก)
+			walletPaymentOption
 		}
 
 		if err := c.ShouldBindJSON(&req); err != nil {
@@ -84,58 +39,126 @@ func createBookingWithQRHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.H
 			return
 		}
 
-		// 1. ดึงราคาแพ็คเกจ
-		var packagePrice float64
-		var packageName string
-		err := dbPool.QueryRow(ctx, `
-			SELECT price, package_name FROM service_packages WHERE package_id = $1 AND is_active = true
-		`, req.PackageID).Scan(&packagePrice, &packageName)
+		// ค่าปรับการยกเลิกค้างชำระ → จองใหม่ไม่ได้
+		if !requireNoOutstandingCancellationFees(c, dbPool, ctx, clientID) {
+			return
+		}
 
+		promptPayID := req.PromptPayID
+		if promptPayID == "" {
+			promptPayID = req.PhoneNumber
+		}
+		target, err := parsePromptPayTarget(promptPayID, req.PromptPayType)
 		if err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Package not found or inactive"})
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
 
-		// 2. สร้าง Booking ใน DB (สถานะ pending_payment)
-		var bookingID int
-		err = dbPool.QueryRow(ctx, `
-			INSERT INTO bookings (
-				client_id, provider_id, package_id, booking_date, start_time, 
-				location, special_notes, status, total_price, payment_method
-			)
-			VALUES ($1, $2, $3, $4, $5, $6, $7, 'pending_payment', $8, 'promptpay')
-			RETURNING booking_id
-		`, clientID, req.ProviderID, req.PackageID, req.BookingDate, req.StartTime,
-			req.Location, req.SpecialNotes, packagePrice).Scan(&bookingID)
+		tx, err := dbPool.Begin(ctx)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create booking"})
+			return
+		}
+		defer tx.Rollback(ctx)
+
+		// 1. ใช้ quote (ราคาคำนวณฝั่ง server, ใช้ได้ครั้งเดียว)
+		quote, err := consumePriceQuote(ctx, tx, req.QuoteID, c.GetInt("userID"), QuoteKindBooking, time.Now())
+		if err != nil {
+			respondPricingError(c, err, "Failed to create booking")
+			return
+		}
+		packagePrice := quote.Total
 
+		var packageName string
+		tx.QueryRow(ctx, `SELECT package_name FROM service_packages WHERE package_id = $1`, quote.PackageID).Scan(&packageName)
+
+		// ใช้ยอด wallet ได้บางส่วนหรือทั้งหมด ส่วนที่เหลือสแกน QR
+		fromWallet, remaining, err := planWalletPayment(packagePrice, getWalletSpendableBalance(ctx, dbPool, clientID),
+			req.walletPaymentOption, 0)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create booking", "details": err.Error()})
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
 
-		// 3. สร้าง QR Code PromptPay
-		qrCode := generatePromptPayQR(req.PhoneNumber, packagePrice)
+		// 2. สร้าง Booking ใน DB (สถานะ pending_payment) + ใช้คูปองใน quote
+		paymentMethod := "promptpay"
+		bookingID, err := createBookingFromQuote(ctx, tx, quote, req.Location, req.SpecialNotes, "pending_payment", &paymentMethod)
+		if err != nil {
+			respondPricingError(c, err, "Failed to create booking")
+			return
+		}
 
-		// 4. สร้าง Payment Record
+		// 3. กันเงินส่วนที่จ่ายจาก wallet (ตัดจริงเมื่อยืนยันการชำระเงิน)
+		var walletHoldID *int
+		if fromWallet > 0 {
+			holdID, err := holdWalletFunds(ctx, tx, clientID, fromWallet, &bookingID,
+				fmt.Sprintf("Wallet payment for booking #%d", bookingID))
+			if err != nil {
+				c.JSON(http.StatusPaymentRequired, gin.H{"error": "Failed to reserve wallet balance", "details": err.Error()})
+				return
+			}
+			walletHoldID = &holdID
+		}
+
+		// 4. สร้าง QR Code PromptPay (เฉพาะส่วนที่เหลือ)
+		var qrCode *string
+		if remaining > 0 {
+			payload := buildPromptPayPayload(target, remaining)
+			qrCode = &payload
+		}
+
+		// 5. สร้าง Payment Record
 		paymentReference := generatePaymentReference(bookingID)
-		_, err = dbPool.Exec(ctx, `
+		var paymentID int
+		err = tx.QueryRow(ctx, `
 			INSERT INTO payments (
 				booking_id, amount, payment_method, payment_status, 
-				payment_reference, qr_code, expires_at
+				payment_reference, qr_code, expires_at, wallet_hold_id, wallet_amount
 			)
-			VALUES ($1, $2, 'promptpay', 'pending', $3, $4, $5)
-		`, bookingID, packagePrice, paymentReference, qrCode, time.Now().Add(15*time.Minute))
+			VALUES ($1, $2, $3, 'pending', $4, $5, $6, $7, $8)
+			RETURNING payment_id
+		`, bookingID, remaining, walletPaymentMethod(fromWallet, remaining, "promptpay"), paymentReference, qrCode,
+			time.Now().Add(15*time.Minute), walletHoldID, fromWallet).Scan(&paymentID)
 
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create payment record"})
 			return
 		}
+		if err := tx.Commit(ctx); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create booking"})
+			return
+		}
+
+		// wallet ครอบคลุมทั้งหมด → ยืนยันเลยไม่ต้องสแกน
+		if remaining == 0 {
+			result, err := completePromptPayPayment(dbPool, ctx, paymentID, "", nil)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to confirm payment"})
+				return
+			}
+			c.JSON(http.StatusCreated, gin.H{
+				"booking_id":        bookingID,
+				"quote_id":          quote.QuoteID,
+				"amount":            packagePrice,
+				"wallet_paid":       fromWallet,
+				"payment_reference": paymentReference,
+				"package_name":      packageName,
+				"net_amount":        result.NetAmount,
+				"message":           "Booking paid from wallet",
+			})
+			return
+		}
 
 		c.JSON(http.StatusCreated, gin.H{
 			"booking_id":        bookingID,
-			"qr_code":           qrCode,
+			"quote_id":          quote.QuoteID,
+			"qr_code":           *qrCode,
 			"amount":            packagePrice,
+			"wallet_paid":       fromWallet,
+			"qr_amount":         remaining,
 			"payment_reference": paymentReference,
+			"qr_image_url":      "/payments/" + paymentReference + "/qr.png",
+			"promptpay_type":    target.Type,
 			"expires_at":        time.Now().Add(15 * time.Minute).Format(time.RFC3339),
 			"package_name":      packageName,
 			"message":           "Scan QR code to pay within 15 minutes",
@@ -143,11 +166,19 @@ func createBookingWithQRHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.H
 	}
 }
 
-// --- POST /payments/:payment_reference/confirm (ยืนยันการชำระเงินแบบแมนนวล) ---
+// --- POST /payments/:payment_reference/confirm (admin ยืนยันการชำระเงินแบบแมนนวล) ---
+// ผู้ใช้ทั่วไปยืนยันได้ทางการอัปโหลดสลิปเท่านั้น (ตรวจอัตโนมัติ หรือเข้าคิวให้ admin ตรวจ)
 func confirmPaymentHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		paymentRef := c.Param("payment_reference")
 
+		var isAdmin bool
+		dbPool.QueryRow(ctx, `SELECT COALESCE(is_admin, false) FROM users WHERE user_id = $1`, c.GetInt("userID")).Scan(&isAdmin)
+		if !isAdmin {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Payments are confirmed by uploading the transfer slip"})
+			return
+		}
+
 		var req struct {
 			TransactionID string  `json:"transaction_id"` // เลข Ref จากธนาคาร (optional)
 			SlipImage     *string `json:"slip_image"`     // URL รูปสลิป (optional)
@@ -158,88 +189,198 @@ func confirmPaymentHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.Handle
 			return
 		}
 
-		// 1. ค้นหา Payment และ Booking
-		var bookingID int
+		// 1. ค้นหา Payment
 		var paymentID int
-		var amount float64
-		var providerID int
-
 		err := dbPool.QueryRow(ctx, `
-			SELECT p.payment_id, p.booking_id, p.amount, b.provider_id
-			FROM payments p
-			JOIN bookings b ON p.booking_id = b.booking_id
-			WHERE p.payment_reference = $1 AND p.payment_status = 'pending'
-		`, paymentRef).Scan(&paymentID, &bookingID, &amount, &providerID)
+			SELECT payment_id FROM payments
+			WHERE payment_reference = $1 AND payment_status = 'pending'
+		`, paymentRef).Scan(&paymentID)
 
 		if err != nil {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Payment not found or already completed"})
 			return
 		}
 
-		// 2. อัพเดทสถานะ Payment
-		_, err = dbPool.Exec(ctx, `
-			UPDATE payments 
-			SET payment_status = 'completed', 
-			    transaction_id = $1,
-			    slip_image = $2,
-			    paid_at = NOW()
-			WHERE payment_id = $3
-		`, req.TransactionID, req.SlipImage, paymentID)
-
+		// 2. ยืนยัน Payment + Booking + เพิ่มเงินเข้า Wallet ของ Provider
+		result, err := completePromptPayPayment(dbPool, ctx, paymentID, req.TransactionID, req.SlipImage)
+		if err == errPaymentNotPending {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Payment not found or already completed"})
+			return
+		}
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update payment"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to confirm payment"})
 			return
 		}
 
-		// 3. อัพเดทสถานะ Booking เป็น confirmed
-		_, err = dbPool.Exec(ctx, `
-			UPDATE bookings 
-			SET status = 'confirmed', payment_status = 'paid'
-			WHERE booking_id = $1
-		`, bookingID)
+		if result.Topup != nil {
+			c.JSON(http.StatusOK, gin.H{"message": "Top-up confirmed successfully", "topup": result.Topup})
+			return
+		}
+		if result.Tip != nil {
+			c.JSON(http.StatusOK, gin.H{"message": "Tip confirmed successfully", "tip": result.Tip})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":     "Payment confirmed successfully",
+			"booking_id":  result.BookingID,
+			"amount_paid": result.Amount,
+			"net_amount":  result.NetAmount,
+			"commission":  result.Commission,
+		})
+	}
+}
 
+var errPaymentNotPending = errors.New("payment not found or already completed")
+
+// promptPayConfirmation is the outcome of completing a PromptPay payment
+type promptPayConfirmation struct {
+	BookingID    int
+	ProviderID   int
+	Amount       float64
+	WalletAmount float64 // ส่วนที่จ่ายจาก wallet (รวมอยู่ใน Amount แล้ว)
+	NetAmount    float64
+	Commission   float64
+	Topup        *WalletTopup // payment นี้เป็นการเติมเงิน wallet (ไม่มี booking)
+	Tip          *BookingTip  // payment นี้เป็นทิปหลังจบงาน
+}
+
+// completePromptPayPayment marks a pending PromptPay payment as paid, confirms the
+// booking and credits the provider's pending balance (หักค่าธรรมเนียม 12.75%) in one transaction.
+// Used by both manual confirmation and automatic slip matching. Top-up and tip payments credit
+// the client's or provider's wallet instead.
+func completePromptPayPayment(dbPool *pgxpool.Pool, ctx context.Context, paymentID int, transactionID string, slipImage *string) (*promptPayConfirmation, error) {
+	tx, err := dbPool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	result := &promptPayConfirmation{}
+
+	// 1. อัพเดทสถานะ Payment (เฉพาะที่ยัง pending กันยืนยันซ้ำ)
+	var bookingID, topupID, tipID, walletHoldID *int
+	err = tx.QueryRow(ctx, `
+		UPDATE payments
+		SET payment_status = 'completed',
+		    transaction_id = $1,
+		    slip_image = COALESCE($2, slip_image),
+		    paid_at = NOW(),
+		    updated_at = NOW()
+		WHERE payment_id = $3 AND payment_status = 'pending'
+		RETURNING booking_id, topup_id, tip_id, wallet_hold_id, amount
+	`, transactionID, slipImage, paymentID).Scan(&bookingID, &topupID, &tipID, &walletHoldID, &result.Amount)
+	if err == pgx.ErrNoRows {
+		return nil, errPaymentNotPending
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// เติมเงิน wallet
+	if topupID != nil {
+		topup, credited, err := completeWalletTopup(ctx, tx, *topupID)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update booking"})
-			return
+			return nil, fmt.Errorf("failed to complete top-up: %w", err)
 		}
+		if err := tx.Commit(ctx); err != nil {
+			return nil, err
+		}
+		if credited {
+			notifyWalletTopup(topup)
+		}
+		result.Topup = topup
+		return result, nil
+	}
 
-		// 4. เพิ่มเงินเข้า Wallet ของ Provider (หักค่าคอมฯ 12.75%)
-		commission := amount * 0.1275
-		netAmount := amount - commission
+	// ทิป provider
+	if tipID != nil {
+		tip, credited, err := completeBookingTip(ctx, tx, *tipID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to complete tip: %w", err)
+		}
+		if err := tx.Commit(ctx); err != nil {
+			return nil, err
+		}
+		if credited {
+			notifyTipReceived(tip)
+		}
+		result.Tip = tip
+		result.BookingID = tip.BookingID
+		return result, nil
+	}
+	if bookingID == nil {
+		return nil, fmt.Errorf("payment %d has no booking", paymentID)
+	}
+	result.BookingID = *bookingID
 
-		_, err = dbPool.Exec(ctx, `
-			INSERT INTO wallets (user_id, balance)
-			VALUES ($1, 0)
-			ON CONFLICT (user_id) DO NOTHING
-		`, providerID)
+	// ตัดเงินส่วนที่กันไว้จาก wallet
+	if walletHoldID != nil {
+		result.WalletAmount, err = captureWalletHold(ctx, tx, *walletHoldID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to capture wallet hold: %w", err)
+		}
+		result.Amount += result.WalletAmount
+	}
 
-		_, err = dbPool.Exec(ctx, `
-			UPDATE wallets 
-			SET balance = balance + $1
-			WHERE user_id = $2
-		`, netAmount, providerID)
+	// 2. อัพเดทสถานะ Booking เป็น confirmed
+	err = tx.QueryRow(ctx, `
+		UPDATE bookings
+		SET status = 'confirmed', payment_status = 'paid', updated_at = NOW()
+		WHERE booking_id = $1
+		RETURNING provider_id
+	`, result.BookingID).Scan(&result.ProviderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update booking: %w", err)
+	}
 
-		// 5. บันทึก Transaction
-		_, err = dbPool.Exec(ctx, `
-			INSERT INTO transactions (
-				user_id, transaction_type, amount, booking_id, 
-				description, balance_after
-			)
-			VALUES (
-				$1, 'booking_payment', $2, $3, 
-				'Payment from booking', 
-				(SELECT balance FROM wallets WHERE user_id = $1)
-			)
-		`, providerID, netAmount, bookingID)
+	// 3. คำนวณค่าธรรมเนียมตามอัตรา ณ เวลาที่จอง (Platform 10% + Payment Gateway 2.75% เฉพาะส่วนที่จ่ายผ่าน PromptPay)
+	platformRate, gatewayRate := bookingCommissionRates(ctx, tx, result.BookingID)
+	gatewayFee, platformCommission, netAmount, feeRate := bookingPaymentFees(result.Amount, result.WalletAmount, platformRate, gatewayRate)
+	result.Commission = roundSatang(gatewayFee + platformCommission)
+	result.NetAmount = netAmount
+
+	// 4. บันทึก Transaction
+	_, err = tx.Exec(ctx, `
+		INSERT INTO transactions (
+			user_id, type, amount, status, booking_id,
+			stripe_fee, platform_commission, total_fee_percentage, net_amount
+		)
+		VALUES ($1, 'booking_payment', $2, 'completed', $3, $4, $5, $7, $6)
+	`, result.ProviderID, result.Amount, result.BookingID, gatewayFee, platformCommission, result.NetAmount, feeRate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create transaction: %w", err)
+	}
 
-		c.JSON(http.StatusOK, gin.H{
-			"message":     "Payment confirmed successfully",
-			"booking_id":  bookingID,
-			"amount_paid": amount,
-			"net_amount":  netAmount,
-			"commission":  commission,
-		})
+	// 5. เพิ่มเงินเข้า Wallet ของ Provider (pending_balance - รอ 7 วันเหมือน Stripe)
+	_, err = tx.Exec(ctx, `
+		INSERT INTO wallets (user_id, available_balance, pending_balance, total_earned)
+		VALUES ($1, 0, $2, $2)
+		ON CONFLICT (user_id)
+		DO UPDATE SET
+			pending_balance = wallets.pending_balance + $2,
+			total_earned = wallets.total_earned + $2,
+			updated_at = NOW()
+	`, result.ProviderID, result.NetAmount)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update provider wallet: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
 	}
+
+	CreateNotification(result.ProviderID, "payment_success",
+		fmt.Sprintf("PromptPay payment received for booking #%d: ฿%.2f (net: ฿%.2f)", result.BookingID, result.Amount, result.NetAmount),
+		map[string]interface{}{
+			"booking_id": result.BookingID,
+			"amount":     result.Amount,
+			"net_amount": result.NetAmount,
+		})
+
+	issueBookingReceiptQuietly(ctx, dbPool, result.BookingID)
+
+	return result, nil
 }
 
 // --- GET /payments/:payment_reference/status (ตรวจสอบสถานะการชำระเงิน) ---
@@ -249,20 +390,23 @@ func checkPaymentStatusHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.Ha
 
 		var payment struct {
 			PaymentID     int        `json:"payment_id"`
-			BookingID     int        `json:"booking_id"`
+			BookingID     *int       `json:"booking_id"`
+			TopupID       *int       `json:"topup_id,omitempty"`
+			TipID         *int       `json:"tip_id,omitempty"`
 			Amount        float64    `json:"amount"`
+			WalletAmount  float64    `json:"wallet_amount"`
 			PaymentStatus string     `json:"payment_status"`
-			QRCode        string     `json:"qr_code"`
+			QRCode        *string    `json:"qr_code"`
 			ExpiresAt     time.Time  `json:"expires_at"`
 			PaidAt        *time.Time `json:"paid_at"`
 		}
 
 		err := dbPool.QueryRow(ctx, `
-			SELECT payment_id, booking_id, amount, payment_status, qr_code, expires_at, paid_at
+			SELECT payment_id, booking_id, topup_id, tip_id, amount, COALESCE(wallet_amount, 0), payment_status, qr_code, expires_at, paid_at
 			FROM payments
 			WHERE payment_reference = $1
 		`, paymentRef).Scan(
-			&payment.PaymentID, &payment.BookingID, &payment.Amount,
+			&payment.PaymentID, &payment.BookingID, &payment.TopupID, &payment.TipID, &payment.Amount, &payment.WalletAmount,
 			&payment.PaymentStatus, &payment.QRCode, &payment.ExpiresAt, &payment.PaidAt,
 		)
 
@@ -274,10 +418,8 @@ func checkPaymentStatusHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.Ha
 		// ตรวจสอบว่าหมดอายุหรือยัง
 		isExpired := time.Now().After(payment.ExpiresAt) && payment.PaymentStatus == "pending"
 		if isExpired {
-			// อัพเดทสถานะเป็น expired
-			dbPool.Exec(ctx, `
-				UPDATE payments SET payment_status = 'expired' WHERE payment_id = $1
-			`, payment.PaymentID)
+			// อัพเดทสถานะเป็น expired (คืนเงิน wallet ที่กันไว้ด้วย)
+			expireStalePayments(ctx, dbPool, payment.PaymentID)
 			payment.PaymentStatus = "expired"
 		}
 
@@ -285,6 +427,53 @@ func checkPaymentStatusHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.Ha
 	}
 }
 
+// --- GET /payments/:payment_reference/qr.png และ /qr.svg (รูป QR Code สำหรับสแกนจ่าย) ---
+func getPaymentQRImageHandler(dbPool *pgxpool.Pool, ctx context.Context, format string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		paymentRef := c.Param("payment_reference")
+		userID, _ := c.Get("userID")
+
+		var qrCode *string
+		err := dbPool.QueryRow(ctx, `
+			SELECT p.qr_code
+			FROM payments p
+			LEFT JOIN bookings b ON p.booking_id = b.booking_id
+			LEFT JOIN wallet_topups wt ON p.topup_id = wt.topup_id
+			LEFT JOIN booking_tips bt ON p.tip_id = bt.tip_id
+			WHERE p.payment_reference = $1 AND (b.client_id = $2 OR b.provider_id = $2 OR wt.user_id = $2 OR bt.client_id = $2)
+		`, paymentRef, userID).Scan(&qrCode)
+
+		if err != nil || qrCode == nil || *qrCode == "" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "QR code not found for this payment"})
+			return
+		}
+
+		// ขนาด: จำนวน pixel ต่อ 1 module (default 8, สูงสุด 20)
+		scale := 8
+		if s, err := strconv.Atoi(c.Query("scale")); err == nil && s >= 1 && s <= 20 {
+			scale = s
+		}
+
+		var image []byte
+		contentType := "image/png"
+		if format == "svg" {
+			contentType = "image/svg+xml"
+			image, err = renderQRSVG(*qrCode, scale)
+		} else {
+			image, err = renderQRPNG(*qrCode, scale)
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render QR code"})
+			return
+		}
+
+		// Override the global JSON Content-Type header
+		c.Header("Content-Type", contentType)
+		c.Header("Cache-Control", "private, max-age=900")
+		c.Data(http.StatusOK, contentType, image)
+	}
+}
+
 // สร้าง Payment Reference (unique)
 func generatePaymentReference(bookingID int) string {
 	timestamp := time.Now().Unix()
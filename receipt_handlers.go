@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// --- GET /bookings/:id/receipt?type=booking|deposit (ใบเสร็จ PDF ของการจอง) ---
+func getBookingReceiptHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := c.Get("userID")
+		bookingID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid booking ID"})
+			return
+		}
+
+		var clientID, providerID int
+		err = dbPool.QueryRow(ctx, `SELECT client_id, provider_id FROM bookings WHERE booking_id = $1`, bookingID).
+			Scan(&clientID, &providerID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Booking not found"})
+			return
+		}
+		if userID != clientID && userID != providerID {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+			return
+		}
+
+		var receipt *Receipt
+		switch c.DefaultQuery("type", ReceiptSourceBooking) {
+		case ReceiptSourceBooking:
+			receipt, err = issueBookingReceipt(ctx, dbPool, bookingID)
+		case ReceiptSourceDeposit:
+			var depositID int
+			if err = dbPool.QueryRow(ctx, `SELECT deposit_id FROM booking_deposits WHERE booking_id = $1`, bookingID).Scan(&depositID); err == nil {
+				receipt, err = issueDepositReceipt(ctx, dbPool, depositID)
+			}
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "type must be booking or deposit"})
+			return
+		}
+		if errors.Is(err, errBookingNotPaid) || errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "No payment found for this booking"})
+			return
+		} else if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to issue receipt", "details": err.Error()})
+			return
+		}
+
+		sendReceiptPDF(c, receipt)
+	}
+}
+
+func sendReceiptPDF(c *gin.Context, receipt *Receipt) {
+	pdf := buildReceiptPDF(*receipt, receiptIssuerFromEnv())
+	c.Header("Content-Type", "application/pdf")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.pdf"`, receipt.ReceiptNo))
+	c.Data(http.StatusOK, "application/pdf", pdf)
+}
+
+// --- GET /receipts (ใบเสร็จทั้งหมดของฉัน) ---
+func getMyReceiptsHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := c.Get("userID")
+
+		rows, err := dbPool.Query(ctx, `SELECT `+receiptColumns+receiptJoins+`
+			WHERE r.buyer_id = $1
+			ORDER BY r.issued_at DESC
+			LIMIT 200`, userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch receipts"})
+			return
+		}
+		defer rows.Close()
+
+		receipts := make([]Receipt, 0)
+		for rows.Next() {
+			r, err := scanReceipt(rows)
+			if err != nil {
+				continue
+			}
+			receipts = append(receipts, *r)
+		}
+
+		c.JSON(http.StatusOK, gin.H{"receipts": receipts, "total": len(receipts)})
+	}
+}
+
+// loadOwnReceipt fetches a receipt the current user bought; writes the error response itself
+func loadOwnReceipt(c *gin.Context, dbPool *pgxpool.Pool, ctx context.Context) (*Receipt, bool) {
+	userID, _ := c.Get("userID")
+	receipt, err := scanReceipt(dbPool.QueryRow(ctx, `SELECT `+receiptColumns+receiptJoins+`
+		WHERE r.receipt_id = $1`, c.Param("receipt_id")))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Receipt not found"})
+		return nil, false
+	}
+	if receipt.BuyerID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return nil, false
+	}
+	return receipt, true
+}
+
+// --- GET /receipts/:receipt_id/pdf ---
+func getReceiptPDFHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		receipt, ok := loadOwnReceipt(c, dbPool, ctx)
+		if !ok {
+			return
+		}
+		sendReceiptPDF(c, receipt)
+	}
+}
+
+// --- POST /receipts/:receipt_id/email (ส่งใบเสร็จทางอีเมล) ---
+func emailReceiptHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		receipt, ok := loadOwnReceipt(c, dbPool, ctx)
+		if !ok {
+			return
+		}
+
+		var req struct {
+			Email string `json:"email" binding:"omitempty,email"` // ว่าง = อีเมลของบัญชี
+		}
+		if err := c.ShouldBindJSON(&req); err != nil && err.Error() != "EOF" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		to := req.Email
+		if to == "" {
+			to = receipt.BuyerEmail
+		}
+
+		pdf := buildReceiptPDF(*receipt, receiptIssuerFromEnv())
+		if err := sendReceiptEmail(to, receipt, pdf); err != nil {
+			c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to send receipt email"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Receipt sent", "receipt_no": receipt.ReceiptNo, "email": to})
+	}
+}
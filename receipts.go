@@ -0,0 +1,501 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"gopkg.in/gomail.v2"
+)
+
+// ================================
+// Client Receipts / Tax Invoices (ใบเสร็จรับเงิน/ใบกำกับภาษี)
+// ================================
+
+// Receipt source types (receipts.source_type)
+const (
+	ReceiptSourceBooking   = "booking"
+	ReceiptSourceExtension = "extension"
+	ReceiptSourceDeposit   = "deposit"
+	ReceiptSourceBoost     = "boost"
+	ReceiptSourceGallery   = "gallery"
+)
+
+var errBookingNotPaid = errors.New("booking has not been paid")
+
+// defaultVATRate is Thai VAT; prices shown to clients are VAT-inclusive
+const defaultVATRate = 0.07
+
+// Receipt is an issued receipt / tax invoice
+type Receipt struct {
+	ReceiptID      int       `json:"receipt_id"`
+	ReceiptNo      string    `json:"receipt_no"`
+	FiscalYear     int       `json:"fiscal_year"`
+	SourceType     string    `json:"source_type"`
+	SourceRef      string    `json:"source_ref"`
+	BookingID      *int      `json:"booking_id"`
+	BuyerID        int       `json:"buyer_id"`
+	BuyerName      string    `json:"buyer_name"`
+	BuyerEmail     string    `json:"buyer_email"`
+	ProviderID     *int      `json:"provider_id"`
+	ProviderName   string    `json:"provider_name"`
+	Description    string    `json:"description"`
+	Subtotal       float64   `json:"subtotal"`        // ราคาก่อนส่วนลด (รวม VAT)
+	DiscountAmount float64   `json:"discount_amount"` // ส่วนลดคูปอง
+	CouponCode     string    `json:"coupon_code,omitempty"`
+	VATRate        float64   `json:"vat_rate"`
+	VATBase        float64   `json:"vat_base"`     // มูลค่าก่อน VAT
+	VATAmount      float64   `json:"vat_amount"`   // ภาษีมูลค่าเพิ่ม
+	TotalAmount    float64   `json:"total_amount"` // ยอดชำระจริง
+	PaymentMethod  string    `json:"payment_method"`
+	PaidAt         time.Time `json:"paid_at"`
+	IssuedAt       time.Time `json:"issued_at"`
+}
+
+// receiptSource is a payment that needs a receipt
+type receiptSource struct {
+	SourceType    string
+	SourceRef     string // unique per source type (booking_id, deposit_id, ...)
+	BookingID     *int
+	BuyerID       int
+	ProviderID    *int
+	Description   string
+	AmountPaid    float64
+	Discount      float64
+	CouponCode    string
+	PaymentMethod string
+	PaidAt        time.Time
+}
+
+// receiptAmounts is the VAT breakdown of a VAT-inclusive amount paid
+type receiptAmounts struct {
+	Subtotal float64
+	Discount float64
+	Total    float64
+	VATBase  float64
+	VAT      float64
+}
+
+func computeReceiptAmounts(amountPaid, discount, vatRate float64) receiptAmounts {
+	round := func(v float64) float64 { return math.Round(v*100) / 100 }
+	total := round(amountPaid)
+	base := round(total / (1 + vatRate))
+	return receiptAmounts{
+		Subtotal: round(total + discount),
+		Discount: round(discount),
+		Total:    total,
+		VATBase:  base,
+		VAT:      round(total - base),
+	}
+}
+
+// receiptVATRate reads VAT_RATE (e.g. "0.07"), defaulting to 7%
+func receiptVATRate() float64 {
+	if v, err := strconv.ParseFloat(os.Getenv("VAT_RATE"), 64); err == nil && v >= 0 && v < 1 {
+		return v
+	}
+	return defaultVATRate
+}
+
+// fiscalYearStartMonth reads FISCAL_YEAR_START_MONTH (1-12, default January)
+func fiscalYearStartMonth() time.Month {
+	if m, err := strconv.Atoi(os.Getenv("FISCAL_YEAR_START_MONTH")); err == nil && m >= 1 && m <= 12 {
+		return time.Month(m)
+	}
+	return time.January
+}
+
+// fiscalYearOf labels a fiscal year by the calendar year in which it ends (Bangkok time)
+func fiscalYearOf(t time.Time, startMonth time.Month) int {
+	local := t.In(bangkokLocation)
+	if startMonth == time.January || local.Month() < startMonth {
+		return local.Year()
+	}
+	return local.Year() + 1
+}
+
+func receiptNumber(fiscalYear, sequence int) string {
+	return fmt.Sprintf("RC%d-%06d", fiscalYear, sequence)
+}
+
+const receiptColumns = `
+	r.receipt_id, r.receipt_no, r.fiscal_year, r.source_type, r.source_ref, r.booking_id,
+	r.buyer_id, COALESCE(NULLIF(TRIM(CONCAT(bu.first_name, ' ', bu.last_name)), ''), bu.username), bu.email,
+	r.provider_id, COALESCE(NULLIF(TRIM(CONCAT(pu.first_name, ' ', pu.last_name)), ''), pu.username, ''),
+	r.description, r.subtotal, r.discount_amount, COALESCE(r.coupon_code, ''), r.vat_rate, r.vat_base,
+	r.vat_amount, r.total_amount, r.payment_method, r.paid_at, r.issued_at
+`
+
+const receiptJoins = `
+	FROM receipts r
+	JOIN users bu ON bu.user_id = r.buyer_id
+	LEFT JOIN users pu ON pu.user_id = r.provider_id
+`
+
+func scanReceipt(row pgx.Row) (*Receipt, error) {
+	var r Receipt
+	err := row.Scan(&r.ReceiptID, &r.ReceiptNo, &r.FiscalYear, &r.SourceType, &r.SourceRef, &r.BookingID,
+		&r.BuyerID, &r.BuyerName, &r.BuyerEmail, &r.ProviderID, &r.ProviderName,
+		&r.Description, &r.Subtotal, &r.DiscountAmount, &r.CouponCode, &r.VATRate, &r.VATBase,
+		&r.VATAmount, &r.TotalAmount, &r.PaymentMethod, &r.PaidAt, &r.IssuedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// getReceiptBySource returns the receipt already issued for a payment, if any
+func getReceiptBySource(ctx context.Context, dbPool *pgxpool.Pool, sourceType, sourceRef string) (*Receipt, error) {
+	return scanReceipt(dbPool.QueryRow(ctx, `SELECT `+receiptColumns+receiptJoins+`
+		WHERE r.source_type = $1 AND r.source_ref = $2`, sourceType, sourceRef))
+}
+
+// issueReceipt assigns the next number in the fiscal year and stores the receipt.
+// Issuing twice for the same source returns the existing receipt.
+func issueReceipt(ctx context.Context, dbPool *pgxpool.Pool, src receiptSource) (*Receipt, error) {
+	if existing, err := getReceiptBySource(ctx, dbPool, src.SourceType, src.SourceRef); err == nil {
+		return existing, nil
+	}
+
+	tx, err := dbPool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	fiscalYear := fiscalYearOf(src.PaidAt, fiscalYearStartMonth())
+
+	// เลขที่เอกสารต้องต่อเนื่อง ไม่ข้ามเลข → ล็อกต่อปีบัญชี
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock(7300, $1)`, fiscalYear); err != nil {
+		return nil, err
+	}
+
+	var exists bool
+	if err := tx.QueryRow(ctx, `
+		SELECT EXISTS(SELECT 1 FROM receipts WHERE source_type = $1 AND source_ref = $2)
+	`, src.SourceType, src.SourceRef).Scan(&exists); err != nil {
+		return nil, err
+	}
+
+	if !exists {
+		var sequence int
+		if err := tx.QueryRow(ctx, `
+			SELECT COALESCE(MAX(sequence_no), 0) + 1 FROM receipts WHERE fiscal_year = $1
+		`, fiscalYear).Scan(&sequence); err != nil {
+			return nil, err
+		}
+
+		vatRate := receiptVATRate()
+		amounts := computeReceiptAmounts(src.AmountPaid, src.Discount, vatRate)
+		_, err = tx.Exec(ctx, `
+			INSERT INTO receipts (
+				receipt_no, fiscal_year, sequence_no, source_type, source_ref, booking_id,
+				buyer_id, provider_id, description, subtotal, discount_amount, coupon_code,
+				vat_rate, vat_base, vat_amount, total_amount, payment_method, paid_at
+			) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, NULLIF($12, ''), $13, $14, $15, $16, $17, $18)
+		`, receiptNumber(fiscalYear, sequence), fiscalYear, sequence, src.SourceType, src.SourceRef, src.BookingID,
+			src.BuyerID, src.ProviderID, src.Description, amounts.Subtotal, amounts.Discount, src.CouponCode,
+			vatRate, amounts.VATBase, amounts.VAT, amounts.Total, src.PaymentMethod, src.PaidAt)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return getReceiptBySource(ctx, dbPool, src.SourceType, src.SourceRef)
+}
+
+// issueReceiptQuietly is used from payment flows: a receipt failure must not fail the payment
+func issueReceiptQuietly(ctx context.Context, dbPool *pgxpool.Pool, src receiptSource) {
+	if _, err := issueReceipt(ctx, dbPool, src); err != nil {
+		logReceiptFailure(src.SourceType, src.SourceRef, err)
+	}
+}
+
+// issueBookingReceiptQuietly is issueReceiptQuietly for a paid booking
+func issueBookingReceiptQuietly(ctx context.Context, dbPool *pgxpool.Pool, bookingID int) {
+	src, err := bookingReceiptSource(ctx, dbPool, bookingID)
+	if err != nil {
+		logReceiptFailure(ReceiptSourceBooking, strconv.Itoa(bookingID), err)
+		return
+	}
+	issueReceiptQuietly(ctx, dbPool, *src)
+}
+
+func logReceiptFailure(sourceType, sourceRef string, err error) {
+	log.Printf("⚠️  Failed to issue %s receipt %s: %v", sourceType, sourceRef, err)
+}
+
+// bookingReceiptSource builds the receipt source for a paid booking
+// (amount from the booking_payment ledger line, coupon from coupon_usages)
+func bookingReceiptSource(ctx context.Context, dbPool *pgxpool.Pool, bookingID int) (*receiptSource, error) {
+	var (
+		clientID, providerID int
+		packageName          string
+		startTime            time.Time
+		totalPrice           float64
+		amountPaid           *float64
+		paidAt               *time.Time
+		paymentMethod        string
+	)
+	err := dbPool.QueryRow(ctx, `
+		SELECT b.client_id, b.provider_id, COALESCE(bs.package_name, sp.package_name, 'Service'), b.start_time, b.total_price,
+		       t.amount, t.created_at,
+		       CASE WHEN p.payment_id IS NOT NULL THEN p.payment_method
+		            WHEN w.wallet_paid >= t.amount THEN 'wallet'
+		            WHEN w.wallet_paid > 0 THEN 'wallet+card'
+		            ELSE 'card' END
+		FROM bookings b
+		LEFT JOIN service_packages sp ON sp.package_id = b.package_id
+		LEFT JOIN booking_snapshots bs ON bs.booking_id = b.booking_id
+		LEFT JOIN LATERAL (
+			SELECT amount, created_at FROM transactions
+			WHERE booking_id = b.booking_id AND type = 'booking_payment' AND status = 'completed'
+			ORDER BY created_at ASC LIMIT 1
+		) t ON true
+		LEFT JOIN LATERAL (
+			SELECT payment_id, payment_method FROM payments
+			WHERE booking_id = b.booking_id AND payment_status = 'completed'
+			LIMIT 1
+		) p ON true
+		LEFT JOIN LATERAL (
+			SELECT COALESCE(SUM(amount), 0) AS wallet_paid FROM transactions
+			WHERE booking_id = b.booking_id AND user_id = b.client_id
+			  AND type::text = 'wallet_payment' AND status::text = 'completed'
+		) w ON true
+		WHERE b.booking_id = $1
+	`, bookingID).Scan(&clientID, &providerID, &packageName, &startTime, &totalPrice, &amountPaid, &paidAt, &paymentMethod)
+	if err != nil {
+		return nil, err
+	}
+	if amountPaid == nil {
+		return nil, errBookingNotPaid
+	}
+
+	src := &receiptSource{
+		SourceType:    ReceiptSourceBooking,
+		SourceRef:     strconv.Itoa(bookingID),
+		BookingID:     &bookingID,
+		BuyerID:       clientID,
+		ProviderID:    &providerID,
+		Description:   fmt.Sprintf("Booking #%d - %s on %s", bookingID, packageName, startTime.In(bangkokLocation).Format("2 Jan 2006 15:04")),
+		AmountPaid:    *amountPaid,
+		PaymentMethod: paymentMethod,
+		PaidAt:        *paidAt,
+	}
+
+	var couponCode string
+	var discount float64
+	if err := dbPool.QueryRow(ctx, `
+		SELECT COALESCE(string_agg(c.code, ', ' ORDER BY cu.used_at), ''), COALESCE(SUM(cu.discount_amount), 0)
+		FROM coupon_usages cu JOIN coupons c ON c.coupon_id = cu.coupon_id
+		WHERE cu.booking_id = $1 AND cu.status = 'applied'
+	`, bookingID).Scan(&couponCode, &discount); err == nil && discount > 0 {
+		src.CouponCode = couponCode
+		src.Discount = discount
+	}
+	return src, nil
+}
+
+// issueBookingReceipt issues (or returns) the receipt for a paid booking
+func issueBookingReceipt(ctx context.Context, dbPool *pgxpool.Pool, bookingID int) (*Receipt, error) {
+	if existing, err := getReceiptBySource(ctx, dbPool, ReceiptSourceBooking, strconv.Itoa(bookingID)); err == nil {
+		return existing, nil
+	}
+	src, err := bookingReceiptSource(ctx, dbPool, bookingID)
+	if err != nil {
+		return nil, err
+	}
+	return issueReceipt(ctx, dbPool, *src)
+}
+
+// issueDepositReceipt issues (or returns) the receipt for a paid booking deposit
+func issueDepositReceipt(ctx context.Context, dbPool *pgxpool.Pool, depositID int) (*Receipt, error) {
+	var bookingID, clientID, providerID int
+	var amount float64
+	var paidAt *time.Time
+	err := dbPool.QueryRow(ctx, `
+		SELECT booking_id, client_id, provider_id, amount, paid_at
+		FROM booking_deposits WHERE deposit_id = $1 AND status IN ('paid', 'forfeited')
+	`, depositID).Scan(&bookingID, &clientID, &providerID, &amount, &paidAt)
+	if err != nil {
+		return nil, err
+	}
+	if paidAt == nil {
+		return nil, errBookingNotPaid
+	}
+	return issueReceipt(ctx, dbPool, receiptSource{
+		SourceType:    ReceiptSourceDeposit,
+		SourceRef:     strconv.Itoa(depositID),
+		BookingID:     &bookingID,
+		BuyerID:       clientID,
+		ProviderID:    &providerID,
+		Description:   fmt.Sprintf("Deposit for booking #%d", bookingID),
+		AmountPaid:    amount,
+		PaymentMethod: "card",
+		PaidAt:        *paidAt,
+	})
+}
+
+// --- PDF & Email ---
+
+// receiptIssuer is the platform's identity printed on receipts
+type receiptIssuer struct {
+	Name    string
+	TaxID   string
+	Address string
+	Branch  string
+}
+
+func receiptIssuerFromEnv() receiptIssuer {
+	payer := withholdingPayerFromEnv()
+	branch := os.Getenv("PLATFORM_BRANCH")
+	if branch == "" {
+		branch = "Head Office"
+	}
+	return receiptIssuer{Name: payer.Name, TaxID: payer.TaxID, Address: payer.Address, Branch: branch}
+}
+
+// buildReceiptPDF renders a receipt / tax invoice (abbreviated form when the issuer has no tax ID)
+func buildReceiptPDF(r Receipt, issuer receiptIssuer) []byte {
+	title := "Receipt / Tax Invoice"
+	if issuer.TaxID == "" {
+		title = "Receipt"
+	}
+
+	doc := newPDFDocument(title + " " + r.ReceiptNo)
+	const left, right = 50.0, 545.0
+
+	doc.Text(left, 60, 18, true, title)
+	doc.TextRight(right, 60, 10, true, "No. "+r.ReceiptNo)
+	doc.TextRight(right, 76, 10, false, "Date "+r.IssuedAt.In(bangkokLocation).Format("2 Jan 2006"))
+	doc.TextRight(right, 92, 10, false, "Paid "+r.PaidAt.In(bangkokLocation).Format("2 Jan 2006 15:04"))
+
+	// Issuer (platform)
+	doc.Text(left, 100, 11, true, issuer.Name)
+	if issuer.TaxID != "" {
+		doc.Text(left, 115, 9, false, fmt.Sprintf("Tax ID %s (%s)", issuer.TaxID, issuer.Branch))
+	}
+	doc.Text(left, 128, 9, false, issuer.Address)
+
+	// Buyer & provider
+	doc.Rect(left, 145, right-left, 60, 0.8)
+	doc.Text(left+10, 162, 9, true, "Customer")
+	doc.Text(left+10, 177, 10, false, r.BuyerName)
+	doc.Text(left+10, 192, 9, false, r.BuyerEmail)
+	if r.ProviderID != nil {
+		doc.Text(300, 162, 9, true, "Service provider")
+		doc.Text(300, 177, 10, false, r.ProviderName)
+		doc.Text(300, 192, 9, false, fmt.Sprintf("Provider ID %d", *r.ProviderID))
+	}
+
+	// Line items
+	y := 230.0
+	doc.Line(left, y, right, y, 0.8)
+	doc.Text(left+5, y+15, 10, true, "Description")
+	doc.TextRight(right-5, y+15, 10, true, "Amount (THB)")
+	doc.Line(left, y+23, right, y+23, 0.5)
+	doc.Text(left+5, y+40, 10, false, r.Description)
+	doc.TextRight(right-5, y+40, 10, false, formatBaht(r.Subtotal))
+	y += 50
+	if r.DiscountAmount > 0 {
+		label := "Discount"
+		if r.CouponCode != "" {
+			label = "Discount (coupon " + r.CouponCode + ")"
+		}
+		doc.Text(left+5, y+5, 10, false, label)
+		doc.TextRight(right-5, y+5, 10, false, "-"+formatBaht(r.DiscountAmount))
+		y += 18
+	}
+	doc.Line(left, y, right, y, 0.5)
+
+	// Totals
+	rows := []struct {
+		label string
+		value float64
+		bold  bool
+	}{
+		{"Value before VAT", r.VATBase, false},
+		{fmt.Sprintf("VAT %.0f%%", r.VATRate*100), r.VATAmount, false},
+		{"Total paid", r.TotalAmount, true},
+	}
+	for _, row := range rows {
+		y += 18
+		doc.Text(330, y, 10, row.bold, row.label)
+		doc.TextRight(right-5, y, 10, row.bold, formatBaht(row.value))
+	}
+	doc.Line(330, y+8, right, y+8, 0.8)
+
+	y += 35
+	doc.Text(left, y, 9, false, "Payment method: "+receiptPaymentMethodLabel(r.PaymentMethod))
+	doc.Text(left, y+14, 9, false, "Prices include VAT. This document was issued electronically.")
+
+	return doc.Bytes()
+}
+
+func receiptPaymentMethodLabel(method string) string {
+	switch method {
+	case "promptpay":
+		return "PromptPay"
+	case "card":
+		return "Credit/debit card (Stripe)"
+	case "wallet":
+		return "SkillMatch wallet"
+	default:
+		return method
+	}
+}
+
+// receiptEmailBody is the HTML body of the receipt email (user-supplied values are escaped)
+func receiptEmailBody(r *Receipt) string {
+	return fmt.Sprintf(`
+		<p>Hello %s,</p>
+		<p>Thank you for your payment of <b>฿%s</b>. Your receipt <b>%s</b> is attached.</p>
+		<p>Thai Variety Platform</p>
+	`, html.EscapeString(r.BuyerName), formatBaht(r.TotalAmount), html.EscapeString(r.ReceiptNo))
+}
+
+// sendReceiptEmail emails the receipt PDF as an attachment (same SMTP settings as verification emails)
+func sendReceiptEmail(to string, r *Receipt, pdf []byte) error {
+	smtpHost := os.Getenv("SMTP_HOST")
+	smtpPort := os.Getenv("SMTP_PORT")
+	smtpUser := os.Getenv("SMTP_USER")
+	smtpPassword := os.Getenv("SMTP_PASSWORD")
+
+	if smtpHost == "" || smtpUser == "" || smtpPassword == "" {
+		log.Printf("⚠️  Email service not configured - receipt %s not sent to %s", r.ReceiptNo, to)
+		return nil
+	}
+
+	m := gomail.NewMessage()
+	m.SetHeader("From", os.Getenv("EMAIL_FROM"))
+	m.SetHeader("To", to)
+	m.SetHeader("Subject", fmt.Sprintf("Your receipt %s", r.ReceiptNo))
+	m.SetBody("text/html", receiptEmailBody(r))
+	m.Attach(r.ReceiptNo+".pdf",
+		gomail.SetCopyFunc(func(w io.Writer) error {
+			_, err := w.Write(pdf)
+			return err
+		}),
+		gomail.SetHeader(map[string][]string{"Content-Type": {"application/pdf"}}),
+	)
+
+	port, _ := strconv.Atoi(smtpPort)
+	d := gomail.NewDialer(smtpHost, port, smtpUser, smtpPassword)
+	if err := d.DialAndSend(m); err != nil {
+		log.Printf("❌ Failed to send receipt %s to %s: %v", r.ReceiptNo, to, err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test VAT breakdown of VAT-inclusive prices
+func TestComputeReceiptAmounts(t *testing.T) {
+	t.Run("VAT Inclusive", func(t *testing.T) {
+		a := computeReceiptAmounts(1070, 0, 0.07)
+		assert.Equal(t, 1070.0, a.Subtotal)
+		assert.Equal(t, 1070.0, a.Total)
+		assert.Equal(t, 1000.0, a.VATBase)
+		assert.Equal(t, 70.0, a.VAT)
+	})
+
+	t.Run("With Coupon Discount", func(t *testing.T) {
+		a := computeReceiptAmounts(900, 100, 0.07)
+		assert.Equal(t, 1000.0, a.Subtotal)
+		assert.Equal(t, 100.0, a.Discount)
+		assert.Equal(t, 841.12, a.VATBase)
+		assert.Equal(t, 58.88, a.VAT)
+		assert.Equal(t, a.Total, a.VATBase+a.VAT)
+	})
+
+	t.Run("Zero VAT", func(t *testing.T) {
+		a := computeReceiptAmounts(500, 0, 0)
+		assert.Equal(t, 500.0, a.VATBase)
+		assert.Equal(t, 0.0, a.VAT)
+	})
+}
+
+// Test fiscal year labelling
+func TestFiscalYearOf(t *testing.T) {
+	t.Run("Calendar Year", func(t *testing.T) {
+		// 31 Dec 2025 17:30 UTC = 1 Jan 2026 00:30 in Bangkok
+		assert.Equal(t, 2026, fiscalYearOf(time.Date(2025, 12, 31, 17, 30, 0, 0, time.UTC), time.January))
+		assert.Equal(t, 2025, fiscalYearOf(time.Date(2025, 12, 31, 16, 0, 0, 0, time.UTC), time.January))
+	})
+
+	t.Run("October Start", func(t *testing.T) {
+		assert.Equal(t, 2025, fiscalYearOf(time.Date(2025, 9, 30, 12, 0, 0, 0, bangkokLocation), time.October))
+		assert.Equal(t, 2026, fiscalYearOf(time.Date(2025, 10, 1, 0, 0, 0, 0, bangkokLocation), time.October))
+	})
+}
+
+// Test receipt numbering
+func TestReceiptNumber(t *testing.T) {
+	assert.Equal(t, "RC2026-000001", receiptNumber(2026, 1))
+	assert.Equal(t, "RC2026-123456", receiptNumber(2026, 123456))
+}
+
+// Test receipt PDF generation
+func TestReceiptPDF(t *testing.T) {
+	providerID := 42
+	r := Receipt{
+		ReceiptNo:      "RC2026-000007",
+		BuyerName:      "John Client",
+		BuyerEmail:     "john@example.com",
+		ProviderID:     &providerID,
+		ProviderName:   "Jane Provider",
+		Description:    "Booking #15 - Premium",
+		Subtotal:       1000,
+		DiscountAmount: 100,
+		CouponCode:     "WELCOME10",
+		VATRate:        0.07,
+		VATBase:        841.12,
+		VATAmount:      58.88,
+		TotalAmount:    900,
+		PaymentMethod:  "promptpay",
+		PaidAt:         time.Date(2026, 3, 1, 5, 0, 0, 0, time.UTC),
+		IssuedAt:       time.Date(2026, 3, 1, 5, 0, 0, 0, time.UTC),
+	}
+	withPDFFonts(t, nil)
+
+	t.Run("Tax Invoice", func(t *testing.T) {
+		pdf := string(buildReceiptPDF(r, receiptIssuer{Name: "SkillMatch Co., Ltd.", TaxID: "0105556177553", Branch: "Head Office"}))
+		assert.True(t, bytes.HasPrefix([]byte(pdf), []byte("%PDF-1.4")))
+		assert.Contains(t, pdf, "(Receipt / Tax Invoice)")
+		assert.Contains(t, pdf, "(No. RC2026-000007)")
+		assert.Contains(t, pdf, `(Discount \(coupon WELCOME10\))`)
+		assert.Contains(t, pdf, "(VAT 7%)")
+		assert.Contains(t, pdf, "(58.88)")
+		assert.Contains(t, pdf, "(Jane Provider)")
+		assert.Contains(t, pdf, "(Payment method: PromptPay)")
+	})
+
+	t.Run("Without Tax ID", func(t *testing.T) {
+		pdf := string(buildReceiptPDF(r, receiptIssuer{Name: "SkillMatch"}))
+		assert.NotContains(t, pdf, "Tax Invoice")
+		assert.Contains(t, pdf, "(Receipt)")
+	})
+
+	t.Run("Thai Buyer Name", func(t *testing.T) {
+		font, err := parseTrueTypeFont(buildTestTrueType("TestThai", "สมชายใจดี"))
+		assert.NoError(t, err)
+		withPDFFonts(t, font)
+
+		thai := r
+		thai.BuyerName = "สมชายใจดี"
+		pdf := buildReceiptPDF(thai, receiptIssuer{Name: "SkillMatch"})
+		assert.Equal(t, "สมชายใจดี", extractPDFText(pdf))
+		assert.Contains(t, string(pdf), "(Jane Provider)")
+	})
+}
+
+// Test the receipt email escapes user-supplied names
+func TestReceiptEmailBody(t *testing.T) {
+	body := receiptEmailBody(&Receipt{ReceiptNo: "RC2026-000007", BuyerName: `<img src=x onerror="alert(1)"> & Co`, TotalAmount: 900})
+	assert.NotContains(t, body, "<img")
+	assert.Contains(t, body, "Hello &lt;img src=x onerror=&#34;alert(1)&#34;&gt; &amp; Co,")
+	assert.Contains(t, body, "<b>RC2026-000007</b>")
+}
@@ -0,0 +1,541 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ================================
+// Nightly Reconciliation (กระทบยอด wallet / escrow / payments)
+// ================================
+
+// Reconciliation check types (reconciliation_discrepancies.check_type)
+const (
+	ReconcileWallet  = "wallet"
+	ReconcileEscrow  = "escrow"
+	ReconcilePayment = "payment"
+)
+
+// reconciliationHour is when the nightly run starts (Bangkok time)
+const reconciliationHour = 2
+
+// reconciliationTolerance ignores rounding noise below one satang
+const reconciliationTolerance = 0.005
+
+var errReconciliationRunning = errors.New("reconciliation is already running")
+
+// ReconciliationRun is one execution of the job (reconciliation_runs)
+type ReconciliationRun struct {
+	RunID            int        `json:"run_id"`
+	Trigger          string     `json:"trigger"` // scheduled, manual
+	Status           string     `json:"status"`  // running, completed, failed
+	WalletsChecked   int        `json:"wallets_checked"`
+	EscrowsChecked   int        `json:"escrows_checked"`
+	PaymentsChecked  int        `json:"payments_checked"`
+	DiscrepancyCount int        `json:"discrepancy_count"`
+	NewCount         int        `json:"new_count"`
+	ResolvedCount    int        `json:"resolved_count"`
+	ErrorMessage     *string    `json:"error_message,omitempty"`
+	StartedAt        time.Time  `json:"started_at"`
+	FinishedAt       *time.Time `json:"finished_at"`
+}
+
+// reconciliationFinding is one discrepancy detected by a check
+type reconciliationFinding struct {
+	CheckType  string
+	Code       string
+	EntityType string // wallet, escrow, payment, booking
+	EntityID   int
+	UserID     *int
+	BookingID  *int
+	Expected   *float64
+	Actual     *float64
+	Details    map[string]interface{}
+}
+
+func amountsDiffer(a, b float64) bool {
+	return math.Abs(a-b) > reconciliationTolerance
+}
+
+func roundSatang(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+// --- Wallets ---
+
+// walletLedger is a wallet and the sums of the ledger rows that should explain it
+type walletLedger struct {
+	WalletID             int
+	UserID               int
+	Available            float64
+	Pending              float64
+	Credit               float64
+	Debt                 float64 // ยอดที่ provider ค้างคืน (reversal ที่หักจาก wallet ไม่ได้)
+	TotalEarned          float64
+	TotalWithdrawn       float64
+	Earnings             float64 // completed booking_payment / booking_extension / escrow_release / cancellation_fee / tip / campaign_subsidy (net), less reversals
+	Adjustments          float64 // completed bonus / penalty / adjustment (signed)
+	WalletFunding        float64 // completed top-ups + wallet refunds + referral rewards - wallet payments (held or captured)
+	WithdrawalsHeld      float64 // withdrawals not rejected/failed/cancelled (deducted at request)
+	WithdrawalsCompleted float64
+}
+
+// checkWalletLedger: available + pending + credit - debt = earnings + adjustments + wallet funding - withdrawals held
+func checkWalletLedger(w walletLedger) []reconciliationFinding {
+	var findings []reconciliationFinding
+	userID := w.UserID
+	finding := func(code string, expected, actual float64) reconciliationFinding {
+		e, a := roundSatang(expected), roundSatang(actual)
+		return reconciliationFinding{
+			CheckType: ReconcileWallet, Code: code, EntityType: "wallet", EntityID: w.WalletID,
+			UserID: &userID, Expected: &e, Actual: &a,
+			Details: map[string]interface{}{
+				"available_balance": w.Available,
+				"pending_balance":   w.Pending,
+				"credit_balance":    w.Credit,
+				"debt_balance":      w.Debt,
+				"earnings":          roundSatang(w.Earnings),
+				"adjustments":       roundSatang(w.Adjustments),
+				"wallet_funding":    roundSatang(w.WalletFunding),
+				"withdrawals_held":  roundSatang(w.WithdrawalsHeld),
+			},
+		}
+	}
+
+	expectedBalance := w.Earnings + w.Adjustments + w.WalletFunding - w.WithdrawalsHeld
+	if actual := w.Available + w.Pending + w.Credit - w.Debt; amountsDiffer(expectedBalance, actual) {
+		findings = append(findings, finding("wallet_balance_mismatch", expectedBalance, actual))
+	}
+	if amountsDiffer(w.Earnings, w.TotalEarned) {
+		findings = append(findings, finding("wallet_total_earned_mismatch", w.Earnings, w.TotalEarned))
+	}
+	if amountsDiffer(w.WithdrawalsCompleted, w.TotalWithdrawn) {
+		findings = append(findings, finding("wallet_total_withdrawn_mismatch", w.WithdrawalsCompleted, w.TotalWithdrawn))
+	}
+	if w.Available < 0 || w.Pending < 0 {
+		findings = append(findings, finding("wallet_negative_balance", 0, math.Min(w.Available, w.Pending)))
+	}
+	return findings
+}
+
+// --- Escrow ---
+
+// escrowState is an escrow_payments row next to its booking
+type escrowState struct {
+	EscrowID      int
+	BookingID     int
+	ProviderID    int
+	Amount        float64
+	EscrowStatus  string
+	ReleasedAt    *time.Time
+	BookingStatus string
+	EscrowLocked  bool // bookings.escrow_locked
+}
+
+// bookings that can no longer hold money in escrow
+var closedBookingStatuses = map[string]bool{
+	"cancelled": true, "funds_released": true, "dispute_resolved": true, "refunded": true,
+}
+
+func checkEscrowState(e escrowState) []reconciliationFinding {
+	var findings []reconciliationFinding
+	bookingID, providerID, amount := e.BookingID, e.ProviderID, e.Amount
+	add := func(code string) {
+		findings = append(findings, reconciliationFinding{
+			CheckType: ReconcileEscrow, Code: code, EntityType: "escrow", EntityID: e.EscrowID,
+			UserID: &providerID, BookingID: &bookingID, Actual: &amount,
+			Details: map[string]interface{}{
+				"escrow_status":  e.EscrowStatus,
+				"booking_status": e.BookingStatus,
+				"escrow_locked":  e.EscrowLocked,
+			},
+		})
+	}
+
+	switch e.EscrowStatus {
+	case "locked":
+		if closedBookingStatuses[e.BookingStatus] {
+			add("escrow_locked_on_closed_booking")
+		}
+		if !e.EscrowLocked {
+			add("escrow_flag_mismatch")
+		}
+	case "released":
+		if e.BookingStatus != "funds_released" && e.BookingStatus != "dispute_resolved" {
+			add("escrow_released_booking_open")
+		}
+	case "disputed":
+		if e.BookingStatus != "disputed" {
+			add("escrow_dispute_mismatch")
+		}
+	}
+	if e.ReleasedAt == nil && (e.EscrowStatus == "released" || e.EscrowStatus == "refunded" || e.EscrowStatus == "partially_refunded") {
+		add("escrow_settled_without_timestamp")
+	}
+	return findings
+}
+
+// --- Payments ---
+
+// paymentState is a payments row next to its booking
+type paymentState struct {
+	PaymentID            int
+	BookingID            int
+	ClientID             int
+	Amount               float64
+	PaymentStatus        string
+	BookingPaymentStatus string
+	CompletedForBooking  int  // completed payments for the same booking
+	HasLedger            bool // a completed booking_payment transaction exists
+}
+
+var paidBookingStatuses = map[string]bool{"paid": true, "deposit_paid": true, "fully_paid": true}
+
+func checkPaymentState(p paymentState) []reconciliationFinding {
+	if p.PaymentStatus != "completed" {
+		return nil
+	}
+
+	var findings []reconciliationFinding
+	bookingID, clientID, amount := p.BookingID, p.ClientID, p.Amount
+	add := func(code string) {
+		findings = append(findings, reconciliationFinding{
+			CheckType: ReconcilePayment, Code: code, EntityType: "payment", EntityID: p.PaymentID,
+			UserID: &clientID, BookingID: &bookingID, Actual: &amount,
+			Details: map[string]interface{}{
+				"payment_status":         p.PaymentStatus,
+				"booking_payment_status": p.BookingPaymentStatus,
+			},
+		})
+	}
+
+	if !paidBookingStatuses[p.BookingPaymentStatus] {
+		add("payment_completed_booking_unpaid")
+	}
+	if !p.HasLedger {
+		add("payment_missing_ledger")
+	}
+	if p.CompletedForBooking > 1 {
+		add("payment_duplicate")
+	}
+	return findings
+}
+
+// --- Run ---
+
+// reconciliationDue reports whether the nightly run for now's date has not started yet
+func reconciliationDue(lastStarted *time.Time, now time.Time) bool {
+	local := now.In(bangkokLocation)
+	if local.Hour() < reconciliationHour {
+		return false
+	}
+	todayRun := time.Date(local.Year(), local.Month(), local.Day(), reconciliationHour, 0, 0, 0, bangkokLocation)
+	return lastStarted == nil || lastStarted.Before(todayRun)
+}
+
+// runReconciliation checks every wallet, escrow and payment, records discrepancies
+// and alerts admins about new ones. Only one run can be in progress at a time.
+func runReconciliation(ctx context.Context, dbPool *pgxpool.Pool, trigger string) (*ReconciliationRun, error) {
+	conn, err := dbPool.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	var locked bool
+	if err := conn.QueryRow(ctx, `SELECT pg_try_advisory_lock(7301)`).Scan(&locked); err != nil {
+		return nil, err
+	}
+	if !locked {
+		return nil, errReconciliationRunning
+	}
+	defer conn.Exec(context.Background(), `SELECT pg_advisory_unlock(7301)`)
+
+	run := &ReconciliationRun{Trigger: trigger, Status: "running"}
+	err = conn.QueryRow(ctx, `
+		INSERT INTO reconciliation_runs (trigger, status) VALUES ($1, 'running')
+		RETURNING run_id, started_at
+	`, trigger).Scan(&run.RunID, &run.StartedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	findings, err := collectReconciliationFindings(ctx, dbPool, run)
+	if err == nil {
+		err = recordReconciliationFindings(ctx, dbPool, run, findings)
+	}
+
+	now := time.Now()
+	run.FinishedAt = &now
+	run.Status = "completed"
+	if err != nil {
+		msg := err.Error()
+		run.Status, run.ErrorMessage = "failed", &msg
+	}
+	dbPool.Exec(ctx, `
+		UPDATE reconciliation_runs
+		SET status = $2, wallets_checked = $3, escrows_checked = $4, payments_checked = $5,
+		    discrepancy_count = $6, new_count = $7, resolved_count = $8, error_message = $9, finished_at = $10
+		WHERE run_id = $1
+	`, run.RunID, run.Status, run.WalletsChecked, run.EscrowsChecked, run.PaymentsChecked,
+		run.DiscrepancyCount, run.NewCount, run.ResolvedCount, run.ErrorMessage, now)
+
+	if err != nil {
+		return run, err
+	}
+	if run.NewCount > 0 {
+		alertAdminsOfDiscrepancies(ctx, dbPool, run)
+	}
+	log.Printf("🧾 Reconciliation #%d: %d discrepancies (%d new, %d resolved)", run.RunID, run.DiscrepancyCount, run.NewCount, run.ResolvedCount)
+	return run, nil
+}
+
+func collectReconciliationFindings(ctx context.Context, dbPool *pgxpool.Pool, run *ReconciliationRun) ([]reconciliationFinding, error) {
+	var findings []reconciliationFinding
+
+	// 1. Wallets vs ledger
+	rows, err := dbPool.Query(ctx, `
+		SELECT w.wallet_id, w.user_id, w.available_balance, w.pending_balance, w.credit_balance, w.debt_balance, w.total_earned, w.total_withdrawn,
+		       COALESCE(t.earnings, 0), COALESCE(t.adjustments, 0), COALESCE(t.wallet_funding, 0),
+		       COALESCE(wd.held, 0), COALESCE(wd.completed, 0)
+		FROM wallets w
+		LEFT JOIN (
+			SELECT user_id,
+			       SUM(CASE WHEN status::text = 'completed'
+			                 AND type::text IN ('booking_payment', 'booking_extension', 'booking_payment_reversal', 'escrow_release', 'cancellation_fee', 'tip', 'campaign_subsidy') THEN net_amount ELSE 0 END) AS earnings,
+			       SUM(CASE WHEN status::text = 'completed'
+			                 AND type::text IN ('bonus', 'penalty', 'adjustment', 'admin_adjustment') THEN amount ELSE 0 END) AS adjustments,
+			       SUM(CASE WHEN status::text = 'completed' AND type::text IN ('wallet_topup', 'wallet_refund', 'referral_reward') THEN amount
+			                WHEN status::text IN ('pending', 'completed') AND type::text = 'wallet_payment' THEN -amount
+			                ELSE 0 END) AS wallet_funding
+			FROM transactions
+			WHERE user_id IS NOT NULL
+			GROUP BY user_id
+		) t ON t.user_id = w.user_id
+		LEFT JOIN (
+			SELECT user_id,
+			       SUM(CASE WHEN status::text NOT IN ('rejected', 'failed', 'cancelled') THEN requested_amount ELSE 0 END) AS held,
+			       SUM(CASE WHEN status::text = 'completed' THEN requested_amount ELSE 0 END) AS completed
+			FROM withdrawals
+			GROUP BY user_id
+		) wd ON wd.user_id = w.user_id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("wallet check: %w", err)
+	}
+	for rows.Next() {
+		var w walletLedger
+		if err := rows.Scan(&w.WalletID, &w.UserID, &w.Available, &w.Pending, &w.Credit, &w.Debt, &w.TotalEarned, &w.TotalWithdrawn,
+			&w.Earnings, &w.Adjustments, &w.WalletFunding, &w.WithdrawalsHeld, &w.WithdrawalsCompleted); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("wallet check: %w", err)
+		}
+		run.WalletsChecked++
+		findings = append(findings, checkWalletLedger(w)...)
+	}
+	rows.Close()
+
+	// 2. Escrow vs booking state
+	rows, err = dbPool.Query(ctx, `
+		SELECT e.escrow_id, e.booking_id, b.provider_id, e.amount, e.status, e.released_at,
+		       b.status, COALESCE(b.escrow_locked, false)
+		FROM escrow_payments e
+		JOIN bookings b ON b.booking_id = e.booking_id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("escrow check: %w", err)
+	}
+	for rows.Next() {
+		var e escrowState
+		if err := rows.Scan(&e.EscrowID, &e.BookingID, &e.ProviderID, &e.Amount, &e.EscrowStatus, &e.ReleasedAt,
+			&e.BookingStatus, &e.EscrowLocked); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("escrow check: %w", err)
+		}
+		run.EscrowsChecked++
+		findings = append(findings, checkEscrowState(e)...)
+	}
+	rows.Close()
+
+	// bookings flagged as escrow_locked without any escrow row
+	rows, err = dbPool.Query(ctx, `
+		SELECT b.booking_id, b.provider_id, COALESCE(b.remaining_amount, 0)
+		FROM bookings b
+		WHERE b.escrow_locked = true
+		  AND NOT EXISTS (SELECT 1 FROM escrow_payments e WHERE e.booking_id = b.booking_id)
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("escrow check: %w", err)
+	}
+	for rows.Next() {
+		var bookingID, providerID int
+		var remaining float64
+		if err := rows.Scan(&bookingID, &providerID, &remaining); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("escrow check: %w", err)
+		}
+		b, p, r := bookingID, providerID, remaining
+		findings = append(findings, reconciliationFinding{
+			CheckType: ReconcileEscrow, Code: "booking_escrow_missing", EntityType: "booking", EntityID: bookingID,
+			UserID: &p, BookingID: &b, Expected: &r,
+		})
+	}
+	rows.Close()
+
+	// 3. Payments vs booking payment_status
+	rows, err = dbPool.Query(ctx, `
+		SELECT p.payment_id, p.booking_id, b.client_id, p.amount, p.payment_status, COALESCE(b.payment_status, ''),
+		       COUNT(*) FILTER (WHERE p.payment_status = 'completed') OVER (PARTITION BY p.booking_id),
+		       EXISTS(SELECT 1 FROM transactions t
+		              WHERE t.booking_id = p.booking_id AND t.type::text = 'booking_payment' AND t.status::text = 'completed')
+		FROM payments p
+		JOIN bookings b ON b.booking_id = p.booking_id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("payment check: %w", err)
+	}
+	for rows.Next() {
+		var p paymentState
+		if err := rows.Scan(&p.PaymentID, &p.BookingID, &p.ClientID, &p.Amount, &p.PaymentStatus,
+			&p.BookingPaymentStatus, &p.CompletedForBooking, &p.HasLedger); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("payment check: %w", err)
+		}
+		run.PaymentsChecked++
+		findings = append(findings, checkPaymentState(p)...)
+	}
+	rows.Close()
+
+	// bookings marked paid with neither a completed payment nor a payment ledger line
+	rows, err = dbPool.Query(ctx, `
+		SELECT b.booking_id, b.client_id, COALESCE(b.total_price, 0), b.payment_status
+		FROM bookings b
+		WHERE b.payment_status IN ('paid', 'fully_paid')
+		  AND NOT EXISTS (SELECT 1 FROM payments p WHERE p.booking_id = b.booking_id AND p.payment_status = 'completed')
+		  AND NOT EXISTS (SELECT 1 FROM transactions t
+		                  WHERE t.booking_id = b.booking_id AND t.type::text = 'booking_payment' AND t.status::text = 'completed')
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("payment check: %w", err)
+	}
+	for rows.Next() {
+		var bookingID, clientID int
+		var totalPrice float64
+		var paymentStatus string
+		if err := rows.Scan(&bookingID, &clientID, &totalPrice, &paymentStatus); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("payment check: %w", err)
+		}
+		b, u, e := bookingID, clientID, totalPrice
+		findings = append(findings, reconciliationFinding{
+			CheckType: ReconcilePayment, Code: "booking_paid_without_payment", EntityType: "booking", EntityID: bookingID,
+			UserID: &u, BookingID: &b, Expected: &e,
+			Details: map[string]interface{}{"booking_payment_status": paymentStatus},
+		})
+	}
+	rows.Close()
+
+	return findings, nil
+}
+
+// recordReconciliationFindings upserts open discrepancies (one per code+entity) and
+// resolves open ones that this run no longer detects
+func recordReconciliationFindings(ctx context.Context, dbPool *pgxpool.Pool, run *ReconciliationRun, findings []reconciliationFinding) error {
+	tx, err := dbPool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	for _, f := range findings {
+		var difference *float64
+		if f.Expected != nil && f.Actual != nil {
+			d := roundSatang(*f.Actual - *f.Expected)
+			difference = &d
+		}
+		details, _ := json.Marshal(f.Details)
+
+		var inserted bool
+		err := tx.QueryRow(ctx, `
+			INSERT INTO reconciliation_discrepancies (
+				first_run_id, last_run_id, check_type, code, entity_type, entity_id,
+				user_id, booking_id, expected_amount, actual_amount, difference, details
+			) VALUES ($1, $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+			ON CONFLICT (code, entity_type, entity_id) WHERE status = 'open' DO UPDATE SET
+				last_run_id = EXCLUDED.last_run_id,
+				expected_amount = EXCLUDED.expected_amount,
+				actual_amount = EXCLUDED.actual_amount,
+				difference = EXCLUDED.difference,
+				details = EXCLUDED.details,
+				last_seen_at = NOW()
+			RETURNING (xmax = 0)
+		`, run.RunID, f.CheckType, f.Code, f.EntityType, f.EntityID,
+			f.UserID, f.BookingID, f.Expected, f.Actual, difference, details).Scan(&inserted)
+		if err != nil {
+			return err
+		}
+		if inserted {
+			run.NewCount++
+		}
+	}
+	run.DiscrepancyCount = len(findings)
+
+	result, err := tx.Exec(ctx, `
+		UPDATE reconciliation_discrepancies
+		SET status = 'resolved', resolved_at = NOW(), resolution_notes = 'No longer detected by reconciliation'
+		WHERE status = 'open' AND last_run_id <> $1
+	`, run.RunID)
+	if err != nil {
+		return err
+	}
+	run.ResolvedCount = int(result.RowsAffected())
+
+	return tx.Commit(ctx)
+}
+
+func alertAdminsOfDiscrepancies(ctx context.Context, dbPool *pgxpool.Pool, run *ReconciliationRun) {
+	rows, err := dbPool.Query(ctx, `SELECT user_id FROM users WHERE is_admin = true`)
+	if err != nil {
+		log.Printf("⚠️  Failed to load admins for reconciliation alert: %v", err)
+		return
+	}
+	defer rows.Close()
+
+	message := fmt.Sprintf("Reconciliation #%d found %d new discrepancies (%d open in total)", run.RunID, run.NewCount, run.DiscrepancyCount)
+	for rows.Next() {
+		var adminID int
+		if rows.Scan(&adminID) == nil {
+			CreateNotification(adminID, "reconciliation_alert", message, map[string]interface{}{
+				"run_id":            run.RunID,
+				"new_count":         run.NewCount,
+				"discrepancy_count": run.DiscrepancyCount,
+			})
+		}
+	}
+}
+
+// startReconciliationScheduler runs the reconciliation once a night after reconciliationHour
+func startReconciliationScheduler(dbPool *pgxpool.Pool, ctx context.Context) {
+	runPeriodically(ctx, "reconciliation", 15*time.Minute, func(ctx context.Context) error {
+		var lastStarted *time.Time
+		dbPool.QueryRow(ctx, `
+			SELECT MAX(started_at) FROM reconciliation_runs WHERE trigger = 'scheduled'
+		`).Scan(&lastStarted)
+		if !reconciliationDue(lastStarted, time.Now()) {
+			return nil
+		}
+
+		_, err := runReconciliation(ctx, dbPool, "scheduled")
+		if errors.Is(err, errReconciliationRunning) {
+			return nil
+		}
+		return err
+	})
+}
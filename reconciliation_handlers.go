@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ================================
+// Admin: Reconciliation
+// ================================
+
+// GET /admin/reconciliation/runs
+func adminGetReconciliationRunsHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		rows, err := dbPool.Query(ctx, `
+			SELECT run_id, trigger, status, wallets_checked, escrows_checked, payments_checked,
+			       discrepancy_count, new_count, resolved_count, error_message, started_at, finished_at
+			FROM reconciliation_runs
+			ORDER BY started_at DESC
+			LIMIT 60
+		`)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch reconciliation runs"})
+			return
+		}
+		defer rows.Close()
+
+		runs := make([]ReconciliationRun, 0)
+		for rows.Next() {
+			var r ReconciliationRun
+			if err := rows.Scan(&r.RunID, &r.Trigger, &r.Status, &r.WalletsChecked, &r.EscrowsChecked, &r.PaymentsChecked,
+				&r.DiscrepancyCount, &r.NewCount, &r.ResolvedCount, &r.ErrorMessage, &r.StartedAt, &r.FinishedAt); err != nil {
+				continue
+			}
+			runs = append(runs, r)
+		}
+
+		c.JSON(http.StatusOK, gin.H{"runs": runs})
+	}
+}
+
+// POST /admin/reconciliation/runs (รันกระทบยอดทันที)
+func adminRunReconciliationHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		run, err := runReconciliation(ctx, dbPool, "manual")
+		if errors.Is(err, errReconciliationRunning) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Reconciliation failed", "details": err.Error(), "run": run})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Reconciliation completed", "run": run})
+	}
+}
+
+// GET /admin/reconciliation/discrepancies?status=open&check_type=wallet&run_id=
+func adminGetDiscrepanciesHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		status := c.DefaultQuery("status", "open")
+		if status == "all" {
+			status = ""
+		}
+		runID, _ := strconv.Atoi(c.Query("run_id"))
+
+		rows, err := dbPool.Query(ctx, `
+			SELECT discrepancy_id, first_run_id, last_run_id, check_type, code, entity_type, entity_id,
+			       user_id, booking_id, expected_amount, actual_amount, difference, details, status,
+			       resolution_notes, resolved_by, resolved_at, first_seen_at, last_seen_at
+			FROM reconciliation_discrepancies
+			WHERE ($1 = '' OR status = $1)
+			  AND ($2 = '' OR check_type = $2)
+			  AND ($3 = 0 OR last_run_id = $3)
+			ORDER BY last_seen_at DESC, discrepancy_id DESC
+			LIMIT 500
+		`, status, c.Query("check_type"), runID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch discrepancies"})
+			return
+		}
+		defer rows.Close()
+
+		discrepancies := make([]gin.H, 0)
+		for rows.Next() {
+			var (
+				discrepancyID, firstRunID, lastRunID, entityID int
+				checkType, code, entityType, dStatus           string
+				userID, bookingID, resolvedBy                  *int
+				expected, actual, difference                   *float64
+				details                                        []byte
+				resolutionNotes                                *string
+				resolvedAt                                     *time.Time
+				firstSeenAt, lastSeenAt                        time.Time
+			)
+			if err := rows.Scan(&discrepancyID, &firstRunID, &lastRunID, &checkType, &code, &entityType, &entityID,
+				&userID, &bookingID, &expected, &actual, &difference, &details, &dStatus,
+				&resolutionNotes, &resolvedBy, &resolvedAt, &firstSeenAt, &lastSeenAt); err != nil {
+				continue
+			}
+			var detailMap map[string]interface{}
+			json.Unmarshal(details, &detailMap)
+
+			discrepancies = append(discrepancies, gin.H{
+				"discrepancy_id":   discrepancyID,
+				"first_run_id":     firstRunID,
+				"last_run_id":      lastRunID,
+				"check_type":       checkType,
+				"code":             code,
+				"entity_type":      entityType,
+				"entity_id":        entityID,
+				"user_id":          userID,
+				"booking_id":       bookingID,
+				"expected_amount":  expected,
+				"actual_amount":    actual,
+				"difference":       difference,
+				"details":          detailMap,
+				"status":           dStatus,
+				"resolution_notes": resolutionNotes,
+				"resolved_by":      resolvedBy,
+				"resolved_at":      resolvedAt,
+				"first_seen_at":    firstSeenAt,
+				"last_seen_at":     lastSeenAt,
+			})
+		}
+
+		c.JSON(http.StatusOK, gin.H{"discrepancies": discrepancies, "total": len(discrepancies)})
+	}
+}
+
+// PATCH /admin/reconciliation/discrepancies/:discrepancy_id (ปิดเคส: resolved / ignored)
+func adminUpdateDiscrepancyHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		adminID := c.GetInt("userID")
+
+		var req struct {
+			Status string `json:"status" binding:"required,oneof=resolved ignored"`
+			Notes  string `json:"notes" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		result, err := dbPool.Exec(ctx, `
+			UPDATE reconciliation_discrepancies
+			SET status = $1, resolution_notes = $2, resolved_by = $3, resolved_at = NOW()
+			WHERE discrepancy_id = $4 AND status = 'open'
+		`, req.Status, req.Notes, adminID, c.Param("discrepancy_id"))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update discrepancy"})
+			return
+		}
+		if result.RowsAffected() == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Open discrepancy not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Discrepancy updated", "status": req.Status})
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func findingCodes(findings []reconciliationFinding) []string {
+	codes := make([]string, 0, len(findings))
+	for _, f := range findings {
+		codes = append(codes, f.Code)
+	}
+	return codes
+}
+
+// Test wallet balances against the ledger
+func TestCheckWalletLedger(t *testing.T) {
+	balanced := walletLedger{
+		WalletID: 1, UserID: 7,
+		Available: 600, Pending: 272.5, TotalEarned: 1372.5, TotalWithdrawn: 300,
+		Earnings: 1372.5, Adjustments: 0, WithdrawalsHeld: 500, WithdrawalsCompleted: 300,
+	}
+
+	t.Run("Balanced Wallet", func(t *testing.T) {
+		assert.Empty(t, checkWalletLedger(balanced))
+	})
+
+	t.Run("Rounding Noise Ignored", func(t *testing.T) {
+		w := balanced
+		w.Earnings += 0.004
+		w.TotalEarned += 0.004
+		assert.Empty(t, checkWalletLedger(w))
+	})
+
+	t.Run("Balance Mismatch", func(t *testing.T) {
+		w := balanced
+		w.Available = 650 // escrow released twice
+		findings := checkWalletLedger(w)
+		assert.Equal(t, []string{"wallet_balance_mismatch"}, findingCodes(findings))
+		assert.Equal(t, 872.5, *findings[0].Expected)
+		assert.Equal(t, 922.5, *findings[0].Actual)
+		assert.Equal(t, 7, *findings[0].UserID)
+	})
+
+	t.Run("Client Wallet Funding", func(t *testing.T) {
+		// เติม 1000, จ่าย booking 700 (หนึ่งรายการยังกันไว้ 100), ได้คืนเข้า wallet 50
+		w := walletLedger{
+			WalletID: 2, UserID: 8,
+			Available: 250, WalletFunding: 1000 - 700 - 100 + 50,
+		}
+		assert.Empty(t, checkWalletLedger(w))
+
+		w.Available = 350 // hold released without cancelling the ledger line
+		assert.Equal(t, []string{"wallet_balance_mismatch"}, findingCodes(checkWalletLedger(w)))
+	})
+
+	t.Run("Reversal Shortfall As Debt", func(t *testing.T) {
+		// รายได้ 872.5 ถอนไปแล้ว 500 → booking ถูกยกเลิก หักคืนได้ 372.5 อีก 500 เป็นยอดค้าง
+		w := walletLedger{
+			WalletID: 3, UserID: 9,
+			Debt: 500, Earnings: 872.5 - 872.5, WithdrawalsHeld: 500,
+		}
+		assert.Empty(t, checkWalletLedger(w))
+	})
+
+	t.Run("Totals And Negative Balance", func(t *testing.T) {
+		w := balanced
+		w.Pending = -27.5
+		w.Available = 900
+		w.TotalWithdrawn = 0
+		assert.ElementsMatch(t,
+			[]string{"wallet_total_withdrawn_mismatch", "wallet_negative_balance"},
+			findingCodes(checkWalletLedger(w)))
+	})
+}
+
+// Test escrow rows against booking state
+func TestCheckEscrowState(t *testing.T) {
+	now := time.Now()
+
+	assert.Empty(t, checkEscrowState(escrowState{EscrowStatus: "locked", BookingStatus: "in_progress", EscrowLocked: true}))
+	assert.Empty(t, checkEscrowState(escrowState{EscrowStatus: "released", BookingStatus: "funds_released", ReleasedAt: &now}))
+
+	assert.Equal(t, []string{"escrow_locked_on_closed_booking"},
+		findingCodes(checkEscrowState(escrowState{EscrowStatus: "locked", BookingStatus: "cancelled", EscrowLocked: true})))
+	assert.Equal(t, []string{"escrow_flag_mismatch"},
+		findingCodes(checkEscrowState(escrowState{EscrowStatus: "locked", BookingStatus: "in_progress"})))
+	assert.Equal(t, []string{"escrow_released_booking_open", "escrow_settled_without_timestamp"},
+		findingCodes(checkEscrowState(escrowState{EscrowStatus: "released", BookingStatus: "completed"})))
+	assert.Equal(t, []string{"escrow_dispute_mismatch"},
+		findingCodes(checkEscrowState(escrowState{EscrowStatus: "disputed", BookingStatus: "completed"})))
+}
+
+// Test payments against booking payment_status
+func TestCheckPaymentState(t *testing.T) {
+	ok := paymentState{PaymentID: 3, BookingID: 9, PaymentStatus: "completed", BookingPaymentStatus: "paid", CompletedForBooking: 1, HasLedger: true}
+	assert.Empty(t, checkPaymentState(ok))
+
+	pending := ok
+	pending.PaymentStatus, pending.BookingPaymentStatus, pending.HasLedger = "pending", "pending", false
+	assert.Empty(t, checkPaymentState(pending))
+
+	broken := ok
+	broken.BookingPaymentStatus, broken.HasLedger, broken.CompletedForBooking = "pending", false, 2
+	findings := checkPaymentState(broken)
+	assert.Equal(t, []string{"payment_completed_booking_unpaid", "payment_missing_ledger", "payment_duplicate"}, findingCodes(findings))
+	assert.Equal(t, 9, *findings[0].BookingID)
+}
+
+// Test nightly schedule
+func TestReconciliationDue(t *testing.T) {
+	at := func(day, hour int) time.Time { return time.Date(2025, 10, day, hour, 30, 0, 0, bangkokLocation) }
+
+	assert.False(t, reconciliationDue(nil, at(16, 1)))
+	assert.True(t, reconciliationDue(nil, at(16, 2)))
+
+	yesterday := at(15, 2)
+	assert.True(t, reconciliationDue(&yesterday, at(16, 3)))
+
+	today := at(16, 2)
+	assert.False(t, reconciliationDue(&today, at(16, 23)))
+}
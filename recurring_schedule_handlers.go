@@ -0,0 +1,421 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// recurringRuleRequest is the body for creating/updating a recurring rule
+type recurringRuleRequest struct {
+	Weekday          *int    `json:"weekday" binding:"required"`
+	StartTime        string  `json:"start_time" binding:"required"` // HH:MM
+	EndTime          string  `json:"end_time" binding:"required"`   // HH:MM
+	LocationType     *string `json:"location_type"`
+	LocationProvince *string `json:"location_province"`
+	EffectiveFrom    string  `json:"effective_from"`
+	EffectiveUntil   *string `json:"effective_until"`
+	SkipHolidays     bool    `json:"skip_holidays"`
+	IsPaused         bool    `json:"is_paused"`
+	Notes            *string `json:"notes"`
+}
+
+func (req recurringRuleRequest) rule() *RecurringScheduleRule {
+	return &RecurringScheduleRule{
+		Weekday:          *req.Weekday,
+		StartTime:        req.StartTime,
+		EndTime:          req.EndTime,
+		LocationType:     req.LocationType,
+		LocationProvince: req.LocationProvince,
+		EffectiveFrom:    req.EffectiveFrom,
+		EffectiveUntil:   req.EffectiveUntil,
+		SkipHolidays:     req.SkipHolidays,
+		IsPaused:         req.IsPaused,
+		Notes:            req.Notes,
+	}
+}
+
+func insertRecurringRule(ctx context.Context, db rowQuerier, providerID int, r *RecurringScheduleRule) (*RecurringScheduleRule, error) {
+	return scanRecurringRule(db.QueryRow(ctx, `
+		INSERT INTO provider_recurring_schedules (
+			provider_id, weekday, start_time, end_time, location_type, location_province,
+			effective_from, effective_until, skip_holidays, is_paused, notes
+		) VALUES ($1, $2, $3::time, $4::time, $5, $6, $7::date, $8::date, $9, $10, $11)
+		RETURNING `+recurringRuleColumns,
+		providerID, r.Weekday, r.StartTime, r.EndTime, r.LocationType, r.LocationProvince,
+		r.EffectiveFrom, r.EffectiveUntil, r.SkipHolidays, r.IsPaused, r.Notes))
+}
+
+// GET /provider/schedule/recurring - กฎตารางประจำสัปดาห์ + วันยกเว้นของตัวเอง
+func getMyRecurringSchedulesHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetInt("userID")
+
+		rows, err := dbPool.Query(ctx, `
+			SELECT `+recurringRuleColumns+`
+			FROM provider_recurring_schedules
+			WHERE provider_id = $1
+			ORDER BY weekday, start_time
+		`, userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch recurring schedules"})
+			return
+		}
+		defer rows.Close()
+
+		rules := make([]*RecurringScheduleRule, 0)
+		for rows.Next() {
+			if r, err := scanRecurringRule(rows); err == nil {
+				rules = append(rules, r)
+			}
+		}
+
+		rows, err = dbPool.Query(ctx, `
+			SELECT exception_id, provider_id, TO_CHAR(start_date, 'YYYY-MM-DD'), TO_CHAR(end_date, 'YYYY-MM-DD'), reason, created_at
+			FROM provider_schedule_exceptions
+			WHERE provider_id = $1 AND end_date >= CURRENT_DATE
+			ORDER BY start_date
+		`, userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch schedule exceptions"})
+			return
+		}
+		defer rows.Close()
+
+		exceptions := make([]ScheduleException, 0)
+		for rows.Next() {
+			var e ScheduleException
+			if err := rows.Scan(&e.ExceptionID, &e.ProviderID, &e.StartDate, &e.EndDate, &e.Reason, &e.CreatedAt); err == nil {
+				exceptions = append(exceptions, e)
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{"rules": rules, "exceptions": exceptions})
+	}
+}
+
+// POST /provider/schedule/recurring - เพิ่มกฎตารางประจำสัปดาห์
+func createRecurringScheduleHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetInt("userID")
+
+		var req recurringRuleRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		r := req.rule()
+		localNow := time.Now().In(loadAvailabilitySettings(ctx, dbPool, userID).location())
+		if err := validateRecurringRule(r, localNow); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		var count int
+		_ = dbPool.QueryRow(ctx, `SELECT COUNT(*) FROM provider_recurring_schedules WHERE provider_id = $1`, userID).Scan(&count)
+		if count >= maxRecurringRules {
+			c.JSON(http.StatusBadRequest, gin.H{"error": errRecurringRuleCount.Error()})
+			return
+		}
+
+		r, err := insertRecurringRule(ctx, dbPool, userID, r)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create recurring schedule"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"message": "Recurring schedule created", "rule": r})
+	}
+}
+
+// PUT /provider/schedule/recurring - แทนที่กฎทั้งหมดในครั้งเดียว (bulk edit)
+func replaceRecurringSchedulesHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetInt("userID")
+
+		var req struct {
+			Rules []recurringRuleRequest `json:"rules" binding:"dive"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if len(req.Rules) > maxRecurringRules {
+			c.JSON(http.StatusBadRequest, gin.H{"error": errRecurringRuleCount.Error()})
+			return
+		}
+		now := time.Now().In(loadAvailabilitySettings(ctx, dbPool, userID).location())
+		newRules := make([]*RecurringScheduleRule, 0, len(req.Rules))
+		for i, item := range req.Rules {
+			r := item.rule()
+			if err := validateRecurringRule(r, now); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "index": i})
+				return
+			}
+			newRules = append(newRules, r)
+		}
+
+		tx, err := dbPool.Begin(ctx)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+			return
+		}
+		defer tx.Rollback(ctx)
+
+		if _, err := tx.Exec(ctx, `DELETE FROM provider_recurring_schedules WHERE provider_id = $1`, userID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to replace recurring schedules"})
+			return
+		}
+		rules := make([]*RecurringScheduleRule, 0, len(newRules))
+		for _, r := range newRules {
+			saved, err := insertRecurringRule(ctx, tx, userID, r)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to replace recurring schedules"})
+				return
+			}
+			rules = append(rules, saved)
+		}
+
+		if err := tx.Commit(ctx); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit recurring schedules"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Recurring schedules replaced", "rules": rules})
+	}
+}
+
+// PUT /provider/schedule/recurring/:ruleId - แก้ไขกฎ (ส่งค่าทั้งหมด)
+func updateRecurringScheduleHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetInt("userID")
+
+		var req recurringRuleRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		r := req.rule()
+		localNow := time.Now().In(loadAvailabilitySettings(ctx, dbPool, userID).location())
+		if err := validateRecurringRule(r, localNow); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		r, err := scanRecurringRule(dbPool.QueryRow(ctx, `
+			UPDATE provider_recurring_schedules
+			SET weekday = $3, start_time = $4::time, end_time = $5::time, location_type = $6, location_province = $7,
+			    effective_from = $8::date, effective_until = $9::date, skip_holidays = $10, is_paused = $11,
+			    notes = $12, updated_at = NOW()
+			WHERE rule_id = $1 AND provider_id = $2
+			RETURNING `+recurringRuleColumns,
+			c.Param("ruleId"), userID, r.Weekday, r.StartTime, r.EndTime, r.LocationType, r.LocationProvince,
+			r.EffectiveFrom, r.EffectiveUntil, r.SkipHolidays, r.IsPaused, r.Notes))
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Recurring schedule not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update recurring schedule"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Recurring schedule updated", "rule": r})
+	}
+}
+
+// PATCH /provider/schedule/recurring/pause - หยุด/เปิดกฎ (ไม่ส่ง rule_ids = ทุกกฎ)
+func pauseRecurringSchedulesHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetInt("userID")
+
+		var req struct {
+			Paused  *bool `json:"paused" binding:"required"`
+			RuleIDs []int `json:"rule_ids"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		tag, err := dbPool.Exec(ctx, `
+			UPDATE provider_recurring_schedules
+			SET is_paused = $2, updated_at = NOW()
+			WHERE provider_id = $1 AND (cardinality($3::int[]) = 0 OR rule_id = ANY($3))
+		`, userID, *req.Paused, append([]int{}, req.RuleIDs...))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update recurring schedules"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Recurring schedules updated", "paused": *req.Paused, "updated": tag.RowsAffected()})
+	}
+}
+
+// DELETE /provider/schedule/recurring/:ruleId
+func deleteRecurringScheduleHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetInt("userID")
+
+		tag, err := dbPool.Exec(ctx, `
+			DELETE FROM provider_recurring_schedules WHERE rule_id = $1 AND provider_id = $2
+		`, c.Param("ruleId"), userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete recurring schedule"})
+			return
+		}
+		if tag.RowsAffected() == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Recurring schedule not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Recurring schedule deleted"})
+	}
+}
+
+// POST /provider/schedule/exceptions - วันลา/วันหยุดส่วนตัว (กฎประจำสัปดาห์ไม่เปิดในช่วงนี้)
+func createScheduleExceptionHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetInt("userID")
+
+		var e ScheduleException
+		if err := c.ShouldBindJSON(&e); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := validateScheduleException(&e); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		err := dbPool.QueryRow(ctx, `
+			INSERT INTO provider_schedule_exceptions (provider_id, start_date, end_date, reason)
+			VALUES ($1, $2::date, $3::date, $4)
+			RETURNING exception_id, provider_id, created_at
+		`, userID, e.StartDate, e.EndDate, e.Reason).Scan(&e.ExceptionID, &e.ProviderID, &e.CreatedAt)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create schedule exception"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"message": "Schedule exception created", "exception": e})
+	}
+}
+
+// DELETE /provider/schedule/exceptions/:exceptionId
+func deleteScheduleExceptionHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetInt("userID")
+
+		tag, err := dbPool.Exec(ctx, `
+			DELETE FROM provider_schedule_exceptions WHERE exception_id = $1 AND provider_id = $2
+		`, c.Param("exceptionId"), userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete schedule exception"})
+			return
+		}
+		if tag.RowsAffected() == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Schedule exception not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Schedule exception deleted"})
+	}
+}
+
+// recurringExpansionRange turns the admin start_date/end_date filters into the window
+// to expand recurring rules over (default 7 วัน, สูงสุด maxAvailabilityRangeDays)
+func recurringExpansionRange(startDate, endDate string, now time.Time) (time.Time, time.Time, error) {
+	if len(startDate) > 10 {
+		startDate = startDate[:10]
+	}
+	if len(endDate) > 10 {
+		endDate = endDate[:10]
+	}
+	// ตัวกรองของ admin ใช้เวลาไทย (timezone ของแพลตฟอร์ม)
+	from, to, err := parseAvailabilityRange(startDate, "", now, bangkokLocation)
+	if err != nil {
+		return from, to, err
+	}
+	if endDate != "" {
+		end, err := time.ParseInLocation("2006-01-02", endDate, bangkokLocation)
+		if err != nil || end.Before(from) {
+			return time.Time{}, time.Time{}, errAvailabilityRange
+		}
+		to = end.AddDate(0, 0, 1)
+		if limit := from.AddDate(0, 0, maxAvailabilityRangeDays); to.After(limit) {
+			to = limit
+		}
+	}
+	return from, to, nil
+}
+
+// appendRecurringScheduleView adds the expanded recurring windows (source = "recurring")
+// to an admin schedule listing and re-sorts it by start time
+func appendRecurringScheduleView(ctx context.Context, dbPool *pgxpool.Pool, schedules []ProviderScheduleWithDetails,
+	providerID int, startDate, endDate, status, province string) ([]ProviderScheduleWithDetails, error) {
+	if status != "" && status != "available" {
+		return schedules, nil
+	}
+	from, to, err := recurringExpansionRange(startDate, endDate, time.Now())
+	if err != nil {
+		return schedules, err
+	}
+	windows, err := loadRecurringWindows(ctx, dbPool, providerID, from, to)
+	if err != nil || len(windows) == 0 {
+		return schedules, err
+	}
+
+	type providerInfo struct {
+		username string
+		phone    *string
+	}
+	ids := make([]int, 0)
+	for _, w := range windows {
+		ids = append(ids, w.Rule.ProviderID)
+	}
+	providers := make(map[int]providerInfo)
+	rows, err := dbPool.Query(ctx, `SELECT user_id, username, phone FROM users WHERE user_id = ANY($1)`, ids)
+	if err != nil {
+		return schedules, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int
+		var p providerInfo
+		if err := rows.Scan(&id, &p.username, &p.phone); err == nil {
+			providers[id] = p
+		}
+	}
+
+	for _, w := range windows {
+		if province != "" && (w.Rule.LocationProvince == nil || *w.Rule.LocationProvince != province) {
+			continue
+		}
+		ruleID := w.Rule.RuleID
+		p := providers[w.Rule.ProviderID]
+		schedules = append(schedules, ProviderScheduleWithDetails{
+			ProviderID:       w.Rule.ProviderID,
+			ProviderUsername: p.username,
+			ProviderPhone:    p.phone,
+			StartTime:        w.Start,
+			EndTime:          w.End,
+			Status:           "available",
+			LocationType:     w.Rule.LocationType,
+			LocationProvince: w.Rule.LocationProvince,
+			Notes:            w.Rule.Notes,
+			CreatedAt:        w.Rule.CreatedAt,
+			UpdatedAt:        w.Rule.UpdatedAt,
+			Source:           "recurring",
+			RecurringRuleID:  &ruleID,
+		})
+	}
+	sort.SliceStable(schedules, func(i, j int) bool { return schedules[i].StartTime.Before(schedules[j].StartTime) })
+	return schedules, nil
+}
@@ -0,0 +1,291 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ================================
+// Recurring Weekly Schedules (ตารางว่างประจำสัปดาห์ → แตกเป็นช่วงเวลาจริงตอน query)
+// ================================
+
+var (
+	errRecurringWeekday   = errors.New("weekday must be 0 (Sunday) to 6 (Saturday)")
+	errRecurringTime      = errors.New("start_time and end_time (HH:MM) are required and must differ")
+	errRecurringLocation  = errors.New("location_type must be Incall, Outcall or Both")
+	errRecurringDates     = errors.New("effective_from/effective_until must be YYYY-MM-DD with until on or after from")
+	errScheduleException  = errors.New("start_date/end_date must be YYYY-MM-DD with end on or after start")
+	errRecurringRuleCount = errors.New("at most 50 recurring rules are allowed")
+)
+
+const maxRecurringRules = 50
+
+// RecurringScheduleRule is a weekly availability window. end_time <= start_time means
+// the window runs past midnight (เช่น 20:00-02:00).
+type RecurringScheduleRule struct {
+	RuleID           int       `json:"rule_id"`
+	ProviderID       int       `json:"provider_id"`
+	Weekday          int       `json:"weekday"`    // 0 = อาทิตย์ ... 6 = เสาร์
+	StartTime        string    `json:"start_time"` // HH:MM (เวลาไทย)
+	EndTime          string    `json:"end_time"`   // HH:MM (เวลาไทย)
+	LocationType     *string   `json:"location_type"`
+	LocationProvince *string   `json:"location_province"`
+	EffectiveFrom    string    `json:"effective_from"`  // YYYY-MM-DD
+	EffectiveUntil   *string   `json:"effective_until"` // YYYY-MM-DD (nil = ไม่มีกำหนด)
+	SkipHolidays     bool      `json:"skip_holidays"`   // ไม่เปิดในวันหยุดจากปฏิทินวันหยุดไทย
+	IsPaused         bool      `json:"is_paused"`
+	Notes            *string   `json:"notes"`
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+// ScheduleException is a date range (วันลา/ไปเที่ยว) when recurring rules don't apply
+type ScheduleException struct {
+	ExceptionID int       `json:"exception_id"`
+	ProviderID  int       `json:"provider_id"`
+	StartDate   string    `json:"start_date"` // YYYY-MM-DD
+	EndDate     string    `json:"end_date"`   // YYYY-MM-DD (รวมวันนี้)
+	Reason      *string   `json:"reason"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// recurringWindow is one expanded occurrence of a rule
+type recurringWindow struct {
+	timeRange
+	Rule RecurringScheduleRule
+}
+
+const recurringRuleColumns = `
+	rule_id, provider_id, weekday, TO_CHAR(start_time, 'HH24:MI'), TO_CHAR(end_time, 'HH24:MI'),
+	location_type, location_province, TO_CHAR(effective_from, 'YYYY-MM-DD'), TO_CHAR(effective_until, 'YYYY-MM-DD'),
+	skip_holidays, is_paused, notes, created_at, updated_at`
+
+func scanRecurringRule(row pgx.Row) (*RecurringScheduleRule, error) {
+	var r RecurringScheduleRule
+	err := row.Scan(&r.RuleID, &r.ProviderID, &r.Weekday, &r.StartTime, &r.EndTime, &r.LocationType, &r.LocationProvince,
+		&r.EffectiveFrom, &r.EffectiveUntil, &r.SkipHolidays, &r.IsPaused, &r.Notes, &r.CreatedAt, &r.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// validateRecurringRule checks a rule and defaults effective_from to today (now in the provider's time zone)
+func validateRecurringRule(r *RecurringScheduleRule, now time.Time) error {
+	if r.Weekday < 0 || r.Weekday > 6 {
+		return errRecurringWeekday
+	}
+	start, okStart := clockMinutes(&r.StartTime)
+	end, okEnd := clockMinutes(&r.EndTime)
+	if !okStart || !okEnd || start == end {
+		return errRecurringTime
+	}
+	if r.LocationType != nil {
+		switch *r.LocationType {
+		case "Incall", "Outcall", "Both":
+		default:
+			return errRecurringLocation
+		}
+	}
+	if r.EffectiveFrom == "" {
+		r.EffectiveFrom = now.Format("2006-01-02") // now อยู่ใน timezone ของ provider แล้ว
+	}
+	from, err := time.Parse("2006-01-02", r.EffectiveFrom)
+	if err != nil {
+		return errRecurringDates
+	}
+	if r.EffectiveUntil != nil {
+		until, err := time.Parse("2006-01-02", *r.EffectiveUntil)
+		if err != nil || until.Before(from) {
+			return errRecurringDates
+		}
+	}
+	return nil
+}
+
+// validateScheduleException checks the date range of an exception
+func validateScheduleException(e *ScheduleException) error {
+	start, err := time.Parse("2006-01-02", e.StartDate)
+	if err != nil {
+		return errScheduleException
+	}
+	end, err := time.Parse("2006-01-02", e.EndDate)
+	if err != nil || end.Before(start) {
+		return errScheduleException
+	}
+	return nil
+}
+
+// expandRecurringRules turns weekly rules into concrete windows overlapping [from, to).
+// A window belongs to the date it starts on: exceptions, holidays and effective dates
+// are checked against that date. Paused rules are skipped. Clock times are wall-clock in loc.
+func expandRecurringRules(rules []RecurringScheduleRule, exceptions []ScheduleException, holidays map[string]bool, from, to time.Time, loc *time.Location) []recurringWindow {
+	windows := make([]recurringWindow, 0)
+	if !to.After(from) {
+		return windows
+	}
+	excluded := func(date string) bool {
+		for _, e := range exceptions {
+			if date >= e.StartDate && date <= e.EndDate {
+				return true
+			}
+		}
+		return false
+	}
+
+	// เริ่มจากวันก่อนหน้า เผื่อช่วงข้ามเที่ยงคืนที่เริ่มเมื่อวาน
+	day := startOfLocalDay(from, loc).AddDate(0, 0, -1)
+	for ; day.Before(to); day = day.AddDate(0, 0, 1) {
+		date := day.Format("2006-01-02")
+		if excluded(date) {
+			continue
+		}
+		for _, r := range rules {
+			if r.IsPaused || r.Weekday != int(day.Weekday()) || date < r.EffectiveFrom ||
+				(r.EffectiveUntil != nil && date > *r.EffectiveUntil) || (r.SkipHolidays && holidays[date]) {
+				continue
+			}
+			startMin, _ := clockMinutes(&r.StartTime)
+			endMin, _ := clockMinutes(&r.EndTime)
+			// time.Date ไม่ใช่ day.Add เพื่อให้วันที่เปลี่ยนเวลา (DST) ยังได้เวลาตามนาฬิกา
+			start := time.Date(day.Year(), day.Month(), day.Day(), startMin/60, startMin%60, 0, 0, loc)
+			end := time.Date(day.Year(), day.Month(), day.Day(), endMin/60, endMin%60, 0, 0, loc)
+			if endMin <= startMin {
+				end = end.AddDate(0, 0, 1) // ข้ามเที่ยงคืน
+			}
+			if end.After(from) && start.Before(to) {
+				windows = append(windows, recurringWindow{timeRange{start, end}, r})
+			}
+		}
+	}
+	return windows
+}
+
+// loadRecurringRules returns the active (not paused) rules of one provider, or of all when providerID is 0
+func loadRecurringRules(ctx context.Context, db availabilityQuerier, providerID int) ([]RecurringScheduleRule, error) {
+	rows, err := db.Query(ctx, `
+		SELECT `+recurringRuleColumns+`
+		FROM provider_recurring_schedules
+		WHERE ($1 = 0 OR provider_id = $1) AND is_paused = false
+		ORDER BY provider_id, weekday, start_time
+	`, providerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := make([]RecurringScheduleRule, 0)
+	for rows.Next() {
+		r, err := scanRecurringRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, *r)
+	}
+	return rules, rows.Err()
+}
+
+// loadScheduleExceptions returns exceptions overlapping [from, to), keyed by provider
+func loadScheduleExceptions(ctx context.Context, db availabilityQuerier, providerID int, from, to time.Time) (map[int][]ScheduleException, error) {
+	rows, err := db.Query(ctx, `
+		SELECT exception_id, provider_id, TO_CHAR(start_date, 'YYYY-MM-DD'), TO_CHAR(end_date, 'YYYY-MM-DD'), reason, created_at
+		FROM provider_schedule_exceptions
+		WHERE ($1 = 0 OR provider_id = $1) AND end_date >= $2::date - 2 AND start_date <= $3::date + 1
+	`, providerID, from.UTC().Format("2006-01-02"), to.UTC().Format("2006-01-02"))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	exceptions := make(map[int][]ScheduleException)
+	for rows.Next() {
+		var e ScheduleException
+		if err := rows.Scan(&e.ExceptionID, &e.ProviderID, &e.StartDate, &e.EndDate, &e.Reason, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		exceptions[e.ProviderID] = append(exceptions[e.ProviderID], e)
+	}
+	return exceptions, rows.Err()
+}
+
+// loadHolidaySet returns the holiday dates (YYYY-MM-DD) around [from, to)
+// (เผื่อวันหน้า/หลัง เพราะวันที่ท้องถิ่นของแต่ละ provider อาจต่างจาก UTC)
+func loadHolidaySet(ctx context.Context, db availabilityQuerier, from, to time.Time) (map[string]bool, error) {
+	rows, err := db.Query(ctx, `
+		SELECT TO_CHAR(holiday_date, 'YYYY-MM-DD') FROM thai_holidays
+		WHERE holiday_date BETWEEN $1::date - 2 AND $2::date + 1
+	`, from.UTC().Format("2006-01-02"), to.UTC().Format("2006-01-02"))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	holidays := make(map[string]bool)
+	for rows.Next() {
+		var date string
+		if err := rows.Scan(&date); err != nil {
+			return nil, err
+		}
+		holidays[date] = true
+	}
+	return holidays, rows.Err()
+}
+
+// loadRecurringWindows expands the recurring rules of one provider (or all when providerID is 0)
+func loadRecurringWindows(ctx context.Context, db availabilityQuerier, providerID int, from, to time.Time) ([]recurringWindow, error) {
+	rules, err := loadRecurringRules(ctx, db, providerID)
+	if err != nil || len(rules) == 0 {
+		return nil, err
+	}
+	exceptions, err := loadScheduleExceptions(ctx, db, providerID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	holidays, err := loadHolidaySet(ctx, db, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	byProvider := make(map[int][]RecurringScheduleRule)
+	order := make([]int, 0)
+	for _, r := range rules {
+		if _, ok := byProvider[r.ProviderID]; !ok {
+			order = append(order, r.ProviderID)
+		}
+		byProvider[r.ProviderID] = append(byProvider[r.ProviderID], r)
+	}
+	timezones, err := loadProviderTimezones(ctx, db, order)
+	if err != nil {
+		return nil, err
+	}
+	windows := make([]recurringWindow, 0)
+	for _, id := range order {
+		windows = append(windows, expandRecurringRules(byProvider[id], exceptions[id], holidays, from, to, timezoneOrDefault(timezones[id]))...)
+	}
+	return windows, nil
+}
+
+// loadProviderTimezones returns the saved time zone of each provider (missing = default)
+func loadProviderTimezones(ctx context.Context, db availabilityQuerier, providerIDs []int) (map[int]string, error) {
+	rows, err := db.Query(ctx, `
+		SELECT provider_id, timezone FROM provider_availability_settings WHERE provider_id = ANY($1)
+	`, providerIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	timezones := make(map[int]string)
+	for rows.Next() {
+		var id int
+		var tz string
+		if err := rows.Scan(&id, &tz); err != nil {
+			return nil, err
+		}
+		timezones[id] = tz
+	}
+	return timezones, rows.Err()
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpandRecurringRules(t *testing.T) {
+	tuesday := RecurringScheduleRule{RuleID: 1, Weekday: 2, StartTime: "10:00", EndTime: "18:00", EffectiveFrom: "2026-10-01"}
+	// 2026-10-18 (อาทิตย์) ถึงก่อน 2026-11-01
+	from, to := bkk(18, 0, 0), bkk(18, 0, 0).AddDate(0, 0, 14)
+
+	t.Run("expands a weekly rule on matching weekdays", func(t *testing.T) {
+		windows := expandRecurringRules([]RecurringScheduleRule{tuesday}, nil, nil, from, to, bangkokLocation)
+		if assert.Len(t, windows, 2) {
+			assert.Equal(t, timeRange{bkk(20, 10, 0), bkk(20, 18, 0)}, windows[0].timeRange)
+			assert.Equal(t, timeRange{bkk(27, 10, 0), bkk(27, 18, 0)}, windows[1].timeRange)
+			assert.Equal(t, 1, windows[1].Rule.RuleID)
+		}
+	})
+
+	t.Run("skips exceptions, holidays, paused rules and dates outside the effective range", func(t *testing.T) {
+		vacation := []ScheduleException{{StartDate: "2026-10-19", EndDate: "2026-10-21"}}
+		assert.Len(t, expandRecurringRules([]RecurringScheduleRule{tuesday}, vacation, nil, from, to, bangkokLocation), 1)
+
+		holidayRule := tuesday
+		holidayRule.SkipHolidays = true
+		holidays := map[string]bool{"2026-10-27": true}
+		assert.Len(t, expandRecurringRules([]RecurringScheduleRule{holidayRule}, nil, holidays, from, to, bangkokLocation), 1)
+		assert.Len(t, expandRecurringRules([]RecurringScheduleRule{tuesday}, nil, holidays, from, to, bangkokLocation), 2)
+
+		paused := tuesday
+		paused.IsPaused = true
+		assert.Empty(t, expandRecurringRules([]RecurringScheduleRule{paused}, nil, nil, from, to, bangkokLocation))
+
+		until := "2026-10-25"
+		ended := tuesday
+		ended.EffectiveUntil = &until
+		assert.Len(t, expandRecurringRules([]RecurringScheduleRule{ended}, nil, nil, from, to, bangkokLocation), 1)
+
+		later := tuesday
+		later.EffectiveFrom = "2026-10-21"
+		assert.Len(t, expandRecurringRules([]RecurringScheduleRule{later}, nil, nil, from, to, bangkokLocation), 1)
+	})
+
+	t.Run("overnight windows run past midnight and are picked up from the previous day", func(t *testing.T) {
+		saturdayNight := RecurringScheduleRule{Weekday: 6, StartTime: "20:00", EndTime: "02:00", EffectiveFrom: "2026-10-01"}
+		// เริ่มคืนวันเสาร์ 17 ต.ค. จบ 02:00 วันอาทิตย์ 18 ต.ค.
+		windows := expandRecurringRules([]RecurringScheduleRule{saturdayNight}, nil, nil, from, bkk(19, 0, 0), bangkokLocation)
+		if assert.Len(t, windows, 1) {
+			assert.Equal(t, timeRange{bkk(17, 20, 0), bkk(18, 2, 0)}, windows[0].timeRange)
+		}
+	})
+}
+
+func TestValidateRecurringRule(t *testing.T) {
+	now := bkk(18, 9, 0)
+
+	r := RecurringScheduleRule{Weekday: 1, StartTime: "09:00", EndTime: "17:00"}
+	assert.NoError(t, validateRecurringRule(&r, now))
+	assert.Equal(t, "2026-10-18", r.EffectiveFrom)
+
+	bad := RecurringScheduleRule{Weekday: 7, StartTime: "09:00", EndTime: "17:00"}
+	assert.ErrorIs(t, validateRecurringRule(&bad, now), errRecurringWeekday)
+
+	bad = RecurringScheduleRule{Weekday: 1, StartTime: "09:00", EndTime: "09:00"}
+	assert.ErrorIs(t, validateRecurringRule(&bad, now), errRecurringTime)
+
+	location := "Home"
+	bad = RecurringScheduleRule{Weekday: 1, StartTime: "09:00", EndTime: "17:00", LocationType: &location}
+	assert.ErrorIs(t, validateRecurringRule(&bad, now), errRecurringLocation)
+
+	until := "2026-10-01"
+	bad = RecurringScheduleRule{Weekday: 1, StartTime: "09:00", EndTime: "17:00", EffectiveFrom: "2026-10-18", EffectiveUntil: &until}
+	assert.ErrorIs(t, validateRecurringRule(&bad, now), errRecurringDates)
+
+	assert.ErrorIs(t, validateScheduleException(&ScheduleException{StartDate: "2026-10-20", EndDate: "2026-10-19"}), errScheduleException)
+}
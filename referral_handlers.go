@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// GET /referrals/me - โค้ด/ลิงก์ชวนเพื่อน + สถิติ + รายชื่อที่ชวน
+func getMyReferralDashboardHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetInt("userID")
+
+		code, err := getOrCreateReferralCode(ctx, dbPool, userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load referral code"})
+			return
+		}
+
+		var stats struct {
+			Total         int     `json:"total"`
+			Pending       int     `json:"pending"`
+			Rewarded      int     `json:"rewarded"`
+			UnderReview   int     `json:"under_review"`
+			Rejected      int     `json:"rejected"`
+			TotalEarned   float64 `json:"total_earned"`
+			ClientsJoined int     `json:"clients_joined"`
+			ProvidersJoin int     `json:"providers_joined"`
+		}
+		err = dbPool.QueryRow(ctx, `
+			SELECT COUNT(*),
+			       COUNT(*) FILTER (WHERE status = 'pending'),
+			       COUNT(*) FILTER (WHERE status = 'rewarded'),
+			       COUNT(*) FILTER (WHERE status = 'flagged'),
+			       COUNT(*) FILTER (WHERE status = 'rejected'),
+			       COALESCE(SUM(referrer_reward) FILTER (WHERE status = 'rewarded'), 0),
+			       COUNT(*) FILTER (WHERE referred_role = 'client'),
+			       COUNT(*) FILTER (WHERE referred_role = 'provider')
+			FROM referrals
+			WHERE referrer_id = $1
+		`, userID).Scan(&stats.Total, &stats.Pending, &stats.Rewarded, &stats.UnderReview, &stats.Rejected,
+			&stats.TotalEarned, &stats.ClientsJoined, &stats.ProvidersJoin)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load referral stats"})
+			return
+		}
+
+		rows, err := dbPool.Query(ctx, `
+			SELECT r.referral_id, u.username, r.referred_role, r.status, r.referrer_reward, r.created_at, r.rewarded_at
+			FROM referrals r
+			JOIN users u ON u.user_id = r.referred_id
+			WHERE r.referrer_id = $1
+			ORDER BY r.created_at DESC
+			LIMIT 100
+		`, userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load referrals"})
+			return
+		}
+		defer rows.Close()
+
+		referrals := make([]gin.H, 0)
+		for rows.Next() {
+			var r Referral
+			var username string
+			if err := rows.Scan(&r.ReferralID, &username, &r.ReferredRole, &r.Status, &r.ReferrerReward, &r.CreatedAt, &r.RewardedAt); err != nil {
+				continue
+			}
+			status := r.Status
+			if status == ReferralFlagged {
+				status = "under_review" // ไม่บอกผู้ชวนว่าถูก flag
+			}
+			referrals = append(referrals, gin.H{
+				"referral_id": r.ReferralID,
+				"username":    maskUsername(username),
+				"role":        r.ReferredRole,
+				"status":      status,
+				"reward":      r.ReferrerReward,
+				"joined_at":   r.CreatedAt,
+				"rewarded_at": r.RewardedAt,
+			})
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"code":      code,
+			"link":      referralLink(code),
+			"rewards":   referralRewardRules,
+			"stats":     stats,
+			"referrals": referrals,
+		})
+	}
+}
+
+// maskUsername keeps the first and last character (เพื่อความเป็นส่วนตัวของผู้ถูกชวน)
+func maskUsername(name string) string {
+	runes := []rune(name)
+	if len(runes) <= 2 {
+		return strings.Repeat("*", len(runes))
+	}
+	return string(runes[0]) + strings.Repeat("*", len(runes)-2) + string(runes[len(runes)-1])
+}
+
+// GET /referrals/code/:code (Public) - ตรวจโค้ดก่อนสมัคร
+func checkReferralCodeHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var username string
+		err := dbPool.QueryRow(ctx, `
+			SELECT u.username FROM referral_codes rc JOIN users u ON u.user_id = rc.user_id
+			WHERE rc.code = UPPER($1)
+		`, strings.TrimSpace(c.Param("code"))).Scan(&username)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"valid": false, "error": "Referral code not found"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"valid": true, "referrer": username})
+	}
+}
+
+// GET /admin/referrals?status=flagged
+func adminGetReferralsHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		status := c.Query("status")
+
+		rows, err := dbPool.Query(ctx, `
+			SELECT `+referralColumns+`
+			FROM referrals
+			WHERE ($1 = '' OR status = $1)
+			ORDER BY created_at DESC
+			LIMIT 200
+		`, status)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch referrals"})
+			return
+		}
+		defer rows.Close()
+
+		referrals := make([]*Referral, 0)
+		for rows.Next() {
+			if r, err := scanReferral(rows); err == nil {
+				referrals = append(referrals, r)
+			}
+		}
+		c.JSON(http.StatusOK, gin.H{"referrals": referrals, "total": len(referrals)})
+	}
+}
+
+// PATCH /admin/referrals/:id - ตรวจ referral ที่ถูก flag (approve = กลับไปรอเงื่อนไข, reject = ไม่จ่าย)
+func adminReviewReferralHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		adminID := c.GetInt("userID")
+
+		var req struct {
+			Action string `json:"action" binding:"required,oneof=approve reject"`
+			Notes  string `json:"notes"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		newStatus := ReferralPending
+		if req.Action == "reject" {
+			newStatus = ReferralRejected
+		}
+
+		r, err := scanReferral(dbPool.QueryRow(ctx, `
+			UPDATE referrals
+			SET status = $2, reviewed_by = $3, review_notes = NULLIF($4, ''), reviewed_at = NOW(), updated_at = NOW()
+			WHERE referral_id = $1 AND status IN ('flagged', 'pending')
+			RETURNING `+referralColumns,
+			c.Param("id"), newStatus, adminID, req.Notes))
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Referral not found or already settled"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update referral"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Referral " + req.Action + "d", "referral": r})
+	}
+}
@@ -0,0 +1,384 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ================================
+// Referral Program (ชวนเพื่อน: โค้ด/ลิงก์ส่วนตัว, รางวัลหลังจองสำเร็จ/อนุมัติ provider)
+// ================================
+
+const (
+	ReferralPending  = "pending"  // รอเงื่อนไข (client จองสำเร็จครั้งแรก / provider ได้รับอนุมัติ)
+	ReferralFlagged  = "flagged"  // สงสัยทุจริต รอ admin ตรวจ
+	ReferralRewarded = "rewarded" // จ่ายรางวัลแล้ว
+	ReferralRejected = "rejected" // admin ปฏิเสธ
+
+	referralCodeLength    = 8
+	referralCodeAlphabet  = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789" // ไม่มี 0/O, 1/I
+	referralIPVelocityMax = 3                                  // สมัครจาก IP เดียวกันเกินนี้ใน 24 ชม. = น่าสงสัย
+	referralCouponDays    = 90
+	referralMinPaidAmount = 300.0 // client ต้องจ่ายจริง (ไม่นับคูปอง/เครดิต) อย่างน้อยเท่านี้ถึงนับว่าจองสำเร็จ
+)
+
+// referralRewardRule is what each side gets once a referral qualifies
+type referralRewardRule struct {
+	ReferrerCredit float64 // เครดิตเข้า credit_balance ผู้ชวน (ใช้จ่ายได้ ถอนไม่ได้)
+	ReferredCoupon float64 // คูปองส่วนลด (fixed) ให้ผู้ถูกชวน
+}
+
+var referralRewardRules = map[string]referralRewardRule{
+	"client":   {ReferrerCredit: 100, ReferredCoupon: 100},
+	"provider": {ReferrerCredit: 300},
+}
+
+var (
+	errInvalidReferralCode = errors.New("referral code not found")
+	errReferralNotPending  = errors.New("referral is not pending")
+)
+
+type Referral struct {
+	ReferralID     int        `json:"referral_id"`
+	ReferrerID     int        `json:"referrer_id"`
+	ReferredID     int        `json:"referred_id"`
+	Code           string     `json:"code"`
+	ReferredRole   string     `json:"referred_role"`
+	Status         string     `json:"status"`
+	FraudReasons   []string   `json:"fraud_reasons"`
+	ReferrerReward float64    `json:"referrer_reward"`
+	ReferredReward float64    `json:"referred_reward"`
+	RewardCouponID *int       `json:"reward_coupon_id,omitempty"`
+	QualifiedAt    *time.Time `json:"qualified_at"`
+	RewardedAt     *time.Time `json:"rewarded_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+const referralColumns = `
+	referral_id, referrer_id, referred_id, code, referred_role, status, fraud_reasons,
+	referrer_reward, referred_reward, reward_coupon_id, qualified_at, rewarded_at, created_at`
+
+func scanReferral(row pgx.Row) (*Referral, error) {
+	var r Referral
+	err := row.Scan(&r.ReferralID, &r.ReferrerID, &r.ReferredID, &r.Code, &r.ReferredRole, &r.Status, &r.FraudReasons,
+		&r.ReferrerReward, &r.ReferredReward, &r.RewardCouponID, &r.QualifiedAt, &r.RewardedAt, &r.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+func randomCode(n int, alphabet string) (string, error) {
+	var sb strings.Builder
+	max := big.NewInt(int64(len(alphabet)))
+	for i := 0; i < n; i++ {
+		idx, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		sb.WriteByte(alphabet[idx.Int64()])
+	}
+	return sb.String(), nil
+}
+
+// getOrCreateReferralCode returns the user's personal code, creating it on first use
+func getOrCreateReferralCode(ctx context.Context, dbPool *pgxpool.Pool, userID int) (string, error) {
+	var code string
+	err := dbPool.QueryRow(ctx, `SELECT code FROM referral_codes WHERE user_id = $1`, userID).Scan(&code)
+	if err == nil {
+		return code, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return "", err
+	}
+
+	for attempt := 0; attempt < 5; attempt++ {
+		code, err = randomCode(referralCodeLength, referralCodeAlphabet)
+		if err != nil {
+			return "", err
+		}
+		err = dbPool.QueryRow(ctx, `
+			INSERT INTO referral_codes (user_id, code) VALUES ($1, $2)
+			ON CONFLICT (user_id) DO UPDATE SET user_id = EXCLUDED.user_id
+			RETURNING code
+		`, userID, code).Scan(&code)
+		if err == nil {
+			return code, nil
+		}
+		if !strings.Contains(err.Error(), "duplicate") {
+			return "", err
+		}
+	}
+	return "", err
+}
+
+func referralLink(code string) string {
+	return "http://localhost:5174/register?ref=" + code
+}
+
+// signupFingerprint is where a registration came from
+type signupFingerprint struct {
+	IP       string
+	DeviceID string
+	Phone    *string
+}
+
+func signupFingerprintFrom(c *gin.Context, phone *string) signupFingerprint {
+	return signupFingerprint{IP: c.ClientIP(), DeviceID: strings.TrimSpace(c.GetHeader("X-Device-ID")), Phone: phone}
+}
+
+// recordSignupFingerprint keeps the registration IP/device for later referral fraud checks
+func recordSignupFingerprint(ctx context.Context, dbPool *pgxpool.Pool, userID int, fp signupFingerprint) {
+	_, err := dbPool.Exec(ctx, `
+		INSERT INTO user_signup_fingerprints (user_id, ip_address, device_id)
+		VALUES ($1, NULLIF($2, ''), NULLIF($3, ''))
+		ON CONFLICT (user_id) DO NOTHING
+	`, userID, fp.IP, fp.DeviceID)
+	if err != nil {
+		log.Printf("Warning: Could not record signup fingerprint for user %d: %v\n", userID, err)
+	}
+}
+
+// referralSignals are the fraud checks between referrer and referred user
+type referralSignals struct {
+	SameIP         bool
+	SameDevice     bool
+	SamePhone      bool
+	RecentSignupIP int // สมัครจาก IP นี้ใน 24 ชม. ที่ผ่านมา
+}
+
+func referralFraudReasons(s referralSignals) []string {
+	reasons := make([]string, 0)
+	if s.SameIP {
+		reasons = append(reasons, "same_ip")
+	}
+	if s.SameDevice {
+		reasons = append(reasons, "same_device")
+	}
+	if s.SamePhone {
+		reasons = append(reasons, "same_phone")
+	}
+	if s.RecentSignupIP > referralIPVelocityMax {
+		reasons = append(reasons, "ip_velocity")
+	}
+	return reasons
+}
+
+// attributeReferral links a new user to the owner of code. Suspicious referrals are stored as
+// flagged so they never pay out without an admin review.
+func attributeReferral(ctx context.Context, dbPool *pgxpool.Pool, referredID int, code, role string, fp signupFingerprint) (*Referral, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	var referrerID int
+	err := dbPool.QueryRow(ctx, `SELECT user_id FROM referral_codes WHERE code = $1`, code).Scan(&referrerID)
+	if errors.Is(err, pgx.ErrNoRows) || referrerID == referredID {
+		return nil, errInvalidReferralCode
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var s referralSignals
+	err = dbPool.QueryRow(ctx, `
+		SELECT
+			COALESCE(f.ip_address = NULLIF($2, ''), false),
+			COALESCE(f.device_id = NULLIF($3, ''), false),
+			COALESCE(u.phone_number = NULLIF($4, ''), false),
+			(SELECT COUNT(*) FROM user_signup_fingerprints
+			 WHERE ip_address = NULLIF($2, '') AND created_at > NOW() - INTERVAL '24 hours')
+		FROM users u
+		LEFT JOIN user_signup_fingerprints f ON f.user_id = u.user_id
+		WHERE u.user_id = $1
+	`, referrerID, fp.IP, fp.DeviceID, derefString(fp.Phone)).Scan(&s.SameIP, &s.SameDevice, &s.SamePhone, &s.RecentSignupIP)
+	if err != nil {
+		return nil, err
+	}
+	reasons := referralFraudReasons(s)
+	status := ReferralPending
+	if len(reasons) > 0 {
+		status = ReferralFlagged
+	}
+
+	return scanReferral(dbPool.QueryRow(ctx, `
+		INSERT INTO referrals (referrer_id, referred_id, code, referred_role, status, fraud_reasons, signup_ip, device_id)
+		VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''), NULLIF($8, ''))
+		RETURNING `+referralColumns,
+		referrerID, referredID, code, role, status, reasons, fp.IP, fp.DeviceID))
+}
+
+func derefString(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// attributeSignupReferral is called by the registration handlers; a bad code never blocks sign-up
+func attributeSignupReferral(ctx context.Context, dbPool *pgxpool.Pool, c *gin.Context, userID int, code *string, role string, phone *string) bool {
+	fp := signupFingerprintFrom(c, phone)
+	recordSignupFingerprint(ctx, dbPool, userID, fp)
+
+	refCode := c.Query("ref")
+	if code != nil && *code != "" {
+		refCode = *code
+	}
+	if refCode == "" {
+		return false
+	}
+	r, err := attributeReferral(ctx, dbPool, userID, refCode, role, fp)
+	if err != nil {
+		log.Printf("Warning: Referral code %q not applied for user %d: %v\n", refCode, userID, err)
+		return false
+	}
+	if r.Status == ReferralFlagged {
+		log.Printf("⚠️  Referral #%d flagged: %v\n", r.ReferralID, r.FraudReasons)
+	}
+	return true
+}
+
+// --- Rewards ---
+
+// rewardReferral pays a qualified referral: non-withdrawable wallet credit for the referrer and
+// a personal coupon for a referred client. The referral row is locked so it can only pay once.
+func rewardReferral(ctx context.Context, tx pgx.Tx, referralID int) (*Referral, error) {
+	r, err := scanReferral(tx.QueryRow(ctx, `SELECT `+referralColumns+` FROM referrals WHERE referral_id = $1 FOR UPDATE`, referralID))
+	if err != nil {
+		return nil, err
+	}
+	if r.Status != ReferralPending {
+		return nil, errReferralNotPending
+	}
+	rule := referralRewardRules[r.ReferredRole]
+
+	if rule.ReferrerCredit > 0 {
+		if err := creditWalletCredit(ctx, tx, r.ReferrerID, rule.ReferrerCredit); err != nil {
+			return nil, err
+		}
+		_, err = tx.Exec(ctx, `
+			INSERT INTO transactions (user_id, related_user_id, type, status, amount, net_amount, description, processed_at)
+			VALUES ($1, $2, 'referral_reward', 'completed', $3, $3, $4, NOW())
+		`, r.ReferrerID, r.ReferredID, rule.ReferrerCredit, fmt.Sprintf("Referral reward (referral #%d)", r.ReferralID))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var couponID *int
+	if rule.ReferredCoupon > 0 {
+		code, err := randomCode(referralCodeLength, referralCodeAlphabet)
+		if err != nil {
+			return nil, err
+		}
+		var id int
+		err = tx.QueryRow(ctx, `
+			INSERT INTO coupons (code, discount_type, discount_value, valid_from, valid_until, usage_limit,
+			                     created_by, assigned_user_id)
+			VALUES ($1, 'fixed', $2, NOW(), NOW() + make_interval(days => $3), 1, $4, $4)
+			RETURNING coupon_id
+		`, "REF"+code, rule.ReferredCoupon, referralCouponDays, r.ReferredID).Scan(&id)
+		if err != nil {
+			return nil, err
+		}
+		couponID = &id
+	}
+
+	return scanReferral(tx.QueryRow(ctx, `
+		UPDATE referrals
+		SET status = 'rewarded', referrer_reward = $2, referred_reward = $3, reward_coupon_id = $4,
+		    qualified_at = COALESCE(qualified_at, NOW()), rewarded_at = NOW(), updated_at = NOW()
+		WHERE referral_id = $1
+		RETURNING `+referralColumns,
+		referralID, rule.ReferrerCredit, rule.ReferredCoupon, couponID))
+}
+
+func notifyReferralRewarded(r *Referral) {
+	if r.ReferrerReward > 0 {
+		CreateNotification(r.ReferrerID, "referral_reward",
+			fmt.Sprintf("เพื่อนที่คุณชวนใช้งานสำเร็จแล้ว! รับเครดิต ฿%s เข้า wallet (ใช้จองได้ ถอนไม่ได้)", formatBaht(r.ReferrerReward)),
+			map[string]interface{}{"referral_id": r.ReferralID, "amount": r.ReferrerReward})
+	}
+	if r.RewardCouponID != nil {
+		CreateNotification(r.ReferredID, "referral_welcome_coupon",
+			fmt.Sprintf("ขอบคุณที่ใช้บริการ! รับคูปองส่วนลด ฿%s สำหรับการจองครั้งถัดไป (ดูได้ที่คูปองของฉัน)", formatBaht(r.ReferredReward)),
+			map[string]interface{}{"referral_id": r.ReferralID, "coupon_id": *r.RewardCouponID})
+	}
+}
+
+// runReferralRewards pays pending referrals whose referred client finished a booking with a
+// captured, unreversed payment of at least referralMinPaidAmount (excluding what was paid from
+// wallet credit), or whose referred provider was approved
+func runReferralRewards(ctx context.Context, dbPool *pgxpool.Pool) error {
+	rows, err := dbPool.Query(ctx, `
+		SELECT r.referral_id
+		FROM referrals r
+		JOIN users u ON u.user_id = r.referred_id
+		WHERE r.status = 'pending'
+		  AND (
+			(r.referred_role = 'client' AND EXISTS (
+				SELECT 1 FROM bookings b
+				WHERE b.client_id = r.referred_id AND b.status IN ('completed', 'funds_released')
+				  AND NOT EXISTS (
+					SELECT 1 FROM transactions t
+					WHERE t.booking_id = b.booking_id AND t.type::text = 'booking_payment_reversal'
+				  )
+				  AND (
+					SELECT COALESCE(SUM(t.amount), 0) FROM transactions t
+					WHERE t.booking_id = b.booking_id AND t.type::text = 'booking_payment' AND t.status::text = 'completed'
+				  ) - (
+					SELECT COALESCE(SUM(t.credit_amount), 0) FROM transactions t
+					WHERE t.booking_id = b.booking_id AND t.type::text = 'wallet_payment' AND t.status::text = 'completed'
+				  ) >= $1
+			))
+			OR (r.referred_role = 'provider' AND u.provider_verification_status = 'approved')
+		  )
+		ORDER BY r.referral_id
+		LIMIT 200
+	`, referralMinPaidAmount)
+	if err != nil {
+		return err
+	}
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	rows.Close()
+
+	for _, id := range ids {
+		tx, err := dbPool.Begin(ctx)
+		if err != nil {
+			return err
+		}
+		r, err := rewardReferral(ctx, tx, id)
+		if err == nil {
+			err = tx.Commit(ctx)
+		}
+		tx.Rollback(ctx)
+		if err != nil {
+			if !errors.Is(err, errReferralNotPending) {
+				log.Printf("⚠️  Referral #%d reward failed: %v", id, err)
+			}
+			continue
+		}
+		notifyReferralRewarded(r)
+	}
+	return nil
+}
+
+// startReferralRewardScheduler runs runReferralRewards every 30 minutes
+func startReferralRewardScheduler(dbPool *pgxpool.Pool, ctx context.Context) {
+	runPeriodically(ctx, "referral rewards", 30*time.Minute, func(ctx context.Context) error {
+		return runReferralRewards(ctx, dbPool)
+	})
+}
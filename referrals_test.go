@@ -0,0 +1,43 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test which signup signals flag a referral for review
+func TestReferralFraudReasons(t *testing.T) {
+	t.Run("Clean Signup", func(t *testing.T) {
+		assert.Empty(t, referralFraudReasons(referralSignals{RecentSignupIP: 1}))
+	})
+
+	t.Run("Same Device And Phone", func(t *testing.T) {
+		reasons := referralFraudReasons(referralSignals{SameDevice: true, SamePhone: true})
+		assert.Equal(t, []string{"same_device", "same_phone"}, reasons)
+	})
+
+	t.Run("IP Velocity", func(t *testing.T) {
+		assert.Empty(t, referralFraudReasons(referralSignals{RecentSignupIP: referralIPVelocityMax}))
+		assert.Equal(t, []string{"ip_velocity"}, referralFraudReasons(referralSignals{RecentSignupIP: referralIPVelocityMax + 1}))
+	})
+}
+
+func TestRandomReferralCode(t *testing.T) {
+	code, err := randomCode(referralCodeLength, referralCodeAlphabet)
+	assert.NoError(t, err)
+	assert.Len(t, code, referralCodeLength)
+	for _, ch := range code {
+		assert.True(t, strings.ContainsRune(referralCodeAlphabet, ch))
+	}
+}
+
+func TestReferralRewardRules(t *testing.T) {
+	assert.Greater(t, referralRewardRules["client"].ReferrerCredit, 0.0)
+	assert.Greater(t, referralRewardRules["client"].ReferredCoupon, 0.0)
+	assert.Greater(t, referralRewardRules["provider"].ReferrerCredit, 0.0)
+	// รางวัลต้องไม่คุ้มกว่ายอดที่ต้องจ่ายจริงเพื่อให้ได้รางวัล
+	assert.Greater(t, referralMinPaidAmount, referralRewardRules["client"].ReferrerCredit)
+	assert.Equal(t, "a***e", maskUsername("alice"))
+}
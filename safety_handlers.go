@@ -275,9 +275,10 @@ func checkInHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
 		var providerID, clientID int
 		var duration int
 		err := dbPool.QueryRow(ctx, `
-			SELECT b.provider_id, b.client_id, sp.duration
+			SELECT b.provider_id, b.client_id, COALESCE(bs.package_duration, sp.duration)
 			FROM bookings b
 			JOIN service_packages sp ON b.package_id = sp.package_id
+			LEFT JOIN booking_snapshots bs ON bs.booking_id = b.booking_id
 			WHERE b.booking_id = $1 AND b.status = 'confirmed'
 		`, input.BookingID).Scan(&providerID, &clientID, &duration)
 
@@ -701,11 +702,36 @@ func purchaseGalleryAccessHandler(dbPool *pgxpool.Pool, ctx context.Context) gin
 			return
 		}
 
-		// TODO: Process payment via Stripe
-		// For now, just grant access
+		fromWallet, remaining, err := planWalletPayment(price, getWalletSpendableBalance(ctx, dbPool, viewerID), input.walletPaymentOption, 0)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		// ยังไม่มีการตัดบัตรสำหรับ gallery → ให้สิทธิ์ได้เฉพาะเมื่อจ่ายจาก wallet ครบ
+		if remaining > 0 {
+			c.JSON(http.StatusPaymentRequired, gin.H{"error": errCardPaymentUnavailable.Error(), "amount": price})
+			return
+		}
+
+		tx, err := dbPool.Begin(ctx)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to grant access"})
+			return
+		}
+		defer tx.Rollback(ctx)
+
+		// ส่วนที่จ่ายจาก wallet ตัดทันที
+		if fromWallet > 0 {
+			_, err = payFromWallet(ctx, tx, viewerID, fromWallet, nil,
+				fmt.Sprintf("Wallet payment for private gallery of provider #%d (%s)", input.ProviderID, input.AccessType))
+			if err != nil {
+				c.JSON(http.StatusPaymentRequired, gin.H{"error": "Insufficient wallet balance"})
+				return
+			}
+		}
 
 		var accessID int
-		err = dbPool.QueryRow(ctx, `
+		err = tx.QueryRow(ctx, `
 			INSERT INTO private_gallery_access (gallery_owner_id, viewer_id, access_type, expires_at)
 			VALUES ($1, $2, $3, $4)
 			ON CONFLICT (gallery_owner_id, viewer_id) DO UPDATE SET
@@ -713,16 +739,30 @@ func purchaseGalleryAccessHandler(dbPool *pgxpool.Pool, ctx context.Context) gin
 			RETURNING access_id
 		`, input.ProviderID, viewerID, input.AccessType, expiresAt).Scan(&accessID)
 
-		if err != nil {
+		if err != nil || tx.Commit(ctx) != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to grant access"})
 			return
 		}
 
+		// access_id is reused on renewal, so each purchase gets its own receipt ref
+		paidAt := time.Now()
+		issueReceiptQuietly(ctx, dbPool, receiptSource{
+			SourceType:    ReceiptSourceGallery,
+			SourceRef:     fmt.Sprintf("%d-%d", accessID, paidAt.Unix()),
+			BuyerID:       viewerID.(int),
+			ProviderID:    &input.ProviderID,
+			Description:   fmt.Sprintf("Private gallery access (%s)", input.AccessType),
+			AmountPaid:    price,
+			PaymentMethod: "wallet",
+			PaidAt:        paidAt,
+		})
+
 		c.JSON(http.StatusCreated, gin.H{
 			"access_id":   accessID,
 			"access_type": input.AccessType,
 			"expires_at":  expiresAt,
 			"price":       price,
+			"wallet_paid": fromWallet,
 			"message":     "Gallery access granted successfully",
 		})
 	}
@@ -134,17 +134,24 @@ type CancellationPolicy struct {
 }
 
 type CancellationFee struct {
-	FeeID         int        `json:"fee_id"`
-	BookingID     int        `json:"booking_id"`
-	CancelledBy   int        `json:"cancelled_by"` // user_id ที่ยกเลิก
-	FeeAmount     float64    `json:"fee_amount"`
-	FeePercentage float64    `json:"fee_percentage"`
-	Status        string     `json:"status"` // pending, paid, waived
-	PaidAt        *time.Time `json:"paid_at"`
-	WaivedAt      *time.Time `json:"waived_at"`
-	WaivedBy      *int       `json:"waived_by"` // admin ที่ยกเว้น
-	WaiverReason  *string    `json:"waiver_reason"`
-	CreatedAt     time.Time  `json:"created_at"`
+	FeeID              int        `json:"fee_id"`
+	BookingID          int        `json:"booking_id"`
+	CancelledBy        int        `json:"cancelled_by"` // user_id ที่ยกเลิก
+	ClientID           int        `json:"client_id"`
+	ProviderID         int        `json:"provider_id"`
+	FeeAmount          float64    `json:"fee_amount"`
+	FeePercentage      float64    `json:"fee_percentage"`
+	AmountPaid         float64    `json:"amount_paid"`
+	Outstanding        float64    `json:"outstanding"` // ยอดค้างชำระ
+	Status             string     `json:"status"`      // pending (ค้างชำระ), paid, waived
+	ProviderCredited   float64    `json:"provider_credited"`
+	PlatformCommission float64    `json:"platform_commission"`
+	LastChargeError    *string    `json:"last_charge_error,omitempty"`
+	PaidAt             *time.Time `json:"paid_at"`
+	WaivedAt           *time.Time `json:"waived_at"`
+	WaivedBy           *int       `json:"waived_by"` // admin ที่ยกเว้น
+	WaiverReason       *string    `json:"waiver_reason"`
+	CreatedAt          time.Time  `json:"created_at"`
 }
 
 // ================================
@@ -192,16 +199,19 @@ type Coupon struct {
 	IsActive         bool      `json:"is_active"`
 	CreatedBy        int       `json:"created_by"`  // admin/provider
 	ProviderID       *int      `json:"provider_id"` // null = platform-wide
+	Stackable        bool      `json:"stackable"`
 	CreatedAt        time.Time `json:"created_at"`
 }
 
 type CouponUsage struct {
-	UsageID        int       `json:"usage_id"`
-	CouponID       int       `json:"coupon_id"`
-	UserID         int       `json:"user_id"`
-	BookingID      int       `json:"booking_id"`
-	DiscountAmount float64   `json:"discount_amount"`
-	UsedAt         time.Time `json:"used_at"`
+	UsageID        int        `json:"usage_id"`
+	CouponID       int        `json:"coupon_id"`
+	UserID         int        `json:"user_id"`
+	BookingID      int        `json:"booking_id"`
+	DiscountAmount float64    `json:"discount_amount"`
+	Status         string     `json:"status"` // applied, released
+	UsedAt         time.Time  `json:"used_at"`
+	ReleasedAt     *time.Time `json:"released_at"`
 }
 
 // ================================
@@ -262,6 +272,7 @@ type PrivateGallerySettingsRequest struct {
 type PurchaseGalleryRequest struct {
 	ProviderID int    `json:"provider_id" binding:"required"`
 	AccessType string `json:"access_type" binding:"required"` // subscription, one_time
+	walletPaymentOption
 }
 
 // Deposit
@@ -281,6 +292,7 @@ type CancellationPolicyRequest struct {
 // Boost
 type PurchaseBoostRequest struct {
 	PackageID int `json:"package_id" binding:"required"`
+	walletPaymentOption
 }
 
 // Coupon
@@ -293,6 +305,7 @@ type CreateCouponRequest struct {
 	ValidFrom        string   `json:"valid_from" binding:"required"`
 	ValidUntil       string   `json:"valid_until" binding:"required"`
 	UsageLimit       *int     `json:"usage_limit"`
+	Stackable        bool     `json:"stackable"` // ใช้ร่วมกับคูปอง/แคมเปญอื่นที่ stackable ได้
 }
 
 type ApplyCouponRequest struct {
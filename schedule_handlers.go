@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"strconv"
 	"time"
@@ -21,8 +22,8 @@ func createScheduleHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.Handle
 		}
 
 		var req struct {
-			StartTime        string   `json:"start_time" binding:"required"` // ISO 8601
-			EndTime          string   `json:"end_time" binding:"required"`   // ISO 8601
+			StartTime        string   `json:"start_time" binding:"required"` // ISO 8601 (ไม่มี offset = timezone ของ provider)
+			EndTime          string   `json:"end_time" binding:"required"`   // ISO 8601 (ไม่มี offset = timezone ของ provider)
 			Status           string   `json:"status"`                        // available, blocked
 			LocationType     *string  `json:"location_type"`                 // Incall, Outcall, Both
 			LocationAddress  *string  `json:"location_address"`
@@ -39,13 +40,14 @@ func createScheduleHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.Handle
 		}
 
 		// Parse time
-		startTime, err := time.Parse(time.RFC3339, req.StartTime)
+		loc := loadAvailabilitySettings(ctx, dbPool, userID.(int)).location()
+		startTime, err := parseClientTime(req.StartTime, loc)
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid start_time format (use ISO 8601)"})
 			return
 		}
 
-		endTime, err := time.Parse(time.RFC3339, req.EndTime)
+		endTime, err := parseClientTime(req.EndTime, loc)
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid end_time format (use ISO 8601)"})
 			return
@@ -72,6 +74,7 @@ func createScheduleHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.Handle
 		err = dbPool.QueryRow(ctx, `
 			SELECT COUNT(*) FROM provider_schedules
 			WHERE provider_id = $1
+			AND import_id IS NULL -- block จากปฏิทินภายนอกซ้อนได้ (หักออกตอนคำนวณเวลาว่าง)
 			AND (
 				(start_time <= $2 AND end_time > $2) OR -- New start falls within existing
 				(start_time < $3 AND end_time >= $3) OR -- New end falls within existing
@@ -124,10 +127,12 @@ func getMySchedulesHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.Handle
 		}
 
 		// Query parameters
-		startDate := c.Query("start_date") // YYYY-MM-DD
-		endDate := c.Query("end_date")     // YYYY-MM-DD
+		startDate := c.Query("start_date") // YYYY-MM-DD (timezone ของ provider) หรือ RFC3339
+		endDate := c.Query("end_date")     // YYYY-MM-DD (รวมทั้งวัน) หรือ RFC3339
 		status := c.Query("status")        // available, booked, blocked
 
+		loc := loadAvailabilitySettings(ctx, dbPool, userID.(int)).location()
+
 		query := `
 			SELECT 
 				schedule_id, provider_id, booking_id, start_time, end_time, status,
@@ -141,13 +146,23 @@ func getMySchedulesHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.Handle
 
 		// Filter by date range
 		if startDate != "" {
+			from, err := parseDateParam(startDate, loc, false)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
 			query += " AND start_time >= $" + strconv.Itoa(argCounter)
-			args = append(args, startDate)
+			args = append(args, from)
 			argCounter++
 		}
 		if endDate != "" {
+			to, err := parseDateParam(endDate, loc, true)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
 			query += " AND end_time <= $" + strconv.Itoa(argCounter)
-			args = append(args, endDate)
+			args = append(args, to)
 			argCounter++
 		}
 
@@ -177,12 +192,14 @@ func getMySchedulesHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.Handle
 			if err != nil {
 				continue
 			}
+			s.StartTime, s.EndTime = s.StartTime.In(loc), s.EndTime.In(loc)
 			schedules = append(schedules, s)
 		}
 
 		c.JSON(http.StatusOK, gin.H{
 			"schedules": schedules,
 			"total":     len(schedules),
+			"timezone":  loc.String(),
 		})
 	}
 }
@@ -223,9 +240,10 @@ func updateScheduleHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.Handle
 		// Verify ownership
 		var ownerID int
 		var currentStatus string
+		var importID *int
 		err := dbPool.QueryRow(ctx, `
-			SELECT provider_id, status FROM provider_schedules WHERE schedule_id = $1
-		`, scheduleID).Scan(&ownerID, &currentStatus)
+			SELECT provider_id, status, import_id FROM provider_schedules WHERE schedule_id = $1
+		`, scheduleID).Scan(&ownerID, &currentStatus, &importID)
 
 		if err != nil {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Schedule not found"})
@@ -237,6 +255,12 @@ func updateScheduleHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.Handle
 			return
 		}
 
+		// block จากปฏิทินภายนอกแก้ที่ต้นทางหรือยกเลิกการเชื่อมแทน
+		if importID != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Imported calendar blocks are managed by their calendar import"})
+			return
+		}
+
 		// Cannot update booked schedules
 		if currentStatus == "booked" {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot update booked schedule. Please cancel booking first."})
@@ -249,8 +273,10 @@ func updateScheduleHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.Handle
 		argCounter := 1
 		updates := []string{}
 
+		loc := loadAvailabilitySettings(ctx, dbPool, ownerID).location()
+		var startTime, endTime time.Time
 		if req.StartTime != nil {
-			startTime, err := time.Parse(time.RFC3339, *req.StartTime)
+			startTime, err = parseClientTime(*req.StartTime, loc)
 			if err != nil {
 				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid start_time format"})
 				return
@@ -261,7 +287,7 @@ func updateScheduleHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.Handle
 		}
 
 		if req.EndTime != nil {
-			endTime, err := time.Parse(time.RFC3339, *req.EndTime)
+			endTime, err = parseClientTime(*req.EndTime, loc)
 			if err != nil {
 				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid end_time format"})
 				return
@@ -346,10 +372,10 @@ func updateScheduleHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.Handle
 			newEnd := currentEnd
 
 			if req.StartTime != nil {
-				newStart, _ = time.Parse(time.RFC3339, *req.StartTime)
+				newStart = startTime
 			}
 			if req.EndTime != nil {
-				newEnd, _ = time.Parse(time.RFC3339, *req.EndTime)
+				newEnd = endTime
 			}
 
 			// ตรวจสอบ overlap (ยกเว้น schedule ปัจจุบัน)
@@ -358,6 +384,7 @@ func updateScheduleHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.Handle
 				SELECT COUNT(*) FROM provider_schedules
 				WHERE provider_id = $1
 				AND schedule_id != $2
+				AND import_id IS NULL
 				AND (
 					(start_time <= $3 AND end_time > $3) OR
 					(start_time < $4 AND end_time >= $4) OR
@@ -414,9 +441,10 @@ func deleteScheduleHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.Handle
 		// Verify ownership and status
 		var ownerID int
 		var status string
+		var importID *int
 		err := dbPool.QueryRow(ctx, `
-			SELECT provider_id, status FROM provider_schedules WHERE schedule_id = $1
-		`, scheduleID).Scan(&ownerID, &status)
+			SELECT provider_id, status, import_id FROM provider_schedules WHERE schedule_id = $1
+		`, scheduleID).Scan(&ownerID, &status, &importID)
 
 		if err != nil {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Schedule not found"})
@@ -428,6 +456,12 @@ func deleteScheduleHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.Handle
 			return
 		}
 
+		// block จากปฏิทินภายนอกแก้ที่ต้นทางหรือยกเลิกการเชื่อมแทน
+		if importID != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Imported calendar blocks are managed by their calendar import"})
+			return
+		}
+
 		// Cannot delete booked schedules
 		if status == "booked" {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot delete booked schedule. Please cancel booking first."})
@@ -482,14 +516,25 @@ func getProviderScheduleAdminHandler(dbPool *pgxpool.Pool, ctx context.Context)
 		args := []interface{}{providerID}
 		argCounter := 2
 
+		// ตัวกรองของ admin เป็นวันที่ตามเวลาไทย
 		if startDate != "" {
+			from, err := parseDateParam(startDate, bangkokLocation, false)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
 			query += " AND s.start_time >= $" + strconv.Itoa(argCounter)
-			args = append(args, startDate)
+			args = append(args, from)
 			argCounter++
 		}
 		if endDate != "" {
+			to, err := parseDateParam(endDate, bangkokLocation, true)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
 			query += " AND s.end_time <= $" + strconv.Itoa(argCounter)
-			args = append(args, endDate)
+			args = append(args, to)
 			argCounter++
 		}
 		if status != "" {
@@ -519,9 +564,24 @@ func getProviderScheduleAdminHandler(dbPool *pgxpool.Pool, ctx context.Context)
 			if err != nil {
 				continue
 			}
+			s.Source = "schedule"
 			schedules = append(schedules, s)
 		}
 
+		// รวมช่วงเวลาที่แตกจากกฎประจำสัปดาห์
+		providerIDInt, _ := strconv.Atoi(providerID)
+		if providerIDInt > 0 {
+			schedules, err = appendRecurringScheduleView(ctx, dbPool, schedules, providerIDInt, startDate, endDate, status, "")
+			if err != nil {
+				if errors.Is(err, errAvailabilityRange) {
+					c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+					return
+				}
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to expand recurring schedules"})
+				return
+			}
+		}
+
 		c.JSON(http.StatusOK, gin.H{
 			"provider_id": providerID,
 			"schedules":   schedules,
@@ -562,14 +622,25 @@ func getAllProvidersScheduleAdminHandler(dbPool *pgxpool.Pool, ctx context.Conte
 		args := []interface{}{}
 		argCounter := 1
 
+		// ตัวกรองของ admin เป็นวันที่ตามเวลาไทย
 		if startDate != "" {
+			from, err := parseDateParam(startDate, bangkokLocation, false)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
 			query += " AND s.start_time >= $" + strconv.Itoa(argCounter)
-			args = append(args, startDate)
+			args = append(args, from)
 			argCounter++
 		}
 		if endDate != "" {
+			to, err := parseDateParam(endDate, bangkokLocation, true)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
 			query += " AND s.end_time <= $" + strconv.Itoa(argCounter)
-			args = append(args, endDate)
+			args = append(args, to)
 			argCounter++
 		}
 		if status != "" {
@@ -604,9 +675,21 @@ func getAllProvidersScheduleAdminHandler(dbPool *pgxpool.Pool, ctx context.Conte
 			if err != nil {
 				continue
 			}
+			s.Source = "schedule"
 			schedules = append(schedules, s)
 		}
 
+		// รวมช่วงเวลาที่แตกจากกฎประจำสัปดาห์ของทุก Provider
+		schedules, err = appendRecurringScheduleView(ctx, dbPool, schedules, 0, startDate, endDate, status, province)
+		if err != nil {
+			if errors.Is(err, errAvailabilityRange) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to expand recurring schedules"})
+			return
+		}
+
 		c.JSON(http.StatusOK, gin.H{
 			"schedules": schedules,
 			"total":     len(schedules),
@@ -0,0 +1,356 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// ================================
+// Payment Slip Verification
+// ================================
+
+// Slip match results (payment_slips.match_status)
+const (
+	SlipStatusMatched  = "matched"  // ตรงกับ payment → ยืนยันอัตโนมัติ
+	SlipStatusReview   = "review"   // ไม่แน่ใจ → ส่งเข้าคิวให้ admin ตรวจ
+	SlipStatusRejected = "rejected" // ใช้ไม่ได้ (สลิปซ้ำ / สลิปเก่ากว่า payment)
+	SlipStatusApproved = "approved" // admin อนุมัติจากคิว
+)
+
+// SlipData is what a verifier could extract from a transfer slip
+type SlipData struct {
+	Amount         float64    `json:"amount"`
+	TransactionRef string     `json:"transaction_ref"`
+	TransferredAt  *time.Time `json:"transferred_at"`
+	SendingBank    string     `json:"sending_bank,omitempty"`
+	Verified       bool       `json:"verified"` // true = ยอด/เวลา/ref ยืนยันกับธนาคารหรือ slip API แล้วเท่านั้น
+	Verifier       string     `json:"verifier"`
+}
+
+// SlipInput is the uploaded slip plus whatever the client app could read from it
+type SlipInput struct {
+	Image          []byte
+	QRPayload      string     // mini-QR บนสลิป (ถอดรหัสจากแอปฝั่ง client)
+	DeclaredAmount float64    // ยอดที่ลูกค้าระบุ
+	DeclaredAt     *time.Time // เวลาโอนที่ลูกค้าระบุ
+}
+
+// SlipVerifier extracts amount, timestamp and transaction reference from a slip
+type SlipVerifier interface {
+	Name() string
+	VerifySlip(ctx context.Context, input SlipInput) (*SlipData, error)
+}
+
+// newSlipVerifierFromEnv picks the HTTP verifier when SLIP_VERIFY_API_URL is set,
+// otherwise falls back to the local QR-payload decoder
+func newSlipVerifierFromEnv() SlipVerifier {
+	apiURL := os.Getenv("SLIP_VERIFY_API_URL")
+	if apiURL == "" {
+		return localSlipVerifier{}
+	}
+	return &httpSlipVerifier{
+		apiURL:   apiURL,
+		apiKey:   os.Getenv("SLIP_VERIFY_API_KEY"),
+		provider: os.Getenv("SLIP_VERIFY_PROVIDER"),
+		client:   &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+// --- Local verifier (rule-based, reads the bank's slip mini-QR) ---
+
+type localSlipVerifier struct{}
+
+func (localSlipVerifier) Name() string { return "local" }
+
+// VerifySlip decodes the slip mini-QR for the transaction reference and sending bank.
+// The mini-QR carries no amount or time, so those come from what the client declared.
+// The result is never verified: the CRC16 is public and anyone can build a valid QR,
+// so without a bank/API check every slip goes to the admin review queue.
+func (localSlipVerifier) VerifySlip(_ context.Context, input SlipInput) (*SlipData, error) {
+	data := &SlipData{
+		Amount:        input.DeclaredAmount,
+		TransferredAt: input.DeclaredAt,
+		Verifier:      "local",
+	}
+	if input.QRPayload == "" {
+		return data, nil
+	}
+
+	slipQR, err := decodeThaiSlipQR(input.QRPayload)
+	if err != nil {
+		return data, nil // อ่าน QR ไม่ได้ → ให้ admin ตรวจ
+	}
+	data.TransactionRef = slipQR.TransactionRef
+	data.SendingBank = slipQR.SendingBank
+	return data, nil
+}
+
+// ThaiSlipQR is the content of the verification mini-QR printed on Thai bank slips
+type ThaiSlipQR struct {
+	APIID          string
+	SendingBank    string
+	TransactionRef string
+	CountryCode    string
+	ChecksumValid  bool
+}
+
+// decodeThaiSlipQR parses the EMVCo-style TLV payload of a slip mini-QR:
+// tag 00 (00 = API ID, 01 = sending bank code, 02 = transaction ref), tag 51 = country, tag 91 = CRC
+func decodeThaiSlipQR(payload string) (*ThaiSlipQR, error) {
+	fields, err := parseEMVTLV(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	inner, ok := fields["00"]
+	if !ok {
+		return nil, errors.New("slip QR missing tag 00")
+	}
+	sub, err := parseEMVTLV(inner)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ThaiSlipQR{
+		APIID:          sub["00"],
+		SendingBank:    sub["01"],
+		TransactionRef: sub["02"],
+		CountryCode:    fields["51"],
+	}
+	if result.TransactionRef == "" {
+		return nil, errors.New("slip QR missing transaction reference")
+	}
+
+	if crc, ok := fields["91"]; ok {
+		idx := strings.LastIndex(payload, "9104"+crc)
+		result.ChecksumValid = idx >= 0 && strings.EqualFold(calculateCRC16(payload[:idx+4]), crc)
+	}
+	return result, nil
+}
+
+// parseEMVTLV splits a TLV string (2-digit tag, 2-digit length) into a tag map
+func parseEMVTLV(payload string) (map[string]string, error) {
+	fields := make(map[string]string)
+	for i := 0; i < len(payload); {
+		if i+4 > len(payload) {
+			return nil, fmt.Errorf("truncated TLV header at offset %d", i)
+		}
+		tag := payload[i : i+2]
+		var length int
+		if _, err := fmt.Sscanf(payload[i+2:i+4], "%02d", &length); err != nil {
+			return nil, fmt.Errorf("invalid TLV length for tag %s", tag)
+		}
+		if i+4+length > len(payload) {
+			return nil, fmt.Errorf("TLV value for tag %s overruns payload", tag)
+		}
+		fields[tag] = payload[i+4 : i+4+length]
+		i += 4 + length
+	}
+	return fields, nil
+}
+
+// --- HTTP verifier (third-party slip verification APIs) ---
+
+type httpSlipVerifier struct {
+	apiURL   string
+	apiKey   string
+	provider string // "slipok", "easyslip" หรือว่าง (generic)
+	client   *http.Client
+}
+
+func (v *httpSlipVerifier) Name() string {
+	if v.provider == "" {
+		return "http"
+	}
+	return v.provider
+}
+
+func (v *httpSlipVerifier) VerifySlip(ctx context.Context, input SlipInput) (*SlipData, error) {
+	var body bytes.Buffer
+	var contentType string
+
+	if input.QRPayload != "" {
+		// ทุก provider รองรับการส่ง QR payload ตรงๆ (เร็วกว่าส่งรูป)
+		json.NewEncoder(&body).Encode(map[string]interface{}{"data": input.QRPayload, "payload": input.QRPayload})
+		contentType = "application/json"
+	} else {
+		field := "file"
+		if v.provider == "slipok" {
+			field = "files"
+		}
+		mw := multipart.NewWriter(&body)
+		part, err := mw.CreateFormFile(field, "slip.jpg")
+		if err != nil {
+			return nil, err
+		}
+		part.Write(input.Image)
+		mw.Close()
+		contentType = mw.FormDataContentType()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, v.apiURL, &body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	if v.provider == "slipok" {
+		req.Header.Set("x-authorization", v.apiKey)
+	} else {
+		req.Header.Set("Authorization", "Bearer "+v.apiKey)
+	}
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("slip verification API request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 500 {
+		return nil, fmt.Errorf("slip verification API returned %d", resp.StatusCode)
+	}
+
+	data, err := parseSlipAPIResponse(v.provider, respBody)
+	if err != nil {
+		return nil, err
+	}
+	data.Verifier = v.Name()
+	return data, nil
+}
+
+// parseSlipAPIResponse maps the provider-specific JSON response to SlipData.
+// A response the provider marks as unsuccessful yields unverified (empty) data.
+func parseSlipAPIResponse(provider string, response []byte) (*SlipData, error) {
+	data := &SlipData{}
+
+	switch provider {
+	case "slipok":
+		var r struct {
+			Success bool `json:"success"`
+			Data    struct {
+				TransRef       string  `json:"transRef"`
+				TransTimestamp string  `json:"transTimestamp"`
+				Amount         float64 `json:"amount"`
+				SendingBank    string  `json:"sendingBank"`
+			} `json:"data"`
+		}
+		if err := json.Unmarshal(response, &r); err != nil {
+			return nil, err
+		}
+		data.Verified = r.Success
+		data.TransactionRef = r.Data.TransRef
+		data.Amount = r.Data.Amount
+		data.SendingBank = r.Data.SendingBank
+		data.TransferredAt = parseSlipTime(r.Data.TransTimestamp)
+
+	case "easyslip":
+		var r struct {
+			Status int `json:"status"`
+			Data   struct {
+				TransRef string `json:"transRef"`
+				Date     string `json:"date"`
+				Amount   struct {
+					Amount float64 `json:"amount"`
+				} `json:"amount"`
+				Sender struct {
+					Bank struct {
+						ID string `json:"id"`
+					} `json:"bank"`
+				} `json:"sender"`
+			} `json:"data"`
+		}
+		if err := json.Unmarshal(response, &r); err != nil {
+			return nil, err
+		}
+		data.Verified = r.Status == http.StatusOK
+		data.TransactionRef = r.Data.TransRef
+		data.Amount = r.Data.Amount.Amount
+		data.SendingBank = r.Data.Sender.Bank.ID
+		data.TransferredAt = parseSlipTime(r.Data.Date)
+
+	default:
+		// Generic parser
+		var r struct {
+			Valid          bool    `json:"valid"`
+			TransactionRef string  `json:"transaction_ref"`
+			Amount         float64 `json:"amount"`
+			TransferredAt  string  `json:"transferred_at"`
+			SendingBank    string  `json:"sending_bank"`
+		}
+		if err := json.Unmarshal(response, &r); err != nil {
+			return nil, err
+		}
+		data.Verified = r.Valid
+		data.TransactionRef = r.TransactionRef
+		data.Amount = r.Amount
+		data.SendingBank = r.SendingBank
+		data.TransferredAt = parseSlipTime(r.TransferredAt)
+	}
+
+	return data, nil
+}
+
+func parseSlipTime(value string) *time.Time {
+	if value == "" {
+		return nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return &t
+	}
+	return nil
+}
+
+// --- Matching rules ---
+
+// slipPaymentInfo is the pending payment a slip is being matched against
+type slipPaymentInfo struct {
+	Amount    float64
+	CreatedAt time.Time
+	ExpiresAt *time.Time
+}
+
+// slipLateGrace is how long after the QR expired a transfer is still accepted automatically
+const slipLateGrace = 24 * time.Hour
+
+// matchSlipToPayment decides whether a slip can auto-confirm a payment.
+// duplicateRef is true when the transaction ref already confirmed another payment.
+func matchSlipToPayment(slip *SlipData, payment slipPaymentInfo, duplicateRef bool) (string, string) {
+	if duplicateRef {
+		return SlipStatusRejected, "transaction reference has already been used for another payment"
+	}
+	if slip.TransferredAt != nil && slip.TransferredAt.Before(payment.CreatedAt.Add(-10*time.Minute)) {
+		return SlipStatusRejected, "slip was issued before this payment was created"
+	}
+	if slip.TransactionRef == "" {
+		return SlipStatusReview, "transaction reference could not be read"
+	}
+	if slip.Amount <= 0 {
+		return SlipStatusReview, "amount could not be read"
+	}
+	if math.Abs(slip.Amount-payment.Amount) >= 0.01 {
+		return SlipStatusReview, fmt.Sprintf("amount mismatch: slip ฿%.2f, expected ฿%.2f", slip.Amount, payment.Amount)
+	}
+	if slip.TransferredAt == nil {
+		return SlipStatusReview, "transfer time could not be read"
+	}
+	if payment.ExpiresAt != nil && slip.TransferredAt.After(payment.ExpiresAt.Add(slipLateGrace)) {
+		return SlipStatusReview, "transfer was made long after the QR code expired"
+	}
+	if !slip.Verified {
+		return SlipStatusReview, "slip could not be verified with the bank"
+	}
+	return SlipStatusMatched, "slip matches payment"
+}
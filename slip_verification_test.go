@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func buildTestSlipQR(transRef string) string {
+	inner := emvTLV("00", "000001") + emvTLV("01", "004") + emvTLV("02", transRef)
+	payload := emvTLV("00", inner) + emvTLV("51", "TH") + "9104"
+	return payload + calculateCRC16(payload)
+}
+
+// Test Thai slip mini-QR decoding
+func TestDecodeThaiSlipQR(t *testing.T) {
+	t.Run("Valid Slip QR", func(t *testing.T) {
+		slipQR, err := decodeThaiSlipQR(buildTestSlipQR("014242082547BPM04988"))
+		assert.NoError(t, err)
+		assert.Equal(t, "004", slipQR.SendingBank)
+		assert.Equal(t, "014242082547BPM04988", slipQR.TransactionRef)
+		assert.Equal(t, "TH", slipQR.CountryCode)
+		assert.True(t, slipQR.ChecksumValid)
+	})
+
+	t.Run("Tampered Slip QR Fails Checksum", func(t *testing.T) {
+		payload := buildTestSlipQR("014242082547BPM04988")
+		tampered := payload[:len(payload)-4] + "0000"
+		slipQR, err := decodeThaiSlipQR(tampered)
+		assert.NoError(t, err)
+		assert.False(t, slipQR.ChecksumValid)
+	})
+
+	t.Run("Truncated Payload", func(t *testing.T) {
+		_, err := decodeThaiSlipQR("0041000600")
+		assert.Error(t, err)
+	})
+}
+
+// Test local verifier
+func TestLocalSlipVerifier(t *testing.T) {
+	transferredAt := time.Now()
+
+	t.Run("Uses Declared Amount With Decoded Reference", func(t *testing.T) {
+		data, err := localSlipVerifier{}.VerifySlip(context.Background(), SlipInput{
+			QRPayload:      buildTestSlipQR("REF123"),
+			DeclaredAmount: 1500,
+			DeclaredAt:     &transferredAt,
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, "REF123", data.TransactionRef)
+		assert.Equal(t, 1500.0, data.Amount)
+		assert.False(t, data.Verified) // checksum ถูกก็ยังปลอมได้ → admin ตรวจ
+	})
+
+	t.Run("Declared Data Never Auto-Matches", func(t *testing.T) {
+		data, _ := localSlipVerifier{}.VerifySlip(context.Background(), SlipInput{
+			QRPayload:      buildTestSlipQR("FORGED1"),
+			DeclaredAmount: 1500,
+			DeclaredAt:     &transferredAt,
+		})
+		status, _ := matchSlipToPayment(data, slipPaymentInfo{Amount: 1500, CreatedAt: transferredAt.Add(-time.Minute)}, false)
+		assert.Equal(t, SlipStatusReview, status)
+	})
+
+	t.Run("No QR Payload Is Unverified", func(t *testing.T) {
+		data, err := localSlipVerifier{}.VerifySlip(context.Background(), SlipInput{DeclaredAmount: 1500})
+		assert.NoError(t, err)
+		assert.False(t, data.Verified)
+		assert.Empty(t, data.TransactionRef)
+	})
+}
+
+// Test third-party slip API response parsing
+func TestParseSlipAPIResponse(t *testing.T) {
+	t.Run("SlipOK", func(t *testing.T) {
+		data, err := parseSlipAPIResponse("slipok", []byte(`{"success":true,"data":{"transRef":"ABC123","transTimestamp":"2025-01-15T07:30:00Z","amount":1500,"sendingBank":"004"}}`))
+		assert.NoError(t, err)
+		assert.True(t, data.Verified)
+		assert.Equal(t, "ABC123", data.TransactionRef)
+		assert.Equal(t, 1500.0, data.Amount)
+		assert.NotNil(t, data.TransferredAt)
+	})
+
+	t.Run("EasySlip", func(t *testing.T) {
+		data, err := parseSlipAPIResponse("easyslip", []byte(`{"status":200,"data":{"transRef":"XYZ789","date":"2025-01-15T14:30:00+07:00","amount":{"amount":999.5},"sender":{"bank":{"id":"014"}}}}`))
+		assert.NoError(t, err)
+		assert.True(t, data.Verified)
+		assert.Equal(t, "XYZ789", data.TransactionRef)
+		assert.Equal(t, 999.5, data.Amount)
+		assert.Equal(t, "014", data.SendingBank)
+	})
+
+	t.Run("Unsuccessful Response Is Unverified", func(t *testing.T) {
+		data, err := parseSlipAPIResponse("slipok", []byte(`{"success":false,"code":1012}`))
+		assert.NoError(t, err)
+		assert.False(t, data.Verified)
+	})
+}
+
+// Test slip-to-payment matching rules
+func TestMatchSlipToPayment(t *testing.T) {
+	created := time.Date(2025, 1, 15, 14, 0, 0, 0, time.UTC)
+	expires := created.Add(15 * time.Minute)
+	payment := slipPaymentInfo{Amount: 1500, CreatedAt: created, ExpiresAt: &expires}
+	paidAt := created.Add(5 * time.Minute)
+
+	validSlip := func() *SlipData {
+		return &SlipData{Amount: 1500, TransactionRef: "REF1", TransferredAt: &paidAt, Verified: true}
+	}
+
+	t.Run("Exact Match Auto-Confirms", func(t *testing.T) {
+		status, _ := matchSlipToPayment(validSlip(), payment, false)
+		assert.Equal(t, SlipStatusMatched, status)
+	})
+
+	t.Run("Duplicate Reference Is Rejected", func(t *testing.T) {
+		status, _ := matchSlipToPayment(validSlip(), payment, true)
+		assert.Equal(t, SlipStatusRejected, status)
+	})
+
+	t.Run("Old Slip Is Rejected", func(t *testing.T) {
+		slip := validSlip()
+		old := created.Add(-2 * time.Hour)
+		slip.TransferredAt = &old
+		status, _ := matchSlipToPayment(slip, payment, false)
+		assert.Equal(t, SlipStatusRejected, status)
+	})
+
+	t.Run("Amount Mismatch Goes To Review", func(t *testing.T) {
+		slip := validSlip()
+		slip.Amount = 1000
+		status, _ := matchSlipToPayment(slip, payment, false)
+		assert.Equal(t, SlipStatusReview, status)
+	})
+
+	t.Run("Unverified Slip Goes To Review", func(t *testing.T) {
+		slip := validSlip()
+		slip.Verified = false
+		status, _ := matchSlipToPayment(slip, payment, false)
+		assert.Equal(t, SlipStatusReview, status)
+	})
+
+	t.Run("Missing Reference Goes To Review", func(t *testing.T) {
+		slip := validSlip()
+		slip.TransactionRef = ""
+		status, _ := matchSlipToPayment(slip, payment, false)
+		assert.Equal(t, SlipStatusReview, status)
+	})
+}
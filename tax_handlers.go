@@ -0,0 +1,568 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ================================
+// Provider: Tax Statements
+// ================================
+
+// parseTaxYear reads ?year= (default: current year in Bangkok)
+func parseTaxYear(c *gin.Context) (int, bool) {
+	yearStr := c.Query("year")
+	if yearStr == "" {
+		return time.Now().In(bangkokLocation).Year(), true
+	}
+	year, err := strconv.Atoi(yearStr)
+	if err != nil || year < 2000 || year > 2100 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid year"})
+		return 0, false
+	}
+	return year, true
+}
+
+const taxCertificateColumns = `
+	certificate_id, certificate_no, user_id, tax_year, taxpayer_type, payee_name,
+	COALESCE(payee_tax_id, ''), COALESCE(payee_address, ''), income_type,
+	total_gross, total_tax, entry_count, issued_at
+`
+
+func scanTaxCertificate(row pgx.Row) (*WithholdingCertificate, error) {
+	var cert WithholdingCertificate
+	err := row.Scan(&cert.CertificateID, &cert.CertificateNo, &cert.UserID, &cert.TaxYear, &cert.TaxpayerType,
+		&cert.PayeeName, &cert.PayeeTaxID, &cert.PayeeAddress, &cert.IncomeType,
+		&cert.TotalGross, &cert.TotalTax, &cert.EntryCount, &cert.IssuedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &cert, nil
+}
+
+// --- GET /tax/statements?year=2025 ---
+func getTaxStatementsHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := c.Get("userID")
+		year, ok := parseTaxYear(c)
+		if !ok {
+			return
+		}
+
+		rows, err := dbPool.Query(ctx, `
+			SELECT entry_id, withdrawal_id, income_type, gross_amount, rate, tax_amount, net_paid, paid_at
+			FROM withholding_tax_entries
+			WHERE user_id = $1 AND tax_year = $2
+			ORDER BY paid_at ASC
+		`, userID, year)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch tax statements"})
+			return
+		}
+		defer rows.Close()
+
+		entries := make([]gin.H, 0)
+		monthly := make([]gin.H, 12)
+		for m := range monthly {
+			monthly[m] = gin.H{"month": m + 1, "gross_amount": 0.0, "tax_amount": 0.0}
+		}
+		var totalGross, totalTax, totalNet float64
+		for rows.Next() {
+			var (
+				entryID                   int
+				withdrawalID              *int
+				incomeType                string
+				gross, rate, tax, netPaid float64
+				paidAt                    time.Time
+			)
+			if err := rows.Scan(&entryID, &withdrawalID, &incomeType, &gross, &rate, &tax, &netPaid, &paidAt); err != nil {
+				continue
+			}
+			entries = append(entries, gin.H{
+				"entry_id":      entryID,
+				"withdrawal_id": withdrawalID,
+				"income_type":   incomeType,
+				"gross_amount":  gross,
+				"rate":          rate,
+				"tax_amount":    tax,
+				"net_paid":      netPaid,
+				"paid_at":       paidAt,
+			})
+
+			m := paidAt.In(bangkokLocation).Month() - 1
+			monthly[m]["gross_amount"] = monthly[m]["gross_amount"].(float64) + gross
+			monthly[m]["tax_amount"] = monthly[m]["tax_amount"].(float64) + tax
+			totalGross += gross
+			totalTax += tax
+			totalNet += netPaid
+		}
+
+		var certificate gin.H
+		cert, err := scanTaxCertificate(dbPool.QueryRow(ctx, `
+			SELECT `+taxCertificateColumns+` FROM tax_certificates WHERE user_id = $1 AND tax_year = $2
+		`, userID, year))
+		if err == nil {
+			certificate = gin.H{
+				"certificate_no": cert.CertificateNo,
+				"issued_at":      cert.IssuedAt,
+				"total_gross":    cert.TotalGross,
+				"total_tax":      cert.TotalTax,
+				"pdf_url":        fmt.Sprintf("/tax/certificates/%d/pdf", year),
+			}
+		}
+
+		var profile gin.H
+		var taxpayerType, taxID, legalName string
+		var address *string
+		err = dbPool.QueryRow(ctx, `
+			SELECT taxpayer_type, tax_id, legal_name, address FROM provider_tax_profiles WHERE user_id = $1
+		`, userID).Scan(&taxpayerType, &taxID, &legalName, &address)
+		if err == nil {
+			profile = gin.H{"taxpayer_type": taxpayerType, "tax_id": taxID, "legal_name": legalName, "address": address}
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"tax_year":    year,
+			"tax_profile": profile,
+			"entries":     entries,
+			"monthly":     monthly,
+			"totals": gin.H{
+				"gross_amount": totalGross,
+				"tax_amount":   totalTax,
+				"net_paid":     totalNet,
+			},
+			"certificate": certificate,
+		})
+	}
+}
+
+// --- PUT /tax/profile (ข้อมูลผู้เสียภาษีสำหรับออก 50 ทวิ) ---
+func updateTaxProfileHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := c.Get("userID")
+
+		var req struct {
+			TaxpayerType string `json:"taxpayer_type" binding:"omitempty,oneof=individual company"`
+			TaxID        string `json:"tax_id" binding:"required"`
+			LegalName    string `json:"legal_name" binding:"required"`
+			Address      string `json:"address"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if req.TaxpayerType == "" {
+			req.TaxpayerType = TaxpayerIndividual
+		}
+		taxID := onlyDigits(req.TaxID)
+		if !isValidThaiNationalID(taxID) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid 13-digit tax ID"})
+			return
+		}
+
+		_, err := dbPool.Exec(ctx, `
+			INSERT INTO provider_tax_profiles (user_id, taxpayer_type, tax_id, legal_name, address, updated_at)
+			VALUES ($1, $2, $3, $4, NULLIF($5, ''), NOW())
+			ON CONFLICT (user_id) DO UPDATE
+			SET taxpayer_type = EXCLUDED.taxpayer_type,
+			    tax_id = EXCLUDED.tax_id,
+			    legal_name = EXCLUDED.legal_name,
+			    address = EXCLUDED.address,
+			    updated_at = NOW()
+		`, userID, req.TaxpayerType, taxID, req.LegalName, req.Address)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save tax profile"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Tax profile updated successfully"})
+	}
+}
+
+// --- GET /tax/certificates/:year/pdf ---
+func getMyTaxCertificatePDFHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := c.Get("userID")
+		year, err := strconv.Atoi(c.Param("year"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid year"})
+			return
+		}
+
+		cert, err := scanTaxCertificate(dbPool.QueryRow(ctx, `
+			SELECT `+taxCertificateColumns+` FROM tax_certificates WHERE user_id = $1 AND tax_year = $2
+		`, userID, year))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "No withholding tax certificate has been issued for this year"})
+			return
+		}
+
+		sendTaxCertificatePDF(c, cert)
+	}
+}
+
+func sendTaxCertificatePDF(c *gin.Context, cert *WithholdingCertificate) {
+	pdf := buildWithholdingCertificatePDF(*cert, withholdingPayerFromEnv())
+	c.Header("Content-Type", "application/pdf")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="50tawi_%s.pdf"`, cert.CertificateNo))
+	c.Data(http.StatusOK, "application/pdf", pdf)
+}
+
+// ================================
+// Admin: Withholding Tax
+// ================================
+
+// --- GET /admin/tax/withholding-rates ---
+func adminGetWithholdingRatesHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		rows, err := dbPool.Query(ctx, `
+			SELECT rate_id, name, taxpayer_type, income_type, rate, min_amount,
+			       effective_from, effective_until, is_active
+			FROM withholding_tax_rates
+			ORDER BY taxpayer_type, effective_from DESC
+		`)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch withholding rates"})
+			return
+		}
+		defer rows.Close()
+
+		rates := make([]WithholdingRate, 0)
+		for rows.Next() {
+			var r WithholdingRate
+			if err := rows.Scan(&r.RateID, &r.Name, &r.TaxpayerType, &r.IncomeType, &r.Rate, &r.MinAmount,
+				&r.EffectiveFrom, &r.EffectiveUntil, &r.IsActive); err != nil {
+				continue
+			}
+			rates = append(rates, r)
+		}
+
+		c.JSON(http.StatusOK, gin.H{"rates": rates})
+	}
+}
+
+// --- POST /admin/tax/withholding-rates ---
+func adminCreateWithholdingRateHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Name          string     `json:"name" binding:"required"`
+			TaxpayerType  string     `json:"taxpayer_type" binding:"required,oneof=individual company"`
+			IncomeType    string     `json:"income_type" binding:"required"`
+			Rate          float64    `json:"rate" binding:"min=0,max=1"`
+			MinAmount     float64    `json:"min_amount" binding:"min=0"`
+			EffectiveFrom *time.Time `json:"effective_from"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		var rateID int
+		err := dbPool.QueryRow(ctx, `
+			INSERT INTO withholding_tax_rates (name, taxpayer_type, income_type, rate, min_amount, effective_from)
+			VALUES ($1, $2, $3, $4, $5, COALESCE($6, NOW()))
+			RETURNING rate_id
+		`, req.Name, req.TaxpayerType, req.IncomeType, req.Rate, req.MinAmount, req.EffectiveFrom).Scan(&rateID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create withholding rate"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"message": "Withholding rate created successfully", "rate_id": rateID})
+	}
+}
+
+// --- PUT /admin/tax/withholding-rates/:rate_id ---
+func adminUpdateWithholdingRateHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		rateID := c.Param("rate_id")
+
+		var req struct {
+			Rate           *float64   `json:"rate" binding:"omitempty,min=0,max=1"`
+			MinAmount      *float64   `json:"min_amount" binding:"omitempty,min=0"`
+			EffectiveUntil *time.Time `json:"effective_until"`
+			IsActive       *bool      `json:"is_active"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		tag, err := dbPool.Exec(ctx, `
+			UPDATE withholding_tax_rates
+			SET rate = COALESCE($1, rate),
+			    min_amount = COALESCE($2, min_amount),
+			    effective_until = COALESCE($3, effective_until),
+			    is_active = COALESCE($4, is_active),
+			    updated_at = NOW()
+			WHERE rate_id = $5
+		`, req.Rate, req.MinAmount, req.EffectiveUntil, req.IsActive, rateID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update withholding rate"})
+			return
+		}
+		if tag.RowsAffected() == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Withholding rate not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Withholding rate updated successfully", "rate_id": rateID})
+	}
+}
+
+// --- POST /admin/tax/certificates/generate (ออก 50 ทวิ รายปีให้ทุก provider) ---
+func adminGenerateTaxCertificatesHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		adminID, _ := c.Get("userID")
+
+		var req struct {
+			Year int `json:"year" binding:"required,min=2000,max=2100"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		tx, err := dbPool.Begin(ctx)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+			return
+		}
+		defer tx.Rollback(ctx)
+
+		// เลขที่เอกสารต้องเรียงต่อเนื่องภายในปี → ล็อกตารางระหว่างออกเลข
+		if _, err = tx.Exec(ctx, `LOCK TABLE tax_certificates IN SHARE ROW EXCLUSIVE MODE`); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to lock certificates"})
+			return
+		}
+
+		rows, err := tx.Query(ctx, `
+			SELECT e.user_id,
+			       COALESCE(p.taxpayer_type, 'individual'),
+			       COALESCE(p.legal_name, NULLIF(TRIM(CONCAT(u.first_name, ' ', u.last_name)), ''), u.username),
+			       COALESCE(p.tax_id, ''), COALESCE(p.address, ''),
+			       MIN(e.income_type), SUM(e.gross_amount), SUM(e.tax_amount), COUNT(*)
+			FROM withholding_tax_entries e
+			JOIN users u ON u.user_id = e.user_id
+			LEFT JOIN provider_tax_profiles p ON p.user_id = e.user_id
+			WHERE e.tax_year = $1
+			GROUP BY e.user_id, p.taxpayer_type, p.legal_name, p.tax_id, p.address, u.first_name, u.last_name, u.username
+			ORDER BY e.user_id
+		`, req.Year)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to aggregate withholding tax", "details": err.Error()})
+			return
+		}
+		var certs []WithholdingCertificate
+		for rows.Next() {
+			var cert WithholdingCertificate
+			if err := rows.Scan(&cert.UserID, &cert.TaxpayerType, &cert.PayeeName, &cert.PayeeTaxID, &cert.PayeeAddress,
+				&cert.IncomeType, &cert.TotalGross, &cert.TotalTax, &cert.EntryCount); err != nil {
+				rows.Close()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read withholding tax", "details": err.Error()})
+				return
+			}
+			cert.TaxYear = req.Year
+			certs = append(certs, cert)
+		}
+		rows.Close()
+
+		var nextSeq int
+		tx.QueryRow(ctx, `SELECT COALESCE(MAX(sequence_no), 0) + 1 FROM tax_certificates WHERE tax_year = $1`, req.Year).Scan(&nextSeq)
+
+		issued, updated := 0, 0
+		for _, cert := range certs {
+			// ออกใหม่ หรือปรับยอดใบเดิม (เลขที่เดิม) ถ้ามีรายการเพิ่มหลังออกไปแล้ว
+			var certificateID int
+			var inserted bool
+			err = tx.QueryRow(ctx, `
+				INSERT INTO tax_certificates (
+					certificate_no, user_id, tax_year, sequence_no, taxpayer_type, payee_name, payee_tax_id,
+					payee_address, income_type, total_gross, total_tax, entry_count, issued_by, issued_at
+				) VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''), NULLIF($8, ''), $9, $10, $11, $12, $13, NOW())
+				ON CONFLICT (user_id, tax_year) DO UPDATE
+				SET taxpayer_type = EXCLUDED.taxpayer_type,
+				    payee_name = EXCLUDED.payee_name,
+				    payee_tax_id = EXCLUDED.payee_tax_id,
+				    payee_address = EXCLUDED.payee_address,
+				    total_gross = EXCLUDED.total_gross,
+				    total_tax = EXCLUDED.total_tax,
+				    entry_count = EXCLUDED.entry_count,
+				    issued_by = EXCLUDED.issued_by,
+				    issued_at = NOW()
+				RETURNING certificate_id, (xmax = 0)
+			`, fmt.Sprintf("WHT%d-%06d", req.Year, nextSeq), cert.UserID, req.Year, nextSeq, cert.TaxpayerType,
+				cert.PayeeName, cert.PayeeTaxID, cert.PayeeAddress, cert.IncomeType,
+				cert.TotalGross, cert.TotalTax, cert.EntryCount, adminID).Scan(&certificateID, &inserted)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to issue certificate", "details": err.Error()})
+				return
+			}
+			if inserted {
+				nextSeq++
+				issued++
+			} else {
+				updated++
+			}
+
+			_, err = tx.Exec(ctx, `
+				UPDATE withholding_tax_entries SET certificate_id = $1 WHERE user_id = $2 AND tax_year = $3
+			`, certificateID, cert.UserID, req.Year)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to link certificate entries"})
+				return
+			}
+		}
+
+		if err = tx.Commit(ctx); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":  "Withholding tax certificates generated",
+			"tax_year": req.Year,
+			"issued":   issued,
+			"updated":  updated,
+		})
+	}
+}
+
+// --- GET /admin/tax/certificates?year=2025 ---
+func adminGetTaxCertificatesHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		year, ok := parseTaxYear(c)
+		if !ok {
+			return
+		}
+
+		rows, err := dbPool.Query(ctx, `
+			SELECT `+taxCertificateColumns+` FROM tax_certificates WHERE tax_year = $1 ORDER BY sequence_no
+		`, year)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch certificates"})
+			return
+		}
+		defer rows.Close()
+
+		certs := make([]WithholdingCertificate, 0)
+		for rows.Next() {
+			cert, err := scanTaxCertificate(rows)
+			if err != nil {
+				continue
+			}
+			certs = append(certs, *cert)
+		}
+
+		c.JSON(http.StatusOK, gin.H{"tax_year": year, "certificates": certs, "total": len(certs)})
+	}
+}
+
+// --- GET /admin/tax/certificates/:certificate_id/pdf ---
+func adminGetTaxCertificatePDFHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		cert, err := scanTaxCertificate(dbPool.QueryRow(ctx, `
+			SELECT `+taxCertificateColumns+` FROM tax_certificates WHERE certificate_id = $1
+		`, c.Param("certificate_id")))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Certificate not found"})
+			return
+		}
+
+		sendTaxCertificatePDF(c, cert)
+	}
+}
+
+// --- GET /admin/tax/export?year=2025&month=3&form=pnd3 (ไฟล์แนบ ภ.ง.ด.3 / ภ.ง.ด.53) ---
+func adminExportWithholdingTaxHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		year, ok := parseTaxYear(c)
+		if !ok {
+			return
+		}
+
+		from := time.Date(year, time.January, 1, 0, 0, 0, 0, bangkokLocation)
+		to := from.AddDate(1, 0, 0)
+		period := strconv.Itoa(year)
+		if monthStr := c.Query("month"); monthStr != "" {
+			month, err := strconv.Atoi(monthStr)
+			if err != nil || month < 1 || month > 12 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid month"})
+				return
+			}
+			from = time.Date(year, time.Month(month), 1, 0, 0, 0, 0, bangkokLocation)
+			to = from.AddDate(0, 1, 0)
+			period = fmt.Sprintf("%d%02d", year, month)
+		}
+
+		taxpayerType := ""
+		switch c.Query("form") {
+		case "pnd3":
+			taxpayerType = TaxpayerIndividual
+		case "pnd53":
+			taxpayerType = TaxpayerCompany
+		case "":
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "form must be pnd3 or pnd53"})
+			return
+		}
+
+		rows, err := dbPool.Query(ctx, `
+			SELECT COALESCE(p.tax_id, ''),
+			       COALESCE(p.legal_name, NULLIF(TRIM(CONCAT(u.first_name, ' ', u.last_name)), ''), u.username),
+			       COALESCE(p.address, ''), COALESCE(p.taxpayer_type, 'individual'),
+			       e.paid_at, e.income_type, e.rate, e.gross_amount, e.tax_amount,
+			       COALESCE(tc.certificate_no, '')
+			FROM withholding_tax_entries e
+			JOIN users u ON u.user_id = e.user_id
+			LEFT JOIN provider_tax_profiles p ON p.user_id = e.user_id
+			LEFT JOIN tax_certificates tc ON tc.certificate_id = e.certificate_id
+			WHERE e.paid_at >= $1 AND e.paid_at < $2
+			  AND ($3 = '' OR COALESCE(p.taxpayer_type, 'individual') = $3)
+			ORDER BY e.paid_at, e.entry_id
+		`, from, to, taxpayerType)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export withholding tax"})
+			return
+		}
+		defer rows.Close()
+
+		var buf bytes.Buffer
+		w := csv.NewWriter(&buf)
+		w.Write([]string{"seq", "payee_tax_id", "payee_name", "payee_address", "form", "paid_date",
+			"income_type", "rate_percent", "amount_paid", "tax_withheld", "condition", "certificate_no"})
+		seq := 0
+		for rows.Next() {
+			var (
+				taxID, name, address, payeeType, incomeType, certNo string
+				paidAt                                              time.Time
+				rate, gross, tax                                    float64
+			)
+			if err := rows.Scan(&taxID, &name, &address, &payeeType, &paidAt, &incomeType, &rate, &gross, &tax, &certNo); err != nil {
+				continue
+			}
+			seq++
+			w.Write([]string{
+				strconv.Itoa(seq), taxID, name, address, withholdingFormName(payeeType),
+				paidAt.In(bangkokLocation).Format("02/01/2006"), incomeType,
+				fmt.Sprintf("%.2f", rate*100), fmt.Sprintf("%.2f", gross), fmt.Sprintf("%.2f", tax),
+				"1", // 1 = หัก ณ ที่จ่าย
+				certNo,
+			})
+		}
+		w.Flush()
+
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="withholding_tax_%s.csv"`, period))
+		c.Data(http.StatusOK, "text/csv; charset=utf-8", append([]byte("\xef\xbb\xbf"), buf.Bytes()...))
+	}
+}
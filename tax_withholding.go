@@ -0,0 +1,274 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ================================
+// Withholding Tax (ภาษีหัก ณ ที่จ่าย) & 50 Tawi Certificates
+// ================================
+
+// bangkokLocation is Thailand's time zone (no DST), used for tax years and filing months
+var bangkokLocation = time.FixedZone("Asia/Bangkok", 7*60*60)
+
+// Taxpayer types (provider_tax_profiles.taxpayer_type)
+const (
+	TaxpayerIndividual = "individual" // บุคคลธรรมดา → ภ.ง.ด.3
+	TaxpayerCompany    = "company"    // นิติบุคคล → ภ.ง.ด.53
+)
+
+// WithholdingRate is a configurable withholding rule (withholding_tax_rates)
+type WithholdingRate struct {
+	RateID         int        `json:"rate_id"`
+	Name           string     `json:"name"`
+	TaxpayerType   string     `json:"taxpayer_type"`
+	IncomeType     string     `json:"income_type"` // e.g. "40(8)"
+	Rate           float64    `json:"rate"`        // 0.0300 = 3%
+	MinAmount      float64    `json:"min_amount"`  // ไม่หักถ้ายอดจ่ายต่อครั้งต่ำกว่านี้
+	EffectiveFrom  time.Time  `json:"effective_from"`
+	EffectiveUntil *time.Time `json:"effective_until"`
+	IsActive       bool       `json:"is_active"`
+}
+
+// WithholdingCertificate is a yearly 50 Tawi certificate for one provider
+type WithholdingCertificate struct {
+	CertificateID int       `json:"certificate_id"`
+	CertificateNo string    `json:"certificate_no"`
+	UserID        int       `json:"user_id"`
+	TaxYear       int       `json:"tax_year"`
+	TaxpayerType  string    `json:"taxpayer_type"`
+	PayeeName     string    `json:"payee_name"`
+	PayeeTaxID    string    `json:"payee_tax_id"`
+	PayeeAddress  string    `json:"payee_address"`
+	IncomeType    string    `json:"income_type"`
+	TotalGross    float64   `json:"total_gross"`
+	TotalTax      float64   `json:"total_tax"`
+	EntryCount    int       `json:"entry_count"`
+	IssuedAt      time.Time `json:"issued_at"`
+}
+
+// calculateWithholdingTax applies a rate to one payment, rounded to satang
+func calculateWithholdingTax(amount float64, rate *WithholdingRate) float64 {
+	if rate == nil || amount <= 0 || amount < rate.MinAmount {
+		return 0
+	}
+	return math.Round(amount*rate.Rate*100) / 100
+}
+
+// taxYearOf returns the Thai tax year (calendar year, Bangkok time) of a payment
+func taxYearOf(t time.Time) int {
+	return t.In(bangkokLocation).Year()
+}
+
+// lookupWithholdingRate finds the active rate for the provider's taxpayer type at a point in time
+func lookupWithholdingRate(ctx context.Context, tx pgx.Tx, userID int, at time.Time) (*WithholdingRate, error) {
+	var rate WithholdingRate
+	err := tx.QueryRow(ctx, `
+		SELECT r.rate_id, r.name, r.taxpayer_type, r.income_type, r.rate, r.min_amount,
+		       r.effective_from, r.effective_until, r.is_active
+		FROM withholding_tax_rates r
+		WHERE r.is_active = true
+		  AND r.taxpayer_type = COALESCE(
+		        (SELECT taxpayer_type FROM provider_tax_profiles WHERE user_id = $1), 'individual')
+		  AND r.effective_from <= $2
+		  AND (r.effective_until IS NULL OR r.effective_until > $2)
+		ORDER BY r.effective_from DESC
+		LIMIT 1
+	`, userID, at).Scan(&rate.RateID, &rate.Name, &rate.TaxpayerType, &rate.IncomeType, &rate.Rate,
+		&rate.MinAmount, &rate.EffectiveFrom, &rate.EffectiveUntil, &rate.IsActive)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &rate, nil
+}
+
+// assessWithdrawalWithholding fixes the tax to withhold from a withdrawal (once),
+// storing it on the withdrawal so the transferred amount and the ledger agree
+func assessWithdrawalWithholding(ctx context.Context, tx pgx.Tx, withdrawalID int, at time.Time) (float64, error) {
+	var userID int
+	var netAmount float64
+	var assessed *float64
+	err := tx.QueryRow(ctx, `
+		SELECT user_id, net_amount, withholding_tax FROM withdrawals WHERE withdrawal_id = $1
+	`, withdrawalID).Scan(&userID, &netAmount, &assessed)
+	if err != nil {
+		return 0, err
+	}
+	if assessed != nil {
+		return *assessed, nil
+	}
+
+	rate, err := lookupWithholdingRate(ctx, tx, userID, at)
+	if err != nil {
+		return 0, err
+	}
+	tax := calculateWithholdingTax(netAmount, rate)
+
+	var rateID *int
+	if rate != nil {
+		rateID = &rate.RateID
+	}
+	_, err = tx.Exec(ctx, `
+		UPDATE withdrawals SET withholding_tax = $1, withholding_rate_id = $2 WHERE withdrawal_id = $3
+	`, tax, rateID, withdrawalID)
+	return tax, err
+}
+
+// settleWithdrawalWithholding records the withheld tax as its own ledger line when a
+// payout completes. Safe to call more than once for the same withdrawal.
+func settleWithdrawalWithholding(ctx context.Context, tx pgx.Tx, withdrawalID int, paidAt time.Time) (float64, error) {
+	tax, err := assessWithdrawalWithholding(ctx, tx, withdrawalID, paidAt)
+	if err != nil || tax <= 0 {
+		return tax, err
+	}
+
+	var exists bool
+	if err := tx.QueryRow(ctx, `
+		SELECT EXISTS(SELECT 1 FROM withholding_tax_entries WHERE withdrawal_id = $1)
+	`, withdrawalID).Scan(&exists); err != nil || exists {
+		return tax, err
+	}
+
+	var userID int
+	var grossAmount, rateValue float64
+	var incomeType string
+	var rateID *int
+	err = tx.QueryRow(ctx, `
+		SELECT w.user_id, w.net_amount, w.withholding_rate_id,
+		       COALESCE(r.rate, 0), COALESCE(r.income_type, '40(8)')
+		FROM withdrawals w
+		LEFT JOIN withholding_tax_rates r ON r.rate_id = w.withholding_rate_id
+		WHERE w.withdrawal_id = $1
+	`, withdrawalID).Scan(&userID, &grossAmount, &rateID, &rateValue, &incomeType)
+	if err != nil {
+		return 0, err
+	}
+
+	var transactionID int
+	err = tx.QueryRow(ctx, `
+		INSERT INTO transactions (
+			user_id, type, status, amount, commission_amount, net_amount,
+			withdrawal_id, description, processed_at
+		) VALUES ($1, 'withholding_tax', 'completed', $2, 0, $2, $3, $4, $5)
+		RETURNING transaction_id
+	`, userID, tax, withdrawalID,
+		fmt.Sprintf("Withholding tax %.2f%% on payout of ฿%.2f", rateValue*100, grossAmount), paidAt).Scan(&transactionID)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO withholding_tax_entries (
+			user_id, withdrawal_id, transaction_id, rate_id, tax_year, income_type,
+			gross_amount, rate, tax_amount, net_paid, paid_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+	`, userID, withdrawalID, transactionID, rateID, taxYearOf(paidAt), incomeType,
+		grossAmount, rateValue, tax, grossAmount-tax, paidAt)
+	return tax, err
+}
+
+// --- 50 Tawi PDF ---
+
+// withholdingPayer is the platform's identity printed on certificates
+type withholdingPayer struct {
+	Name    string
+	TaxID   string
+	Address string
+}
+
+func withholdingPayerFromEnv() withholdingPayer {
+	name := os.Getenv("PLATFORM_LEGAL_NAME")
+	if name == "" {
+		name = "SkillMatch Co., Ltd."
+	}
+	return withholdingPayer{
+		Name:    name,
+		TaxID:   os.Getenv("PLATFORM_TAX_ID"),
+		Address: os.Getenv("PLATFORM_ADDRESS"),
+	}
+}
+
+// withholdingFormName is the filing form the certificate refers to
+func withholdingFormName(taxpayerType string) string {
+	if taxpayerType == TaxpayerCompany {
+		return "P.N.D.53"
+	}
+	return "P.N.D.3"
+}
+
+// buildWithholdingCertificatePDF renders a yearly 50 Tawi (withholding tax certificate)
+func buildWithholdingCertificatePDF(cert WithholdingCertificate, payer withholdingPayer) []byte {
+	doc := newPDFDocument("Withholding Tax Certificate " + cert.CertificateNo)
+	const left, right = 50.0, 545.0
+
+	doc.Text(left, 60, 16, true, "Withholding Tax Certificate (50 Tawi)")
+	doc.Text(left, 78, 9, false, "Section 50 bis of the Revenue Code")
+	doc.TextRight(right, 60, 10, false, "No. "+cert.CertificateNo)
+	doc.TextRight(right, 78, 10, false, fmt.Sprintf("Tax year %d", cert.TaxYear))
+
+	// Payer
+	doc.Rect(left, 95, right-left, 70, 0.8)
+	doc.Text(left+10, 113, 10, true, "Payer (withholding agent)")
+	doc.Text(left+10, 130, 10, false, payer.Name)
+	doc.Text(left+10, 145, 10, false, "Tax ID: "+payer.TaxID)
+	doc.Text(left+10, 160, 9, false, payer.Address)
+
+	// Payee
+	doc.Rect(left, 175, right-left, 70, 0.8)
+	doc.Text(left+10, 193, 10, true, "Payee (income recipient)")
+	doc.Text(left+10, 210, 10, false, cert.PayeeName)
+	doc.Text(left+10, 225, 10, false, "Tax ID: "+cert.PayeeTaxID)
+	doc.Text(left+10, 240, 9, false, cert.PayeeAddress)
+
+	// Income table
+	doc.Text(left, 275, 10, false, "Filed with form: "+withholdingFormName(cert.TaxpayerType))
+	doc.Line(left, 290, right, 290, 0.8)
+	doc.Text(left+5, 305, 10, true, "Type of income")
+	doc.TextRight(400, 305, 10, true, "Amount paid")
+	doc.TextRight(right-5, 305, 10, true, "Tax withheld")
+	doc.Line(left, 313, right, 313, 0.5)
+	doc.Text(left+5, 330, 10, false, fmt.Sprintf("Section %s service fees (%d payments)", cert.IncomeType, cert.EntryCount))
+	doc.TextRight(400, 330, 10, false, formatBaht(cert.TotalGross))
+	doc.TextRight(right-5, 330, 10, false, formatBaht(cert.TotalTax))
+	doc.Line(left, 340, right, 340, 0.5)
+	doc.Text(left+5, 356, 10, true, "Total")
+	doc.TextRight(400, 356, 10, true, formatBaht(cert.TotalGross))
+	doc.TextRight(right-5, 356, 10, true, formatBaht(cert.TotalTax))
+	doc.Line(left, 364, right, 364, 0.8)
+
+	doc.Text(left, 395, 10, false, "Payer: [X] Withheld at source")
+	doc.Text(left, 430, 10, false, "We certify that the above information is correct.")
+	doc.Text(left, 470, 10, false, "Issued on "+cert.IssuedAt.In(bangkokLocation).Format("2 January 2006"))
+	doc.Line(350, 470, right, 470, 0.5)
+	doc.Text(370, 485, 9, false, "Authorized signature")
+
+	return doc.Bytes()
+}
+
+// formatBaht formats an amount with thousands separators and 2 decimals
+func formatBaht(amount float64) string {
+	negative := amount < 0
+	s := fmt.Sprintf("%.2f", math.Abs(amount))
+	intPart, frac := s[:len(s)-3], s[len(s)-3:]
+
+	var out []byte
+	for i := range intPart {
+		if i > 0 && (len(intPart)-i)%3 == 0 {
+			out = append(out, ',')
+		}
+		out = append(out, intPart[i])
+	}
+	if negative {
+		return "-" + string(out) + frac
+	}
+	return string(out) + frac
+}
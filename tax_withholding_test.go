@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test withholding tax calculation
+func TestCalculateWithholdingTax(t *testing.T) {
+	rate := &WithholdingRate{Rate: 0.03, MinAmount: 1000}
+
+	t.Run("Applies Rate", func(t *testing.T) {
+		assert.Equal(t, 45.0, calculateWithholdingTax(1500, rate))
+		assert.Equal(t, 26.17, calculateWithholdingTax(872.25, &WithholdingRate{Rate: 0.03}))
+	})
+
+	t.Run("Below Minimum Is Not Withheld", func(t *testing.T) {
+		assert.Equal(t, 0.0, calculateWithholdingTax(999.99, rate))
+	})
+
+	t.Run("No Rate Configured", func(t *testing.T) {
+		assert.Equal(t, 0.0, calculateWithholdingTax(5000, nil))
+	})
+}
+
+// Test tax year boundaries use Bangkok time
+func TestTaxYearOf(t *testing.T) {
+	// 31 Dec 2024 18:00 UTC = 1 Jan 2025 01:00 in Bangkok
+	assert.Equal(t, 2025, taxYearOf(time.Date(2024, 12, 31, 18, 0, 0, 0, time.UTC)))
+	assert.Equal(t, 2024, taxYearOf(time.Date(2024, 12, 31, 16, 59, 0, 0, time.UTC)))
+}
+
+// Test amount formatting
+func TestFormatBaht(t *testing.T) {
+	assert.Equal(t, "0.00", formatBaht(0))
+	assert.Equal(t, "999.50", formatBaht(999.5))
+	assert.Equal(t, "1,000.00", formatBaht(1000))
+	assert.Equal(t, "1,234,567.89", formatBaht(1234567.89))
+	assert.Equal(t, "-12,500.00", formatBaht(-12500))
+}
+
+// Test 50 Tawi PDF generation
+func TestWithholdingCertificatePDF(t *testing.T) {
+	cert := WithholdingCertificate{
+		CertificateNo: "WHT2025-000001",
+		TaxYear:       2025,
+		TaxpayerType:  TaxpayerIndividual,
+		PayeeName:     "Jane (Provider) Doe",
+		PayeeTaxID:    "1101700230708",
+		IncomeType:    "40(8)",
+		TotalGross:    120000,
+		TotalTax:      3600,
+		EntryCount:    12,
+		IssuedAt:      time.Date(2026, 1, 15, 3, 0, 0, 0, time.UTC),
+	}
+	pdf := buildWithholdingCertificatePDF(cert, withholdingPayer{Name: "SkillMatch Co., Ltd.", TaxID: "0105556177553"})
+
+	assert.True(t, bytes.HasPrefix(pdf, []byte("%PDF-1.4")))
+	assert.True(t, bytes.HasSuffix(pdf, []byte("%%EOF\n")))
+	assert.Contains(t, string(pdf), "(No. WHT2025-000001)")
+	assert.Contains(t, string(pdf), `Jane \(Provider\) Doe`)
+	assert.Contains(t, string(pdf), "(120,000.00)")
+	assert.Contains(t, string(pdf), "(Filed with form: P.N.D.3)")
+}
+
+// Test PDF string escaping
+func TestPDFEscape(t *testing.T) {
+	assert.Equal(t, `a\(b\)\\c`, pdfEscape(`a(b)\c`))
+	assert.Equal(t, `Caf\351`, pdfEscape("Café"))
+	assert.Equal(t, "THB 100", pdfEscape("฿100"))
+	assert.Equal(t, "???", pdfEscape("ไทย"))
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"time"
+	_ "time/tzdata" // ฐานข้อมูล timezone ติดไปกับ binary (container ไม่มี /usr/share/zoneinfo)
+)
+
+// ================================
+// Time Zone Policy
+// ================================
+// - ทุกเวลาเก็บเป็น TIMESTAMPTZ (จุดเวลาจริง ไม่ขึ้นกับ timezone ของ server/session)
+// - Provider มี timezone ของตัวเอง (provider_availability_settings.timezone, ค่าเริ่มต้น Asia/Bangkok)
+// - Booking เก็บ timezone ของ Provider ตอนจอง (bookings.timezone) ใช้แสดงผลและข้อความแจ้งเตือน
+// - วันที่/เวลาจาก request ที่ไม่มี offset ตีความใน timezone นั้นเสมอ
+// - Response ส่ง RFC3339 พร้อม offset ของ timezone นั้น
+
+const defaultTimezone = "Asia/Bangkok"
+
+var errInvalidTimezone = errors.New("timezone must be an IANA name such as Asia/Bangkok")
+
+// loadTimezone resolves an IANA time zone name ("" = Asia/Bangkok)
+func loadTimezone(name string) (*time.Location, error) {
+	if name == "" || name == defaultTimezone {
+		return bangkokLocation, nil
+	}
+	if name == "Local" || !strings.Contains(name, "/") && name != "UTC" {
+		return nil, errInvalidTimezone
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, errInvalidTimezone
+	}
+	return loc, nil
+}
+
+// timezoneOrDefault is loadTimezone for stored values, falling back to Asia/Bangkok
+func timezoneOrDefault(name string) *time.Location {
+	loc, err := loadTimezone(name)
+	if err != nil {
+		return bangkokLocation
+	}
+	return loc
+}
+
+// startOfLocalDay returns midnight of t's date in loc
+func startOfLocalDay(t time.Time, loc *time.Location) time.Time {
+	local := t.In(loc)
+	return time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+}
+
+// parseLocalDateTime parses a YYYY-MM-DD date and HH:MM clock as wall-clock time in loc
+func parseLocalDateTime(date, clock string, loc *time.Location) (time.Time, error) {
+	return time.ParseInLocation("2006-01-02 15:04", date+" "+clock, loc)
+}
+
+// parseClientTime accepts RFC3339 with an offset, or a local date-time without offset
+// (2006-01-02T15:04[:05]) interpreted in loc
+func parseClientTime(value string, loc *time.Location) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	for _, layout := range []string{"2006-01-02T15:04:05", "2006-01-02T15:04", "2006-01-02 15:04:05", "2006-01-02 15:04"} {
+		if t, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, errors.New("invalid time format (use ISO 8601)")
+}
+
+// parseDateParam parses a query filter: RFC3339, or YYYY-MM-DD as the start of that day in loc
+// (endOfDay = the start of the next day, so the date is inclusive)
+func parseDateParam(value string, loc *time.Location, endOfDay bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", value, loc)
+	if err != nil {
+		return time.Time{}, errors.New("dates must be YYYY-MM-DD or RFC3339")
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
+// formatLocalTime formats t for notifications in the given IANA zone
+func formatLocalTime(t time.Time, timezone string) string {
+	return t.In(timezoneOrDefault(timezone)).Format("2 Jan 2006 15:04")
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadTimezone(t *testing.T) {
+	loc, err := loadTimezone("")
+	assert.NoError(t, err)
+	assert.Equal(t, bangkokLocation, loc)
+
+	loc, err = loadTimezone("Asia/Tokyo")
+	assert.NoError(t, err)
+	assert.Equal(t, "Asia/Tokyo", loc.String())
+
+	for _, bad := range []string{"Local", "EST", "Mars/Olympus_Mons"} {
+		_, err := loadTimezone(bad)
+		assert.ErrorIs(t, err, errInvalidTimezone, bad)
+	}
+	assert.Equal(t, bangkokLocation, timezoneOrDefault("nonsense"))
+}
+
+func TestParseClientTime(t *testing.T) {
+	t.Run("no offset is provider local time", func(t *testing.T) {
+		got, err := parseClientTime("2026-10-20T10:00", bangkokLocation)
+		assert.NoError(t, err)
+		assert.True(t, bkk(20, 10, 0).Equal(got))
+	})
+
+	t.Run("explicit offset wins", func(t *testing.T) {
+		got, err := parseClientTime("2026-10-20T03:00:00Z", time.UTC)
+		assert.NoError(t, err)
+		assert.True(t, bkk(20, 10, 0).Equal(got))
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := parseClientTime("20/10/2026 10:00", bangkokLocation)
+		assert.Error(t, err)
+	})
+}
+
+func TestParseDateParam(t *testing.T) {
+	from, err := parseDateParam("2026-10-20", bangkokLocation, false)
+	assert.NoError(t, err)
+	assert.True(t, bkk(20, 0, 0).Equal(from))
+
+	to, err := parseDateParam("2026-10-20", bangkokLocation, true)
+	assert.NoError(t, err)
+	assert.True(t, bkk(21, 0, 0).Equal(to))
+
+	_, err = parseDateParam("tomorrow", bangkokLocation, false)
+	assert.Error(t, err)
+}
+
+// booking วันที่ 20 เวลา 10:00 (ไทย) ยกเลิกวันที่ 19 เวลา 11:00 (ไทย) = เหลือ 23 ชั่วโมง → อยู่ในช่วงค่าปรับ 24 ชม.
+func TestCancellationFeeWindowUsesLocalTime(t *testing.T) {
+	tiers := []CancellationTier{
+		{HoursBeforeBooking: 48, FeePercentage: 0.25},
+		{HoursBeforeBooking: 24, FeePercentage: 0.5},
+	}
+	now := bkk(19, 11, 0)
+
+	start, err := parseLocalDateTime("2026-10-20", "10:00", bangkokLocation)
+	assert.NoError(t, err)
+	assert.InDelta(t, 23, hoursUntilStart(start, now), 0.001)
+	assert.Equal(t, 0.5, cancellationFeeRate(tiers, hoursUntilStart(start, now)))
+
+	t.Run("reading the wall clock as UTC would move the booking 7 hours later", func(t *testing.T) {
+		utc, _ := parseLocalDateTime("2026-10-20", "10:00", time.UTC)
+		assert.InDelta(t, 30, hoursUntilStart(utc, now), 0.001)
+		assert.Equal(t, 0.25, cancellationFeeRate(tiers, hoursUntilStart(utc, now)))
+	})
+
+	t.Run("provider in another zone", func(t *testing.T) {
+		tokyo, _ := loadTimezone("Asia/Tokyo")
+		start, _ := parseLocalDateTime("2026-10-20", "10:00", tokyo) // = 08:00 เวลาไทย
+		assert.InDelta(t, 21, hoursUntilStart(start, now), 0.001)
+	})
+}
+
+func TestEscrowReminderMessageLocalTime(t *testing.T) {
+	deadline := time.Date(2026, 10, 20, 3, 0, 0, 0, time.UTC)
+
+	assert.Contains(t, escrowReminderMessage(42, deadline, "Asia/Bangkok"), "on 20 Oct 2026 10:00.")
+	assert.Contains(t, escrowReminderMessage(42, deadline, ""), "on 20 Oct 2026 10:00.")
+	assert.Contains(t, escrowReminderMessage(42, deadline, "Europe/London"), "on 20 Oct 2026 04:00.") // BST
+}
+
+func TestProviderTimezoneSlots(t *testing.T) {
+	tokyo, _ := loadTimezone("Asia/Tokyo")
+	open := time.Date(2026, 10, 20, 9, 0, 0, 0, tokyo)
+	free := []timeRange{{open, open.Add(2 * time.Hour)}}
+
+	slots := bookableSlots(free, time.Hour, time.Hour, open, open.Add(24*time.Hour), open.Add(-time.Hour), tokyo)
+	if assert.Len(t, slots, 2) {
+		assert.Equal(t, "2026-10-20", slots[0].BookingDate)
+		assert.Equal(t, "09:00", slots[0].StartClock)
+		assert.Equal(t, "+09:00", slots[0].StartTime.Format("-07:00"))
+	}
+
+	t.Run("recurring rules keep wall-clock time across DST changes", func(t *testing.T) {
+		ny, _ := loadTimezone("America/New_York")
+		sunday := RecurringScheduleRule{Weekday: 0, StartTime: "09:00", EndTime: "17:00", EffectiveFrom: "2026-01-01"}
+		// 1 พ.ย. 2026 สิ้นสุด DST (เวลาย้อน 1 ชม. ตอนตี 2)
+		from := time.Date(2026, 10, 25, 0, 0, 0, 0, ny)
+		windows := expandRecurringRules([]RecurringScheduleRule{sunday}, nil, nil, from, from.AddDate(0, 0, 8), ny)
+		if assert.Len(t, windows, 2) {
+			assert.Equal(t, 13, windows[0].Start.UTC().Hour()) // 09:00 EDT
+			assert.Equal(t, 14, windows[1].Start.UTC().Hour()) // 09:00 EST
+			assert.Equal(t, 8*time.Hour, windows[1].End.Sub(windows[1].Start))
+		}
+	})
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stripe/stripe-go/v78"
+	"github.com/stripe/stripe-go/v78/checkout/session"
+)
+
+// POST /bookings/:id/tip (ทิป provider หลังจบงาน: บัตร / PromptPay / wallet)
+func createBookingTipHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetInt("userID")
+		bookingID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid booking ID"})
+			return
+		}
+
+		var req struct {
+			Amount     float64 `json:"amount" binding:"required"`
+			Method     string  `json:"method" binding:"required,oneof=card promptpay wallet"`
+			Message    *string `json:"message"`
+			SuccessURL string  `json:"success_url"`
+			CancelURL  string  `json:"cancel_url"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		amount := roundSatang(req.Amount)
+		if amount < minTipAmount || amount > maxTipAmount {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": fmt.Sprintf("Tip must be between ฿%s and ฿%s", formatBaht(minTipAmount), formatBaht(maxTipAmount)),
+			})
+			return
+		}
+
+		var clientID, providerID int
+		var status string
+		err = dbPool.QueryRow(ctx, `
+			SELECT client_id, provider_id, status FROM bookings WHERE booking_id = $1
+		`, bookingID).Scan(&clientID, &providerID, &status)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Booking not found"})
+			return
+		}
+		if clientID != userID {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Only the client of this booking can tip"})
+			return
+		}
+		if !tippableBookingStatuses[status] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Tips are only possible after the booking is completed", "status": status})
+			return
+		}
+
+		tx, err := dbPool.Begin(ctx)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create tip"})
+			return
+		}
+		defer tx.Rollback(ctx)
+
+		var tipID int
+		err = tx.QueryRow(ctx, `
+			INSERT INTO booking_tips (booking_id, client_id, provider_id, amount, message, payment_method, status)
+			VALUES ($1, $2, $3, $4, $5, $6, 'pending')
+			RETURNING tip_id
+		`, bookingID, clientID, providerID, amount, req.Message, req.Method).Scan(&tipID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create tip"})
+			return
+		}
+
+		switch req.Method {
+		case TipByWallet:
+			// ตัดจาก wallet แล้วเข้า provider ทันที
+			_, err = payFromWallet(ctx, tx, userID, amount, &bookingID, fmt.Sprintf("Tip for booking #%d", bookingID))
+			if errors.Is(err, errInsufficientWalletBalance) {
+				c.JSON(http.StatusPaymentRequired, gin.H{"error": "Insufficient wallet balance"})
+				return
+			}
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to pay from wallet"})
+				return
+			}
+			tip, _, err := completeBookingTip(ctx, tx, tipID)
+			if err != nil || tx.Commit(ctx) != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record tip"})
+				return
+			}
+			notifyTipReceived(tip)
+			c.JSON(http.StatusCreated, gin.H{"message": "Tip sent", "tip": tip})
+
+		case TipByPromptPay:
+			target, err := platformPromptPayTarget()
+			if err != nil {
+				c.JSON(http.StatusServiceUnavailable, gin.H{"error": "PromptPay is not available", "details": err.Error()})
+				return
+			}
+			qrCode := buildPromptPayPayload(target, amount)
+			paymentReference := "TIP" + generatePaymentReference(tipID)[3:]
+			expiresAt := time.Now().Add(15 * time.Minute)
+
+			_, err = tx.Exec(ctx, `
+				INSERT INTO payments (tip_id, amount, payment_method, payment_status, payment_reference, qr_code, expires_at)
+				VALUES ($1, $2, 'promptpay', 'pending', $3, $4, $5)
+			`, tipID, amount, paymentReference, qrCode, expiresAt)
+			if err == nil {
+				_, err = tx.Exec(ctx, `UPDATE booking_tips SET payment_reference = $1 WHERE tip_id = $2`, paymentReference, tipID)
+			}
+			if err != nil || tx.Commit(ctx) != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create payment record"})
+				return
+			}
+			c.JSON(http.StatusCreated, gin.H{
+				"tip_id":            tipID,
+				"amount":            amount,
+				"qr_code":           qrCode,
+				"payment_reference": paymentReference,
+				"qr_image_url":      "/payments/" + paymentReference + "/qr.png",
+				"expires_at":        expiresAt.Format(time.RFC3339),
+				"message":           "Scan QR code to send the tip within 15 minutes",
+			})
+
+		default:
+			if err := tx.Commit(ctx); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create tip"})
+				return
+			}
+
+			successURL := req.SuccessURL
+			cancelURL := req.CancelURL
+			if successURL == "" {
+				successURL = fmt.Sprintf("http://localhost:5174/bookings/%d?tip=success", bookingID)
+			}
+			if cancelURL == "" {
+				cancelURL = fmt.Sprintf("http://localhost:5174/bookings/%d?tip=cancelled", bookingID)
+			}
+
+			params := &stripe.CheckoutSessionParams{
+				Mode: stripe.String(string(stripe.CheckoutSessionModePayment)),
+				LineItems: []*stripe.CheckoutSessionLineItemParams{
+					{
+						PriceData: &stripe.CheckoutSessionLineItemPriceDataParams{
+							Currency: stripe.String("thb"),
+							ProductData: &stripe.CheckoutSessionLineItemPriceDataProductDataParams{
+								Name: stripe.String(fmt.Sprintf("Tip - booking #%d", bookingID)),
+							},
+							UnitAmount: stripe.Int64(int64(math.Round(amount * 100))),
+						},
+						Quantity: stripe.Int64(1),
+					},
+				},
+				SuccessURL:        stripe.String(successURL),
+				CancelURL:         stripe.String(cancelURL),
+				ClientReferenceID: stripe.String(strconv.Itoa(userID)),
+				Metadata: map[string]string{
+					"payment_type": "tip",
+					"tip_id":       strconv.Itoa(tipID),
+					"booking_id":   strconv.Itoa(bookingID),
+				},
+			}
+
+			s, err := session.New(params)
+			if err != nil {
+				dbPool.Exec(ctx, `UPDATE booking_tips SET status = 'failed', updated_at = NOW() WHERE tip_id = $1`, tipID)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create payment session", "details": err.Error()})
+				return
+			}
+
+			c.JSON(http.StatusOK, gin.H{
+				"tip_id":       tipID,
+				"amount":       amount,
+				"checkout_url": s.URL,
+				"session_id":   s.ID,
+			})
+		}
+	}
+}
+
+// GET /bookings/:id/tips (client และ provider ของ booking)
+func getBookingTipsHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetInt("userID")
+
+		rows, err := dbPool.Query(ctx, `
+			SELECT `+bookingTipColumns+`
+			FROM booking_tips
+			WHERE booking_id = $1 AND (client_id = $2 OR provider_id = $2) AND status = 'paid'
+			ORDER BY created_at
+		`, c.Param("id"), userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch tips"})
+			return
+		}
+		defer rows.Close()
+
+		tips := make([]*BookingTip, 0)
+		var total float64
+		for rows.Next() {
+			if t, err := scanBookingTip(rows); err == nil {
+				total += t.Amount
+				tips = append(tips, t)
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{"tips": tips, "total_amount": roundSatang(total)})
+	}
+}
+
+// handleTipPayment - เรียกจาก paymentWebhookHandler() เมื่อ metadata.payment_type == "tip"
+func handleTipPayment(dbPool *pgxpool.Pool, ctx context.Context, checkoutSession stripe.CheckoutSession) error {
+	tipID, err := strconv.Atoi(checkoutSession.Metadata["tip_id"])
+	if err != nil {
+		return fmt.Errorf("invalid tip_id in metadata: %v", err)
+	}
+
+	tx, err := dbPool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	tip, credited, err := completeBookingTip(ctx, tx, tipID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("tip #%d not found", tipID)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to complete tip: %v", err)
+	}
+	if paid := float64(checkoutSession.AmountTotal) / 100; amountsDiffer(paid, tip.Amount) {
+		return fmt.Errorf("tip #%d paid ฿%.2f but expected ฿%.2f", tipID, paid, tip.Amount)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+
+	if credited {
+		notifyTipReceived(tip)
+	}
+	return nil
+}
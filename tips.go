@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ================================
+// Tips (ทิปให้ provider หลังจบงาน)
+// ================================
+
+const (
+	TipByCard      = "card"
+	TipByPromptPay = "promptpay"
+	TipByWallet    = "wallet"
+
+	minTipAmount = 20.0
+	maxTipAmount = 10000.0
+)
+
+// tippableBookingStatuses are bookings the client can still tip for
+var tippableBookingStatuses = map[string]bool{"completed": true, "funds_released": true}
+
+type BookingTip struct {
+	TipID              int        `json:"tip_id"`
+	BookingID          int        `json:"booking_id"`
+	ClientID           int        `json:"client_id"`
+	ProviderID         int        `json:"provider_id"`
+	Amount             float64    `json:"amount"`
+	Message            *string    `json:"message"`
+	PaymentMethod      string     `json:"payment_method"`
+	Status             string     `json:"status"` // pending, paid, expired, failed
+	PlatformCommission float64    `json:"platform_commission"`
+	GatewayFee         float64    `json:"gateway_fee"`
+	ProviderNet        float64    `json:"provider_net"`
+	PaymentReference   *string    `json:"payment_reference,omitempty"`
+	PaidAt             *time.Time `json:"paid_at"`
+	CreatedAt          time.Time  `json:"created_at"`
+}
+
+const bookingTipColumns = `
+	tip_id, booking_id, client_id, provider_id, amount, message, payment_method, status,
+	platform_commission, gateway_fee, provider_net, payment_reference, paid_at, created_at`
+
+func scanBookingTip(row pgx.Row) (*BookingTip, error) {
+	var t BookingTip
+	err := row.Scan(&t.TipID, &t.BookingID, &t.ClientID, &t.ProviderID, &t.Amount, &t.Message, &t.PaymentMethod,
+		&t.Status, &t.PlatformCommission, &t.GatewayFee, &t.ProviderNet, &t.PaymentReference, &t.PaidAt, &t.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// tipCommissionRates returns the active commission rule for tips (applies_to = 'tip').
+// Without a rule tips are free of platform commission and gateway fee.
+func tipCommissionRates(ctx context.Context, tx pgx.Tx) (platformRate, gatewayRate float64) {
+	err := tx.QueryRow(ctx, `
+		SELECT platform_rate, payment_gateway_rate
+		FROM commission_rules
+		WHERE applies_to = 'tip' AND is_active = true
+		  AND effective_from <= NOW() AND (effective_until IS NULL OR effective_until > NOW())
+		ORDER BY effective_from DESC
+		LIMIT 1
+	`).Scan(&platformRate, &gatewayRate)
+	if err != nil {
+		return 0, 0
+	}
+	return platformRate, gatewayRate
+}
+
+// splitTip applies the tip commission rule; the provider keeps the rest
+func splitTip(amount, platformRate, gatewayRate float64) (commission, gatewayFee, providerNet float64) {
+	commission = roundSatang(amount * platformRate)
+	gatewayFee = roundSatang(amount * gatewayRate)
+	providerNet = roundSatang(amount - commission - gatewayFee)
+	return
+}
+
+// completeBookingTip marks a tip paid and credits the provider's available balance (the booking
+// is already finished, so there is nothing left to hold it for). A tip that is no longer
+// pending (webhook retry) is returned unchanged with credited=false.
+func completeBookingTip(ctx context.Context, tx pgx.Tx, tipID int) (t *BookingTip, credited bool, err error) {
+	t, err = scanBookingTip(tx.QueryRow(ctx, `SELECT `+bookingTipColumns+` FROM booking_tips WHERE tip_id = $1 FOR UPDATE`, tipID))
+	if err != nil {
+		return nil, false, err
+	}
+	if t.Status == "paid" {
+		return t, false, nil
+	}
+
+	platformRate, gatewayRate := tipCommissionRates(ctx, tx)
+	if t.PaymentMethod == TipByWallet {
+		gatewayRate = 0 // จ่ายจาก wallet ไม่ผ่าน gateway
+	}
+	commission, gatewayFee, providerNet := splitTip(t.Amount, platformRate, gatewayRate)
+
+	t, err = scanBookingTip(tx.QueryRow(ctx, `
+		UPDATE booking_tips
+		SET status = 'paid', platform_commission = $2, gateway_fee = $3, provider_net = $4,
+		    paid_at = NOW(), updated_at = NOW()
+		WHERE tip_id = $1
+		RETURNING `+bookingTipColumns, tipID, commission, gatewayFee, providerNet))
+	if err != nil {
+		return nil, false, err
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO wallets (user_id, available_balance, pending_balance, total_earned)
+		VALUES ($1, $2, 0, $2)
+		ON CONFLICT (user_id)
+		DO UPDATE SET
+			available_balance = wallets.available_balance + $2,
+			total_earned = wallets.total_earned + $2,
+			updated_at = NOW()
+	`, t.ProviderID, providerNet)
+	if err != nil {
+		return nil, false, err
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO transactions (
+			user_id, type, status, amount, commission_amount, platform_commission, stripe_fee, net_amount,
+			booking_id, description, processed_at
+		) VALUES ($1, 'tip', 'completed', $2, $3, $3, $4, $5, $6, $7, NOW())
+	`, t.ProviderID, t.Amount, commission, gatewayFee, providerNet, t.BookingID,
+		fmt.Sprintf("Tip for booking #%d (%s)", t.BookingID, t.PaymentMethod))
+	if err != nil {
+		return nil, false, err
+	}
+
+	return t, true, nil
+}
+
+func notifyTipReceived(t *BookingTip) {
+	message := fmt.Sprintf("คุณได้รับทิป ฿%s จากการจอง #%d", formatBaht(t.Amount), t.BookingID)
+	if t.Message != nil && *t.Message != "" {
+		message += ": " + *t.Message
+	}
+	CreateNotification(t.ProviderID, "tip_received", message, map[string]interface{}{
+		"tip_id":     t.TipID,
+		"booking_id": t.BookingID,
+		"amount":     t.Amount,
+		"net_amount": t.ProviderNet,
+	})
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test the tip commission split
+func TestSplitTip(t *testing.T) {
+	t.Run("No Commission", func(t *testing.T) {
+		commission, gatewayFee, providerNet := splitTip(500, 0, 0)
+		assert.Equal(t, 0.0, commission)
+		assert.Equal(t, 0.0, gatewayFee)
+		assert.Equal(t, 500.0, providerNet)
+	})
+
+	t.Run("Gateway Fee Only", func(t *testing.T) {
+		commission, gatewayFee, providerNet := splitTip(333, 0, 0.0275)
+		assert.Equal(t, 0.0, commission)
+		assert.Equal(t, 9.16, gatewayFee)
+		assert.Equal(t, 323.84, providerNet)
+	})
+
+	t.Run("Platform And Gateway", func(t *testing.T) {
+		commission, gatewayFee, providerNet := splitTip(1000, 0.05, 0.0275)
+		assert.Equal(t, 50.0, commission)
+		assert.Equal(t, 27.5, gatewayFee)
+		assert.Equal(t, 922.5, providerNet)
+		assert.Equal(t, 1000.0, roundSatang(commission+gatewayFee+providerNet))
+	})
+}
+
+func TestTippableBookingStatuses(t *testing.T) {
+	for _, status := range []string{"completed", "funds_released"} {
+		assert.True(t, tippableBookingStatuses[status], status)
+	}
+	for _, status := range []string{"pending", "confirmed", "in_progress", "cancelled", "disputed"} {
+		assert.False(t, tippableBookingStatuses[status], status)
+	}
+}
@@ -0,0 +1,438 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ================================
+// Wallet Payments (จ่ายด้วยยอดใน wallet: เต็มจำนวนหรือบางส่วน)
+// ================================
+
+// จ่ายได้จาก credit_balance ก่อน แล้วจึง available_balance; pending_balance (รายได้ที่ยังติด hold 7 วัน) ใช้จ่ายไม่ได้
+//
+//   credit_balance    = เงินเติม / เงินคืน / รางวัลของ client → ใช้จ่ายในระบบได้ แต่ถอนออกไม่ได้
+//   available_balance = รายได้ที่ถอนได้ (withdrawal ใช้เฉพาะยอดนี้)
+//
+// wallet_payment transaction:
+//   pending   = กันเงินไว้ระหว่างรอจ่ายส่วนที่เหลือ (Stripe / PromptPay)
+//   completed = ตัดเงินจริงแล้ว
+//   cancelled = ปล่อยเงินคืนยอดเดิม (credit_amount กลับ credit_balance, ที่เหลือกลับ available_balance)
+
+// stripeMinimumChargeTHB is the smallest card charge Stripe accepts in THB
+const stripeMinimumChargeTHB = 10.0
+
+var (
+	errInsufficientWalletBalance = errors.New("insufficient wallet balance")
+	errInvalidWalletAmount       = errors.New("wallet amount must be positive")
+	errWalletHoldReleased        = errors.New("wallet hold was already released")
+	errCardPaymentUnavailable    = errors.New("card payment is not available for this purchase; pay the full amount from your wallet (use_wallet)")
+)
+
+// walletPaymentOption is the part of a checkout request that asks to pay from the wallet:
+// use_wallet spends as much as possible, wallet_amount spends an exact amount
+type walletPaymentOption struct {
+	UseWallet    bool     `json:"use_wallet"`
+	WalletAmount *float64 `json:"wallet_amount"`
+}
+
+func (o walletPaymentOption) requested() bool {
+	return o.UseWallet || o.WalletAmount != nil
+}
+
+// planWalletPayment splits a total into the part paid from the wallet and the part still to
+// charge externally. minExternal keeps the external part at or above the gateway minimum
+// (0 for PromptPay / no external charge).
+func planWalletPayment(total, available float64, opt walletPaymentOption, minExternal float64) (fromWallet, remaining float64, err error) {
+	total = roundSatang(total)
+	if !opt.requested() || total <= 0 {
+		return 0, total, nil
+	}
+
+	want := math.Min(roundSatang(available), total)
+	if opt.WalletAmount != nil {
+		if *opt.WalletAmount <= 0 {
+			return 0, total, errInvalidWalletAmount
+		}
+		want = math.Min(roundSatang(*opt.WalletAmount), total)
+		if want > roundSatang(available) {
+			return 0, total, errInsufficientWalletBalance
+		}
+	}
+
+	fromWallet = want
+	remaining = roundSatang(total - fromWallet)
+	if remaining > 0 && remaining < minExternal {
+		// ส่วนที่เหลือต่ำกว่าขั้นต่ำของ gateway → ลดส่วนที่จ่ายจาก wallet ลง
+		fromWallet = roundSatang(total - minExternal)
+		if fromWallet < 0 {
+			fromWallet = 0
+		}
+		remaining = roundSatang(total - fromWallet)
+	}
+	return fromWallet, remaining, nil
+}
+
+// walletPaymentMethod labels receipts and payment records
+func walletPaymentMethod(fromWallet, remaining float64, external string) string {
+	switch {
+	case fromWallet > 0 && remaining > 0:
+		return "wallet+" + external
+	case fromWallet > 0:
+		return "wallet"
+	default:
+		return external
+	}
+}
+
+// getWalletSpendableBalance returns credit + available balance (0 when the user has no wallet yet)
+func getWalletSpendableBalance(ctx context.Context, dbPool *pgxpool.Pool, userID interface{}) float64 {
+	var spendable float64
+	dbPool.QueryRow(ctx, `SELECT available_balance + credit_balance FROM wallets WHERE user_id = $1`, userID).Scan(&spendable)
+	return spendable
+}
+
+// debitWallet takes amount out of credit_balance first, then available_balance, and returns
+// the part taken from credit; fails instead of going negative
+func debitWallet(ctx context.Context, tx pgx.Tx, userID interface{}, amount float64) (fromCredit float64, err error) {
+	err = tx.QueryRow(ctx, `
+		WITH w AS (SELECT user_id, credit_balance FROM wallets WHERE user_id = $1 FOR UPDATE)
+		UPDATE wallets
+		SET credit_balance = wallets.credit_balance - LEAST(wallets.credit_balance, $2),
+		    available_balance = wallets.available_balance - GREATEST($2 - wallets.credit_balance, 0),
+		    updated_at = NOW()
+		FROM w
+		WHERE wallets.user_id = w.user_id AND wallets.available_balance + wallets.credit_balance >= $2
+		RETURNING LEAST(w.credit_balance, $2)
+	`, userID, amount).Scan(&fromCredit)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, errInsufficientWalletBalance
+	}
+	return fromCredit, err
+}
+
+// creditWalletCredit adds money the client put in or got back (top-up / refund / reward) to the
+// non-withdrawable credit_balance. It is not earnings, so total_earned is left alone.
+func creditWalletCredit(ctx context.Context, tx pgx.Tx, userID interface{}, amount float64) error {
+	_, err := tx.Exec(ctx, `
+		INSERT INTO wallets (user_id, available_balance, pending_balance, total_earned, credit_balance)
+		VALUES ($1, 0, 0, 0, $2)
+		ON CONFLICT (user_id)
+		DO UPDATE SET
+			credit_balance = wallets.credit_balance + $2,
+			updated_at = NOW()
+	`, userID, amount)
+	return err
+}
+
+// creditWalletAvailable gives back withdrawable money (e.g. a released hold that was paid from
+// earnings). total_earned is left alone.
+func creditWalletAvailable(ctx context.Context, tx pgx.Tx, userID interface{}, amount float64) error {
+	_, err := tx.Exec(ctx, `
+		INSERT INTO wallets (user_id, available_balance, pending_balance, total_earned)
+		VALUES ($1, $2, 0, 0)
+		ON CONFLICT (user_id)
+		DO UPDATE SET
+			available_balance = wallets.available_balance + $2,
+			updated_at = NOW()
+	`, userID, amount)
+	return err
+}
+
+// holdWalletFunds reserves amount for a checkout that still has an external part to pay
+func holdWalletFunds(ctx context.Context, tx pgx.Tx, userID interface{}, amount float64, bookingID *int, description string) (int, error) {
+	return insertWalletPayment(ctx, tx, userID, amount, bookingID, description, "pending")
+}
+
+// payFromWallet spends amount right away (no external part)
+func payFromWallet(ctx context.Context, tx pgx.Tx, userID interface{}, amount float64, bookingID *int, description string) (int, error) {
+	return insertWalletPayment(ctx, tx, userID, amount, bookingID, description, "completed")
+}
+
+func insertWalletPayment(ctx context.Context, tx pgx.Tx, userID interface{}, amount float64, bookingID *int, description, status string) (int, error) {
+	if amount <= 0 {
+		return 0, errInvalidWalletAmount
+	}
+	fromCredit, err := debitWallet(ctx, tx, userID, amount)
+	if err != nil {
+		return 0, err
+	}
+
+	var processedAt *time.Time
+	if status == "completed" {
+		now := time.Now()
+		processedAt = &now
+	}
+
+	var transactionID int
+	err = tx.QueryRow(ctx, `
+		INSERT INTO transactions (user_id, type, status, amount, net_amount, credit_amount, booking_id, description, processed_at)
+		VALUES ($1, 'wallet_payment', $2, $3, $3, $4, $5, $6, $7)
+		RETURNING transaction_id
+	`, userID, status, amount, fromCredit, bookingID, description, processedAt).Scan(&transactionID)
+	return transactionID, err
+}
+
+// captureWalletHold turns a hold into a completed payment and returns its amount.
+// Capturing an already captured hold is a no-op (webhook retry).
+func captureWalletHold(ctx context.Context, tx pgx.Tx, holdID int) (float64, error) {
+	var amount float64
+	err := tx.QueryRow(ctx, `
+		UPDATE transactions
+		SET status = 'completed', processed_at = COALESCE(processed_at, NOW())
+		WHERE transaction_id = $1 AND type::text = 'wallet_payment' AND status::text IN ('pending', 'completed')
+		RETURNING amount
+	`, holdID).Scan(&amount)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, errWalletHoldReleased
+	}
+	return amount, err
+}
+
+// releaseWalletHold gives a pending hold back to the balances it came from. Releasing twice is a no-op.
+func releaseWalletHold(ctx context.Context, tx pgx.Tx, holdID int) error {
+	var userID int
+	var amount, fromCredit float64
+	err := tx.QueryRow(ctx, `
+		UPDATE transactions
+		SET status = 'cancelled'
+		WHERE transaction_id = $1 AND type::text = 'wallet_payment' AND status::text = 'pending'
+		RETURNING user_id, amount, COALESCE(credit_amount, 0)
+	`, holdID).Scan(&userID, &amount, &fromCredit)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if fromCredit > 0 {
+		if err := creditWalletCredit(ctx, tx, userID, fromCredit); err != nil {
+			return err
+		}
+	}
+	if rest := roundSatang(amount - fromCredit); rest > 0 {
+		return creditWalletAvailable(ctx, tx, userID, rest)
+	}
+	return nil
+}
+
+// captureWalletHoldByRef captures the hold referenced in checkout metadata ("" = no wallet part)
+func captureWalletHoldByRef(ctx context.Context, dbPool *pgxpool.Pool, ref string) (float64, error) {
+	if ref == "" {
+		return 0, nil
+	}
+	holdID, err := strconv.Atoi(ref)
+	if err != nil {
+		return 0, fmt.Errorf("invalid wallet_hold_id: %v", err)
+	}
+
+	tx, err := dbPool.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	amount, err := captureWalletHold(ctx, tx, holdID)
+	if err != nil {
+		return 0, err
+	}
+	return amount, tx.Commit(ctx)
+}
+
+// releaseWalletHoldByRef releases the hold referenced in checkout metadata ("" = no wallet part)
+func releaseWalletHoldByRef(ctx context.Context, dbPool *pgxpool.Pool, ref string) error {
+	if ref == "" {
+		return nil
+	}
+	holdID, err := strconv.Atoi(ref)
+	if err != nil {
+		return fmt.Errorf("invalid wallet_hold_id: %v", err)
+	}
+
+	tx, err := dbPool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := releaseWalletHold(ctx, tx, holdID); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// holdWalletForCheckout reserves the wallet part of a checkout in its own transaction
+func holdWalletForCheckout(ctx context.Context, dbPool *pgxpool.Pool, userID interface{}, amount float64, bookingID *int, description string) (int, error) {
+	tx, err := dbPool.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	holdID, err := holdWalletFunds(ctx, tx, userID, amount, bookingID, description)
+	if err != nil {
+		return 0, err
+	}
+	return holdID, tx.Commit(ctx)
+}
+
+// payFromWalletNow spends amount in its own transaction
+func payFromWalletNow(ctx context.Context, dbPool *pgxpool.Pool, userID interface{}, amount float64, bookingID *int, description string) (int, error) {
+	tx, err := dbPool.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	transactionID, err := payFromWallet(ctx, tx, userID, amount, bookingID, description)
+	if err != nil {
+		return 0, err
+	}
+	return transactionID, tx.Commit(ctx)
+}
+
+// creditWalletRefund puts a refund into the client's wallet credit (usable immediately, not withdrawable)
+func creditWalletRefund(ctx context.Context, tx pgx.Tx, userID int, amount float64, bookingID *int, description string) error {
+	if amount <= 0 {
+		return nil
+	}
+	if err := creditWalletCredit(ctx, tx, userID, amount); err != nil {
+		return err
+	}
+	_, err := tx.Exec(ctx, `
+		INSERT INTO transactions (user_id, type, status, amount, net_amount, booking_id, description, processed_at)
+		VALUES ($1, 'wallet_refund', 'completed', $2, $2, $3, $4, NOW())
+	`, userID, amount, bookingID, description)
+	return err
+}
+
+// recordClientRefund refunds a client either into their wallet right away or as a pending
+// booking_refund that finance sends back to the original payment method
+func recordClientRefund(ctx context.Context, tx pgx.Tx, bookingID, clientID int, amount float64, reason string, toWallet bool) error {
+	if amount <= 0 {
+		return nil
+	}
+	if toWallet {
+		return creditWalletRefund(ctx, tx, clientID, amount, &bookingID, reason)
+	}
+	_, err := tx.Exec(ctx, `
+		INSERT INTO transactions (user_id, type, status, amount, net_amount, booking_id, description)
+		VALUES ($1, 'booking_refund', 'pending', $2, $2, $3, $4)
+	`, clientID, amount, bookingID, reason)
+	return err
+}
+
+// refundDestination is the label returned to clients for where a refund went
+func refundDestination(toWallet bool) string {
+	if toWallet {
+		return "wallet"
+	}
+	return "original_payment_method"
+}
+
+// ================================
+// Expired checkouts: ปล่อยเงินที่กันไว้คืน wallet
+// ================================
+
+// expireStalePayments expires PromptPay payments past their QR deadline (all of them, or just
+// paymentID when it is not 0), releasing any wallet hold and marking top-ups and tips expired
+func expireStalePayments(ctx context.Context, dbPool *pgxpool.Pool, paymentID int) (int, error) {
+	tx, err := dbPool.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, `
+		UPDATE payments
+		SET payment_status = 'expired', updated_at = NOW()
+		WHERE payment_status = 'pending' AND expires_at < NOW()
+		  AND ($1 = 0 OR payment_id = $1)
+		RETURNING wallet_hold_id, topup_id, tip_id, booking_id
+	`, paymentID)
+	if err != nil {
+		return 0, err
+	}
+	var expired int
+	var holdIDs, topupIDs, tipIDs, bookingIDs []int
+	for rows.Next() {
+		expired++
+		var holdID, topupID, tipID, bookingID *int
+		if err := rows.Scan(&holdID, &topupID, &tipID, &bookingID); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if holdID != nil {
+			holdIDs = append(holdIDs, *holdID)
+		}
+		if topupID != nil {
+			topupIDs = append(topupIDs, *topupID)
+		}
+		if tipID != nil {
+			tipIDs = append(tipIDs, *tipID)
+		}
+		if bookingID != nil {
+			bookingIDs = append(bookingIDs, *bookingID)
+		}
+	}
+	rows.Close()
+
+	for _, holdID := range holdIDs {
+		if err := releaseWalletHold(ctx, tx, holdID); err != nil {
+			return 0, err
+		}
+	}
+	if len(topupIDs) > 0 {
+		_, err = tx.Exec(ctx, `
+			UPDATE wallet_topups SET status = 'expired', updated_at = NOW()
+			WHERE topup_id = ANY($1) AND status = 'pending'
+		`, topupIDs)
+		if err != nil {
+			return 0, err
+		}
+	}
+	if len(tipIDs) > 0 {
+		_, err = tx.Exec(ctx, `
+			UPDATE booking_tips SET status = 'expired', updated_at = NOW()
+			WHERE tip_id = ANY($1) AND status = 'pending'
+		`, tipIDs)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	// booking ที่รอจ่ายด้วย QR นี้ → หมดอายุ คืนช่วงเวลาให้คนอื่นจองได้
+	for _, bookingID := range bookingIDs {
+		tag, err := tx.Exec(ctx, `
+			UPDATE bookings SET status = 'expired', updated_at = NOW()
+			WHERE booking_id = $1 AND status = 'pending_payment'
+		`, bookingID)
+		if err == nil && tag.RowsAffected() > 0 {
+			err = releaseBookingDiscounts(ctx, tx, bookingID)
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return expired, tx.Commit(ctx)
+}
+
+// startPaymentExpiryScheduler expires abandoned PromptPay QR payments and releases their wallet holds
+func startPaymentExpiryScheduler(dbPool *pgxpool.Pool, ctx context.Context) {
+	runPeriodically(ctx, "payment-expiry", 5*time.Minute, func(ctx context.Context) error {
+		n, err := expireStalePayments(ctx, dbPool, 0)
+		if n > 0 {
+			log.Printf("⌛ Expired %d stale payment(s)", n)
+		}
+		return err
+	})
+}
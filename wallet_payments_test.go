@@ -0,0 +1,90 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test splitting a checkout between wallet and external payment
+func TestPlanWalletPayment(t *testing.T) {
+	amount := func(v float64) *float64 { return &v }
+
+	t.Run("Wallet Not Requested", func(t *testing.T) {
+		fromWallet, remaining, err := planWalletPayment(1500, 5000, walletPaymentOption{}, stripeMinimumChargeTHB)
+		assert.NoError(t, err)
+		assert.Equal(t, 0.0, fromWallet)
+		assert.Equal(t, 1500.0, remaining)
+	})
+
+	t.Run("Use Whole Balance", func(t *testing.T) {
+		fromWallet, remaining, err := planWalletPayment(1500, 400.5, walletPaymentOption{UseWallet: true}, stripeMinimumChargeTHB)
+		assert.NoError(t, err)
+		assert.Equal(t, 400.5, fromWallet)
+		assert.Equal(t, 1099.5, remaining)
+	})
+
+	t.Run("Balance Covers Everything", func(t *testing.T) {
+		fromWallet, remaining, err := planWalletPayment(1500, 5000, walletPaymentOption{UseWallet: true}, stripeMinimumChargeTHB)
+		assert.NoError(t, err)
+		assert.Equal(t, 1500.0, fromWallet)
+		assert.Equal(t, 0.0, remaining)
+	})
+
+	t.Run("Exact Amount", func(t *testing.T) {
+		fromWallet, remaining, err := planWalletPayment(1500, 5000, walletPaymentOption{WalletAmount: amount(200)}, stripeMinimumChargeTHB)
+		assert.NoError(t, err)
+		assert.Equal(t, 200.0, fromWallet)
+		assert.Equal(t, 1300.0, remaining)
+	})
+
+	t.Run("Exact Amount Above Balance", func(t *testing.T) {
+		_, _, err := planWalletPayment(1500, 100, walletPaymentOption{WalletAmount: amount(200)}, stripeMinimumChargeTHB)
+		assert.ErrorIs(t, err, errInsufficientWalletBalance)
+
+		_, _, err = planWalletPayment(1500, 100, walletPaymentOption{WalletAmount: amount(-5)}, stripeMinimumChargeTHB)
+		assert.ErrorIs(t, err, errInvalidWalletAmount)
+	})
+
+	t.Run("Card Part Kept Above Stripe Minimum", func(t *testing.T) {
+		fromWallet, remaining, err := planWalletPayment(1500, 1495, walletPaymentOption{UseWallet: true}, stripeMinimumChargeTHB)
+		assert.NoError(t, err)
+		assert.Equal(t, 1490.0, fromWallet)
+		assert.Equal(t, 10.0, remaining)
+
+		// PromptPay ไม่มีขั้นต่ำ
+		fromWallet, remaining, _ = planWalletPayment(1500, 1495, walletPaymentOption{UseWallet: true}, 0)
+		assert.Equal(t, 1495.0, fromWallet)
+		assert.Equal(t, 5.0, remaining)
+	})
+}
+
+func TestWalletPaymentMethod(t *testing.T) {
+	assert.Equal(t, "card", walletPaymentMethod(0, 500, "card"))
+	assert.Equal(t, "wallet", walletPaymentMethod(500, 0, "card"))
+	assert.Equal(t, "wallet+promptpay", walletPaymentMethod(100, 400, "promptpay"))
+}
+
+func TestBookingPaymentFees(t *testing.T) {
+	t.Run("Card Only", func(t *testing.T) {
+		gatewayFee, commission, net, rate := bookingPaymentFees(1000, 0, 0.10, 0.0275)
+		assert.Equal(t, 27.5, gatewayFee)
+		assert.Equal(t, 100.0, commission)
+		assert.Equal(t, 872.5, net)
+		assert.Equal(t, 0.1275, rate)
+	})
+
+	t.Run("Gateway Fee Only On Card Part", func(t *testing.T) {
+		gatewayFee, commission, net, rate := bookingPaymentFees(1000, 600, 0.10, 0.0275)
+		assert.Equal(t, 11.0, gatewayFee)
+		assert.Equal(t, 100.0, commission)
+		assert.Equal(t, 889.0, net)
+		assert.Equal(t, 0.111, rate)
+	})
+
+	t.Run("Wallet Only Has No Gateway Fee", func(t *testing.T) {
+		gatewayFee, _, net, _ := bookingPaymentFees(1000, 1000, 0.10, 0.0275)
+		assert.Equal(t, 0.0, gatewayFee)
+		assert.Equal(t, 900.0, net)
+	})
+}
@@ -0,0 +1,311 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stripe/stripe-go/v78"
+	"github.com/stripe/stripe-go/v78/checkout/session"
+)
+
+// ================================
+// Wallet Top-up (เติมเงินเข้า wallet ผ่าน Stripe หรือ PromptPay)
+// ================================
+
+const (
+	minWalletTopup = 20.0
+	maxWalletTopup = 50000.0
+)
+
+const walletTopupColumns = `topup_id, user_id, amount, method, status, payment_reference, stripe_session_id, completed_at, created_at`
+
+func scanWalletTopup(row pgx.Row) (*WalletTopup, error) {
+	var t WalletTopup
+	err := row.Scan(&t.TopupID, &t.UserID, &t.Amount, &t.Method, &t.Status, &t.PaymentReference,
+		&t.StripeSessionID, &t.CompletedAt, &t.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// platformPromptPayTarget is where PromptPay top-ups are paid to (the platform, not a provider)
+func platformPromptPayTarget() (PromptPayTarget, error) {
+	id := os.Getenv("PLATFORM_PROMPTPAY_ID")
+	if id == "" {
+		id = os.Getenv("PLATFORM_TAX_ID")
+	}
+	if id == "" {
+		return PromptPayTarget{}, errors.New("platform PromptPay account is not configured")
+	}
+	return parsePromptPayTarget(id, os.Getenv("PLATFORM_PROMPTPAY_TYPE"))
+}
+
+// POST /wallet/topup
+func createWalletTopupHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetInt("userID")
+
+		var req struct {
+			Amount     float64 `json:"amount" binding:"required"`
+			Method     string  `json:"method" binding:"required,oneof=stripe promptpay"`
+			SuccessURL string  `json:"success_url"`
+			CancelURL  string  `json:"cancel_url"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		amount := roundSatang(req.Amount)
+		if amount < minWalletTopup || amount > maxWalletTopup {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": fmt.Sprintf("Top-up amount must be between ฿%s and ฿%s", formatBaht(minWalletTopup), formatBaht(maxWalletTopup)),
+			})
+			return
+		}
+
+		if req.Method == "promptpay" {
+			createPromptPayTopup(c, dbPool, ctx, userID, amount)
+			return
+		}
+
+		var topupID int
+		err := dbPool.QueryRow(ctx, `
+			INSERT INTO wallet_topups (user_id, amount, method, status)
+			VALUES ($1, $2, 'stripe', 'pending')
+			RETURNING topup_id
+		`, userID, amount).Scan(&topupID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create top-up"})
+			return
+		}
+
+		successURL := req.SuccessURL
+		cancelURL := req.CancelURL
+		if successURL == "" {
+			successURL = "http://localhost:5174/wallet?topup=success"
+		}
+		if cancelURL == "" {
+			cancelURL = "http://localhost:5174/wallet?topup=cancelled"
+		}
+
+		params := &stripe.CheckoutSessionParams{
+			Mode: stripe.String(string(stripe.CheckoutSessionModePayment)),
+			LineItems: []*stripe.CheckoutSessionLineItemParams{
+				{
+					PriceData: &stripe.CheckoutSessionLineItemPriceDataParams{
+						Currency: stripe.String("thb"),
+						ProductData: &stripe.CheckoutSessionLineItemPriceDataProductDataParams{
+							Name: stripe.String("Wallet top-up"),
+						},
+						UnitAmount: stripe.Int64(int64(math.Round(amount * 100))),
+					},
+					Quantity: stripe.Int64(1),
+				},
+			},
+			SuccessURL:        stripe.String(successURL),
+			CancelURL:         stripe.String(cancelURL),
+			ClientReferenceID: stripe.String(strconv.Itoa(userID)),
+			Metadata: map[string]string{
+				"payment_type": "wallet_topup",
+				"topup_id":     strconv.Itoa(topupID),
+			},
+		}
+
+		s, err := session.New(params)
+		if err != nil {
+			dbPool.Exec(ctx, `UPDATE wallet_topups SET status = 'failed', updated_at = NOW() WHERE topup_id = $1`, topupID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create payment session", "details": err.Error()})
+			return
+		}
+		dbPool.Exec(ctx, `UPDATE wallet_topups SET stripe_session_id = $1 WHERE topup_id = $2`, s.ID, topupID)
+
+		c.JSON(http.StatusOK, gin.H{
+			"topup_id":     topupID,
+			"amount":       amount,
+			"checkout_url": s.URL,
+			"session_id":   s.ID,
+		})
+	}
+}
+
+// createPromptPayTopup creates the top-up and a payments row with the QR, so the existing
+// /payments/:payment_reference routes (status, QR image, slip upload) work for it too
+func createPromptPayTopup(c *gin.Context, dbPool *pgxpool.Pool, ctx context.Context, userID int, amount float64) {
+	target, err := platformPromptPayTarget()
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "PromptPay top-up is not available", "details": err.Error()})
+		return
+	}
+
+	tx, err := dbPool.Begin(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create top-up"})
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	var topupID int
+	err = tx.QueryRow(ctx, `
+		INSERT INTO wallet_topups (user_id, amount, method, status)
+		VALUES ($1, $2, 'promptpay', 'pending')
+		RETURNING topup_id
+	`, userID, amount).Scan(&topupID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create top-up"})
+		return
+	}
+
+	qrCode := buildPromptPayPayload(target, amount)
+	paymentReference := "TOP" + generatePaymentReference(topupID)[3:]
+	expiresAt := time.Now().Add(15 * time.Minute)
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO payments (topup_id, amount, payment_method, payment_status, payment_reference, qr_code, expires_at)
+		VALUES ($1, $2, 'promptpay', 'pending', $3, $4, $5)
+	`, topupID, amount, paymentReference, qrCode, expiresAt)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create payment record"})
+		return
+	}
+	_, err = tx.Exec(ctx, `UPDATE wallet_topups SET payment_reference = $1 WHERE topup_id = $2`, paymentReference, topupID)
+	if err != nil || tx.Commit(ctx) != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create top-up"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"topup_id":          topupID,
+		"amount":            amount,
+		"qr_code":           qrCode,
+		"payment_reference": paymentReference,
+		"qr_image_url":      "/payments/" + paymentReference + "/qr.png",
+		"expires_at":        expiresAt.Format(time.RFC3339),
+		"message":           "Scan QR code to top up within 15 minutes",
+	})
+}
+
+// GET /wallet/topups
+func getMyWalletTopupsHandler(dbPool *pgxpool.Pool, ctx context.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetInt("userID")
+
+		rows, err := dbPool.Query(ctx, `
+			SELECT `+walletTopupColumns+`
+			FROM wallet_topups
+			WHERE user_id = $1
+			ORDER BY created_at DESC
+			LIMIT 100
+		`, userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch top-ups"})
+			return
+		}
+		defer rows.Close()
+
+		topups := make([]*WalletTopup, 0)
+		for rows.Next() {
+			if t, err := scanWalletTopup(rows); err == nil {
+				topups = append(topups, t)
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{"topups": topups, "total": len(topups)})
+	}
+}
+
+// completeWalletTopup credits a paid top-up to credit_balance (spendable, not withdrawable). A top-up that is no longer
+// pending (webhook retry) is returned unchanged with credited=false.
+func completeWalletTopup(ctx context.Context, tx pgx.Tx, topupID int) (t *WalletTopup, credited bool, err error) {
+	t, err = scanWalletTopup(tx.QueryRow(ctx, `
+		UPDATE wallet_topups
+		SET status = 'completed', completed_at = NOW(), updated_at = NOW()
+		WHERE topup_id = $1 AND status IN ('pending', 'expired')
+		RETURNING `+walletTopupColumns, topupID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		t, err = scanWalletTopup(tx.QueryRow(ctx, `SELECT `+walletTopupColumns+` FROM wallet_topups WHERE topup_id = $1`, topupID))
+		return t, false, err
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	if err := creditWalletCredit(ctx, tx, t.UserID, t.Amount); err != nil {
+		return nil, false, err
+	}
+	_, err = tx.Exec(ctx, `
+		INSERT INTO transactions (user_id, type, status, amount, net_amount, description, processed_at)
+		VALUES ($1, 'wallet_topup', 'completed', $2, $2, $3, NOW())
+	`, t.UserID, t.Amount, fmt.Sprintf("Wallet top-up #%d (%s)", t.TopupID, t.Method))
+	if err != nil {
+		return nil, false, err
+	}
+	return t, true, nil
+}
+
+func notifyWalletTopup(t *WalletTopup) {
+	CreateNotification(t.UserID, "wallet_topup_completed",
+		fmt.Sprintf("เติมเงินเข้า wallet สำเร็จ ฿%s", formatBaht(t.Amount)),
+		map[string]interface{}{"topup_id": t.TopupID, "amount": t.Amount})
+}
+
+// handleWalletTopupPayment - เรียกจาก paymentWebhookHandler() เมื่อ metadata.payment_type == "wallet_topup"
+func handleWalletTopupPayment(dbPool *pgxpool.Pool, ctx context.Context, checkoutSession stripe.CheckoutSession) error {
+	topupID, err := strconv.Atoi(checkoutSession.Metadata["topup_id"])
+	if err != nil {
+		return fmt.Errorf("invalid topup_id in metadata: %v", err)
+	}
+
+	tx, err := dbPool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	t, credited, err := completeWalletTopup(ctx, tx, topupID)
+	if err != nil {
+		return fmt.Errorf("failed to complete top-up: %v", err)
+	}
+	if paid := float64(checkoutSession.AmountTotal) / 100; amountsDiffer(paid, t.Amount) {
+		return fmt.Errorf("top-up #%d paid ฿%.2f but expected ฿%.2f", topupID, paid, t.Amount)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+
+	if credited {
+		notifyWalletTopup(t)
+	}
+	return nil
+}
+
+// handleCheckoutSessionExpired - checkout ที่ไม่ได้จ่าย: ปล่อยเงิน wallet ที่กันไว้ และปิด top-up / ทิป
+func handleCheckoutSessionExpired(dbPool *pgxpool.Pool, ctx context.Context, checkoutSession stripe.CheckoutSession) error {
+	if err := releaseWalletHoldByRef(ctx, dbPool, checkoutSession.Metadata["wallet_hold_id"]); err != nil {
+		return fmt.Errorf("failed to release wallet hold: %v", err)
+	}
+	if checkoutSession.Metadata["payment_type"] == "wallet_topup" {
+		_, err := dbPool.Exec(ctx, `
+			UPDATE wallet_topups SET status = 'expired', updated_at = NOW()
+			WHERE topup_id = $1 AND status = 'pending'
+		`, checkoutSession.Metadata["topup_id"])
+		return err
+	}
+	if checkoutSession.Metadata["payment_type"] == "tip" {
+		_, err := dbPool.Exec(ctx, `
+			UPDATE booking_tips SET status = 'expired', updated_at = NOW()
+			WHERE tip_id = $1 AND status = 'pending'
+		`, checkoutSession.Metadata["tip_id"])
+		return err
+	}
+	return nil
+}